@@ -27,12 +27,18 @@ import (
 	_ "sofastack.io/sofa-mosn/pkg/buffer"
 	_ "sofastack.io/sofa-mosn/pkg/filter/network/proxy"
 	_ "sofastack.io/sofa-mosn/pkg/filter/network/tcpproxy"
+	_ "sofastack.io/sofa-mosn/pkg/filter/network/udpproxy"
+	_ "sofastack.io/sofa-mosn/pkg/filter/stream/cors"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/faultinject"
+	_ "sofastack.io/sofa-mosn/pkg/filter/stream/gzip"
+	_ "sofastack.io/sofa-mosn/pkg/filter/stream/headermutation"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/healthcheck/sofarpc"
+	_ "sofastack.io/sofa-mosn/pkg/filter/stream/localratelimit"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/mixer"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/payloadlimit"
 	_ "sofastack.io/sofa-mosn/pkg/metrics/sink"
 	_ "sofastack.io/sofa-mosn/pkg/metrics/sink/prometheus"
+	_ "sofastack.io/sofa-mosn/pkg/metrics/sink/statsd"
 	_ "sofastack.io/sofa-mosn/pkg/network"
 	_ "sofastack.io/sofa-mosn/pkg/protocol"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/http/conv"
@@ -48,9 +54,11 @@ import (
 	_ "sofastack.io/sofa-mosn/pkg/upstream/healthcheck"
 	_ "sofastack.io/sofa-mosn/pkg/xds"
 
+	_ "sofastack.io/sofa-mosn/pkg/trace/skywalking"
 	_ "sofastack.io/sofa-mosn/pkg/trace/sofa/http"
 	_ "sofastack.io/sofa-mosn/pkg/trace/sofa/rpc"
 	_ "sofastack.io/sofa-mosn/pkg/trace/sofa/rpc/ext"
+	_ "sofastack.io/sofa-mosn/pkg/trace/zipkin"
 )
 
 var Version = "0.4.0"
@@ -17,12 +17,10 @@ import (
 	"sofastack.io/sofa-mosn/pkg/protocol/serialize"
 	"sofastack.io/sofa-mosn/pkg/stream"
 	_ "sofastack.io/sofa-mosn/pkg/stream/sofarpc"
-	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 type Client struct {
-	Client stream.Client
-	conn   types.ClientConnection
+	Client *stream.SyncClient
 	Id     uint64
 }
 
@@ -35,12 +33,19 @@ func NewClient(addr string) *Client {
 		fmt.Println(err)
 		return nil
 	}
-	c.Client = stream.NewStreamClient(context.Background(), protocol.SofaRPC, conn, nil)
-	c.conn = conn
+	c.Client = stream.NewSyncClient(stream.NewStreamClient(context.Background(), protocol.SofaRPC, conn, nil))
 	return c
 }
 
-func (c *Client) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+func (c *Client) Request() {
+	c.Id++
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	headers, _, err := c.Client.Call(ctx, buildBoltV1Request(c.Id), nil)
+	if err != nil {
+		fmt.Println("[RPC Client] Request failed:", err)
+		return
+	}
 	fmt.Printf("[RPC Client] Receive Data:")
 	if cmd, ok := headers.(sofarpc.SofaRpcCmd); ok {
 		streamID := protocol.StreamIDConv(cmd.RequestID())
@@ -51,15 +56,6 @@ func (c *Client) OnReceive(ctx context.Context, headers types.HeaderMap, data ty
 	}
 }
 
-func (c *Client) OnDecodeError(context context.Context, err error, headers types.HeaderMap) {}
-
-func (c *Client) Request() {
-	c.Id++
-	requestEncoder := c.Client.NewStream(context.Background(), c)
-	headers := buildBoltV1Request(c.Id)
-	requestEncoder.AppendHeaders(context.Background(), headers, true)
-}
-
 func buildBoltV1Request(requestID uint64) *sofarpc.BoltRequest {
 	request := &sofarpc.BoltRequest{
 		Protocol: sofarpc.PROTOCOL_CODE_V1,
@@ -74,7 +70,7 @@ func buildBoltV1Request(requestID uint64) *sofarpc.BoltRequest {
 	headers := map[string]string{"service": "testSofa"} // used for sofa routing
 
 	buf := buffer.NewIoBuffer(100)
-	if err := serialize.Instance.SerializeMap(headers, buf); err != nil {
+	if err := serialize.GetSerialization(request.Codec).SerializeMap(headers, buf); err != nil {
 		panic("serialize headers error")
 	} else {
 		request.HeaderMap = buf.Bytes()
@@ -91,11 +87,10 @@ func main() {
 	if client := NewClient("127.0.0.1:2045"); client != nil {
 		for {
 			client.Request()
-			time.Sleep(200 * time.Millisecond)
 			if !*t {
-				time.Sleep(3 * time.Second)
 				return
 			}
+			time.Sleep(200 * time.Millisecond)
 		}
 	}
 }
@@ -7,21 +7,21 @@ import (
 	"net"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc"
 	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc/codec"
 	"sofastack.io/sofa-mosn/pkg/protocol/serialize"
 	"sofastack.io/sofa-mosn/pkg/stream"
 	_ "sofastack.io/sofa-mosn/pkg/stream/sofarpc"
-	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 type Client struct {
-	Codec stream.Client
-	conn  types.ClientConnection
-	Id    uint64
+	Client *stream.SyncClient
+	Id     uint64
 }
 
 func NewClient(addr string) *Client {
@@ -33,27 +33,27 @@ func NewClient(addr string) *Client {
 		fmt.Println(err)
 		return nil
 	}
-	c.Codec = stream.NewStreamClient(context.Background(), protocol.SofaRPC, conn, nil)
-	c.conn = conn
+	c.Client = stream.NewSyncClient(stream.NewStreamClient(context.Background(), protocol.SofaRPC, conn, nil))
 	return c
 }
 
-func (c *Client) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
-	fmt.Printf("[RPC Client] Receive Data:")
-	if streamID, ok := headers.Get(sofarpc.SofaPropertyHeader(sofarpc.HeaderReqID)); ok {
-		fmt.Println(streamID)
-	}
-
-	fmt.Println("Response Headers are:", headers)
-}
-
-func (c *Client) OnDecodeError(context context.Context, err error, headers types.HeaderMap) {}
-
 func (c *Client) Request() {
 	c.Id++
-	requestEncoder := c.Codec.NewStream(context.Background(), c)
-	headers := buildBoltV1Request(c.Id)
-	requestEncoder.AppendHeaders(context.Background(), headers, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	headers, _, err := c.Client.Call(ctx, buildBoltV1Request(c.Id), nil)
+	if err != nil {
+		fmt.Println("[RPC Client] Request failed:", err)
+		return
+	}
+	fmt.Printf("[RPC Client] Receive Data:")
+	if cmd, ok := headers.(sofarpc.SofaRpcCmd); ok {
+		streamID := protocol.StreamIDConv(cmd.RequestID())
+
+		if resp, ok := cmd.(rpc.RespStatus); ok {
+			fmt.Println("stream:", streamID, " status:", resp.RespStatus())
+		}
+	}
 }
 
 func buildBoltV1Request(requestID uint64) *sofarpc.BoltRequest {
@@ -69,11 +69,12 @@ func buildBoltV1Request(requestID uint64) *sofarpc.BoltRequest {
 
 	headers := map[string]string{"service": "testSofa"} // used for sofa routing
 
-	if headerBytes, err := serialize.Instance.Serialize(headers); err != nil {
+	buf := buffer.NewIoBuffer(100)
+	if err := serialize.GetSerialization(request.Codec).SerializeMap(headers, buf); err != nil {
 		panic("serialize headers error")
 	} else {
-		request.HeaderMap = headerBytes
-		request.HeaderLen = int16(len(headerBytes))
+		request.HeaderMap = buf.Bytes()
+		request.HeaderLen = int16(buf.Len())
 	}
 
 	return request
@@ -86,11 +87,10 @@ func main() {
 	if client := NewClient("127.0.0.1:2045"); client != nil {
 		for {
 			client.Request()
-			time.Sleep(200 * time.Millisecond)
 			if !*t {
-				time.Sleep(3 * time.Second)
 				return
 			}
+			time.Sleep(200 * time.Millisecond)
 		}
 	}
 }
@@ -21,13 +21,21 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 
 	"sofastack.io/sofa-mosn/pkg/admin/store"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/metrics/sink/console"
+	"sofastack.io/sofa-mosn/pkg/overload"
+	"sofastack.io/sofa-mosn/pkg/server/keeper"
 	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
 )
 
 var levelMap = map[string]log.Level{
@@ -50,7 +58,27 @@ func configDump(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	if buf, err := store.Dump(); err == nil {
+	// resource=all|clusters|listeners|routers dumps the running config,
+	// otherwise the effective config stored is dumped
+	var buf []byte
+	var err error
+	switch resource := r.URL.Query().Get("resource"); resource {
+	case "":
+		if buf, err = store.Dump(); err == nil {
+			buf, err = config.Redact(buf)
+		}
+	case "all":
+		buf, err = config.DumpEffectiveConfig("")
+	case config.ResourceClusters, config.ResourceListeners, config.ResourceRouters:
+		buf, err = config.DumpEffectiveConfig(resource)
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: unknown resource: %s", "config dump", resource)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "unknown resource")
+		fmt.Fprint(w, msg)
+		return
+	}
+	if err == nil {
 		log.DefaultLogger.Infof("[admin api] [config dump] config dump")
 		w.WriteHeader(200)
 		w.Write(buf)
@@ -62,6 +90,49 @@ func configDump(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// configDiff returns the differences between the running config and the config file
+func configDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "config diff", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	diff, err := config.DiffConfigFile()
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "config diff", err)
+		w.WriteHeader(500)
+		msg := fmt.Sprintf(errMsgFmt, "internal error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	buf, _ := json.Marshal(diff)
+	log.DefaultLogger.Infof("[admin api] [config diff] config diff")
+	w.WriteHeader(200)
+	w.Write(buf)
+}
+
+// reloadConfig reloads the config file and returns the reload result
+func reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "reload", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := config.Reload()
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "reload", err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "reload failed: "+strings.Replace(err.Error(), `"`, `'`, -1))
+		fmt.Fprint(w, msg)
+		return
+	}
+	buf, _ := json.Marshal(result)
+	log.DefaultLogger.Infof("[admin api] [reload] config reloaded, applied: %d, skipped: %d, failed: %d",
+		len(result.Applied), len(result.Skipped), len(result.Failed))
+	w.WriteHeader(200)
+	w.Write(buf)
+}
+
 func statsDump(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "stats dump", r.Method)
@@ -69,9 +140,119 @@ func statsDump(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.DefaultLogger.Infof("[admin api]  [stats dump] stats dump")
+	query := r.URL.Query()
+	// the snapshots are read, so the concurrent updates would not make torn reads
+	stores := filterStats(metrics.SnapshotAll(), query)
 	w.WriteHeader(200)
 	sink := console.NewConsoleSink()
-	sink.Flush(w, metrics.GetAll())
+	if query.Get("format") == "flat" {
+		sink = console.NewConsoleFlatSink()
+	}
+	sink.Flush(w, stores)
+}
+
+// filterStats returns the metrics stores matched the query:
+// type: the store's type
+// label_key, label_value: the store has the label, any label's value is matched if label_key is empty
+// key_prefix: the metrics' key has the prefix
+func filterStats(stores []types.Metrics, query url.Values) []types.Metrics {
+	typ := query.Get("type")
+	labelKey := query.Get("label_key")
+	labelValue := query.Get("label_value")
+	keyPrefix := query.Get("key_prefix")
+
+	filtered := make([]types.Metrics, 0, len(stores))
+	for _, m := range stores {
+		if typ != "" && m.Type() != typ {
+			continue
+		}
+		if !matchLabel(m.Labels(), labelKey, labelValue) {
+			continue
+		}
+		if keyPrefix != "" {
+			m = &prefixMetrics{Metrics: m, prefix: keyPrefix}
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+func matchLabel(labels map[string]string, key, value string) bool {
+	if key != "" {
+		v, ok := labels[key]
+		return ok && (value == "" || v == value)
+	}
+	if value != "" {
+		for _, v := range labels {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// prefixMetrics only iterates the metrics whose key has the prefix
+type prefixMetrics struct {
+	types.Metrics
+	prefix string
+}
+
+func (m *prefixMetrics) Each(f func(string, interface{})) {
+	m.Metrics.Each(func(key string, i interface{}) {
+		if strings.HasPrefix(key, m.prefix) {
+			f(key, i)
+		}
+	})
+}
+
+// returns the stats matcher in use by GET, and sets the stats matcher by POST.
+// the stats matcher set is evaluated when the metrics are created
+func statsMatcher(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		buf, err := json.MarshalIndent(metrics.StatsMatcherConfig(), "", " ")
+		if err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "stats matcher", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			msg := fmt.Sprintf(errMsgFmt, "internal error")
+			fmt.Fprint(w, msg)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "stats matcher", err)
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, "read body error")
+			fmt.Fprint(w, msg)
+			return
+		}
+		config := v2.StatsMatcher{}
+		if err := json.Unmarshal(body, &config); err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid body, %v", "stats matcher", err)
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, "invalid body")
+			fmt.Fprint(w, msg)
+			return
+		}
+		if err := metrics.SetStatsMatcherConfig(config); err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "stats matcher", err)
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, err.Error())
+			fmt.Fprint(w, msg)
+			return
+		}
+		log.DefaultLogger.Infof("[admin api] [stats matcher] set stats matcher: %s", body)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "set stats matcher success\n")
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "stats matcher", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
 }
 
 // update log level
@@ -165,6 +346,136 @@ func disableLogger(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "disable logger success\n")
 }
 
+// LoggerStatus is the state of a logger, the level is empty if the logger is not an error logger
+type LoggerStatus struct {
+	Path     string `json:"path"`
+	Level    string `json:"level,omitempty"`
+	Disabled bool   `json:"disabled"`
+}
+
+// LoggerUpdateData updates the level or the state of a logger, the path "*" means all the loggers
+type LoggerUpdateData struct {
+	Path    string `json:"path"`
+	Level   string `json:"level,omitempty"`
+	Disable *bool  `json:"disable,omitempty"`
+}
+
+const allLoggers = "*"
+
+func levelName(level log.Level) string {
+	for name, lv := range levelMap {
+		if lv == level {
+			return name
+		}
+	}
+	return ""
+}
+
+// loggerStatuses returns the statuses of the loggers, filters the loggers by the path unless the path is "*"
+// if errorLoggerOnly is true, only the error loggers are returned
+func loggerStatuses(path string, errorLoggerOnly bool) []LoggerStatus {
+	statuses := []LoggerStatus{}
+	for _, state := range log.GetLoggerStates() {
+		if path != allLoggers && state.Path != path {
+			continue
+		}
+		if errorLoggerOnly && !state.IsErrorLogger {
+			continue
+		}
+		status := LoggerStatus{
+			Path:     state.Path,
+			Disabled: state.Disabled,
+		}
+		if state.IsErrorLogger {
+			status.Level = levelName(state.Level)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// loggerStates lists the loggers by GET, and updates the loggers by POST
+func loggerStates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		buf, _ := json.MarshalIndent(loggerStatuses(allLoggers, false), "", " ")
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+	case http.MethodPost:
+		updateLoggers(w, r)
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "loggers", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// post data:
+// {"path":"xxx","level":"DEBUG"} or {"path":"xxx","disable":true}
+// returns the loggers changed
+func updateLoggers(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "update loggers", err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "read body error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	data := &LoggerUpdateData{}
+	if err := json.Unmarshal(body, data); err != nil || data.Path == "" || (data.Level == "" && data.Disable == nil) {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, update loggers failed with bad request data: %s", "update loggers", string(body))
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "invalid request data")
+		fmt.Fprint(w, msg)
+		return
+	}
+	level, ok := levelMap[data.Level]
+	if data.Level != "" && !ok {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: unknown log level %s", "update loggers", data.Level)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "unknown log level")
+		fmt.Fprint(w, msg)
+		return
+	}
+	if data.Path != allLoggers {
+		statuses := loggerStatuses(data.Path, false)
+		if len(statuses) == 0 {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: logger %s not found", "update loggers", data.Path)
+			w.WriteHeader(http.StatusNotFound)
+			msg := fmt.Sprintf(errMsgFmt, "logger not found")
+			fmt.Fprint(w, msg)
+			return
+		}
+		if data.Level != "" && statuses[0].Level == "" {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: logger %s has no level", "update loggers", data.Path)
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, "logger has no level")
+			fmt.Fprint(w, msg)
+			return
+		}
+	}
+	if data.Level != "" {
+		if data.Path == allLoggers {
+			log.UpdateAllErrorLoggerLevel(level)
+		} else {
+			log.UpdateErrorLoggerLevel(data.Path, level)
+		}
+		log.DefaultLogger.Infof("[admin api] [update loggers] update log: %s level as %s", data.Path, data.Level)
+	}
+	if data.Disable != nil {
+		if data.Path == allLoggers {
+			log.ToggleAllLoggers(*data.Disable)
+		} else {
+			log.ToggleLogger(data.Path, *data.Disable)
+		}
+		log.DefaultLogger.Infof("[admin api] [update loggers] update log: %s disable as %v", data.Path, *data.Disable)
+	}
+	// only the error loggers are changed if all the loggers' level is updated
+	buf, _ := json.MarshalIndent(loggerStatuses(data.Path, data.Disable == nil), "", " ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
 // returns data
 // pid=xxx&state=xxx
 func getState(w http.ResponseWriter, r *http.Request) {
@@ -178,3 +489,246 @@ func getState(w http.ResponseWriter, r *http.Request) {
 	msg := fmt.Sprintf("pid=%d&state=%d\n", pid, state)
 	fmt.Fprint(w, msg)
 }
+
+// returns the state of the overload manager, the usage of the resources and the actions engaged
+func overloadStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "overload status", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	buf, err := json.MarshalIndent(overload.GetStatus(), "", " ")
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "overload status", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		msg := fmt.Sprintf(errMsgFmt, "internal error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
+// returns the connection pools' state of a cluster
+// cluster=xxx
+func upstreamConnectionsDump(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "upstream connections dump", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	clusterName := r.URL.Query().Get("cluster")
+	cm := cluster.GetClusterMngAdapterInstance()
+	if clusterName == "" || !cm.ClusterExist(clusterName) {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: cluster %s not found", "upstream connections dump", clusterName)
+		w.WriteHeader(http.StatusNotFound)
+		msg := fmt.Sprintf(errMsgFmt, "cluster not found")
+		fmt.Fprint(w, msg)
+		return
+	}
+	buf, err := json.MarshalIndent(cm.ConnPoolSnapshots(clusterName), "", " ")
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "upstream connections dump", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		msg := fmt.Sprintf(errMsgFmt, "internal error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [upstream connections dump] dump upstream connections of cluster %s", clusterName)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
+// clustersDump dumps the state of the clusters and their hosts, the cluster query filters the cluster by the name
+func clustersDump(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "clusters dump", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	clusterName := r.URL.Query().Get("cluster")
+	cm := cluster.GetClusterMngAdapterInstance()
+	if clusterName != "" && !cm.ClusterExist(clusterName) {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: cluster %s not found", "clusters dump", clusterName)
+		w.WriteHeader(http.StatusNotFound)
+		msg := fmt.Sprintf(errMsgFmt, "cluster not found")
+		fmt.Fprint(w, msg)
+		return
+	}
+	buf, err := json.MarshalIndent(cm.ClusterStatuses(clusterName), "", " ")
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "clusters dump", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		msg := fmt.Sprintf(errMsgFmt, "internal error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
+// close upstream connection
+type CloseUpstreamConnectionData struct {
+	Cluster      string `json:"cluster"`
+	ConnectionID string `json:"connection_id"`
+	Graceful     bool   `json:"graceful"`
+}
+
+type CloseUpstreamConnectionResult struct {
+	Found  bool `json:"found"`
+	Active bool `json:"active"`
+}
+
+func closeUpstreamConnection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "close upstream connection", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "close upstream connection", err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "read body error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	data := &CloseUpstreamConnectionData{}
+	if err := json.Unmarshal(body, data); err != nil || data.Cluster == "" || data.ConnectionID == "" {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, close upstream connection failed with bad request data: %s", "close upstream connection", string(body))
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "invalid request data")
+		fmt.Fprint(w, msg)
+		return
+	}
+	result := CloseUpstreamConnectionResult{}
+	result.Found, result.Active = cluster.GetClusterMngAdapterInstance().CloseUpstreamConnection(data.Cluster, data.ConnectionID, data.Graceful)
+	if !result.Found {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: connection %s of cluster %s not found", "close upstream connection", data.ConnectionID, data.Cluster)
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		log.DefaultLogger.Infof("[admin api] [close upstream connection] close connection %s of cluster %s, graceful: %v", data.ConnectionID, data.Cluster, data.Graceful)
+		w.WriteHeader(http.StatusOK)
+	}
+	buf, _ := json.Marshal(result)
+	w.Write(buf)
+}
+
+// healthz is the health check of the load balancers, it returns 200 if the mosn accepts traffic, 503 otherwise
+func healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "healthz", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	healthy, reason := store.Healthy()
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "unhealthy: %s\n", reason)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "ok\n")
+}
+
+// healthCheckFail forces the healthz to fail for the maintenance, until the healthCheckOK is called
+func healthCheckFail(w http.ResponseWriter, r *http.Request) {
+	setHealthCheckFailed(w, r, true)
+}
+
+// healthCheckOK clears the failure forced by the healthCheckFail
+func healthCheckOK(w http.ResponseWriter, r *http.Request) {
+	setHealthCheckFailed(w, r, false)
+}
+
+func setHealthCheckFailed(w http.ResponseWriter, r *http.Request, failed bool) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "health check", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	store.SetHealthCheckFailed(failed)
+	log.DefaultLogger.Infof("[admin api] [health check] health check forced failed: %v", failed)
+	healthy, _ := store.Healthy()
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"healthy":%v,"forced_failed":%v}`, healthy, failed)
+}
+
+// RuntimeValue is a runtime key and its value, the values set by the admin api override the config
+type RuntimeValue struct {
+	Key        string      `json:"key"`
+	Value      interface{} `json:"value"`
+	Overridden bool        `json:"overridden,omitempty"`
+}
+
+// runtimeValues returns the runtime values sorted by the keys, and overrides the values by post data:
+// {"key1":value1,"key2":null}, a null value removes the override of the key
+func runtimeValues(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "runtime", err)
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, "read body error")
+			fmt.Fprint(w, msg)
+			return
+		}
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(body, &values); err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, set runtime failed with bad request data: %s", "runtime", string(body))
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, "invalid request data")
+			fmt.Fprint(w, msg)
+			return
+		}
+		if err := config.SetRuntimeOverrides(values); err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "runtime", err)
+			w.WriteHeader(http.StatusBadRequest)
+			msg := fmt.Sprintf(errMsgFmt, strings.Replace(err.Error(), `"`, `'`, -1))
+			fmt.Fprint(w, msg)
+			return
+		}
+		log.DefaultLogger.Infof("[admin api] [runtime] runtime overridden: %s", string(body))
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "runtime", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	values := config.GetRuntime().Values()
+	overrides := config.GetRuntimeOverrides()
+	result := make([]RuntimeValue, 0, len(values))
+	for key, value := range values {
+		_, overridden := overrides[key]
+		result = append(result, RuntimeValue{
+			Key:        key,
+			Value:      value,
+			Overridden: overridden,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	buf, _ := json.MarshalIndent(result, "", " ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
+// shutdown is replaced by the tests, so the process is not exited
+var shutdown = keeper.Shutdown
+
+// shutdownProcess shuts down the process after the response is sent,
+// the connections are drained before it if graceful=true
+func shutdownProcess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "shutdown", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	graceful := r.URL.Query().Get("graceful") == "true"
+	log.DefaultLogger.Infof("[admin api] [shutdown] shutdown by the admin api, graceful: %v", graceful)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"graceful":%v}`, graceful)
+	go shutdown("admin", graceful)
+}
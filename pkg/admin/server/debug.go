@@ -0,0 +1,221 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	rpprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	// maxCPUProfileSeconds bounds the duration of a cpu profile or an execution trace captured by the admin api
+	maxCPUProfileSeconds = 30
+	// defaultTraceSeconds is the duration of an execution trace if the seconds is not specified
+	defaultTraceSeconds = 1
+	defaultMaxDumps     = 10
+	dumpTimeFormat      = "20060102-150405.000"
+)
+
+// debugHandleFuncStore stores the debug apis, they are served only if the admin debug is enabled
+var debugHandleFuncStore = map[string]func(http.ResponseWriter, *http.Request){
+	"/debug/pprof/":                pprof.Index,
+	"/debug/pprof/cmdline":         pprof.Cmdline,
+	"/debug/pprof/profile":         cpuProfile,
+	"/debug/pprof/symbol":          pprof.Symbol,
+	"/debug/pprof/trace":           traceProfile,
+	"/api/v1/debug/heap_dump":      heapDump,
+	"/api/v1/debug/goroutine_dump": goroutineDump,
+}
+
+var (
+	cpuProfiling int32
+	tracing      int32
+
+	dumpMux      sync.Mutex
+	dumpDir      string
+	dumpMaxFiles = defaultMaxDumps
+)
+
+// registerDebugHandlers mounts the debug apis on the mux if the config enables them
+func registerDebugHandlers(mux *http.ServeMux, debugConfig DebugConfig) {
+	if debugConfig == nil {
+		return
+	}
+	cfg := debugConfig.GetDebug()
+	if cfg == nil || !cfg.AdminDebug {
+		return
+	}
+	dumpMux.Lock()
+	dumpDir = cfg.DumpDir
+	dumpMaxFiles = cfg.MaxDumps
+	if dumpMaxFiles <= 0 {
+		dumpMaxFiles = defaultMaxDumps
+	}
+	dumpMux.Unlock()
+	for pattern, handler := range debugHandleFuncStore {
+		mux.HandleFunc(pattern, handler)
+	}
+	log.StartLogger.Infof("[admin server] [debug] debug apis are enabled")
+}
+
+// cpuProfile bounds the profile duration to maxCPUProfileSeconds and refuses the concurrent captures
+func cpuProfile(w http.ResponseWriter, r *http.Request) {
+	captureProfile(w, r, "cpu profile", &cpuProfiling, maxCPUProfileSeconds, pprof.Profile)
+}
+
+// traceProfile bounds the trace duration to maxCPUProfileSeconds and refuses the concurrent captures
+func traceProfile(w http.ResponseWriter, r *http.Request) {
+	captureProfile(w, r, "trace", &tracing, defaultTraceSeconds, pprof.Trace)
+}
+
+// captureProfile serves the pprof handler that captures for the seconds in the query,
+// only one capture of the same kind is allowed at the same time
+func captureProfile(w http.ResponseWriter, r *http.Request, api string, capturing *int32, defaultSeconds int, handler http.HandlerFunc) {
+	if !atomic.CompareAndSwapInt32(capturing, 0, 1) {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: another %s is in progress", api, api)
+		w.WriteHeader(http.StatusConflict)
+		msg := fmt.Sprintf(errMsgFmt, "another "+api+" is in progress")
+		fmt.Fprint(w, msg)
+		return
+	}
+	defer atomic.StoreInt32(capturing, 0)
+
+	query := r.URL.Query()
+	sec, err := strconv.Atoi(query.Get("seconds"))
+	if err != nil || sec <= 0 {
+		sec = defaultSeconds
+	}
+	if sec > maxCPUProfileSeconds {
+		sec = maxCPUProfileSeconds
+	}
+	query.Set("seconds", strconv.Itoa(sec))
+	r.URL.RawQuery = query.Encode()
+	// the form is parsed by the pprof handler from the raw query
+	r.Form = nil
+	handler(w, r)
+}
+
+// heapDump writes a heap profile to the dump directory
+func heapDump(w http.ResponseWriter, r *http.Request) {
+	writeDump(w, r, "heap", 0)
+}
+
+// goroutineDump writes the stacks of all goroutines to the dump directory
+func goroutineDump(w http.ResponseWriter, r *http.Request) {
+	writeDump(w, r, "goroutine", 2)
+}
+
+func writeDump(w http.ResponseWriter, r *http.Request, name string, debug int) {
+	api := name + " dump"
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	file, err := dumpProfile(name, debug)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", api, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		msg := fmt.Sprintf(errMsgFmt, strings.Replace(err.Error(), `"`, `'`, -1))
+		fmt.Fprint(w, msg)
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [debug] %s written to %s", api, file)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"file":%q}`, file)
+}
+
+// dumpProfile writes the named profile to a timestamped file, and removes the oldest dumps beyond the max
+func dumpProfile(name string, debug int) (string, error) {
+	dumpMux.Lock()
+	defer dumpMux.Unlock()
+
+	profile := rpprof.Lookup(name)
+	if profile == nil {
+		return "", fmt.Errorf("unknown profile %s", name)
+	}
+	dir := dumpDir
+	if dir == "" {
+		dir = filepath.Join(types.MosnLogBasePath, "dumps")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.prof", name, time.Now().Format(dumpTimeFormat)))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	err = profile.WriteTo(f, debug)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	pruneDumps(dir, dumpMaxFiles)
+	return path, nil
+}
+
+// pruneDumps keeps the newest max dumps in the dir
+func pruneDumps(dir string, max int) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var dumps []os.FileInfo
+	for _, info := range infos {
+		if !info.IsDir() && isDumpFile(info.Name()) {
+			dumps = append(dumps, info)
+		}
+	}
+	if len(dumps) <= max {
+		return
+	}
+	sort.Slice(dumps, func(i, j int) bool {
+		if dumps[i].ModTime().Equal(dumps[j].ModTime()) {
+			return dumps[i].Name() < dumps[j].Name()
+		}
+		return dumps[i].ModTime().Before(dumps[j].ModTime())
+	})
+	for _, info := range dumps[:len(dumps)-max] {
+		if err := os.Remove(filepath.Join(dir, info.Name())); err != nil {
+			log.DefaultLogger.Errorf("[admin api] [debug] remove dump %s failed: %v", info.Name(), err)
+		}
+	}
+}
+
+func isDumpFile(name string) bool {
+	return strings.HasSuffix(name, ".prof") &&
+		(strings.HasPrefix(name, "heap-") || strings.HasPrefix(name, "goroutine-"))
+}
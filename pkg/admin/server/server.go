@@ -41,12 +41,25 @@ func RegisterAdminHandleFunc(pattern string, handler func(http.ResponseWriter, *
 func init() {
 	// default admin api
 	apiHandleFuncStore = map[string]func(http.ResponseWriter, *http.Request){
-		"/api/v1/config_dump":     configDump,
-		"/api/v1/stats":           statsDump,
-		"/api/v1/update_loglevel": updateLogLevel,
-		"/api/v1/enable_log":      enableLogger,
-		"/api/v1/disbale_log":     disableLogger,
-		"/api/v1/states":          getState,
+		"/api/v1/config_dump":               configDump,
+		"/api/v1/config_diff":               configDiff,
+		"/api/v1/reload":                    reloadConfig,
+		"/api/v1/stats":                     statsDump,
+		"/api/v1/stats_matcher":             statsMatcher,
+		"/api/v1/update_loglevel":           updateLogLevel,
+		"/api/v1/enable_log":                enableLogger,
+		"/api/v1/disbale_log":               disableLogger,
+		"/api/v1/states":                    getState,
+		"/api/v1/upstream_connections":      upstreamConnectionsDump,
+		"/api/v1/close_upstream_connection": closeUpstreamConnection,
+		"/api/v1/clusters":                  clustersDump,
+		"/api/v1/loggers":                   loggerStates,
+		"/api/v1/overload":                  overloadStatus,
+		"/api/v1/shutdown":                  shutdownProcess,
+		"/api/v1/runtime":                   runtimeValues,
+		"/api/v1/healthcheck/fail":          healthCheckFail,
+		"/api/v1/healthcheck/ok":            healthCheckOK,
+		"/healthz":                          healthz,
 	}
 }
 
@@ -56,6 +69,7 @@ type Server struct {
 
 func (s *Server) Start(config Config) {
 	var addr string
+	var debugConfig DebugConfig
 	if config != nil {
 		// merge MOSNConfig into global context
 		store.SetMOSNConfig(config)
@@ -70,12 +84,14 @@ func (s *Server) Start(config Config) {
 		if xdsPort, ok := address.GetSocketAddress().GetPortSpecifier().(*core.SocketAddress_PortValue); ok {
 			addr = fmt.Sprintf("%s:%d", address.GetSocketAddress().GetAddress(), xdsPort.PortValue)
 		}
+		debugConfig, _ = config.(DebugConfig)
 	}
 
 	mux := http.NewServeMux()
 	for pattern, handler := range apiHandleFuncStore {
 		mux.HandleFunc(pattern, handler)
 	}
+	registerDebugHandlers(mux, debugConfig)
 
 	srv := &http.Server{Addr: addr, Handler: mux}
 	store.AddService(srv, "Mosn Admin Server", nil, nil)
@@ -23,9 +23,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -34,8 +37,13 @@ import (
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	v2 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v2"
 	"sofastack.io/sofa-mosn/pkg/admin/store"
+	apiv2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/server/keeper"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
 )
 
 func getEffectiveConfig(port uint32) (string, error) {
@@ -211,6 +219,12 @@ func TestDumpStats(t *testing.T) {
 				"gg2": "3",
 			},
 		},
+		// the gauge of the dropped logs is registered at init
+		"meta": {
+			"mosn.stats": {
+				"log_dropped": "0",
+			},
+		},
 	}, "", "\t")
 
 	if data, err := getStats(config.Port); err != nil {
@@ -378,6 +392,143 @@ func TestRegisterNewAPI(t *testing.T) {
 
 }
 
+func TestCloseUpstreamConnection(t *testing.T) {
+	cm := cluster.NewClusterManagerSingleton(nil, nil)
+	defer cm.Destroy()
+
+	// bad request
+	w := httptest.NewRecorder()
+	closeUpstreamConnection(w, httptest.NewRequest(http.MethodPost, "/api/v1/close_upstream_connection", strings.NewReader(`{"cluster":"test"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, but got %d", w.Code)
+	}
+	// the connection is not found
+	w = httptest.NewRecorder()
+	closeUpstreamConnection(w, httptest.NewRequest(http.MethodPost, "/api/v1/close_upstream_connection", strings.NewReader(`{"cluster":"test","connection_id":"1@127.0.0.1:8080","graceful":true}`)))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected not found, but got %d", w.Code)
+	}
+	result := &CloseUpstreamConnectionResult{}
+	if err := json.Unmarshal(w.Body.Bytes(), result); err != nil || result.Found || result.Active {
+		t.Fatalf("unexpected result: %s", w.Body.String())
+	}
+	// the cluster is not found
+	w = httptest.NewRecorder()
+	upstreamConnectionsDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/upstream_connections?cluster=test", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected not found, but got %d", w.Code)
+	}
+}
+
+func TestClustersDump(t *testing.T) {
+	cm := cluster.NewClusterManagerSingleton(nil, nil)
+	defer cm.Destroy()
+	if err := cm.AddOrUpdatePrimaryCluster(apiv2.Cluster{Name: "dump"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.UpdateClusterHosts("dump", []apiv2.Host{{HostConfig: apiv2.HostConfig{Address: "127.0.0.1:8080"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	clustersDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/clusters?cluster=dump", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected ok, but got %d", w.Code)
+	}
+	var statuses []types.ClusterStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "dump" || len(statuses[0].Hosts) != 1 ||
+		statuses[0].Hosts[0].Address != "127.0.0.1:8080" || !statuses[0].Hosts[0].Healthy {
+		t.Fatalf("unexpected clusters dump: %s", w.Body.String())
+	}
+	// the cluster is not found
+	w = httptest.NewRecorder()
+	clustersDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/clusters?cluster=unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected not found, but got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	clustersDump(w, httptest.NewRequest(http.MethodPost, "/api/v1/clusters", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected method not allowed, but got %d", w.Code)
+	}
+}
+
+func TestStatsDumpFilter(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+	m1, _ := metrics.NewMetrics("FilterTest", map[string]string{"cluster": "c1"})
+	m1.Counter("request_total").Inc(1)
+	m1.Gauge("connection_active").Update(2)
+	m1.Histogram("request_time").Update(3)
+	m2, _ := metrics.NewMetrics("FilterTest", map[string]string{"cluster": "c2"})
+	m2.Counter("request_total").Inc(4)
+	m3, _ := metrics.NewMetrics("OtherTest", map[string]string{"listener": "c1"})
+	m3.Counter("request_total").Inc(5)
+
+	for _, tc := range []struct {
+		query    string
+		expected string
+	}{
+		{"type=FilterTest&label_key=cluster&label_value=c2", "FilterTest.cluster.c2.request_total 4\n"},
+		{"label_value=c1&key_prefix=request_total", "FilterTest.cluster.c1.request_total 1\nOtherTest.listener.c1.request_total 5\n"},
+		{"type=OtherTest&label_key=cluster", ""},
+		{"label_key=cluster&label_value=c1&key_prefix=request_time",
+			"FilterTest.cluster.c1.request_time_count 1\n" +
+				"FilterTest.cluster.c1.request_time_max 3\n" +
+				"FilterTest.cluster.c1.request_time_mean 3.00\n" +
+				"FilterTest.cluster.c1.request_time_min 3\n" +
+				"FilterTest.cluster.c1.request_time_p95 3.00\n" +
+				"FilterTest.cluster.c1.request_time_p99 3.00\n"},
+	} {
+		w := httptest.NewRecorder()
+		statsDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats?format=flat&"+tc.query, nil))
+		if w.Code != http.StatusOK || w.Body.String() != tc.expected {
+			t.Errorf("query %s expected %q, but got %d %q", tc.query, tc.expected, w.Code, w.Body.String())
+		}
+	}
+
+	// the json format is grouped by type and labels
+	w := httptest.NewRecorder()
+	statsDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats?type=FilterTest&key_prefix=request_total", nil))
+	expected, _ := rawjson.MarshalIndent(map[string]map[string]map[string]string{
+		"FilterTest": {
+			"cluster.c1": {"request_total": "1"},
+			"cluster.c2": {"request_total": "4"},
+		},
+	}, "", "\t")
+	if w.Body.String() != string(expected) {
+		t.Errorf("unexpected stats: %s", w.Body.String())
+	}
+}
+
+func TestStatsMatcher(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	// invalid pattern
+	w := httptest.NewRecorder()
+	statsMatcher(w, httptest.NewRequest(http.MethodPost, "/api/v1/stats_matcher", strings.NewReader(`{"exclusion_rules":[{"keys":["["]}]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, but got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	statsMatcher(w, httptest.NewRequest(http.MethodPost, "/api/v1/stats_matcher", strings.NewReader(`{"exclusion_rules":[{"type":"upstream","labels":{"cluster":"dyn-*","host":"*"}}]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set stats matcher failed: %d %s", w.Code, w.Body.String())
+	}
+	m, _ := metrics.NewMetrics("upstream", map[string]string{"cluster": "dyn-1", "host": "127.0.0.1:80"})
+	if _, ok := m.(*metrics.NilMetrics); !ok {
+		t.Fatal("host stats of the dynamic cluster should be excluded")
+	}
+	if config := metrics.StatsMatcherConfig(); len(config.ExclusionRules) != 1 || config.ExclusionRules[0].Labels["cluster"] != "dyn-*" {
+		t.Fatalf("unexpected stats matcher: %v", config)
+	}
+}
+
 func readLines(path string) ([]string, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -392,3 +543,364 @@ func readLines(path string) ([]string, error) {
 	}
 	return lines, scanner.Err()
 }
+
+func TestConfigDumpResource(t *testing.T) {
+	w := httptest.NewRecorder()
+	configDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/config_dump?resource=unknown", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, but got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	configDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/config_dump?resource=clusters", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "null" {
+		t.Fatalf("unexpected clusters dump: %d, %s", w.Code, w.Body.String())
+	}
+	// the stored config is redacted too
+	store.SetMOSNConfig(map[string]interface{}{
+		"private_key": "secret key",
+	})
+	defer store.Reset()
+	w = httptest.NewRecorder()
+	configDump(w, httptest.NewRequest(http.MethodGet, "/api/v1/config_dump", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "secret key") {
+		t.Fatalf("unexpected config dump: %d, %s", w.Code, w.Body.String())
+	}
+	// no config file is loaded
+	w = httptest.NewRecorder()
+	configDiff(w, httptest.NewRequest(http.MethodGet, "/api/v1/config_diff", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected internal error, but got %d", w.Code)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	w := httptest.NewRecorder()
+	reloadConfig(w, httptest.NewRequest(http.MethodGet, "/api/v1/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected method not allowed, but got %d", w.Code)
+	}
+	// no config file is loaded
+	w = httptest.NewRecorder()
+	reloadConfig(w, httptest.NewRequest(http.MethodPost, "/api/v1/reload", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, but got %d", w.Code)
+	}
+}
+
+func TestLoggerStates(t *testing.T) {
+	errorLogName := "/tmp/mosn_admin/test_loggers_error.log"
+	rawLogName := "/tmp/mosn_admin/test_loggers_raw.log"
+	errorLogger, err := log.GetOrCreateDefaultErrorLogger(errorLogName, log.INFO)
+	if err != nil {
+		t.Fatal("create logger failed")
+	}
+	rawLogger, err := log.GetOrCreateLogger(rawLogName, nil)
+	if err != nil {
+		t.Fatal("create logger failed")
+	}
+	// the loggers are reused if the test runs more than once
+	errorLogger.SetLogLevel(log.INFO)
+	findStatus := func(statuses []LoggerStatus, path string) (LoggerStatus, bool) {
+		for _, status := range statuses {
+			if status.Path == path {
+				return status, true
+			}
+		}
+		return LoggerStatus{}, false
+	}
+	// list loggers
+	w := httptest.NewRecorder()
+	loggerStates(w, httptest.NewRequest(http.MethodGet, "/api/v1/loggers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list loggers expected 200, but got %d", w.Code)
+	}
+	statuses := []LoggerStatus{}
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if status, ok := findStatus(statuses, errorLogName); !ok || status.Level != "INFO" || status.Disabled {
+		t.Errorf("error logger status is not expected: %v, %v", status, ok)
+	}
+	if status, ok := findStatus(statuses, rawLogName); !ok || status.Level != "" || status.Disabled {
+		t.Errorf("raw logger status is not expected: %v, %v", status, ok)
+	}
+	// update level
+	w = httptest.NewRecorder()
+	loggerStates(w, httptest.NewRequest(http.MethodPost, "/api/v1/loggers", strings.NewReader(`{"path":"`+errorLogName+`","level":"DEBUG"}`)))
+	statuses = []LoggerStatus{}
+	json.Unmarshal(w.Body.Bytes(), &statuses)
+	if w.Code != http.StatusOK || len(statuses) != 1 || statuses[0].Level != "DEBUG" {
+		t.Errorf("update logger level failed: %d, %s", w.Code, w.Body.String())
+	}
+	if errorLogger.GetLogLevel() != log.DEBUG {
+		t.Errorf("logger level is not expected: %v", errorLogger.GetLogLevel())
+	}
+	// disable logger
+	w = httptest.NewRecorder()
+	loggerStates(w, httptest.NewRequest(http.MethodPost, "/api/v1/loggers", strings.NewReader(`{"path":"`+rawLogName+`","disable":true}`)))
+	if w.Code != http.StatusOK || !rawLogger.Disabled() {
+		t.Errorf("disable logger failed: %d, %s", w.Code, w.Body.String())
+	}
+	// enable all loggers
+	w = httptest.NewRecorder()
+	loggerStates(w, httptest.NewRequest(http.MethodPost, "/api/v1/loggers", strings.NewReader(`{"path":"*","disable":false}`)))
+	statuses = []LoggerStatus{}
+	json.Unmarshal(w.Body.Bytes(), &statuses)
+	if _, ok := findStatus(statuses, errorLogName); w.Code != http.StatusOK || !ok || rawLogger.Disabled() {
+		t.Errorf("enable all loggers failed: %d, %s", w.Code, w.Body.String())
+	}
+	// bad requests
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"path":"` + rawLogName + `","level":"DEBUG"}`, http.StatusBadRequest},
+		{`{"path":"` + errorLogName + `","level":"UNKNOWN"}`, http.StatusBadRequest},
+		{`{"path":"` + errorLogName + `"}`, http.StatusBadRequest},
+		{`{"path":"/tmp/mosn_admin/not_exists.log","disable":true}`, http.StatusNotFound},
+	} {
+		w = httptest.NewRecorder()
+		loggerStates(w, httptest.NewRequest(http.MethodPost, "/api/v1/loggers", strings.NewReader(tc.body)))
+		if w.Code != tc.code {
+			t.Errorf("request %s expected %d, but got %d", tc.body, tc.code, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	loggerStates(w, httptest.NewRequest(http.MethodDelete, "/api/v1/loggers", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, but got %d", w.Code)
+	}
+}
+
+func TestShutdownProcess(t *testing.T) {
+	called := make(chan bool, 1)
+	shutdown = func(reason string, graceful bool) {
+		called <- graceful
+	}
+	defer func() {
+		shutdown = keeper.Shutdown
+	}()
+	w := httptest.NewRecorder()
+	shutdownProcess(w, httptest.NewRequest(http.MethodGet, "/api/v1/shutdown", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, but got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	shutdownProcess(w, httptest.NewRequest(http.MethodPost, "/api/v1/shutdown?graceful=true", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"graceful":true}` {
+		t.Fatalf("unexpected response: %d, %s", w.Code, w.Body.String())
+	}
+	select {
+	case graceful := <-called:
+		if !graceful {
+			t.Fatal("expected a graceful shutdown")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown is not called")
+	}
+}
+
+func TestRuntimeValues(t *testing.T) {
+	if err := config.SetRuntimeBase(map[string]interface{}{"feature.a": true}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetRuntimeBase(nil)
+
+	w := httptest.NewRecorder()
+	runtimeValues(w, httptest.NewRequest(http.MethodPost, "/api/v1/runtime", strings.NewReader(`{"feature.a":false,"feature.b":"1s"}`)))
+	values := []RuntimeValue{}
+	if err := json.Unmarshal(w.Body.Bytes(), &values); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("set runtime failed: %d, %s", w.Code, w.Body.String())
+	}
+	if len(values) != 2 || values[0] != (RuntimeValue{"feature.a", false, true}) || values[1] != (RuntimeValue{"feature.b", "1s", true}) {
+		t.Fatalf("unexpected runtime: %s", w.Body.String())
+	}
+	if config.GetRuntime().GetDuration("feature.b", 0) != time.Second {
+		t.Fatal("expected the runtime changed")
+	}
+	// remove the overrides
+	w = httptest.NewRecorder()
+	runtimeValues(w, httptest.NewRequest(http.MethodPost, "/api/v1/runtime", strings.NewReader(`{"feature.a":null,"feature.b":null}`)))
+	w = httptest.NewRecorder()
+	runtimeValues(w, httptest.NewRequest(http.MethodGet, "/api/v1/runtime", nil))
+	values = []RuntimeValue{}
+	if err := json.Unmarshal(w.Body.Bytes(), &values); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("get runtime failed: %d, %s", w.Code, w.Body.String())
+	}
+	if len(values) != 1 || values[0] != (RuntimeValue{"feature.a", true, false}) {
+		t.Fatalf("unexpected runtime: %s", w.Body.String())
+	}
+	// bad requests
+	for _, body := range []string{`[1]`, `{"a..b":1}`, `{"a":{"b":1}}`} {
+		w = httptest.NewRecorder()
+		runtimeValues(w, httptest.NewRequest(http.MethodPost, "/api/v1/runtime", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("request %s expected 400, but got %d", body, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	runtimeValues(w, httptest.NewRequest(http.MethodDelete, "/api/v1/runtime", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, but got %d", w.Code)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	oldState := store.GetMosnState()
+	store.SetMosnState(store.Running)
+	defer store.SetMosnState(oldState)
+
+	expectHealthz := func(code int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if w.Code != code {
+			t.Fatalf("expected healthz %d, but got %d, %s", code, w.Code, w.Body.String())
+		}
+	}
+	expectHealthz(http.StatusOK)
+
+	// the forced failure is kept until it is cleared
+	w := httptest.NewRecorder()
+	healthCheckFail(w, httptest.NewRequest(http.MethodPost, "/api/v1/healthcheck/fail", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"healthy":false,"forced_failed":true}` {
+		t.Fatalf("unexpected response: %d, %s", w.Code, w.Body.String())
+	}
+	expectHealthz(http.StatusServiceUnavailable)
+	store.SetMosnState(store.Running)
+	expectHealthz(http.StatusServiceUnavailable)
+	if v := metrics.NewServerHealthStats().Gauge(metrics.ServerHealthCheckFailed).Value(); v != 1 {
+		t.Fatalf("expected the forced failed gauge 1, but got %d", v)
+	}
+	w = httptest.NewRecorder()
+	healthCheckOK(w, httptest.NewRequest(http.MethodPost, "/api/v1/healthcheck/ok", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"healthy":true,"forced_failed":false}` {
+		t.Fatalf("unexpected response: %d, %s", w.Code, w.Body.String())
+	}
+	expectHealthz(http.StatusOK)
+	if v := metrics.NewServerHealthStats().Gauge(metrics.ServerHealthCheckFailed).Value(); v != 0 {
+		t.Fatalf("expected the forced failed gauge 0, but got %d", v)
+	}
+
+	// draining and not running
+	store.SetDraining(true)
+	expectHealthz(http.StatusServiceUnavailable)
+	store.SetDraining(false)
+	store.SetMosnState(store.Passive_Reconfiguring)
+	expectHealthz(http.StatusServiceUnavailable)
+
+	w = httptest.NewRecorder()
+	healthCheckFail(w, httptest.NewRequest(http.MethodGet, "/api/v1/healthcheck/fail", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, but got %d", w.Code)
+	}
+}
+
+type mockDebugConfig struct {
+	mockMOSNConfig
+	debug config.PProfConfig
+}
+
+func (m *mockDebugConfig) GetDebug() *config.PProfConfig {
+	return &m.debug
+}
+
+func TestDebugHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosn_dumps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the debug apis are not served by default
+	mux := http.NewServeMux()
+	registerDebugHandlers(mux, &mockDebugConfig{})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/debug/heap_dump", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 if the admin debug is disabled, but got %d", w.Code)
+	}
+
+	mux = http.NewServeMux()
+	registerDebugHandlers(mux, &mockDebugConfig{
+		debug: config.PProfConfig{
+			AdminDebug: true,
+			DumpDir:    dir,
+			MaxDumps:   2,
+		},
+	})
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected pprof index served, but got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/debug/heap_dump", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, but got %d", w.Code)
+	}
+
+	var files []string
+	for _, api := range []string{"heap_dump", "goroutine_dump", "heap_dump"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/debug/"+api, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s failed: %d, %s", api, w.Code, w.Body.String())
+		}
+		resp := struct {
+			File string `json:"file"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(resp.File) != dir {
+			t.Fatalf("dump %s is not written to %s", resp.File, dir)
+		}
+		files = append(files, resp.File)
+		time.Sleep(10 * time.Millisecond)
+	}
+	// the oldest dump is removed
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Fatalf("expected %s removed, but got %v", files[0], err)
+	}
+	for _, f := range files[1:] {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatalf("expected %s retained, but got %v", f, err)
+		}
+	}
+
+	// the concurrent cpu profiles are refused
+	atomic.StoreInt32(&cpuProfiling, 1)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=1", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, but got %d", w.Code)
+	}
+	atomic.StoreInt32(&cpuProfiling, 0)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected cpu profile captured, but got %d, %s", w.Code, w.Body.String())
+	}
+
+	// the concurrent traces are refused
+	atomic.StoreInt32(&tracing, 1)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/trace?seconds=1", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, but got %d", w.Code)
+	}
+	atomic.StoreInt32(&tracing, 0)
+	// the trace duration is bounded
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/trace?seconds=3600", nil)
+	captureProfile(httptest.NewRecorder(), r, "trace", &tracing, defaultTraceSeconds, func(w http.ResponseWriter, r *http.Request) {
+		if sec := r.URL.Query().Get("seconds"); sec != strconv.Itoa(maxCPUProfileSeconds) {
+			t.Errorf("expected the trace bounded to %d seconds, but got %s", maxCPUProfileSeconds, sec)
+		}
+	})
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/trace", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected trace captured, but got %d, %s", w.Code, w.Body.String())
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	. "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
 )
 
 /*
@@ -19,3 +20,8 @@ import (
 type Config interface {
 	GetAdmin() *Admin
 }
+
+// DebugConfig is implemented by the Config that gates the debug apis of the admin server
+type DebugConfig interface {
+	GetDebug() *config.PProfConfig
+}
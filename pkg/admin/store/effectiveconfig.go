@@ -99,6 +99,24 @@ func SetHosts(clusterName string, hostConfigs []v2.Host) {
 	}
 }
 
+func SetClusterHealthCheck(clusterName string, hc v2.HealthCheck) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if cluster, ok := conf.Cluster[clusterName]; ok {
+		cluster.HealthCheck = hc
+		conf.Cluster[clusterName] = cluster
+	}
+}
+
+func SetClusterCircuitBreakers(clusterName string, cb v2.CircuitBreakers) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if cluster, ok := conf.Cluster[clusterName]; ok {
+		cluster.CirBreThresholds = cb
+		conf.Cluster[clusterName] = cluster
+	}
+}
+
 func SetRouter(routerName string, router v2.RouterConfiguration) {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -0,0 +1,90 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"sync/atomic"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+)
+
+// the health check state, accessed atomically
+var (
+	healthCheckFailed uint32
+	draining          uint32
+)
+
+func init() {
+	RegisterOnStateChanged(func(s State) {
+		updateHealthStats()
+	})
+}
+
+// SetHealthCheckFailed forces the health check to fail for the maintenance, it is kept until it is cleared
+func SetHealthCheckFailed(failed bool) {
+	atomic.StoreUint32(&healthCheckFailed, boolToUint32(failed))
+	log.DefaultLogger.Infof("[admin store] [health check] health check forced failed: %v", failed)
+	updateHealthStats()
+}
+
+// HealthCheckFailed returns whether the health check is forced to fail
+func HealthCheckFailed() bool {
+	return atomic.LoadUint32(&healthCheckFailed) == 1
+}
+
+// SetDraining makes the health check fail when the mosn is going to shut down,
+// so the external load balancers stop sending traffic before the connections are drained
+func SetDraining(d bool) {
+	atomic.StoreUint32(&draining, boolToUint32(d))
+	updateHealthStats()
+}
+
+// Healthy returns whether the mosn accepts traffic and the reason if it does not.
+// The mosn is healthy after the config is loaded, and it is not shutting down or forced to fail
+func Healthy() (bool, string) {
+	switch {
+	case HealthCheckFailed():
+		return false, "health check failed by admin"
+	case atomic.LoadUint32(&draining) == 1:
+		return false, "draining"
+	}
+	switch GetMosnState() {
+	case Running, Active_Reconfiguring:
+		return true, ""
+	case Passive_Reconfiguring:
+		return false, "reconfiguring"
+	default:
+		return false, "initializing"
+	}
+}
+
+func updateHealthStats() {
+	s := metrics.NewServerHealthStats()
+	healthy, _ := Healthy()
+	s.Gauge(metrics.ServerHealthy).Update(int64(boolToUint32(healthy)))
+	s.Gauge(metrics.ServerHealthCheckFailed).Update(int64(atomic.LoadUint32(&healthCheckFailed)))
+	s.Gauge(metrics.ServerDraining).Update(int64(atomic.LoadUint32(&draining)))
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -43,6 +43,12 @@ type ListenerType string
 const EGRESS ListenerType = "egress"
 const INGRESS ListenerType = "ingress"
 
+// Group of listener network, an empty network is tcp
+const (
+	TCP_NETWORK = "tcp"
+	UDP_NETWORK = "udp"
+)
+
 type ListenerConfig struct {
 	Name                  string          `json:"name,omitempty"`
 	Type                  ListenerType    `json:"type,omitempty"`
@@ -54,6 +60,91 @@ type ListenerConfig struct {
 	StreamFilters         []Filter        `json:"stream_filters,omitempty"`
 	Inspector             bool            `json:"inspector,omitempty"`
 	ConnectionIdleTimeout *DurationConfig `json:"connection_idle_timeout,omitempty"`
+	// ReadOriginalDst saves the destination address of the connections redirected by iptables
+	// in the connection context, the ORIGINAL_DST clusters forward the requests to it
+	ReadOriginalDst bool `json:"read_original_dst,omitempty"`
+	// DebugPayloadBytes dumps the headers and the first bytes of the bodies the stream codecs sent and received
+	// if the log level is TRACE, 0 means off
+	DebugPayloadBytes int `json:"debug_payload_bytes,omitempty"`
+	// DebugRedactHeaders are the headers redacted in the dumps, authorization, cookie and set-cookie are redacted if it is empty
+	DebugRedactHeaders []string `json:"debug_redact_headers,omitempty"`
+	// MaxConnections limits the connections of the listener, the new connections beyond it are closed, 0 means no limit
+	MaxConnections uint32 `json:"max_connections,omitempty"`
+	// MaxConcurrentStreams limits the concurrent streams of a connection,
+	// the streams beyond it get an overflow response, 0 means no limit
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams,omitempty"`
+	// StopAcceptOnOverload closes the new connections of the listener while the overload manager stops accepting
+	StopAcceptOnOverload bool `json:"stop_accept_on_overload,omitempty"`
+	// UseProxyProto reads the PROXY protocol v1 or v2 header before the tls handshake and the protocol sniffing,
+	// the addresses in the header are the connection's remote and local addresses.
+	// The connections without a valid header are closed
+	UseProxyProto bool `json:"use_proxy_proto,omitempty"`
+	// Network is the transport of the listener, tcp or udp. The udp listeners receive the datagrams
+	// and pass them to the network filters supporting udp, such as the udp proxy
+	Network string `json:"network,omitempty"`
+	// SocketOptions are applied to the listener socket when it is bound, the listeners not bound to port
+	// only receive the connections redirected by iptables or transferred by the hot upgrade
+	SocketOptions *ListenerSocketOptions `json:"socket_options,omitempty"`
+	// TransferConnection gates transferring the established connections to the new mosn in the hot upgrade.
+	// If it is not configured, the connections are transferred if their protocol can be resumed, such as sofarpc.
+	// If it is false, the connections are kept by the old mosn until it exits.
+	// The tcp proxy connections are never transferred, their upstream connections stay in the old mosn
+	TransferConnection *bool `json:"transfer_connection,omitempty"`
+	// RequestID configures the request id that correlates the logs, the access logs and the traces
+	// of the downstream requests and their upstream requests. The request ids are only set on the http
+	// requests of the listeners configuring it
+	RequestID *RequestIDConfig `json:"request_id,omitempty"`
+	// WriteBufferWatermarks is the flow control of the downstream connections,
+	// the upstream responses are not read while the downstream connection is backed up
+	WriteBufferWatermarks *WriteBufferWatermarks `json:"write_buffer_watermarks,omitempty"`
+}
+
+// RequestIDConfig configures the request id of the streams proxied by the listener
+type RequestIDConfig struct {
+	// Header is the header the request id is propagated to the upstream by, default is x-request-id
+	Header string `json:"header,omitempty"`
+	// TrustDownstream adopts the request id in the downstream request header if it is present,
+	// otherwise the request id is always regenerated
+	TrustDownstream bool `json:"trust_downstream,omitempty"`
+}
+
+// ListenerSocketOptions is the socket options of a tcp listener.
+// The inherited listener keeps the options of the old process, it must have the same reuse port and ipv6 only options
+type ListenerSocketOptions struct {
+	// ReusePort allows multiple processes to bind the same address, the connections are balanced by the kernel
+	ReusePort bool `json:"reuse_port,omitempty"`
+	// TCPFastOpenQueueLength enables the tcp fast open with the max pending fast open requests, 0 means off
+	TCPFastOpenQueueLength int `json:"tcp_fast_open_queue_length,omitempty"`
+	// Backlog is the max pending connections to be accepted, 0 means the system default
+	Backlog int `json:"backlog,omitempty"`
+	// IPv6Only restricts the listener of an ipv6 address to accept the ipv6 connections only,
+	// the system default is used if it is not configured
+	IPv6Only *bool `json:"ipv6_only,omitempty"`
+}
+
+// WriteBufferWatermarks is the flow control of a connection's write buffer. Once the bytes written but not sent
+// are above the high watermark, the reading of the peer connection is disabled, and it is enabled again once the
+// bytes drain to the low watermark. The flow control is disabled if the high watermark is 0
+type WriteBufferWatermarks struct {
+	High uint32 `json:"high,omitempty"`
+	// Low is half of the high watermark if it is not configured
+	Low uint32 `json:"low,omitempty"`
+}
+
+// OverloadConfig configs the overload manager.
+// The pressure is the max ratio of the usage to the configured max of the resources, the actions are
+// engaged when the pressure reaches their thresholds, and disengaged when it falls below the thresholds minus the hysteresis.
+// A zero max or threshold disables the resource or the action
+type OverloadConfig struct {
+	RefreshInterval *DurationConfig `json:"refresh_interval,omitempty"`
+	// MaxHeapBytes is the max heap in use bytes
+	MaxHeapBytes  uint64 `json:"max_heap_bytes,omitempty"`
+	MaxGoroutines uint64 `json:"max_goroutines,omitempty"`
+	// StopAcceptingThreshold stops accepting new connections on the listeners with stop_accept_on_overload
+	StopAcceptingThreshold float64 `json:"stop_accepting_threshold,omitempty"`
+	// RejectStreamsThreshold answers the new http streams with 503 before they are proxied
+	RejectStreamsThreshold float64 `json:"reject_streams_threshold,omitempty"`
+	Hysteresis             float64 `json:"hysteresis,omitempty"`
 }
 
 type TCPRouteConfig struct {
@@ -82,6 +173,7 @@ type DelayInjectConfig struct {
 type RouterConfigurationConfig struct {
 	RouterConfigName        string               `json:"router_config_name,omitempty"`
 	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
+	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
 	RouterConfigPath        string               `json:"router_configs,omitempty"`
@@ -94,39 +186,95 @@ type RouterConfig struct {
 	DirectResponse  *DirectResponseAction  `json:"direct_response,omitempty"`
 	MetadataConfig  *MetadataConfig        `json:"metadata,omitempty"`
 	PerFilterConfig map[string]interface{} `json:"per_filter_config,omitempty"`
+	MaxRequestBytes uint64                 `json:"max_request_bytes,omitempty"`
 }
 
 type RouterActionConfig struct {
-	ClusterName             string               `json:"cluster_name,omitempty"`
-	UpstreamProtocol        string               `json:"upstream_protocol,omitempty"`
-	ClusterHeader           string               `json:"cluster_header,omitempty"`
-	WeightedClusters        []WeightedCluster    `json:"weighted_clusters,omitempty"`
-	MetadataConfig          *MetadataConfig      `json:"metadata_match,omitempty"`
-	TimeoutConfig           DurationConfig       `json:"timeout,omitempty"`
-	RetryPolicy             *RetryPolicy         `json:"retry_policy,omitempty"`
-	PrefixRewrite           string               `json:"prefix_rewrite,omitempty"`
-	HostRewrite             string               `json:"host_rewrite,omitempty"`
-	AutoHostRewrite         bool                 `json:"auto_host_rewrite,omitempty"`
+	ClusterName         string            `json:"cluster_name,omitempty"`
+	UpstreamProtocol    string            `json:"upstream_protocol,omitempty"`
+	ClusterHeader       string            `json:"cluster_header,omitempty"`
+	WeightedClusters    []WeightedCluster `json:"weighted_clusters,omitempty"`
+	MetadataConfig      *MetadataConfig   `json:"metadata_match,omitempty"`
+	TimeoutConfig       DurationConfig    `json:"timeout,omitempty"`
+	PerTryTimeoutConfig DurationConfig    `json:"per_try_timeout,omitempty"`
+	RetryPolicy         *RetryPolicy      `json:"retry_policy,omitempty"`
+	HashPolicy          []HashPolicy      `json:"hash_policy,omitempty"`
+	PrefixRewrite       string            `json:"prefix_rewrite,omitempty"`
+	HostRewrite         string            `json:"host_rewrite,omitempty"`
+	AutoHostRewrite     bool              `json:"auto_host_rewrite,omitempty"`
+	RegexRewrite        *RegexRewrite     `json:"regex_rewrite,omitempty"`
+	// KeepOriginalPath keeps the path before rewritten in the x-mosn-original-path header
+	KeepOriginalPath        bool                 `json:"keep_original_path,omitempty"`
 	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
+	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	// Priority selects the circuit breakers thresholds of the upstream cluster
+	Priority RoutingPriority `json:"priority,omitempty"`
 }
 
 type ClusterWeightConfig struct {
 	Name           string          `json:"name,omitempty"`
 	Weight         uint32          `json:"weight,omitempty"`
 	MetadataConfig *MetadataConfig `json:"metadata_match,omitempty"`
+	// RequestHeadersToAdd are added to the requests routed to the cluster, so the upstream can identify the canary
+	RequestHeadersToAdd []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
 }
 
+// RetryPolicyConfig is the retry config of a route, the retry timeout is the timeout of each try
 type RetryPolicyConfig struct {
 	RetryOn            bool           `json:"retry_on,omitempty"`
 	RetryTimeoutConfig DurationConfig `json:"retry_timeout,omitempty"`
 	NumRetries         uint32         `json:"num_retries,omitempty"`
+	// RetryOnConditions are the conditions to retry a request, retry_on retries on 5xx, connect-failure and reset
+	// if the conditions are not configured. only the connect failures are retried if neither is configured
+	RetryOnConditions []string `json:"retry_on_conditions,omitempty"`
+	// RetriableStatusCodes are the codes retried by the retriable-status-codes condition
+	RetriableStatusCodes []uint32 `json:"retriable_status_codes,omitempty"`
+}
+
+// The retry on conditions
+const (
+	// RetryOn5xx retries if the upstream responds a 5xx code, or does not respond in the try timeout
+	RetryOn5xx = "5xx"
+	// RetryOnConnectFailure retries if the connection to the upstream is failed
+	RetryOnConnectFailure = "connect-failure"
+	// RetryOnReset retries if the upstream resets the request
+	RetryOnReset = "reset"
+	// RetryOnRetriableStatusCodes retries if the upstream responds one of the retriable status codes
+	RetryOnRetriableStatusCodes = "retriable-status-codes"
+)
+
+// RegexRewrite rewrites the path matched by the pattern, the substitution can reference the capture groups, such as $1
+type RegexRewrite struct {
+	Pattern      string `json:"pattern,omitempty"`
+	Substitution string `json:"substitution,omitempty"`
+}
+
+// HashPolicy specifies the hash key of a request for the hash based load balancers, such as LB_RINGHASH.
+// The policies are tried in order, the first one that gets a key from the request is used
+type HashPolicy struct {
+	// Header hashes the value of the request header
+	Header string `json:"header,omitempty"`
+	// SourceIP hashes the source ip of the downstream connection
+	SourceIP bool `json:"source_ip,omitempty"`
 }
 
 type FilterChainConfig struct {
+	Name             string      `json:"name,omitempty"`
 	FilterChainMatch string      `json:"match,omitempty"`
 	TLSConfig        *TLSConfig  `json:"tls_context,omitempty"`
 	TLSConfigs       []TLSConfig `json:"tls_context_set,omitempty"`
 	Filters          []Filter    `json:"filters,omitempty"`
+	// MatchConfig selects the filter chain for the connections by the tls server name,
+	// the connections without a matched server name use the default filter chain
+	MatchConfig *FilterChainMatchConfig `json:"filter_chain_match,omitempty"`
+	// StreamFilters overrides the listener's stream filters for the connections of the filter chain
+	StreamFilters []Filter `json:"stream_filters,omitempty"`
+}
+
+// FilterChainMatchConfig is the criteria to select a filter chain for a connection
+type FilterChainMatchConfig struct {
+	// ServerNames is the tls server names (SNI) of the filter chain, the wildcard names such as *.example.com are supported
+	ServerNames []string `json:"server_names,omitempty"`
 }
@@ -40,6 +40,7 @@ const (
 	CONNECTION_MANAGER          = "connection_manager"
 	DEFAULT_NETWORK_FILTER      = "proxy"
 	TCP_PROXY                   = "tcp_proxy"
+	UDP_PROXY                   = "udp_proxy"
 	FAULT_INJECT_NETWORK_FILTER = "fault_inject"
 	RPC_PROXY                   = "rpc_proxy"
 	X_PROXY                     = "x_proxy"
@@ -47,9 +48,13 @@ const (
 
 // Stream Filter's Type
 const (
-	MIXER        = "mixer"
-	FaultStream  = "fault"
-	PayloadLimit = "payload_limit"
+	MIXER          = "mixer"
+	FaultStream    = "fault"
+	PayloadLimit   = "payload_limit"
+	LocalRateLimit = "local_ratelimit"
+	Gzip           = "gzip"
+	HeaderMutation = "header_mutation"
+	Cors           = "cors"
 )
 
 // ClusterType
@@ -61,6 +66,9 @@ const (
 	SIMPLE_CLUSTER  ClusterType = "SIMPLE"
 	DYNAMIC_CLUSTER ClusterType = "DYNAMIC"
 	EDS_CLUSTER     ClusterType = "EDS"
+	// ORIGINAL_DST_CLUSTER forwards to the original destination of the downstream connection,
+	// its hosts are not configured
+	ORIGINAL_DST_CLUSTER ClusterType = "ORIGINAL_DST"
 )
 
 // LbType
@@ -70,23 +78,146 @@ type LbType string
 const (
 	LB_RANDOM     LbType = "LB_RANDOM"
 	LB_ROUNDROBIN LbType = "LB_ROUNDROBIN"
+	LB_RINGHASH   LbType = "LB_RINGHASH"
+)
+
+// ConnPoolMode
+type ConnPoolMode string
+
+// Group of connection pool mode, an empty mode is the shared mode
+const (
+	SHARED_CONN_POOL         ConnPoolMode = "shared"
+	PER_DOWNSTREAM_CONN_POOL ConnPoolMode = "per_downstream"
+)
+
+// ProxyProtocolVersion
+type ProxyProtocolVersion string
+
+// Group of proxy protocol version, an empty version sends no proxy protocol header
+const (
+	PROXY_PROTOCOL_V1 ProxyProtocolVersion = "v1"
+	PROXY_PROTOCOL_V2 ProxyProtocolVersion = "v2"
+)
+
+// RoutingPriority selects the circuit breakers thresholds of the cluster
+type RoutingPriority string
+
+// Group of routing priority, an empty priority is the default priority
+const (
+	DEFAULT_PRIORITY RoutingPriority = "DEFAULT"
+	HIGH_PRIORITY    RoutingPriority = "HIGH"
 )
 
 // Cluster represents a cluster's information
 type Cluster struct {
-	Name                 string          `json:"name,omitempty"`
-	ClusterType          ClusterType     `json:"type,omitempty"`
-	SubType              string          `json:"sub_type,omitempty"` //not used yet
-	LbType               LbType          `json:"lb_type,omitempty"`
-	MaxRequestPerConn    uint32          `json:"max_request_per_conn,omitempty"`
-	ConnBufferLimitBytes uint32          `json:"conn_buffer_limit_bytes,omitempty"`
-	CirBreThresholds     CircuitBreakers `json:"circuit_breakers,omitempty"`
-	HealthCheck          HealthCheck     `json:"health_check,omitempty"`
-	Spec                 ClusterSpecInfo `json:"spec,omitempty"`
-	LBSubSetConfig       LBSubsetConfig  `json:"lb_subset_config,omitempty"`
-	TLS                  TLSConfig       `json:"tls_context,omitempty"`
-	Hosts                []Host          `json:"hosts,omitempty"`
-	ConnectTimeout       *DurationConfig `json:"connect_timeout,omitempty"`
+	Name                 string            `json:"name,omitempty"`
+	ClusterType          ClusterType       `json:"type,omitempty"`
+	SubType              string            `json:"sub_type,omitempty"` //not used yet
+	LbType               LbType            `json:"lb_type,omitempty"`
+	MaxRequestPerConn    uint32            `json:"max_request_per_conn,omitempty"`
+	ConnBufferLimitBytes uint32            `json:"conn_buffer_limit_bytes,omitempty"`
+	CirBreThresholds     CircuitBreakers   `json:"circuit_breakers,omitempty"`
+	HealthCheck          HealthCheck       `json:"health_check,omitempty"`
+	Spec                 ClusterSpecInfo   `json:"spec,omitempty"`
+	LBSubSetConfig       LBSubsetConfig    `json:"lb_subset_config,omitempty"`
+	TLS                  TLSConfig         `json:"tls_context,omitempty"`
+	Hosts                []Host            `json:"hosts,omitempty"`
+	ConnectTimeout       *DurationConfig   `json:"connect_timeout,omitempty"`
+	TCPKeepalive         *TCPKeepalive     `json:"tcp_keepalive,omitempty"`
+	TCPUserTimeout       *DurationConfig   `json:"tcp_user_timeout,omitempty"`
+	ConnPoolMode         ConnPoolMode      `json:"connection_pool_mode,omitempty"`
+	MethodStats          bool              `json:"method_stats,omitempty"`
+	KeepAlive            *KeepAlive        `json:"keepalive,omitempty"`
+	OutlierDetection     *OutlierDetection `json:"outlier_detection,omitempty"`
+	RingHashConfig       *RingHashConfig   `json:"ring_hash_config,omitempty"`
+	SlowStart            *SlowStart        `json:"slow_start,omitempty"`
+	// DrainTimeout is how long a removed host's connection pools wait for the active requests before closed,
+	// 30s if it is not configured, zero closes the connection pools at once
+	DrainTimeout *DurationConfig `json:"drain_timeout,omitempty"`
+	// OriginalDstLbConfig is used by the ORIGINAL_DST cluster only
+	OriginalDstLbConfig *OriginalDstLbConfig `json:"original_dst_lb_config,omitempty"`
+	// ProxyProtocol sends the PROXY protocol header with the downstream connection's addresses
+	// once the upstream connection is connected. A shared connection would carry the addresses of the
+	// downstream connection created it, so the per_downstream connection pool mode is required
+	ProxyProtocol ProxyProtocolVersion `json:"proxy_protocol,omitempty"`
+	// WriteBufferWatermarks is the flow control of the upstream connections,
+	// the downstream requests are not read while the upstream connection is backed up
+	WriteBufferWatermarks *WriteBufferWatermarks `json:"write_buffer_watermarks,omitempty"`
+}
+
+// OriginalDstLbConfig is the config of the ORIGINAL_DST cluster
+type OriginalDstLbConfig struct {
+	// UseHTTPHeader takes the destination from the x-mosn-original-dst-host header,
+	// the downstream connection's original destination is used if the header is not found
+	UseHTTPHeader bool `json:"use_http_header,omitempty"`
+	// IdleTimeout is how long an unused destination host is kept, 5s if it is not configured
+	IdleTimeout *DurationConfig `json:"idle_timeout,omitempty"`
+}
+
+// TCPKeepalive is the tcp keepalive config of the upstream connections
+// the zero values use the system default
+type TCPKeepalive struct {
+	Enable   bool            `json:"enable,omitempty"`
+	Idle     *DurationConfig `json:"idle,omitempty"`
+	Interval *DurationConfig `json:"interval,omitempty"`
+	Probes   uint32          `json:"probes,omitempty"`
+}
+
+// KeepAlive is the heartbeat config of the upstream connections
+// the heartbeats are sent only when the connection is idle if the interval is not configured
+type KeepAlive struct {
+	Interval         *DurationConfig `json:"interval,omitempty"`
+	Timeout          *DurationConfig `json:"timeout,omitempty"`
+	FailCountToClose uint32          `json:"fail_count_to_close,omitempty"`
+	// JitterPercent is the random percent added to or subtracted from the interval, 20 if it is not configured
+	JitterPercent *uint32 `json:"jitter_percent,omitempty"`
+	// MaxInterval caps the interval doubled after each timeout, 8 times of the interval if it is not configured,
+	// the backoff is disabled if it is not greater than the interval
+	MaxInterval *DurationConfig `json:"max_interval,omitempty"`
+	// HTTPProbe enables the heartbeats on the idle http1 upstream connections
+	HTTPProbe *HTTPKeepAliveProbe `json:"http_probe,omitempty"`
+}
+
+// HTTPKeepAliveProbe is the request sent as the heartbeat on the http1 upstream connections,
+// the request is "OPTIONS /" if the method and path are not configured
+type HTTPKeepAliveProbe struct {
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// OutlierDetection is the config of ejecting the outlier hosts, such as the hosts failed the keepalive,
+// or the hosts returning consecutive errors
+type OutlierDetection struct {
+	// BaseEjectionTime is multiplied by the times the host is ejected, 30s if it is not configured
+	BaseEjectionTime *DurationConfig `json:"base_ejection_time,omitempty"`
+	// MaxEjectionTime caps the ejection time, 300s if it is not configured
+	MaxEjectionTime *DurationConfig `json:"max_ejection_time,omitempty"`
+	// Consecutive5xx is the consecutive 5xx responses or timeouts to eject a host, zero means disabled
+	Consecutive5xx uint32 `json:"consecutive_5xx,omitempty"`
+	// ConsecutiveConnectFailure is the consecutive connect failures to eject a host, zero means disabled
+	ConsecutiveConnectFailure uint32 `json:"consecutive_connect_failure,omitempty"`
+	// MaxEjectionPercent is the max percent of the ejected hosts in the cluster, 10 if it is not configured
+	MaxEjectionPercent uint32 `json:"max_ejection_percent,omitempty"`
+}
+
+// RingHashConfig is the config of the LB_RINGHASH load balancer,
+// the zero values use the default ring size
+type RingHashConfig struct {
+	// MinimumRingSize is the min virtual nodes in the ring, a larger ring balances the requests better
+	MinimumRingSize uint64 `json:"minimum_ring_size,omitempty"`
+	// MaximumRingSize caps the virtual nodes in the ring, the ring may be smaller than the minimum size if it is reached
+	MaximumRingSize uint64 `json:"maximum_ring_size,omitempty"`
+}
+
+// SlowStart is the config of ramping up the traffic to a host after it is added or becomes healthy,
+// the slow start is disabled if the duration is not configured
+type SlowStart struct {
+	SlowStartDuration *DurationConfig `json:"slow_start_duration,omitempty"`
+	// Aggression is the exponent of the ramp, 1 means a linear ramp, a larger value sends more traffic at the beginning
+	Aggression float64 `json:"aggression,omitempty"`
+	// MinWeightPercent is the min percent of the weight during the slow start, 10 if it is not configured
+	MinWeightPercent uint32 `json:"min_weight_percent,omitempty"`
 }
 
 // HealthCheck is a configuration of health check
@@ -116,6 +247,12 @@ func (hc *HealthCheck) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// The range of the host weight, the weight out of the range is clamped
+const (
+	MinHostWeight = uint32(1)
+	MaxHostWeight = uint32(128)
+)
+
 // Host represenets a host information
 type Host struct {
 	HostConfig
@@ -193,6 +330,63 @@ type StreamPayloadLimit struct {
 	HttpStatus    int32 `json:"http_status"`
 }
 
+// StreamLocalRateLimit limits the requests by the token buckets.
+// the requests share a bucket if DescriptorHeaders is empty, or the requests with the same
+// headers values share a bucket
+type StreamLocalRateLimit struct {
+	MaxTokens     uint32         `json:"max_tokens,omitempty"`
+	TokensPerFill uint32         `json:"tokens_per_fill,omitempty"`
+	FillInterval  DurationConfig `json:"fill_interval,omitempty"`
+	// DescriptorHeaders are the headers to build the bucket key
+	DescriptorHeaders []string `json:"descriptor_headers,omitempty"`
+	// MaxDescriptors is the max buckets keyed by the descriptor headers, the least recently used one is evicted
+	MaxDescriptors int `json:"max_descriptors,omitempty"`
+	// Status is the response status of the limited requests, default is 429
+	Status               int            `json:"status,omitempty"`
+	ResponseHeadersToAdd []*HeaderValue `json:"response_headers_to_add,omitempty"`
+	// EnableRateLimitHeaders adds the x-ratelimit-limit and x-ratelimit-remaining headers to the limited responses
+	EnableRateLimitHeaders bool `json:"enable_x_ratelimit_headers,omitempty"`
+}
+
+// StreamGzip compresses the responses if the downstream accepts the gzip encoding
+type StreamGzip struct {
+	// MinContentLength is the min length of the response body to be compressed
+	MinContentLength int `json:"min_content_length,omitempty"`
+	// MaxContentLength is the max length of the response body to be compressed, default is 1MB.
+	// the whole body is compressed into a buffer, so the larger responses are sent as is
+	MaxContentLength int `json:"max_content_length,omitempty"`
+	// ContentTypes are the content types of the responses to be compressed,
+	// the text and json types are used if it is empty
+	ContentTypes []string `json:"content_types,omitempty"`
+	// CompressionLevel is the level of the compress/gzip, default is gzip.DefaultCompression
+	CompressionLevel *int `json:"compression_level,omitempty"`
+}
+
+// StreamHeaderMutation adds and removes the request and response headers,
+// the values to add can reference the request info fields such as %DOWNSTREAM_REMOTE_ADDRESS%
+type StreamHeaderMutation struct {
+	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
+	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
+	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
+	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+}
+
+// StreamCors handles the cross-origin requests
+type StreamCors struct {
+	// AllowOrigins are matched exactly, "*" allows any origin
+	AllowOrigins []string `json:"allow_origins,omitempty"`
+	// AllowOriginSuffixes are matched as the domain suffixes of the origin, such as ".example.com"
+	AllowOriginSuffixes []string       `json:"allow_origin_suffixes,omitempty"`
+	AllowMethods        []string       `json:"allow_methods,omitempty"`
+	AllowHeaders        []string       `json:"allow_headers,omitempty"`
+	ExposeHeaders       []string       `json:"expose_headers,omitempty"`
+	MaxAge              DurationConfig `json:"max_age,omitempty"`
+	// AllowCredentials can not be used with the allow origin "*"
+	AllowCredentials bool `json:"allow_credentials,omitempty"`
+	// ShadowEnabled only counts the requests would be allowed or denied, the requests and responses are not modified
+	ShadowEnabled bool `json:"shadow_enabled,omitempty"`
+}
+
 func (f FaultInject) Marshal() (b []byte, err error) {
 	f.FaultInjectConfig.DelayDurationConfig.Duration = time.Duration(f.DelayDuration)
 	return json.Marshal(f.FaultInjectConfig)
@@ -212,6 +406,8 @@ type StreamFaultInject struct {
 	Abort           *AbortInject    `json:"abort,omitempty"`
 	UpstreamCluster string          `json:"upstream_cluster,omitempty"`
 	Headers         []HeaderMatcher `json:"headers,omitempty"`
+	// Deterministic makes the percentages hit by the hash of the stream id instead of a random number
+	Deterministic bool `json:"deterministic,omitempty"`
 }
 
 type DelayInject struct {
@@ -235,6 +431,9 @@ func (d *DelayInject) UnmarshalJSON(b []byte) error {
 type AbortInject struct {
 	Status  int    `json:"status,omitempty"`
 	Percent uint32 `json:"percentage,omitempty"`
+	// RPCStatus is the response status of the rpc protocols such as the bolt, if it is not set,
+	// the rpc response status is mapped from the Status
+	RPCStatus int16 `json:"rpc_status,omitempty"`
 }
 
 type Mixer struct {
@@ -267,11 +466,13 @@ type RouteAction struct {
 	RouterActionConfig
 	MetadataMatch Metadata      `json:"-"`
 	Timeout       time.Duration `json:"-"`
+	PerTryTimeout time.Duration `json:"-"`
 }
 
 func (r RouteAction) MarshalJSON() (b []byte, err error) {
 	r.RouterActionConfig.MetadataConfig = metadataToConfig(r.MetadataMatch)
 	r.RouterActionConfig.TimeoutConfig.Duration = r.Timeout
+	r.RouterActionConfig.PerTryTimeoutConfig.Duration = r.PerTryTimeout
 	return json.Marshal(r.RouterActionConfig)
 }
 
@@ -280,6 +481,7 @@ func (r *RouteAction) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	r.Timeout = r.RouterActionConfig.TimeoutConfig.Duration
+	r.PerTryTimeout = r.RouterActionConfig.PerTryTimeoutConfig.Duration
 	r.MetadataMatch = configToMetadata(r.MetadataConfig)
 	return nil
 }
@@ -341,11 +543,28 @@ func (cb *CircuitBreakers) UnmarshalJSON(b []byte) (err error) {
 	return json.Unmarshal(b, &cb.Thresholds)
 }
 
+// Thresholds is the circuit breakers of a priority, the thresholds without priority are the default priority's
 type Thresholds struct {
-	MaxConnections     uint32 `json:"max_connections,omitempty"`
-	MaxPendingRequests uint32 `json:"max_pending_requests,omitempty"`
-	MaxRequests        uint32 `json:"max_requests,omitempty"`
-	MaxRetries         uint32 `json:"max_retries,omitempty"`
+	Priority           RoutingPriority `json:"priority,omitempty"`
+	MaxConnections     uint32          `json:"max_connections,omitempty"`
+	MaxPendingRequests uint32          `json:"max_pending_requests,omitempty"`
+	MaxRequests        uint32          `json:"max_requests,omitempty"`
+	MaxRetries         uint32          `json:"max_retries,omitempty"`
+	// MaxConnectFailures is the consecutive connect failures to a host before the
+	// connection pool stops dialing it for a backoff window, zero means disabled
+	MaxConnectFailures uint32          `json:"max_connect_failures,omitempty"`
+	ConnectBackoffBase *DurationConfig `json:"connect_backoff_base,omitempty"`
+	ConnectBackoffMax  *DurationConfig `json:"connect_backoff_max,omitempty"`
+	// RetryBudget limits the retries by the active requests, max_retries is ignored if it is configured
+	RetryBudget *RetryBudget `json:"retry_budget,omitempty"`
+}
+
+// RetryBudget is the max percent of the active requests that may be retries
+type RetryBudget struct {
+	// BudgetPercent is 20 if it is not configured
+	BudgetPercent uint32 `json:"budget_percent,omitempty"`
+	// MinRetryConcurrency is the retries allowed regardless of the active requests, 3 if it is not configured
+	MinRetryConcurrency uint32 `json:"min_retry_concurrency,omitempty"`
 }
 
 // ClusterSpecInfo is a configuration of subscribe
@@ -361,11 +580,23 @@ type SubscribeSpec struct {
 
 // LBSubsetConfig is a configuration of load balance subset
 type LBSubsetConfig struct {
-	FallBackPolicy  uint8             `json:"fall_back_policy,omitempty"`
-	DefaultSubset   map[string]string `json:"default_subset,omitempty"`
-	SubsetSelectors [][]string        `json:"subset_selectors,omitempty"`
+	FallBackPolicy uint8 `json:"fall_back_policy,omitempty"`
+	// FallbackPolicy is the named fall back policy, it takes precedence over the FallBackPolicy if it is configured
+	FallbackPolicy  SubsetFallbackPolicy `json:"fallback_policy,omitempty"`
+	DefaultSubset   map[string]string    `json:"default_subset,omitempty"`
+	SubsetSelectors [][]string           `json:"subset_selectors,omitempty"`
 }
 
+// SubsetFallbackPolicy is the policy when no subset matches the route's metadata
+type SubsetFallbackPolicy string
+
+// Subset fall back policies
+const (
+	NO_FALLBACK    SubsetFallbackPolicy = "NO_FALLBACK"
+	ANY_ENDPOINT   SubsetFallbackPolicy = "ANY_ENDPOINT"
+	DEFAULT_SUBSET SubsetFallbackPolicy = "DEFAULT_SUBSET"
+)
+
 // TLSConfig is a configuration of tls context
 type TLSConfig struct {
 	Status            bool                   `json:"status,omitempty"`
@@ -384,6 +615,7 @@ type TLSConfig struct {
 	ALPN              string                 `json:"alpn,omitempty"`
 	Ticket            string                 `json:"ticket,omitempty"`
 	Fallback          bool                   `json:"fall_back,omitempty"`
+	WatchFiles        bool                   `json:"watch_files,omitempty"`
 	ExtendVerify      map[string]interface{} `json:"extend_verify,omitempty"`
 	SdsConfig         *SdsConfig             `json:"sds_source,omitempty"`
 }
@@ -402,7 +634,46 @@ func (c *SdsConfig) Valid() bool {
 type AccessLog struct {
 	Path   string `json:"log_path,omitempty"`
 	Format string `json:"log_format,omitempty"`
-}
+	// AbsentHeader is written for the headers in the format not found, such as "-",
+	// nothing is written by default
+	AbsentHeader string           `json:"absent_header,omitempty"`
+	Roller       *LogRollerConfig `json:"log_roller,omitempty"`
+	Buffer       *LogBufferConfig `json:"log_buffer,omitempty"`
+}
+
+// LogRollerConfig rotates the log file when its size exceeds the max size,
+// the rotated files are suffixed with the rotate time, and pruned by the max age and max backups.
+// the zero max size is 100 megabytes, the zero max age and max backups keep all the rotated files.
+type LogRollerConfig struct {
+	// MaxSize is the max size in megabytes of a log file
+	MaxSize int `json:"max_size,omitempty"`
+	// MaxAge is the max days to keep a rotated file
+	MaxAge int `json:"max_age,omitempty"`
+	// MaxBackups is the max rotated files to keep
+	MaxBackups int `json:"max_backups,omitempty"`
+	// Compress compresses the rotated files by gzip
+	Compress bool `json:"compress,omitempty"`
+}
+
+// LogBufferConfig is the config of the logs buffered before written by the logger's goroutine
+type LogBufferConfig struct {
+	// BufferSize is the max logs buffered, 1000 if it is zero
+	BufferSize int `json:"buffer_size,omitempty"`
+	// FlushInterval is the interval the buffered logs are written in a batch,
+	// the logs are written as soon as possible if it is not configured
+	FlushInterval *DurationConfig `json:"flush_interval,omitempty"`
+	// OverflowPolicy is the policy if the buffer is full, the log is dropped by default
+	OverflowPolicy LogOverflowPolicy `json:"overflow_policy,omitempty"`
+}
+
+// LogOverflowPolicy is the policy of the logs written when the buffer is full
+type LogOverflowPolicy string
+
+// Log overflow policies
+const (
+	LOG_OVERFLOW_DROP  LogOverflowPolicy = "DROP"
+	LOG_OVERFLOW_BLOCK LogOverflowPolicy = "BLOCK"
+)
 
 // FilterChain wraps a set of match criteria, an option TLS context,
 // a set of filters, and various other parameters.
@@ -456,6 +727,15 @@ type TCPProxy struct {
 	Routes             []*TCPRoute    `json:"routes,omitempty"`
 }
 
+// UDPProxy is the config of the udp proxy network filter, each downstream address of the listener
+// is a session forwarding the datagrams to a host of the cluster
+type UDPProxy struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
+	// IdleTimeout is how long a session without datagrams in both directions is kept, 60s if it is not configured
+	IdleTimeout *DurationConfig `json:"idle_timeout,omitempty"`
+}
+
 // WebSocketProxy
 type WebSocketProxy struct {
 	StatPrefix         string
@@ -470,7 +750,22 @@ type Proxy struct {
 	UpstreamProtocol   string                 `json:"upstream_protocol,omitempty"`
 	RouterConfigName   string                 `json:"router_config_name,omitempty"`
 	ValidateClusters   bool                   `json:"validate_clusters,omitempty"`
+	LoopDetection      *LoopDetection         `json:"loop_detection,omitempty"`
 	ExtendConfig       map[string]interface{} `json:"extend_config,omitempty"`
+	// Timeout is the upstream timeout of the routes without a timeout, 60s if it is not configured
+	Timeout *DurationConfig `json:"timeout,omitempty"`
+}
+
+// LoopDetection configures how a proxy detects requests that are proxied in a loop
+type LoopDetection struct {
+	// MaxHops is the max hops a request can be forwarded, zero means the default limit
+	MaxHops uint32 `json:"max_hops,omitempty"`
+	// InstanceCheck rejects requests that have passed through this instance already
+	InstanceCheck bool `json:"instance_check,omitempty"`
+	// InstanceID identifies this instance, the service node is used if it is empty
+	InstanceID string `json:"instance_id,omitempty"`
+	// ExternalEgress strips the loop detection headers before the requests leave the mesh
+	ExternalEgress bool `json:"external_egress,omitempty"`
 }
 
 // HeaderValueOption is header name/value pair plus option to control append behavior.
@@ -485,6 +780,17 @@ type HeaderValue struct {
 	Value string `json:"value,omitempty"`
 }
 
+// RouteValidationMode is how the routes referencing the clusters not configured are handled
+type RouteValidationMode string
+
+// Group of route validation mode, an empty mode is the warn mode
+const (
+	// ROUTE_VALIDATION_WARN installs the routes and logs the dangling cluster references
+	ROUTE_VALIDATION_WARN RouteValidationMode = "warn"
+	// ROUTE_VALIDATION_STRICT rejects the router config has dangling cluster references
+	ROUTE_VALIDATION_STRICT RouteValidationMode = "strict"
+)
+
 // RouterConfiguration is a filter for routers
 // Filter type is:  "CONNECTION_MANAGER"
 type RouterConfiguration struct {
@@ -574,16 +880,21 @@ type VirtualHost struct {
 	Routers                 []Router             `json:"routers,omitempty"`
 	RequireTLS              string               `json:"require_tls,omitempty"` // not used yet
 	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
+	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	MaxRequestBytes         uint64               `json:"max_request_bytes,omitempty"`
+	// PerFilterConfig is the stream filters config of the routes in the virtual host
+	PerFilterConfig map[string]interface{} `json:"per_filter_config,omitempty"`
 }
 
 // RouterMatch represents the route matching parameters
 type RouterMatch struct {
-	Prefix  string          `json:"prefix,omitempty"`  // Match request's Path with Prefix Comparing
-	Path    string          `json:"path,omitempty"`    // Match request's Path with Exact Comparing
-	Regex   string          `json:"regex,omitempty"`   // Match request's Path with Regex Comparing
-	Headers []HeaderMatcher `json:"headers,omitempty"` // Match request's Headers
+	Prefix          string                  `json:"prefix,omitempty"`           // Match request's Path with Prefix Comparing
+	Path            string                  `json:"path,omitempty"`             // Match request's Path with Exact Comparing
+	Regex           string                  `json:"regex,omitempty"`            // Match request's Path with Regex Comparing
+	Headers         []HeaderMatcher         `json:"headers,omitempty"`          // Match request's Headers
+	QueryParameters []QueryParameterMatcher `json:"query_parameters,omitempty"` // Match request's Query Parameters
 }
 
 // DirectResponseAction represents the direct response parameters
@@ -605,6 +916,22 @@ type HeaderMatcher struct {
 	Name  string `json:"name,omitempty"`
 	Value string `json:"value,omitempty"`
 	Regex bool   `json:"regex,omitempty"`
+	// Prefix and Suffix match the header value by the prefix and the suffix instead of the value
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	// Present matches the header's presence only
+	Present bool `json:"present,omitempty"`
+	// Invert inverts the match result, a missing header matches the inverted matcher
+	Invert bool `json:"invert,omitempty"`
+}
+
+// QueryParameterMatcher specifies a query parameter that the route should match on.
+// An empty value without regex matches the parameter's presence only
+type QueryParameterMatcher struct {
+	Name    string `json:"name,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Regex   bool   `json:"regex,omitempty"`
+	Present bool   `json:"present,omitempty"`
 }
 
 // XProxyExtendConfig
@@ -612,6 +939,32 @@ type XProxyExtendConfig struct {
 	SubProtocol string `json:"sub_protocol,omitempty"`
 }
 
+// SofaRPCExtendConfig is the sofarpc config in the proxy's extend config
+type SofaRPCExtendConfig struct {
+	// HeartbeatPassThrough proxies the heartbeats as the normal requests,
+	// otherwise the heartbeats are replied by the server stream directly
+	HeartbeatPassThrough bool `json:"heartbeat_pass_through,omitempty"`
+	// UpstreamSubProtocol converts the requests to the sub protocol (boltv1 or boltv2) before sending to the upstream,
+	// and the responses are converted back to the sub protocol of the downstream. keeps the sub protocol if empty
+	UpstreamSubProtocol string `json:"upstream_sub_protocol,omitempty"`
+	// HTTPMappings maps the bolt requests to the http requests if the upstream protocol is http1,
+	// the requests matched no mapping are tunneled with the bolt fields in the http headers
+	HTTPMappings []SofaRPCHTTPMapping `json:"http_mappings,omitempty"`
+}
+
+// SofaRPCHTTPMapping maps the bolt requests of the service to the http requests
+type SofaRPCHTTPMapping struct {
+	// Service matches the service name of the bolt request, "*" matches any service
+	Service string `json:"service"`
+	// Method is the http method, POST if empty
+	Method string `json:"method,omitempty"`
+	// Path is the path template, the "{service}", "{method}" and "{class}" are replaced by
+	// the service name, the method name and the class name of the bolt request
+	Path string `json:"path,omitempty"`
+	// Host is the http host, the upstream address if empty
+	Host string `json:"host,omitempty"`
+}
+
 // ServiceRegistryInfo
 type ServiceRegistryInfo struct {
 	ServiceAppInfo ApplicationInfo     `json:"application,omitempty"`
@@ -652,11 +1005,20 @@ type PublishContent struct {
 
 // StatsMatcher is a configuration for disabling stat instantiation.
 // TODO: support inclusion_list
-// TODO: support exclusion list/inclusion_list as pattern
 type StatsMatcher struct {
-	RejectAll       bool     `json:"reject_all,omitempty"`
-	ExclusionLabels []string `json:"exclusion_labels,omitempty"`
-	ExclusionKeys   []string `json:"exclusion_keys,omitempty"`
+	RejectAll       bool                 `json:"reject_all,omitempty"`
+	ExclusionLabels []string             `json:"exclusion_labels,omitempty"`
+	ExclusionKeys   []string             `json:"exclusion_keys,omitempty"`
+	ExclusionRules  []StatsExclusionRule `json:"exclusion_rules,omitempty"`
+}
+
+// StatsExclusionRule excludes the stats matched all of its patterns, the patterns are
+// globs in the syntax of path.Match, an empty type matches any type.
+// The whole stats of the type and labels are excluded if no keys are configured.
+type StatsExclusionRule struct {
+	Type   string            `json:"type,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Keys   []string          `json:"keys,omitempty"`
 }
 
 // ServerConfig for making up server for mosn
@@ -666,6 +1028,10 @@ type ServerConfig struct {
 	DefaultLogPath  string `json:"default_log_path,omitempty"`
 	DefaultLogLevel string `json:"default_log_level,omitempty"`
 	GlobalLogRoller string `json:"global_log_roller,omitempty"`
+	// DefaultLogRoller rotates the default log by size, it takes precedence over the global log roller
+	DefaultLogRoller *LogRollerConfig `json:"default_log_roller,omitempty"`
+	// DefaultLogBuffer is the buffer of the default log, the default buffer is used if it is not configured
+	DefaultLogBuffer *LogBufferConfig `json:"default_log_buffer,omitempty"`
 
 	UseNetpollMode bool `json:"use_netpoll_mode,omitempty"`
 	//graceful shutdown config
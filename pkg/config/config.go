@@ -34,7 +34,9 @@ import (
 
 type ContentKey string
 
-// Tracing configuration for a server
+// Tracing configuration for a server.
+// only one tracing driver works in a process, such as SOFATracer, Zipkin and SkyWalking,
+// the config is the config of the driver
 type TracingConfig struct {
 	Enable bool                   `json:"enable"`
 	Tracer string                 `json:"tracer"`
@@ -44,10 +46,11 @@ type TracingConfig struct {
 
 // MetricsConfig for metrics sinks
 type MetricsConfig struct {
-	SinkConfigs  []v2.Filter       `json:"sinks"`
-	StatsMatcher v2.StatsMatcher   `json:"stats_matcher"`
-	ShmZone      string            `json:"shm_zone"`
-	ShmSize      datasize.ByteSize `json:"shm_size"`
+	SinkConfigs   []v2.Filter       `json:"sinks"`
+	StatsMatcher  v2.StatsMatcher   `json:"stats_matcher"`
+	ShmZone       string            `json:"shm_zone"`
+	ShmSize       datasize.ByteSize `json:"shm_size"`
+	FlushInterval v2.DurationConfig `json:"flush_interval"` // the interval that the metrics are pushed to the flush sinks
 }
 
 // ClusterManagerConfig for making up cluster manager
@@ -155,12 +158,33 @@ type MOSNConfig struct {
 	RawAdmin            json.RawMessage `json:"admin,omitempty"`             // admin raw message
 	Debug               PProfConfig     `json:"pprof,omitempty"`
 	Pid                 string          `json:"pid,omitempty"` // pid file
+	// RouteValidation is how the routes referencing the clusters not configured are handled
+	RouteValidation v2.RouteValidationMode `json:"route_validation,omitempty"`
+	// DumpInterval is the min interval that the dynamic changes are written back to the config file
+	DumpInterval *v2.DurationConfig `json:"dump_interval,omitempty"`
+	// Includes are the config files merged into the servers and clusters, the patterns are relative to the config file
+	// the included servers, listeners and clusters are not written back when dumping
+	Includes []string `json:"includes,omitempty"`
+	// ReloadOnSighup makes SIGHUP reload the config file instead of starting a new mosn
+	ReloadOnSighup bool `json:"reload_on_sighup,omitempty"`
+	// Overload configs the overload manager, mosn sheds load instead of running out of memory
+	Overload *v2.OverloadConfig `json:"overload_manager,omitempty"`
+	// Runtime is the values of the runtime keys, such as feature flags, the values can be overridden by the admin api
+	Runtime map[string]interface{} `json:"runtime,omitempty"`
 }
 
 // PProfConfig is used to start a pprof server for debug
 type PProfConfig struct {
 	StartDebug bool `json:"debug"`      // If StartDebug is true, start a pprof, default is false
 	Port       int  `json:"port_value"` // If port value is 0, will use 9090 as default
+	// AdminDebug mounts the pprof and the dump apis on the admin server, default is false
+	AdminDebug bool `json:"admin_debug,omitempty"`
+	// DumpDir is the directory the heap and goroutine dumps are written to,
+	// if it is empty, will use the dumps directory under the log path
+	DumpDir string `json:"dump_dir,omitempty"`
+	// MaxDumps is the max number of the dumps retained in the DumpDir, the oldest dumps are removed,
+	// if it is 0, will use 10 as default
+	MaxDumps int `json:"max_dumps,omitempty"`
 }
 
 // Mode is mosn's starting type
@@ -200,6 +224,11 @@ func (c *MOSNConfig) GetAdmin() *xdsboot.Admin {
 	return nil
 }
 
+// GetDebug returns the debug config, the admin server mounts the debug apis by it
+func (c *MOSNConfig) GetDebug() *PProfConfig {
+	return &c.Debug
+}
+
 // protetced configPath, read only
 func GetConfigPath() string {
 	return configPath
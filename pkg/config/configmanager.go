@@ -18,8 +18,12 @@
 package config
 
 import (
+	"fmt"
+	"sync"
+
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/router"
 )
 
 // TODO: The functions in this file is for service discovery, but the function implmentation is not general, should fix it
@@ -38,9 +42,16 @@ import (
 // 3. bridge module get biz info(like service subscribe/publish, application info) from callback invocations
 // 4. biz module(like confreg) get biz info from bridge module directly
 
+// configLock protects the config from the concurrent mutations and the dump,
+// the exported functions changing the config take the lock
+var configLock sync.RWMutex
+
 // ResetServiceRegistryInfo
 // called when reset service registry info received
 func ResetServiceRegistryInfo(appInfo v2.ApplicationInfo, subServiceList []string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	// reset service info
 	config.ServiceRegistry.ServiceAppInfo = v2.ApplicationInfo{
 		AntShareCloud: appInfo.AntShareCloud,
@@ -56,14 +67,34 @@ func ResetServiceRegistryInfo(appInfo v2.ApplicationInfo, subServiceList []strin
 	config.ServiceRegistry.ServicePubInfo = []v2.PublishInfo{}
 
 	// delete subInfo / dynamic clusters
-	RemoveClusterConfig(subServiceList)
+	if removeClusterConfig(subServiceList) {
+		dump(true)
+	}
 }
 
 // AddOrUpdateClusterConfig
 // called when add cluster config info received
-func AddOrUpdateClusterConfig(clusters []v2.Cluster) {
+// the clusters are not changed if any of them is invalid
+func AddOrUpdateClusterConfig(clusters []v2.Cluster) error {
+	if err := validateClusters(clusters); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [update cluster] %v", err)
+		return err
+	}
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	addOrUpdateClusterConfig(clusters)
 	dump(true)
+	return nil
+}
+
+func validateClusters(clusters []v2.Cluster) error {
+	for i := range clusters {
+		if err := ValidateCluster(&clusters[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func addOrUpdateClusterConfig(clusters []v2.Cluster) {
@@ -93,6 +124,9 @@ func addOrUpdateClusterConfig(clusters []v2.Cluster) {
 }
 
 func RemoveClusterConfig(clusterNames []string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	if removeClusterConfig(clusterNames) {
 		dump(true)
 	}
@@ -116,9 +150,233 @@ func removeClusterConfig(clusterNames []string) bool {
 	return dirty
 }
 
+// AddOrUpdateClusterHosts
+// called when the hosts of a cluster are added or updated, the host with the same address is replaced
+// an error is returned if the cluster is not exists, the cluster should be added first
+func AddOrUpdateClusterHosts(clusterName string, hosts []v2.Host) error {
+	invalid := func(field, format string, args ...interface{}) error {
+		return &ValidationError{
+			Kind:   KindCluster,
+			Name:   clusterName,
+			Field:  field,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+	for _, host := range hosts {
+		if err := validateHost(host, invalid); err != nil {
+			log.DefaultLogger.Errorf("[configmanager] [update cluster hosts] %v", err)
+			return err
+		}
+	}
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	idx := clusterIndex(clusterName)
+	if idx == -1 {
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	cluster := &config.ClusterManager.Clusters[idx]
+	// makes a new slice, the hosts slice may be shared with the copies of the cluster config
+	newHosts := make([]v2.Host, len(cluster.Hosts), len(cluster.Hosts)+len(hosts))
+	copy(newHosts, cluster.Hosts)
+	for _, host := range hosts {
+		exist := false
+		for i := range newHosts {
+			if newHosts[i].Address == host.Address {
+				newHosts[i] = host
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			newHosts = append(newHosts, host)
+		}
+	}
+	cluster.Hosts = newHosts
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[configmanager] [update cluster hosts] cluster %s add or update %d hosts", clusterName, len(hosts))
+	}
+	dump(true)
+	return nil
+}
+
+// RemoveClusterHosts
+// called when the hosts of a cluster are removed
+// an error is returned if the cluster is not exists
+func RemoveClusterHosts(clusterName string, addrs []string) error {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	idx := clusterIndex(clusterName)
+	if idx == -1 {
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	cluster := &config.ClusterManager.Clusters[idx]
+	removed := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		removed[addr] = struct{}{}
+	}
+	newHosts := make([]v2.Host, 0, len(cluster.Hosts))
+	for _, host := range cluster.Hosts {
+		if _, ok := removed[host.Address]; !ok {
+			newHosts = append(newHosts, host)
+		}
+	}
+	if len(newHosts) == len(cluster.Hosts) {
+		return nil
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[configmanager] [remove cluster hosts] cluster %s remove %d hosts", clusterName, len(cluster.Hosts)-len(newHosts))
+	}
+	cluster.Hosts = newHosts
+	dump(true)
+	return nil
+}
+
+func clusterIndex(clusterName string) int {
+	for i := range config.ClusterManager.Clusters {
+		if config.ClusterManager.Clusters[i].Name == clusterName {
+			return i
+		}
+	}
+	return -1
+}
+
+// ClusterConfigUpdateType is the scope of a partial cluster config update
+type ClusterConfigUpdateType string
+
+// Group of ClusterConfigUpdateType
+const (
+	ClusterUpdateHealthCheck     ClusterConfigUpdateType = "health_check"
+	ClusterUpdateCircuitBreakers ClusterConfigUpdateType = "circuit_breakers"
+	ClusterUpdateHosts           ClusterConfigUpdateType = "hosts"
+	// ClusterUpdateAll means the whole cluster is added or updated, includes the hosts
+	ClusterUpdateAll ClusterConfigUpdateType = "cluster"
+)
+
+// ClusterConfigUpdateCallback is called when a part of the cluster config is updated
+// the cluster is the whole cluster config after updated, typ represents which part is changed
+type ClusterConfigUpdateCallback func(typ ClusterConfigUpdateType, cluster v2.Cluster) error
+
+var clusterConfigUpdateCBs []ClusterConfigUpdateCallback
+
+// RegisterClusterConfigUpdateListener
+// used to register ClusterConfigUpdateCallback
+func RegisterClusterConfigUpdateListener(cb ClusterConfigUpdateCallback) {
+	clusterConfigUpdateCBs = append(clusterConfigUpdateCBs, cb)
+}
+
+// UpdateClusterHealthCheck
+// called when a cluster's health check config updated, other fields of the cluster are not changed
+func UpdateClusterHealthCheck(clusterName string, hc v2.HealthCheck) error {
+	if err := validateHealthCheck(hc); err != nil {
+		return err
+	}
+	return updateClusterConfig(clusterName, ClusterUpdateHealthCheck, func(cluster *v2.Cluster) {
+		cluster.HealthCheck = hc
+	})
+}
+
+// UpdateClusterCircuitBreakers
+// called when a cluster's circuit breakers config updated, other fields of the cluster are not changed
+func UpdateClusterCircuitBreakers(clusterName string, cb v2.CircuitBreakers) error {
+	if err := validateCircuitBreakers(cb); err != nil {
+		return err
+	}
+	return updateClusterConfig(clusterName, ClusterUpdateCircuitBreakers, func(cluster *v2.Cluster) {
+		cluster.CirBreThresholds = cb
+	})
+}
+
+// updateClusterConfig applies the updated cluster config to the running clusters first,
+// the stored config is updated and dumped only if the update is applied
+func updateClusterConfig(clusterName string, typ ClusterConfigUpdateType, update func(cluster *v2.Cluster)) error {
+	configLock.Lock()
+	idx := clusterIndex(clusterName)
+	if idx == -1 {
+		configLock.Unlock()
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	cluster := config.ClusterManager.Clusters[idx]
+	configLock.Unlock()
+	update(&cluster)
+
+	// the callbacks are called out of the lock, they apply the config to the running clusters
+	if err := applyClusterConfigUpdate(typ, cluster); err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	defer configLock.Unlock()
+	// the cluster may be removed while the update is applied
+	idx = clusterIndex(clusterName)
+	if idx == -1 {
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	update(&config.ClusterManager.Clusters[idx])
+	dump(true)
+
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[configmanager] [update cluster] update cluster %s %s", clusterName, typ)
+	}
+	return nil
+}
+
+func applyClusterConfigUpdate(typ ClusterConfigUpdateType, cluster v2.Cluster) error {
+	var err error
+	for _, cb := range clusterConfigUpdateCBs {
+		if e := cb(typ, cluster); e != nil {
+			log.DefaultLogger.Errorf("[configmanager] [update cluster] apply cluster %s %s failed: %v", cluster.Name, typ, e)
+			err = e
+		}
+	}
+	return err
+}
+
+func validateHealthCheck(hc v2.HealthCheck) error {
+	// no service name means the health check is disabled
+	if hc.ServiceName == "" {
+		return nil
+	}
+	if hc.Interval <= 0 {
+		return fmt.Errorf("invalid health check interval: %s", hc.Interval)
+	}
+	if hc.Timeout < 0 {
+		return fmt.Errorf("invalid health check timeout: %s", hc.Timeout)
+	}
+	if hc.IntervalJitter < 0 {
+		return fmt.Errorf("invalid health check interval jitter: %s", hc.IntervalJitter)
+	}
+	if hc.HealthyThreshold == 0 || hc.UnhealthyThreshold == 0 {
+		return fmt.Errorf("invalid health check threshold, healthy: %d, unhealthy: %d", hc.HealthyThreshold, hc.UnhealthyThreshold)
+	}
+	return nil
+}
+
+func validateCircuitBreakers(cb v2.CircuitBreakers) error {
+	if len(cb.Thresholds) == 0 {
+		return fmt.Errorf("circuit breakers has no thresholds")
+	}
+	for _, threshold := range cb.Thresholds {
+		if threshold.MaxConnections == 0 || threshold.MaxRequests == 0 {
+			return fmt.Errorf("invalid circuit breakers threshold, max connections: %d, max requests: %d", threshold.MaxConnections, threshold.MaxRequests)
+		}
+		if threshold.ConnectBackoffBase != nil && threshold.ConnectBackoffBase.Duration < 0 {
+			return fmt.Errorf("invalid connect backoff base: %s", threshold.ConnectBackoffBase.Duration)
+		}
+		if threshold.ConnectBackoffMax != nil && threshold.ConnectBackoffMax.Duration < 0 {
+			return fmt.Errorf("invalid connect backoff max: %s", threshold.ConnectBackoffMax.Duration)
+		}
+	}
+	return validateThresholds(cb.Thresholds)
+}
+
 // AddPubInfo
 // called when add pub info received
 func AddPubInfo(pubInfoAdded map[string]string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	for srvName, srvData := range pubInfoAdded {
 		exist := false
 		srvPubInfo := v2.PublishInfo{
@@ -147,6 +405,9 @@ func AddPubInfo(pubInfoAdded map[string]string) {
 // DelPubInfo
 // called when delete publish info received
 func DelPubInfo(serviceName string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	dirty := false
 
 	for i, srvPubInfo := range config.ServiceRegistry.ServicePubInfo {
@@ -163,60 +424,148 @@ func DelPubInfo(serviceName string) {
 
 // AddClusterWithRouter is a wrapper of AddOrUpdateCluster and AddOrUpdateRoutersConfig
 // use this function to only dump config once
-func AddClusterWithRouter(listenername string, clusters []v2.Cluster, routerConfig *v2.RouterConfiguration) {
+func AddClusterWithRouter(listenername string, clusters []v2.Cluster, routerConfig *v2.RouterConfiguration) error {
+	if err := validateClusters(clusters); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [add cluster with router] %v", err)
+		return err
+	}
+	if err := ValidateRouterConfiguration(routerConfig); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [add cluster with router] %v", err)
+		return err
+	}
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	addOrUpdateClusterConfig(clusters)
-	addOrUpdateRouterConfig(listenername, routerConfig)
 	dump(true)
+	if err := addOrUpdateRouterConfig(listenername, "", routerConfig); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [add cluster with router] %v", err)
+		return err
+	}
+	return nil
+}
+
+// listenerIndex is the position of a listener in the config
+type listenerIndex struct {
+	server   int
+	listener int
 }
 
-func findListener(listenername string) (v2.Listener, int) {
-	// support only one server
-	listeners := config.Servers[0].Listeners
-	for idx, ln := range listeners {
-		if ln.Name == listenername {
-			return ln, idx
+// findListener searches the listener in all of the servers
+func findListener(listenername string) (v2.Listener, listenerIndex, error) {
+	if len(config.Servers) == 0 {
+		return v2.Listener{}, listenerIndex{}, fmt.Errorf("no server is configured, listener %s is not found", listenername)
+	}
+	for i, srv := range config.Servers {
+		for j, ln := range srv.Listeners {
+			if ln.Name == listenername {
+				return ln, listenerIndex{server: i, listener: j}, nil
+			}
 		}
 	}
-	return v2.Listener{}, -1
+	return v2.Listener{}, listenerIndex{}, fmt.Errorf("listener %s is not found", listenername)
 }
 
-func updateListener(idx int, ln v2.Listener) {
-	listeners := config.Servers[0].Listeners
-	if idx < len(listeners) {
-		listeners[idx] = ln
+func updateListener(idx listenerIndex, ln v2.Listener) {
+	if idx.server < len(config.Servers) {
+		listeners := config.Servers[idx.server].Listeners
+		if idx.listener < len(listeners) {
+			listeners[idx.listener] = ln
+		}
 	}
 }
 
+// findFilterChain returns the index of the filter chain named filterChainName in the listener,
+// an empty filterChainName matches the listener that has only one filter chain
+func findFilterChain(ln v2.Listener, filterChainName string) (int, error) {
+	if filterChainName == "" {
+		switch len(ln.FilterChains) {
+		case 0:
+			return -1, fmt.Errorf("listener %s has no filter chains", ln.Name)
+		case 1:
+			return 0, nil
+		default:
+			return -1, fmt.Errorf("listener %s has %d filter chains, the filter chain name is required", ln.Name, len(ln.FilterChains))
+		}
+	}
+	for i, fc := range ln.FilterChains {
+		if fc.Name == filterChainName {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("filter chain %s is not found in listener %s", filterChainName, ln.Name)
+}
+
 // AddOrUpdateRouterConfig update the connection_manager's config
-func AddOrUpdateRouterConfig(listenername string, routerConfig *v2.RouterConfiguration) {
-	if addOrUpdateRouterConfig(listenername, routerConfig) {
-		dump(true)
+// in the strict route validation mode, the router config referencing clusters not configured is rejected
+func AddOrUpdateRouterConfig(listenername string, routerConfig *v2.RouterConfiguration) error {
+	return AddOrUpdateFilterChainRouterConfig(listenername, "", routerConfig)
+}
+
+// AddOrUpdateFilterChainRouterConfig update the connection_manager's config in the filter chain named filterChainName,
+// the filterChainName can be empty if the listener has only one filter chain
+func AddOrUpdateFilterChainRouterConfig(listenername, filterChainName string, routerConfig *v2.RouterConfiguration) error {
+	if err := ValidateRouterConfiguration(routerConfig); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [update router] %v", err)
+		return err
 	}
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	if err := router.ValidateClusterReferences(routerConfig); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [update router] %v", err)
+		return err
+	}
+	if err := addOrUpdateRouterConfig(listenername, filterChainName, routerConfig); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [update router] %v", err)
+		return err
+	}
+	dump(true)
+	return nil
 }
 
-func addOrUpdateRouterConfig(listenername string, routerConfig *v2.RouterConfiguration) bool {
-	_, idx := findListener(listenername)
-	if idx == -1 {
-		return false
+func addOrUpdateRouterConfig(listenername, filterChainName string, routerConfig *v2.RouterConfiguration) error {
+	ln, _, err := findListener(listenername)
+	if err != nil {
+		return err
+	}
+	if _, err := findFilterChain(ln, filterChainName); err != nil {
+		return err
 	}
 
 	routerMap.Lock()
-	routerMap.config[listenername] = routerConfig
+	routerMap.config[routerKey{listener: listenername, filterChain: filterChainName}] = routerConfig
 	routerMap.Unlock()
-	return true
+	return nil
 }
 
 // AddOrUpdateStreamFilters update the stream filters config
-func AddOrUpdateStreamFilters(listenername string, typ string, cfg map[string]interface{}) {
-	if addOrUpdateStreamFilters(listenername, typ, cfg) {
-		dump(true)
+func AddOrUpdateStreamFilters(listenername string, typ string, cfg map[string]interface{}) error {
+	if typ == "" {
+		err := &ValidationError{
+			Kind:   KindListener,
+			Name:   listenername,
+			Field:  "stream_filters",
+			Reason: "stream filter type is required",
+		}
+		log.DefaultLogger.Errorf("[configmanager] [update stream filters] %v", err)
+		return err
 	}
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	if err := addOrUpdateStreamFilters(listenername, typ, cfg); err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [update stream filters] %v", err)
+		return err
+	}
+	dump(true)
+	return nil
 }
 
-func addOrUpdateStreamFilters(listenername string, typ string, cfg map[string]interface{}) bool {
-	ln, idx := findListener(listenername)
-	if idx == -1 {
-		return false
+func addOrUpdateStreamFilters(listenername string, typ string, cfg map[string]interface{}) error {
+	ln, idx, err := findListener(listenername)
+	if err != nil {
+		return err
 	}
 	filterIndex := -1
 	for i, sf := range ln.StreamFilters {
@@ -235,12 +584,105 @@ func addOrUpdateStreamFilters(listenername string, typ string, cfg map[string]in
 	} else {
 		ln.StreamFilters[filterIndex] = filter
 	}
+	return nil
+}
+
+// RemoveRouterConfig removes the connection_manager from the listener's filter chains
+// returns whether the config is changed
+func RemoveRouterConfig(listenername string) bool {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	dirty := removeRouterUpdates(listenername)
+	ln, _, err := findListener(listenername)
+	if err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [remove router] %v", err)
+	} else {
+		for i := range ln.FilterChains {
+			fc := &ln.FilterChains[i]
+			for j, nf := range fc.Filters {
+				if nf.Type == v2.CONNECTION_MANAGER {
+					fc.Filters = append(fc.Filters[:j], fc.Filters[j+1:]...)
+					dirty = true
+					break
+				}
+			}
+		}
+	}
+	if dirty {
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[configmanager] [remove router] remove router config of listener %s", listenername)
+		}
+		dump(true)
+	}
+	return dirty
+}
+
+// removeRouterUpdates removes the router config of the listener not dumped yet
+func removeRouterUpdates(listenername string) bool {
+	routerMap.Lock()
+	defer routerMap.Unlock()
+	removed := false
+	for key := range routerMap.config {
+		if key.listener == listenername {
+			delete(routerMap.config, key)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// RemoveStreamFilter removes the stream filter of the type from the listener
+// returns whether the config is changed
+func RemoveStreamFilter(listenername string, typ string) bool {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	ln, idx, err := findListener(listenername)
+	if err != nil {
+		log.DefaultLogger.Errorf("[configmanager] [remove stream filter] %v", err)
+		return false
+	}
+	for i, sf := range ln.StreamFilters {
+		if sf.Type == typ {
+			ln.StreamFilters = append(ln.StreamFilters[:i], ln.StreamFilters[i+1:]...)
+			updateListener(idx, ln)
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[configmanager] [remove stream filter] remove stream filter %s of listener %s", typ, listenername)
+			}
+			dump(true)
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveListenerConfig
+// called when a listener is destroyed, returns whether the config is changed
+func RemoveListenerConfig(listenername string) bool {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	removeRouterUpdates(listenername)
+	_, idx, err := findListener(listenername)
+	if err != nil {
+		return false
+	}
+	srv := &config.Servers[idx.server]
+	srv.Listeners = append(srv.Listeners[:idx.listener], srv.Listeners[idx.listener+1:]...)
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[configmanager] [remove listener] remove listener %s", listenername)
+	}
+	dump(true)
 	return true
 }
 
 // AddMsgMeta
 // called when msg meta updated
 func AddMsgMeta(dataId, groupId string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	if config.ServiceRegistry.MsgMetaInfo == nil {
 		config.ServiceRegistry.MsgMetaInfo = make(map[string][]string)
 	}
@@ -269,6 +711,9 @@ func AddMsgMeta(dataId, groupId string) {
 // DelMsgMeta
 // called when delete msg meta received
 func DelMsgMeta(dataId string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	dirty := false
 
 	if _, ok := config.ServiceRegistry.MsgMetaInfo[dataId]; ok {
@@ -281,6 +726,9 @@ func DelMsgMeta(dataId string) {
 
 // UpdateMqClientKey update mq client registry info
 func UpdateMqClientKey(id, clientKey string, remove bool) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	if config.ServiceRegistry.MqClientKey == nil {
 		config.ServiceRegistry.MqClientKey = make(map[string]string)
 	}
@@ -296,6 +744,9 @@ func UpdateMqClientKey(id, clientKey string, remove bool) {
 
 // UpdteMqMeta update mq meta info
 func UpdateMqMeta(topic, meta string, remove bool) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	if config.ServiceRegistry.MqMeta == nil {
 		config.ServiceRegistry.MqMeta = make(map[string]string)
 	}
@@ -311,6 +762,9 @@ func UpdateMqMeta(topic, meta string, remove bool) {
 
 // SetMqConsumers update topic consumer list
 func SetMqConsumers(key string, consumers []string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	if config.ServiceRegistry.MqConsumers == nil {
 		config.ServiceRegistry.MqConsumers = make(map[string][]string)
 	}
@@ -329,6 +783,9 @@ func SetMqConsumers(key string, consumers []string) {
 
 // RmMqConsumers remove topic consumer list
 func RmMqConsumers(key string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
 	if config.ServiceRegistry.MqConsumers== nil {
 		config.ServiceRegistry.MqConsumers = make(map[string][]string)
 		return
@@ -19,10 +19,17 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/router"
 )
 
 func mockInitConfig(t *testing.T, cfg []byte) {
@@ -73,6 +80,240 @@ func TestUpdateClusterConfig(t *testing.T) {
 	}
 }
 
+func TestUpdateClusterHealthCheckAndCircuitBreakers(t *testing.T) {
+	cfg := []byte(basicClusterConfigStr)
+	mockInitConfig(t, cfg)
+	config.ClusterManager.Clusters[0].Hosts = []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:8080"}},
+	}
+	var events []ClusterConfigUpdateType
+	var updated []v2.Cluster
+	clusterConfigUpdateCBs = []ClusterConfigUpdateCallback{
+		func(typ ClusterConfigUpdateType, cluster v2.Cluster) error {
+			events = append(events, typ)
+			updated = append(updated, cluster)
+			return nil
+		},
+	}
+	defer func() {
+		clusterConfigUpdateCBs = nil
+	}()
+	hc := v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			ServiceName:        "test_cluster",
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 2,
+		},
+		Interval: 5 * time.Second,
+	}
+	if err := UpdateClusterHealthCheck("test_cluster", hc); err != nil {
+		t.Fatal("update health check failed", err)
+	}
+	cb := v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{MaxConnections: 10, MaxRequests: 100},
+		},
+	}
+	if err := UpdateClusterCircuitBreakers("test_cluster", cb); err != nil {
+		t.Fatal("update circuit breakers failed", err)
+	}
+	// verify stored config
+	stored := config.ClusterManager.Clusters[0]
+	if stored.HealthCheck.Interval != 5*time.Second || stored.HealthCheck.UnhealthyThreshold != 2 {
+		t.Errorf("health check is not updated: %+v", stored.HealthCheck)
+	}
+	if !reflect.DeepEqual(stored.CirBreThresholds, cb) {
+		t.Errorf("circuit breakers is not updated: %+v", stored.CirBreThresholds)
+	}
+	if len(stored.Hosts) != 1 || stored.Hosts[0].Address != "127.0.0.1:8080" {
+		t.Errorf("hosts should not be changed: %v", stored.Hosts)
+	}
+	// verify events
+	if !reflect.DeepEqual(events, []ClusterConfigUpdateType{ClusterUpdateHealthCheck, ClusterUpdateCircuitBreakers}) {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	if updated[0].Name != "test_cluster" || updated[0].HealthCheck.Interval != 5*time.Second {
+		t.Errorf("unexpected health check event: %+v", updated[0])
+	}
+	if !reflect.DeepEqual(updated[1].CirBreThresholds, cb) {
+		t.Errorf("unexpected circuit breakers event: %+v", updated[1])
+	}
+	if !getDump() {
+		t.Error("config should be dumped")
+	}
+}
+
+func TestUpdateClusterHealthCheckAndCircuitBreakersInvalid(t *testing.T) {
+	cfg := []byte(basicClusterConfigStr)
+	mockInitConfig(t, cfg)
+	called := false
+	clusterConfigUpdateCBs = []ClusterConfigUpdateCallback{
+		func(typ ClusterConfigUpdateType, cluster v2.Cluster) error {
+			called = true
+			return nil
+		},
+	}
+	defer func() {
+		clusterConfigUpdateCBs = nil
+	}()
+	hc := v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			ServiceName:        "test_cluster",
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+	}
+	// zero interval
+	if err := UpdateClusterHealthCheck("test_cluster", hc); err == nil {
+		t.Error("zero interval should be rejected")
+	}
+	// cluster not exists
+	hc.Interval = time.Second
+	if err := UpdateClusterHealthCheck("not_exists", hc); err == nil {
+		t.Error("not exists cluster should be rejected")
+	}
+	// zero thresholds
+	if err := UpdateClusterCircuitBreakers("test_cluster", v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{MaxConnections: 10}},
+	}); err == nil {
+		t.Error("zero max requests should be rejected")
+	}
+	if err := UpdateClusterCircuitBreakers("test_cluster", v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{
+			MaxConnections:     10,
+			MaxRequests:        10,
+			ConnectBackoffBase: &v2.DurationConfig{Duration: -time.Second},
+		}},
+	}); err == nil {
+		t.Error("negative backoff should be rejected")
+	}
+	if err := UpdateClusterCircuitBreakers("test_cluster", v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{MaxConnections: 10, MaxRequests: 10},
+			{MaxConnections: 20, MaxRequests: 20, Priority: v2.DEFAULT_PRIORITY},
+		},
+	}); err == nil {
+		t.Error("duplicate thresholds of the same priority should be rejected")
+	}
+	if called {
+		t.Error("invalid update should not emit event")
+	}
+}
+
+func TestUpdateClusterConfigApplyFailed(t *testing.T) {
+	cfg := []byte(basicClusterConfigStr)
+	mockInitConfig(t, cfg)
+	getDump()
+	clusterConfigUpdateCBs = []ClusterConfigUpdateCallback{
+		func(typ ClusterConfigUpdateType, cluster v2.Cluster) error {
+			return errors.New("apply failed")
+		},
+	}
+	defer func() {
+		clusterConfigUpdateCBs = nil
+	}()
+	old := config.ClusterManager.Clusters[0].CirBreThresholds
+	if err := UpdateClusterCircuitBreakers("test_cluster", v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{MaxConnections: 10, MaxRequests: 100},
+		},
+	}); err == nil {
+		t.Fatal("the failed apply should return the error")
+	}
+	// the stored config still describes the running cluster
+	if !reflect.DeepEqual(config.ClusterManager.Clusters[0].CirBreThresholds, old) {
+		t.Errorf("circuit breakers should not be stored: %+v", config.ClusterManager.Clusters[0].CirBreThresholds)
+	}
+	if getDump() {
+		t.Error("config should not be dumped")
+	}
+}
+
+func TestUpdateClusterHosts(t *testing.T) {
+	config = MOSNConfig{}
+	config.ClusterManager.Clusters = []v2.Cluster{{Name: "test_hosts"}}
+	defer func() {
+		config = MOSNConfig{}
+	}()
+	newHost := func(addr string, weight uint32) v2.Host {
+		return v2.Host{
+			HostConfig: v2.HostConfig{
+				Address: addr,
+				Weight:  weight,
+			},
+			MetaData: v2.Metadata{"zone": "gz"},
+		}
+	}
+	if err := AddOrUpdateClusterHosts("not_exists", []v2.Host{newHost("127.0.0.1:8080", 1)}); err == nil {
+		t.Fatal("expected add hosts to a cluster not exists failed")
+	}
+	if err := AddOrUpdateClusterHosts("test_hosts", []v2.Host{
+		newHost("127.0.0.1:8080", 1),
+		newHost("127.0.0.1:8081", 1),
+		newHost("127.0.0.1:8080", 2), // duplicated
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddOrUpdateClusterHosts("test_hosts", []v2.Host{
+		newHost("127.0.0.1:8081", 5),
+		newHost("127.0.0.1:8082", 1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []v2.Host{
+		newHost("127.0.0.1:8080", 2),
+		newHost("127.0.0.1:8081", 5),
+		newHost("127.0.0.1:8082", 1),
+	}
+	if hosts := config.ClusterManager.Clusters[0].Hosts; !reflect.DeepEqual(hosts, expected) {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+	if err := RemoveClusterHosts("not_exists", []string{"127.0.0.1:8080"}); err == nil {
+		t.Fatal("expected remove hosts from a cluster not exists failed")
+	}
+	if err := RemoveClusterHosts("test_hosts", []string{"127.0.0.1:8081", "127.0.0.1:9090"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []v2.Host{
+		newHost("127.0.0.1:8080", 2),
+		newHost("127.0.0.1:8082", 1),
+	}
+	if hosts := config.ClusterManager.Clusters[0].Hosts; !reflect.DeepEqual(hosts, expected) {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestUpdateConfigInvalid(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	config.ClusterManager.Clusters = []v2.Cluster{{Name: "test1"}}
+	defer func() {
+		config = MOSNConfig{}
+	}()
+	if err := AddOrUpdateClusterConfig([]v2.Cluster{{Name: "valid"}, {LbType: v2.LB_RANDOM}}); err == nil {
+		t.Error("expected the cluster without name is rejected")
+	}
+	if len(config.ClusterManager.Clusters) != 1 {
+		t.Error("expected the clusters not changed if any of them is invalid")
+	}
+	hosts := []v2.Host{{HostConfig: v2.HostConfig{Address: "invalid"}}}
+	if err := AddOrUpdateClusterHosts("test1", hosts); err == nil {
+		t.Error("expected the invalid host is rejected")
+	}
+	if err := AddOrUpdateRouterConfig("egress", &v2.RouterConfiguration{}); err == nil {
+		t.Error("expected the router config without name is rejected")
+	}
+	if err := AddClusterWithRouter("egress", []v2.Cluster{{Name: "with_router"}}, nil); err == nil {
+		t.Error("expected the empty router config is rejected")
+	}
+	if err := AddOrUpdateStreamFilters("egress", "", nil); err == nil {
+		t.Error("expected the stream filter without type is rejected")
+	}
+	if len(config.ClusterManager.Clusters) != 1 || len(config.ClusterManager.Clusters[0].Hosts) != 0 {
+		t.Errorf("expected the clusters not changed, but got %v", config.ClusterManager.Clusters)
+	}
+}
+
 func TestUpdateRouterConfig(t *testing.T) {
 	// only keep useful test part
 	cfg := []byte(basicConfigStr)
@@ -94,13 +335,13 @@ func TestUpdateRouterConfig(t *testing.T) {
 	if err := json.Unmarshal([]byte(routerConfigStr), routerConfiguration); err != nil {
 		t.Fatal("create update config failed", err)
 	}
-	if !addOrUpdateRouterConfig("egress", routerConfiguration) {
-		t.Fatal("update router config failed")
+	if err := addOrUpdateRouterConfig("egress", "", routerConfiguration); err != nil {
+		t.Fatal("update router config failed", err)
 	}
 	dumpRouterConfig()
 	// verify
-	ln, idx := findListener("egress")
-	if idx == -1 {
+	ln, _, err := findListener("egress")
+	if err != nil {
 		t.Fatal("cannot found egress listener")
 	}
 	filter := ln.FilterChains[0].Filters[0] // only one connection_manager
@@ -115,6 +356,56 @@ func TestUpdateRouterConfig(t *testing.T) {
 	}
 }
 
+func TestUpdateRouterConfigStrict(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	router.SetRouteValidationMode(v2.ROUTE_VALIDATION_STRICT)
+	router.SetClusterChecker(func(clusterName string) bool {
+		return clusterName == "test1"
+	})
+	defer func() {
+		router.SetRouteValidationMode(v2.ROUTE_VALIDATION_WARN)
+		router.SetClusterChecker(nil)
+	}()
+	newRouter := func(clusterName string) *v2.RouterConfiguration {
+		return &v2.RouterConfiguration{
+			RouterConfigurationConfig: v2.RouterConfigurationConfig{
+				RouterConfigName: "egress_router",
+			},
+			VirtualHosts: []*v2.VirtualHost{
+				{
+					Name:    "egress",
+					Domains: []string{"*"},
+					Routers: []v2.Router{
+						{
+							RouterConfig: v2.RouterConfig{
+								Match: v2.RouterMatch{Prefix: "/"},
+								Route: v2.RouteAction{
+									RouterActionConfig: v2.RouterActionConfig{
+										ClusterName: clusterName,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	if err := AddOrUpdateRouterConfig("egress", newRouter("test_missing")); err == nil {
+		t.Fatal("expected the router config with dangling reference is rejected")
+	}
+	routerMap.Lock()
+	_, ok := routerMap.config[routerKey{listener: "egress"}]
+	routerMap.Unlock()
+	if ok {
+		t.Fatal("expected the rejected router config is not stored")
+	}
+	if err := AddOrUpdateRouterConfig("egress", newRouter("test1")); err != nil {
+		t.Fatalf("update router config failed: %v", err)
+	}
+}
+
 func TestUpdateStreamFilter(t *testing.T) {
 	// only keep useful test part
 	cfg := []byte(basicConfigStr)
@@ -126,16 +417,16 @@ func TestUpdateStreamFilter(t *testing.T) {
 	if err := json.Unmarshal([]byte(streamFilterStr), &streamFilterConfig); err != nil {
 		t.Fatal("create filter config failed", err)
 	}
-	if !addOrUpdateStreamFilters("egress", "test", streamFilterConfig) {
-		t.Fatal("update stream filter config failed")
+	if err := addOrUpdateStreamFilters("egress", "test", streamFilterConfig); err != nil {
+		t.Fatal("update stream filter config failed", err)
 	}
-	if !addOrUpdateStreamFilters("ingress", "test", streamFilterConfig) {
-		t.Fatal("add stream filter config failed")
+	if err := addOrUpdateStreamFilters("ingress", "test", streamFilterConfig); err != nil {
+		t.Fatal("add stream filter config failed", err)
 	}
 	// verify
 	for _, name := range []string{"egress", "ingress"} {
-		ln, idx := findListener(name)
-		if idx == -1 {
+		ln, _, err := findListener(name)
+		if err != nil {
 			t.Fatalf("%s cannot found egress listener", name)
 		}
 		filter := ln.StreamFilters[0] // only one stream filter
@@ -151,6 +442,157 @@ func TestUpdateStreamFilter(t *testing.T) {
 	}
 }
 
+func TestUpdateListenerNoServers(t *testing.T) {
+	config = MOSNConfig{}
+	routerConfig := &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "egress_router",
+		},
+	}
+	if err := AddOrUpdateRouterConfig("egress", routerConfig); err == nil {
+		t.Error("expected update router failed without servers")
+	}
+	if err := AddOrUpdateStreamFilters("egress", "test", map[string]interface{}{}); err == nil {
+		t.Error("expected update stream filters failed without servers")
+	}
+	if err := AddClusterWithRouter("egress", []v2.Cluster{{Name: "test1"}}, routerConfig); err == nil {
+		t.Error("expected add cluster with router failed without servers")
+	}
+	if len(config.ClusterManager.Clusters) != 1 {
+		t.Error("expected the cluster is added")
+	}
+}
+
+func TestUpdateListenerMultipleServers(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	config.Servers = append(config.Servers, v2.ServerConfig{
+		Listeners: []v2.Listener{
+			{
+				ListenerConfig: v2.ListenerConfig{
+					Name: "multiple",
+					FilterChains: []v2.FilterChain{
+						{FilterChainConfig: v2.FilterChainConfig{Name: "chain_a"}},
+						{FilterChainConfig: v2.FilterChainConfig{Name: "chain_b"}},
+					},
+				},
+			},
+		},
+	})
+	defer func() {
+		config = MOSNConfig{}
+	}()
+	routerConfig := &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "multiple_router",
+		},
+	}
+	// the filter chain name is required for the listener has multiple filter chains
+	if err := AddOrUpdateRouterConfig("multiple", routerConfig); err == nil {
+		t.Fatal("expected update router failed without filter chain name")
+	}
+	if err := AddOrUpdateFilterChainRouterConfig("multiple", "chain_c", routerConfig); err == nil {
+		t.Fatal("expected update router failed with unknown filter chain")
+	}
+	if err := AddOrUpdateFilterChainRouterConfig("multiple", "chain_b", routerConfig); err != nil {
+		t.Fatalf("update router failed: %v", err)
+	}
+	if err := AddOrUpdateStreamFilters("multiple", "test", map[string]interface{}{"version": "2.0"}); err != nil {
+		t.Fatalf("update stream filters failed: %v", err)
+	}
+	dumpRouterConfig()
+	// verify
+	ln := config.Servers[1].Listeners[0]
+	if len(ln.FilterChains[0].Filters) != 0 {
+		t.Error("the filter chain not matched is changed")
+	}
+	filters := ln.FilterChains[1].Filters
+	if len(filters) != 1 || filters[0].Type != v2.CONNECTION_MANAGER || filters[0].Config["router_config_name"] != "multiple_router" {
+		t.Errorf("the router config is not updated: %v", filters)
+	}
+	if len(ln.StreamFilters) != 1 || ln.StreamFilters[0].Config["version"] != "2.0" {
+		t.Errorf("the stream filters is not updated: %v", ln.StreamFilters)
+	}
+	// the listeners in the first server are not changed
+	if len(config.Servers[0].Listeners[1].StreamFilters) != 0 {
+		t.Error("the listener not matched is changed")
+	}
+}
+
+func TestRemoveRouterAndStreamFilter(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	defer func() {
+		config = MOSNConfig{}
+	}()
+	// a router config not dumped yet
+	if err := addOrUpdateRouterConfig("egress", "", &v2.RouterConfiguration{}); err != nil {
+		t.Fatal(err)
+	}
+	if !RemoveRouterConfig("egress") {
+		t.Fatal("remove router config failed")
+	}
+	if RemoveRouterConfig("egress") {
+		t.Fatal("remove router config again should be not changed")
+	}
+	routerMap.Lock()
+	_, ok := routerMap.config[routerKey{listener: "egress"}]
+	routerMap.Unlock()
+	if ok {
+		t.Fatal("the router config not dumped should be removed")
+	}
+	if filters := config.Servers[0].Listeners[0].FilterChains[0].Filters; len(filters) != 0 {
+		t.Fatalf("the connection_manager should be removed, but got %v", filters)
+	}
+	if RemoveRouterConfig("not_exists") {
+		t.Fatal("remove router config of a listener not exists should be not changed")
+	}
+	// stream filter
+	if RemoveStreamFilter("egress", "not_exists") {
+		t.Fatal("remove stream filter not exists should be not changed")
+	}
+	if !RemoveStreamFilter("egress", "test") {
+		t.Fatal("remove stream filter failed")
+	}
+	if sfs := config.Servers[0].Listeners[0].StreamFilters; len(sfs) != 0 {
+		t.Fatalf("the stream filter should be removed, but got %v", sfs)
+	}
+	// the ingress is not changed
+	if len(config.Servers[0].Listeners[1].FilterChains[0].Filters) != 1 {
+		t.Fatal("the listener not matched is changed")
+	}
+}
+
+func TestRemoveListenerConfig(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	defer func() {
+		config = MOSNConfig{}
+	}()
+	if err := addOrUpdateRouterConfig("egress", "", &v2.RouterConfiguration{}); err != nil {
+		t.Fatal(err)
+	}
+	if !RemoveListenerConfig("egress") {
+		t.Fatal("remove listener failed")
+	}
+	if RemoveListenerConfig("egress") {
+		t.Fatal("remove listener again should be not changed")
+	}
+	if _, _, err := findListener("egress"); err == nil {
+		t.Fatal("the listener should be removed")
+	}
+	routerMap.Lock()
+	n := len(routerMap.config)
+	routerMap.Unlock()
+	if n != 0 {
+		t.Fatal("the router config of the removed listener should be removed")
+	}
+	listeners := config.Servers[0].Listeners
+	if len(listeners) != 1 || listeners[0].Name != "ingress" {
+		t.Fatalf("unexpected listeners: %v", listeners)
+	}
+}
+
 func TestUpdateMqClientKey(t *testing.T) {
 	UpdateMqClientKey("hello", "ck", false)
 	if len(config.ServiceRegistry.MqClientKey) != 1 {
@@ -194,3 +636,61 @@ func TestRmMqConsumers(t *testing.T) {
 		t.Errorf("len(config.ServiceRegistry.MqConsumers) != 0")
 	}
 }
+
+func TestConcurrentUpdateAndDump(t *testing.T) {
+	cfg := []byte(basicClusterConfigStr)
+	mockInitConfig(t, cfg)
+	f, err := ioutil.TempFile("", "mosn_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	oldPath := configPath
+	configPath = f.Name()
+	defer func() {
+		configPath = oldPath
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("concurrent_%d", i)
+			for j := 0; j < 100; j++ {
+				AddOrUpdateClusterConfig([]v2.Cluster{{Name: name, LbType: v2.LB_RANDOM}})
+				RemoveClusterConfig([]string{name})
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for dumping := true; dumping; {
+		select {
+		case <-done:
+			dumping = false
+		default:
+		}
+		setDump()
+		DumpLock()
+		DumpConfig()
+		DumpUnlock()
+	}
+
+	// the dumped config is a valid one
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumped := MOSNConfig{}
+	if err := json.Unmarshal(content, &dumped); err != nil {
+		t.Fatalf("invalid dumped config: %v", err)
+	}
+	if len(dumped.ClusterManager.Clusters) != 1 {
+		t.Fatalf("expected only the basic cluster dumped, but got %d", len(dumped.ClusterManager.Clusters))
+	}
+}
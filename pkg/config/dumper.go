@@ -26,14 +26,21 @@ import (
 	"sofastack.io/sofa-mosn/pkg/admin/store"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"sofastack.io/sofa-mosn/pkg/utils"
 )
 
+// DefaultDumpInterval is used if the dump interval is not configured
+const DefaultDumpInterval = 3 * time.Second
+
 var (
 	once    sync.Once
 	lock    sync.Mutex
 	dumping int32
+	// dumpNotify wakes up the dump handler, the changes made before the handler
+	// wakes up are coalesced into one dump
+	dumpNotify = make(chan struct{}, 1)
 )
 
 func DumpLock() {
@@ -52,26 +59,36 @@ func getDump() bool {
 	return atomic.CompareAndSwapInt32(&dumping, 1, 0)
 }
 
+// routerKey is the filter chain that the router config is updated in
+type routerKey struct {
+	listener    string
+	filterChain string
+}
+
 type routerConfigMap struct {
-	config map[string]*v2.RouterConfiguration
+	config map[routerKey]*v2.RouterConfiguration
 	sync.Mutex
 }
 
 var routerMap = &routerConfigMap{
-	config: make(map[string]*v2.RouterConfiguration),
+	config: make(map[routerKey]*v2.RouterConfiguration),
 }
 
 func dumpRouterConfig() bool {
 	routerMap.Lock()
 	defer routerMap.Unlock()
-	for listenername, routerConfig := range routerMap.config {
-		ln, idx := findListener(listenername)
-		if idx == -1 {
+	for key, routerConfig := range routerMap.config {
+		ln, idx, err := findListener(key.listener)
+		if err != nil {
+			continue
+		}
+		chainIndex, err := findFilterChain(ln, key.filterChain)
+		if err != nil {
+			log.DefaultLogger.Errorf("[config] [dump] update router config failed: %v", err)
 			continue
 		}
-		delete(routerMap.config, listenername)
-		// support only one filter chain
-		nfs := ln.FilterChains[0].Filters
+		delete(routerMap.config, key)
+		nfs := ln.FilterChains[chainIndex].Filters
 		filterIndex := -1
 		for i, nf := range nfs {
 			if nf.Type == v2.CONNECTION_MANAGER {
@@ -92,7 +109,7 @@ func dumpRouterConfig() bool {
 			}
 			if filterIndex == -1 {
 				nfs = append(nfs, filter)
-				ln.FilterChains[0].Filters = nfs
+				ln.FilterChains[chainIndex].Filters = nfs
 				updateListener(idx, ln)
 			} else {
 				nfs[filterIndex] = filter
@@ -105,40 +122,103 @@ func dumpRouterConfig() bool {
 func dump(dirty bool) {
 	if dirty {
 		setDump()
+		select {
+		case dumpNotify <- struct{}{}:
+		default:
+		}
 	}
 }
 
+// DumpConfig writes the config into the config file if it is changed
 func DumpConfig() {
 	if getDump() {
-		//update router config
-		dumpRouterConfig()
+		dumpConfig()
+	}
+}
 
-		log.DefaultLogger.Debugf("[config] [dump] dump config content: %+v", config)
+// ForceDump writes the config into the config file immediately, without waiting for the dump interval.
+// It is used in the shutdown paths, makes sure the changes are not lost.
+func ForceDump() error {
+	if configPath == "" {
+		return nil
+	}
+	DumpLock()
+	defer DumpUnlock()
+	getDump()
+	return dumpConfig()
+}
 
-		//update mosn_config
-		store.SetMOSNConfig(config)
-		// use golang original json lib, so the marshal ident can handle MarshalJSON interface implement correctly
-		content, err := json.MarshalIndent(config, "", "  ")
-		if err == nil {
+func dumpConfig() error {
+	stats := metrics.NewConfigStats()
+	stats.Counter(metrics.ConfigDumpAttempt).Inc(1)
+	content, full, err := dumpContent()
+	if err == nil {
+		log.DefaultLogger.Debugf("[config] [dump] dump config content: %s", content)
+
+		//update mosn_config, the stored one is decoded from the content,
+		// so it never shares the slices and maps being changed
+		snapshot := MOSNConfig{}
+		if e := json.Unmarshal(full, &snapshot); e == nil {
+			store.SetMOSNConfig(snapshot)
+		} else {
+			log.DefaultLogger.Errorf("[config] [dump] decode the dumped config failed: %v", e)
+		}
+		// no config file is loaded, e.g. the config is set by the tests
+		if configPath != "" {
 			err = utils.WriteFileSafety(configPath, content, 0644)
 		}
+	}
 
-		if err != nil {
-			log.DefaultLogger.Alertf(types.ErrorKeyConfigDump, "dump config failed, caused by: "+err.Error())
-		}
+	if err != nil {
+		stats.Counter(metrics.ConfigDumpFailure).Inc(1)
+		log.DefaultLogger.Alertf(types.ErrorKeyConfigDump, "dump config failed, caused by: "+err.Error())
+	}
+	return err
+}
+
+// dumpContent updates the router config and marshals the config under the lock.
+// content is written into the config file, which is not contains the config from the include files,
+// and keeps the placeholders of the environment variables. full is the whole config.
+func dumpContent() (content []byte, full []byte, err error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	//update router config
+	dumpRouterConfig()
+	// use golang original json lib, so the marshal ident can handle MarshalJSON interface implement correctly
+	full, err = json.MarshalIndent(withoutIncluded(config), "", "  ")
+	if err != nil {
+		return nil, nil, err
 	}
+	content = restoreEnv(full, envTemplates)
+	if len(config.Includes) == 0 {
+		return content, full, nil
+	}
+	full, err = json.Marshal(config)
+	return content, full, err
+}
+
+func dumpInterval() time.Duration {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	if config.DumpInterval != nil && config.DumpInterval.Duration > 0 {
+		return config.DumpInterval.Duration
+	}
+	return DefaultDumpInterval
 }
 
 // DumpConfigHandler should be called in a goroutine
 // we call it in mosn/starter with GoWithRecover, which can handle the panic information
+// the config is written at most once in a dump interval, no matter how many changes are made
 func DumpConfigHandler() {
 	once.Do(func() {
-		for {
-			time.Sleep(3 * time.Second)
-
+		interval := dumpInterval()
+		for range dumpNotify {
 			DumpLock()
 			DumpConfig()
 			DumpUnlock()
+
+			time.Sleep(interval)
 		}
 	})
 }
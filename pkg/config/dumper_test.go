@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+)
+
+func TestDumpConfigHandlerCoalesce(t *testing.T) {
+	cfg := []byte(basicClusterConfigStr)
+	mockInitConfig(t, cfg)
+	config.DumpInterval = &v2.DurationConfig{Duration: 200 * time.Millisecond}
+	f, err := ioutil.TempFile("", "mosn_config_dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	oldPath := configPath
+	configPath = f.Name()
+	defer func() {
+		configPath = oldPath
+	}()
+
+	stats := metrics.NewConfigStats()
+	attempts := stats.Counter(metrics.ConfigDumpAttempt).Count()
+	go DumpConfigHandler()
+	for i := 0; i < 1000; i++ {
+		AddOrUpdateClusterConfig([]v2.Cluster{{Name: fmt.Sprintf("cluster_%d", i), LbType: v2.LB_RANDOM}})
+	}
+	time.Sleep(time.Second)
+	// the changes are coalesced, the dump runs at most once in a interval
+	if n := stats.Counter(metrics.ConfigDumpAttempt).Count() - attempts; n == 0 || n > 6 {
+		t.Fatalf("unexpected dump attempts: %d", n)
+	}
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumped := MOSNConfig{}
+	if err := json.Unmarshal(content, &dumped); err != nil {
+		t.Fatalf("invalid dumped config: %v", err)
+	}
+	if len(dumped.ClusterManager.Clusters) != 1001 {
+		t.Fatalf("expected all the clusters dumped, but got %d", len(dumped.ClusterManager.Clusters))
+	}
+	if _, err := os.Stat(f.Name() + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("the temp file is not renamed")
+	}
+}
+
+func TestForceDump(t *testing.T) {
+	cfg := []byte(basicClusterConfigStr)
+	mockInitConfig(t, cfg)
+	f, err := ioutil.TempFile("", "mosn_config_force_dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	oldPath := configPath
+	configPath = f.Name()
+	defer func() {
+		configPath = oldPath
+	}()
+
+	// no changes, the config is still written
+	if err := ForceDump(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumped := MOSNConfig{}
+	if err := json.Unmarshal(content, &dumped); err != nil {
+		t.Fatalf("invalid dumped config: %v", err)
+	}
+	if len(dumped.ClusterManager.Clusters) != 1 {
+		t.Fatalf("expected the basic cluster dumped, but got %d", len(dumped.ClusterManager.Clusters))
+	}
+	// dump failed
+	configPath = "/not/exists/path/mosn_config.json"
+	stats := metrics.NewConfigStats()
+	failures := stats.Counter(metrics.ConfigDumpFailure).Count()
+	if err := ForceDump(); err == nil {
+		t.Fatal("expected dump failed")
+	}
+	if stats.Counter(metrics.ConfigDumpFailure).Count() != failures+1 {
+		t.Fatal("dump failure is not counted")
+	}
+}
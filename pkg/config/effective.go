@@ -0,0 +1,247 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+// Group of the resources in the effective config dump
+const (
+	ResourceClusters  = "clusters"
+	ResourceListeners = "listeners"
+	ResourceRouters   = "routers"
+)
+
+// redactedKeys are the fields that are not shown in the config dump
+var redactedKeys = map[string]struct{}{
+	"private_key": {},
+}
+
+const redactedValue = "[redacted]"
+
+// DumpEffectiveConfig returns the running config in json, the resource selects a part of the config,
+// an empty resource means the whole config.
+// the TLS private keys are redacted
+func DumpEffectiveConfig(resource string) ([]byte, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	// the router configs not dumped yet are applied, so the routers are the running ones
+	dumpRouterConfig()
+	var v interface{}
+	switch resource {
+	case "":
+		v = config
+	case ResourceClusters:
+		v = config.ClusterManager.Clusters
+	case ResourceListeners:
+		v = allListeners(config)
+	case ResourceRouters:
+		routers, err := allRouters(config)
+		if err != nil {
+			return nil, err
+		}
+		v = routers
+	default:
+		return nil, fmt.Errorf("unknown resource: %s", resource)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Redact(data)
+}
+
+func allListeners(cfg MOSNConfig) []v2.Listener {
+	var listeners []v2.Listener
+	for _, srv := range cfg.Servers {
+		listeners = append(listeners, srv.Listeners...)
+	}
+	return listeners
+}
+
+func allRouters(cfg MOSNConfig) ([]*v2.RouterConfiguration, error) {
+	var routers []*v2.RouterConfiguration
+	for _, ln := range allListeners(cfg) {
+		for _, fc := range ln.FilterChains {
+			for _, f := range fc.Filters {
+				if f.Type != v2.CONNECTION_MANAGER {
+					continue
+				}
+				data, err := json.Marshal(f.Config)
+				if err != nil {
+					return nil, err
+				}
+				router := &v2.RouterConfiguration{}
+				if err := json.Unmarshal(data, router); err != nil {
+					return nil, fmt.Errorf("invalid router config in listener %s: %v", ln.Name, err)
+				}
+				routers = append(routers, router)
+			}
+		}
+	}
+	return routers, nil
+}
+
+// Redact replaces the values of the redacted keys in the json, such as the TLS private keys
+func Redact(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(v))
+}
+
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range value {
+			if _, ok := redactedKeys[k]; ok {
+				if s, ok := sub.(string); ok && s != "" {
+					value[k] = redactedValue
+				}
+				continue
+			}
+			value[k] = redactValue(sub)
+		}
+	case []interface{}:
+		for i, sub := range value {
+			value[i] = redactValue(sub)
+		}
+	}
+	return v
+}
+
+// ResourceDiff is the names of the resources changed in the running config, compared with the config file
+type ResourceDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// ConfigDiff is the differences between the running config and the config file
+type ConfigDiff struct {
+	Clusters  ResourceDiff `json:"clusters"`
+	Listeners ResourceDiff `json:"listeners"`
+	Routers   ResourceDiff `json:"routers"`
+}
+
+// DiffConfigFile compares the running config with the config file.
+// the added resources are in the running config but not in the file, and the removed ones are only in the file.
+// the config from the include files is not compared, it is never written into the config file
+func DiffConfigFile() (*ConfigDiff, error) {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	file := MOSNConfig{}
+	if err := json.Unmarshal(interpolateEnv(content), &file); err != nil {
+		return nil, err
+	}
+
+	configLock.Lock()
+	defer configLock.Unlock()
+	dumpRouterConfig()
+	running := withoutIncluded(config)
+
+	diff := &ConfigDiff{}
+	if diff.Clusters, err = diffResources(clusterResources(file), clusterResources(running)); err != nil {
+		return nil, err
+	}
+	if diff.Listeners, err = diffResources(listenerResources(file), listenerResources(running)); err != nil {
+		return nil, err
+	}
+	fileRouters, err := routerResources(file)
+	if err != nil {
+		return nil, err
+	}
+	runningRouters, err := routerResources(running)
+	if err != nil {
+		return nil, err
+	}
+	if diff.Routers, err = diffResources(fileRouters, runningRouters); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func clusterResources(cfg MOSNConfig) map[string]interface{} {
+	resources := make(map[string]interface{}, len(cfg.ClusterManager.Clusters))
+	for _, c := range cfg.ClusterManager.Clusters {
+		resources[c.Name] = c
+	}
+	return resources
+}
+
+func listenerResources(cfg MOSNConfig) map[string]interface{} {
+	resources := make(map[string]interface{})
+	for _, ln := range allListeners(cfg) {
+		resources[ln.Name] = ln
+	}
+	return resources
+}
+
+func routerResources(cfg MOSNConfig) (map[string]interface{}, error) {
+	routers, err := allRouters(cfg)
+	if err != nil {
+		return nil, err
+	}
+	resources := make(map[string]interface{}, len(routers))
+	for _, r := range routers {
+		resources[r.RouterConfigName] = r
+	}
+	return resources, nil
+}
+
+// diffResources compares the resources by the json, the names in the diff are sorted as the resources' order is not kept
+func diffResources(file, running map[string]interface{}) (ResourceDiff, error) {
+	diff := ResourceDiff{}
+	for name, r := range running {
+		f, ok := file[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		rdata, err := json.Marshal(r)
+		if err != nil {
+			return diff, err
+		}
+		fdata, err := json.Marshal(f)
+		if err != nil {
+			return diff, err
+		}
+		if string(rdata) != string(fdata) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range file {
+		if _, ok := running[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
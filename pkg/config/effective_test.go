@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func TestDumpEffectiveConfig(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	config.ClusterManager.Clusters = []v2.Cluster{{Name: "test1"}}
+	config.Servers[0].Listeners[0].FilterChains[0].TLSContexts = []v2.TLSConfig{
+		{
+			Status:     true,
+			CertChain:  "cert",
+			PrivateKey: "secret key",
+		},
+	}
+	defer func() {
+		config = MOSNConfig{}
+	}()
+
+	data, err := DumpEffectiveConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret key") || !strings.Contains(string(data), redactedValue) {
+		t.Errorf("the private key is not redacted: %s", string(data))
+	}
+	// the running config is not redacted
+	if config.Servers[0].Listeners[0].FilterChains[0].TLSContexts[0].PrivateKey != "secret key" {
+		t.Error("the running config is changed")
+	}
+
+	data, err = DumpEffectiveConfig(ResourceClusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clusters []v2.Cluster
+	if err := json.Unmarshal(data, &clusters); err != nil || len(clusters) != 1 || clusters[0].Name != "test1" {
+		t.Errorf("unexpected clusters dump: %s", string(data))
+	}
+
+	data, err = DumpEffectiveConfig(ResourceListeners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listeners []v2.Listener
+	if err := json.Unmarshal(data, &listeners); err != nil || len(listeners) != 2 {
+		t.Errorf("unexpected listeners dump: %s", string(data))
+	}
+
+	data, err = DumpEffectiveConfig(ResourceRouters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routers []*v2.RouterConfiguration
+	if err := json.Unmarshal(data, &routers); err != nil || len(routers) != 2 ||
+		routers[0].RouterConfigName != "egress_router" || routers[1].RouterConfigName != "ingress_router" {
+		t.Errorf("unexpected routers dump: %s", string(data))
+	}
+
+	if _, err := DumpEffectiveConfig("unknown"); err == nil {
+		t.Error("expected the unknown resource is rejected")
+	}
+}
+
+func TestDiffConfigFile(t *testing.T) {
+	cfg := []byte(basicConfigStr)
+	mockInitConfig(t, cfg)
+	config.ClusterManager.Clusters = []v2.Cluster{{Name: "test1"}, {Name: "test2"}}
+	f, err := ioutil.TempFile("", "mosn_config_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	oldPath := configPath
+	configPath = f.Name()
+	defer func() {
+		configPath = oldPath
+		config = MOSNConfig{}
+	}()
+	if err := ForceDump(); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := DiffConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff, &ConfigDiff{}) {
+		t.Fatalf("expected no differences, but got %+v", diff)
+	}
+
+	// changes the running config
+	config.ClusterManager.Clusters = []v2.Cluster{
+		{Name: "test1", LbType: v2.LB_RANDOM},
+		{Name: "test3"},
+	}
+	if !RemoveListenerConfig("ingress") {
+		t.Fatal("remove listener failed")
+	}
+	if !RemoveRouterConfig("egress") {
+		t.Fatal("remove router failed")
+	}
+	diff, err = DiffConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ConfigDiff{
+		Clusters: ResourceDiff{
+			Added:   []string{"test3"},
+			Removed: []string{"test2"},
+			Changed: []string{"test1"},
+		},
+		Listeners: ResourceDiff{
+			Removed: []string{"ingress"},
+			Changed: []string{"egress"},
+		},
+		Routers: ResourceDiff{
+			Removed: []string{"egress_router", "ingress_router"},
+		},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, diff)
+	}
+}
@@ -0,0 +1,304 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+// envPattern matches ${ENV_VAR} and ${ENV_VAR:default}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:[^}]*)?\}`)
+
+// interpolateEnv replaces the ${ENV_VAR:default} in the json content with the environment variable,
+// the default value is used if the environment variable is not set.
+// the content is a json, so ${} can only be in the strings, and the value is escaped as a json string
+func interpolateEnv(content []byte) []byte {
+	return envPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		sub := envPattern.FindSubmatch(match)
+		value, ok := os.LookupEnv(string(sub[1]))
+		if !ok && len(sub[2]) > 0 {
+			value = string(sub[2][1:])
+		}
+		escaped, _ := json.Marshal(value)
+		// trim the quotes
+		return escaped[1 : len(escaped)-1]
+	})
+}
+
+// envTemplate is a json string with ${ENV_VAR:default} in the config file
+type envTemplate struct {
+	resolved string
+	raw      string
+}
+
+// envTemplates records the strings with ${ENV_VAR:default} in the config file by their json paths,
+// the strings at the paths are written back as the raw ones, so the values of the environment variables are not persisted
+var envTemplates map[string]envTemplate
+
+// pathKeyEscaper escapes an object key as a json pointer token
+var pathKeyEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// collectEnvTemplates returns the json strings with ${ENV_VAR:default} in the raw content by their json paths,
+// the empty values are skipped
+func collectEnvTemplates(content []byte) map[string]envTemplate {
+	var raw interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil
+	}
+	templates := make(map[string]envTemplate)
+	var walk func(v interface{}, path string)
+	walk = func(v interface{}, path string) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				walk(e, path+"/"+pathKeyEscaper.Replace(k))
+			}
+		case []interface{}:
+			for i, e := range v {
+				walk(e, path+"/"+strconv.Itoa(i))
+			}
+		case string:
+			if !envPattern.MatchString(v) {
+				return
+			}
+			quoted, _ := json.Marshal(v)
+			var resolved string
+			if err := json.Unmarshal(interpolateEnv(quoted), &resolved); err == nil && resolved != "" {
+				templates[path] = envTemplate{resolved: resolved, raw: v}
+			}
+		}
+	}
+	walk(raw, "")
+	return templates
+}
+
+// envPathFrame is an object or array being scanned by restoreEnv
+type envPathFrame struct {
+	array bool
+	index int
+	key   string
+}
+
+// restoreEnv replaces the json string values at the paths of the templates with the raw strings,
+// a value is kept if it is not the resolved one, e.g. it is changed after the config is loaded
+func restoreEnv(content []byte, templates map[string]envTemplate) []byte {
+	if len(templates) == 0 {
+		return content
+	}
+	var stack []envPathFrame
+	path := func() string {
+		var b strings.Builder
+		for _, f := range stack {
+			b.WriteByte('/')
+			if f.array {
+				b.WriteString(strconv.Itoa(f.index))
+			} else {
+				b.WriteString(f.key)
+			}
+		}
+		return b.String()
+	}
+	out := make([]byte, 0, len(content))
+	for i := 0; i < len(content); {
+		switch content[i] {
+		case '{':
+			stack = append(stack, envPathFrame{})
+		case '[':
+			stack = append(stack, envPathFrame{array: true})
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if len(stack) > 0 && stack[len(stack)-1].array {
+				stack[len(stack)-1].index++
+			}
+		}
+		if content[i] != '"' {
+			out = append(out, content[i])
+			i++
+			continue
+		}
+		// the end of the json string, the escaped quotes are skipped
+		end := i + 1
+		for end < len(content) && content[end] != '"' {
+			if content[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(content) {
+			return append(out, content[i:]...)
+		}
+		end++
+		token := content[i:end]
+		next := end
+		for next < len(content) && (content[next] == ' ' || content[next] == '\n' || content[next] == '\t' || content[next] == '\r') {
+			next++
+		}
+		if next < len(content) && content[next] == ':' {
+			// an object key
+			var key string
+			json.Unmarshal(token, &key)
+			if len(stack) > 0 {
+				stack[len(stack)-1].key = pathKeyEscaper.Replace(key)
+			}
+			out = append(out, token...)
+		} else if t, ok := templates[path()]; ok && isJSONString(token, t.resolved) {
+			raw, _ := json.Marshal(t.raw)
+			out = append(out, raw...)
+		} else {
+			out = append(out, token...)
+		}
+		i = end
+	}
+	return out
+}
+
+// isJSONString returns true if the json string token is the value
+func isJSONString(token []byte, value string) bool {
+	var s string
+	return json.Unmarshal(token, &s) == nil && s == value
+}
+
+// includedNames records the clusters, listeners and servers come from the include files
+type includedNames struct {
+	clusters  map[string]struct{}
+	listeners map[string]struct{}
+	servers   map[string]struct{}
+}
+
+func newIncludedNames() *includedNames {
+	return &includedNames{
+		clusters:  make(map[string]struct{}),
+		listeners: make(map[string]struct{}),
+		servers:   make(map[string]struct{}),
+	}
+}
+
+func (in *includedNames) empty() bool {
+	return len(in.clusters) == 0 && len(in.listeners) == 0 && len(in.servers) == 0
+}
+
+// included is the config from the include files of the running config, it is not written back when dumping
+var included = newIncludedNames()
+
+// loadIncludes merges the clusters and servers in the include files into the config.
+// the include patterns are relative to the directory of the main config file.
+// the listeners in a server with the same mosn_server_name are merged into the server
+func loadIncludes(cfg *MOSNConfig, dir string) (*includedNames, error) {
+	in := newIncludedNames()
+	if len(cfg.Includes) == 0 {
+		return in, nil
+	}
+	if cfg.ClusterManager.ClusterConfigPath != "" {
+		return nil, fmt.Errorf("includes can not be used with clusters_configs")
+	}
+	clusters := make(map[string]struct{}, len(cfg.ClusterManager.Clusters))
+	for _, c := range cfg.ClusterManager.Clusters {
+		clusters[c.Name] = struct{}{}
+	}
+	for _, pattern := range cfg.Includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			includeCfg := &MOSNConfig{}
+			if err := json.Unmarshal(interpolateEnv(content), includeCfg); err != nil {
+				return nil, fmt.Errorf("include file %s is invalid: %v", file, err)
+			}
+			for _, c := range includeCfg.ClusterManager.Clusters {
+				if _, ok := clusters[c.Name]; ok {
+					return nil, fmt.Errorf("include file %s has duplicate cluster %s", file, c.Name)
+				}
+				clusters[c.Name] = struct{}{}
+				in.clusters[c.Name] = struct{}{}
+				cfg.ClusterManager.Clusters = append(cfg.ClusterManager.Clusters, c)
+			}
+			for _, srv := range includeCfg.Servers {
+				for _, ln := range srv.Listeners {
+					if ln.Name == "" {
+						return nil, fmt.Errorf("include file %s has a listener without name", file)
+					}
+					in.listeners[ln.Name] = struct{}{}
+				}
+				mergeServer(cfg, srv, in)
+			}
+		}
+	}
+	return in, nil
+}
+
+func mergeServer(cfg *MOSNConfig, srv v2.ServerConfig, in *includedNames) {
+	for i := range cfg.Servers {
+		if cfg.Servers[i].ServerName == srv.ServerName {
+			cfg.Servers[i].Listeners = append(cfg.Servers[i].Listeners, srv.Listeners...)
+			return
+		}
+	}
+	in.servers[srv.ServerName] = struct{}{}
+	cfg.Servers = append(cfg.Servers, srv)
+}
+
+// withoutIncluded returns a copy of the config without the included clusters and listeners,
+// the config is not changed
+func withoutIncluded(cfg MOSNConfig) MOSNConfig {
+	if included.empty() {
+		return cfg
+	}
+	clusters := make([]v2.Cluster, 0, len(cfg.ClusterManager.Clusters))
+	for _, c := range cfg.ClusterManager.Clusters {
+		if _, ok := included.clusters[c.Name]; !ok {
+			clusters = append(clusters, c)
+		}
+	}
+	cfg.ClusterManager.Clusters = clusters
+	servers := make([]v2.ServerConfig, 0, len(cfg.Servers))
+	for _, srv := range cfg.Servers {
+		if _, ok := included.servers[srv.ServerName]; ok {
+			continue
+		}
+		listeners := make([]v2.Listener, 0, len(srv.Listeners))
+		for _, ln := range srv.Listeners {
+			if _, ok := included.listeners[ln.Name]; !ok {
+				listeners = append(listeners, ln)
+			}
+		}
+		srv.Listeners = listeners
+		servers = append(servers, srv)
+	}
+	cfg.Servers = servers
+	return cfg
+}
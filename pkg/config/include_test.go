@@ -0,0 +1,198 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	os.Setenv("MOSN_TEST_REGISTRY", "127.0.0.1:9600")
+	os.Setenv("MOSN_TEST_QUOTE", `a"b`)
+	os.Unsetenv("MOSN_TEST_NOT_SET")
+	defer func() {
+		os.Unsetenv("MOSN_TEST_REGISTRY")
+		os.Unsetenv("MOSN_TEST_QUOTE")
+	}()
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{`{"address":"${MOSN_TEST_REGISTRY}"}`, `{"address":"127.0.0.1:9600"}`},
+		{`{"address":"${MOSN_TEST_REGISTRY:127.0.0.1:80}"}`, `{"address":"127.0.0.1:9600"}`},
+		{`{"address":"${MOSN_TEST_NOT_SET:127.0.0.1:80}"}`, `{"address":"127.0.0.1:80"}`},
+		{`{"address":"${MOSN_TEST_NOT_SET}"}`, `{"address":""}`},
+		{`{"name":"${MOSN_TEST_QUOTE}"}`, `{"name":"a\"b"}`},
+		{`{"name":"$MOSN_TEST_REGISTRY"}`, `{"name":"$MOSN_TEST_REGISTRY"}`},
+	}
+	for i, tc := range testCases {
+		if got := string(interpolateEnv([]byte(tc.content))); got != tc.expected {
+			t.Errorf("#%d expected %s, but got %s", i, tc.expected, got)
+		}
+	}
+}
+
+func TestRestoreEnv(t *testing.T) {
+	os.Setenv("MOSN_TEST_SECRET", "secret")
+	os.Setenv("MOSN_TEST_QUOTE", `a"b`)
+	defer func() {
+		os.Unsetenv("MOSN_TEST_SECRET")
+		os.Unsetenv("MOSN_TEST_QUOTE")
+	}()
+	os.Unsetenv("MOSN_TEST_NOT_SET")
+	templates := collectEnvTemplates([]byte(`{
+		"password": "${MOSN_TEST_SECRET}",
+		"list": ["key-${MOSN_TEST_SECRET:x}", "${MOSN_TEST_QUOTE}"],
+		"empty": "${MOSN_TEST_NOT_SET}"
+	}`))
+	if len(templates) != 3 {
+		t.Fatalf("unexpected templates: %v", templates)
+	}
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{`{"password": "secret"}`, `{"password": "${MOSN_TEST_SECRET}"}`},
+		{`{"list":["key-secret","a\"b"]}`, `{"list":["key-${MOSN_TEST_SECRET:x}","${MOSN_TEST_QUOTE}"]}`},
+		{"{\n  \"name\": \"a,b}\",\n  \"list\": [\n    {\"x\": [1, 2]},\n    \"a\\\"b\"\n  ]\n}", "{\n  \"name\": \"a,b}\",\n  \"list\": [\n    {\"x\": [1, 2]},\n    \"${MOSN_TEST_QUOTE}\"\n  ]\n}"},
+		// the keys and the strings containing the value are kept
+		{`{"secret": "other", "name": "x\"secret"}`, `{"secret": "other", "name": "x\"secret"}`},
+		// the values at the other paths are kept, even if they are the resolved values
+		{`{"name": "secret", "list": ["a\"b"], "nested": {"password": "secret"}}`, `{"name": "secret", "list": ["a\"b"], "nested": {"password": "secret"}}`},
+		// the changed values are kept
+		{`{"password": "changed"}`, `{"password": "changed"}`},
+		// the empty values are not restored
+		{`{"empty": ""}`, `{"empty": ""}`},
+	}
+	for i, tc := range testCases {
+		if got := string(restoreEnv([]byte(tc.content), templates)); got != tc.expected {
+			t.Errorf("#%d expected %s, but got %s", i, tc.expected, got)
+		}
+	}
+}
+
+func TestLoadIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosn_config_includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("MOSN_TEST_HOST", "127.0.0.1:8080")
+	defer os.Unsetenv("MOSN_TEST_HOST")
+
+	files := map[string]string{
+		"mosn.json": `{
+			"includes": ["clusters/*.json", "listeners.json"],
+			"servers": [{"mosn_server_name": "main", "listeners": [{"name": "main_listener", "address": "127.0.0.1:2045"}]}],
+			"cluster_manager": {"clusters": [{"name": "main_cluster", "hosts": [{"address": "${MOSN_TEST_HOST}"}]}]}
+		}`,
+		"clusters/a.json": `{"cluster_manager": {"clusters": [{"name": "cluster_a", "hosts": [{"address": "${MOSN_TEST_HOST}"}]}]}}`,
+		"clusters/b.json": `{"cluster_manager": {"clusters": [{"name": "cluster_b"}]}}`,
+		"listeners.json": `{"servers": [
+			{"mosn_server_name": "main", "listeners": [{"name": "included_listener", "address": "127.0.0.1:2046"}]},
+			{"mosn_server_name": "included", "listeners": [{"name": "included_server_listener", "address": "127.0.0.1:2047"}]}
+		]}`,
+	}
+	if err := os.Mkdir(filepath.Join(dir, "clusters"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldPath := configPath
+	defer func() {
+		configPath = oldPath
+		config = MOSNConfig{}
+		included = newIncludedNames()
+		envTemplates = nil
+	}()
+
+	cfg := Load(filepath.Join(dir, "mosn.json"))
+	clusters := cfg.ClusterManager.Clusters
+	if len(clusters) != 3 || clusters[1].Name != "cluster_a" || clusters[2].Name != "cluster_b" {
+		t.Fatalf("unexpected clusters: %v", clusters)
+	}
+	if clusters[1].Hosts[0].Address != "127.0.0.1:8080" {
+		t.Fatalf("the env is not interpolated: %v", clusters[1].Hosts)
+	}
+	if len(cfg.Servers) != 2 || len(cfg.Servers[0].Listeners) != 2 || cfg.Servers[0].Listeners[1].Name != "included_listener" {
+		t.Fatalf("unexpected servers: %v", cfg.Servers)
+	}
+
+	// the included config is not written back
+	content, full, err := dumpContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumped := MOSNConfig{}
+	if err := json.Unmarshal(content, &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if len(dumped.ClusterManager.Clusters) != 1 || dumped.ClusterManager.Clusters[0].Name != "main_cluster" {
+		t.Errorf("unexpected dumped clusters: %v", dumped.ClusterManager.Clusters)
+	}
+	// the placeholders of the environment variables are written back, the running config is resolved
+	if hosts := dumped.ClusterManager.Clusters[0].Hosts; len(hosts) != 1 || hosts[0].Address != "${MOSN_TEST_HOST}" {
+		t.Errorf("the env placeholder should be dumped: %v", hosts)
+	}
+	if hosts := config.ClusterManager.Clusters[0].Hosts; hosts[0].Address != "127.0.0.1:8080" {
+		t.Errorf("the env is not interpolated: %v", hosts)
+	}
+	if len(dumped.Servers) != 1 || len(dumped.Servers[0].Listeners) != 1 || dumped.Servers[0].Listeners[0].Name != "main_listener" {
+		t.Errorf("unexpected dumped servers: %v", dumped.Servers)
+	}
+	if len(dumped.Includes) != 2 {
+		t.Errorf("the includes should be dumped: %v", dumped.Includes)
+	}
+	whole := MOSNConfig{}
+	if err := json.Unmarshal(full, &whole); err != nil {
+		t.Fatal(err)
+	}
+	if len(whole.ClusterManager.Clusters) != 3 || len(whole.Servers) != 2 {
+		t.Errorf("the full config should contain the included config: %s", string(full))
+	}
+	// the running config is not changed
+	if len(config.ClusterManager.Clusters) != 3 || len(config.Servers[0].Listeners) != 2 {
+		t.Errorf("the running config is changed when dumping")
+	}
+}
+
+func TestLoadIncludesDuplicateCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosn_config_includes_duplicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := `{"cluster_manager": {"clusters": [{"name": "main_cluster"}]}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "dup.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &MOSNConfig{Includes: []string{"*.json"}}
+	cfg.ClusterManager.Clusters = []v2.Cluster{{Name: "main_cluster"}}
+	if _, err := loadIncludes(cfg, dir); err == nil {
+		t.Fatal("expected the duplicate cluster is rejected")
+	}
+}
@@ -46,7 +46,7 @@ func DefaultConfigLoad(path string) *MOSNConfig {
 	}
 	cfg := &MOSNConfig{}
 	// translate to lower case
-	err = json.Unmarshal(content, cfg)
+	err = json.Unmarshal(interpolateEnv(content), cfg)
 	if err != nil {
 		log.Fatalln("[config] [default load] json unmarshal config failed, ", err)
 	}
@@ -58,6 +58,15 @@ func DefaultConfigLoad(path string) *MOSNConfig {
 func Load(path string) *MOSNConfig {
 	configPath, _ = filepath.Abs(path)
 	if cfg := configLoadFunc(path); cfg != nil {
+		in, err := loadIncludes(cfg, filepath.Dir(configPath))
+		if err != nil {
+			log.Fatalln("[config] [load] load include files failed, ", err)
+		}
+		included = in
+		// the placeholders of the environment variables are written back when dumping
+		if content, err := ioutil.ReadFile(configPath); err == nil {
+			envTemplates = collectEnvTemplates(content)
+		}
 		config = *cfg
 	}
 	return &config
@@ -18,6 +18,7 @@
 package config
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -43,8 +44,8 @@ var protocolsSupported = map[string]bool{
 }
 
 const (
-	MinHostWeight               = uint32(1)
-	MaxHostWeight               = uint32(128)
+	MinHostWeight               = v2.MinHostWeight
+	MaxHostWeight               = v2.MaxHostWeight
 	DefaultMaxRequestPerConn    = uint32(1024)
 	DefaultConnBufferLimitBytes = uint32(16 * 1024)
 )
@@ -96,8 +97,9 @@ func ParseClusterConfig(clusters []v2.Cluster) ([]v2.Cluster, map[string][]v2.Ho
 	var pClusters []v2.Cluster
 	clusterV2Map := make(map[string][]v2.Host)
 	for _, c := range clusters {
-		if c.Name == "" {
-			log.StartLogger.Fatalln("[config] [parse cluster] name is required in cluster config")
+		c.Hosts = parseHostConfig(c.Hosts)
+		if err := ValidateCluster(&c); err != nil {
+			log.StartLogger.Fatalf("[config] [parse cluster] %v", err)
 		}
 		if c.MaxRequestPerConn == 0 {
 			c.MaxRequestPerConn = DefaultMaxRequestPerConn
@@ -109,16 +111,6 @@ func ParseClusterConfig(clusters []v2.Cluster) ([]v2.Cluster, map[string][]v2.Ho
 			log.StartLogger.Infof("[config] [parse cluster] conn_buffer_limit_bytes is not specified, use default value %d",
 				DefaultConnBufferLimitBytes)
 		}
-		if c.LBSubSetConfig.FallBackPolicy > 2 {
-			log.StartLogger.Fatalln("[config] [parse cluster] lb subset config 's fall back policy set error. ",
-				"For 0, represent NO_FALLBACK",
-				"For 1, represent ANY_ENDPOINT",
-				"For 2, represent DEFAULT_SUBSET")
-		}
-		if _, ok := protocolsSupported[c.HealthCheck.Protocol]; !ok && c.HealthCheck.Protocol != "" {
-			log.StartLogger.Fatalf("[config] [parse cluster] unsupported health check protocol: %v", c.HealthCheck.Protocol)
-		}
-		c.Hosts = parseHostConfig(c.Hosts)
 		clusterV2Map[c.Name] = c.Hosts
 		pClusters = append(pClusters, c)
 	}
@@ -168,18 +160,16 @@ func ParseLogLevel(level string) log.Level {
 
 // ParseListenerConfig
 func ParseListenerConfig(lc *v2.Listener, inheritListeners []net.Listener) *v2.Listener {
-	if lc.AddrConfig == "" {
-		log.StartLogger.Fatalln("[config] [parse listener] Address is required in listener config")
-	}
-	addr, err := net.ResolveTCPAddr("tcp", lc.AddrConfig)
-	if err != nil {
-		log.StartLogger.Fatalln("[config] [parse listener] Address not valid:", lc.AddrConfig)
+	if err := ValidateListener(lc); err != nil {
+		log.StartLogger.Fatalf("[config] [parse listener] %v", err)
 	}
+	addr, _ := net.ResolveTCPAddr("tcp", lc.AddrConfig)
 	//try inherit legacy listener
 	var old *net.TCPListener
 
 	for i, il := range inheritListeners {
-		if il == nil {
+		// the udp listeners are not inherited, they are bound again with the reuse port option
+		if il == nil || lc.Network == v2.UDP_NETWORK {
 			continue
 		}
 		tl := il.(*net.TCPListener)
@@ -196,6 +186,9 @@ func ParseListenerConfig(lc *v2.Listener, inheritListeners []net.Listener) *v2.L
 			(addr.IP.IsLoopback() && ilAddr.IP.IsLoopback()) ||
 			addr.IP.Equal(ilAddr.IP) {
 			log.StartLogger.Infof("[config] [parse listener] inherit listener addr: %s", lc.AddrConfig)
+			if err := ValidateInheritedListener(lc, tl); err != nil {
+				log.StartLogger.Fatalf("[config] [parse listener] %v", err)
+			}
 			old = tl
 			inheritListeners[i] = nil
 			break
@@ -218,6 +211,9 @@ func ParseRouterConfiguration(c *v2.FilterChain) *v2.RouterConfiguration {
 				if err := json.Unmarshal(data, routerConfiguration); err != nil {
 					log.StartLogger.Fatal("[config] [parse router] Parsing Virtual Host Error:", err)
 				}
+				if err := ValidateRouterConfiguration(routerConfiguration); err != nil {
+					log.StartLogger.Fatalf("[config] [parse router] %v", err)
+				}
 			} else {
 				log.StartLogger.Fatal("[config] [parse router] Parsing Virtual Host Error")
 			}
@@ -271,6 +267,93 @@ func ParseStreamPayloadLimitFilter(cfg map[string]interface{}) (*v2.StreamPayloa
 	return filterConfig, nil
 }
 
+// ParseStreamLocalRateLimitFilter
+func ParseStreamLocalRateLimitFilter(cfg map[string]interface{}) (*v2.StreamLocalRateLimit, error) {
+	filterConfig := &v2.StreamLocalRateLimit{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.MaxTokens == 0 || filterConfig.TokensPerFill == 0 || filterConfig.FillInterval.Duration <= 0 {
+		return nil, fmt.Errorf("max_tokens, tokens_per_fill and fill_interval of local rate limit should be positive")
+	}
+	if filterConfig.MaxDescriptors < 0 {
+		return nil, fmt.Errorf("max_descriptors of local rate limit should not be negative")
+	}
+	return filterConfig, nil
+}
+
+// ParseStreamGzipFilter
+func ParseStreamGzipFilter(cfg map[string]interface{}) (*v2.StreamGzip, error) {
+	filterConfig := &v2.StreamGzip{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.MinContentLength < 0 {
+		return nil, fmt.Errorf("min_content_length of gzip should not be negative")
+	}
+	if filterConfig.MaxContentLength < 0 {
+		return nil, fmt.Errorf("max_content_length of gzip should not be negative")
+	}
+	if filterConfig.MaxContentLength > 0 && filterConfig.MaxContentLength < filterConfig.MinContentLength {
+		return nil, fmt.Errorf("max_content_length of gzip should not be less than min_content_length")
+	}
+	if level := filterConfig.CompressionLevel; level != nil && (*level < gzip.HuffmanOnly || *level > gzip.BestCompression) {
+		return nil, fmt.Errorf("compression_level of gzip should be in [%d, %d]", gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	return filterConfig, nil
+}
+
+// ParseStreamHeaderMutationFilter
+func ParseStreamHeaderMutationFilter(cfg map[string]interface{}) (*v2.StreamHeaderMutation, error) {
+	filterConfig := &v2.StreamHeaderMutation{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	for _, headers := range [][]*v2.HeaderValueOption{filterConfig.RequestHeadersToAdd, filterConfig.ResponseHeadersToAdd} {
+		for _, h := range headers {
+			if h == nil || h.Header == nil || h.Header.Key == "" {
+				return nil, fmt.Errorf("header to add of header mutation should have a key")
+			}
+		}
+	}
+	return filterConfig, nil
+}
+
+// ParseStreamCorsFilter
+func ParseStreamCorsFilter(cfg map[string]interface{}) (*v2.StreamCors, error) {
+	filterConfig := &v2.StreamCors{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.MaxAge.Duration < 0 {
+		return nil, fmt.Errorf("max_age of cors should not be negative")
+	}
+	if filterConfig.AllowCredentials {
+		for _, origin := range filterConfig.AllowOrigins {
+			if origin == "*" {
+				return nil, fmt.Errorf("allow_credentials of cors can not be used with the allow origin *")
+			}
+		}
+	}
+	return filterConfig, nil
+}
+
 // ParseStreamFaultInjectFilter
 func ParseStreamFaultInjectFilter(cfg map[string]interface{}) (*v2.StreamFaultInject, error) {
 	filterConfig := &v2.StreamFaultInject{}
@@ -326,6 +409,22 @@ func ParseTCPProxy(cfg map[string]interface{}) (*v2.TCPProxy, error) {
 	return proxy, nil
 }
 
+// ParseUDPProxy
+func ParseUDPProxy(cfg map[string]interface{}) (*v2.UDPProxy, error) {
+	proxy := &v2.UDPProxy{}
+	if data, err := json.Marshal(cfg); err == nil {
+		if err := json.Unmarshal(data, proxy); err != nil {
+			return nil, fmt.Errorf("[config] config is not a udp proxy config: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("[config] config is not a udp proxy config: %v", err)
+	}
+	if proxy.Cluster == "" {
+		return nil, fmt.Errorf("[config] the cluster of the udp proxy is required")
+	}
+	return proxy, nil
+}
+
 func ParseServiceRegistry(src v2.ServiceRegistryInfo) {
 	//trigger all callbacks
 	if cbs, ok := configParsedCBMaps[ParseCallbackKeyServiceRgtInfo]; ok {
@@ -177,7 +177,7 @@ func TestParseClusterConfig(t *testing.T) {
 			},
 			{
 				HostConfig: v2.HostConfig{
-					Address: "127.0.0.1:8080",
+					Address: "127.0.0.1:8081",
 					Weight:  100,
 				},
 			},
@@ -0,0 +1,526 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+)
+
+// ReloadResult is the result of reloading the config file,
+// each item describes a resource and what is done with it
+type ReloadResult struct {
+	Applied []string `json:"applied,omitempty"`
+	Skipped []string `json:"skipped,omitempty"`
+	Failed  []string `json:"failed,omitempty"`
+}
+
+// RouterConfigUpdateCallback is called when a router config is reloaded, it applies the router config to the running routers
+type RouterConfigUpdateCallback func(routerConfig *v2.RouterConfiguration) error
+
+var routerConfigUpdateCBs []RouterConfigUpdateCallback
+
+// RegisterRouterConfigUpdateListener
+// used to register RouterConfigUpdateCallback
+func RegisterRouterConfigUpdateListener(cb RouterConfigUpdateCallback) {
+	routerConfigUpdateCBs = append(routerConfigUpdateCBs, cb)
+}
+
+// ListenerLimitsUpdateCallback is called when the limits of a listener are reloaded, it applies the limits to the running listener
+type ListenerLimitsUpdateCallback func(serverName string, ln v2.Listener) error
+
+var listenerLimitsUpdateCBs []ListenerLimitsUpdateCallback
+
+// RegisterListenerLimitsUpdateListener
+// used to register ListenerLimitsUpdateCallback
+func RegisterListenerLimitsUpdateListener(cb ListenerLimitsUpdateCallback) {
+	listenerLimitsUpdateCBs = append(listenerLimitsUpdateCBs, cb)
+}
+
+// ListenerTLSUpdateCallback is called when the tls contexts of a listener are reloaded, it applies the tls contexts to the running listener
+type ListenerTLSUpdateCallback func(serverName string, ln v2.Listener) error
+
+var listenerTLSUpdateCBs []ListenerTLSUpdateCallback
+
+// RegisterListenerTLSUpdateListener
+// used to register ListenerTLSUpdateCallback
+func RegisterListenerTLSUpdateListener(cb ListenerTLSUpdateCallback) {
+	listenerTLSUpdateCBs = append(listenerTLSUpdateCBs, cb)
+}
+
+// ReloadOnSighup returns whether SIGHUP reloads the config file
+func ReloadOnSighup() bool {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return config.ReloadOnSighup
+}
+
+// reloadItem is a change to be applied
+type reloadItem struct {
+	name  string
+	apply func() error
+}
+
+// Reload reads the config file again, and applies the changes to the running mosn.
+// The new clusters, the updated clusters and hosts, the updated router configs, the listener limits, the listener tls contexts,
+// the log level and the runtime section are applied.
+// The listeners are not added, removed or changed in place, and the clusters are not removed, these changes are skipped.
+// If the config file is invalid, an error is returned and nothing is applied.
+func Reload() (*ReloadResult, error) {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	newCfg := &MOSNConfig{}
+	if err := json.Unmarshal(interpolateEnv(content), newCfg); err != nil {
+		return nil, err
+	}
+	in, err := loadIncludes(newCfg, filepath.Dir(configPath))
+	if err != nil {
+		return nil, err
+	}
+	for i := range newCfg.ClusterManager.Clusters {
+		newCfg.ClusterManager.Clusters[i].Hosts = parseHostConfig(newCfg.ClusterManager.Clusters[i].Hosts)
+	}
+	if err := validateReloadConfig(newCfg); err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{}
+	configLock.Lock()
+	items, skipped, err := planReload(newCfg)
+	if err == nil {
+		// the config from the include files is not written back
+		included = in
+		envTemplates = collectEnvTemplates(content)
+		config.Includes = newCfg.Includes
+	}
+	configLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result.Skipped = skipped
+	// the items are applied out of the lock, they use the exported functions that take the lock
+	for _, item := range items {
+		if err := item.apply(); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", item.name, err))
+		} else {
+			result.Applied = append(result.Applied, item.name)
+		}
+	}
+	for _, item := range result.Applied {
+		log.DefaultLogger.Infof("[config] [reload] applied %s", item)
+	}
+	for _, item := range result.Skipped {
+		log.DefaultLogger.Warnf("[config] [reload] skipped %s", item)
+	}
+	for _, item := range result.Failed {
+		log.DefaultLogger.Errorf("[config] [reload] failed %s", item)
+	}
+	return result, nil
+}
+
+func validateReloadConfig(cfg *MOSNConfig) error {
+	for i := range cfg.ClusterManager.Clusters {
+		if err := ValidateCluster(&cfg.ClusterManager.Clusters[i]); err != nil {
+			return err
+		}
+	}
+	for _, ln := range allListeners(*cfg) {
+		if err := ValidateListener(&ln); err != nil {
+			return err
+		}
+	}
+	routers, err := allRouters(*cfg)
+	if err != nil {
+		return err
+	}
+	for _, r := range routers {
+		if err := ValidateRouterConfiguration(r); err != nil {
+			return err
+		}
+	}
+	return ValidateRuntime(cfg.Runtime, false)
+}
+
+// planReload compares the new config with the running one, returns the changes to be applied and the skipped ones
+func planReload(newCfg *MOSNConfig) ([]reloadItem, []string, error) {
+	var items []reloadItem
+	var skipped []string
+
+	// clusters
+	running := make(map[string]v2.Cluster, len(config.ClusterManager.Clusters))
+	for _, c := range config.ClusterManager.Clusters {
+		running[c.Name] = c
+	}
+	clusters := make(map[string]struct{}, len(newCfg.ClusterManager.Clusters))
+	for _, c := range newCfg.ClusterManager.Clusters {
+		clusters[c.Name] = struct{}{}
+		old, ok := running[c.Name]
+		if !ok {
+			items = append(items, reloadClusterItem(fmt.Sprintf("cluster %s: added", c.Name), c))
+			continue
+		}
+		changed, err := jsonChanged(withoutHosts(old), withoutHosts(c))
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			items = append(items, reloadClusterItem(fmt.Sprintf("cluster %s: updated", c.Name), c))
+			continue
+		}
+		if changed, err = jsonChanged(old.Hosts, c.Hosts); err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			items = append(items, reloadHostsItem(fmt.Sprintf("cluster %s: hosts updated", c.Name), old, c))
+		}
+	}
+	for _, c := range config.ClusterManager.Clusters {
+		if _, ok := clusters[c.Name]; !ok {
+			skipped = append(skipped, fmt.Sprintf("cluster %s: removing clusters is not supported", c.Name))
+		}
+	}
+
+	// listeners, only the router configs, the limits and the tls contexts are applied
+	runningListeners := make(map[string]v2.Listener)
+	for _, ln := range allListeners(config) {
+		runningListeners[ln.Name] = ln
+	}
+	listeners := make(map[string]struct{})
+	for _, ln := range allListeners(*newCfg) {
+		listeners[ln.Name] = struct{}{}
+		old, ok := runningListeners[ln.Name]
+		switch {
+		case ln.Name == "":
+			skipped = append(skipped, fmt.Sprintf("listener %s: listener without name is not supported", ln.AddrConfig))
+			continue
+		case !ok:
+			skipped = append(skipped, fmt.Sprintf("listener %s: adding listeners is not supported", ln.Name))
+			continue
+		case old.AddrConfig != ln.AddrConfig:
+			skipped = append(skipped, fmt.Sprintf("listener %s: changing the address is not supported", ln.Name))
+			continue
+		case len(old.FilterChains) != len(ln.FilterChains):
+			skipped = append(skipped, fmt.Sprintf("listener %s: changing the filter chains is not supported", ln.Name))
+			continue
+		}
+		changed, err := jsonChanged(withoutTLS(withoutLimits(withoutRouters(old))), withoutTLS(withoutLimits(withoutRouters(ln))))
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			skipped = append(skipped, fmt.Sprintf("listener %s: only the router configs, the limits and the tls contexts are reloaded", ln.Name))
+		}
+		if old.MaxConnections != ln.MaxConnections || old.MaxConcurrentStreams != ln.MaxConcurrentStreams {
+			items = append(items, reloadListenerLimitsItem(ln))
+		}
+		if changed, err = tlsChanged(old, ln); err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			// the running listener supports only one filter chain to update the tls contexts
+			if len(ln.FilterChains) != 1 {
+				skipped = append(skipped, fmt.Sprintf("listener %s: the tls contexts of multiple filter chains are not reloaded", ln.Name))
+			} else {
+				items = append(items, reloadListenerTLSItem(ln))
+			}
+		}
+		for i, fc := range ln.FilterChains {
+			routerItems, routerSkipped, err := planRouterReload(ln, old.FilterChains[i], fc)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, routerItems...)
+			skipped = append(skipped, routerSkipped...)
+		}
+	}
+	for name := range runningListeners {
+		if _, ok := listeners[name]; !ok {
+			skipped = append(skipped, fmt.Sprintf("listener %s: removing listeners is not supported", name))
+		}
+	}
+
+	// runtime
+	changed, err := jsonChanged(config.Runtime, newCfg.Runtime)
+	if err != nil {
+		return nil, nil, err
+	}
+	if changed {
+		items = append(items, reloadRuntimeItem(newCfg.Runtime))
+	}
+
+	// log level
+	for _, srv := range newCfg.Servers {
+		for i := range config.Servers {
+			if config.Servers[i].ServerName == srv.ServerName && config.Servers[i].DefaultLogLevel != srv.DefaultLogLevel {
+				items = append(items, reloadLogLevelItem(srv))
+			}
+		}
+	}
+	return items, skipped, nil
+}
+
+func planRouterReload(ln v2.Listener, oldChain, newChain v2.FilterChain) ([]reloadItem, []string, error) {
+	oldRouters, err := allRouters(MOSNConfig{Servers: []v2.ServerConfig{{Listeners: []v2.Listener{listenerWithChain(ln, oldChain)}}}})
+	if err != nil {
+		return nil, nil, err
+	}
+	newRouters, err := allRouters(MOSNConfig{Servers: []v2.ServerConfig{{Listeners: []v2.Listener{listenerWithChain(ln, newChain)}}}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(newRouters) == 0 {
+		if len(oldRouters) > 0 {
+			return nil, []string{fmt.Sprintf("listener %s: removing the router config is not supported", ln.Name)}, nil
+		}
+		return nil, nil, nil
+	}
+	router := newRouters[0]
+	if len(oldRouters) > 0 {
+		changed, err := jsonChanged(oldRouters[0], router)
+		if err != nil || !changed {
+			return nil, nil, err
+		}
+	}
+	if newChain.Name == "" && len(ln.FilterChains) > 1 {
+		return nil, []string{fmt.Sprintf("listener %s: the filter chain name is required to reload the router %s", ln.Name, router.RouterConfigName)}, nil
+	}
+	return []reloadItem{
+		{
+			name: fmt.Sprintf("router %s of listener %s: updated", router.RouterConfigName, ln.Name),
+			apply: func() error {
+				if err := AddOrUpdateFilterChainRouterConfig(ln.Name, newChain.Name, router); err != nil {
+					return err
+				}
+				for _, cb := range routerConfigUpdateCBs {
+					if err := cb(router); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}, nil, nil
+}
+
+func listenerWithChain(ln v2.Listener, fc v2.FilterChain) v2.Listener {
+	ln.FilterChains = []v2.FilterChain{fc}
+	return ln
+}
+
+func reloadClusterItem(name string, c v2.Cluster) reloadItem {
+	return reloadItem{
+		name: name,
+		apply: func() error {
+			if err := AddOrUpdateClusterConfig([]v2.Cluster{c}); err != nil {
+				return err
+			}
+			return applyClusterConfigUpdate(ClusterUpdateAll, c)
+		},
+	}
+}
+
+func reloadHostsItem(name string, old, c v2.Cluster) reloadItem {
+	return reloadItem{
+		name: name,
+		apply: func() error {
+			hosts := make(map[string]struct{}, len(c.Hosts))
+			for _, h := range c.Hosts {
+				hosts[h.Address] = struct{}{}
+			}
+			var removed []string
+			for _, h := range old.Hosts {
+				if _, ok := hosts[h.Address]; !ok {
+					removed = append(removed, h.Address)
+				}
+			}
+			if err := RemoveClusterHosts(c.Name, removed); err != nil {
+				return err
+			}
+			if err := AddOrUpdateClusterHosts(c.Name, c.Hosts); err != nil {
+				return err
+			}
+			return applyClusterConfigUpdate(ClusterUpdateHosts, c)
+		},
+	}
+}
+
+func reloadLogLevelItem(srv v2.ServerConfig) reloadItem {
+	return reloadItem{
+		name: fmt.Sprintf("server %s: log level updated to %s", srv.ServerName, srv.DefaultLogLevel),
+		apply: func() error {
+			configLock.Lock()
+			for i := range config.Servers {
+				if config.Servers[i].ServerName == srv.ServerName {
+					config.Servers[i].DefaultLogLevel = srv.DefaultLogLevel
+				}
+			}
+			dump(true)
+			configLock.Unlock()
+			log.DefaultLogger.SetLogLevel(ParseLogLevel(srv.DefaultLogLevel))
+			return nil
+		},
+	}
+}
+
+// reloadRuntimeItem replaces the runtime section, the values set by the admin api still override it
+func reloadRuntimeItem(values map[string]interface{}) reloadItem {
+	return reloadItem{
+		name: "runtime: updated",
+		apply: func() error {
+			if err := SetRuntimeBase(values); err != nil {
+				return err
+			}
+			configLock.Lock()
+			config.Runtime = values
+			dump(true)
+			configLock.Unlock()
+			return nil
+		},
+	}
+}
+
+func reloadListenerLimitsItem(ln v2.Listener) reloadItem {
+	return reloadItem{
+		name: fmt.Sprintf("listener %s: limits updated", ln.Name),
+		apply: func() error {
+			configLock.Lock()
+			running, idx, err := findListener(ln.Name)
+			if err != nil {
+				configLock.Unlock()
+				return err
+			}
+			running.MaxConnections = ln.MaxConnections
+			running.MaxConcurrentStreams = ln.MaxConcurrentStreams
+			updateListener(idx, running)
+			serverName := config.Servers[idx.server].ServerName
+			dump(true)
+			configLock.Unlock()
+			for _, cb := range listenerLimitsUpdateCBs {
+				if err := cb(serverName, running); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func reloadListenerTLSItem(ln v2.Listener) reloadItem {
+	return reloadItem{
+		name: fmt.Sprintf("listener %s: tls contexts updated", ln.Name),
+		apply: func() error {
+			configLock.Lock()
+			running, idx, err := findListener(ln.Name)
+			if err != nil {
+				configLock.Unlock()
+				return err
+			}
+			// the filter chains are copied, the running config is not changed in place
+			chains := make([]v2.FilterChain, len(running.FilterChains))
+			copy(chains, running.FilterChains)
+			chains[0].TLSConfig = ln.FilterChains[0].TLSConfig
+			chains[0].TLSConfigs = ln.FilterChains[0].TLSConfigs
+			chains[0].TLSContexts = ln.FilterChains[0].TLSContexts
+			running.FilterChains = chains
+			updateListener(idx, running)
+			serverName := config.Servers[idx.server].ServerName
+			dump(true)
+			configLock.Unlock()
+			for _, cb := range listenerTLSUpdateCBs {
+				if err := cb(serverName, running); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func withoutHosts(c v2.Cluster) v2.Cluster {
+	c.Hosts = nil
+	return c
+}
+
+// withoutRouters returns a copy of the listener without the connection_manager filters
+func withoutRouters(ln v2.Listener) v2.Listener {
+	chains := make([]v2.FilterChain, 0, len(ln.FilterChains))
+	for _, fc := range ln.FilterChains {
+		filters := make([]v2.Filter, 0, len(fc.Filters))
+		for _, f := range fc.Filters {
+			if f.Type != v2.CONNECTION_MANAGER {
+				filters = append(filters, f)
+			}
+		}
+		fc.Filters = filters
+		chains = append(chains, fc)
+	}
+	ln.FilterChains = chains
+	return ln
+}
+
+// withoutTLS returns a copy of the listener without the tls contexts
+func withoutTLS(ln v2.Listener) v2.Listener {
+	chains := make([]v2.FilterChain, 0, len(ln.FilterChains))
+	for _, fc := range ln.FilterChains {
+		fc.TLSConfig = nil
+		fc.TLSConfigs = nil
+		fc.TLSContexts = nil
+		chains = append(chains, fc)
+	}
+	ln.FilterChains = chains
+	return ln
+}
+
+// tlsChanged returns whether the tls contexts of the filter chains are changed,
+// the filter chains count should be checked before
+func tlsChanged(old, ln v2.Listener) (bool, error) {
+	for i := range ln.FilterChains {
+		changed, err := jsonChanged(old.FilterChains[i].TLSContexts, ln.FilterChains[i].TLSContexts)
+		if err != nil || changed {
+			return changed, err
+		}
+	}
+	return false, nil
+}
+
+func withoutLimits(ln v2.Listener) v2.Listener {
+	ln.MaxConnections = 0
+	ln.MaxConcurrentStreams = 0
+	return ln
+}
+
+// jsonChanged compares the configs by the json
+func jsonChanged(old, updated interface{}) (bool, error) {
+	oldData, err := json.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+	newData, err := json.Marshal(updated)
+	if err != nil {
+		return false, err
+	}
+	return string(oldData) != string(newData), nil
+}
@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+)
+
+const reloadConfigStr = `{
+	"servers": [{
+		"mosn_server_name": "main",
+		"default_log_level": "INFO",
+		"listeners": [
+			{
+				"name": "egress",
+				"address": "127.0.0.1:2045",
+				"filter_chains": [{
+					"filters": [{
+						"type": "connection_manager",
+						"config": {
+							"router_config_name": "egress_router",
+							"virtual_hosts": [{"name": "egress", "domains": ["*"], "routers": [{"match": {"prefix": "/"}, "route": {"cluster_name": "test1"}}]}]
+						}
+					}]
+				}]
+			},
+			{"name": "ingress", "address": "127.0.0.1:2046"}
+		]
+	}],
+	"cluster_manager": {
+		"clusters": [
+			{"name": "test1", "lb_type": "LB_RANDOM", "hosts": [{"address": "127.0.0.1:8080"}]},
+			{"name": "test2", "lb_type": "LB_RANDOM"}
+		]
+	}
+}`
+
+const reloadedConfigStr = `{
+	"servers": [{
+		"mosn_server_name": "main",
+		"default_log_level": "DEBUG",
+		"listeners": [
+			{
+				"name": "egress",
+				"address": "127.0.0.1:2045",
+				"max_connections": 100,
+				"max_concurrent_streams": 10,
+				"filter_chains": [{
+					"tls_context": {"status": true, "cert_chain": "cert.pem", "private_key": "key.pem", "watch_files": true},
+					"filters": [{
+						"type": "connection_manager",
+						"config": {
+							"router_config_name": "egress_router",
+							"virtual_hosts": [{"name": "egress", "domains": ["*"], "routers": [{"match": {"prefix": "/"}, "route": {"cluster_name": "test3"}}]}]
+						}
+					}]
+				}]
+			},
+			{"name": "ingress", "address": "127.0.0.1:2047"},
+			{"name": "new_listener", "address": "127.0.0.1:2048"}
+		]
+	}],
+	"cluster_manager": {
+		"clusters": [
+			{"name": "test1", "lb_type": "LB_RANDOM", "hosts": [{"address": "127.0.0.1:8081"}]},
+			{"name": "test3", "lb_type": "LB_RANDOM"}
+		]
+	}
+}`
+
+func TestReload(t *testing.T) {
+	mockInitConfig(t, []byte(reloadConfigStr))
+	f, err := ioutil.TempFile("", "mosn_config_reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	oldPath := configPath
+	configPath = f.Name()
+	level := log.DefaultLogger.GetLogLevel()
+	defer func() {
+		configPath = oldPath
+		config = MOSNConfig{}
+		log.DefaultLogger.SetLogLevel(level)
+		routerMap.Lock()
+		routerMap.config = make(map[routerKey]*v2.RouterConfiguration)
+		routerMap.Unlock()
+	}()
+
+	var clusterUpdates []ClusterConfigUpdateType
+	RegisterClusterConfigUpdateListener(func(typ ClusterConfigUpdateType, c v2.Cluster) error {
+		clusterUpdates = append(clusterUpdates, typ)
+		return nil
+	})
+	var routerUpdates []string
+	RegisterRouterConfigUpdateListener(func(routerConfig *v2.RouterConfiguration) error {
+		routerUpdates = append(routerUpdates, routerConfig.RouterConfigName)
+		return nil
+	})
+
+	var limitsUpdates []string
+	RegisterListenerLimitsUpdateListener(func(serverName string, ln v2.Listener) error {
+		limitsUpdates = append(limitsUpdates, fmt.Sprintf("%s/%s:%d:%d", serverName, ln.Name, ln.MaxConnections, ln.MaxConcurrentStreams))
+		return nil
+	})
+	var tlsUpdates []string
+	RegisterListenerTLSUpdateListener(func(serverName string, ln v2.Listener) error {
+		tlsUpdates = append(tlsUpdates, fmt.Sprintf("%s/%s:%s", serverName, ln.Name, ln.FilterChains[0].TLSContexts[0].CertChain))
+		return nil
+	})
+
+	// an invalid config file is not applied
+	if err := ioutil.WriteFile(f.Name(), []byte(`{"cluster_manager": {"clusters": [{"lb_type": "LB_RANDOM"}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reload(); err == nil {
+		t.Fatal("expected the invalid config is rejected")
+	}
+	if len(clusterUpdates) != 0 || len(config.ClusterManager.Clusters) != 2 {
+		t.Fatal("the invalid config is applied")
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte(reloadedConfigStr), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result.Applied)
+	sort.Strings(result.Skipped)
+	expected := &ReloadResult{
+		Applied: []string{
+			"cluster test1: hosts updated",
+			"cluster test3: added",
+			"listener egress: limits updated",
+			"listener egress: tls contexts updated",
+			"router egress_router of listener egress: updated",
+			"server main: log level updated to DEBUG",
+		},
+		Skipped: []string{
+			"cluster test2: removing clusters is not supported",
+			"listener ingress: changing the address is not supported",
+			"listener new_listener: adding listeners is not supported",
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %+v, but got %+v", expected, result)
+	}
+	// verify the running config
+	clusters := config.ClusterManager.Clusters
+	if len(clusters) != 3 || clusters[0].Hosts[0].Address != "127.0.0.1:8081" || len(clusters[0].Hosts) != 1 || clusters[2].Name != "test3" {
+		t.Errorf("unexpected clusters: %v", clusters)
+	}
+	if config.Servers[0].DefaultLogLevel != "DEBUG" || log.DefaultLogger.GetLogLevel() != log.DEBUG {
+		t.Error("the log level is not updated")
+	}
+	if config.Servers[0].Listeners[1].AddrConfig != "127.0.0.1:2046" || len(config.Servers[0].Listeners) != 2 {
+		t.Error("the listeners are changed")
+	}
+	routerMap.Lock()
+	router, ok := routerMap.config[routerKey{listener: "egress"}]
+	routerMap.Unlock()
+	if !ok || router.VirtualHosts[0].Routers[0].Route.ClusterName != "test3" {
+		t.Error("the router config is not updated")
+	}
+	sort.Slice(clusterUpdates, func(i, j int) bool { return clusterUpdates[i] < clusterUpdates[j] })
+	if !reflect.DeepEqual(clusterUpdates, []ClusterConfigUpdateType{ClusterUpdateAll, ClusterUpdateHosts}) {
+		t.Errorf("unexpected cluster updates: %v", clusterUpdates)
+	}
+	if !reflect.DeepEqual(routerUpdates, []string{"egress_router"}) {
+		t.Errorf("unexpected router updates: %v", routerUpdates)
+	}
+	if !reflect.DeepEqual(limitsUpdates, []string{"main/egress:100:10"}) || config.Servers[0].Listeners[0].MaxConnections != 100 {
+		t.Errorf("unexpected listener limits updates: %v", limitsUpdates)
+	}
+	if !reflect.DeepEqual(tlsUpdates, []string{"main/egress:cert.pem"}) || !config.Servers[0].Listeners[0].FilterChains[0].TLSContexts[0].WatchFiles {
+		t.Errorf("unexpected listener tls updates: %v", tlsUpdates)
+	}
+
+	// reload again, nothing is changed
+	clusterUpdates = nil
+	routerUpdates = nil
+	limitsUpdates = nil
+	tlsUpdates = nil
+	dumpRouterConfig()
+	if result, err = Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != 0 || len(result.Failed) != 0 || len(clusterUpdates) != 0 || len(routerUpdates) != 0 || len(limitsUpdates) != 0 || len(tlsUpdates) != 0 {
+		t.Errorf("expected nothing is applied, but got %+v", result)
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+)
+
+// KindRuntime is the kind of the ValidationError of the runtime values
+const KindRuntime = "runtime"
+
+// RuntimeChangeCallback is called when the value of a runtime key is changed, the value is nil if the key is removed.
+// The callbacks are called in the goroutine changing the runtime, after the new values take effects,
+// they must not change the runtime or register callbacks
+type RuntimeChangeCallback func(key string, value interface{})
+
+// Runtime is a snapshot of the runtime values, it is never modified, so the data path reads it without lock.
+// The values of the runtime section of the config are overridden by the values set by the admin api
+type Runtime struct {
+	values map[string]interface{}
+}
+
+var (
+	// runtimeMux serializes the changes of the runtime layers and the callbacks
+	runtimeMux       sync.Mutex
+	runtimeBase      = map[string]interface{}{}
+	runtimeOverrides = map[string]interface{}{}
+	runtimeCallbacks = map[string][]RuntimeChangeCallback{}
+	runtimeStore     atomic.Value // store *Runtime
+)
+
+func init() {
+	runtimeStore.Store(&Runtime{values: map[string]interface{}{}})
+}
+
+// GetRuntime returns the current runtime values
+func GetRuntime() *Runtime {
+	return runtimeStore.Load().(*Runtime)
+}
+
+// Get returns the value of the key
+func (r *Runtime) Get(key string) (interface{}, bool) {
+	v, ok := r.values[key]
+	return v, ok
+}
+
+// Values returns a copy of the runtime values
+func (r *Runtime) Values() map[string]interface{} {
+	return copyRuntimeValues(r.values)
+}
+
+// GetBool returns the bool value of the key, the default value is returned if the key is not set or not a bool
+func (r *Runtime) GetBool(key string, defaultValue bool) bool {
+	switch v := r.values[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// GetInt returns the integer value of the key, the default value is returned if the key is not set or not an integer
+func (r *Runtime) GetInt(key string, defaultValue int64) int64 {
+	switch v := r.values[key].(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v)
+		}
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// GetDuration returns the duration value of the key, such as "1.5s",
+// the default value is returned if the key is not set or not a duration
+func (r *Runtime) GetDuration(key string, defaultValue time.Duration) time.Duration {
+	switch v := r.values[key].(type) {
+	case time.Duration:
+		return v
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// RegisterRuntimeListener registers a callback called when the value of the key is changed
+func RegisterRuntimeListener(key string, cb RuntimeChangeCallback) {
+	runtimeMux.Lock()
+	defer runtimeMux.Unlock()
+	runtimeCallbacks[key] = append(runtimeCallbacks[key], cb)
+}
+
+// ValidateRuntime checks the runtime keys are dotted names and the values are bools, numbers or strings.
+// A nil value is valid if allowNil is true, it removes the key
+func ValidateRuntime(values map[string]interface{}, allowNil bool) error {
+	invalid := func(key, format string, args ...interface{}) error {
+		return &ValidationError{
+			Kind:   KindRuntime,
+			Name:   key,
+			Field:  "runtime",
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+	for key, value := range values {
+		for _, part := range strings.Split(key, ".") {
+			if part == "" || strings.ContainsAny(part, " \t\r\n") {
+				return invalid(key, "invalid key, the key is a dotted name")
+			}
+		}
+		switch value.(type) {
+		case nil:
+			if !allowNil {
+				return invalid(key, "null value")
+			}
+		case bool, float64, int, int64, string, time.Duration:
+		default:
+			return invalid(key, "unsupported value type %T, the value is a bool, a number or a string", value)
+		}
+	}
+	return nil
+}
+
+// SetRuntimeBase replaces the values of the runtime section of the config, the values set by the admin api are kept
+func SetRuntimeBase(values map[string]interface{}) error {
+	if err := ValidateRuntime(values, false); err != nil {
+		return err
+	}
+	runtimeMux.Lock()
+	defer runtimeMux.Unlock()
+	runtimeBase = copyRuntimeValues(values)
+	updateRuntime()
+	return nil
+}
+
+// SetRuntimeOverrides sets the values overriding the runtime section of the config, a nil value removes the override
+func SetRuntimeOverrides(values map[string]interface{}) error {
+	if err := ValidateRuntime(values, true); err != nil {
+		return err
+	}
+	runtimeMux.Lock()
+	defer runtimeMux.Unlock()
+	overrides := copyRuntimeValues(runtimeOverrides)
+	for key, value := range values {
+		if value == nil {
+			delete(overrides, key)
+		} else {
+			overrides[key] = value
+		}
+	}
+	runtimeOverrides = overrides
+	updateRuntime()
+	return nil
+}
+
+// GetRuntimeOverrides returns a copy of the values set by the admin api
+func GetRuntimeOverrides() map[string]interface{} {
+	runtimeMux.Lock()
+	defer runtimeMux.Unlock()
+	return copyRuntimeValues(runtimeOverrides)
+}
+
+// updateRuntime stores the merged values and calls the callbacks of the changed keys, it is called with the lock
+func updateRuntime() {
+	values := copyRuntimeValues(runtimeBase)
+	for key, value := range runtimeOverrides {
+		values[key] = value
+	}
+	old := GetRuntime().values
+	runtimeStore.Store(&Runtime{values: values})
+
+	var changed []string
+	for key, value := range values {
+		if oldValue, ok := old[key]; !ok || !reflect.DeepEqual(oldValue, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range old {
+		if _, ok := values[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	for _, key := range changed {
+		log.DefaultLogger.Infof("[config] [runtime] key %s changed to %v", key, values[key])
+		for _, cb := range runtimeCallbacks[key] {
+			cb(key, values[key])
+		}
+	}
+}
+
+func copyRuntimeValues(values map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		c[key] = value
+	}
+	return c
+}
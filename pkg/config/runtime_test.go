@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func resetRuntime() {
+	runtimeMux.Lock()
+	runtimeBase = map[string]interface{}{}
+	runtimeOverrides = map[string]interface{}{}
+	runtimeCallbacks = map[string][]RuntimeChangeCallback{}
+	runtimeMux.Unlock()
+	runtimeStore.Store(&Runtime{values: map[string]interface{}{}})
+}
+
+func TestRuntimeGetters(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+
+	values := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"proxy.debug_headers": true,
+		"proxy.debug_string": "false",
+		"retry.budget": 20,
+		"retry.ratio": 0.5,
+		"drain.time": "1.5s",
+		"drain.invalid": "1.5"
+	}`), &values); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRuntimeBase(values); err != nil {
+		t.Fatalf("set runtime failed: %v", err)
+	}
+	rt := GetRuntime()
+	if !rt.GetBool("proxy.debug_headers", false) || rt.GetBool("proxy.debug_string", true) || !rt.GetBool("proxy.unknown", true) {
+		t.Error("unexpected bool values")
+	}
+	if rt.GetInt("retry.budget", 0) != 20 || rt.GetInt("retry.ratio", 1) != 1 || rt.GetInt("drain.time", 2) != 2 {
+		t.Error("unexpected int values")
+	}
+	if rt.GetDuration("drain.time", 0) != 1500*time.Millisecond || rt.GetDuration("drain.invalid", time.Second) != time.Second {
+		t.Error("unexpected duration values")
+	}
+	// the snapshot is not changed by the updates
+	if err := SetRuntimeOverrides(map[string]interface{}{"retry.budget": float64(10)}); err != nil {
+		t.Fatalf("set runtime overrides failed: %v", err)
+	}
+	if rt.GetInt("retry.budget", 0) != 20 || GetRuntime().GetInt("retry.budget", 0) != 10 {
+		t.Error("unexpected snapshot values")
+	}
+}
+
+func TestRuntimeOverrides(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+
+	var changes []string
+	RegisterRuntimeListener("feature.a", func(key string, value interface{}) {
+		changes = append(changes, key)
+		if GetRuntime().GetBool(key, false) != (value == true) {
+			t.Errorf("the callback is called before the value takes effects")
+		}
+	})
+	if err := SetRuntimeBase(map[string]interface{}{"feature.a": true, "feature.b": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	// override a key of the config
+	if err := SetRuntimeOverrides(map[string]interface{}{"feature.a": false}); err != nil {
+		t.Fatal(err)
+	}
+	// the overrides are kept when the config is updated
+	if err := SetRuntimeBase(map[string]interface{}{"feature.a": true, "feature.b": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if GetRuntime().GetBool("feature.a", true) {
+		t.Error("expected the override kept")
+	}
+	// remove the override, fall back to the config
+	if err := SetRuntimeOverrides(map[string]interface{}{"feature.a": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if !GetRuntime().GetBool("feature.a", false) || len(GetRuntimeOverrides()) != 0 {
+		t.Error("expected the override removed")
+	}
+	// the key is removed
+	if err := SetRuntimeBase(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetRuntime().Get("feature.a"); ok {
+		t.Error("expected the key removed")
+	}
+	// set, overridden, the override removed and the key removed
+	if len(changes) != 4 {
+		t.Errorf("expected 4 changes of feature.a, but got %d", len(changes))
+	}
+}
+
+func TestValidateRuntime(t *testing.T) {
+	testCases := []struct {
+		values   map[string]interface{}
+		allowNil bool
+		valid    bool
+	}{
+		{map[string]interface{}{"a.b_c": 1.0, "d": "x", "e": false}, false, true},
+		{map[string]interface{}{"a": nil}, true, true},
+		{map[string]interface{}{"a": nil}, false, false},
+		{map[string]interface{}{"a..b": 1.0}, false, false},
+		{map[string]interface{}{"a.": 1.0}, false, false},
+		{map[string]interface{}{"a b": 1.0}, false, false},
+		{map[string]interface{}{"a": []interface{}{1.0}}, false, false},
+		{map[string]interface{}{"a": map[string]interface{}{}}, false, false},
+	}
+	for i, tc := range testCases {
+		err := ValidateRuntime(tc.values, tc.allowNil)
+		if tc.valid != (err == nil) {
+			t.Errorf("#%d unexpected validation result: %v", i, err)
+			continue
+		}
+		if err != nil {
+			if verr, ok := err.(*ValidationError); !ok || verr.Kind != KindRuntime {
+				t.Errorf("#%d unexpected validation error: %v", i, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,428 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/network"
+)
+
+// Group of the config kinds in the ValidationError
+const (
+	KindCluster  = "cluster"
+	KindListener = "listener"
+	KindRouter   = "router"
+)
+
+// ValidationError describes which field of a config is invalid
+type ValidationError struct {
+	Kind   string
+	Name   string
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s config %s, %s: %s", e.Kind, e.Name, e.Field, e.Reason)
+}
+
+var lbTypesSupported = map[v2.LbType]bool{
+	v2.LB_RANDOM:     true,
+	v2.LB_ROUNDROBIN: true,
+	v2.LB_RINGHASH:   true,
+}
+
+// RegisterLbType
+// used to register a load balancer type, so the clusters using it are valid
+func RegisterLbType(lbType v2.LbType) bool {
+	if _, ok := lbTypesSupported[lbType]; ok {
+		return false
+	}
+	lbTypesSupported[lbType] = true
+	return true
+}
+
+// ValidateCluster checks the cluster config is valid
+func ValidateCluster(c *v2.Cluster) error {
+	invalid := func(field, format string, args ...interface{}) error {
+		return &ValidationError{
+			Kind:   KindCluster,
+			Name:   c.Name,
+			Field:  field,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+	if c.Name == "" {
+		return invalid("name", "name is required")
+	}
+	if c.LbType != "" && !lbTypesSupported[c.LbType] {
+		return invalid("lb_type", "unsupported lb type %s", c.LbType)
+	}
+	if c.ConnectTimeout != nil && c.ConnectTimeout.Duration < 0 {
+		return invalid("connect_timeout", "negative timeout %s", c.ConnectTimeout.Duration)
+	}
+	if c.TCPUserTimeout != nil && c.TCPUserTimeout.Duration < 0 {
+		return invalid("tcp_user_timeout", "negative timeout %s", c.TCPUserTimeout.Duration)
+	}
+	if c.DrainTimeout != nil && c.DrainTimeout.Duration < 0 {
+		return invalid("drain_timeout", "negative timeout %s", c.DrainTimeout.Duration)
+	}
+	if c.ProxyProtocol != "" && c.ProxyProtocol != v2.PROXY_PROTOCOL_V1 && c.ProxyProtocol != v2.PROXY_PROTOCOL_V2 {
+		return invalid("proxy_protocol", "unknown proxy protocol version %s", c.ProxyProtocol)
+	}
+	// the header is sent once per connection, a shared connection would report the first client's addresses
+	if c.ProxyProtocol != "" && c.ConnPoolMode != v2.PER_DOWNSTREAM_CONN_POOL {
+		return invalid("proxy_protocol", "proxy protocol requires the %s connection pool mode", v2.PER_DOWNSTREAM_CONN_POOL)
+	}
+	if c.ClusterType == v2.ORIGINAL_DST_CLUSTER && len(c.Hosts) > 0 {
+		return invalid("hosts", "hosts of the original dst cluster are not configurable")
+	}
+	if od := c.OriginalDstLbConfig; od != nil && od.IdleTimeout != nil && od.IdleTimeout.Duration < 0 {
+		return invalid("original_dst_lb_config", "negative idle timeout %s", od.IdleTimeout.Duration)
+	}
+	if ka := c.KeepAlive; ka != nil {
+		if ka.Interval != nil && ka.Interval.Duration < 0 {
+			return invalid("keepalive", "negative interval %s", ka.Interval.Duration)
+		}
+		if ka.Timeout != nil && ka.Timeout.Duration < 0 {
+			return invalid("keepalive", "negative timeout %s", ka.Timeout.Duration)
+		}
+		if ka.JitterPercent != nil && *ka.JitterPercent > 100 {
+			return invalid("keepalive", "jitter percent %d is greater than 100", *ka.JitterPercent)
+		}
+		if ka.MaxInterval != nil && ka.MaxInterval.Duration < 0 {
+			return invalid("keepalive", "negative max interval %s", ka.MaxInterval.Duration)
+		}
+		if probe := ka.HTTPProbe; probe != nil && probe.Path != "" && !strings.HasPrefix(probe.Path, "/") {
+			return invalid("keepalive", "invalid http probe path %s", probe.Path)
+		}
+	}
+	if od := c.OutlierDetection; od != nil {
+		if od.BaseEjectionTime != nil && od.BaseEjectionTime.Duration < 0 {
+			return invalid("outlier_detection", "negative base ejection time %s", od.BaseEjectionTime.Duration)
+		}
+		if od.MaxEjectionTime != nil && od.MaxEjectionTime.Duration < 0 {
+			return invalid("outlier_detection", "negative max ejection time %s", od.MaxEjectionTime.Duration)
+		}
+		if od.MaxEjectionPercent > 100 {
+			return invalid("outlier_detection", "max ejection percent %d is greater than 100", od.MaxEjectionPercent)
+		}
+	}
+	if rh := c.RingHashConfig; rh != nil && rh.MaximumRingSize > 0 && rh.MinimumRingSize > rh.MaximumRingSize {
+		return invalid("ring_hash_config", "minimum ring size %d is greater than maximum ring size %d", rh.MinimumRingSize, rh.MaximumRingSize)
+	}
+	if err := validateThresholds(c.CirBreThresholds.Thresholds); err != nil {
+		return invalid("circuit_breakers", "%v", err)
+	}
+	if ss := c.SlowStart; ss != nil {
+		if ss.SlowStartDuration != nil && ss.SlowStartDuration.Duration < 0 {
+			return invalid("slow_start", "negative slow start duration %s", ss.SlowStartDuration.Duration)
+		}
+		if ss.Aggression < 0 {
+			return invalid("slow_start", "negative aggression %v", ss.Aggression)
+		}
+		if ss.MinWeightPercent > 100 {
+			return invalid("slow_start", "min weight percent %d is greater than 100", ss.MinWeightPercent)
+		}
+	}
+	if w := c.WriteBufferWatermarks; w != nil && w.Low > 0 && w.Low >= w.High {
+		return invalid("write_buffer_watermarks", "low watermark %d is not less than high watermark %d", w.Low, w.High)
+	}
+	if err := validateLBSubset(c.LBSubSetConfig, invalid); err != nil {
+		return err
+	}
+	if _, ok := protocolsSupported[c.HealthCheck.Protocol]; !ok && c.HealthCheck.Protocol != "" {
+		return invalid("health_check", "unsupported health check protocol %s", c.HealthCheck.Protocol)
+	}
+	return validateHosts(c.Hosts, invalid)
+}
+
+func validateLBSubset(cfg v2.LBSubsetConfig, invalid func(field, format string, args ...interface{}) error) error {
+	defaultSubset := cfg.FallBackPolicy == 2
+	switch cfg.FallbackPolicy {
+	case "":
+		if cfg.FallBackPolicy > 2 {
+			return invalid("lb_subset_config", "unknown fall back policy %d, 0: NO_FALLBACK, 1: ANY_ENDPOINT, 2: DEFAULT_SUBSET", cfg.FallBackPolicy)
+		}
+	case v2.NO_FALLBACK, v2.ANY_ENDPOINT:
+		defaultSubset = false
+	case v2.DEFAULT_SUBSET:
+		defaultSubset = true
+	default:
+		return invalid("lb_subset_config", "unknown fallback policy %s, expected NO_FALLBACK, ANY_ENDPOINT or DEFAULT_SUBSET", cfg.FallbackPolicy)
+	}
+	if defaultSubset && len(cfg.DefaultSubset) == 0 {
+		return invalid("lb_subset_config", "default subset is required by the DEFAULT_SUBSET fallback policy")
+	}
+	return nil
+}
+
+func validateHosts(hosts []v2.Host, invalid func(field, format string, args ...interface{}) error) error {
+	addrs := make(map[string]struct{}, len(hosts))
+	for _, h := range hosts {
+		if err := validateHost(h, invalid); err != nil {
+			return err
+		}
+		if _, ok := addrs[h.Address]; ok {
+			return invalid("hosts", "duplicate host address %s", h.Address)
+		}
+		addrs[h.Address] = struct{}{}
+	}
+	return nil
+}
+
+func validateHost(h v2.Host, invalid func(field, format string, args ...interface{}) error) error {
+	if err := validateAddress(h.Address); err != nil {
+		return invalid("hosts", "invalid host address %s: %v", h.Address, err)
+	}
+	// zero weight uses the default weight, the weight out of range is clamped when the host is created
+	if h.Weight > MaxHostWeight {
+		log.DefaultLogger.Warnf("[config] [validate] host %s weight %d is out of range [%d, %d], clamped to %d", h.Address, h.Weight, MinHostWeight, MaxHostWeight, MaxHostWeight)
+	}
+	return nil
+}
+
+// validateAddress checks the address is a host:port one
+func validateAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return fmt.Errorf("invalid port %s", port)
+	}
+	return nil
+}
+
+// ValidateRouterConfiguration checks the router config is valid
+func ValidateRouterConfiguration(r *v2.RouterConfiguration) error {
+	if r == nil {
+		return &ValidationError{
+			Kind:   KindRouter,
+			Field:  "router_config_name",
+			Reason: "router config is required",
+		}
+	}
+	invalid := func(field, format string, args ...interface{}) error {
+		return &ValidationError{
+			Kind:   KindRouter,
+			Name:   r.RouterConfigName,
+			Field:  field,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+	if r.RouterConfigName == "" {
+		return invalid("router_config_name", "name is required")
+	}
+	for i, vh := range r.VirtualHosts {
+		if vh == nil {
+			return invalid("virtual_hosts", "virtual host %d is empty", i)
+		}
+		if len(vh.Routers) == 0 {
+			return invalid("virtual_hosts", "virtual host %s has no routers", vh.Name)
+		}
+		for _, r := range vh.Routers {
+			for _, hp := range r.Route.HashPolicy {
+				if hp.Header == "" && !hp.SourceIP {
+					return invalid("hash_policy", "hash policy in virtual host %s has neither header nor source ip", vh.Name)
+				}
+			}
+			if !validRoutingPriority(r.Route.Priority) {
+				return invalid("priority", "unknown priority %s in virtual host %s", r.Route.Priority, vh.Name)
+			}
+			if rp := r.Route.RetryPolicy; rp != nil {
+				if err := validateRetryPolicy(rp); err != nil {
+					return invalid("retry_policy", "retry policy in virtual host %s is invalid: %v", vh.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateThresholds checks the circuit breakers thresholds of a cluster,
+// it is used by both the cluster config and the circuit breakers update
+func validateThresholds(thresholds []v2.Thresholds) error {
+	priorities := make(map[v2.RoutingPriority]bool, len(thresholds))
+	for _, threshold := range thresholds {
+		if rb := threshold.RetryBudget; rb != nil && rb.BudgetPercent > 100 {
+			return fmt.Errorf("retry budget percent %d is greater than 100", rb.BudgetPercent)
+		}
+		if !validRoutingPriority(threshold.Priority) {
+			return fmt.Errorf("unknown priority %s", threshold.Priority)
+		}
+		// the thresholds without priority are the default priority's
+		priority := threshold.Priority
+		if priority == "" {
+			priority = v2.DEFAULT_PRIORITY
+		}
+		if priorities[priority] {
+			return fmt.Errorf("duplicate thresholds of priority %s", priority)
+		}
+		priorities[priority] = true
+	}
+	return nil
+}
+
+func validRoutingPriority(p v2.RoutingPriority) bool {
+	return p == "" || p == v2.DEFAULT_PRIORITY || p == v2.HIGH_PRIORITY
+}
+
+func validateRetryPolicy(rp *v2.RetryPolicy) error {
+	for _, cond := range rp.RetryOnConditions {
+		switch cond {
+		case v2.RetryOn5xx, v2.RetryOnConnectFailure, v2.RetryOnReset:
+		case v2.RetryOnRetriableStatusCodes:
+			if len(rp.RetriableStatusCodes) == 0 {
+				return fmt.Errorf("no retriable status codes")
+			}
+		default:
+			return fmt.Errorf("unknown retry on condition %s", cond)
+		}
+	}
+	for _, code := range rp.RetriableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid status code %d", code)
+		}
+	}
+	return nil
+}
+
+// ValidateListener checks the listener config is valid
+// a listener without a name is valid, the listener is named when it is added
+func ValidateListener(l *v2.Listener) error {
+	invalid := func(field, format string, args ...interface{}) error {
+		return &ValidationError{
+			Kind:   KindListener,
+			Name:   l.Name,
+			Field:  field,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+	if l.AddrConfig == "" {
+		return invalid("address", "address is required")
+	}
+	if _, err := net.ResolveTCPAddr("tcp", l.AddrConfig); err != nil {
+		return invalid("address", "invalid address %s: %v", l.AddrConfig, err)
+	}
+	names := make(map[string]struct{}, len(l.FilterChains))
+	for _, fc := range l.FilterChains {
+		if fc.Name == "" {
+			continue
+		}
+		if _, ok := names[fc.Name]; ok {
+			return invalid("filter_chains", "duplicate filter chain name %s", fc.Name)
+		}
+		names[fc.Name] = struct{}{}
+	}
+	serverNames := make(map[string]struct{})
+	for _, fc := range l.FilterChains {
+		if fc.MatchConfig == nil {
+			continue
+		}
+		for _, sn := range fc.MatchConfig.ServerNames {
+			name := strings.TrimRight(strings.ToLower(sn), ".")
+			if name == "" {
+				return invalid("filter_chain_match", "empty server name of filter chain %s", fc.Name)
+			}
+			if _, ok := serverNames[name]; ok {
+				return invalid("filter_chain_match", "duplicate server name %s", sn)
+			}
+			serverNames[name] = struct{}{}
+		}
+	}
+	switch l.Network {
+	case "", v2.TCP_NETWORK:
+	case v2.UDP_NETWORK:
+		if l.UseOriginalDst || l.ReadOriginalDst || l.UseProxyProto {
+			return invalid("network", "the original dst and the proxy protocol are not supported by the udp listener")
+		}
+		if l.TransferConnection != nil && *l.TransferConnection {
+			return invalid("transfer_connection", "the udp listener has no connection to transfer")
+		}
+		for _, fc := range l.FilterChains {
+			for _, tls := range fc.TLSContexts {
+				if tls.Status {
+					return invalid("network", "tls is not supported by the udp listener")
+				}
+			}
+		}
+	default:
+		return invalid("network", "unknown network %s", l.Network)
+	}
+	if so := l.SocketOptions; so != nil {
+		if !l.BindToPort {
+			return invalid("socket_options", "the socket options are not applied to the listener not bound to port")
+		}
+		if so.TCPFastOpenQueueLength < 0 || so.Backlog < 0 {
+			return invalid("socket_options", "negative tcp fast open queue length %d or backlog %d", so.TCPFastOpenQueueLength, so.Backlog)
+		}
+		if l.Network == v2.UDP_NETWORK && (so.TCPFastOpenQueueLength > 0 || so.Backlog > 0) {
+			return invalid("socket_options", "tcp fast open and backlog are not supported by the udp listener")
+		}
+		if addr, _ := net.ResolveTCPAddr("tcp", l.AddrConfig); so.IPv6Only != nil && addr.IP.To4() != nil {
+			return invalid("socket_options", "ipv6 only is not supported by the ipv4 address %s", l.AddrConfig)
+		}
+	}
+	if rid := l.RequestID; rid != nil && strings.ContainsAny(rid.Header, " \t\r\n:") {
+		return invalid("request_id", "invalid request id header %q", rid.Header)
+	}
+	if w := l.WriteBufferWatermarks; w != nil && w.Low > 0 && w.Low >= w.High {
+		return invalid("write_buffer_watermarks", "low watermark %d is not less than high watermark %d", w.Low, w.High)
+	}
+	if l.DebugPayloadBytes < 0 {
+		return invalid("debug_payload_bytes", "negative debug payload bytes %d", l.DebugPayloadBytes)
+	}
+	for _, al := range l.AccessLogs {
+		if r := al.Roller; r != nil && (r.MaxSize < 0 || r.MaxAge < 0 || r.MaxBackups < 0) {
+			return invalid("access_logs", "negative log roller of access log %s", al.Path)
+		}
+		if b := al.Buffer; b != nil {
+			if b.BufferSize < 0 || (b.FlushInterval != nil && b.FlushInterval.Duration < 0) {
+				return invalid("access_logs", "negative log buffer of access log %s", al.Path)
+			}
+			if p := b.OverflowPolicy; p != "" && p != v2.LOG_OVERFLOW_DROP && p != v2.LOG_OVERFLOW_BLOCK {
+				return invalid("access_logs", "unknown overflow policy %s of access log %s", p, al.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateInheritedListener checks the listener inherited from the old process has the socket options
+// that must be set before binding, the options of a bound socket can not be changed
+func ValidateInheritedListener(l *v2.Listener, tl *net.TCPListener) error {
+	if err := network.CheckInheritedListenerSockopts(tl, l.SocketOptions); err != nil {
+		return &ValidationError{
+			Kind:   KindListener,
+			Name:   l.Name,
+			Field:  "socket_options",
+			Reason: fmt.Sprintf("inherited listener %s: %v", tl.Addr(), err),
+		}
+	}
+	return nil
+}
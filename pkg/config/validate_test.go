@@ -0,0 +1,284 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func TestValidateCluster(t *testing.T) {
+	host := func(addr string, weight uint32) v2.Host {
+		return v2.Host{
+			HostConfig: v2.HostConfig{
+				Address: addr,
+				Weight:  weight,
+			},
+		}
+	}
+	invalidJitter := uint32(101)
+	testCases := []struct {
+		cluster v2.Cluster
+		field   string
+	}{
+		{v2.Cluster{Name: "ok", LbType: v2.LB_RANDOM, Hosts: []v2.Host{host("127.0.0.1:80", 0), host("mosn.io:80", 128)}}, ""},
+		{v2.Cluster{}, "name"},
+		{v2.Cluster{Name: "lb", LbType: "LB_UNKNOWN"}, "lb_type"},
+		{v2.Cluster{Name: "timeout", ConnectTimeout: &v2.DurationConfig{Duration: -time.Second}}, "connect_timeout"},
+		{v2.Cluster{Name: "drain", DrainTimeout: &v2.DurationConfig{Duration: -time.Second}}, "drain_timeout"},
+		{v2.Cluster{Name: "no_drain", DrainTimeout: &v2.DurationConfig{}}, ""},
+		{v2.Cluster{Name: "proxy_protocol", ProxyProtocol: v2.PROXY_PROTOCOL_V2, ConnPoolMode: v2.PER_DOWNSTREAM_CONN_POOL}, ""},
+		{v2.Cluster{Name: "proxy_protocol_shared", ProxyProtocol: v2.PROXY_PROTOCOL_V2}, "proxy_protocol"},
+		{v2.Cluster{Name: "proxy_protocol_version", ProxyProtocol: "v3", ConnPoolMode: v2.PER_DOWNSTREAM_CONN_POOL}, "proxy_protocol"},
+		{v2.Cluster{Name: "original_dst", ClusterType: v2.ORIGINAL_DST_CLUSTER, OriginalDstLbConfig: &v2.OriginalDstLbConfig{UseHTTPHeader: true}}, ""},
+		{v2.Cluster{Name: "original_dst_hosts", ClusterType: v2.ORIGINAL_DST_CLUSTER, Hosts: []v2.Host{host("127.0.0.1:80", 0)}}, "hosts"},
+		{v2.Cluster{Name: "original_dst_idle", OriginalDstLbConfig: &v2.OriginalDstLbConfig{IdleTimeout: &v2.DurationConfig{Duration: -time.Second}}}, "original_dst_lb_config"},
+		{v2.Cluster{Name: "keepalive", KeepAlive: &v2.KeepAlive{Interval: &v2.DurationConfig{Duration: -time.Second}}}, "keepalive"},
+		{v2.Cluster{Name: "ejection", OutlierDetection: &v2.OutlierDetection{BaseEjectionTime: &v2.DurationConfig{Duration: -time.Second}}}, "outlier_detection"},
+		{v2.Cluster{Name: "ejection_percent", OutlierDetection: &v2.OutlierDetection{Consecutive5xx: 5, MaxEjectionPercent: 101}}, "outlier_detection"},
+		{v2.Cluster{Name: "probe", KeepAlive: &v2.KeepAlive{HTTPProbe: &v2.HTTPKeepAliveProbe{Path: "health"}}}, "keepalive"},
+		{v2.Cluster{Name: "jitter", KeepAlive: &v2.KeepAlive{JitterPercent: &invalidJitter}}, "keepalive"},
+		{v2.Cluster{Name: "ringhash", LbType: v2.LB_RINGHASH, RingHashConfig: &v2.RingHashConfig{MinimumRingSize: 2048}}, ""},
+		{v2.Cluster{Name: "ring_size", RingHashConfig: &v2.RingHashConfig{MinimumRingSize: 2048, MaximumRingSize: 1024}}, "ring_hash_config"},
+		{v2.Cluster{Name: "retry_budget", CirBreThresholds: v2.CircuitBreakers{Thresholds: []v2.Thresholds{{RetryBudget: &v2.RetryBudget{BudgetPercent: 101}}}}}, "circuit_breakers"},
+		{v2.Cluster{Name: "priorities", CirBreThresholds: v2.CircuitBreakers{Thresholds: []v2.Thresholds{{}, {Priority: v2.HIGH_PRIORITY}}}}, ""},
+		{v2.Cluster{Name: "unknown_priority", CirBreThresholds: v2.CircuitBreakers{Thresholds: []v2.Thresholds{{Priority: "LOW"}}}}, "circuit_breakers"},
+		{v2.Cluster{Name: "duplicate_priority", CirBreThresholds: v2.CircuitBreakers{Thresholds: []v2.Thresholds{{}, {Priority: v2.DEFAULT_PRIORITY}}}}, "circuit_breakers"},
+		{v2.Cluster{Name: "slow_start", SlowStart: &v2.SlowStart{SlowStartDuration: &v2.DurationConfig{Duration: time.Minute}, Aggression: 2}}, ""},
+		{v2.Cluster{Name: "aggression", SlowStart: &v2.SlowStart{Aggression: -1}}, "slow_start"},
+		{v2.Cluster{Name: "min_weight", SlowStart: &v2.SlowStart{MinWeightPercent: 101}}, "slow_start"},
+		{v2.Cluster{Name: "watermarks", WriteBufferWatermarks: &v2.WriteBufferWatermarks{High: 64 << 10}}, ""},
+		{v2.Cluster{Name: "low_watermark", WriteBufferWatermarks: &v2.WriteBufferWatermarks{High: 64 << 10, Low: 64 << 10}}, "write_buffer_watermarks"},
+		{v2.Cluster{Name: "no_high_watermark", WriteBufferWatermarks: &v2.WriteBufferWatermarks{Low: 32 << 10}}, "write_buffer_watermarks"},
+		{v2.Cluster{Name: "subset", LBSubSetConfig: v2.LBSubsetConfig{FallBackPolicy: 3}}, "lb_subset_config"},
+		{v2.Cluster{Name: "fallback", LBSubSetConfig: v2.LBSubsetConfig{FallbackPolicy: "ANY"}}, "lb_subset_config"},
+		{v2.Cluster{Name: "default_subset", LBSubSetConfig: v2.LBSubsetConfig{FallbackPolicy: v2.DEFAULT_SUBSET}}, "lb_subset_config"},
+		{v2.Cluster{Name: "default_subset_policy", LBSubSetConfig: v2.LBSubsetConfig{FallBackPolicy: 2}}, "lb_subset_config"},
+		{v2.Cluster{Name: "named_fallback", LBSubSetConfig: v2.LBSubsetConfig{FallBackPolicy: 2, FallbackPolicy: v2.ANY_ENDPOINT}}, ""},
+		{v2.Cluster{Name: "default_subset_fallback", LBSubSetConfig: v2.LBSubsetConfig{
+			FallbackPolicy: v2.DEFAULT_SUBSET, DefaultSubset: map[string]string{"version": "1.0"}}}, ""},
+		{v2.Cluster{Name: "address", Hosts: []v2.Host{host("127.0.0.1", 1)}}, "hosts"},
+		{v2.Cluster{Name: "port", Hosts: []v2.Host{host("127.0.0.1:http", 1)}}, "hosts"},
+		{v2.Cluster{Name: "duplicate", Hosts: []v2.Host{host("127.0.0.1:80", 1), host("127.0.0.1:80", 2)}}, "hosts"},
+		// the weight out of range is clamped
+		{v2.Cluster{Name: "weight", Hosts: []v2.Host{host("127.0.0.1:80", MaxHostWeight+1)}}, ""},
+	}
+	for i, tc := range testCases {
+		err := ValidateCluster(&tc.cluster)
+		if tc.field == "" {
+			if err != nil {
+				t.Errorf("#%d expected valid, but got %v", i, err)
+			}
+			continue
+		}
+		verr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("#%d expected a validation error, but got %v", i, err)
+			continue
+		}
+		if verr.Kind != KindCluster || verr.Name != tc.cluster.Name || verr.Field != tc.field {
+			t.Errorf("#%d unexpected validation error: %v", i, verr)
+		}
+	}
+	// the registered lb type is valid
+	RegisterLbType("LB_VALIDATE_TEST")
+	if err := ValidateCluster(&v2.Cluster{Name: "registered", LbType: "LB_VALIDATE_TEST"}); err != nil {
+		t.Errorf("expected the registered lb type is valid, but got %v", err)
+	}
+}
+
+func TestValidateRouterConfiguration(t *testing.T) {
+	routers := []v2.Router{{RouterConfig: v2.RouterConfig{Match: v2.RouterMatch{Prefix: "/"}}}}
+	hashRouters := []v2.Router{{RouterConfig: v2.RouterConfig{Match: v2.RouterMatch{Prefix: "/"}}}}
+	hashRouters[0].Route.HashPolicy = []v2.HashPolicy{{Header: "user"}, {}}
+	retryRouters := []v2.Router{{RouterConfig: v2.RouterConfig{Match: v2.RouterMatch{Prefix: "/"}}}}
+	retryRouters[0].Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{RetryOnConditions: []string{v2.RetryOn5xx, v2.RetryOnRetriableStatusCodes}},
+	}
+	priorityRouters := []v2.Router{{RouterConfig: v2.RouterConfig{Match: v2.RouterMatch{Prefix: "/"}}}}
+	priorityRouters[0].Route.Priority = "LOW"
+	testCases := []struct {
+		router *v2.RouterConfiguration
+		field  string
+	}{
+		{&v2.RouterConfiguration{
+			RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "ok"},
+			VirtualHosts:              []*v2.VirtualHost{{Name: "vh", Routers: routers}},
+		}, ""},
+		{nil, "router_config_name"},
+		{&v2.RouterConfiguration{}, "router_config_name"},
+		{&v2.RouterConfiguration{
+			RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "no_routers"},
+			VirtualHosts:              []*v2.VirtualHost{{Name: "vh"}},
+		}, "virtual_hosts"},
+		{&v2.RouterConfiguration{
+			RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "hash_policy"},
+			VirtualHosts:              []*v2.VirtualHost{{Name: "vh", Routers: hashRouters}},
+		}, "hash_policy"},
+		{&v2.RouterConfiguration{
+			RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "retry_policy"},
+			VirtualHosts:              []*v2.VirtualHost{{Name: "vh", Routers: retryRouters}},
+		}, "retry_policy"},
+		{&v2.RouterConfiguration{
+			RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "priority"},
+			VirtualHosts:              []*v2.VirtualHost{{Name: "vh", Routers: priorityRouters}},
+		}, "priority"},
+	}
+	for i, tc := range testCases {
+		err := ValidateRouterConfiguration(tc.router)
+		if tc.field == "" {
+			if err != nil {
+				t.Errorf("#%d expected valid, but got %v", i, err)
+			}
+			continue
+		}
+		if verr, ok := err.(*ValidationError); !ok || verr.Kind != KindRouter || verr.Field != tc.field {
+			t.Errorf("#%d unexpected validation error: %v", i, err)
+		}
+	}
+}
+
+func TestValidateListener(t *testing.T) {
+	newListener := func(addr string, chains ...string) *v2.Listener {
+		ln := &v2.Listener{}
+		ln.Name = "test"
+		ln.AddrConfig = addr
+		for _, name := range chains {
+			ln.FilterChains = append(ln.FilterChains, v2.FilterChain{
+				FilterChainConfig: v2.FilterChainConfig{Name: name},
+			})
+		}
+		return ln
+	}
+	withRoller := func(ln *v2.Listener, roller *v2.LogRollerConfig) *v2.Listener {
+		ln.AccessLogs = append(ln.AccessLogs, v2.AccessLog{Path: "/tmp/access.log", Roller: roller})
+		return ln
+	}
+	withBuffer := func(ln *v2.Listener, buffer *v2.LogBufferConfig) *v2.Listener {
+		ln.AccessLogs = append(ln.AccessLogs, v2.AccessLog{Path: "/tmp/access.log", Buffer: buffer})
+		return ln
+	}
+	withDebugPayload := func(ln *v2.Listener, bytes int) *v2.Listener {
+		ln.DebugPayloadBytes = bytes
+		return ln
+	}
+	withServerNames := func(ln *v2.Listener, serverNames ...[]string) *v2.Listener {
+		for i, names := range serverNames {
+			ln.FilterChains[i].MatchConfig = &v2.FilterChainMatchConfig{ServerNames: names}
+		}
+		return ln
+	}
+	withNetwork := func(ln *v2.Listener, network string, tls bool) *v2.Listener {
+		ln.Network = network
+		for i := range ln.FilterChains {
+			ln.FilterChains[i].TLSContexts = []v2.TLSConfig{{Status: tls}}
+		}
+		return ln
+	}
+	withSocketOptions := func(ln *v2.Listener, bind bool, options *v2.ListenerSocketOptions) *v2.Listener {
+		ln.BindToPort = bind
+		ln.SocketOptions = options
+		return ln
+	}
+	withTransfer := func(ln *v2.Listener, transfer bool) *v2.Listener {
+		ln.TransferConnection = &transfer
+		return ln
+	}
+	withRequestID := func(ln *v2.Listener, header string) *v2.Listener {
+		ln.RequestID = &v2.RequestIDConfig{Header: header, TrustDownstream: true}
+		return ln
+	}
+	withWatermarks := func(ln *v2.Listener, high, low uint32) *v2.Listener {
+		ln.WriteBufferWatermarks = &v2.WriteBufferWatermarks{High: high, Low: low}
+		return ln
+	}
+	v6Only := true
+	testCases := []struct {
+		listener *v2.Listener
+		field    string
+	}{
+		{newListener("127.0.0.1:2045", "a", "b", "", ""), ""},
+		{newListener(""), "address"},
+		{newListener("127.0.0.1"), "address"},
+		{newListener("127.0.0.1:2045", "a", "a"), "filter_chains"},
+		{withRoller(newListener("127.0.0.1:2045"), &v2.LogRollerConfig{MaxSize: 10, MaxBackups: 3, Compress: true}), ""},
+		{withRoller(newListener("127.0.0.1:2045"), &v2.LogRollerConfig{MaxAge: -1}), "access_logs"},
+		{withBuffer(newListener("127.0.0.1:2045"), &v2.LogBufferConfig{BufferSize: 4096, OverflowPolicy: v2.LOG_OVERFLOW_BLOCK}), ""},
+		{withBuffer(newListener("127.0.0.1:2045"), &v2.LogBufferConfig{FlushInterval: &v2.DurationConfig{Duration: -time.Second}}), "access_logs"},
+		{withBuffer(newListener("127.0.0.1:2045"), &v2.LogBufferConfig{OverflowPolicy: "WAIT"}), "access_logs"},
+		{withDebugPayload(newListener("127.0.0.1:2045"), 1024), ""},
+		{withDebugPayload(newListener("127.0.0.1:2045"), -1), "debug_payload_bytes"},
+		{withServerNames(newListener("127.0.0.1:2045", "a", "b"), []string{"www.example.com"}, []string{"*.example.com"}), ""},
+		{withServerNames(newListener("127.0.0.1:2045", "a", "b"), []string{"www.example.com"}, []string{"WWW.example.com."}), "filter_chain_match"},
+		{withServerNames(newListener("127.0.0.1:2045", "a"), []string{""}), "filter_chain_match"},
+		{withNetwork(newListener("127.0.0.1:53", "a"), v2.UDP_NETWORK, false), ""},
+		{withNetwork(newListener("127.0.0.1:53", "a"), v2.UDP_NETWORK, true), "network"},
+		{withNetwork(newListener("127.0.0.1:53", "a"), "sctp", false), "network"},
+		{withSocketOptions(newListener("127.0.0.1:2045"), true, &v2.ListenerSocketOptions{ReusePort: true, Backlog: 1024}), ""},
+		{withSocketOptions(newListener("[::1]:2045"), true, &v2.ListenerSocketOptions{IPv6Only: &v6Only}), ""},
+		{withSocketOptions(newListener("127.0.0.1:2045"), false, &v2.ListenerSocketOptions{ReusePort: true}), "socket_options"},
+		{withSocketOptions(newListener("127.0.0.1:2045"), true, &v2.ListenerSocketOptions{Backlog: -1}), "socket_options"},
+		{withSocketOptions(newListener("127.0.0.1:2045"), true, &v2.ListenerSocketOptions{IPv6Only: &v6Only}), "socket_options"},
+		{withSocketOptions(withNetwork(newListener("127.0.0.1:53"), v2.UDP_NETWORK, false), true, &v2.ListenerSocketOptions{TCPFastOpenQueueLength: 16}), "socket_options"},
+		{withTransfer(newListener("127.0.0.1:2045"), true), ""},
+		{withTransfer(withNetwork(newListener("127.0.0.1:53"), v2.UDP_NETWORK, false), false), ""},
+		{withTransfer(withNetwork(newListener("127.0.0.1:53"), v2.UDP_NETWORK, false), true), "transfer_connection"},
+		{withRequestID(newListener("127.0.0.1:2045"), ""), ""},
+		{withRequestID(newListener("127.0.0.1:2045"), "x-trace-request"), ""},
+		{withRequestID(newListener("127.0.0.1:2045"), "x-trace request"), "request_id"},
+		{withWatermarks(newListener("127.0.0.1:2045"), 64<<10, 16<<10), ""},
+		{withWatermarks(newListener("127.0.0.1:2045"), 64<<10, 128<<10), "write_buffer_watermarks"},
+	}
+	for i, tc := range testCases {
+		err := ValidateListener(tc.listener)
+		if tc.field == "" {
+			if err != nil {
+				t.Errorf("#%d expected valid, but got %v", i, err)
+			}
+			continue
+		}
+		if verr, ok := err.(*ValidationError); !ok || verr.Kind != KindListener || verr.Field != tc.field {
+			t.Errorf("#%d unexpected validation error: %v", i, err)
+		}
+	}
+}
+
+func TestValidateInheritedListener(t *testing.T) {
+	tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	ln := &v2.Listener{}
+	ln.Name = "inherited"
+	if err := ValidateInheritedListener(ln, tl); err != nil {
+		t.Errorf("expected valid without the socket options, but got %v", err)
+	}
+	ln.SocketOptions = &v2.ListenerSocketOptions{Backlog: 1024}
+	if err := ValidateInheritedListener(ln, tl); err != nil {
+		t.Errorf("expected the backlog applied to the inherited listener, but got %v", err)
+	}
+	// the reuse port can not be set on the inherited listener
+	ln.SocketOptions.ReusePort = true
+	if verr, ok := ValidateInheritedListener(ln, tl).(*ValidationError); !ok || verr.Field != "socket_options" {
+		t.Errorf("expected the reuse port not matched, but got %v", verr)
+	}
+}
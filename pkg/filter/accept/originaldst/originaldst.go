@@ -60,6 +60,18 @@ func (filter *originalDst) OnAccept(cb types.ListenerFilterCallbacks) types.Filt
 	return types.Continue
 }
 
+// GetOriginalDst returns the destination address of a tcp connection before it is redirected by iptables
+func GetOriginalDst(conn net.Conn) (net.Addr, error) {
+	if _, ok := conn.(*net.TCPConn); !ok {
+		return nil, fmt.Errorf("unsupported connection type %T", conn)
+	}
+	ip, port, err := getOriginalAddr(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &net.TCPAddr{IP: net.IPv4(ip[0], ip[1], ip[2], ip[3]), Port: port}, nil
+}
+
 func getOriginalAddr(conn net.Conn) ([]byte, int, error) {
 	tc := conn.(*net.TCPConn)
 
@@ -76,6 +88,9 @@ func getOriginalAddr(conn net.Conn) ([]byte, int, error) {
 	if err := syscall.SetNonblock(fd, true); err != nil {
 		return nil, 0, fmt.Errorf("setnonblock %v", err)
 	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("getsockopt SO_ORIGINAL_DST %v", err)
+	}
 
 	p0 := int(addr.Multiaddr[2])
 	p1 := int(addr.Multiaddr[3])
@@ -0,0 +1,266 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyprotocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The PROXY protocol versions
+const (
+	Version1 = 1
+	Version2 = 2
+)
+
+const (
+	// v1MaxLength is the max length of a v1 header, including the CRLF
+	v1MaxLength = 107
+	// v2HeaderLength is the length of the v2 signature, the version and command, the family and the address length
+	v2HeaderLength = 16
+
+	v2CommandLocal = 0x0
+	v2CommandProxy = 0x1
+
+	v2FamilyUnspec = 0x00
+	v2FamilyTCP4   = 0x11
+	v2FamilyUDP4   = 0x12
+	v2FamilyTCP6   = 0x21
+	v2FamilyUDP6   = 0x22
+
+	v2AddressLengthIPv4 = 12
+	v2AddressLengthIPv6 = 36
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+var (
+	ErrNoHeader      = errors.New("no proxy protocol header")
+	ErrInvalidHeader = errors.New("invalid proxy protocol header")
+)
+
+// Header is the PROXY protocol header received before the connection data.
+// The addresses are nil if the connection is not proxied, such as the health checks of the proxy,
+// the connection's own addresses should be used in this case
+type Header struct {
+	Version     int
+	Source      net.Addr
+	Destination net.Addr
+}
+
+// ReadHeader reads the PROXY protocol v1 or v2 header of the connection.
+// The header is read exactly, the data following it is left in the connection.
+// A zero timeout means no read deadline
+func ReadHeader(conn net.Conn, timeout time.Duration) (*Header, error) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+	// the v1 prefix and the beginning of the v2 signature have the same length
+	prefix := make([]byte, len(v1Prefix), v2HeaderLength)
+	if _, err := io.ReadFull(conn, prefix); err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(prefix, v1Prefix):
+		return readV1(conn)
+	case bytes.Equal(prefix, v2Signature[:len(prefix)]):
+		return readV2(conn, prefix)
+	default:
+		return nil, ErrNoHeader
+	}
+}
+
+func readV1(conn net.Conn) (*Header, error) {
+	line := make([]byte, 0, v1MaxLength-len(v1Prefix))
+	b := make([]byte, 1)
+	// read byte by byte, so no data after the header is consumed
+	for {
+		if len(line) == cap(line) {
+			return nil, fmt.Errorf("%v: v1 header is longer than %d bytes", ErrInvalidHeader, v1MaxLength)
+		}
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return nil, err
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			break
+		}
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%v: v1 header is not terminated by CRLF", ErrInvalidHeader)
+	}
+	return parseV1(string(line[:len(line)-2]))
+}
+
+func parseV1(line string) (*Header, error) {
+	fields := strings.Split(line, " ")
+	header := &Header{Version: Version1}
+	switch fields[0] {
+	case "UNKNOWN":
+		// the rest of the line is ignored
+		return header, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("%v: unknown v1 protocol %q", ErrInvalidHeader, fields[0])
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%v: v1 header has %d fields", ErrInvalidHeader, len(fields))
+	}
+	v4 := fields[0] == "TCP4"
+	src, err := parseV1Address(fields[1], fields[3], v4)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Address(fields[2], fields[4], v4)
+	if err != nil {
+		return nil, err
+	}
+	header.Source = src
+	header.Destination = dst
+	return header, nil
+}
+
+func parseV1Address(host, port string, v4 bool) (net.Addr, error) {
+	ip := net.ParseIP(host)
+	// the ipv4 addresses are dotted, and the ipv6 addresses are colon separated
+	if ip == nil || strings.Contains(host, ":") == v4 {
+		return nil, fmt.Errorf("%v: invalid v1 address %q", ErrInvalidHeader, host)
+	}
+	// the port has no leading zeros
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, fmt.Errorf("%v: invalid v1 port %q", ErrInvalidHeader, port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func readV2(conn net.Conn, prefix []byte) (*Header, error) {
+	buf := prefix[:v2HeaderLength]
+	if _, err := io.ReadFull(conn, buf[len(prefix):]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(buf[:len(v2Signature)], v2Signature) {
+		return nil, ErrNoHeader
+	}
+	verCmd, family := buf[12], buf[13]
+	if verCmd>>4 != Version2 {
+		return nil, fmt.Errorf("%v: unknown v2 version %d", ErrInvalidHeader, verCmd>>4)
+	}
+	// the addresses and the tlvs
+	payload := make([]byte, binary.BigEndian.Uint16(buf[14:16]))
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, err
+	}
+	header := &Header{Version: Version2}
+	switch verCmd & 0xf {
+	case v2CommandLocal:
+		return header, nil
+	case v2CommandProxy:
+	default:
+		return nil, fmt.Errorf("%v: unknown v2 command %d", ErrInvalidHeader, verCmd&0xf)
+	}
+	var ipLen int
+	switch family {
+	case v2FamilyTCP4, v2FamilyUDP4:
+		ipLen = net.IPv4len
+	case v2FamilyTCP6, v2FamilyUDP6:
+		ipLen = net.IPv6len
+	default:
+		// the unix sockets and the unspecified families are treated as a local connection
+		return header, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, fmt.Errorf("%v: v2 address length %d is too short", ErrInvalidHeader, len(payload))
+	}
+	header.Source = &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	header.Destination = &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return header, nil
+}
+
+// EncodeHeader encodes the PROXY protocol header of the version.
+// If the addresses are not tcp addresses of the same family, an UNKNOWN v1 header or a LOCAL v2 header is encoded
+func EncodeHeader(version int, src, dst net.Addr) ([]byte, error) {
+	srcIP, srcPort, srcOK := tcpAddress(src)
+	dstIP, dstPort, dstOK := tcpAddress(dst)
+	known := srcOK && dstOK && (srcIP.To4() != nil) == (dstIP.To4() != nil)
+	v4 := known && srcIP.To4() != nil
+
+	switch version {
+	case Version1:
+		if !known {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		protocol := "TCP6"
+		if v4 {
+			protocol = "TCP4"
+			srcIP, dstIP = srcIP.To4(), dstIP.To4()
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", protocol, srcIP, dstIP, srcPort, dstPort)), nil
+	case Version2:
+		buf := make([]byte, v2HeaderLength, v2HeaderLength+v2AddressLengthIPv6)
+		copy(buf, v2Signature)
+		if !known {
+			buf[12] = Version2<<4 | v2CommandLocal
+			buf[13] = v2FamilyUnspec
+			return buf, nil
+		}
+		buf[12] = Version2<<4 | v2CommandProxy
+		if v4 {
+			buf[13] = v2FamilyTCP4
+			binary.BigEndian.PutUint16(buf[14:], v2AddressLengthIPv4)
+			buf = append(buf, srcIP.To4()...)
+			buf = append(buf, dstIP.To4()...)
+		} else {
+			buf[13] = v2FamilyTCP6
+			binary.BigEndian.PutUint16(buf[14:], v2AddressLengthIPv6)
+			buf = append(buf, srcIP.To16()...)
+			buf = append(buf, dstIP.To16()...)
+		}
+		var ports [4]byte
+		binary.BigEndian.PutUint16(ports[:2], uint16(srcPort))
+		binary.BigEndian.PutUint16(ports[2:], uint16(dstPort))
+		return append(buf, ports[:]...), nil
+	default:
+		return nil, fmt.Errorf("unknown proxy protocol version %d", version)
+	}
+}
+
+func tcpAddress(addr net.Addr) (net.IP, int, bool) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || tcpAddr == nil || tcpAddr.IP == nil {
+		return nil, 0, false
+	}
+	return tcpAddr.IP, tcpAddr.Port, true
+}
@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyprotocol
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// readFrom reads the header from a connection that received the data, returns the data left after the header
+func readFrom(t *testing.T, data []byte) (*Header, string, error) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		client.Write(data)
+		client.Close()
+	}()
+	header, err := ReadHeader(server, time.Second)
+	if err != nil {
+		return nil, "", err
+	}
+	left, _ := ioutil.ReadAll(server)
+	return header, string(left), nil
+}
+
+func TestEncodeAndReadHeader(t *testing.T) {
+	for _, tc := range []struct {
+		src, dst string
+	}{
+		{"192.168.1.1:56324", "10.0.0.1:443"},
+		{"[2001:db8::1]:56324", "[2001:db8::2]:443"},
+	} {
+		src, _ := net.ResolveTCPAddr("tcp", tc.src)
+		dst, _ := net.ResolveTCPAddr("tcp", tc.dst)
+		for _, version := range []int{Version1, Version2} {
+			data, err := EncodeHeader(version, src, dst)
+			if err != nil {
+				t.Fatalf("encode v%d header failed: %v", version, err)
+			}
+			header, left, err := readFrom(t, append(data, "GET / HTTP/1.1\r\n"...))
+			if err != nil {
+				t.Fatalf("read v%d header %q failed: %v", version, data, err)
+			}
+			if header.Version != version || header.Source.String() != tc.src || header.Destination.String() != tc.dst {
+				t.Errorf("v%d header expected %s %s, but got %+v", version, tc.src, tc.dst, header)
+			}
+			if left != "GET / HTTP/1.1\r\n" {
+				t.Errorf("v%d header consumed the data: %q", version, left)
+			}
+		}
+	}
+	// the addresses of different families are not proxied
+	src, _ := net.ResolveTCPAddr("tcp", "192.168.1.1:56324")
+	dst, _ := net.ResolveTCPAddr("tcp", "[2001:db8::2]:443")
+	for _, version := range []int{Version1, Version2} {
+		data, _ := EncodeHeader(version, src, dst)
+		header, _, err := readFrom(t, data)
+		if err != nil || header.Source != nil || header.Destination != nil {
+			t.Errorf("v%d header %q expected a local connection, but got %+v, error: %v", version, data, header, err)
+		}
+	}
+	if _, err := EncodeHeader(3, src, dst); err == nil {
+		t.Error("encode an unknown version should be failed")
+	}
+}
+
+func TestReadInvalidHeader(t *testing.T) {
+	v2Header, _ := EncodeHeader(Version2, &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 80}, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80})
+	badVersion := append([]byte{}, v2Header...)
+	badVersion[12] = 0x31
+	badLength := append([]byte{}, v2Header[:16]...)
+	badLength[15] = 4
+	badLength = append(badLength, 1, 2, 3, 4)
+	for _, data := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\n",
+		"PROXY TCP4 192.168.1.1 10.0.0.1 56324\r\n",
+		"PROXY TCP4 2001:db8::1 10.0.0.1 56324 443\r\n",
+		"PROXY TCP6 192.168.1.1 2001:db8::2 56324 443\r\n",
+		"PROXY TCP4 192.168.1.1 10.0.0.1 56324 65536\r\n",
+		"PROXY TCP4 192.168.1.1 10.0.0.1 056324 443\r\n",
+		"PROXY UDP4 192.168.1.1 10.0.0.1 56324 443\r\n",
+		"PROXY TCP4 192.168.1.1 10.0.0.1 56324 443 " + string(make([]byte, 64)) + "\r\n",
+		"PROXY TCP4 192.168",
+		string(v2Header[:20]),
+		string(badVersion),
+		string(badLength),
+	} {
+		if header, _, err := readFrom(t, []byte(data)); err == nil {
+			t.Errorf("read invalid header %q expected an error, but got %+v", data, header)
+		}
+	}
+	// the rest of an unknown v1 header is ignored
+	header, left, err := readFrom(t, []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\nping"))
+	if err != nil || header.Source != nil || left != "ping" {
+		t.Errorf("read unknown header failed, header: %+v, left: %q, error: %v", header, left, err)
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
@@ -44,8 +46,22 @@ type proxy struct {
 	downstreamCallbacks DownstreamCallbacks
 
 	upstreamConnecting bool
+	// upstreamResource is true if the upstream connection holds the cluster's connection resource
+	upstreamResource bool
 
 	accessLogs []types.AccessLog
+	// ctx is the downstream connection's context
+	ctx context.Context
+
+	// clusterStats is the stats of the cluster the upstream connection belongs to
+	clusterStats *types.ClusterStats
+	// the connection is reset if no bytes flow in both directions for the idle timeout,
+	// lastActive is the unix nano time of the last bytes, accessed atomically
+	idleTimer  *time.Timer
+	lastActive int64
+	// idleMux protects the idle timer, the timer is not reset after it is stopped
+	idleMux     sync.Mutex
+	idleStopped bool
 }
 
 func NewProxy(ctx context.Context, config *v2.TCPProxy, clusterManager types.ClusterManager) Proxy {
@@ -54,6 +70,7 @@ func NewProxy(ctx context.Context, config *v2.TCPProxy, clusterManager types.Clu
 		clusterManager: clusterManager,
 		requestInfo:    network.NewRequestInfo(),
 		accessLogs:     mosnctx.Get(ctx, types.ContextKeyAccessLogs).([]types.AccessLog),
+		ctx:            ctx,
 	}
 
 	p.upstreamCallbacks = &upstreamCallbacks{
@@ -72,6 +89,10 @@ func (p *proxy) OnData(buffer types.IoBuffer) types.FilterStatus {
 	}
 	bytesRecved := p.requestInfo.BytesReceived() + uint64(buffer.Len())
 	p.requestInfo.SetBytesReceived(bytesRecved)
+	p.onActive()
+	if p.clusterStats != nil {
+		p.clusterStats.UpstreamTCPProxyDownstreamBytes.Inc(int64(buffer.Len()))
+	}
 
 	p.upstreamConnection.Write(buffer.Clone())
 	buffer.Drain(buffer.Len())
@@ -121,30 +142,48 @@ func (p *proxy) initializeUpstreamConnection() types.FilterStatus {
 
 	ctx := &LbContext{
 		conn: p.readCallbacks,
+		ctx:  p.ctx,
 	}
-	connectionData := p.clusterManager.TCPConnForCluster(ctx, clusterSnapshot)
-	if connectionData.Connection == nil {
-		p.requestInfo.SetResponseFlag(types.NoHealthyUpstream)
-		p.onInitFailure(NoHealthyUpstream)
-
-		return types.Stop
-	}
-	p.readCallbacks.SetUpstreamHost(connectionData.HostInfo)
-	clusterConnectionResource.Increase()
-	upstreamConnection := connectionData.Connection
-	upstreamConnection.AddConnectionEventListener(p.upstreamCallbacks)
-	upstreamConnection.FilterManager().AddReadFilter(p.upstreamCallbacks)
-	p.upstreamConnection = upstreamConnection
-	if err := upstreamConnection.Connect(); err != nil {
-		p.requestInfo.SetResponseFlag(types.NoHealthyUpstream)
-		p.onInitFailure(NoHealthyUpstream)
-		return types.Stop
+	// the downstream data is read after the upstream connection is connected,
+	// so the stats and the idle timer are set before connecting
+	stats := clusterInfo.Stats()
+	p.clusterStats = &stats
+	p.startIdleTimer()
+
+	// the failed connection is retried by another host of the cluster
+	maxConnectAttempts := p.config.MaxConnectAttempts()
+	for attempt := uint32(1); ; attempt++ {
+		connectionData := p.clusterManager.TCPConnForCluster(ctx, clusterSnapshot)
+		if connectionData.Connection == nil {
+			p.requestInfo.SetResponseFlag(types.NoHealthyUpstream)
+			p.onInitFailure(NoHealthyUpstream)
+
+			return types.Stop
+		}
+		ctx.triedHosts = append(ctx.triedHosts, connectionData.HostInfo.AddressString())
+		p.readCallbacks.SetUpstreamHost(connectionData.HostInfo)
+		clusterConnectionResource.Increase()
+		p.upstreamResource = true
+		upstreamConnection := connectionData.Connection
+		upstreamConnection.AddConnectionEventListener(p.upstreamCallbacks)
+		upstreamConnection.FilterManager().AddReadFilter(p.upstreamCallbacks)
+		p.upstreamConnection = upstreamConnection
+		if err := upstreamConnection.Connect(); err == nil {
+			break
+		}
+		if attempt >= maxConnectAttempts {
+			p.requestInfo.SetResponseFlag(types.NoHealthyUpstream)
+			p.onInitFailure(NoHealthyUpstream)
+			return types.Stop
+		}
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[tcpproxy] connect to %s failed, retry %d of %d", connectionData.HostInfo.AddressString(), attempt, maxConnectAttempts)
+		}
+		clusterInfo.Stats().UpstreamConnectionRetry.Inc(1)
 	}
 
-	p.requestInfo.OnUpstreamHostSelected(connectionData.HostInfo)
-	p.requestInfo.SetUpstreamLocalAddress(upstreamConnection.LocalAddr())
-
-	// TODO: update upstream stats
+	p.requestInfo.OnUpstreamHostSelected(p.readCallbacks.UpstreamHost())
+	p.requestInfo.SetUpstreamLocalAddress(p.upstreamConnection.LocalAddr())
 
 	return types.Continue
 }
@@ -168,6 +207,10 @@ func (p *proxy) onUpstreamData(buffer types.IoBuffer) {
 	log.DefaultLogger.Tracef("Tcp Proxy :: read upstream data , len = %v", buffer.Len())
 	bytesSent := p.requestInfo.BytesSent() + uint64(buffer.Len())
 	p.requestInfo.SetBytesSent(bytesSent)
+	p.onActive()
+	if p.clusterStats != nil {
+		p.clusterStats.UpstreamTCPProxyUpstreamBytes.Inc(int64(buffer.Len()))
+	}
 
 	p.readCallbacks.Connection().Write(buffer.Clone())
 	buffer.Drain(buffer.Len())
@@ -186,18 +229,23 @@ func (p *proxy) onUpstreamEvent(event types.ConnectionEvent) {
 		p.readCallbacks.Connection().SetReadDisable(false)
 
 		p.onConnectionSuccess()
-	case types.ConnectTimeout:
+	case types.ConnectTimeout, types.ConnectFailed:
+		// the connection is retried by initializeUpstreamConnection
 		p.finalizeUpstreamConnectionStats()
 
 		p.requestInfo.SetResponseFlag(types.UpstreamConnectionFailure)
-		p.closeUpstreamConnection()
-		p.initializeUpstreamConnection()
-	case types.ConnectFailed:
-		p.requestInfo.SetResponseFlag(types.UpstreamConnectionFailure)
+		host := p.readCallbacks.UpstreamHost()
+		host.HostStats().UpstreamConnectionConFail.Inc(1)
+		host.ClusterInfo().Stats().UpstreamConnectionConFail.Inc(1)
 	}
 }
 
 func (p *proxy) finalizeUpstreamConnectionStats() {
+	// a failed connection is finalized by the connect event and the close event
+	if !p.upstreamResource {
+		return
+	}
+	p.upstreamResource = false
 	upstreamClusterInfo := p.readCallbacks.UpstreamHost().ClusterInfo()
 	upstreamClusterInfo.ResourceManager().Connections().Decrease()
 }
@@ -216,18 +264,75 @@ func (p *proxy) onDownstreamEvent(event types.ConnectionEvent) {
 	}
 
 	if event.IsClose() {
+		p.stopIdleTimer()
+		if p.clusterStats != nil {
+			duration := time.Since(p.requestInfo.StartTime()).Nanoseconds() / int64(time.Millisecond)
+			p.clusterStats.UpstreamTCPProxyConnectionDurationMs.Update(duration)
+		}
 		for _, al := range p.accessLogs {
 			al.Log(nil, nil, p.requestInfo)
 		}
 	}
 }
 
+func (p *proxy) onActive() {
+	atomic.StoreInt64(&p.lastActive, time.Now().UnixNano())
+}
+
+// startIdleTimer starts the idle timer if the idle timeout is configured,
+// the timer is reset to the remaining time if there are bytes in the period
+func (p *proxy) startIdleTimer() {
+	timeout := p.config.IdleTimeout()
+	if timeout <= 0 {
+		return
+	}
+	atomic.StoreInt64(&p.lastActive, time.Now().UnixNano())
+	p.idleMux.Lock()
+	defer p.idleMux.Unlock()
+	if p.idleStopped {
+		return
+	}
+	p.idleTimer = time.AfterFunc(timeout, func() {
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&p.lastActive)))
+		p.idleMux.Lock()
+		if p.idleStopped {
+			p.idleMux.Unlock()
+			return
+		}
+		if idle < timeout {
+			p.idleTimer.Reset(timeout - idle)
+			p.idleMux.Unlock()
+			return
+		}
+		p.idleMux.Unlock()
+		p.clusterStats.UpstreamTCPProxyIdleTimeout.Inc(1)
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[tcpproxy] connection %d is idle for %s, reset the connections", p.readCallbacks.Connection().ID(), idle)
+		}
+		// the upstream connection is closed by the downstream close event
+		p.readCallbacks.Connection().Close(types.NoFlush, types.LocalClose)
+	})
+}
+
+func (p *proxy) stopIdleTimer() {
+	p.idleMux.Lock()
+	defer p.idleMux.Unlock()
+	p.idleStopped = true
+	if p.idleTimer != nil {
+		p.idleTimer.Stop()
+	}
+}
+
+// ReadDisableUpstream stops reading the upstream while the downstream connection is backed up
 func (p *proxy) ReadDisableUpstream(disable bool) {
-	// TODO
+	if p.upstreamConnection != nil {
+		p.upstreamConnection.SetReadDisable(disable)
+	}
 }
 
+// ReadDisableDownstream stops reading the downstream while the upstream connection is backed up
 func (p *proxy) ReadDisableDownstream(disable bool) {
-	// TODO
+	p.readCallbacks.Connection().SetReadDisable(disable)
 }
 
 type proxyConfig struct {
@@ -344,6 +449,22 @@ func NewProxyConfig(config *v2.TCPProxy) ProxyConfig {
 	}
 }
 
+// IdleTimeout returns 0 if the idle timeout is not configured
+func (pc *proxyConfig) IdleTimeout() time.Duration {
+	if pc.idleTimeout == nil {
+		return 0
+	}
+	return *pc.idleTimeout
+}
+
+// MaxConnectAttempts is 1 at least, the connection is not retried by default
+func (pc *proxyConfig) MaxConnectAttempts() uint32 {
+	if pc.maxConnectAttempts == 0 {
+		return 1
+	}
+	return pc.maxConnectAttempts
+}
+
 func (pc *proxyConfig) GetRouteFromEntries(connection types.Connection) string {
 	if pc.cluster != "" {
 		log.DefaultLogger.Tracef("Tcp Proxy get cluster from config , cluster name = %v", pc.cluster)
@@ -383,6 +504,12 @@ func (uc *upstreamCallbacks) OnEvent(event types.ConnectionEvent) {
 	case types.Connected:
 		uc.proxy.upstreamConnection.SetNoDelay(true)
 		uc.proxy.upstreamConnection.SetReadDisable(false)
+	case types.OnAboveWriteBufferHighWatermark:
+		uc.proxy.ReadDisableDownstream(true)
+		return
+	case types.OnBelowWriteBufferLowWatermark:
+		uc.proxy.ReadDisableDownstream(false)
+		return
 	}
 
 	uc.proxy.onUpstreamEvent(event)
@@ -405,12 +532,22 @@ type downstreamCallbacks struct {
 }
 
 func (dc *downstreamCallbacks) OnEvent(event types.ConnectionEvent) {
-	dc.proxy.onDownstreamEvent(event)
+	switch event {
+	case types.OnAboveWriteBufferHighWatermark:
+		dc.proxy.ReadDisableUpstream(true)
+	case types.OnBelowWriteBufferLowWatermark:
+		dc.proxy.ReadDisableUpstream(false)
+	default:
+		dc.proxy.onDownstreamEvent(event)
+	}
 }
 
 // LbContext is a types.LoadBalancerContext implementation
 type LbContext struct {
 	conn types.ReadFilterCallbacks
+	ctx  context.Context
+	// triedHosts is the addresses of the hosts failed to connect
+	triedHosts []string
 }
 
 func (c *LbContext) MetadataMatchCriteria() types.MetadataMatchCriteria {
@@ -427,5 +564,19 @@ func (c *LbContext) DownstreamHeaders() types.HeaderMap {
 }
 
 func (c *LbContext) DownstreamContext() context.Context {
-	return nil
+	return c.ctx
+}
+
+// TCP Proxy have no hash policy
+func (c *LbContext) HashKey() (uint64, bool) {
+	return 0, false
+}
+
+func (c *LbContext) ShouldSelectAnotherHost(host types.Host) bool {
+	for _, addr := range c.triedHosts {
+		if addr == host.AddressString() {
+			return true
+		}
+	}
+	return false
 }
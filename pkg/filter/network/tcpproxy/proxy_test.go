@@ -1,10 +1,18 @@
 package tcpproxy
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"net"
 	"testing"
+	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
 )
 
 func Test_IpRangeList_Contains(t *testing.T) {
@@ -39,3 +47,206 @@ func Test_ParsePortRangeList(t *testing.T) {
 		t.Errorf("test  port range fail")
 	}
 }
+
+// newDownstream creates a downstream connection proxied by the tcp proxy, the client side is returned
+func newDownstream(t *testing.T, config *v2.TCPProxy, cm types.ClusterManager) net.Conn {
+	client, _ := newDownstreamWithWatermarks(t, config, cm, 0, 0)
+	return client
+}
+
+func newDownstreamWithWatermarks(t *testing.T, config *v2.TCPProxy, cm types.ClusterManager, low, high uint32) (net.Conn, *proxy) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawc, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyAccessLogs, []types.AccessLog{})
+	conn := network.NewServerConnection(ctx, rawc, nil)
+	if high > 0 {
+		conn.SetWriteBufferWatermarks(low, high)
+	}
+	p := NewProxy(ctx, config, cm)
+	conn.FilterManager().AddReadFilter(p)
+	conn.FilterManager().InitializeReadFilters()
+	conn.Start(ctx)
+	return client, p.(*proxy)
+}
+
+func addCluster(t *testing.T, cm types.ClusterManager, name string, addrs ...string) types.ClusterStats {
+	if err := cm.AddOrUpdatePrimaryCluster(v2.Cluster{Name: name, LbType: v2.LB_ROUNDROBIN}); err != nil {
+		t.Fatal(err)
+	}
+	var hosts []v2.Host
+	for _, addr := range addrs {
+		hosts = append(hosts, v2.Host{HostConfig: v2.HostConfig{Address: addr}})
+	}
+	if err := cm.UpdateClusterHosts(name, hosts); err != nil {
+		t.Fatal(err)
+	}
+	return cm.GetClusterSnapshot(context.Background(), name).ClusterInfo().Stats()
+}
+
+func TestProxyMaxConnectAttempts(t *testing.T) {
+	// the address is closed, so the connections are refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cm := cluster.NewClusterManagerSingleton(nil, nil)
+	clusterName := "tcp_proxy_refused"
+	stats := addCluster(t, cm, clusterName, addr)
+	defer cm.RemovePrimaryCluster(clusterName)
+
+	client := newDownstream(t, &v2.TCPProxy{Cluster: clusterName, MaxConnectAttempts: 3}, cm)
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the downstream closed, but got %v", err)
+	}
+	if stats.UpstreamConnectionRetry.Count() != 2 || stats.UpstreamConnectionConFail.Count() != 3 {
+		t.Errorf("expected 3 connect attempts, but got retry %d, connect fail %d",
+			stats.UpstreamConnectionRetry.Count(), stats.UpstreamConnectionConFail.Count())
+	}
+}
+
+func TestProxyIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(c, c)
+		}
+	}()
+
+	cm := cluster.NewClusterManagerSingleton(nil, nil)
+	clusterName := "tcp_proxy_echo"
+	stats := addCluster(t, cm, clusterName, ln.Addr().String())
+	defer cm.RemovePrimaryCluster(clusterName)
+
+	idleTimeout := 300 * time.Millisecond
+	client := newDownstream(t, &v2.TCPProxy{Cluster: clusterName, IdleTimeout: &idleTimeout}, cm)
+	defer client.Close()
+	buf := make([]byte, 5)
+	// the connection is kept while the bytes flow
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if _, err := client.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(client, buf); err != nil || string(buf) != "hello" {
+			t.Fatalf("expected the echo, but got %s, %v", buf, err)
+		}
+	}
+	if stats.UpstreamTCPProxyDownstreamBytes.Count() != 20 || stats.UpstreamTCPProxyUpstreamBytes.Count() != 20 {
+		t.Errorf("expected 20 bytes in both directions, but got downstream %d, upstream %d",
+			stats.UpstreamTCPProxyDownstreamBytes.Count(), stats.UpstreamTCPProxyUpstreamBytes.Count())
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(buf); err != io.EOF {
+		t.Fatalf("expected the idle connection closed, but got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stats.UpstreamTCPProxyIdleTimeout.Count() != 1 || stats.UpstreamTCPProxyConnectionDurationMs.Count() != 1 {
+		t.Errorf("expected the idle timeout and the duration recorded, but got idle timeout %d, duration %d",
+			stats.UpstreamTCPProxyIdleTimeout.Count(), stats.UpstreamTCPProxyConnectionDurationMs.Count())
+	}
+}
+
+func TestProxyStopIdleTimer(t *testing.T) {
+	idleTimeout := 50 * time.Millisecond
+	p := &proxy{
+		config: NewProxyConfig(&v2.TCPProxy{IdleTimeout: &idleTimeout}),
+	}
+	p.startIdleTimer()
+	// the timer is reset by the bytes until it is stopped, then it never resets the connection,
+	// which has no callbacks here
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		p.onActive()
+	}
+	p.stopIdleTimer()
+	time.Sleep(3 * idleTimeout)
+
+	// the timer is not started after stopped
+	p = &proxy{
+		config: NewProxyConfig(&v2.TCPProxy{IdleTimeout: &idleTimeout}),
+	}
+	p.stopIdleTimer()
+	p.startIdleTimer()
+	if p.idleTimer != nil {
+		t.Fatal("the stopped idle timer should not be started")
+	}
+}
+
+func TestProxyWriteBufferWatermarks(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the upstream writes as fast as the proxy reads once the downstream is started
+	total := int64(64 << 20)
+	started := make(chan struct{})
+	sent := make(chan int64, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		<-started
+		n, _ := io.CopyN(c, zeroReader{}, total)
+		sent <- n
+	}()
+
+	cm := cluster.NewClusterManagerSingleton(nil, nil)
+	clusterName := "tcp_proxy_watermarks"
+	addCluster(t, cm, clusterName, ln.Addr().String())
+	defer cm.RemovePrimaryCluster(clusterName)
+
+	client, p := newDownstreamWithWatermarks(t, &v2.TCPProxy{Cluster: clusterName}, cm, 0, 1<<20)
+	defer client.Close()
+	close(started)
+	// the downstream does not read, so the proxy stops reading the upstream
+	select {
+	case n := <-sent:
+		t.Fatalf("expected the upstream blocked, but %d bytes are sent", n)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if p.upstreamConnection.ReadEnabled() {
+		t.Fatal("expected the upstream read disabled")
+	}
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if n, err := io.CopyN(ioutil.Discard, client, total); err != nil || n != total {
+		t.Fatalf("expected %d bytes read, but got %d, %v", total, n, err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -18,6 +18,8 @@
 package tcpproxy
 
 import (
+	"time"
+
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -33,6 +35,12 @@ type Proxy interface {
 // ProxyConfig
 type ProxyConfig interface {
 	GetRouteFromEntries(connection types.Connection) string
+
+	// IdleTimeout is how long the connections are kept without bytes in both directions, 0 means no timeout
+	IdleTimeout() time.Duration
+
+	// MaxConnectAttempts is the max hosts tried to connect before the downstream connection is closed
+	MaxConnectAttempts() uint32
 }
 
 // UpstreamCallbacks for upstream's callbacks
@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpproxy
+
+import (
+	"context"
+	"net"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/filter"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func init() {
+	filter.RegisterNetwork(v2.UDP_PROXY, CreateUDPProxyFactory)
+}
+
+type udpProxyFilterConfigFactory struct {
+	Proxy *v2.UDPProxy
+}
+
+// CreateFilterChain adds no filter, the udp proxy works on the udp listeners only
+func (f *udpProxyFilterConfigFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	log.DefaultLogger.Errorf("[udpproxy] the udp proxy of cluster %s is not added to the tcp listener", f.Proxy.Cluster)
+}
+
+func (f *udpProxyFilterConfigFactory) CreateUDPFilter(context context.Context, clusterManager types.ClusterManager, conn net.PacketConn) types.UDPReadFilter {
+	return NewProxy(context, f.Proxy, clusterManager, conn)
+}
+
+func CreateUDPProxyFactory(conf map[string]interface{}) (types.NetworkFilterChainFactory, error) {
+	p, err := config.ParseUDPProxy(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &udpProxyFilterConfigFactory{
+		Proxy: p,
+	}, nil
+}
@@ -0,0 +1,316 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpproxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// DefaultIdleTimeout is the idle timeout of the sessions if it is not configured
+const DefaultIdleTimeout = 60 * time.Second
+
+// maxDatagramSize is the max size of a udp datagram
+const maxDatagramSize = 65535
+
+// datagramBufferPool reuses the read buffers of the ended sessions
+var datagramBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, maxDatagramSize)
+		return &buf
+	},
+}
+
+// proxy maps each downstream address of the listener to a session,
+// the session's upstream socket is connected to a host chosen by the cluster's load balancer
+type proxy struct {
+	cluster        string
+	idleTimeout    time.Duration
+	clusterManager types.ClusterManager
+	// conn is the listener's socket, the datagrams from the upstreams are written to the downstreams by it
+	conn          net.PacketConn
+	listenerStats *types.ListenerStats
+	accessLogs    []types.AccessLog
+
+	mutex    sync.Mutex
+	sessions map[string]*session
+	closed   bool
+}
+
+// NewProxy creates a udp proxy of the listener's socket
+func NewProxy(ctx context.Context, config *v2.UDPProxy, clusterManager types.ClusterManager, conn net.PacketConn) types.UDPReadFilter {
+	p := &proxy{
+		cluster:        config.Cluster,
+		idleTimeout:    DefaultIdleTimeout,
+		clusterManager: clusterManager,
+		conn:           conn,
+		sessions:       make(map[string]*session),
+	}
+	if config.IdleTimeout != nil && config.IdleTimeout.Duration > 0 {
+		p.idleTimeout = config.IdleTimeout.Duration
+	}
+	if stats, ok := mosnctx.Get(ctx, types.ContextKeyListenerStats).(*types.ListenerStats); ok {
+		p.listenerStats = stats
+	}
+	if als, ok := mosnctx.Get(ctx, types.ContextKeyAccessLogs).([]types.AccessLog); ok {
+		p.accessLogs = als
+	}
+	return p
+}
+
+func (p *proxy) OnData(data []byte, from net.Addr) {
+	if p.listenerStats != nil {
+		p.listenerStats.DownstreamBytesReadTotal.Inc(int64(len(data)))
+	}
+	s := p.getOrCreateSession(from)
+	if s == nil {
+		return
+	}
+	s.send(data)
+}
+
+// Close closes all the sessions, the datagrams received after it are dropped
+func (p *proxy) Close() {
+	p.mutex.Lock()
+	p.closed = true
+	sessions := make([]*session, 0, len(p.sessions))
+	for _, s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.mutex.Unlock()
+
+	for _, s := range sessions {
+		s.close()
+	}
+}
+
+func (p *proxy) getOrCreateSession(from net.Addr) *session {
+	key := from.String()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil
+	}
+	if s, ok := p.sessions[key]; ok {
+		return s
+	}
+	s := p.newSession(from)
+	if s != nil {
+		p.sessions[key] = s
+		s.start()
+	}
+	return s
+}
+
+// newSession chooses a host of the cluster, the datagram is dropped if the session is not created
+func (p *proxy) newSession(from net.Addr) *session {
+	snapshot := p.clusterManager.GetClusterSnapshot(context.Background(), p.cluster)
+	if snapshot == nil || snapshot.ClusterInfo() == nil {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[udpproxy] cluster %s is not found, drop the datagram from %s", p.cluster, from)
+		}
+		return nil
+	}
+	info := snapshot.ClusterInfo()
+	if !info.ResourceManager().Connections().CanCreate() {
+		info.Stats().UpstreamUDPDatagramsDropped.Inc(1)
+		return nil
+	}
+	data, err := p.clusterManager.UDPConnForCluster(&lbContext{from: from}, snapshot)
+	if err != nil {
+		info.Stats().UpstreamUDPDatagramsDropped.Inc(1)
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[udpproxy] create the session of %s to cluster %s failed: %v", from, p.cluster, err)
+		}
+		return nil
+	}
+	info.ResourceManager().Connections().Increase()
+	info.Stats().UpstreamUDPSessionTotal.Inc(1)
+	info.Stats().UpstreamUDPSessionActive.Inc(1)
+	return &session{
+		proxy:       p,
+		from:        from,
+		upstream:    data.Connection,
+		host:        data.HostInfo,
+		stats:       info.Stats(),
+		requestInfo: network.NewRequestInfo(),
+	}
+}
+
+func (p *proxy) removeSession(s *session) {
+	key := s.from.String()
+	p.mutex.Lock()
+	if p.sessions[key] == s {
+		delete(p.sessions, key)
+	}
+	p.mutex.Unlock()
+}
+
+// session forwards the datagrams between a downstream address and an upstream host
+type session struct {
+	proxy       *proxy
+	from        net.Addr
+	upstream    net.Conn
+	host        types.HostInfo
+	stats       types.ClusterStats
+	requestInfo types.RequestInfo
+	closeOnce   sync.Once
+	// the unix nano time of the last datagram in both directions, accessed atomically
+	lastActive int64
+	// the per session counters, accessed atomically
+	datagramsSent     uint64
+	datagramsReceived uint64
+	bytesSent         uint64
+	bytesReceived     uint64
+}
+
+func (s *session) start() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+	utils.GoWithRecover(s.readLoop, nil)
+}
+
+// send writes the datagram from the downstream to the upstream host
+func (s *session) send(data []byte) {
+	n, err := s.upstream.Write(data)
+	if err != nil {
+		s.stats.UpstreamUDPDatagramsDropped.Inc(1)
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[udpproxy] send the datagram from %s to %s failed: %v", s.from, s.host.AddressString(), err)
+		}
+		return
+	}
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+	atomic.AddUint64(&s.datagramsSent, 1)
+	atomic.AddUint64(&s.bytesSent, uint64(n))
+	s.stats.UpstreamUDPDatagramsSent.Inc(1)
+	s.stats.UpstreamBytesWriteTotal.Inc(int64(n))
+}
+
+// readLoop writes the datagrams from the upstream host to the downstream, it ends when the session is closed.
+// The read deadline is the idle timeout after the last datagram in both directions
+func (s *session) readLoop() {
+	bufPtr := datagramBufferPool.Get().(*[]byte)
+	defer datagramBufferPool.Put(bufPtr)
+	buf := *bufPtr
+	for {
+		s.upstream.SetReadDeadline(time.Unix(0, atomic.LoadInt64(&s.lastActive)).Add(s.proxy.idleTimeout))
+		n, err := s.upstream.Read(buf)
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			// the datagrams sent to the upstream keep the session active
+			if s.idle() {
+				s.onIdleTimeout()
+				return
+			}
+			continue
+		}
+		if err != nil {
+			// the session is closed, or the host is unreachable
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[udpproxy] session of %s to %s read failed: %v", s.from, s.host.AddressString(), err)
+			}
+			s.close()
+			return
+		}
+		atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+		if _, err := s.proxy.conn.WriteTo(buf[:n], s.from); err != nil {
+			s.stats.UpstreamUDPDatagramsDropped.Inc(1)
+			continue
+		}
+		atomic.AddUint64(&s.datagramsReceived, 1)
+		atomic.AddUint64(&s.bytesReceived, uint64(n))
+		s.stats.UpstreamUDPDatagramsReceived.Inc(1)
+		s.stats.UpstreamBytesReadTotal.Inc(int64(n))
+		if s.proxy.listenerStats != nil {
+			s.proxy.listenerStats.DownstreamBytesWriteTotal.Inc(int64(n))
+		}
+	}
+}
+
+func (s *session) idle() bool {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastActive))) >= s.proxy.idleTimeout
+}
+
+func (s *session) onIdleTimeout() {
+	s.stats.UpstreamUDPSessionIdleTimeout.Inc(1)
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[udpproxy] session of %s to %s is idle timeout", s.from, s.host.AddressString())
+	}
+	s.close()
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		s.upstream.Close()
+		s.proxy.removeSession(s)
+		s.host.ClusterInfo().ResourceManager().Connections().Decrease()
+		s.stats.UpstreamUDPSessionActive.Dec(1)
+
+		// a session is logged as a connection, the bytes received are the bytes from the downstream
+		s.requestInfo.SetDownstreamRemoteAddress(s.from)
+		s.requestInfo.SetDownstreamLocalAddress(s.proxy.conn.LocalAddr())
+		s.requestInfo.OnUpstreamHostSelected(s.host)
+		s.requestInfo.SetUpstreamLocalAddress(s.upstream.LocalAddr())
+		s.requestInfo.SetBytesReceived(atomic.LoadUint64(&s.bytesSent))
+		s.requestInfo.SetBytesSent(atomic.LoadUint64(&s.bytesReceived))
+		s.requestInfo.SetRequestFinishedDuration(time.Now())
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[udpproxy] session of %s to %s closed, datagrams sent: %d, received: %d",
+				s.from, s.host.AddressString(), atomic.LoadUint64(&s.datagramsSent), atomic.LoadUint64(&s.datagramsReceived))
+		}
+		for _, al := range s.proxy.accessLogs {
+			al.Log(nil, nil, s.requestInfo)
+		}
+	})
+}
+
+// lbContext is a types.LoadBalancerContext implementation of a udp session
+type lbContext struct {
+	from net.Addr
+}
+
+func (c *lbContext) MetadataMatchCriteria() types.MetadataMatchCriteria {
+	return nil
+}
+
+func (c *lbContext) DownstreamConnection() net.Conn {
+	return nil
+}
+
+// the datagrams have no header
+func (c *lbContext) DownstreamHeaders() types.HeaderMap {
+	return nil
+}
+
+func (c *lbContext) DownstreamContext() context.Context {
+	return nil
+}
+
+// the session is kept by the downstream address, so no hash key is needed
+func (c *lbContext) HashKey() (uint64, bool) {
+	return 0, false
+}
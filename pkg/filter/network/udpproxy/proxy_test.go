@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpproxy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+// startUDPEchoServer echoes the datagrams with the server's address as the prefix
+func startUDPEchoServer(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(append([]byte(conn.LocalAddr().String()+" "), buf[:n]...), from)
+		}
+	}()
+	return conn
+}
+
+func TestUDPProxy(t *testing.T) {
+	servers := []net.PacketConn{startUDPEchoServer(t), startUDPEchoServer(t)}
+	var hosts []v2.Host
+	for _, s := range servers {
+		defer s.Close()
+		hosts = append(hosts, v2.Host{HostConfig: v2.HostConfig{Address: s.LocalAddr().String()}})
+	}
+	cm := cluster.NewClusterManagerSingleton(nil, nil)
+	clusterName := "udp_proxy_cluster"
+	if err := cm.AddOrUpdatePrimaryCluster(v2.Cluster{Name: clusterName, LbType: v2.LB_RANDOM}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.UpdateClusterHosts(clusterName, hosts); err != nil {
+		t.Fatal(err)
+	}
+	defer cm.RemovePrimaryCluster(clusterName)
+	stats := cm.GetClusterSnapshot(context.Background(), clusterName).ClusterInfo().Stats()
+
+	// the listener's socket passes the datagrams to the proxy
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	p := NewProxy(context.Background(), &v2.UDPProxy{
+		Cluster:     clusterName,
+		IdleTimeout: &v2.DurationConfig{Duration: 300 * time.Millisecond},
+	}, cm, ln)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := ln.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			p.OnData(buf[:n], from)
+		}
+	}()
+
+	request := func(c net.Conn, data string) string {
+		if _, err := c.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 1024)
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatalf("read the response of %s failed: %v", data, err)
+		}
+		return string(buf[:n])
+	}
+	client, err := net.Dial("udp", ln.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// the datagrams of a downstream address are forwarded to the same host
+	first := request(client, "ping")
+	for i := 0; i < 5; i++ {
+		if resp := request(client, "ping"); resp != first {
+			t.Fatalf("expected the response from the same host %s, but got %s", first, resp)
+		}
+	}
+	if stats.UpstreamUDPSessionTotal.Count() != 1 || stats.UpstreamUDPSessionActive.Count() != 1 {
+		t.Errorf("expected 1 session, but got total %d, active %d", stats.UpstreamUDPSessionTotal.Count(), stats.UpstreamUDPSessionActive.Count())
+	}
+	if stats.UpstreamUDPDatagramsSent.Count() != 6 || stats.UpstreamUDPDatagramsReceived.Count() != 6 {
+		t.Errorf("expected 6 datagrams in both directions, but got sent %d, received %d",
+			stats.UpstreamUDPDatagramsSent.Count(), stats.UpstreamUDPDatagramsReceived.Count())
+	}
+
+	other, err := net.Dial("udp", ln.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	request(other, "ping")
+	if stats.UpstreamUDPSessionTotal.Count() != 2 || stats.UpstreamUDPSessionActive.Count() != 2 {
+		t.Errorf("expected 2 sessions, but got total %d, active %d", stats.UpstreamUDPSessionTotal.Count(), stats.UpstreamUDPSessionActive.Count())
+	}
+
+	// the active session is kept, the idle one expires
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		request(client, "ping")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stats.UpstreamUDPSessionIdleTimeout.Count() != 1 || stats.UpstreamUDPSessionActive.Count() != 1 {
+		t.Errorf("expected 1 idle session expired, but got idle timeout %d, active %d",
+			stats.UpstreamUDPSessionIdleTimeout.Count(), stats.UpstreamUDPSessionActive.Count())
+	}
+	// a new session is created after the idle timeout
+	request(other, "ping")
+	if stats.UpstreamUDPSessionTotal.Count() != 3 {
+		t.Errorf("expected 3 sessions, but got %d", stats.UpstreamUDPSessionTotal.Count())
+	}
+
+	p.Close()
+	time.Sleep(100 * time.Millisecond)
+	if stats.UpstreamUDPSessionActive.Count() != 0 {
+		t.Errorf("expected the sessions closed, but got %d", stats.UpstreamUDPSessionActive.Count())
+	}
+}
@@ -0,0 +1,295 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cors
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	headerOrigin                        = "Origin"
+	headerVary                          = "Vary"
+	headerAccessControlRequestMethod    = "Access-Control-Request-Method"
+	headerAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	headerAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	headerAccessControlMaxAge           = "Access-Control-Max-Age"
+	headerAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+)
+
+const preflightStatus = 204
+
+type corsConfig struct {
+	allowAnyOrigin      bool
+	allowOrigins        map[string]bool
+	allowOriginSuffixes []string
+	allowMethods        string
+	allowHeaders        string
+	exposeHeaders       string
+	maxAge              string
+	allowCredentials    bool
+	shadow              bool
+}
+
+func makeCorsConfig(cfg *v2.StreamCors) *corsConfig {
+	config := &corsConfig{
+		allowOrigins:        make(map[string]bool, len(cfg.AllowOrigins)),
+		allowOriginSuffixes: cfg.AllowOriginSuffixes,
+		allowMethods:        strings.Join(cfg.AllowMethods, ","),
+		allowHeaders:        strings.Join(cfg.AllowHeaders, ","),
+		exposeHeaders:       strings.Join(cfg.ExposeHeaders, ","),
+		allowCredentials:    cfg.AllowCredentials,
+		shadow:              cfg.ShadowEnabled,
+	}
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			config.allowAnyOrigin = true
+		}
+		config.allowOrigins[origin] = true
+	}
+	if cfg.MaxAge.Duration > 0 {
+		config.maxAge = strconv.FormatInt(int64(cfg.MaxAge.Seconds()), 10)
+	}
+	return config
+}
+
+func parseStreamCorsConfig(c interface{}) (*corsConfig, bool) {
+	conf, ok := c.(map[string]interface{})
+	if !ok {
+		log.DefaultLogger.Errorf("config is not stream cors, %v", c)
+		return nil, false
+	}
+	cfg, err := config.ParseStreamCorsFilter(conf)
+	if err != nil {
+		log.DefaultLogger.Errorf("config is not stream cors, %v", err)
+		return nil, false
+	}
+	return makeCorsConfig(cfg), true
+}
+
+func (c *corsConfig) allowOrigin(origin string) bool {
+	if c.allowAnyOrigin || c.allowOrigins[origin] {
+		return true
+	}
+	for _, suffix := range c.allowOriginSuffixes {
+		if matchOriginSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOriginSuffix matches the suffix at a label boundary of the origin's host, the scheme and the port are ignored.
+// "example.com" matches "https://example.com" and "https://a.example.com:8443", but not "https://evilexample.com"
+func matchOriginSuffix(origin, suffix string) bool {
+	if suffix == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if suffix[0] == '.' {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == suffix || strings.HasSuffix(host, "."+suffix)
+}
+
+// allowOriginValue returns * if any origin is allowed, the config allows any origin
+// with the credentials is rejected, as the browsers reject the * with the credentials
+func (c *corsConfig) allowOriginValue(origin string) string {
+	if c.allowAnyOrigin {
+		return "*"
+	}
+	return origin
+}
+
+// streamCorsFilter is an implement of types.StreamReceiverFilter and types.StreamSenderFilter.
+// the preflight requests are answered by the filter, and the access control headers are added to
+// the responses of the allowed origins
+type streamCorsFilter struct {
+	ctx            context.Context
+	config         *corsConfig
+	stats          types.Metrics
+	receiveHandler types.StreamReceiverFilterHandler
+	sendHandler    types.StreamSenderFilterHandler
+	// allowedOrigin is the origin of the allowed request that is not preflight
+	allowedOrigin string
+}
+
+func newFilter(ctx context.Context, cfg *corsConfig, stats types.Metrics) *streamCorsFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [cors] create a new cors filter")
+	}
+	return &streamCorsFilter{
+		ctx:    ctx,
+		config: cfg,
+		stats:  stats,
+	}
+}
+
+// ReadPerRouteConfig makes the route-level or the virtual host level configuration override filter-level configuration
+func (f *streamCorsFilter) ReadPerRouteConfig(route types.Route) {
+	rule := route.RouteRule()
+	cors, ok := rule.PerFilterConfig()[v2.Cors]
+	if !ok {
+		vh := rule.VirtualHost()
+		if vh == nil {
+			return
+		}
+		if cors, ok = vh.PerFilterConfig()[v2.Cors]; !ok {
+			return
+		}
+	}
+	if config, ok := parseStreamCorsConfig(cors); ok {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(f.ctx, "[stream filter] [cors] use router config to replace stream filter config, config: %v", cors)
+		}
+		f.config = config
+	}
+}
+
+func (f *streamCorsFilter) SetReceiveFilterHandler(handler types.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *streamCorsFilter) SetSenderFilterHandler(handler types.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *streamCorsFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if headers == nil {
+		return types.StreamFilterContinue
+	}
+	origin, ok := headers.Get(headerOrigin)
+	if !ok || origin == "" {
+		return types.StreamFilterContinue
+	}
+	if route := f.receiveHandler.Route(); route != nil {
+		f.ReadPerRouteConfig(route)
+	}
+	allowed := f.config.allowOrigin(origin)
+	if f.config.shadow {
+		if allowed {
+			f.stats.Counter(metrics.CorsShadowAllowed).Inc(1)
+		} else {
+			f.stats.Counter(metrics.CorsShadowDenied).Inc(1)
+		}
+		return types.StreamFilterContinue
+	}
+	if allowed {
+		f.stats.Counter(metrics.CorsAllowed).Inc(1)
+	} else {
+		f.stats.Counter(metrics.CorsDenied).Inc(1)
+	}
+	if !isPreflight(headers) {
+		if allowed {
+			f.allowedOrigin = origin
+		}
+		return types.StreamFilterContinue
+	}
+	f.stats.Counter(metrics.CorsPreflight).Inc(1)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [cors] answer the preflight request, origin: %s, allowed: %v", origin, allowed)
+	}
+	f.sendPreflightResponse(headers, origin, allowed)
+	return types.StreamFilterStop
+}
+
+func isPreflight(headers types.HeaderMap) bool {
+	method, _ := headers.Get(types.HeaderMethod)
+	if method != "OPTIONS" {
+		return false
+	}
+	requestMethod, ok := headers.Get(headerAccessControlRequestMethod)
+	return ok && requestMethod != ""
+}
+
+// sendPreflightResponse answers the preflight request without the access control headers if the origin is not allowed,
+// so the browser rejects the actual request
+func (f *streamCorsFilter) sendPreflightResponse(headers types.HeaderMap, origin string, allowed bool) {
+	if _, ok := headers.(mosnhttp.RequestHeader); !ok {
+		// the other protocols only support the hijack reply by the request headers
+		if allowed {
+			f.setPreflightHeaders(headers, origin)
+		}
+		f.receiveHandler.SendHijackReply(preflightStatus, headers)
+		return
+	}
+	response := mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	response.SetStatusCode(preflightStatus)
+	if allowed {
+		f.setPreflightHeaders(response, origin)
+	}
+	f.receiveHandler.SendDirectResponse(response, nil, nil)
+}
+
+func (f *streamCorsFilter) setPreflightHeaders(headers types.HeaderMap, origin string) {
+	f.setAllowOriginHeaders(headers, origin)
+	if f.config.allowMethods != "" {
+		headers.Set(headerAccessControlAllowMethods, f.config.allowMethods)
+	}
+	if f.config.allowHeaders != "" {
+		headers.Set(headerAccessControlAllowHeaders, f.config.allowHeaders)
+	}
+	if f.config.maxAge != "" {
+		headers.Set(headerAccessControlMaxAge, f.config.maxAge)
+	}
+}
+
+func (f *streamCorsFilter) setAllowOriginHeaders(headers types.HeaderMap, origin string) {
+	value := f.config.allowOriginValue(origin)
+	headers.Set(headerAccessControlAllowOrigin, value)
+	if value != "*" {
+		// the response varies by the origin
+		if vary, ok := headers.Get(headerVary); !ok || vary == "" {
+			headers.Set(headerVary, headerOrigin)
+		} else if !strings.Contains(strings.ToLower(vary), "origin") {
+			headers.Set(headerVary, vary+", "+headerOrigin)
+		}
+	}
+	if f.config.allowCredentials {
+		headers.Set(headerAccessControlAllowCredentials, "true")
+	}
+}
+
+func (f *streamCorsFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if f.allowedOrigin == "" || headers == nil {
+		return types.StreamFilterContinue
+	}
+	f.setAllowOriginHeaders(headers, f.allowedOrigin)
+	if f.config.exposeHeaders != "" {
+		headers.Set(headerAccessControlExposeHeaders, f.config.exposeHeaders)
+	}
+	return types.StreamFilterContinue
+}
+
+func (f *streamCorsFilter) OnDestroy() {}
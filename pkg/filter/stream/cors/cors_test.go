@@ -0,0 +1,314 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// only implement the function that used in test
+type mockFilterChainFactoryCallbacks struct {
+	types.StreamFilterChainFactoryCallbacks
+	receiver types.StreamReceiverFilter
+	sender   types.StreamSenderFilter
+}
+
+func (cb *mockFilterChainFactoryCallbacks) AddStreamReceiverFilter(filter types.StreamReceiverFilter, p types.Phase) {
+	cb.receiver = filter
+}
+
+func (cb *mockFilterChainFactoryCallbacks) AddStreamSenderFilter(filter types.StreamSenderFilter) {
+	cb.sender = filter
+}
+
+type mockStreamReceiverFilterHandler struct {
+	types.StreamReceiverFilterHandler
+	route       types.Route
+	hijackCode  int
+	respHeaders types.HeaderMap
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() types.Route {
+	return h.route
+}
+
+func (h *mockStreamReceiverFilterHandler) SendHijackReply(code int, headers types.HeaderMap) {
+	h.hijackCode = code
+	h.respHeaders = headers
+}
+
+func (h *mockStreamReceiverFilterHandler) SendDirectResponse(headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) {
+	h.respHeaders = headers
+}
+
+type mockRoute struct {
+	types.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() types.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	types.RouteRule
+	config map[string]interface{}
+	vh     *mockVirtualHost
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+func (r *mockRouteRule) VirtualHost() types.VirtualHost {
+	return r.vh
+}
+
+type mockVirtualHost struct {
+	types.VirtualHost
+	config map[string]interface{}
+}
+
+func (vh *mockVirtualHost) PerFilterConfig() map[string]interface{} {
+	return vh.config
+}
+
+var testConfig = map[string]interface{}{
+	"allow_origins":         []interface{}{"http://example.com"},
+	"allow_origin_suffixes": []interface{}{".example.org"},
+	"allow_methods":         []interface{}{"GET", "POST"},
+	"allow_headers":         []interface{}{"content-type"},
+	"expose_headers":        []interface{}{"x-request-id"},
+	"max_age":               "10m",
+	"allow_credentials":     true,
+}
+
+func createFilter(t *testing.T, conf map[string]interface{}) (*mockFilterChainFactoryCallbacks, *mockStreamReceiverFilterHandler) {
+	factory, err := CreateCorsFilterFactory(conf)
+	if err != nil {
+		t.Fatalf("create cors filter factory failed: %v", err)
+	}
+	cb := &mockFilterChainFactoryCallbacks{}
+	factory.CreateFilterChain(context.Background(), cb)
+	handler := &mockStreamReceiverFilterHandler{}
+	cb.receiver.SetReceiveFilterHandler(handler)
+	return cb, handler
+}
+
+func TestCorsPreflight(t *testing.T) {
+	cb, handler := createFilter(t, testConfig)
+	stats := metrics.NewCorsStats()
+	preflight := stats.Counter(metrics.CorsPreflight).Count()
+
+	request := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	request.Set(types.HeaderMethod, "OPTIONS")
+	request.Set("Origin", "http://api.example.org")
+	request.Set("Access-Control-Request-Method", "POST")
+	if status := cb.receiver.OnReceive(context.Background(), request, nil, nil); status != types.StreamFilterStop {
+		t.Fatalf("preflight request should be stopped")
+	}
+	resp, ok := handler.respHeaders.(mosnhttp.ResponseHeader)
+	if !ok || resp.StatusCode() != preflightStatus {
+		t.Fatalf("preflight response is not expected: %v", handler.respHeaders)
+	}
+	for k, v := range map[string]string{
+		"Access-Control-Allow-Origin":      "http://api.example.org",
+		"Access-Control-Allow-Methods":     "GET,POST",
+		"Access-Control-Allow-Headers":     "content-type",
+		"Access-Control-Max-Age":           "600",
+		"Access-Control-Allow-Credentials": "true",
+		"Vary":                             "Origin",
+	} {
+		if value, _ := resp.Get(k); value != v {
+			t.Errorf("header %s expected %s, but got %s", k, v, value)
+		}
+	}
+	if c := stats.Counter(metrics.CorsPreflight).Count(); c != preflight+1 {
+		t.Errorf("preflight counter is not increased")
+	}
+	// the direct response passes through the sender filters without changes
+	response := protocol.CommonHeader{}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if len(response) != 0 {
+		t.Errorf("preflight response should not be changed: %v", response)
+	}
+}
+
+func TestCorsOriginSuffix(t *testing.T) {
+	c := makeCorsConfig(&v2.StreamCors{AllowOriginSuffixes: []string{".example.org", "example.com"}})
+	for origin, allowed := range map[string]bool{
+		"http://api.example.org":  true,
+		"http://example.org":      false,
+		"http://evilexample.org":  false,
+		"https://example.com":     true,
+		"https://api.example.com": true,
+		"https://evilexample.com": false,
+		// the port is not a part of the host
+		"https://a.example.com:8443": true,
+		"http://api.example.org:80":  true,
+		"https://evilexample.com:80": false,
+		"https://example.com.evil":   false,
+		"null":                       false,
+	} {
+		if c.allowOrigin(origin) != allowed {
+			t.Errorf("origin %s expected allowed %v", origin, allowed)
+		}
+	}
+}
+
+func TestCorsAnyOriginWithCredentials(t *testing.T) {
+	if _, err := CreateCorsFilterFactory(map[string]interface{}{
+		"allow_origins":     []interface{}{"*"},
+		"allow_credentials": true,
+	}); err == nil {
+		t.Fatal("expected any origin with the credentials rejected")
+	}
+}
+
+func TestCorsPreflightDenied(t *testing.T) {
+	cb, handler := createFilter(t, testConfig)
+	request := protocol.CommonHeader{
+		types.HeaderMethod:              "OPTIONS",
+		"Origin":                        "http://evil.com",
+		"Access-Control-Request-Method": "GET",
+	}
+	if status := cb.receiver.OnReceive(context.Background(), request, nil, nil); status != types.StreamFilterStop {
+		t.Fatalf("preflight request should be stopped")
+	}
+	if handler.hijackCode != preflightStatus {
+		t.Errorf("preflight should be hijacked with %d, but got %d", preflightStatus, handler.hijackCode)
+	}
+	if _, ok := request.Get("Access-Control-Allow-Origin"); ok {
+		t.Errorf("denied preflight should not have the access control headers")
+	}
+}
+
+func TestCorsActualRequest(t *testing.T) {
+	cb, _ := createFilter(t, map[string]interface{}{
+		"allow_origins":  []interface{}{"*"},
+		"expose_headers": []interface{}{"x-request-id", "x-trace-id"},
+	})
+	request := protocol.CommonHeader{types.HeaderMethod: "GET", "Origin": "http://any.com"}
+	if status := cb.receiver.OnReceive(context.Background(), request, nil, nil); status != types.StreamFilterContinue {
+		t.Fatalf("actual request should be continued")
+	}
+	response := protocol.CommonHeader{}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if v, _ := response.Get("Access-Control-Allow-Origin"); v != "*" {
+		t.Errorf("allow origin expected *, but got %s", v)
+	}
+	if v, _ := response.Get("Access-Control-Expose-Headers"); v != "x-request-id,x-trace-id" {
+		t.Errorf("expose headers is not expected: %s", v)
+	}
+	if _, ok := response.Get("Vary"); ok {
+		t.Errorf("response with any origin should not vary by origin")
+	}
+	// no origin, no cors
+	cb, _ = createFilter(t, testConfig)
+	cb.receiver.OnReceive(context.Background(), protocol.CommonHeader{types.HeaderMethod: "GET"}, nil, nil)
+	response = protocol.CommonHeader{"Vary": "Accept-Encoding"}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if len(response) != 1 {
+		t.Errorf("response without origin should not be changed: %v", response)
+	}
+}
+
+func TestCorsVaryAppend(t *testing.T) {
+	cb, _ := createFilter(t, testConfig)
+	cb.receiver.OnReceive(context.Background(), protocol.CommonHeader{"Origin": "http://example.com"}, nil, nil)
+	response := protocol.CommonHeader{"Vary": "Accept-Encoding"}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if v, _ := response.Get("Vary"); v != "Accept-Encoding, Origin" {
+		t.Errorf("vary is not expected: %s", v)
+	}
+}
+
+func TestCorsShadow(t *testing.T) {
+	conf := map[string]interface{}{
+		"allow_origins":  []interface{}{"http://example.com"},
+		"shadow_enabled": true,
+	}
+	cb, handler := createFilter(t, conf)
+	stats := metrics.NewCorsStats()
+	allowed := stats.Counter(metrics.CorsShadowAllowed).Count()
+	denied := stats.Counter(metrics.CorsShadowDenied).Count()
+
+	request := protocol.CommonHeader{
+		types.HeaderMethod:              "OPTIONS",
+		"Origin":                        "http://evil.com",
+		"Access-Control-Request-Method": "GET",
+	}
+	if status := cb.receiver.OnReceive(context.Background(), request, nil, nil); status != types.StreamFilterContinue {
+		t.Fatalf("shadow mode should not stop the preflight")
+	}
+	if handler.hijackCode != 0 || len(request) != 3 {
+		t.Errorf("shadow mode should not change the request")
+	}
+	cb, _ = createFilter(t, conf)
+	cb.receiver.OnReceive(context.Background(), protocol.CommonHeader{"Origin": "http://example.com"}, nil, nil)
+	response := protocol.CommonHeader{}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if len(response) != 0 {
+		t.Errorf("shadow mode should not change the response: %v", response)
+	}
+	if stats.Counter(metrics.CorsShadowAllowed).Count() != allowed+1 || stats.Counter(metrics.CorsShadowDenied).Count() != denied+1 {
+		t.Errorf("shadow counters are not expected")
+	}
+}
+
+func TestCorsVirtualHostConfig(t *testing.T) {
+	cb, handler := createFilter(t, testConfig)
+	rule := &mockRouteRule{
+		vh: &mockVirtualHost{
+			config: map[string]interface{}{
+				v2.Cors: map[string]interface{}{
+					"allow_origins": []interface{}{"http://vhost.com"},
+				},
+			},
+		},
+	}
+	handler.route = &mockRoute{rule: rule}
+	cb.receiver.OnReceive(context.Background(), protocol.CommonHeader{"Origin": "http://example.com"}, nil, nil)
+	response := protocol.CommonHeader{}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if len(response) != 0 {
+		t.Errorf("origin should be denied by the virtual host config: %v", response)
+	}
+	// the route config overrides the virtual host config
+	cb, handler = createFilter(t, testConfig)
+	rule.config = map[string]interface{}{
+		v2.Cors: map[string]interface{}{
+			"allow_origins": []interface{}{"http://route.com"},
+		},
+	}
+	handler.route = &mockRoute{rule: rule}
+	cb.receiver.OnReceive(context.Background(), protocol.CommonHeader{"Origin": "http://route.com"}, nil, nil)
+	response = protocol.CommonHeader{}
+	cb.sender.Append(context.Background(), response, nil, nil)
+	if v, _ := response.Get("Access-Control-Allow-Origin"); v != "http://route.com" {
+		t.Errorf("origin should be allowed by the route config: %v", response)
+	}
+}
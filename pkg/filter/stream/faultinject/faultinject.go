@@ -20,11 +20,14 @@ package faultinject
 import (
 	"context"
 	"math/rand"
+	"strconv"
 	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/config"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/router"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"github.com/json-iterator/go"
@@ -34,18 +37,21 @@ var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 // faultInjectConfig is parsed from v2.StreamFaultInject
 type faultInjectConfig struct {
-	fixedDelay   time.Duration
-	delayPercent uint32
-	abortStatus  int
-	abortPercent uint32
-	upstream     string
-	headers      []*types.HeaderData
+	fixedDelay     time.Duration
+	delayPercent   uint32
+	abortStatus    int
+	abortRPCStatus int16
+	abortPercent   uint32
+	upstream       string
+	headers        []*types.HeaderData
+	deterministic  bool
 }
 
 func makefaultInjectConfig(cfg *v2.StreamFaultInject) *faultInjectConfig {
 	faultConfig := &faultInjectConfig{
-		upstream: cfg.UpstreamCluster,
-		headers:  router.GetRouterHeaders(cfg.Headers),
+		upstream:      cfg.UpstreamCluster,
+		headers:       router.GetRouterHeaders(cfg.Headers),
+		deterministic: cfg.Deterministic,
 	}
 	if cfg.Delay != nil {
 		faultConfig.fixedDelay = cfg.Delay.Delay
@@ -53,6 +59,7 @@ func makefaultInjectConfig(cfg *v2.StreamFaultInject) *faultInjectConfig {
 	}
 	if cfg.Abort != nil {
 		faultConfig.abortStatus = cfg.Abort.Status
+		faultConfig.abortRPCStatus = cfg.Abort.RPCStatus
 		faultConfig.abortPercent = cfg.Abort.Percent
 	}
 	return faultConfig
@@ -84,6 +91,9 @@ type streamFaultInjectFilter struct {
 	stop    chan struct{}
 	rander  *rand.Rand
 	headers types.HeaderMap
+	// streamID is used to hit the percentages if the config is deterministic
+	streamID    uint64
+	hasStreamID bool
 }
 
 func NewFilter(ctx context.Context, cfg *v2.StreamFaultInject) types.StreamReceiverFilter {
@@ -141,12 +151,16 @@ func (f *streamFaultInjectFilter) OnReceive(ctx context.Context, headers types.H
 		}
 		return types.StreamFilterContinue
 	}
+	if f.config.deterministic {
+		f.streamID, f.hasStreamID = protocol.StreamIDByContext(ctx)
+	}
 	// TODO: some parameters can get from request header
 	if delay := f.getDelayDuration(); delay > 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] start a delay timer")
 		}
 		f.handler.RequestInfo().SetResponseFlag(types.DelayInjected)
+		metrics.NewFaultInjectStats().Counter(metrics.FaultInjectDelayed).Inc(1)
 		select {
 		case <-time.After(delay):
 		case <-f.stop:
@@ -193,7 +207,7 @@ func (f *streamFaultInjectFilter) getDelayDuration() time.Duration {
 		return 0
 	}
 	// rander generates 0~99, if greater than percent means no delay
-	if (f.rander.Uint32() % 100) >= f.config.delayPercent {
+	if !f.percentHit(f.config.delayPercent, delaySalt) {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] delay percent is not matched")
 		}
@@ -210,7 +224,7 @@ func (f *streamFaultInjectFilter) isAbort() bool {
 		}
 		return false
 	}
-	if (f.rander.Uint32() % 100) >= f.config.abortPercent {
+	if !f.percentHit(f.config.abortPercent, abortSalt) {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] abort percent is not matched")
 		}
@@ -225,5 +239,37 @@ func (f *streamFaultInjectFilter) abort(headers types.HeaderMap) {
 		log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] abort inject")
 	}
 	f.handler.RequestInfo().SetResponseFlag(types.FaultInjected)
+	metrics.NewFaultInjectStats().Counter(metrics.FaultInjectAborted).Inc(1)
+	if f.config.abortRPCStatus != 0 && headers != nil && f.handler.RequestInfo().Protocol() == protocol.SofaRPC {
+		headers.Set(types.HeaderRPCStatus, strconv.Itoa(int(f.config.abortRPCStatus)))
+	}
 	f.handler.SendHijackReply(f.config.abortStatus, headers)
 }
+
+// the salts of the deterministic draws, so the delay and the abort of a stream are drawn independently
+const (
+	delaySalt byte = iota + 1
+	abortSalt
+)
+
+// percentHit returns true if the request hits the percent.
+// a deterministic config uses the hash of the stream id and the salt, so the requests with the same stream id
+// get the same result for the same salt
+func (f *streamFaultInjectFilter) percentHit(percent uint32, salt byte) bool {
+	if f.config.deterministic && f.hasStreamID {
+		return streamIDHash(f.streamID, salt)%100 < percent
+	}
+	return (f.rander.Uint32() % 100) < percent
+}
+
+// fnv-1a hash of the salt and the stream id, the stream ids are sequential, so they are hashed before the percentages
+func streamIDHash(id uint64, salt byte) uint32 {
+	h := uint32(2166136261)
+	h ^= uint32(salt)
+	h *= 16777619
+	for i := uint(0); i < 64; i += 8 {
+		h ^= uint32(byte(id >> i))
+		h *= 16777619
+	}
+	return h
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -386,3 +387,102 @@ func TestFaultInject_RouteConfigOverride(t *testing.T) {
 		t.Error("timeout")
 	}
 }
+
+// Deterministic percent should returns the same result for the same stream id
+func TestDeterministicPercent(t *testing.T) {
+	f := &streamFaultInjectFilter{
+		config: &faultInjectConfig{
+			abortPercent:  30,
+			deterministic: true,
+		},
+		rander:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		hasStreamID: true,
+	}
+	hint := 0
+	testCount := 100000
+	for i := 0; i < testCount; i++ {
+		f.streamID = uint64(i)
+		abort := f.isAbort()
+		for j := 0; j < 3; j++ {
+			if f.isAbort() != abort {
+				t.Fatalf("stream id %d got different results", i)
+			}
+		}
+		if abort {
+			hint++
+		}
+	}
+	// errors range in 5%
+	if ratio := float32(hint) / float32(testCount); ratio < 0.25 || ratio > 0.35 {
+		t.Errorf("percent 30's error range is not expected, hint count %d", hint)
+	}
+}
+
+// Deterministic delay and abort should be drawn independently
+func TestDeterministicIndependent(t *testing.T) {
+	f := &streamFaultInjectFilter{
+		config: &faultInjectConfig{
+			delayPercent:  50,
+			fixedDelay:    time.Second,
+			abortPercent:  50,
+			deterministic: true,
+		},
+		hasStreamID: true,
+	}
+	both := 0
+	testCount := 100000
+	for i := 0; i < testCount; i++ {
+		f.streamID = uint64(i)
+		if f.getDelayDuration() > 0 && f.isAbort() {
+			both++
+		}
+	}
+	// the independent draws hit both in 25%
+	if ratio := float32(both) / float32(testCount); ratio < 0.2 || ratio > 0.3 {
+		t.Errorf("delay and abort are not independent, both hint count %d", both)
+	}
+}
+
+func TestFaultInject_AbortRPCStatus(t *testing.T) {
+	cfg := &v2.StreamFaultInject{
+		Abort: &v2.AbortInject{
+			Percent:   100,
+			Status:    500,
+			RPCStatus: 16,
+		},
+	}
+	aborted := metrics.NewFaultInjectStats().Counter(metrics.FaultInjectAborted)
+	for _, tc := range []struct {
+		protocol types.Protocol
+		expected string
+	}{
+		{protocol.SofaRPC, "16"},
+		{protocol.HTTP1, ""},
+	} {
+		cb := &mockStreamReceiverFilterCallbacks{
+			info: &mockRequestInfo{
+				protocol: tc.protocol,
+			},
+			route: &mockRoute{
+				rule: &mockRouteRule{},
+			},
+			called: make(chan int, 1),
+		}
+		f := NewFilter(context.Background(), cfg)
+		f.SetReceiveFilterHandler(cb)
+		headers := protocol.CommonHeader(map[string]string{})
+		before := aborted.Count()
+		if status := f.OnReceive(context.TODO(), headers, nil, nil); status != types.StreamFilterStop {
+			t.Fatal("fault inject should matched")
+		}
+		if cb.hijackCode != 500 || cb.info.flag != types.FaultInjected {
+			t.Errorf("%s abort not expected, code: %d", tc.protocol, cb.hijackCode)
+		}
+		if status, _ := headers.Get(types.HeaderRPCStatus); status != tc.expected {
+			t.Errorf("%s rpc status expected %s, but got %s", tc.protocol, tc.expected, status)
+		}
+		if aborted.Count() != before+1 {
+			t.Errorf("%s aborts are not counted", tc.protocol)
+		}
+	}
+}
@@ -69,7 +69,12 @@ func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
 
 type mockRequestInfo struct {
 	types.RequestInfo
-	flag types.ResponseFlag
+	flag     types.ResponseFlag
+	protocol types.Protocol
+}
+
+func (info *mockRequestInfo) Protocol() types.Protocol {
+	return info.protocol
 }
 
 func (info *mockRequestInfo) SetResponseFlag(flag types.ResponseFlag) {
@@ -0,0 +1,232 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gzip
+
+import (
+	"compress/gzip"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+	headerContentType     = "Content-Type"
+	headerCacheControl    = "Cache-Control"
+	headerVary            = "Vary"
+)
+
+const (
+	defaultMinContentLength = 30
+	// defaultMaxContentLength bounds the buffer of the compressed body
+	defaultMaxContentLength = 1 << 20
+)
+
+var defaultContentTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/xml",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+}
+
+// gzipWriterPools are the pools of the gzip writers indexed by the level
+var gzipWriterPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+
+func getGzipWriter(level int) *gzip.Writer {
+	if w, ok := gzipWriterPools[level-gzip.HuffmanOnly].Get().(*gzip.Writer); ok {
+		return w
+	}
+	// the level is checked by the config parser
+	w, _ := gzip.NewWriterLevel(nil, level)
+	return w
+}
+
+func putGzipWriter(level int, w *gzip.Writer) {
+	w.Reset(nil)
+	gzipWriterPools[level-gzip.HuffmanOnly].Put(w)
+}
+
+type gzipConfig struct {
+	minContentLength int
+	maxContentLength int
+	contentTypes     []string
+	level            int
+}
+
+func makeGzipConfig(cfg *v2.StreamGzip) *gzipConfig {
+	config := &gzipConfig{
+		minContentLength: cfg.MinContentLength,
+		maxContentLength: cfg.MaxContentLength,
+		contentTypes:     cfg.ContentTypes,
+		level:            gzip.DefaultCompression,
+	}
+	if config.minContentLength == 0 {
+		config.minContentLength = defaultMinContentLength
+	}
+	if config.maxContentLength == 0 {
+		config.maxContentLength = defaultMaxContentLength
+	}
+	if len(config.contentTypes) == 0 {
+		config.contentTypes = defaultContentTypes
+	}
+	if cfg.CompressionLevel != nil {
+		config.level = *cfg.CompressionLevel
+	}
+	return config
+}
+
+// matchContentType matches the media type of the content type, the parameters such as charset are ignored
+func (c *gzipConfig) matchContentType(contentType string) bool {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(contentType)
+	for _, t := range c.contentTypes {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptGzip returns true if the accept encoding contains gzip or *, and the q value is not zero
+func acceptGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(coding, ";")
+		name := strings.TrimSpace(params[0])
+		if !strings.EqualFold(name, "gzip") && name != "*" {
+			continue
+		}
+		accept := true
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil && q == 0 {
+					accept = false
+				}
+			}
+		}
+		return accept
+	}
+	return false
+}
+
+// streamGzipFilter is an implement of types.StreamReceiverFilter and types.StreamSenderFilter.
+// the proxy sends the whole response body to the sender filters, so the body is compressed
+// into a pooled buffer by a pooled writer. the body larger than the max content length is not compressed,
+// so the compressed buffer is bounded
+type streamGzipFilter struct {
+	ctx            context.Context
+	config         *gzipConfig
+	stats          types.Metrics
+	receiveHandler types.StreamReceiverFilterHandler
+	sendHandler    types.StreamSenderFilterHandler
+	acceptGzip     bool
+}
+
+func newFilter(ctx context.Context, cfg *gzipConfig, stats types.Metrics) *streamGzipFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [gzip] create a new gzip filter")
+	}
+	return &streamGzipFilter{
+		ctx:    ctx,
+		config: cfg,
+		stats:  stats,
+	}
+}
+
+func (f *streamGzipFilter) SetReceiveFilterHandler(handler types.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *streamGzipFilter) SetSenderFilterHandler(handler types.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *streamGzipFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if headers != nil {
+		if acceptEncoding, ok := headers.Get(headerAcceptEncoding); ok {
+			f.acceptGzip = acceptGzip(acceptEncoding)
+		}
+	}
+	return types.StreamFilterContinue
+}
+
+func (f *streamGzipFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if !f.shouldCompress(headers, buf) {
+		f.stats.Counter(metrics.GzipNotCompressed).Inc(1)
+		return types.StreamFilterContinue
+	}
+	out := buffer.GetIoBuffer(buf.Len())
+	defer buffer.PutIoBuffer(out)
+	w := getGzipWriter(f.config.level)
+	w.Reset(out)
+	_, err := w.Write(buf.Bytes())
+	if err == nil {
+		err = w.Close()
+	}
+	putGzipWriter(f.config.level, w)
+	if err != nil || out.Len() >= buf.Len() {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter] [gzip] response is not compressed, error: %v, size: %d, compressed size: %d", err, buf.Len(), out.Len())
+		}
+		f.stats.Counter(metrics.GzipNotCompressed).Inc(1)
+		return types.StreamFilterContinue
+	}
+	f.stats.Counter(metrics.GzipCompressed).Inc(1)
+	f.stats.Counter(metrics.GzipBytesSaved).Inc(int64(buf.Len() - out.Len()))
+
+	headers.Set(headerContentEncoding, "gzip")
+	headers.Del(headerContentLength)
+	if vary, ok := headers.Get(headerVary); !ok || vary == "" {
+		headers.Set(headerVary, headerAcceptEncoding)
+	} else if !strings.Contains(strings.ToLower(vary), "accept-encoding") {
+		headers.Set(headerVary, vary+", "+headerAcceptEncoding)
+	}
+	f.sendHandler.SetResponseData(out)
+	return types.StreamFilterContinue
+}
+
+func (f *streamGzipFilter) shouldCompress(headers types.HeaderMap, buf types.IoBuffer) bool {
+	if !f.acceptGzip || headers == nil || buf == nil || buf.Len() < f.config.minContentLength || buf.Len() > f.config.maxContentLength {
+		return false
+	}
+	// already encoded
+	if encoding, ok := headers.Get(headerContentEncoding); ok && encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	if cacheControl, ok := headers.Get(headerCacheControl); ok && strings.Contains(strings.ToLower(cacheControl), "no-transform") {
+		return false
+	}
+	contentType, _ := headers.Get(headerContentType)
+	return f.config.matchContentType(contentType)
+}
+
+func (f *streamGzipFilter) OnDestroy() {}
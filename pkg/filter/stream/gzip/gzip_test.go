@@ -0,0 +1,170 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// only implement the function that used in test
+type mockStreamSenderFilterHandler struct {
+	types.StreamSenderFilterHandler
+	data types.IoBuffer
+}
+
+func (h *mockStreamSenderFilterHandler) SetResponseData(data types.IoBuffer) {
+	h.data.Reset()
+	h.data.ReadFrom(data)
+}
+
+func newResponseHeader(kvs map[string]string) mosnhttp.ResponseHeader {
+	header := mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	for k, v := range kvs {
+		header.Set(k, v)
+	}
+	return header
+}
+
+func TestAcceptGzip(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"gzip":                 true,
+		"GZIP":                 true,
+		"deflate, gzip;q=1.0":  true,
+		"br, *":                true,
+		"gzip;q=0":             false,
+		"deflate, gzip; q=0.0": false,
+		"deflate, br":          false,
+		"":                     false,
+	} {
+		if acceptGzip(value) != expected {
+			t.Errorf("accept encoding %s expected %v", value, expected)
+		}
+	}
+}
+
+func TestGzipCompress(t *testing.T) {
+	stats := metrics.NewGzipStats()
+	compressed := stats.Counter(metrics.GzipCompressed).Count()
+	saved := stats.Counter(metrics.GzipBytesSaved).Count()
+
+	body := strings.Repeat(`{"key":"value"}`, 100)
+	f := newFilter(context.Background(), makeGzipConfig(&v2.StreamGzip{}), stats)
+	handler := &mockStreamSenderFilterHandler{data: buffer.NewIoBufferString(body)}
+	f.SetSenderFilterHandler(handler)
+	f.OnReceive(context.Background(), protocol.CommonHeader{headerAcceptEncoding: "gzip, deflate"}, nil, nil)
+	headers := newResponseHeader(map[string]string{
+		headerContentType: "application/json; charset=utf-8",
+		headerVary:        "Origin",
+	})
+	headers.SetContentLength(len(body))
+	if status := f.Append(context.Background(), headers, handler.data, nil); status != types.StreamFilterContinue {
+		t.Fatalf("append should be continued")
+	}
+	if encoding, _ := headers.Get(headerContentEncoding); encoding != "gzip" {
+		t.Errorf("content encoding is not expected: %s", encoding)
+	}
+	if vary, _ := headers.Get(headerVary); vary != "Origin, Accept-Encoding" {
+		t.Errorf("vary is not expected: %s", vary)
+	}
+	if headers.ContentLength() == len(body) {
+		t.Errorf("content length is not removed")
+	}
+	r, err := gzip.NewReader(bytes.NewReader(handler.data.Bytes()))
+	if err != nil {
+		t.Fatalf("response is not gzip: %v", err)
+	}
+	if decoded, err := ioutil.ReadAll(r); err != nil || string(decoded) != body {
+		t.Errorf("decoded body is not expected, error: %v", err)
+	}
+	if stats.Counter(metrics.GzipCompressed).Count() != compressed+1 ||
+		stats.Counter(metrics.GzipBytesSaved).Count() != saved+int64(len(body)-handler.data.Len()) {
+		t.Errorf("compressed response is not counted")
+	}
+}
+
+func TestGzipNotCompress(t *testing.T) {
+	stats := metrics.NewGzipStats()
+	body := strings.Repeat("text", 100)
+	level := gzip.BestSpeed
+	cfg := makeGzipConfig(&v2.StreamGzip{
+		MinContentLength: 100,
+		MaxContentLength: 1000,
+		ContentTypes:     []string{"text/plain"},
+		CompressionLevel: &level,
+	})
+	testCases := []struct {
+		acceptEncoding string
+		headers        map[string]string
+		body           string
+	}{
+		{"deflate", map[string]string{headerContentType: "text/plain"}, body},
+		{"gzip", map[string]string{headerContentType: "text/plain"}, "short"},
+		{"gzip", map[string]string{headerContentType: "text/plain"}, strings.Repeat(body, 3)},
+		{"gzip", map[string]string{headerContentType: "application/json"}, body},
+		{"gzip", map[string]string{headerContentType: "text/plain", headerContentEncoding: "br"}, body},
+		{"gzip", map[string]string{headerContentType: "text/plain", headerCacheControl: "no-transform"}, body},
+	}
+	for i, tc := range testCases {
+		notCompressed := stats.Counter(metrics.GzipNotCompressed).Count()
+		f := newFilter(context.Background(), cfg, stats)
+		handler := &mockStreamSenderFilterHandler{data: buffer.NewIoBufferString(tc.body)}
+		f.SetSenderFilterHandler(handler)
+		f.OnReceive(context.Background(), protocol.CommonHeader{headerAcceptEncoding: tc.acceptEncoding}, nil, nil)
+		headers := newResponseHeader(tc.headers)
+		f.Append(context.Background(), headers, handler.data, nil)
+		if handler.data.String() != tc.body {
+			t.Errorf("#%d response should not be compressed", i)
+		}
+		if encoding, _ := headers.Get(headerContentEncoding); encoding != tc.headers[headerContentEncoding] {
+			t.Errorf("#%d content encoding is changed: %s", i, encoding)
+		}
+		if stats.Counter(metrics.GzipNotCompressed).Count() != notCompressed+1 {
+			t.Errorf("#%d response is not counted", i)
+		}
+	}
+}
+
+func TestCreateGzipFilterFactory(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"compression_level": 10},
+		{"compression_level": -3},
+		{"min_content_length": -1},
+		{"max_content_length": -1},
+		{"min_content_length": 100, "max_content_length": 10},
+	} {
+		if _, err := CreateGzipFilterFactory(cfg); err == nil {
+			t.Errorf("invalid config %v is accepted", cfg)
+		}
+	}
+	if _, err := CreateGzipFilterFactory(map[string]interface{}{"compression_level": 0}); err != nil {
+		t.Errorf("create gzip filter factory failed: %v", err)
+	}
+}
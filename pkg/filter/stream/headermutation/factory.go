@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headermutation
+
+import (
+	"context"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/filter"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/router"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func init() {
+	filter.RegisterStream(v2.HeaderMutation, CreateHeaderMutationFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	Config          *v2.StreamHeaderMutation
+	requestHeaders  router.HeaderParser
+	responseHeaders router.HeaderParser
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks types.StreamFilterChainFactoryCallbacks) {
+	filter := newFilter(context, f.requestHeaders, f.responseHeaders)
+	if f.requestHeaders != nil {
+		callbacks.AddStreamReceiverFilter(filter, types.DownFilterAfterRoute)
+	}
+	if f.responseHeaders != nil {
+		callbacks.AddStreamSenderFilter(filter)
+	}
+}
+
+func CreateHeaderMutationFilterFactory(conf map[string]interface{}) (types.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create header mutation stream filter factory")
+	cfg, err := config.ParseStreamHeaderMutationFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{
+		Config:          cfg,
+		requestHeaders:  router.NewHeaderParser(cfg.RequestHeadersToAdd, cfg.RequestHeadersToRemove),
+		responseHeaders: router.NewHeaderParser(cfg.ResponseHeadersToAdd, cfg.ResponseHeadersToRemove),
+	}, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headermutation
+
+import (
+	"context"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/router"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// streamHeaderMutationFilter is an implement of types.StreamReceiverFilter and types.StreamSenderFilter,
+// it mutates the request headers after the route is matched, so the routing is not affected
+type streamHeaderMutationFilter struct {
+	ctx             context.Context
+	requestHeaders  router.HeaderParser
+	responseHeaders router.HeaderParser
+	receiveHandler  types.StreamReceiverFilterHandler
+	sendHandler     types.StreamSenderFilterHandler
+}
+
+func newFilter(ctx context.Context, requestHeaders, responseHeaders router.HeaderParser) *streamHeaderMutationFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [header mutation] create a new header mutation filter")
+	}
+	return &streamHeaderMutationFilter{
+		ctx:             ctx,
+		requestHeaders:  requestHeaders,
+		responseHeaders: responseHeaders,
+	}
+}
+
+func (f *streamHeaderMutationFilter) SetReceiveFilterHandler(handler types.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *streamHeaderMutationFilter) SetSenderFilterHandler(handler types.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *streamHeaderMutationFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if f.requestHeaders != nil && headers != nil {
+		f.requestHeaders.EvaluateHeaders(headers, f.receiveHandler.RequestInfo())
+	}
+	return types.StreamFilterContinue
+}
+
+func (f *streamHeaderMutationFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if f.responseHeaders != nil && headers != nil {
+		f.responseHeaders.EvaluateHeaders(headers, f.sendHandler.RequestInfo())
+	}
+	return types.StreamFilterContinue
+}
+
+func (f *streamHeaderMutationFilter) OnDestroy() {}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headermutation
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// only implement the function that used in test
+type mockFilterChainFactoryCallbacks struct {
+	types.StreamFilterChainFactoryCallbacks
+	receiver types.StreamReceiverFilter
+	sender   types.StreamSenderFilter
+}
+
+func (cb *mockFilterChainFactoryCallbacks) AddStreamReceiverFilter(filter types.StreamReceiverFilter, p types.Phase) {
+	cb.receiver = filter
+}
+
+func (cb *mockFilterChainFactoryCallbacks) AddStreamSenderFilter(filter types.StreamSenderFilter) {
+	cb.sender = filter
+}
+
+type mockStreamReceiverFilterHandler struct {
+	types.StreamReceiverFilterHandler
+	info types.RequestInfo
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() types.RequestInfo {
+	return h.info
+}
+
+type mockStreamSenderFilterHandler struct {
+	types.StreamSenderFilterHandler
+	info types.RequestInfo
+}
+
+func (h *mockStreamSenderFilterHandler) RequestInfo() types.RequestInfo {
+	return h.info
+}
+
+func TestHeaderMutationFilter(t *testing.T) {
+	factory, err := CreateHeaderMutationFilterFactory(map[string]interface{}{
+		"request_headers_to_add": []interface{}{
+			map[string]interface{}{
+				"header": map[string]interface{}{"key": "x-env", "value": "prod"},
+				"append": false,
+			},
+			map[string]interface{}{
+				"header": map[string]interface{}{"key": "x-client", "value": "%DOWNSTREAM_REMOTE_ADDRESS%"},
+			},
+		},
+		"response_headers_to_remove": []interface{}{"server", "x-powered-by"},
+	})
+	if err != nil {
+		t.Fatalf("create header mutation filter factory failed: %v", err)
+	}
+	cb := &mockFilterChainFactoryCallbacks{}
+	factory.CreateFilterChain(context.Background(), cb)
+	if cb.receiver == nil || cb.sender == nil {
+		t.Fatal("header mutation filters are not added")
+	}
+	info := network.NewRequestInfo()
+	info.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345})
+	cb.receiver.SetReceiveFilterHandler(&mockStreamReceiverFilterHandler{info: info})
+	cb.sender.SetSenderFilterHandler(&mockStreamSenderFilterHandler{info: info})
+
+	request := protocol.CommonHeader{"x-env": "test"}
+	if status := cb.receiver.OnReceive(context.Background(), request, nil, nil); status != types.StreamFilterContinue {
+		t.Errorf("receive filter should be continued")
+	}
+	if !reflect.DeepEqual(request, protocol.CommonHeader{"x-env": "prod", "x-client": "127.0.0.1:12345"}) {
+		t.Errorf("request headers are not expected: %v", request)
+	}
+	response := protocol.CommonHeader{"server": "upstream", "x-powered-by": "php", "x-servers": "1"}
+	if status := cb.sender.Append(context.Background(), response, nil, nil); status != types.StreamFilterContinue {
+		t.Errorf("sender filter should be continued")
+	}
+	if !reflect.DeepEqual(response, protocol.CommonHeader{"x-servers": "1"}) {
+		t.Errorf("response headers are not expected: %v", response)
+	}
+}
+
+func TestHeaderMutationFilterResponseOnly(t *testing.T) {
+	factory, err := CreateHeaderMutationFilterFactory(map[string]interface{}{
+		"response_headers_to_remove": []interface{}{"server"},
+	})
+	if err != nil {
+		t.Fatalf("create header mutation filter factory failed: %v", err)
+	}
+	cb := &mockFilterChainFactoryCallbacks{}
+	factory.CreateFilterChain(context.Background(), cb)
+	if cb.receiver != nil || cb.sender == nil {
+		t.Errorf("only the sender filter should be added")
+	}
+	if _, err := CreateHeaderMutationFilterFactory(map[string]interface{}{
+		"request_headers_to_add": []interface{}{
+			map[string]interface{}{"append": false},
+		},
+	}); err == nil {
+		t.Errorf("header without key is accepted")
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	defaultStatus         = 429
+	defaultMaxDescriptors = 10000
+)
+
+// limits of a token bucket
+type limits struct {
+	maxTokens     uint32
+	tokensPerFill uint32
+	fillInterval  time.Duration
+}
+
+type rateLimitConfig struct {
+	limits
+	descriptorHeaders []string
+	maxDescriptors    int
+	// descriptor identifies the buckets keys, the buckets are reset if it is changed
+	descriptor      string
+	status          int
+	responseHeaders []*v2.HeaderValue
+	rateLimitHeader bool
+}
+
+func makeRateLimitConfig(cfg *v2.StreamLocalRateLimit) *rateLimitConfig {
+	config := &rateLimitConfig{
+		limits: limits{
+			maxTokens:     cfg.MaxTokens,
+			tokensPerFill: cfg.TokensPerFill,
+			fillInterval:  cfg.FillInterval.Duration,
+		},
+		descriptorHeaders: cfg.DescriptorHeaders,
+		maxDescriptors:    cfg.MaxDescriptors,
+		status:            cfg.Status,
+		responseHeaders:   cfg.ResponseHeadersToAdd,
+		rateLimitHeader:   cfg.EnableRateLimitHeaders,
+	}
+	if config.maxDescriptors == 0 {
+		config.maxDescriptors = defaultMaxDescriptors
+	}
+	if config.status == 0 {
+		config.status = defaultStatus
+	}
+	if len(config.descriptorHeaders) > 0 {
+		config.descriptor = strings.Join(config.descriptorHeaders, ",")
+	}
+	return config
+}
+
+// descriptorKey returns the bucket key of the request, the missed headers are treated as empty values
+func (c *rateLimitConfig) descriptorKey(headers types.HeaderMap) string {
+	if len(c.descriptorHeaders) == 0 || headers == nil {
+		return ""
+	}
+	values := make([]string, len(c.descriptorHeaders))
+	for i, h := range c.descriptorHeaders {
+		values[i], _ = headers.Get(h)
+	}
+	return strings.Join(values, "|")
+}
+
+type tokenBucket struct {
+	key      string
+	tokens   uint32
+	lastFill time.Time
+}
+
+func newTokenBucket(key string, l limits, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		key:      key,
+		tokens:   l.maxTokens,
+		lastFill: now,
+	}
+}
+
+// take fills the bucket by the elapsed intervals and takes a token, returns the remaining tokens
+func (b *tokenBucket) take(l limits, now time.Time) (bool, uint32) {
+	if elapsed := now.Sub(b.lastFill); elapsed >= l.fillInterval {
+		fills := uint64(elapsed / l.fillInterval)
+		tokens := uint64(b.tokens) + fills*uint64(l.tokensPerFill)
+		if tokens > uint64(l.maxTokens) {
+			tokens = uint64(l.maxTokens)
+		}
+		b.tokens = uint32(tokens)
+		b.lastFill = b.lastFill.Add(time.Duration(fills) * l.fillInterval)
+	}
+	// the max tokens may be decreased by the config update
+	if b.tokens > l.maxTokens {
+		b.tokens = l.maxTokens
+	}
+	if b.tokens == 0 {
+		return false, 0
+	}
+	b.tokens--
+	return true, b.tokens
+}
+
+// rateLimiter contains the token buckets of a listener or a route.
+// the buckets keyed by the descriptors are evicted by lru
+type rateLimiter struct {
+	mutex      sync.Mutex
+	descriptor string
+	global     *tokenBucket
+	buckets    map[string]*list.Element
+	lru        *list.List
+	stats      types.Metrics
+}
+
+func newRateLimiter(listenerName string) *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+		stats:   metrics.NewLocalRateLimitStats(listenerName),
+	}
+}
+
+// allow takes a token from the bucket of the key, the limits are updated in place,
+// and the buckets are reset only if the descriptor headers are changed
+func (l *rateLimiter) allow(cfg *rateLimitConfig, key string) (bool, uint32) {
+	now := time.Now()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if cfg.descriptor != l.descriptor {
+		l.descriptor = cfg.descriptor
+		l.global = nil
+		l.buckets = make(map[string]*list.Element)
+		l.lru.Init()
+	}
+	if cfg.descriptor == "" {
+		if l.global == nil {
+			l.global = newTokenBucket("", cfg.limits, now)
+		}
+		return l.global.take(cfg.limits, now)
+	}
+	var bucket *tokenBucket
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		bucket = e.Value.(*tokenBucket)
+	} else {
+		bucket = newTokenBucket(key, cfg.limits, now)
+		l.buckets[key] = l.lru.PushFront(bucket)
+		for l.lru.Len() > cfg.maxDescriptors {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*tokenBucket).key)
+		}
+	}
+	return bucket.take(cfg.limits, now)
+}
+
+// rateLimiters keeps the limiters across the config updates
+var rateLimiters = struct {
+	mutex    sync.RWMutex
+	limiters map[string]*rateLimiter
+}{
+	limiters: make(map[string]*rateLimiter),
+}
+
+// getRateLimiter returns the limiter of the key, key is the listener name, or with the route if the limit is configured in the route
+func getRateLimiter(listenerName, key string) *rateLimiter {
+	rateLimiters.mutex.RLock()
+	l, ok := rateLimiters.limiters[key]
+	rateLimiters.mutex.RUnlock()
+	if ok {
+		return l
+	}
+	rateLimiters.mutex.Lock()
+	defer rateLimiters.mutex.Unlock()
+	if l, ok = rateLimiters.limiters[key]; !ok {
+		l = newRateLimiter(listenerName)
+		rateLimiters.limiters[key] = l
+	}
+	return l
+}
@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+)
+
+func TestTokenBucket(t *testing.T) {
+	l := limits{
+		maxTokens:     3,
+		tokensPerFill: 2,
+		fillInterval:  time.Second,
+	}
+	now := time.Now()
+	b := newTokenBucket("", l, now)
+	for i := 2; i >= 0; i-- {
+		if ok, remaining := b.take(l, now); !ok || remaining != uint32(i) {
+			t.Fatalf("take failed, ok: %v, remaining: %d", ok, remaining)
+		}
+	}
+	if ok, _ := b.take(l, now.Add(999*time.Millisecond)); ok {
+		t.Fatal("empty bucket should be limited")
+	}
+	// filled by 2 intervals, but no more than the max tokens
+	if ok, remaining := b.take(l, now.Add(2500*time.Millisecond)); !ok || remaining != 2 {
+		t.Fatalf("take after fill failed, ok: %v, remaining: %d", ok, remaining)
+	}
+	if !b.lastFill.Equal(now.Add(2 * time.Second)) {
+		t.Fatalf("last fill time is not aligned to the interval: %v", b.lastFill.Sub(now))
+	}
+	// the max tokens decreased
+	l.maxTokens = 1
+	if ok, remaining := b.take(l, now.Add(2500*time.Millisecond)); !ok || remaining != 0 {
+		t.Fatalf("take after limits decreased failed, ok: %v, remaining: %d", ok, remaining)
+	}
+}
+
+func TestRateLimiterDescriptors(t *testing.T) {
+	cfg := &rateLimitConfig{
+		limits: limits{
+			maxTokens:     1,
+			tokensPerFill: 1,
+			fillInterval:  time.Hour,
+		},
+		descriptorHeaders: []string{"x-user-id"},
+		descriptor:        "x-user-id",
+		maxDescriptors:    2,
+	}
+	l := newRateLimiter("test_descriptors")
+	for _, user := range []string{"alice", "bob"} {
+		key := cfg.descriptorKey(protocol.CommonHeader{"x-user-id": user})
+		if ok, _ := l.allow(cfg, key); !ok {
+			t.Fatalf("first request of %s should be allowed", user)
+		}
+		if ok, _ := l.allow(cfg, key); ok {
+			t.Fatalf("second request of %s should be limited", user)
+		}
+	}
+	// evicts alice
+	if ok, _ := l.allow(cfg, "carol"); !ok {
+		t.Fatal("first request of carol should be allowed")
+	}
+	if len(l.buckets) != 2 || l.lru.Len() != 2 {
+		t.Fatalf("buckets are not evicted, %d buckets", len(l.buckets))
+	}
+	if ok, _ := l.allow(cfg, "alice"); !ok {
+		t.Fatal("evicted alice should get a new bucket")
+	}
+	// only the limits are changed, keeps the buckets
+	limitsChanged := *cfg
+	limitsChanged.maxTokens = 2
+	if ok, _ := l.allow(&limitsChanged, "alice"); ok {
+		t.Fatal("the bucket of alice should be kept")
+	}
+	// the descriptor is changed, resets the buckets
+	descriptorChanged := limitsChanged
+	descriptorChanged.descriptorHeaders = []string{"x-tenant"}
+	descriptorChanged.descriptor = "x-tenant"
+	if ok, remaining := l.allow(&descriptorChanged, "alice"); !ok || remaining != 1 {
+		t.Fatalf("the buckets should be reset, ok: %v, remaining: %d", ok, remaining)
+	}
+	if len(l.buckets) != 1 {
+		t.Fatalf("the buckets should be reset, %d buckets", len(l.buckets))
+	}
+}
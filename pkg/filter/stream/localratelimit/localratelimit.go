@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the headers added to the limited responses if the rate limit headers are enabled
+const (
+	HeaderRateLimitLimit     = "x-ratelimit-limit"
+	HeaderRateLimitRemaining = "x-ratelimit-remaining"
+)
+
+func parseStreamLocalRateLimitConfig(c interface{}) (*rateLimitConfig, bool) {
+	conf, ok := c.(map[string]interface{})
+	if !ok {
+		log.DefaultLogger.Errorf("config is not stream local rate limit, %v", c)
+		return nil, false
+	}
+	cfg, err := config.ParseStreamLocalRateLimitFilter(conf)
+	if err != nil {
+		log.DefaultLogger.Errorf("config is not stream local rate limit, %v", err)
+		return nil, false
+	}
+	return makeRateLimitConfig(cfg), true
+}
+
+// streamLocalRateLimitFilter is an implement of types.StreamReceiverFilter
+type streamLocalRateLimitFilter struct {
+	ctx          context.Context
+	handler      types.StreamReceiverFilterHandler
+	config       *rateLimitConfig
+	listenerName string
+	// limiterKey is the key of the token buckets
+	limiterKey string
+}
+
+func NewFilter(ctx context.Context, cfg *v2.StreamLocalRateLimit) types.StreamReceiverFilter {
+	return newFilter(ctx, makeRateLimitConfig(cfg))
+}
+
+func newFilter(ctx context.Context, cfg *rateLimitConfig) *streamLocalRateLimitFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [local rate limit] create a new local rate limit filter")
+	}
+	listenerName, _ := mosnctx.Get(ctx, types.ContextKeyListenerName).(string)
+	return &streamLocalRateLimitFilter{
+		ctx:          ctx,
+		config:       cfg,
+		listenerName: listenerName,
+		limiterKey:   listenerName,
+	}
+}
+
+// ReadPerRouteConfig makes route-level configuration override filter-level configuration,
+// and the route uses its own token buckets
+func (f *streamLocalRateLimitFilter) ReadPerRouteConfig(route types.Route) {
+	cfg := route.RouteRule().PerFilterConfig()
+	if cfg == nil {
+		return
+	}
+	if rateLimit, ok := cfg[v2.LocalRateLimit]; ok {
+		if config, ok := parseStreamLocalRateLimitConfig(rateLimit); ok {
+			if log.Proxy.GetLogLevel() >= log.DEBUG {
+				log.Proxy.Debugf(f.ctx, "[stream filter] [local rate limit] use router config to replace stream filter config, config: %v", rateLimit)
+			}
+			f.config = config
+			f.limiterKey = f.listenerName + "|" + routeKey(route.RouteRule())
+		}
+	}
+}
+
+// routeKey identifies the route by its virtual host and path, so the route keeps the buckets after the router updated
+func routeKey(rule types.RouteRule) string {
+	var vhost, path string
+	if vh := rule.VirtualHost(); vh != nil {
+		vhost = vh.Name()
+	}
+	if pm := rule.PathMatchCriterion(); pm != nil {
+		path = pm.Matcher()
+	}
+	return vhost + "|" + path
+}
+
+func (f *streamLocalRateLimitFilter) SetReceiveFilterHandler(handler types.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *streamLocalRateLimitFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) types.StreamFilterStatus {
+	if route := f.handler.Route(); route != nil {
+		f.ReadPerRouteConfig(route)
+	}
+	limiter := getRateLimiter(f.listenerName, f.limiterKey)
+	allowed, remaining := limiter.allow(f.config, f.config.descriptorKey(headers))
+	if allowed {
+		limiter.stats.Counter(metrics.LocalRateLimitAccepted).Inc(1)
+		return types.StreamFilterContinue
+	}
+	limiter.stats.Counter(metrics.LocalRateLimitLimited).Inc(1)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [local rate limit] request is limited, limiter: %s", f.limiterKey)
+	}
+	if headers == nil {
+		headers = protocol.CommonHeader(make(map[string]string, len(f.config.responseHeaders)+2))
+	}
+	for _, h := range f.config.responseHeaders {
+		headers.Set(h.Key, h.Value)
+	}
+	if f.config.rateLimitHeader {
+		headers.Set(HeaderRateLimitLimit, strconv.FormatUint(uint64(f.config.maxTokens), 10))
+		headers.Set(HeaderRateLimitRemaining, strconv.FormatUint(uint64(remaining), 10))
+	}
+	f.handler.RequestInfo().SetResponseFlag(types.RateLimited)
+	f.handler.SendHijackReply(f.config.status, headers)
+	return types.StreamFilterStop
+}
+
+func (f *streamLocalRateLimitFilter) OnDestroy() {}
@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"context"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/filter"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// only implement the function that used in test
+type mockFilterChainFactoryCallbacks struct {
+	types.StreamFilterChainFactoryCallbacks
+	filter types.StreamReceiverFilter
+}
+
+func (cb *mockFilterChainFactoryCallbacks) AddStreamReceiverFilter(filter types.StreamReceiverFilter, p types.Phase) {
+	cb.filter = filter
+}
+
+type mockStreamReceiverFilterHandler struct {
+	types.StreamReceiverFilterHandler
+	route      types.Route
+	info       *mockRequestInfo
+	hijackCode int
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() types.Route {
+	return h.route
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() types.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) SendHijackReply(code int, headers types.HeaderMap) {
+	h.hijackCode = code
+}
+
+type mockRequestInfo struct {
+	types.RequestInfo
+	flag types.ResponseFlag
+}
+
+func (info *mockRequestInfo) SetResponseFlag(flag types.ResponseFlag) {
+	info.flag = flag
+}
+
+type mockRoute struct {
+	types.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() types.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	types.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+func (r *mockRouteRule) VirtualHost() types.VirtualHost {
+	return nil
+}
+
+func (r *mockRouteRule) PathMatchCriterion() types.PathMatchCriterion {
+	return nil
+}
+
+func createFilter(t *testing.T, listenerName string, cfg map[string]interface{}) types.StreamReceiverFilter {
+	sf, err := filter.CreateStreamFilterChainFactory(v2.LocalRateLimit, cfg)
+	if err != nil {
+		t.Fatalf("create local rate limit filter factory failed: %v", err)
+	}
+	cb := &mockFilterChainFactoryCallbacks{}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerName, listenerName)
+	sf.CreateFilterChain(ctx, cb)
+	return cb.filter
+}
+
+func TestLocalRateLimitFilter(t *testing.T) {
+	listenerName := "test_local_rate_limit"
+	delete(rateLimiters.limiters, listenerName)
+	stats := metrics.NewLocalRateLimitStats(listenerName)
+	accepted := stats.Counter(metrics.LocalRateLimitAccepted).Count()
+	limited := stats.Counter(metrics.LocalRateLimitLimited).Count()
+
+	cfg := map[string]interface{}{
+		"max_tokens":                 1,
+		"tokens_per_fill":            1,
+		"fill_interval":              "1h",
+		"enable_x_ratelimit_headers": true,
+		"response_headers_to_add": []interface{}{
+			map[string]interface{}{"key": "x-limited-by", "value": "mosn"},
+		},
+	}
+	f := createFilter(t, listenerName, cfg)
+	handler := &mockStreamReceiverFilterHandler{info: &mockRequestInfo{}}
+	f.SetReceiveFilterHandler(handler)
+	if status := f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil); status != types.StreamFilterContinue {
+		t.Fatalf("first request should be accepted")
+	}
+	headers := protocol.CommonHeader{}
+	if status := f.OnReceive(context.Background(), headers, nil, nil); status != types.StreamFilterStop {
+		t.Fatalf("second request should be limited")
+	}
+	if handler.hijackCode != 429 || handler.info.flag != types.RateLimited {
+		t.Errorf("limited reply is not expected, code: %d, flag: %d", handler.hijackCode, handler.info.flag)
+	}
+	for k, v := range map[string]string{
+		HeaderRateLimitLimit:     "1",
+		HeaderRateLimitRemaining: "0",
+		"x-limited-by":           "mosn",
+	} {
+		if value, _ := headers.Get(k); value != v {
+			t.Errorf("header %s expected %s, but got %s", k, v, value)
+		}
+	}
+	if stats.Counter(metrics.LocalRateLimitAccepted).Count() != accepted+1 || stats.Counter(metrics.LocalRateLimitLimited).Count() != limited+1 {
+		t.Errorf("requests are not counted")
+	}
+
+	// the config is updated with the limits only, the tokens are kept
+	cfg["max_tokens"] = 2
+	f2 := createFilter(t, listenerName, cfg)
+	handler2 := &mockStreamReceiverFilterHandler{info: &mockRequestInfo{}}
+	f2.SetReceiveFilterHandler(handler2)
+	if status := f2.OnReceive(context.Background(), nil, nil, nil); status != types.StreamFilterStop {
+		t.Errorf("request should be limited after the limits updated")
+	}
+}
+
+func TestLocalRateLimitRouteConfig(t *testing.T) {
+	listenerName := "test_local_rate_limit_route"
+	delete(rateLimiters.limiters, listenerName)
+	delete(rateLimiters.limiters, listenerName+"||")
+	f := createFilter(t, listenerName, map[string]interface{}{
+		"max_tokens":      1,
+		"tokens_per_fill": 1,
+		"fill_interval":   "1h",
+	})
+	// exhausts the listener bucket
+	f.SetReceiveFilterHandler(&mockStreamReceiverFilterHandler{info: &mockRequestInfo{}})
+	f.OnReceive(context.Background(), nil, nil, nil)
+
+	handler := &mockStreamReceiverFilterHandler{
+		info: &mockRequestInfo{},
+		route: &mockRoute{
+			rule: &mockRouteRule{
+				config: map[string]interface{}{
+					v2.LocalRateLimit: map[string]interface{}{
+						"max_tokens":      1,
+						"tokens_per_fill": 1,
+						"fill_interval":   "1h",
+						"status":          503,
+					},
+				},
+			},
+		},
+	}
+	f.SetReceiveFilterHandler(handler)
+	if status := f.OnReceive(context.Background(), nil, nil, nil); status != types.StreamFilterContinue {
+		t.Fatalf("route should use its own bucket")
+	}
+	if status := f.OnReceive(context.Background(), nil, nil, nil); status != types.StreamFilterStop || handler.hijackCode != 503 {
+		t.Fatalf("route config is not used, code: %d", handler.hijackCode)
+	}
+}
+
+func TestCreateLocalRateLimitFilterFactory(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"tokens_per_fill": 1, "fill_interval": "1s"},
+		{"max_tokens": 1, "fill_interval": "1s"},
+		{"max_tokens": 1, "tokens_per_fill": 1},
+		{"max_tokens": 1, "tokens_per_fill": 1, "fill_interval": "1s", "max_descriptors": -1},
+	} {
+		if _, err := CreateLocalRateLimitFilterFactory(cfg); err == nil {
+			t.Errorf("invalid config %v is accepted", cfg)
+		}
+	}
+}
@@ -50,6 +50,23 @@ func init() {
 		types.LogDownstreamLocalAddress:     DownstreamLocalAddressGetter,
 		types.LogDownstreamRemoteAddress:    DownstreamRemoteAddressGetter,
 		types.LogUpstreamHostSelectedGetter: UpstreamHostSelectedGetter,
+		// upper case style keys
+		types.LogKeyStartTime:                StartTimeGetter,
+		types.LogKeyRequestReceivedDuration:  ReceivedDurationGetter,
+		types.LogKeyResponseReceivedDuration: ResponseReceivedDurationGetter,
+		types.LogKeyUpstreamDuration:         UpstreamDurationGetter,
+		types.LogKeyRequestFinishedDuration:  RequestFinishedDurationGetter,
+		types.LogKeyDuration:                 TotalDurationGetter,
+		types.LogKeyBytesSent:                BytesSentGetter,
+		types.LogKeyBytesReceived:            BytesReceivedGetter,
+		types.LogKeyProtocol:                 ProtocolGetter,
+		types.LogKeyResponseCode:             ResponseCodeGetter,
+		types.LogKeyResponseFlags:            ResponseFlagsGetter,
+		types.LogKeyUpstreamHost:             UpstreamHostAddressGetter,
+		types.LogKeyUpstreamLocalAddress:     UpstreamLocalAddressGetter,
+		types.LogKeyDownstreamLocalAddress:   DownstreamLocalAddressGetter,
+		types.LogKeyDownstreamRemoteAddress:  DownstreamRemoteAddressGetter,
+		types.LogKeyRequestID:                RequestIDGetter,
 	}
 	accessLogs = []*accesslog{}
 }
@@ -72,14 +89,22 @@ type accesslog struct {
 // NewAccessLog
 func NewAccessLog(output string, filter types.AccessLogFilter,
 	format string) (types.AccessLog, error) {
-	lg, err := GetOrCreateLogger(output, nil)
+	return NewAccessLogWithOptions(output, filter, format, "", nil, nil)
+}
+
+// NewAccessLogWithOptions creates an access log that is rotated by the roller and buffered by the options,
+// the global roller and the default buffer are used if they are nil.
+// the absentHeader is written for the headers not found, nothing is written if it is empty
+func NewAccessLogWithOptions(output string, filter types.AccessLogFilter,
+	format string, absentHeader string, roller *Roller, opts *BufferOptions) (types.AccessLog, error) {
+	lg, err := GetOrCreateBufferedLogger(output, roller, opts)
 	if err != nil {
 		return nil, err
 	}
 	l := &accesslog{
 		output:    output,
 		filter:    filter,
-		formatter: NewAccessLogFormatter(format),
+		formatter: newAccessLogFormatter(format, absentHeader),
 		logger:    lg,
 	}
 	if DefaultDisableAccessLog {
@@ -104,10 +129,6 @@ func (l *accesslog) Log(reqHeaders types.HeaderMap, respHeaders types.HeaderMap,
 
 	buf := buffer.GetIoBuffer(AccessLogLen)
 	l.formatter.Format(buf, reqHeaders, respHeaders, requestInfo)
-	// delete first " "
-	if buf.Len() > 0 {
-		buf.Drain(1)
-	}
 	buf.WriteString("\n")
 	l.logger.Print(buf, true)
 }
@@ -119,12 +140,16 @@ type accesslogformatter struct {
 
 // NewAccessLogFormatter
 func NewAccessLogFormatter(format string) types.AccessLogFormatter {
+	return newAccessLogFormatter(format, "")
+}
+
+func newAccessLogFormatter(format string, absentHeader string) types.AccessLogFormatter {
 	if format == "" {
 		format = types.DefaultAccessLogFormat
 	}
 
 	return &accesslogformatter{
-		formatters: formatToFormatter(format),
+		formatters: formatToFormatter(format, absentHeader),
 	}
 }
 
@@ -135,134 +160,110 @@ func (f *accesslogformatter) Format(buf types.IoBuffer, reqHeaders types.HeaderM
 }
 
 // types.AccessLogFormatter
-type simpleRequestInfoFormatter struct {
-	reqInfoFunc []func(info types.RequestInfo) string
+// textFormatter writes the text between the keys
+type textFormatter struct {
+	text string
 }
 
-// Format request info headers
-func (f *simpleRequestInfoFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
-	// todo: map fieldName to field vale string
-	if f.reqInfoFunc == nil {
-		DefaultLogger.Debugf("No ReqInfo Format Keys Input")
-		return
-	}
-
-	for _, vFunc := range f.reqInfoFunc {
-		buf.WriteString(" ")
-		s := vFunc(requestInfo)
-		if s == "" {
-			s = "-"
-		}
-		buf.WriteString(s)
-	}
+func (f *textFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
+	buf.WriteString(f.text)
 }
 
 // types.AccessLogFormatter
-type simpleReqHeadersFormatter struct {
-	reqHeaderFormat []string
+type requestInfoFormatter struct {
+	reqInfoFunc func(info types.RequestInfo) string
 }
 
-// Format request headers format
-func (f *simpleReqHeadersFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
-	if f.reqHeaderFormat == nil {
-		DefaultLogger.Debugf("No ReqHeaders Format Keys Input")
-		return
-	}
-
-	for _, key := range f.reqHeaderFormat {
-		if v, ok := reqHeaders.Get(key); ok {
-			buf.WriteString(" ")
-			buf.WriteString(types.ReqHeaderPrefix)
-			if v == "" {
-				v = "-"
-			}
-			buf.WriteString(v)
-		} else {
-			//DefaultLogger.Debugf("Invalid reqHeaders format keys when print access log: %s", key)
-		}
+func (f *requestInfoFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
+	s := f.reqInfoFunc(requestInfo)
+	if s == "" {
+		s = "-"
 	}
+	buf.WriteString(s)
 }
 
 // types.AccessLogFormatter
-type simpleRespHeadersFormatter struct {
-	respHeaderFormat []string
+// headerFormatter writes the value of the request header or the response header,
+// the prefix is written before the value for the compatible REQ.key and RESP.key formats.
+// the absent is written if the header is not found, and "-" is written if the value is empty
+type headerFormatter struct {
+	key      string
+	prefix   string
+	response bool
+	absent   string
 }
 
-// Format response headers format
-func (f *simpleRespHeadersFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
-	if f.respHeaderFormat == nil {
-		DefaultLogger.Debugf("No RespHeaders Format Keys Input")
+func (f *headerFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
+	headers := reqHeaders
+	if f.response {
+		headers = respHeaders
+	}
+	if headers == nil {
+		buf.WriteString(f.absent)
 		return
 	}
-
-	for _, key := range f.respHeaderFormat {
-		if respHeaders != nil {
-			if v, ok := respHeaders.Get(key); ok {
-				buf.WriteString(" ")
-				buf.WriteString(types.RespHeaderPrefix)
-				if v == "" {
-					v = "-"
-				}
-				buf.WriteString(v)
-			} else {
-				//DefaultLogger.Debugf("Invalid RespHeaders Format Keys:%s", key)
-			}
-		}
+	v, ok := headers.Get(f.key)
+	if !ok {
+		buf.WriteString(f.absent)
+		return
+	}
+	if v == "" {
+		v = "-"
 	}
+	buf.WriteString(f.prefix)
+	buf.WriteString(v)
 }
 
-// format to formatter by parsing format
-func formatToFormatter(format string) []types.AccessLogFormatter {
-
-	strArray := strings.Split(format, " ")
-
-	// delete %
-	for i := 0; i < len(strArray); i++ {
-		strArray[i] = strArray[i][1 : len(strArray[i])-1]
+// keyToFormatter returns the formatter of a key in the format, returns nil if the key is invalid
+func keyToFormatter(key string, absentHeader string) types.AccessLogFormatter {
+	switch {
+	case strings.HasPrefix(key, types.ReqHeaderFunc+"(") && strings.HasSuffix(key, ")"):
+		return &headerFormatter{key: key[len(types.ReqHeaderFunc)+1 : len(key)-1], absent: absentHeader}
+	case strings.HasPrefix(key, types.RespHeaderFunc+"(") && strings.HasSuffix(key, ")"):
+		return &headerFormatter{key: key[len(types.RespHeaderFunc)+1 : len(key)-1], response: true, absent: absentHeader}
+	case strings.HasPrefix(key, types.ReqHeaderPrefix):
+		return &headerFormatter{key: key[len(types.ReqHeaderPrefix):], prefix: types.ReqHeaderPrefix, absent: absentHeader}
+	case strings.HasPrefix(key, types.RespHeaderPrefix):
+		return &headerFormatter{key: key[len(types.RespHeaderPrefix):], prefix: types.RespHeaderPrefix, response: true, absent: absentHeader}
+	case key == types.LogKeyMethod:
+		return &headerFormatter{key: types.HeaderMethod, absent: absentHeader}
+	case key == types.LogKeyPath:
+		return &headerFormatter{key: types.HeaderPath, absent: absentHeader}
 	}
-
-	// classify keys
-	var reqInfoArray, reqHeaderArray, respHeaderArray []string
-	for _, s := range strArray {
-		if strings.HasPrefix(s, types.ReqHeaderPrefix) {
-			reqHeaderArray = append(reqHeaderArray, s)
-
-		} else if strings.HasPrefix(s, types.RespHeaderPrefix) {
-			respHeaderArray = append(respHeaderArray, s)
-		} else {
-			reqInfoArray = append(reqInfoArray, s)
-		}
+	if vFunc, ok := RequestInfoFuncMap[key]; ok {
+		return &requestInfoFormatter{reqInfoFunc: vFunc}
 	}
+	return nil
+}
 
-	// delete REQ.
-	if reqHeaderArray != nil {
-		for i := 0; i < len(reqHeaderArray); i++ {
-			reqHeaderArray[i] = reqHeaderArray[i][len(types.ReqHeaderPrefix):]
+// format to formatter by parsing format, the format is parsed once when the access log is created.
+// the keys are quoted by "%", the text between the keys is written as it is
+func formatToFormatter(format string, absentHeader string) []types.AccessLogFormatter {
+	var formatters []types.AccessLogFormatter
+	for len(format) > 0 {
+		start := strings.IndexByte(format, '%')
+		if start < 0 {
+			formatters = append(formatters, &textFormatter{text: format})
+			break
 		}
-	}
-
-	// delete RESP.
-	if respHeaderArray != nil {
-		for i := 0; i < len(respHeaderArray); i++ {
-			respHeaderArray[i] = respHeaderArray[i][len(types.RespHeaderPrefix):]
+		end := strings.IndexByte(format[start+1:], '%')
+		if end < 0 {
+			formatters = append(formatters, &textFormatter{text: format})
+			break
 		}
-	}
-
-	// set info function
-	var infoFunc []func(info types.RequestInfo) string
-	for _, key := range reqInfoArray {
-		if vFunc, ok := RequestInfoFuncMap[key]; ok {
-			infoFunc = append(infoFunc, vFunc)
+		end += start + 1
+		if start > 0 {
+			formatters = append(formatters, &textFormatter{text: format[:start]})
+		}
+		key := format[start+1 : end]
+		if formatter := keyToFormatter(key, absentHeader); formatter != nil {
+			formatters = append(formatters, formatter)
 		} else {
-			DefaultLogger.Debugf("Invalid ReqInfo Format Keys: %s", key)
+			DefaultLogger.Debugf("Invalid Format Keys: %s", key)
 		}
+		format = format[end+1:]
 	}
-
-	return []types.AccessLogFormatter{
-		&simpleRequestInfoFormatter{reqInfoFunc: infoFunc},
-		&simpleReqHeadersFormatter{reqHeaderFormat: reqHeaderArray},
-		&simpleRespHeadersFormatter{respHeaderFormat: respHeaderArray},
-	}
+	return formatters
 }
 
 // StartTimeGetter
@@ -288,6 +289,25 @@ func RequestFinishedDurationGetter(info types.RequestInfo) string {
 	return info.RequestFinishedDuration().String()
 }
 
+// UpstreamDurationGetter
+// get duration between request resend to upstream and response received
+func UpstreamDurationGetter(info types.RequestInfo) string {
+	if info.ResponseReceivedDuration() == 0 {
+		return ""
+	}
+	return (info.ResponseReceivedDuration() - info.RequestReceivedDuration()).String()
+}
+
+// TotalDurationGetter
+// get duration between request arriving and request finished, or the duration since request's starting time
+// if the request is not finished
+func TotalDurationGetter(info types.RequestInfo) string {
+	if d := info.RequestFinishedDuration(); d > 0 {
+		return d.String()
+	}
+	return info.Duration().String()
+}
+
 // BytesSentGetter
 // get bytes sent
 func BytesSentGetter(info types.RequestInfo) string {
@@ -323,6 +343,42 @@ func GetResponseFlagGetter(info types.RequestInfo) string {
 	return strconv.FormatBool(info.GetResponseFlag(0))
 }
 
+// responseFlagNames is the short names of the response flags
+var responseFlagNames = []struct {
+	flag types.ResponseFlag
+	name string
+}{
+	{types.NoHealthyUpstream, "UH"},
+	{types.UpstreamRequestTimeout, "UT"},
+	{types.UpstreamLocalReset, "LR"},
+	{types.UpstreamRemoteReset, "UR"},
+	{types.UpstreamConnectionFailure, "UF"},
+	{types.UpstreamConnectionTermination, "UC"},
+	{types.UpstreamOverflow, "UO"},
+	{types.NoRouteFound, "NR"},
+	{types.DelayInjected, "DI"},
+	{types.FaultInjected, "FI"},
+	{types.RateLimited, "RL"},
+	{types.ReqEntityTooLarge, "TL"},
+	{types.DownstreamResponseWriteError, "DW"},
+	{types.LoopDetected, "LP"},
+	{types.NoClusterConfigured, "NC"},
+	{types.DownstreamOverflow, "DO"},
+	{types.ProtocolConvertFailed, "PC"},
+}
+
+// ResponseFlagsGetter
+// get the short names of request's response flags, joined by ","
+func ResponseFlagsGetter(info types.RequestInfo) string {
+	var names []string
+	for _, f := range responseFlagNames {
+		if info.GetResponseFlag(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
 // UpstreamLocalAddressGetter
 // get upstream's local address
 func UpstreamLocalAddressGetter(info types.RequestInfo) string {
@@ -358,3 +414,18 @@ func UpstreamHostSelectedGetter(info types.RequestInfo) string {
 	}
 	return ""
 }
+
+// UpstreamHostAddressGetter
+// get upstream's selected host address
+func UpstreamHostAddressGetter(info types.RequestInfo) string {
+	if info.UpstreamHost() != nil {
+		return info.UpstreamHost().AddressString()
+	}
+	return ""
+}
+
+// RequestIDGetter
+// get the request id of the stream
+func RequestIDGetter(info types.RequestInfo) string {
+	return info.RequestID()
+}
@@ -28,6 +28,7 @@ import (
 	"os"
 	"regexp"
 
+	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -80,6 +81,63 @@ func TestAccessLog(t *testing.T) {
 	}
 }
 
+func TestAccessLogFormatter(t *testing.T) {
+	reqHeaders := protocol.CommonHeader{
+		"service":          "test",
+		"empty":            "",
+		types.HeaderMethod: "GET",
+		types.HeaderPath:   "/index",
+	}
+	respHeaders := protocol.CommonHeader{
+		"server": "MOSN",
+	}
+	requestInfo := newRequestInfo()
+	requestInfo.SetProtocol(protocol.HTTP1)
+	requestInfo.SetResponseCode(200)
+	requestInfo.SetBytesSent(2048)
+	requestInfo.SetBytesReceived(1024)
+	requestInfo.SetResponseFlag(types.UpstreamRequestTimeout)
+	requestInfo.SetResponseFlag(types.NoRouteFound)
+	requestInfo.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53242})
+	testCases := []struct {
+		format       string
+		absentHeader string
+		expected     string
+	}{
+		{
+			format:   "%METHOD% %PATH% %PROTOCOL% %RESPONSE_CODE% %BYTES_SENT% %BYTES_RECEIVED% %RESPONSE_FLAGS% %DOWNSTREAM_REMOTE_ADDRESS% %UPSTREAM_HOST%",
+			expected: "GET /index Http1 200 2048 1024 UT,NR 127.0.0.1:53242 -",
+		},
+		{
+			format:   `[%REQ(service)%] "%RESP(server)%" [%REQ(not_exists)%] %Protocol%`,
+			expected: `[test] "MOSN" [] Http1`,
+		},
+		// the keys are written in the order of the format, the absent headers are not written by default
+		{
+			format:   "%RESP.server% %REQ.service% %REQ.not_exists%%REQ.empty% %ResponseCode%",
+			expected: "RESP.MOSN REQ.test REQ.- 200",
+		},
+		// the absent header placeholder is configured
+		{
+			format:       "%REQ(not_exists)% %REQ.not_exists% %REQ(empty)%",
+			absentHeader: "-",
+			expected:     "- - -",
+		},
+		// the invalid keys are ignored
+		{
+			format:   "%UNKNOWN%code=%RESPONSE_CODE% 100%",
+			expected: "code=200 100%",
+		},
+	}
+	for _, tc := range testCases {
+		buf := buffer.NewIoBuffer(AccessLogLen)
+		newAccessLogFormatter(tc.format, tc.absentHeader).Format(buf, reqHeaders, respHeaders, requestInfo)
+		if buf.String() != tc.expected {
+			t.Errorf("format %s expected %s, but got %s", tc.format, tc.expected, buf.String())
+		}
+	}
+}
+
 func TestAccessLogStartTime(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		time.Sleep(time.Millisecond)
@@ -255,6 +313,7 @@ type mock_requestInfo struct {
 	downstreamRemoteAddress  net.Addr
 	isHealthCheckRequest     bool
 	routerRule               types.RouteRule
+	requestID                string
 }
 
 // NewrequestInfo
@@ -316,6 +375,10 @@ func (r *mock_requestInfo) Protocol() types.Protocol {
 	return r.protocol
 }
 
+func (r *mock_requestInfo) SetProtocol(p types.Protocol) {
+	r.protocol = p
+}
+
 func (r *mock_requestInfo) ResponseCode() int {
 	return r.responseCode
 }
@@ -383,3 +446,11 @@ func (r *mock_requestInfo) RouteEntry() types.RouteRule {
 func (r *mock_requestInfo) SetRouteEntry(routerRule types.RouteRule) {
 	r.routerRule = routerRule
 }
+
+func (r *mock_requestInfo) RequestID() string {
+	return r.requestID
+}
+
+func (r *mock_requestInfo) SetRequestID(id string) {
+	r.requestID = id
+}
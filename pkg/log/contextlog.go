@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// contextLogger is an ErrorLogger stored in the stream context, it prefixes the lines with the connection id,
+// the stream id, the trace id, the request id and the upstream host of the stream:
+// {time} [{level}] [c:{connection id} s:{stream id} t:{trace id} r:{request id} u:{upstream host}] {content}
+// so the lines of a request can be found by grep
+type contextLogger struct {
+	ErrorLogger
+	connID   uint64
+	streamID uint64
+	// traceID is the trace id of the context the logger is stored in
+	traceID string
+	// requestID is setted after the request is received, the value is a string
+	requestID atomic.Value
+	// upstreamHost is setted after the upstream host is selected, the value is a string
+	upstreamHost atomic.Value
+}
+
+// NewContextLogger returns an ErrorLogger that prefixes the lines with the connection id and the stream id
+func NewContextLogger(lg ErrorLogger, connID, streamID uint64) ErrorLogger {
+	return &contextLogger{
+		ErrorLogger: lg,
+		connID:      connID,
+		streamID:    streamID,
+	}
+}
+
+// ByContext returns the ErrorLogger stored in the context, returns the DefaultLogger if no logger is stored
+func ByContext(ctx context.Context) ErrorLogger {
+	if ctx != nil {
+		if lg, ok := mosnctx.Get(ctx, types.ContextKeyLogger).(ErrorLogger); ok {
+			return lg
+		}
+	}
+	return DefaultLogger
+}
+
+// ContextWithLogger stores a logger with the connection id of the context and the stream id in the context
+func ContextWithLogger(ctx context.Context, streamID uint64) context.Context {
+	connID, _ := mosnctx.Get(ctx, types.ContextKeyConnectionID).(uint64)
+	lg := NewContextLogger(DefaultLogger, connID, streamID).(*contextLogger)
+	lg.traceID, _ = mosnctx.Get(ctx, types.ContextKeyTraceId).(string)
+	return mosnctx.WithValue(ctx, types.ContextKeyLogger, lg)
+}
+
+// SetRequestID adds the request id to the prefix of the logger stored in the context
+func SetRequestID(ctx context.Context, id string) {
+	if ctx == nil {
+		return
+	}
+	if lg, ok := mosnctx.Get(ctx, types.ContextKeyLogger).(*contextLogger); ok {
+		lg.requestID.Store(id)
+	}
+}
+
+// SetUpstreamHost adds the upstream host to the prefix of the logger stored in the context.
+// the contexts cloned from the context share the logger, so the upstream streams' lines have the host too
+func SetUpstreamHost(ctx context.Context, host string) {
+	if ctx == nil {
+		return
+	}
+	if lg, ok := mosnctx.Get(ctx, types.ContextKeyLogger).(*contextLogger); ok {
+		lg.upstreamHost.Store(host)
+	}
+}
+
+// prefix returns the args with the prefix as the first one, the prefix is not a part of the format
+// since the upstream host may contain '%', such as an IPv6 zone
+func (l *contextLogger) prefix(args []interface{}) []interface{} {
+	s := "[c:" + strconv.FormatUint(l.connID, 10) + " s:" + strconv.FormatUint(l.streamID, 10)
+	if l.traceID != "" {
+		s += " t:" + l.traceID
+	}
+	if id, ok := l.requestID.Load().(string); ok && id != "" {
+		s += " r:" + id
+	}
+	if host, ok := l.upstreamHost.Load().(string); ok && host != "" {
+		s += " u:" + host
+	}
+	return append([]interface{}{s + "]"}, args...)
+}
+
+func (l *contextLogger) Printf(format string, args ...interface{}) {
+	l.ErrorLogger.Printf("%s "+format, l.prefix(args)...)
+}
+
+func (l *contextLogger) Alertf(errkey types.ErrorKey, format string, args ...interface{}) {
+	if l.GetLogLevel() >= ERROR {
+		l.ErrorLogger.Alertf(errkey, "%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) Infof(format string, args ...interface{}) {
+	if l.GetLogLevel() >= INFO {
+		l.ErrorLogger.Infof("%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) Debugf(format string, args ...interface{}) {
+	if l.GetLogLevel() >= DEBUG {
+		l.ErrorLogger.Debugf("%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) Warnf(format string, args ...interface{}) {
+	if l.GetLogLevel() >= WARN {
+		l.ErrorLogger.Warnf("%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) Errorf(format string, args ...interface{}) {
+	if l.GetLogLevel() >= ERROR {
+		l.ErrorLogger.Errorf("%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) ErrorfThrottled(key string, ratePerSec int, format string, args ...interface{}) {
+	if l.GetLogLevel() >= ERROR {
+		l.ErrorLogger.ErrorfThrottled(key, ratePerSec, "%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) Tracef(format string, args ...interface{}) {
+	if l.GetLogLevel() >= TRACE {
+		l.ErrorLogger.Tracef("%s "+format, l.prefix(args)...)
+	}
+}
+
+func (l *contextLogger) Fatalf(format string, args ...interface{}) {
+	l.ErrorLogger.Fatalf("%s "+format, l.prefix(args)...)
+}
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestContextLogger(t *testing.T) {
+	logName := "/tmp/mosn/context_logger.log"
+	os.Remove(logName)
+	loggers.Delete(logName)
+	lg, err := CreateDefaultErrorLogger(logName, INFO, nil)
+	if err != nil {
+		t.Fatal("create logger failed")
+	}
+	// no logger in context
+	if ByContext(context.Background()) != DefaultLogger || ByContext(nil) != DefaultLogger {
+		t.Fatal("expected default logger if no logger in context")
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyLogger, NewContextLogger(lg, 1, 2))
+	ByContext(ctx).Infof("first %d", 1)
+	ByContext(ctx).Debugf("ignored")
+	SetRequestID(ctx, "abc")
+	SetUpstreamHost(ctx, "[fe80::1%eth0]:8080")
+	// the cloned context shares the logger
+	ByContext(mosnctx.Clone(ctx)).Errorf("second")
+	time.Sleep(time.Second) // wait flush
+	lines, err := readLines(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, but got: %v", lines)
+	}
+	if !strings.HasSuffix(lines[0], "[INFO] [c:1 s:2] first 1") ||
+		!strings.HasSuffix(lines[1], "[ERROR] [normal] [c:1 s:2 r:abc u:[fe80::1%eth0]:8080] second") {
+		t.Errorf("log lines are not expected: %v", lines)
+	}
+}
+
+func TestContextWithLogger(t *testing.T) {
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyConnectionID, uint64(10))
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyTraceId, "trace")
+	ctx = ContextWithLogger(ctx, 20)
+	lg, ok := ByContext(ctx).(*contextLogger)
+	if !ok {
+		t.Fatal("expected context logger stored")
+	}
+	if lg.connID != 10 || lg.streamID != 20 || lg.traceID != "trace" || lg.ErrorLogger != DefaultLogger {
+		t.Errorf("context logger is not expected: %+v", lg)
+	}
+}
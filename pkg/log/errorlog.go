@@ -37,8 +37,11 @@ type errorLogger struct {
 	level Level
 }
 
-func CreateDefaultErrorLogger(output string, level Level) (ErrorLogger, error) {
-	lg, err := GetOrCreateLogger(output, nil)
+func CreateDefaultErrorLogger(output string, level Level, opts *ErrorLoggerOptions) (ErrorLogger, error) {
+	if opts == nil {
+		opts = &ErrorLoggerOptions{}
+	}
+	lg, err := GetOrCreateBufferedLogger(output, opts.Roller, opts.Buffer)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +104,27 @@ func (l *errorLogger) Errorf(format string, args ...interface{}) {
 	}
 }
 
+func (l *errorLogger) ErrorfThrottled(key string, ratePerSec int, format string, args ...interface{}) {
+	if l.Logger.disable {
+		return
+	}
+	if l.level >= ERROR {
+		ok, suppressed := errorThrottler.allow(key, ratePerSec, l)
+		if !ok {
+			return
+		}
+		if suppressed > 0 {
+			l.writeSuppressed(key, suppressed)
+		}
+		s := l.codeFormatter(ErrorPre, defaultErrorCode, format)
+		l.Logger.Printf(s, args...)
+	}
+}
+
+func (l *errorLogger) writeSuppressed(key string, suppressed int64) {
+	l.Logger.Printf(l.codeFormatter(ErrorPre, defaultErrorCode, "suppressed %d similar messages of %s"), suppressed, key)
+}
+
 func (l *errorLogger) Alertf(errkey types.ErrorKey, format string, args ...interface{}) {
 	if l.Logger.disable {
 		return
@@ -27,6 +27,7 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	gsyslog "github.com/hashicorp/go-syslog"
@@ -59,6 +60,8 @@ var (
 // Reopen() error
 // Toggle(disable bool)
 type Logger struct {
+	// dropped is the logs dropped by the buffer overflow, it is accessed atomically
+	dropped int64
 	// output is the log's output path
 	// if output is empty(""), it is equals to stderr
 	output string
@@ -69,18 +72,63 @@ type Logger struct {
 	// disable presents the logger state. if disable is true, the logger will write nothing
 	// the default value is false
 	disable bool
+	// flushInterval is the interval the buffered logs are written in a batch
+	flushInterval time.Duration
+	// overflow is the policy if the buffer is full
+	overflow OverflowPolicy
 	// implementation elements
-	create          time.Time
-	reopenChan      chan struct{}
-	closeChan       chan struct{}
+	create time.Time
+	// the requests are done if the channels are closed
+	reopenChan      chan chan struct{}
+	closeChan       chan chan struct{}
 	writeBufferChan chan types.IoBuffer
 }
 
+// OverflowPolicy is the policy of the logs written when the logger's buffer is full
+type OverflowPolicy uint8
+
+// OverflowPolicy types
+const (
+	// OverflowDrop drops the log and counts it
+	OverflowDrop OverflowPolicy = iota
+	// OverflowBlock blocks the writing until the buffer is available
+	OverflowBlock
+)
+
+// defaultBufferSize is the max logs buffered by default
+const defaultBufferSize = 1000
+
+// BufferOptions is the options of the logs buffered before written by the logger's goroutine
+type BufferOptions struct {
+	// Size is the max logs buffered, the default size is used if it is zero
+	Size int
+	// FlushInterval is the interval the buffered logs are written in a batch,
+	// the logs are written as soon as possible if it is zero.
+	// The buffer should be large enough to keep the logs in a flush interval
+	FlushInterval time.Duration
+	// Overflow is the policy if the buffer is full
+	Overflow OverflowPolicy
+}
+
+// droppedLines is the logs dropped by all the loggers
+var droppedLines int64
+
+// DroppedLines returns the logs dropped by the buffer overflow of all the loggers
+func DroppedLines() int64 {
+	return atomic.LoadInt64(&droppedLines)
+}
+
 // loggers keeps all Logger we created
 // key is output, same output reference the same Logger
 var loggers sync.Map // map[string]*Logger
 
 func GetOrCreateLogger(output string, roller *Roller) (*Logger, error) {
+	return GetOrCreateBufferedLogger(output, roller, nil)
+}
+
+// GetOrCreateBufferedLogger creates a Logger buffers the logs by the options, the default buffer is used if it is nil.
+// The exists Logger of the output is returned, the roller and the options are ignored
+func GetOrCreateBufferedLogger(output string, roller *Roller, opts *BufferOptions) (*Logger, error) {
 	if lg, ok := loggers.Load(output); ok {
 		return lg.(*Logger), nil
 	}
@@ -88,13 +136,22 @@ func GetOrCreateLogger(output string, roller *Roller) (*Logger, error) {
 	if roller == nil {
 		roller = defaultRoller
 	}
+	if opts == nil {
+		opts = &BufferOptions{}
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = defaultBufferSize
+	}
 
 	lg := &Logger{
 		output:          output,
 		roller:          roller,
-		writeBufferChan: make(chan types.IoBuffer, 1000),
-		reopenChan:      make(chan struct{}),
-		closeChan:       make(chan struct{}),
+		flushInterval:   opts.FlushInterval,
+		overflow:        opts.Overflow,
+		writeBufferChan: make(chan types.IoBuffer, size),
+		reopenChan:      make(chan chan struct{}),
+		closeChan:       make(chan chan struct{}),
 		// writer and create will be setted in start()
 	}
 	err := lg.start()
@@ -154,31 +211,39 @@ func (l *Logger) handler() {
 			go l.handler()
 		}
 	}()
+	// the buffered logs are written by the ticker if the flush interval is configured
+	var flushC <-chan time.Time
+	writeBufferChan := l.writeBufferChan
+	if l.flushInterval > 0 {
+		ticker := time.NewTicker(l.flushInterval)
+		defer ticker.Stop()
+		flushC = ticker.C
+		writeBufferChan = nil
+	}
 	var buf types.IoBuffer
 	for {
 		select {
-		case <-l.reopenChan:
+		case done := <-l.reopenChan:
 			// reopen is used for roller
+			// the buffered logs are written before reopen, so the tail is not lost
+			l.flush(false)
 			err := l.reopen()
+			close(done)
 			if err == nil {
 				return
 			}
 			DefaultLogger.Infof("%s reopen failed : %v", l.output, err)
-		case <-l.closeChan:
+		case done := <-l.closeChan:
 			// flush all buffers before close
 			// make sure all logs are outputed
 			// a closed logger can not write anymore
-			for {
-				select {
-				case buf = <-l.writeBufferChan:
-					buf.WriteTo(l)
-					buffer.PutIoBuffer(buf)
-				default:
-					l.stop()
-					return
-				}
-			}
-		case buf = <-l.writeBufferChan:
+			l.flush(false)
+			l.stop()
+			close(done)
+			return
+		case <-flushC:
+			l.flush(true)
+		case buf = <-writeBufferChan:
 			for i := 0; i < 20; i++ {
 				select {
 				case b := <-l.writeBufferChan:
@@ -195,6 +260,41 @@ func (l *Logger) handler() {
 	}
 }
 
+// flushBatchSize is the max bytes written in a batch when the buffer is flushed
+const flushBatchSize = 64 * 1024
+
+// flush writes the buffered logs in batches, only the logs buffered before are written if bounded,
+// otherwise it returns until the buffer is empty
+func (l *Logger) flush(bounded bool) {
+	n := len(l.writeBufferChan)
+	var batch types.IoBuffer
+	for i := 0; !bounded || i < n; i++ {
+		var buf types.IoBuffer
+		select {
+		case buf = <-l.writeBufferChan:
+		default:
+		}
+		if buf == nil {
+			break
+		}
+		if batch == nil {
+			batch = buf
+		} else {
+			batch.Write(buf.Bytes())
+			buffer.PutIoBuffer(buf)
+		}
+		if batch.Len() >= flushBatchSize {
+			batch.WriteTo(l)
+			buffer.PutIoBuffer(batch)
+			batch = nil
+		}
+	}
+	if batch != nil {
+		batch.WriteTo(l)
+		buffer.PutIoBuffer(batch)
+	}
+}
+
 func (l *Logger) stop() error {
 	if l.writer == os.Stdout || l.writer == os.Stderr {
 		return nil
@@ -233,8 +333,9 @@ func (l *Logger) Print(buf types.IoBuffer, discard bool) error {
 	select {
 	case l.writeBufferChan <- buf:
 	default:
-		// todo: configurable
-		if discard {
+		if discard && l.overflow == OverflowDrop {
+			atomic.AddInt64(&l.dropped, 1)
+			atomic.AddInt64(&droppedLines, 1)
 			return types.ErrChanFull
 		} else {
 			l.writeBufferChan <- buf
@@ -243,6 +344,11 @@ func (l *Logger) Print(buf types.IoBuffer, discard bool) error {
 	return nil
 }
 
+// Dropped returns the logs dropped by the buffer overflow
+func (l *Logger) Dropped() int64 {
+	return atomic.LoadInt64(&l.dropped)
+}
+
 func (l *Logger) Println(args ...interface{}) {
 	if l.disable {
 		return
@@ -322,18 +428,24 @@ func (l *Logger) Write(p []byte) (n int, err error) {
 	return l.writer.Write(p)
 }
 
+// Close returns after the buffered logs are written
 func (l *Logger) Close() error {
-	l.closeChan <- struct{}{}
+	done := make(chan struct{})
+	l.closeChan <- done
+	<-done
 	return nil
 }
 
+// Reopen returns after the buffered logs are written and the output is reopened
 func (l *Logger) Reopen() error {
 	defer func() {
 		if r := recover(); r != nil {
 			debug.PrintStack()
 		}
 	}()
-	l.reopenChan <- struct{}{}
+	done := make(chan struct{})
+	l.reopenChan <- done
+	<-done
 	return nil
 }
 
@@ -341,6 +453,11 @@ func (l *Logger) Toggle(disable bool) {
 	l.disable = disable
 }
 
+// Disabled returns true if the logger is disabled
+func (l *Logger) Disabled() bool {
+	return l.disable
+}
+
 // syslogAddress
 type syslogAddress struct {
 	network string
@@ -19,6 +19,7 @@ package log
 
 import (
 	"errors"
+	"sort"
 	"sync"
 )
 
@@ -52,8 +53,8 @@ type ErrorLoggerManager struct {
 }
 
 // GetOrCreateErrorLogger returns a ErrorLogger based on the output(p).
-// If Logger not exists, and create function is not nil, creates a new logger
-func (mng *ErrorLoggerManager) GetOrCreateErrorLogger(p string, level Level, f CreateErrorLoggerFunc) (ErrorLogger, error) {
+// If Logger not exists, and create function is not nil, creates a new logger with the options
+func (mng *ErrorLoggerManager) GetOrCreateErrorLogger(p string, level Level, f CreateErrorLoggerFunc, opts *ErrorLoggerOptions) (ErrorLogger, error) {
 	mng.mutex.Lock()
 	defer mng.mutex.Unlock()
 	if lg, ok := mng.managers[p]; ok {
@@ -63,7 +64,7 @@ func (mng *ErrorLoggerManager) GetOrCreateErrorLogger(p string, level Level, f C
 	if f == nil {
 		return nil, ErrNoLoggerFound
 	}
-	lg, err := f(p, level)
+	lg, err := f(p, level, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -86,11 +87,24 @@ func GetErrorLoggerManagerInstance() *ErrorLoggerManager {
 
 // GetOrCreateDefaultErrorLogger used default create function
 func GetOrCreateDefaultErrorLogger(p string, level Level) (ErrorLogger, error) {
-	return errorLoggerManagerInstance.GetOrCreateErrorLogger(p, level, CreateDefaultErrorLogger)
+	return errorLoggerManagerInstance.GetOrCreateErrorLogger(p, level, CreateDefaultErrorLogger, nil)
+}
+
+// GetOrCreateRollingErrorLogger used default create function, the created logger is rotated by the roller
+func GetOrCreateRollingErrorLogger(p string, level Level, roller *Roller) (ErrorLogger, error) {
+	return errorLoggerManagerInstance.GetOrCreateErrorLogger(p, level, CreateDefaultErrorLogger, &ErrorLoggerOptions{
+		Roller: roller,
+	})
 }
 
 func InitDefaultLogger(output string, level Level) (err error) {
-	DefaultLogger, err = GetOrCreateDefaultErrorLogger(output, level)
+	return InitDefaultLoggerWithOptions(output, level, nil)
+}
+
+// InitDefaultLoggerWithOptions inits the default logger that is rotated and buffered by the options,
+// the global roller and the default buffer are used if the options are nil
+func InitDefaultLoggerWithOptions(output string, level Level, opts *ErrorLoggerOptions) (err error) {
+	DefaultLogger, err = errorLoggerManagerInstance.GetOrCreateErrorLogger(output, level, CreateDefaultErrorLogger, opts)
 	if err == nil {
 		Proxy, err = CreateDefaultProxyLogger(output, level)
 	}
@@ -100,7 +114,7 @@ func InitDefaultLogger(output string, level Level) (err error) {
 // UpdateErrorLoggerLevel updates the exists ErrorLogger's Level
 func UpdateErrorLoggerLevel(p string, level Level) bool {
 	// we use a nil create function means just get exists logger
-	if lg, _ := errorLoggerManagerInstance.GetOrCreateErrorLogger(p, 0, nil); lg != nil {
+	if lg, _ := errorLoggerManagerInstance.GetOrCreateErrorLogger(p, 0, nil, nil); lg != nil {
 		lg.SetLogLevel(level)
 		return true
 	}
@@ -110,7 +124,7 @@ func UpdateErrorLoggerLevel(p string, level Level) bool {
 // ToggleLogger enable/disable the exists logger, include ErrorLogger and Logger
 func ToggleLogger(p string, disable bool) bool {
 	// find ErrorLogger
-	if lg, _ := errorLoggerManagerInstance.GetOrCreateErrorLogger(p, 0, nil); lg != nil {
+	if lg, _ := errorLoggerManagerInstance.GetOrCreateErrorLogger(p, 0, nil, nil); lg != nil {
 		lg.Toggle(disable)
 		return true
 	}
@@ -122,6 +136,62 @@ func ToggleLogger(p string, disable bool) bool {
 	return false
 }
 
+// ToggleAllLoggers enable/disable all the exists loggers, include ErrorLogger and Logger
+func ToggleAllLoggers(disable bool) {
+	// the ErrorLogger shares the Logger with the same output
+	loggers.Range(func(key, value interface{}) bool {
+		value.(*Logger).Toggle(disable)
+		return true
+	})
+}
+
+// UpdateAllErrorLoggerLevel updates all the exists ErrorLoggers' Level
+func UpdateAllErrorLoggerLevel(level Level) {
+	errorLoggerManagerInstance.SetAllErrorLoggerLevel(level)
+}
+
+// LoggerState presents the state of an exists logger
+type LoggerState struct {
+	Path string
+	// IsErrorLogger is true if the logger is an ErrorLogger, only the ErrorLogger has a Level
+	IsErrorLogger bool
+	Level         Level
+	Disabled      bool
+}
+
+// GetLoggerStates returns the states of all the exists loggers, include ErrorLogger and Logger, sorted by the path
+func GetLoggerStates() []LoggerState {
+	states := make(map[string]LoggerState)
+	errorLoggerManagerInstance.mutex.Lock()
+	for p, lg := range errorLoggerManagerInstance.managers {
+		states[p] = LoggerState{
+			Path:          p,
+			IsErrorLogger: true,
+			Level:         lg.GetLogLevel(),
+			Disabled:      lg.Disabled(),
+		}
+	}
+	errorLoggerManagerInstance.mutex.Unlock()
+	loggers.Range(func(key, value interface{}) bool {
+		p := key.(string)
+		if _, ok := states[p]; !ok {
+			states[p] = LoggerState{
+				Path:     p,
+				Disabled: value.(*Logger).Disabled(),
+			}
+		}
+		return true
+	})
+	result := make([]LoggerState, 0, len(states))
+	for _, state := range states {
+		result = append(result, state)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
 // Reopen all logger
 func Reopen() (err error) {
 	loggers.Range(func(key, value interface{}) bool {
@@ -19,8 +19,12 @@ package log
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestUpdateLoggerConfig(t *testing.T) {
@@ -109,3 +113,43 @@ func TestSetAllErrorLogLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestRollingErrorLogger(t *testing.T) {
+	// reset for test
+	errorLoggerManagerInstance.managers = make(map[string]ErrorLogger)
+	loggers = sync.Map{}
+	logDir := "/tmp/mosn_rolling"
+	os.RemoveAll(logDir)
+	defer os.RemoveAll(logDir)
+	lg, err := GetOrCreateRollingErrorLogger(filepath.Join(logDir, "error.log"), INFO, &Roller{
+		MaxSize:    1,
+		MaxBackups: 1,
+		Compress:   true,
+		LocalTime:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// about 2.5 MB logs, rotates twice
+	line := strings.Repeat("a", 64*1024)
+	for i := 0; i < 40; i++ {
+		lg.Infof("%s", line)
+		time.Sleep(time.Millisecond)
+	}
+	lg.(*errorLogger).Logger.Close()
+	// the rotated files are compressed and pruned in background
+	var backups []string
+	for i := 0; i < 50; i++ {
+		backups, _ = filepath.Glob(filepath.Join(logDir, "error-*"))
+		if len(backups) == 1 && strings.HasSuffix(backups[0], ".log.gz") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("expected one compressed backup, but got %v", backups)
+	}
+	if info, err := os.Stat(filepath.Join(logDir, "error.log")); err != nil || info.Size() > 1024*1024 {
+		t.Fatalf("log file is not rotated, error: %v", err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -184,3 +185,88 @@ func TestLoglocalOffset(t *testing.T) {
 	t.Logf("%d %d\n", (t1.Unix())/defaultRollerTime, (t1.Unix() / defaultRollerTime))
 	t.Logf("%d %d\n", (t1.Unix()+int64(offset))/defaultRollerTime, (t2.Unix()+int64(offset))/defaultRollerTime)
 }
+
+func TestLogBufferOverflowDrop(t *testing.T) {
+	logName := "/tmp/mosn_bench/buffer_drop.log"
+	os.Remove(logName)
+	loggers.Delete(logName) // the closed logger is not reused
+	// the buffered logs are not written until closed
+	l, err := GetOrCreateBufferedLogger(logName, nil, &BufferOptions{
+		Size:          1,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := DroppedLines()
+	for i := 0; i < 3; i++ {
+		l.Printf("log %d", i)
+	}
+	if l.Dropped() != 2 || DroppedLines()-total != 2 {
+		t.Fatalf("expected 2 logs dropped, but got %d, total %d", l.Dropped(), DroppedLines()-total)
+	}
+	// the buffered logs are written when closed
+	l.Close()
+	b, err := ioutil.ReadFile(logName)
+	if err != nil || string(b) != "log 0\n" {
+		t.Fatalf("expected the buffered log is written, but got %s, error: %v", string(b), err)
+	}
+}
+
+func TestLogBufferOverflowBlock(t *testing.T) {
+	logName := "/tmp/mosn_bench/buffer_block.log"
+	os.Remove(logName)
+	loggers.Delete(logName) // the closed logger is not reused
+	l, err := GetOrCreateBufferedLogger(logName, nil, &BufferOptions{
+		Size:          1,
+		FlushInterval: time.Hour,
+		Overflow:      OverflowBlock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Printf("log 0")
+	done := make(chan struct{})
+	go func() {
+		l.Printf("log 1")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected the log is blocked if the buffer is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// the buffered logs are written when reopened, the blocked log may be written too
+	l.Reopen()
+	b, _ := ioutil.ReadFile(logName)
+	if !strings.HasPrefix(string(b), "log 0\n") {
+		t.Fatalf("expected the buffered log is written when reopened, but got %s", string(b))
+	}
+	<-done
+	l.Close()
+	b, _ = ioutil.ReadFile(logName)
+	if string(b) != "log 0\nlog 1\n" || l.Dropped() != 0 {
+		t.Fatalf("unexpected logs: %s, dropped: %d", string(b), l.Dropped())
+	}
+}
+
+func TestLogBufferFlushInterval(t *testing.T) {
+	logName := "/tmp/mosn_bench/buffer_flush.log"
+	os.Remove(logName)
+	loggers.Delete(logName) // the closed logger is not reused
+	l, err := GetOrCreateBufferedLogger(logName, nil, &BufferOptions{
+		FlushInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < 10; i++ {
+		l.Printf("log %d", i)
+	}
+	time.Sleep(200 * time.Millisecond)
+	b, _ := ioutil.ReadFile(logName)
+	if lines := strings.Count(string(b), "\n"); lines != 10 {
+		t.Fatalf("expected 10 logs flushed, but got %d", lines)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// payloadDumpRate is the max dumps per second of the same title
+const payloadDumpRate = 100
+
+const redactedValue = "<redacted>"
+
+// DefaultRedactHeaders are the headers redacted in the dumps by default
+var DefaultRedactHeaders = []string{"authorization", "cookie", "set-cookie"}
+
+// PayloadDumper dumps the headers and the first bytes of the bodies the stream codecs sent and received
+// at the TRACE level, it is stored in the connection context by the listener
+type PayloadDumper struct {
+	maxBytes int
+	// redactHeaders are the lower case header names
+	redactHeaders map[string]struct{}
+}
+
+// NewPayloadDumper creates a dumper dumps the first maxBytes of the bodies, returns nil if maxBytes is not positive.
+// the DefaultRedactHeaders are used if the redactHeaders is empty
+func NewPayloadDumper(maxBytes int, redactHeaders []string) *PayloadDumper {
+	if maxBytes <= 0 {
+		return nil
+	}
+	if len(redactHeaders) == 0 {
+		redactHeaders = DefaultRedactHeaders
+	}
+	d := &PayloadDumper{
+		maxBytes:      maxBytes,
+		redactHeaders: make(map[string]struct{}, len(redactHeaders)),
+	}
+	for _, h := range redactHeaders {
+		d.redactHeaders[strings.ToLower(h)] = struct{}{}
+	}
+	return d
+}
+
+// PayloadDumpEnabled returns true if the context has a PayloadDumper and the logger of the context is at the TRACE level
+func PayloadDumpEnabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if d, ok := mosnctx.Get(ctx, types.ContextKeyPayloadDumper).(*PayloadDumper); !ok || d == nil {
+		return false
+	}
+	return ByContext(ctx).GetLogLevel() >= TRACE
+}
+
+// DumpPayload dumps the start line, the headers and the body if the context has a PayloadDumper and the logger of the
+// context is at the TRACE level. the dumps are limited by the title to avoid flooding the disk, so the title should
+// be a constant, and the start line is the request line or the status line if the protocol has one
+func DumpPayload(ctx context.Context, title, startLine string, headers types.HeaderMap, body []byte) {
+	if ctx == nil {
+		return
+	}
+	d, ok := mosnctx.Get(ctx, types.ContextKeyPayloadDumper).(*PayloadDumper)
+	if !ok || d == nil {
+		return
+	}
+	lg := ByContext(ctx)
+	if lg.GetLogLevel() < TRACE {
+		return
+	}
+	if ok, _ := errorThrottler.allow("payload."+title, payloadDumpRate, nil); !ok {
+		return
+	}
+	lg.Tracef("[payload] %s\n%s", title, d.dump(startLine, headers, body))
+}
+
+func (d *PayloadDumper) dump(startLine string, headers types.HeaderMap, body []byte) string {
+	var sb strings.Builder
+	if startLine != "" {
+		sb.WriteString(startLine + "\n")
+	}
+	sb.WriteString("headers:\n")
+	if headers != nil {
+		headers.Range(func(key, value string) bool {
+			if _, ok := d.redactHeaders[strings.ToLower(key)]; ok {
+				value = redactedValue
+			}
+			sb.WriteString("  " + key + ": " + value + "\n")
+			return true
+		})
+	}
+	sb.WriteString("body: " + strconv.Itoa(len(body)) + " bytes")
+	if len(body) > d.maxBytes {
+		body = body[:d.maxBytes]
+		sb.WriteString(", first " + strconv.Itoa(d.maxBytes) + " bytes")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(hex.Dump(body))
+	return sb.String()
+}
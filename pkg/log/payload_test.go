@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestPayloadDumper(t *testing.T) {
+	if NewPayloadDumper(0, nil) != nil {
+		t.Fatal("expected no dumper if the bytes is zero")
+	}
+	d := NewPayloadDumper(4, nil)
+	s := d.dump("GET / HTTP/1.1", protocol.CommonHeader{
+		"Authorization": "Basic dGVzdA==",
+		"service":       "test",
+	}, []byte("hello world"))
+	if strings.Contains(s, "dGVzdA==") || !strings.Contains(s, "Authorization: <redacted>") {
+		t.Errorf("authorization is not redacted: %s", s)
+	}
+	if !strings.HasPrefix(s, "GET / HTTP/1.1\n") || !strings.Contains(s, "service: test") {
+		t.Errorf("dump is not expected: %s", s)
+	}
+	if !strings.Contains(s, "body: 11 bytes, first 4 bytes") || !strings.Contains(s, "|hell|") || strings.Contains(s, "world") {
+		t.Errorf("body is not truncated: %s", s)
+	}
+	// configured redact headers
+	s = NewPayloadDumper(4, []string{"Service"}).dump("", protocol.CommonHeader{
+		"authorization": "test",
+		"service":       "secret",
+	}, nil)
+	if !strings.Contains(s, "authorization: test") || strings.Contains(s, "secret") {
+		t.Errorf("dump is not expected: %s", s)
+	}
+}
+
+func TestDumpPayload(t *testing.T) {
+	logName := "/tmp/mosn/payload_dump.log"
+	os.Remove(logName)
+	loggers.Delete(logName)
+	lg, err := CreateDefaultErrorLogger(logName, DEBUG, nil)
+	if err != nil {
+		t.Fatal("create logger failed")
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyLogger, lg)
+	headers := protocol.CommonHeader{"service": "test"}
+	// no dumper
+	if PayloadDumpEnabled(ctx) {
+		t.Fatal("expected dump disabled without dumper")
+	}
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyPayloadDumper, NewPayloadDumper(16, nil))
+	// not trace level
+	if PayloadDumpEnabled(ctx) {
+		t.Fatal("expected dump disabled if the level is not trace")
+	}
+	DumpPayload(ctx, "test dump", "", headers, []byte("ignored"))
+	lg.SetLogLevel(TRACE)
+	if !PayloadDumpEnabled(ctx) {
+		t.Fatal("expected dump enabled")
+	}
+	DumpPayload(ctx, "test dump", "", headers, []byte("dumped"))
+	time.Sleep(time.Second) // wait flush
+	lines, err := readLines(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Join(lines, "\n")
+	if !strings.Contains(data, "[TRACE] [payload] test dump") || !strings.Contains(data, "|dumped|") || strings.Contains(data, "ignored") {
+		t.Errorf("dump is not expected: %s", data)
+	}
+}
@@ -87,6 +87,27 @@ func (l *proxyLogger) Errorf(ctx context.Context, format string, args ...interfa
 	}
 }
 
+func (l *proxyLogger) ErrorfThrottled(ctx context.Context, key string, ratePerSec int, format string, args ...interface{}) {
+	if l.disable {
+		return
+	}
+	if l.level >= ERROR {
+		ok, suppressed := errorThrottler.allow(key, ratePerSec, l)
+		if !ok {
+			return
+		}
+		if suppressed > 0 {
+			l.writeSuppressed(key, suppressed)
+		}
+		s := logTime() + " " + ErrorPre + " [" + defaultErrorCode + "] " + traceInfo(ctx) + " " + format
+		l.Printf(s, args...)
+	}
+}
+
+func (l *proxyLogger) writeSuppressed(key string, suppressed int64) {
+	l.Printf(logTime()+" "+ErrorPre+" ["+defaultErrorCode+"] suppressed %d similar messages of %s", suppressed, key)
+}
+
 func (l *proxyLogger) Alertf(ctx context.Context, errkey types.ErrorKey, format string, args ...interface{}) {
 	if l.disable {
 		return
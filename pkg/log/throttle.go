@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const throttleShards = 32
+
+// throttleCounter counts the logs of a key in the current second, all the fields are accessed atomically
+type throttleCounter struct {
+	// second is the unix second of the current window
+	second int64
+	// count is the logs in the current window
+	count int64
+	// suppressed is the logs suppressed since the last summary
+	suppressed int64
+	// writer writes the summary of the suppressed logs if no log of the key is allowed after the window rolls over,
+	// the value is a suppressedWriterHolder
+	writer atomic.Value
+}
+
+// suppressedWriterHolder wraps the suppressedWriter stored in the atomic.Value,
+// the writers of different concrete types are stored with the same type
+type suppressedWriterHolder struct {
+	writer suppressedWriter
+}
+
+// suppressedWriter writes the summary line of the logs suppressed
+type suppressedWriter interface {
+	writeSuppressed(key string, suppressed int64)
+}
+
+type throttleShard struct {
+	mutex    sync.RWMutex
+	counters map[string]*throttleCounter
+}
+
+// logThrottler limits the logs per key per second.
+// the keys are sharded, so the logs with different keys rarely contend for a lock,
+// and the logs with the same key only update the atomic counters
+type logThrottler struct {
+	shards [throttleShards]throttleShard
+	// the summaries are flushed every second after the first log is suppressed
+	flushOnce sync.Once
+}
+
+func newLogThrottler() *logThrottler {
+	t := &logThrottler{}
+	for i := range t.shards {
+		t.shards[i].counters = make(map[string]*throttleCounter)
+	}
+	return t
+}
+
+// errorThrottler is shared by all the loggers, so the throttled logs should use a unique key
+var errorThrottler = newLogThrottler()
+
+// fnv-1a hash, no allocation
+func throttleHash(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
+}
+
+func (t *logThrottler) counter(key string) *throttleCounter {
+	shard := &t.shards[throttleHash(key)%throttleShards]
+	shard.mutex.RLock()
+	c, ok := shard.counters[key]
+	shard.mutex.RUnlock()
+	if ok {
+		return c
+	}
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if c, ok = shard.counters[key]; !ok {
+		c = &throttleCounter{}
+		shard.counters[key] = c
+	}
+	return c
+}
+
+// allow returns true if the log with the key can be written in the current second.
+// when the first log of a new second is allowed, it also returns the logs suppressed before, so the caller
+// can write a summary line. If no log of the key comes after the window rolls over, the summary is written
+// by the writer, it can be nil if the summary is not needed
+func (t *logThrottler) allow(key string, ratePerSec int, writer suppressedWriter) (bool, int64) {
+	if ratePerSec <= 0 {
+		return true, 0
+	}
+	c := t.counter(key)
+	now := time.Now().Unix()
+	var suppressed int64
+	if second := atomic.LoadInt64(&c.second); second != now && atomic.CompareAndSwapInt64(&c.second, second, now) {
+		atomic.StoreInt64(&c.count, 0)
+		suppressed = atomic.SwapInt64(&c.suppressed, 0)
+	}
+	if atomic.AddInt64(&c.count, 1) > int64(ratePerSec) {
+		// keeps the suppressed logs for the next summary
+		atomic.AddInt64(&c.suppressed, suppressed+1)
+		if writer != nil {
+			c.writer.Store(suppressedWriterHolder{writer: writer})
+			t.flushOnce.Do(func() {
+				go t.flushLoop()
+			})
+		}
+		return false, 0
+	}
+	return true, suppressed
+}
+
+func (t *logThrottler) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		t.flush(now.Unix())
+	}
+}
+
+// flush writes the summaries of the keys whose window rolled over before now without a new log,
+// the summaries of the keys with a new log are returned by allow
+func (t *logThrottler) flush(now int64) {
+	for i := range t.shards {
+		shard := &t.shards[i]
+		shard.mutex.RLock()
+		for key, c := range shard.counters {
+			if atomic.LoadInt64(&c.second) >= now || atomic.LoadInt64(&c.suppressed) == 0 {
+				continue
+			}
+			holder, ok := c.writer.Load().(suppressedWriterHolder)
+			if !ok {
+				continue
+			}
+			if suppressed := atomic.SwapInt64(&c.suppressed, 0); suppressed > 0 {
+				holder.writer.writeSuppressed(key, suppressed)
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitNextSecond makes sure the test runs in one second window
+func waitNextSecond() {
+	now := time.Now()
+	time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now) + 10*time.Millisecond)
+}
+
+func TestLogThrottler(t *testing.T) {
+	throttler := newLogThrottler()
+	waitNextSecond()
+	var allowed, denied int64
+	var mutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				ok, _ := throttler.allow("test", 5, nil)
+				mutex.Lock()
+				if ok {
+					allowed++
+				} else {
+					denied++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 || denied != 95 {
+		t.Fatalf("expected 5 logs allowed, but got allowed: %d, denied: %d", allowed, denied)
+	}
+	// other keys are not limited
+	if ok, _ := throttler.allow("other", 5, nil); !ok {
+		t.Fatal("expected other key allowed")
+	}
+	// rate zero means no limit
+	if ok, _ := throttler.allow("test", 0, nil); !ok {
+		t.Fatal("expected no limit allowed")
+	}
+	waitNextSecond()
+	ok, suppressed := throttler.allow("test", 5, nil)
+	if !ok || suppressed != 95 {
+		t.Fatalf("expected allowed with 95 suppressed, but got: %v, %d", ok, suppressed)
+	}
+	if ok, suppressed := throttler.allow("test", 5, nil); !ok || suppressed != 0 {
+		t.Fatalf("expected allowed without summary, but got: %v, %d", ok, suppressed)
+	}
+}
+
+type testSuppressedWriter struct {
+	mutex      sync.Mutex
+	suppressed map[string]int64
+}
+
+func (w *testSuppressedWriter) writeSuppressed(key string, suppressed int64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.suppressed[key] += suppressed
+}
+
+func TestLogThrottlerFlush(t *testing.T) {
+	throttler := newLogThrottler()
+	writer := &testSuppressedWriter{suppressed: map[string]int64{}}
+	waitNextSecond()
+	for i := 0; i < 10; i++ {
+		throttler.allow("test", 5, writer)
+	}
+	now := time.Now().Unix()
+	// the window is not rolled over
+	throttler.flush(now)
+	if len(writer.suppressed) != 0 {
+		t.Fatalf("expected no summary in the current window, but got: %v", writer.suppressed)
+	}
+	// no more logs of the key after the window rolls over
+	throttler.flush(now + 1)
+	if writer.suppressed["test"] != 5 {
+		t.Fatalf("expected 5 suppressed, but got: %v", writer.suppressed)
+	}
+	// the summary is written once
+	throttler.flush(now + 2)
+	if writer.suppressed["test"] != 5 {
+		t.Fatalf("expected summary written once, but got: %v", writer.suppressed)
+	}
+}
+
+// otherSuppressedWriter is a suppressedWriter of another type
+type otherSuppressedWriter struct {
+	testSuppressedWriter
+}
+
+func TestLogThrottlerWriters(t *testing.T) {
+	throttler := newLogThrottler()
+	writer := &testSuppressedWriter{suppressed: map[string]int64{}}
+	other := &otherSuppressedWriter{testSuppressedWriter{suppressed: map[string]int64{}}}
+	waitNextSecond()
+	// the throttler is shared by the loggers, the writers of different types are used for the same key
+	for i := 0; i < 10; i++ {
+		throttler.allow("test", 5, writer)
+		throttler.allow("test", 5, other)
+	}
+	throttler.flush(time.Now().Unix() + 1)
+	// the latest writer writes the summary
+	if other.suppressed["test"] != 15 || len(writer.suppressed) != 0 {
+		t.Fatalf("expected 15 suppressed written by the latest writer, but got: %v, %v", other.suppressed, writer.suppressed)
+	}
+}
+
+func TestErrorLogThrottled(t *testing.T) {
+	logName := "/tmp/mosn/error_log_throttled.log"
+	os.Remove(logName)
+	loggers.Delete(logName)
+	lg, err := CreateDefaultErrorLogger(logName, ERROR, nil)
+	if err != nil {
+		t.Fatal("create logger failed")
+	}
+	key := fmt.Sprintf("test.throttled.%d", time.Now().UnixNano())
+	waitNextSecond()
+	for i := 0; i < 10; i++ {
+		lg.ErrorfThrottled(key, 2, "error %d", i)
+	}
+	waitNextSecond()
+	lg.ErrorfThrottled(key, 2, "error %d", 10)
+	time.Sleep(time.Second) // wait flush
+	lines, err := readLines(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, but got: %v", lines)
+	}
+	if !strings.HasSuffix(lines[0], "error 0") || !strings.HasSuffix(lines[1], "error 1") ||
+		!strings.HasSuffix(lines[2], "suppressed 8 similar messages of "+key) || !strings.HasSuffix(lines[3], "error 10") {
+		t.Errorf("log lines are not expected: %v", lines)
+	}
+}
+
+func BenchmarkLogThrottler(b *testing.B) {
+	throttler := newLogThrottler()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			throttler.allow("bench", 10, nil)
+		}
+	})
+}
@@ -63,6 +63,10 @@ type ErrorLogger interface {
 
 	Tracef(format string, args ...interface{})
 
+	// ErrorfThrottled is a wrapper of Errorf that writes at most ratePerSec logs per second with the same key,
+	// the number of the suppressed logs is written before the next log of the key is written
+	ErrorfThrottled(key string, ratePerSec int, format string, args ...interface{})
+
 	Fatalf(format string, args ...interface{})
 
 	Fatal(args ...interface{})
@@ -76,6 +80,8 @@ type ErrorLogger interface {
 
 	// Toggle disable/enable the logger
 	Toggle(disable bool)
+	// Disabled returns true if the logger is disabled
+	Disabled() bool
 }
 
 // ProxyLogger generates lines of output to an io.Writer, works for data flow
@@ -91,6 +97,10 @@ type ProxyLogger interface {
 
 	Errorf(ctx context.Context, format string, args ...interface{})
 
+	// ErrorfThrottled is a wrapper of Errorf that writes at most ratePerSec logs per second with the same key,
+	// the number of the suppressed logs is written before the next log of the key is written
+	ErrorfThrottled(ctx context.Context, key string, ratePerSec int, format string, args ...interface{})
+
 	Fatalf(ctx context.Context, format string, args ...interface{})
 
 	// SetLogLevel updates the log level
@@ -102,5 +112,13 @@ type ProxyLogger interface {
 	Toggle(disable bool)
 }
 
-// CreateErrorLoggerFunc creates a ErrorLogger implementation by output and level
-type CreateErrorLoggerFunc func(output string, level Level) (ErrorLogger, error)
+// ErrorLoggerOptions is the options to create an ErrorLogger
+type ErrorLoggerOptions struct {
+	// Roller rotates the log file, the global roller is used if it is nil
+	Roller *Roller
+	// Buffer is the options of the buffered logs, the default buffer is used if it is nil
+	Buffer *BufferOptions
+}
+
+// CreateErrorLoggerFunc creates a ErrorLogger implementation by output, level and options, the options may be nil
+type CreateErrorLoggerFunc func(output string, level Level, opts *ErrorLoggerOptions) (ErrorLogger, error)
@@ -40,6 +40,29 @@ const (
 	DownstreamRequestTimeTotal   = "request_time_total"
 	DownstreamProcessTime        = "process_time"
 	DownstreamProcessTimeTotal   = "process_time_total"
+	DownstreamResponseWriteError = "response_write_error"
+	DownstreamRequestLoop        = "request_loop_detected"
+	DownstreamRequestNoCluster   = "request_no_cluster_configured"
+	DownstreamBodyTooLarge       = "request_body_too_large"
+	DownstreamBodyOverrun        = "request_body_overrun"
+	DownstreamResponseCode1xx    = "response_code_1xx"
+	DownstreamResponseCode2xx    = "response_code_2xx"
+	DownstreamResponseCode3xx    = "response_code_3xx"
+	DownstreamResponseCode4xx    = "response_code_4xx"
+	DownstreamResponseCode5xx    = "response_code_5xx"
+	DownstreamHeartbeatTotal     = "heartbeat_total"
+	DownstreamConnectionOverflow = "connection_overflow"
+	DownstreamConnectionLimit    = "connection_limit"
+	DownstreamRequestOverflow    = "request_overflow"
+	DownstreamStreamLimit        = "concurrent_stream_limit"
+	DownstreamProxyProtocolError = "proxy_protocol_error"
+	// the connections crossed the write buffer watermarks, the backed up connections are above the high watermark now,
+	// and the configured watermarks
+	DownstreamWriteBufferHighWatermarkTotal = "write_buffer_high_watermark_total"
+	DownstreamWriteBufferLowWatermarkTotal  = "write_buffer_low_watermark_total"
+	DownstreamWriteBufferBackedUp           = "write_buffer_backed_up"
+	DownstreamWriteBufferHighWatermark      = "write_buffer_high_watermark"
+	DownstreamWriteBufferLowWatermark       = "write_buffer_low_watermark"
 )
 
 // NewProxyStats returns a stats with namespace prefix proxy
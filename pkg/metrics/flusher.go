@@ -0,0 +1,262 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// DefaultFlushInterval is used if the flush interval is not configured
+const DefaultFlushInterval = 10 * time.Second
+
+// Sink is a metrics backend that the metrics are pushed to periodically
+type Sink interface {
+	// Flush pushes the snapshots of the metrics stores to the backend
+	Flush(stores []types.Metrics) error
+}
+
+// flushSink wraps a Sink, a slow sink skips the flush until its previous flush is done
+type flushSink struct {
+	name string
+	sink Sink
+	busy uint32
+}
+
+var (
+	flushMux   sync.Mutex
+	flushSinks = make(map[string]*flushSink)
+	flushStop  chan struct{}
+)
+
+// AddSink registers the sink that is flushed periodically, the sink with the same name is replaced
+func AddSink(name string, sink Sink) {
+	flushMux.Lock()
+	defer flushMux.Unlock()
+	flushSinks[name] = &flushSink{
+		name: name,
+		sink: sink,
+	}
+}
+
+// RemoveSink removes the sink by name
+func RemoveSink(name string) {
+	flushMux.Lock()
+	defer flushMux.Unlock()
+	delete(flushSinks, name)
+}
+
+// StartFlush starts a goroutine flushing the metrics to the sinks with the interval,
+// the flushing goroutine started before is stopped
+func StartFlush(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	stop := make(chan struct{})
+
+	flushMux.Lock()
+	if flushStop != nil {
+		close(flushStop)
+	}
+	flushStop = stop
+	flushMux.Unlock()
+
+	utils.GoWithRecover(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				Flush()
+			}
+		}
+	}, nil)
+}
+
+// StopFlush stops the flushing goroutine
+func StopFlush() {
+	flushMux.Lock()
+	defer flushMux.Unlock()
+	if flushStop != nil {
+		close(flushStop)
+		flushStop = nil
+	}
+}
+
+// Flush snapshots all the metrics stores and pushes them to the sinks,
+// each sink is flushed in its own goroutine, so the metrics updates and the other sinks are not blocked
+func Flush() {
+	flush(nil)
+}
+
+// FinalFlush stops the flushing goroutine, freezes the metrics and pushes the final state to the sinks.
+// It is called at the process exit, and waits for the sinks done.
+func FinalFlush() error {
+	StopFlush()
+	Freeze()
+	var wg sync.WaitGroup
+	flush(&wg)
+	wg.Wait()
+	if dropped := DroppedUpdates(); dropped > 0 {
+		log.DefaultLogger.Infof("[metrics] [flush] %d updates are dropped after the metrics frozen", dropped)
+	}
+	return nil
+}
+
+func flush(wg *sync.WaitGroup) {
+	flushMux.Lock()
+	sinks := make([]*flushSink, 0, len(flushSinks))
+	for _, s := range flushSinks {
+		sinks = append(sinks, s)
+	}
+	flushMux.Unlock()
+	if len(sinks) == 0 {
+		return
+	}
+
+	stores := SnapshotAll()
+
+	for _, s := range sinks {
+		if !atomic.CompareAndSwapUint32(&s.busy, 0, 1) {
+			log.DefaultLogger.Warnf("[metrics] [flush] sink %s is still flushing, skip this flush", s.name)
+			continue
+		}
+		if wg != nil {
+			wg.Add(1)
+		}
+		fs := s
+		utils.GoWithRecover(func() {
+			defer func() {
+				atomic.StoreUint32(&fs.busy, 0)
+				if wg != nil {
+					wg.Done()
+				}
+			}()
+			if err := fs.sink.Flush(stores); err != nil {
+				log.DefaultLogger.Warnf("[metrics] [flush] sink %s flush failed: %v", fs.name, err)
+			}
+		}, nil)
+	}
+}
+
+// snapshotMetrics is a read-only copy of a metrics store
+type snapshotMetrics struct {
+	typ       string
+	labels    map[string]string
+	labelKeys []string
+	labelVals []string
+	keys      []string
+	values    map[string]interface{}
+}
+
+// snapshot copies the metrics' values, using the same traversal as the sinks
+func snapshot(m types.Metrics) types.Metrics {
+	keys, vals := m.SortedLabels()
+	s := &snapshotMetrics{
+		typ:       m.Type(),
+		labels:    m.Labels(),
+		labelKeys: keys,
+		labelVals: vals,
+		values:    make(map[string]interface{}),
+	}
+	m.Each(func(key string, i interface{}) {
+		switch metric := i.(type) {
+		case gometrics.Counter:
+			s.values[key] = metric.Snapshot()
+		case gometrics.Gauge:
+			s.values[key] = metric.Snapshot()
+		case gometrics.Histogram:
+			s.values[key] = metric.Snapshot()
+		case gometrics.Meter:
+			s.values[key] = metric.Snapshot()
+		case gometrics.Timer:
+			s.values[key] = metric.Snapshot()
+		default: // unsupported metrics, ignore
+			return
+		}
+		s.keys = append(s.keys, key)
+	})
+	sort.Strings(s.keys)
+	return s
+}
+
+func (s *snapshotMetrics) Type() string {
+	return s.typ
+}
+
+func (s *snapshotMetrics) Labels() map[string]string {
+	return s.labels
+}
+
+func (s *snapshotMetrics) SortedLabels() (keys, values []string) {
+	return s.labelKeys, s.labelVals
+}
+
+func (s *snapshotMetrics) Counter(key string) gometrics.Counter {
+	if c, ok := s.values[key].(gometrics.Counter); ok {
+		return c
+	}
+	return gometrics.NilCounter{}
+}
+
+func (s *snapshotMetrics) Gauge(key string) gometrics.Gauge {
+	if g, ok := s.values[key].(gometrics.Gauge); ok {
+		return g
+	}
+	return gometrics.NilGauge{}
+}
+
+func (s *snapshotMetrics) Histogram(key string) gometrics.Histogram {
+	if h, ok := s.values[key].(gometrics.Histogram); ok {
+		return h
+	}
+	return gometrics.NilHistogram{}
+}
+
+func (s *snapshotMetrics) Meter(key string) gometrics.Meter {
+	if m, ok := s.values[key].(gometrics.Meter); ok {
+		return m
+	}
+	return gometrics.NilMeter{}
+}
+
+func (s *snapshotMetrics) Timer(key string) gometrics.Timer {
+	if t, ok := s.values[key].(gometrics.Timer); ok {
+		return t
+	}
+	return gometrics.NilTimer{}
+}
+
+// Each calls the function in the order of the keys
+func (s *snapshotMetrics) Each(f func(string, interface{})) {
+	for _, key := range s.keys {
+		f(key, s.values[key])
+	}
+}
+
+func (s *snapshotMetrics) UnregisterAll() {
+}
@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/metrics/shm"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// mockSink sends the flushed stores to the channel, and blocks until released if block is set
+type mockSink struct {
+	flushed chan []types.Metrics
+	block   chan struct{}
+	err     error
+	count   int32
+}
+
+func newMockSink() *mockSink {
+	return &mockSink{
+		flushed: make(chan []types.Metrics, 16),
+	}
+}
+
+func (s *mockSink) Flush(stores []types.Metrics) error {
+	atomic.AddInt32(&s.count, 1)
+	s.flushed <- stores
+	if s.block != nil {
+		<-s.block
+	}
+	return s.err
+}
+
+func (s *mockSink) wait(t *testing.T) []types.Metrics {
+	select {
+	case stores := <-s.flushed:
+		return stores
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait sink flush timeout")
+	}
+	return nil
+}
+
+func TestFlushSnapshot(t *testing.T) {
+	zone := shm.InitMetricsZone("TestFlushSnapshot", 10*1024)
+	defer func() {
+		zone.Detach()
+		shm.Reset()
+	}()
+	ResetAll()
+
+	m, _ := NewMetrics("flush", map[string]string{"lk": "lv"})
+	m.Counter("counter").Inc(3)
+	m.Gauge("gauge").Update(5)
+	m.Histogram("histogram").Update(7)
+
+	sink := newMockSink()
+	AddSink("mock", sink)
+	defer RemoveSink("mock")
+
+	Flush()
+	stores := sink.wait(t)
+	if len(stores) != 1 {
+		t.Fatalf("expected 1 store, but got %d", len(stores))
+	}
+	s := stores[0]
+	if s.Type() != "flush" || s.Labels()["lk"] != "lv" {
+		t.Fatalf("unexpected store type %s, labels %v", s.Type(), s.Labels())
+	}
+	// the snapshot is not changed by the updates after the flush
+	m.Counter("counter").Inc(1)
+	if v := s.Counter("counter").Count(); v != 3 {
+		t.Fatalf("expected counter 3, but got %d", v)
+	}
+	if v := s.Gauge("gauge").Value(); v != 5 {
+		t.Fatalf("expected gauge 5, but got %d", v)
+	}
+	if v := s.Histogram("histogram").Max(); v != 7 {
+		t.Fatalf("expected histogram max 7, but got %d", v)
+	}
+	var keys []string
+	s.Each(func(key string, i interface{}) {
+		keys = append(keys, key)
+	})
+	if len(keys) != 3 || keys[0] != "counter" || keys[1] != "gauge" || keys[2] != "histogram" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestFlushSlowSink(t *testing.T) {
+	ResetAll()
+	m, _ := NewMetrics("flush_slow", map[string]string{"lk": "lv"})
+
+	slow := newMockSink()
+	slow.block = make(chan struct{})
+	failed := newMockSink()
+	failed.err = errors.New("flush failed")
+	AddSink("slow", slow)
+	AddSink("failed", failed)
+	defer func() {
+		RemoveSink("slow")
+		RemoveSink("failed")
+	}()
+
+	Flush()
+	slow.wait(t)
+	failed.wait(t)
+	// the slow sink skips the flush, the other sinks and the metrics updates are not blocked
+	m.Counter("counter").Inc(1)
+	Flush()
+	failed.wait(t)
+	if n := atomic.LoadInt32(&slow.count); n != 1 {
+		t.Fatalf("slow sink should skip the flush, but flushed %d times", n)
+	}
+	// the slow sink is flushed again after the previous flush is done
+	close(slow.block)
+	for i := 0; ; i++ {
+		Flush()
+		if atomic.LoadInt32(&slow.count) == 2 {
+			break
+		}
+		if i == 30 {
+			t.Fatal("slow sink is not flushed after the previous flush is done")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestStartFlush(t *testing.T) {
+	ResetAll()
+	sink := newMockSink()
+	AddSink("periodic", sink)
+	defer RemoveSink("periodic")
+
+	StartFlush(10 * time.Millisecond)
+	sink.wait(t)
+	sink.wait(t)
+	StopFlush()
+
+	time.Sleep(50 * time.Millisecond)
+	n := atomic.LoadInt32(&sink.count)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&sink.count) != n {
+		t.Fatal("sink should not be flushed after the flush is stopped")
+	}
+}
+
+func TestFinalFlush(t *testing.T) {
+	zone := shm.InitMetricsZone("TestFinalFlush", 10*1024)
+	defer func() {
+		// free the shm entries before the zone is detached
+		ResetAll()
+		zone.Detach()
+		shm.Reset()
+	}()
+	ResetAll()
+	defer Unfreeze()
+
+	m, _ := NewMetrics("flush_final", map[string]string{"lk": "lv"})
+	m.Counter("counter").Inc(3)
+
+	sink := newMockSink()
+	AddSink("mock", sink)
+	defer RemoveSink("mock")
+
+	StartFlush(time.Hour)
+	if err := FinalFlush(); err != nil {
+		t.Fatal(err)
+	}
+	// the final flush is done when FinalFlush returns
+	if atomic.LoadInt32(&sink.count) != 1 {
+		t.Fatalf("expected sink flushed once, but got %d", sink.count)
+	}
+	m.Counter("counter").Inc(1)
+	if count := (<-sink.flushed)[0].Counter("counter").Count(); count != 3 {
+		t.Fatalf("expected final counter 3, but got %d", count)
+	}
+	if !IsFrozen() || DroppedUpdates() != 1 {
+		t.Fatalf("updates after the final flush should be dropped, dropped: %d", DroppedUpdates())
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the state of a metrics store, the updates are dropped if the store is not active
+const (
+	stateActive uint32 = iota
+	// the metrics are frozen, so the last export at the process exit sees a consistent final state
+	stateFrozen
+	// the metrics store is deleted, its metrics may be freed
+	stateDeleted
+)
+
+// frozen is not zero if the metrics are frozen, the stores created since then are frozen too
+var (
+	frozen         uint32
+	droppedUpdates int64
+
+	freezeMux    sync.Mutex
+	frozenStores []types.Metrics
+)
+
+// Freeze drops all the further counters, gauges and histograms updates, and takes the snapshots
+// of the metrics stores at the freeze point, the snapshots are returned by SnapshotAll since then.
+// An update racing with Freeze may still reach the live value, but never appears in the snapshots.
+func Freeze() {
+	freezeMux.Lock()
+	defer freezeMux.Unlock()
+	if atomic.LoadUint32(&frozen) == 1 {
+		return
+	}
+	atomic.StoreUint32(&frozen, 1)
+	setStoresState(stateActive, stateFrozen)
+	frozenStores = takeSnapshots()
+}
+
+// setStoresState changes the stores' state from old to new
+func setStoresState(old, new uint32) {
+	defaultStore.mutex.RLock()
+	defer defaultStore.mutex.RUnlock()
+	for _, m := range defaultStore.metrics {
+		if s, ok := m.(*metrics); ok {
+			atomic.CompareAndSwapUint32(&s.state, old, new)
+		}
+	}
+}
+
+// Unfreeze is only for test. DO NOT use this if not sure.
+func Unfreeze() {
+	freezeMux.Lock()
+	defer freezeMux.Unlock()
+	atomic.StoreUint32(&frozen, 0)
+	setStoresState(stateFrozen, stateActive)
+	atomic.StoreInt64(&droppedUpdates, 0)
+	frozenStores = nil
+}
+
+// IsFrozen returns true if the metrics are frozen
+func IsFrozen() bool {
+	return atomic.LoadUint32(&frozen) == 1
+}
+
+// DroppedUpdates returns the count of the updates dropped since the metrics are frozen
+func DroppedUpdates() int64 {
+	return atomic.LoadInt64(&droppedUpdates)
+}
+
+// SnapshotAll returns the read-only snapshots of all the metrics stores,
+// the snapshots taken at the freeze point are returned if the metrics are frozen
+func SnapshotAll() []types.Metrics {
+	freezeMux.Lock()
+	defer freezeMux.Unlock()
+	if frozenStores != nil {
+		return frozenStores
+	}
+	return takeSnapshots()
+}
+
+func takeSnapshots() []types.Metrics {
+	all := GetAll()
+	stores := make([]types.Metrics, 0, len(all))
+	for _, m := range all {
+		stores = append(stores, snapshot(m))
+	}
+	return stores
+}
+
+// dropUpdate returns true if the update should be dropped,
+// it costs a single atomic load if the store is active
+func dropUpdate(state *uint32) bool {
+	switch atomic.LoadUint32(state) {
+	case stateActive:
+		return false
+	case stateFrozen:
+		atomic.AddInt64(&droppedUpdates, 1)
+	}
+	return true
+}
+
+// freezableCounter drops the updates if its store is not active
+type freezableCounter struct {
+	gometrics.Counter
+	state *uint32
+}
+
+func (c freezableCounter) Clear() {
+	if dropUpdate(c.state) {
+		return
+	}
+	c.Counter.Clear()
+}
+
+func (c freezableCounter) Dec(i int64) {
+	if dropUpdate(c.state) {
+		return
+	}
+	c.Counter.Dec(i)
+}
+
+func (c freezableCounter) Inc(i int64) {
+	if dropUpdate(c.state) {
+		return
+	}
+	c.Counter.Inc(i)
+}
+
+// Stop frees the shm counter when it is unregistered
+func (c freezableCounter) Stop() {
+	if s, ok := c.Counter.(gometrics.Stoppable); ok {
+		s.Stop()
+	}
+}
+
+// freezableGauge drops the updates if its store is not active
+type freezableGauge struct {
+	gometrics.Gauge
+	state *uint32
+}
+
+func (g freezableGauge) Update(v int64) {
+	if dropUpdate(g.state) {
+		return
+	}
+	g.Gauge.Update(v)
+}
+
+// Stop frees the shm gauge when it is unregistered
+func (g freezableGauge) Stop() {
+	if s, ok := g.Gauge.(gometrics.Stoppable); ok {
+		s.Stop()
+	}
+}
+
+// freezableHistogram drops the updates if its store is not active
+type freezableHistogram struct {
+	gometrics.Histogram
+	state *uint32
+}
+
+func (h freezableHistogram) Clear() {
+	if dropUpdate(h.state) {
+		return
+	}
+	h.Histogram.Clear()
+}
+
+func (h freezableHistogram) Update(v int64) {
+	if dropUpdate(h.state) {
+		return
+	}
+	h.Histogram.Update(v)
+}
+
+// freezableMeter drops the updates if its store is not active
+type freezableMeter struct {
+	gometrics.Meter
+	state *uint32
+}
+
+func (m freezableMeter) Mark(n int64) {
+	if dropUpdate(m.state) {
+		return
+	}
+	m.Meter.Mark(n)
+}
+
+// freezableTimer drops the updates if its store is not active
+type freezableTimer struct {
+	gometrics.Timer
+	state *uint32
+}
+
+// Time runs the function anyway, only the record is dropped
+func (t freezableTimer) Time(f func()) {
+	if dropUpdate(t.state) {
+		f()
+		return
+	}
+	t.Timer.Time(f)
+}
+
+func (t freezableTimer) Update(d time.Duration) {
+	if dropUpdate(t.state) {
+		return
+	}
+	t.Timer.Update(d)
+}
+
+func (t freezableTimer) UpdateSince(ts time.Time) {
+	if dropUpdate(t.state) {
+		return
+	}
+	t.Timer.UpdateSince(ts)
+}
@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/shm"
+)
+
+func TestFreezeDropsUpdates(t *testing.T) {
+	zone := shm.InitMetricsZone("TestFreezeDropsUpdates", 10*1024)
+	defer func() {
+		// free the shm entries before the zone is detached
+		ResetAll()
+		zone.Detach()
+		shm.Reset()
+	}()
+	ResetAll()
+	defer Unfreeze()
+
+	m, _ := NewMetrics("freeze", map[string]string{"lk": "lv"})
+	counter := m.Counter("counter")
+	gauge := m.Gauge("gauge")
+	histogram := m.Histogram("histogram")
+	counter.Inc(1)
+	gauge.Update(1)
+	histogram.Update(1)
+
+	Freeze()
+	if !IsFrozen() {
+		t.Fatal("metrics should be frozen")
+	}
+	counter.Inc(1)
+	counter.Clear()
+	gauge.Update(2)
+	histogram.Update(2)
+	if counter.Count() != 1 || gauge.Value() != 1 || histogram.Count() != 1 {
+		t.Fatalf("updates should be dropped, counter: %d, gauge: %d, histogram: %d", counter.Count(), gauge.Value(), histogram.Count())
+	}
+	if dropped := DroppedUpdates(); dropped != 4 {
+		t.Fatalf("expected 4 dropped updates, but got %d", dropped)
+	}
+
+	Unfreeze()
+	counter.Inc(1)
+	if counter.Count() != 2 || DroppedUpdates() != 0 {
+		t.Fatalf("updates should be applied after unfreeze, counter: %d", counter.Count())
+	}
+}
+
+func TestFreezeRacingUpdates(t *testing.T) {
+	zone := shm.InitMetricsZone("TestFreezeRacingUpdates", 10*1024)
+	defer func() {
+		// free the shm entries before the zone is detached
+		ResetAll()
+		zone.Detach()
+		shm.Reset()
+	}()
+	ResetAll()
+	defer Unfreeze()
+
+	m, _ := NewMetrics("freeze", map[string]string{"lk": "lv"})
+	counter := m.Counter("counter")
+	histogram := m.Histogram("histogram")
+
+	const workers, updates = 8, 10000
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < updates; j++ {
+				counter.Inc(1)
+				histogram.Update(1)
+			}
+		}()
+	}
+	close(start)
+	Freeze()
+	frozenCount := SnapshotAll()[0].Counter("counter").Count()
+	wg.Wait()
+
+	// the updates after the freeze point are dropped, and never appear in the snapshot
+	stores := SnapshotAll()
+	if len(stores) != 1 {
+		t.Fatalf("expected 1 store, but got %d", len(stores))
+	}
+	if count := stores[0].Counter("counter").Count(); count != frozenCount {
+		t.Fatalf("frozen snapshot changed from %d to %d", frozenCount, count)
+	}
+	frozenHistogram := stores[0].Histogram("histogram").Count()
+	for i := 0; i < 3; i++ {
+		again := SnapshotAll()[0]
+		if again.Counter("counter").Count() != frozenCount || again.Histogram("histogram").Count() != frozenHistogram {
+			t.Fatal("frozen snapshot should be stable across reads")
+		}
+	}
+	// the updates racing with Freeze may reach the live value, but the dropped updates never do
+	dropped := DroppedUpdates()
+	if frozenCount+frozenHistogram+dropped > 2*workers*updates {
+		t.Fatalf("snapshot %d + %d and dropped %d exceed total updates", frozenCount, frozenHistogram, dropped)
+	}
+	if live := counter.Count() + histogram.Count(); live+dropped != 2*workers*updates {
+		t.Fatalf("expected live %d + dropped %d equals total updates", live, dropped)
+	}
+}
+
+func TestFreezableMetricsStop(t *testing.T) {
+	zone := shm.InitMetricsZone("TestFreezableMetricsStop", 10*1024)
+	defer func() {
+		// free the shm entries before the zone is detached
+		ResetAll()
+		zone.Detach()
+		shm.Reset()
+	}()
+	ResetAll()
+
+	m, _ := NewMetrics("freeze", map[string]string{"lk": "lv"})
+	if _, ok := m.Counter("counter").(gometrics.Stoppable); !ok {
+		t.Fatal("counter should be stoppable, so the shm entry is freed")
+	}
+	if _, ok := m.Gauge("gauge").(gometrics.Stoppable); !ok {
+		t.Fatal("gauge should be stoppable, so the shm entry is freed")
+	}
+}
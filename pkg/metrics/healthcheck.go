@@ -41,3 +41,16 @@ func NewHealthStats(serviceName string) types.Metrics {
 	metrics, _ := NewMetrics(HealthCheckType, map[string]string{"service": serviceName})
 	return metrics
 }
+
+// the health of the mosn instance reported by the admin healthz api
+const (
+	ServerHealthy           = "healthy"
+	ServerHealthCheckFailed = "forced_failed"
+	ServerDraining          = "draining"
+)
+
+// NewServerHealthStats returns the stats of the health of the mosn instance
+func NewServerHealthStats() types.Metrics {
+	metrics, _ := NewMetrics(HealthCheckType, map[string]string{"server": "healthz"})
+	return metrics
+}
@@ -1,9 +1,46 @@
 package metrics
 
+import (
+	"fmt"
+	"path"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
 type metricsMatcher struct {
 	rejectAll       bool
 	exclusionLabels []string
 	exclusionKeys   []string
+	exclusionRules  []v2.StatsExclusionRule
+}
+
+func newMetricsMatcher(config v2.StatsMatcher) (*metricsMatcher, error) {
+	for _, rule := range config.ExclusionRules {
+		patterns := append([]string{rule.Type}, rule.Keys...)
+		for _, pattern := range rule.Labels {
+			patterns = append(patterns, pattern)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid exclusion pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return &metricsMatcher{
+		rejectAll:       config.RejectAll,
+		exclusionLabels: config.ExclusionLabels,
+		exclusionKeys:   config.ExclusionKeys,
+		exclusionRules:  config.ExclusionRules,
+	}, nil
+}
+
+func (m *metricsMatcher) config() v2.StatsMatcher {
+	return v2.StatsMatcher{
+		RejectAll:       m.rejectAll,
+		ExclusionLabels: m.exclusionLabels,
+		ExclusionKeys:   m.exclusionKeys,
+		ExclusionRules:  m.exclusionRules,
+	}
 }
 
 // isExclusionLabels returns the labels will be ignored or not
@@ -11,7 +48,6 @@ func (m *metricsMatcher) isExclusionLabels(labels map[string]string) bool {
 	if m.rejectAll {
 		return true
 	}
-	// TODO: support pattern
 	for _, label := range m.exclusionLabels {
 		if _, ok := labels[label]; ok {
 			return true
@@ -25,12 +61,59 @@ func (m *metricsMatcher) isExclusionKey(key string) bool {
 	if m.rejectAll {
 		return true
 	}
-	// TODO: support pattern
 	for _, eKey := range m.exclusionKeys {
-		if eKey == key  {
+		if eKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isExclusionMetrics returns the whole metrics of the type and labels will be ignored or not
+func (m *metricsMatcher) isExclusionMetrics(typ string, labels map[string]string) bool {
+	if m.isExclusionLabels(labels) {
+		return true
+	}
+	for _, rule := range m.exclusionRules {
+		if len(rule.Keys) == 0 && matchRule(rule, typ, labels) {
 			return true
 		}
 	}
 	return false
 }
 
+// isExclusionMetric returns the key in the metrics of the type and labels will be ignored or not
+func (m *metricsMatcher) isExclusionMetric(typ string, labels map[string]string, key string) bool {
+	if m.isExclusionKey(key) {
+		return true
+	}
+	for _, rule := range m.exclusionRules {
+		if len(rule.Keys) == 0 || !matchRule(rule, typ, labels) {
+			continue
+		}
+		for _, pattern := range rule.Keys {
+			if globMatch(pattern, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchRule(rule v2.StatsExclusionRule, typ string, labels map[string]string) bool {
+	if rule.Type != "" && !globMatch(rule.Type, typ) {
+		return false
+	}
+	for key, pattern := range rule.Labels {
+		value, ok := labels[key]
+		if !ok || !globMatch(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func globMatch(pattern, s string) bool {
+	matched, _ := path.Match(pattern, s)
+	return matched
+}
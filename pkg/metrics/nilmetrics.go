@@ -49,6 +49,14 @@ func (m *NilMetrics) Histogram(key string) gometrics.Histogram {
 	return gometrics.NilHistogram{}
 }
 
+func (m *NilMetrics) Meter(key string) gometrics.Meter {
+	return gometrics.NilMeter{}
+}
+
+func (m *NilMetrics) Timer(key string) gometrics.Timer {
+	return gometrics.NilTimer{}
+}
+
 func (m *NilMetrics) Each(f func(string, interface{})) {
 	// do nothing
 }
@@ -0,0 +1,42 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// OverloadType represents overload manager metrics type
+const OverloadType = "overload"
+
+// overload manager metrics key
+const (
+	OverloadHeapInuse           = "heap_inuse_bytes"
+	OverloadGoroutines          = "goroutines"
+	OverloadPressure            = "pressure_percent"
+	OverloadStopAccepting       = "stop_accepting"
+	OverloadRejectStreams       = "reject_streams"
+	OverloadConnectionsRejected = "connections_rejected"
+	OverloadStreamsRejected     = "streams_rejected"
+)
+
+// NewOverloadStats returns the stats of the overload manager
+func NewOverloadStats() types.Metrics {
+	metrics, _ := NewMetrics(OverloadType, map[string]string{"overload": "manager"})
+	return metrics
+}
@@ -0,0 +1,37 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// RouterType represents the router metrics type
+const RouterType = "router"
+
+// router metrics key
+const (
+	WeightedClusterSelected = "weighted_cluster_selected"
+)
+
+// NewWeightedClusterStats returns the stats of a weighted cluster in the virtual host,
+// the counters show the split of the requests achieved by the weights
+func NewWeightedClusterStats(virtualHost, cluster string) types.Metrics {
+	metrics, _ := NewMetrics(RouterType, map[string]string{"virtual_host": virtualHost, "weighted_cluster": cluster})
+	return metrics
+}
@@ -18,15 +18,28 @@
 package console
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
+	"sort"
 	"strconv"
 
+	"sofastack.io/sofa-mosn/pkg/log"
+	mosnmetrics "sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/sink"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"github.com/rcrowley/go-metrics"
 	"strings"
 )
 
+const logSinkType = "console"
+
+func init() {
+	sink.RegisterFlushSink(logSinkType, func(config map[string]interface{}) (mosnmetrics.Sink, error) {
+		return NewLogSink(), nil
+	})
+}
+
 // histogram output percents
 var percents = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
 
@@ -55,19 +68,8 @@ func (sink *consoleSink) Flush(writer io.Writer, ms []types.Metrics) {
 			typeData[namespace] = namespaceData
 		}
 
-		m.Each(func(key string, i interface{}) {
-			switch metric := i.(type) {
-			case metrics.Counter:
-				namespaceData[key] = strconv.FormatInt(metric.Count(), 10)
-			case metrics.Gauge:
-				namespaceData[key] = strconv.FormatInt(metric.Value(), 10)
-			case metrics.Histogram:
-				h := metric.Snapshot()
-				namespaceData[key+"_min"] = strconv.FormatInt(h.Min(), 10)
-				namespaceData[key+"_max"] = strconv.FormatInt(h.Max(), 10)
-			default: //unsupport metrics, ignore
-				return
-			}
+		eachValue(m, func(key, value string) {
+			namespaceData[key] = value
 		})
 	}
 	//TODO: performance optimize
@@ -81,6 +83,112 @@ func NewConsoleSink() types.MetricsSink {
 	return &consoleSink{}
 }
 
+// flatSink writes one line per metric value, in the format of "type.namespace.key value"
+type flatSink struct {
+}
+
+// ~ MetricsSink
+func (sink *flatSink) Flush(writer io.Writer, ms []types.Metrics) {
+	lines := make([]string, 0, len(ms))
+	for _, m := range ms {
+		prefix := m.Type() + "."
+		if namespace := makeNamespace(m.SortedLabels()); namespace != "" {
+			prefix += namespace + "."
+		}
+		eachValue(m, func(key, value string) {
+			lines = append(lines, prefix+key+" "+value+"\n")
+		})
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		io.WriteString(writer, line)
+	}
+}
+
+// NewConsoleFlatSink returns sink that writes one line per metric value, for grepping
+// Note: This func is not registered into sink factory, and should be use in certain scene.
+func NewConsoleFlatSink() types.MetricsSink {
+	return &flatSink{}
+}
+
+// eachValue calls f with the metrics' values in string format, a histogram is
+// expanded to its count, min, max, mean, p95 and p99, a meter is expanded to its
+// count and rates, and a timer is expanded to both
+func eachValue(m types.Metrics, f func(key, value string)) {
+	m.Each(func(key string, i interface{}) {
+		switch metric := i.(type) {
+		case metrics.Counter:
+			f(key, strconv.FormatInt(metric.Count(), 10))
+		case metrics.Gauge:
+			f(key, strconv.FormatInt(metric.Value(), 10))
+		case metrics.Histogram:
+			eachSample(key, metric.Snapshot(), f)
+		case metrics.Meter:
+			m := metric.Snapshot()
+			f(key+"_count", strconv.FormatInt(m.Count(), 10))
+			eachRate(key, m, f)
+		case metrics.Timer:
+			t := metric.Snapshot()
+			eachSample(key, t, f)
+			eachRate(key, t, f)
+		default: //unsupport metrics, ignore
+			return
+		}
+	})
+}
+
+// sample is the values shared by the histogram and the timer
+type sample interface {
+	Count() int64
+	Min() int64
+	Max() int64
+	Mean() float64
+	Percentiles([]float64) []float64
+}
+
+func eachSample(key string, s sample, f func(key, value string)) {
+	ps := s.Percentiles([]float64{0.95, 0.99})
+	f(key+"_count", strconv.FormatInt(s.Count(), 10))
+	f(key+"_min", strconv.FormatInt(s.Min(), 10))
+	f(key+"_max", strconv.FormatInt(s.Max(), 10))
+	f(key+"_mean", strconv.FormatFloat(s.Mean(), 'f', 2, 64))
+	f(key+"_p95", strconv.FormatFloat(ps[0], 'f', 2, 64))
+	f(key+"_p99", strconv.FormatFloat(ps[1], 'f', 2, 64))
+}
+
+// rate is the values shared by the meter and the timer
+type rate interface {
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+}
+
+func eachRate(key string, r rate, f func(key, value string)) {
+	f(key+"_rate1", strconv.FormatFloat(r.Rate1(), 'f', 2, 64))
+	f(key+"_rate5", strconv.FormatFloat(r.Rate5(), 'f', 2, 64))
+	f(key+"_rate15", strconv.FormatFloat(r.Rate15(), 'f', 2, 64))
+}
+
+// logSink writes the metrics into the default logger in the console sink's format, for debugging
+type logSink struct {
+	console types.MetricsSink
+}
+
+// ~ metrics.Sink
+func (s *logSink) Flush(stores []types.Metrics) error {
+	buf := &bytes.Buffer{}
+	s.console.Flush(buf, stores)
+	log.DefaultLogger.Infof("[metrics] [console] %s", buf.String())
+	return nil
+}
+
+// NewLogSink returns a sink that writes the metrics into the default logger periodically
+func NewLogSink() mosnmetrics.Sink {
+	return &logSink{
+		console: NewConsoleSink(),
+	}
+}
+
 func makeNamespace(keys, vals []string) (namespace string) {
 	pair := make([]string, 0, len(keys))
 
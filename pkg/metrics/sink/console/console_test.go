@@ -138,6 +138,61 @@ func TestConsoleMetrics(t *testing.T) {
 	}
 }
 
+func TestConsoleFlatMetrics(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t1", map[string]string{"lbk1": "lbv1"})
+	s.Counter("k1").Inc(1)
+	s.Gauge("k2").Update(2)
+	for i := int64(1); i <= 4; i++ {
+		s.Histogram("k3").Update(i)
+	}
+	n, _ := metrics.NewMetrics("t2", nil)
+	n.Counter("k1").Inc(3)
+
+	buf := &bytes.Buffer{}
+	NewConsoleFlatSink().Flush(buf, metrics.GetAll())
+	expected := "t1.lbk1.lbv1.k1 1\n" +
+		"t1.lbk1.lbv1.k2 2\n" +
+		"t1.lbk1.lbv1.k3_count 4\n" +
+		"t1.lbk1.lbv1.k3_max 4\n" +
+		"t1.lbk1.lbv1.k3_mean 2.50\n" +
+		"t1.lbk1.lbv1.k3_min 1\n" +
+		"t1.lbk1.lbv1.k3_p95 4.00\n" +
+		"t1.lbk1.lbv1.k3_p99 4.00\n" +
+		"t2.k1 3\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected flat metrics:\n%s", buf.String())
+	}
+}
+
+func TestConsoleMeterTimer(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t1", nil)
+	s.Meter("m").Mark(3)
+	s.Timer("t").Update(2)
+	s.Timer("t").Update(4)
+
+	buf := &bytes.Buffer{}
+	NewConsoleFlatSink().Flush(buf, metrics.GetAll())
+	// the rates are not ticked yet
+	expected := "t1.m_count 3\n" +
+		"t1.m_rate1 0.00\n" +
+		"t1.m_rate15 0.00\n" +
+		"t1.m_rate5 0.00\n" +
+		"t1.t_count 2\n" +
+		"t1.t_max 4\n" +
+		"t1.t_mean 3.00\n" +
+		"t1.t_min 2\n" +
+		"t1.t_p95 4.00\n" +
+		"t1.t_p99 4.00\n" +
+		"t1.t_rate1 0.00\n" +
+		"t1.t_rate15 0.00\n" +
+		"t1.t_rate5 0.00\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected flat metrics:\n%s", buf.String())
+	}
+}
+
 func BenchmarkGetMetrics(b *testing.B) {
 	metrics.ResetAll()
 	// init metrics data
@@ -20,16 +20,23 @@ package sink
 import (
 	"fmt"
 
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 // MetricsSinkCreator creates a MetricsSink according to config
 type MetricsSinkCreator func(config map[string]interface{}) (types.MetricsSink, error)
 
+// FlushSinkCreator creates a metrics.Sink that the metrics are pushed to periodically
+type FlushSinkCreator func(config map[string]interface{}) (metrics.Sink, error)
+
 var metricsSinkFactory map[string]MetricsSinkCreator
 
+var flushSinkFactory map[string]FlushSinkCreator
+
 func init() {
 	metricsSinkFactory = make(map[string]MetricsSinkCreator)
+	flushSinkFactory = make(map[string]FlushSinkCreator)
 }
 
 // RegisterSink registers the sinkType as MetricsSinkCreator
@@ -48,3 +55,27 @@ func CreateMetricsSink(sinkType string, config map[string]interface{}) (types.Me
 	}
 	return nil, fmt.Errorf("unsupported metrics sink type: %v", sinkType)
 }
+
+// RegisterFlushSink registers the sinkType as FlushSinkCreator
+func RegisterFlushSink(sinkType string, creator FlushSinkCreator) {
+	flushSinkFactory[sinkType] = creator
+}
+
+// IsFlushSink returns true if the sinkType is registered as FlushSinkCreator
+func IsFlushSink(sinkType string) bool {
+	_, ok := flushSinkFactory[sinkType]
+	return ok
+}
+
+// CreateFlushSink creates a metrics.Sink according to sinkType, and adds it to the metrics flusher
+func CreateFlushSink(sinkType string, config map[string]interface{}) (metrics.Sink, error) {
+	if creator, ok := flushSinkFactory[sinkType]; ok {
+		sink, err := creator(config)
+		if err != nil {
+			return nil, fmt.Errorf("create metrics sink failed: %v", err)
+		}
+		metrics.AddSink(sinkType, sink)
+		return sink, nil
+	}
+	return nil, fmt.Errorf("unsupported metrics sink type: %v", sinkType)
+}
@@ -45,7 +45,11 @@ import (
 var (
 	sinkType        = "prometheus"
 	defaultEndpoint = "/metrics"
-	numBufPool = sync.Pool{
+	// namespace is the prefix of the mosn metrics names
+	namespace = "mosn_"
+	// defaultPercentiles are the quantiles of the histograms if not configured
+	defaultPercentiles = []float64{0.5, 0.9, 0.99}
+	numBufPool         = sync.Pool{
 		New: func() interface{} {
 			b := make([]byte, 0, 24)
 			return &b
@@ -61,9 +65,12 @@ func init() {
 type promConfig struct {
 	ExportUrl string `json:"export_url"` // when this value is not nil, PromSink will work under the PUSHGATEWAY mode.
 
-	Port     int    `json:"port"` // pull mode attrs
+	Port     int    `json:"port"`    // pull mode attrs
+	Address  string `json:"address"` // listen address, such as 127.0.0.1:9090, overrides the port
 	Endpoint string `json:"endpoint"`
 
+	Percentiles []float64 `json:"percentiles"` // quantiles exported of the histograms
+
 	DisableCollectProcess bool `json:"disable_collect_process"`
 	DisableCollectGo      bool `json:"disable_collect_go"`
 }
@@ -104,7 +111,7 @@ func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 		}
 
 		// TODO cached in metrics struct, avoid calc for each flush
-		prefix := typ + "_"
+		prefix := namespace + typ + "_"
 		suffix := makeLabelStr(labelKeys, labelVals)
 
 		m.Each(func(name string, i interface{}) {
@@ -118,6 +125,14 @@ func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 				psink.flushGauge(tracker, buf, flattenKey(prefix+name), suffix, float64(metric.Value()))
 			case gometrics.Histogram:
 				psink.flushHistogram(tracker, buf, flattenKey(prefix+name), suffix, metric.Snapshot())
+			case gometrics.Meter:
+				snapshot := metric.Snapshot()
+				psink.flushCounter(tracker, buf, flattenKey(prefix+name), suffix, float64(snapshot.Count()))
+				psink.flushRate(tracker, buf, flattenKey(prefix+name), suffix, snapshot)
+			case gometrics.Timer:
+				snapshot := metric.Snapshot()
+				psink.flushHistogram(tracker, buf, flattenKey(prefix+name), suffix, snapshot)
+				psink.flushRate(tracker, buf, flattenKey(prefix+name), suffix, snapshot)
 			}
 			buf.WriteTo(w)
 			buf.Reset()
@@ -125,12 +140,67 @@ func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 	}
 }
 
-func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffer, name string, labels string, snapshot gometrics.Histogram) {
+// sample is the values shared by the histogram and the timer
+type sample interface {
+	Count() int64
+	Min() int64
+	Max() int64
+	Sum() int64
+	Percentiles([]float64) []float64
+}
+
+// rate is the values shared by the meter and the timer
+type rate interface {
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+}
+
+func (psink *promSink) flushRate(tracker map[string]bool, buf types.IoBuffer, name string, labels string, snapshot rate) {
+	psink.flushGauge(tracker, buf, name+"_rate1", labels, snapshot.Rate1())
+	psink.flushGauge(tracker, buf, name+"_rate5", labels, snapshot.Rate5())
+	psink.flushGauge(tracker, buf, name+"_rate15", labels, snapshot.Rate15())
+}
+
+func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffer, name string, labels string, snapshot sample) {
 	// min
 	psink.flushGauge(tracker, buf, name+"_min", labels, float64(snapshot.Min()))
 	// max
 	psink.flushGauge(tracker, buf, name+"_max", labels, float64(snapshot.Max()))
-	// TODO: flush P90 P95 P99 if configured
+
+	// summary
+	if !tracker[name] {
+		buf.WriteString("# TYPE ")
+		buf.WriteString(name)
+		buf.WriteString(" summary\n")
+		tracker[name] = true
+	}
+	if labels != "" {
+		labels += ","
+	}
+	percentiles := snapshot.Percentiles(psink.config.Percentiles)
+	for i, p := range psink.config.Percentiles {
+		buf.WriteString(name)
+		buf.WriteString("{")
+		buf.WriteString(labels)
+		buf.WriteString("quantile=\"")
+		buf.WriteString(strconv.FormatFloat(p, 'g', -1, 64))
+		buf.WriteString("\"} ")
+		writeFloat(buf, percentiles[i])
+		buf.WriteString("\n")
+	}
+	labels = strings.TrimSuffix(labels, ",")
+	psink.writeSample(buf, name+"_sum", labels, float64(snapshot.Sum()))
+	psink.writeSample(buf, name+"_count", labels, float64(snapshot.Count()))
+}
+
+func (psink *promSink) writeSample(buf types.IoBuffer, name string, labels string, val float64) {
+	buf.WriteString(name)
+	buf.WriteString("{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, val)
+	buf.WriteString("\n")
 }
 
 func (psink *promSink) flushGauge(tracker map[string]bool, buf types.IoBuffer, name string, labels string, val float64) {
@@ -193,8 +263,12 @@ func NewPromeSink(config *promConfig) types.MetricsSink {
 		}),
 	})
 
+	addr := config.Address
+	if addr == "" {
+		addr = fmt.Sprintf("0.0.0.0:%d", config.Port)
+	}
 	srv := &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", config.Port),
+		Addr:    addr,
 		Handler: srvMux,
 	}
 
@@ -220,10 +294,19 @@ func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
 		return nil, errors.New("prometheus PushGateway mode currently unsupported")
 	}
 
-	if promCfg.Port == 0 {
+	if promCfg.Port == 0 && promCfg.Address == "" {
 		return nil, errors.New("prometheus sink's port is not specified")
 	}
 
+	if promCfg.Percentiles == nil {
+		promCfg.Percentiles = defaultPercentiles
+	}
+	for _, p := range promCfg.Percentiles {
+		if p <= 0 || p >= 1 {
+			return nil, fmt.Errorf("invalid percentile: %v, should be in (0, 1)", p)
+		}
+	}
+
 	if promCfg.Endpoint == "" {
 		promCfg.Endpoint = defaultEndpoint
 	} else {
@@ -239,9 +322,9 @@ func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
 // output: cluster="app1",host="server"
 func makeLabelStr(keys, values []string) (out string) {
 	if length := len(keys); length > 0 {
-		out = keys[0] + "=\"" + values[0] + "\""
+		out = flattenLabel(keys[0]) + "=\"" + escapeLabelValue(values[0]) + "\""
 		for i := 1; i < length; i++ {
-			out += "," + keys[i] + "=\"" + values[i] + "\""
+			out += "," + flattenLabel(keys[i]) + "=\"" + escapeLabelValue(values[i]) + "\""
 		}
 	}
 	return
@@ -291,9 +374,33 @@ func writeFloat(w types.IoBuffer, f float64) (int, error) {
 
 // name regex [a-zA-Z_:][a-zA-Z0-9_:]*
 func flattenKey(key string) string {
-	key = strings.Replace(key, " ", "_", -1)
-	key = strings.Replace(key, ".", "_", -1)
-	key = strings.Replace(key, "-", "_", -1)
-	key = strings.Replace(key, "=", "_", -1)
-	return key
+	return sanitize(key, true)
+}
+
+// label name regex [a-zA-Z_][a-zA-Z0-9_]*
+func flattenLabel(key string) string {
+	return sanitize(key, false)
+}
+
+// sanitize replaces the invalid characters with underscore, a leading digit is prefixed with underscore
+func sanitize(key string, colon bool) string {
+	if key != "" && key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return strings.Map(func(c rune) rune {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || (colon && c == ':') {
+			return c
+		}
+		return '_'
+	}, key)
+}
+
+// escapeLabelValue escapes the backslash, double-quote and line feed in label value
+func escapeLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	return strings.Replace(value, "\n", "\\n", -1)
 }
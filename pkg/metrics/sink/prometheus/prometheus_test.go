@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"sync"
 	"testing"
 
@@ -256,6 +257,14 @@ func TestPrometheusFlatternKey(t *testing.T) {
 			input:  "listener_address:0.0.0.0:9529",
 			output: "listener_address:0_0_0_0:9529",
 		},
+		{
+			input:  "upstream_request-time/total",
+			output: "upstream_request_time_total",
+		},
+		{
+			input:  "9529.port",
+			output: "_9529_port",
+		},
 	}
 
 	for _, c := range testcase {
@@ -263,6 +272,72 @@ func TestPrometheusFlatternKey(t *testing.T) {
 			t.Error("prometheus flattern key error:", c)
 		}
 	}
+
+	if label := makeLabelStr([]string{"host.addr", "path"}, []string{"127.0.0.1:80", "a\"b\\c\nd"}); label != `host_addr="127.0.0.1:80",path="a\"b\\c\nd"` {
+		t.Error("prometheus label error:", label)
+	}
+}
+
+func TestPrometheusFlushHistogram(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t3", map[string]string{"cluster.name": "c1"})
+	for i := int64(1); i <= 100; i++ {
+		s.Histogram("request-time").Update(i)
+	}
+	s2, _ := metrics.NewMetrics("t3", nil)
+	s2.Histogram("request-time").Update(10)
+
+	psink := &promSink{
+		config: &promConfig{
+			Percentiles: []float64{0.5, 0.99},
+		},
+	}
+	buf := &bytes.Buffer{}
+	psink.Flush(buf, metrics.GetAll())
+	body := buf.String()
+
+	for _, line := range []string{
+		"# TYPE mosn_t3_request_time summary\n",
+		"mosn_t3_request_time{cluster_name=\"c1\",quantile=\"0.5\"} 50.5\n",
+		"mosn_t3_request_time{cluster_name=\"c1\",quantile=\"0.99\"} 99.99\n",
+		"mosn_t3_request_time_sum{cluster_name=\"c1\"} 5050.0\n",
+		"mosn_t3_request_time_count{cluster_name=\"c1\"} 100.0\n",
+		"mosn_t3_request_time_min{cluster_name=\"c1\"} 1.0\n",
+		"mosn_t3_request_time_max{cluster_name=\"c1\"} 100.0\n",
+		"mosn_t3_request_time{quantile=\"0.5\"} 10.0\n",
+		"mosn_t3_request_time_count{} 1.0\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in metrics:\n%s", line, body)
+		}
+	}
+	if n := strings.Count(body, "# TYPE mosn_t3_request_time summary"); n != 1 {
+		t.Errorf("type of the summary should be printed once, but got %d", n)
+	}
+}
+
+func TestPrometheusBuilder(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"port": 8089, "endpoint": "metrics"},
+		{"address": "127.0.0.1:8089", "percentiles": []float64{0.5, 1}},
+		{"export_url": "http://127.0.0.1:9091"},
+	} {
+		if _, err := builder(cfg); err == nil {
+			t.Errorf("config %v should be invalid", cfg)
+		}
+	}
+
+	s, err := builder(map[string]interface{}{
+		"address": "127.0.0.1:8089",
+	})
+	if err != nil {
+		t.Fatalf("build prometheus sink failed: %v", err)
+	}
+	defer store.CloseService()
+	if p := s.(*promSink).config.Percentiles; len(p) != len(defaultPercentiles) {
+		t.Errorf("expected default percentiles, but got %v", p)
+	}
 }
 
 func BenchmarkPromSink_Flush(b *testing.B) {
@@ -0,0 +1,251 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/sink"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	sinkType             = "statsd"
+	defaultAddress       = "127.0.0.1:8125"
+	defaultPrefix        = "mosn"
+	defaultMaxPacketSize = 1432 // fits in an ethernet frame
+	writeTimeout         = time.Second
+	// percentiles exported of the histograms as gauges
+	percentiles     = []float64{0.5, 0.9, 0.99}
+	percentileNames = []string{"p50", "p90", "p99"}
+	// characters reserved by the statsd line protocol
+	replacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+)
+
+func init() {
+	sink.RegisterFlushSink(sinkType, builder)
+}
+
+// statsdConfig contains config for the statsd sink
+type statsdConfig struct {
+	Address       string `json:"address"`         // udp address of the statsd agent
+	Prefix        string `json:"prefix"`          // prefix of the metrics names
+	DisableTags   bool   `json:"disable_tags"`    // the labels are appended to the names instead of the DogStatsD tags
+	MaxPacketSize int    `json:"max_packet_size"` // lines are batched into the packets up to the size
+}
+
+// statsdSink pushes the metrics to statsd by udp, the counters are sent as the deltas since the last flush
+type statsdSink struct {
+	config *statsdConfig
+	conn   net.Conn
+	buf    bytes.Buffer
+	packet bytes.Buffer
+	// the counters' values of the last flush, keyed by the line name and tags
+	// the flushes of a sink are never concurrent, so no lock is needed
+	counters map[string]int64
+	// flushed is the counters' values of the current flush, it replaces the counters after the flush,
+	// so the counters of the unregistered metrics are removed
+	flushed map[string]int64
+}
+
+// NewStatsdSink returns a sink that pushes the metrics to statsd
+func NewStatsdSink(config *statsdConfig) (metrics.Sink, error) {
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{
+		config:   config,
+		conn:     conn,
+		counters: make(map[string]int64),
+		flushed:  make(map[string]int64),
+	}, nil
+}
+
+// ~ metrics.Sink
+func (s *statsdSink) Flush(stores []types.Metrics) error {
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	var err error
+	for _, m := range stores {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		prefix, tags := s.makePrefixAndTags(m.Type(), labelKeys, labelVals)
+		m.Each(func(key string, i interface{}) {
+			if sink.IsExclusionKeys(key) {
+				return
+			}
+			name := prefix + replacer.Replace(key)
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				s.writeCounter(name, tags, metric.Count())
+			case gometrics.Gauge:
+				s.writeLine(name, strconv.FormatInt(metric.Value(), 10), "g", tags)
+			case gometrics.Histogram:
+				s.writeHistogram(name, tags, metric)
+			case gometrics.Meter:
+				snapshot := metric.Snapshot()
+				s.writeCounter(name, tags, snapshot.Count())
+				s.writeRate(name, tags, snapshot)
+			case gometrics.Timer:
+				snapshot := metric.Snapshot()
+				s.writeHistogram(name, tags, snapshot)
+				s.writeRate(name, tags, snapshot)
+			}
+			if e := s.writePackets(); e != nil && err == nil {
+				err = e
+			}
+		})
+	}
+	if s.packet.Len() > 0 {
+		if _, e := s.conn.Write(s.packet.Bytes()); e != nil && err == nil {
+			err = e
+		}
+		s.packet.Reset()
+	}
+	// the counters not flushed are unregistered or excluded
+	for id := range s.counters {
+		delete(s.counters, id)
+	}
+	s.counters, s.flushed = s.flushed, s.counters
+	return err
+}
+
+func (s *statsdSink) makePrefixAndTags(typ string, keys, vals []string) (string, string) {
+	prefix := s.config.Prefix + "." + replacer.Replace(typ) + "."
+	if len(keys) == 0 {
+		return prefix, ""
+	}
+	if s.config.DisableTags {
+		for i := range keys {
+			prefix += replacer.Replace(keys[i]) + "." + replacer.Replace(vals[i]) + "."
+		}
+		return prefix, ""
+	}
+	pair := make([]string, 0, len(keys))
+	for i := range keys {
+		pair = append(pair, replacer.Replace(keys[i])+":"+replacer.Replace(vals[i]))
+	}
+	return prefix, "|#" + strings.Join(pair, ",")
+}
+
+func (s *statsdSink) writeCounter(name, tags string, count int64) {
+	id := name + tags
+	delta := count - s.counters[id]
+	// the counter is reset
+	if delta < 0 {
+		delta = count
+	}
+	s.flushed[id] = count
+	s.writeLine(name, strconv.FormatInt(delta, 10), "c", tags)
+}
+
+// sample is the values shared by the histogram and the timer
+type sample interface {
+	Min() int64
+	Max() int64
+	Mean() float64
+	Percentiles([]float64) []float64
+}
+
+// rate is the values shared by the meter and the timer
+type rate interface {
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+}
+
+func (s *statsdSink) writeRate(name, tags string, r rate) {
+	s.writeLine(name+".rate1", strconv.FormatFloat(r.Rate1(), 'f', -1, 64), "g", tags)
+	s.writeLine(name+".rate5", strconv.FormatFloat(r.Rate5(), 'f', -1, 64), "g", tags)
+	s.writeLine(name+".rate15", strconv.FormatFloat(r.Rate15(), 'f', -1, 64), "g", tags)
+}
+
+func (s *statsdSink) writeHistogram(name, tags string, h sample) {
+	s.writeLine(name+".min", strconv.FormatInt(h.Min(), 10), "g", tags)
+	s.writeLine(name+".max", strconv.FormatInt(h.Max(), 10), "g", tags)
+	s.writeLine(name+".mean", strconv.FormatFloat(h.Mean(), 'f', -1, 64), "g", tags)
+	for i, v := range h.Percentiles(percentiles) {
+		s.writeLine(name+"."+percentileNames[i], strconv.FormatFloat(v, 'f', -1, 64), "g", tags)
+	}
+}
+
+// writeLine writes a line like "name:value|type|#k:v" into the buffer
+func (s *statsdSink) writeLine(name, value, typ, tags string) {
+	s.buf.WriteString(name)
+	s.buf.WriteByte(':')
+	s.buf.WriteString(value)
+	s.buf.WriteByte('|')
+	s.buf.WriteString(typ)
+	s.buf.WriteString(tags)
+	s.buf.WriteByte('\n')
+}
+
+// writePackets moves the lines in the buffer to the packet, a full packet is sent
+func (s *statsdSink) writePackets() error {
+	var err error
+	for s.buf.Len() > 0 {
+		line, _ := s.buf.ReadBytes('\n')
+		if s.packet.Len() > 0 && s.packet.Len()+len(line) > s.config.MaxPacketSize {
+			if _, e := s.conn.Write(s.packet.Bytes()); e != nil && err == nil {
+				err = e
+			}
+			s.packet.Reset()
+		}
+		s.packet.Write(line)
+	}
+	s.buf.Reset()
+	return err
+}
+
+func builder(cfg map[string]interface{}) (metrics.Sink, error) {
+	// parse config
+	statsdCfg := &statsdConfig{}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, statsdCfg); err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+
+	if statsdCfg.Address == "" {
+		statsdCfg.Address = defaultAddress
+	}
+	if statsdCfg.Prefix == "" {
+		statsdCfg.Prefix = defaultPrefix
+	}
+	if statsdCfg.MaxPacketSize == 0 {
+		statsdCfg.MaxPacketSize = defaultMaxPacketSize
+	}
+	if statsdCfg.MaxPacketSize < 0 {
+		return nil, errors.New("statsd sink's max packet size should be positive")
+	}
+	return NewStatsdSink(statsdCfg)
+}
@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newStatsdServer(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readLines reads the packets until the expected lines are received
+func readLines(t *testing.T, conn net.PacketConn, n int) (lines []string, packets int) {
+	buf := make([]byte, 65536)
+	for len(lines) < n {
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		size, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read statsd packet failed, got lines %v: %v", lines, err)
+		}
+		packets++
+		lines = append(lines, strings.Split(strings.TrimSuffix(string(buf[:size]), "\n"), "\n")...)
+	}
+	return
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStatsdSinkFlush(t *testing.T) {
+	server := newStatsdServer(t)
+	defer server.Close()
+
+	s, err := builder(map[string]interface{}{
+		"address": server.LocalAddr().String(),
+		"prefix":  "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.ResetAll()
+	m, _ := metrics.NewMetrics("statsd", map[string]string{"cluster": "c1", "host": "127.0.0.1:80"})
+	m.Counter("request_total").Inc(3)
+	m.Gauge("request_active").Update(2)
+	m.Histogram("request_time").Update(10)
+
+	if err := s.Flush([]types.Metrics{m}); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	lines, _ := readLines(t, server, 8)
+	tags := "|#cluster:c1,host:127.0.0.1_80"
+	expected := []string{
+		"test.statsd.request_active:2|g" + tags,
+		"test.statsd.request_time.min:10|g" + tags,
+		"test.statsd.request_time.max:10|g" + tags,
+		"test.statsd.request_time.mean:10|g" + tags,
+		"test.statsd.request_time.p50:10|g" + tags,
+		"test.statsd.request_time.p90:10|g" + tags,
+		"test.statsd.request_time.p99:10|g" + tags,
+		"test.statsd.request_total:3|c" + tags,
+	}
+	for _, e := range expected {
+		if !containsLine(lines, e) {
+			t.Fatalf("expected line %s, but got %v", e, lines)
+		}
+	}
+
+	// the counter is sent as the delta since the last flush
+	m.Counter("request_total").Inc(2)
+	if err := s.Flush([]types.Metrics{m}); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	lines, _ = readLines(t, server, 8)
+	if !containsLine(lines, "test.statsd.request_total:2|c"+tags) {
+		t.Fatalf("expected counter delta 2, but got %v", lines)
+	}
+
+	// the counters of the unregistered metrics are removed
+	if err := s.Flush(nil); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if n := len(s.(*statsdSink).counters); n != 0 {
+		t.Fatalf("expected the counters removed, but got %d", n)
+	}
+}
+
+func TestStatsdSinkPacketsWithoutTags(t *testing.T) {
+	server := newStatsdServer(t)
+	defer server.Close()
+
+	s, err := builder(map[string]interface{}{
+		"address":         server.LocalAddr().String(),
+		"disable_tags":    true,
+		"max_packet_size": 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.ResetAll()
+	m, _ := metrics.NewMetrics("statsd", map[string]string{"cluster": "c1"})
+	m.Counter("counter1").Inc(1)
+	m.Counter("counter2").Inc(1)
+	m.Counter("counter3").Inc(1)
+
+	if err := s.Flush([]types.Metrics{m}); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	// the lines are split into the packets up to the max size
+	lines, packets := readLines(t, server, 3)
+	if packets < 2 {
+		t.Fatalf("expected the lines are sent in multiple packets, but got %d", packets)
+	}
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "mosn.statsd.cluster.c1.counter") || !strings.HasSuffix(l, ":1|c") {
+			t.Fatalf("unexpected line %s", l)
+		}
+	}
+}
+
+func TestStatsdSinkBuilder(t *testing.T) {
+	if _, err := builder(map[string]interface{}{"max_packet_size": -1}); err == nil {
+		t.Fatal("expected invalid max packet size is rejected")
+	}
+	if _, err := builder(map[string]interface{}{"address": "invalid address"}); err == nil {
+		t.Fatal("expected invalid address is rejected")
+	}
+}
@@ -20,11 +20,14 @@ package metrics
 import (
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"fmt"
 	"sort"
 
 	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/metrics/shm"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -37,12 +40,21 @@ var (
 	errLabelCountExceeded = fmt.Errorf("label count exceeded, max is %d", maxLabelCount)
 )
 
+// StatsSuppressed is the gauge of the metrics suppressed by the stats matcher
+const StatsSuppressed = "stats_suppressed"
+
+// LogDropped is the gauge of the logs dropped by the loggers' buffer overflow
+const LogDropped = "log_dropped"
+
 // stats memory store
 type store struct {
 	matcher *metricsMatcher
 
 	metrics map[string]types.Metrics
 	mutex   sync.RWMutex
+
+	// suppressed records the full names of the metrics excluded by the matcher in use
+	suppressed map[string]struct{}
 }
 
 // metrics is a wrapper of go-metrics registry, is an implement of types.Metrics
@@ -55,6 +67,9 @@ type metrics struct {
 	labelVals []string
 
 	registry gometrics.Registry
+
+	// the updates are dropped if the state is not active
+	state uint32
 }
 
 func init() {
@@ -63,21 +78,71 @@ func init() {
 	defaultStore = &store{
 		matcher: defaultMatcher,
 		// TODO: default length configurable
-		metrics: make(map[string]types.Metrics, 100),
+		metrics:    make(map[string]types.Metrics, 100),
+		suppressed: make(map[string]struct{}),
+	}
+	registerLogDropped()
+}
+
+// registerLogDropped registers the gauge of the dropped logs. the logs are dropped in the log package
+// that does not depend on the metrics, so the gauge reads the dropped lines when it is read
+func registerLogDropped() {
+	m, _ := NewMetrics(MosnMetaType, map[string]string{"mosn": "stats"})
+	if s, ok := m.(*metrics); ok {
+		s.registry.GetOrRegister(LogDropped, gometrics.NewFunctionalGauge(log.DroppedLines))
 	}
 }
 
 // SetStatsMatcher sets the exclusion labels and exclusion keys
 // if a metrics labels/keys contains in exclusions, it will be ignored
 func SetStatsMatcher(all bool, exclusionLabels, exclusionKeys []string) {
+	SetStatsMatcherConfig(v2.StatsMatcher{
+		RejectAll:       all,
+		ExclusionLabels: exclusionLabels,
+		ExclusionKeys:   exclusionKeys,
+	})
+}
+
+// SetStatsMatcherConfig sets the stats matcher, it can be changed at runtime.
+// The matcher is evaluated when the metrics are created, the metrics created before are not affected.
+func SetStatsMatcherConfig(config v2.StatsMatcher) error {
+	matcher, err := newMetricsMatcher(config)
+	if err != nil {
+		return err
+	}
 	defaultStore.mutex.Lock()
-	defer defaultStore.mutex.Unlock()
+	defaultStore.matcher = matcher
+	reset := len(defaultStore.suppressed) > 0
+	defaultStore.suppressed = make(map[string]struct{})
+	defaultStore.mutex.Unlock()
 
-	defaultStore.matcher = &metricsMatcher{
-		rejectAll:       all,
-		exclusionLabels: exclusionLabels,
-		exclusionKeys:   exclusionKeys,
+	if reset {
+		setSuppressed(0)
 	}
+	return nil
+}
+
+// StatsMatcherConfig returns the stats matcher in use
+func StatsMatcherConfig() v2.StatsMatcher {
+	defaultStore.mutex.RLock()
+	defer defaultStore.mutex.RUnlock()
+	return defaultStore.matcher.config()
+}
+
+// suppress records the suppressed metrics, it returns the suppressed count if the name is not recorded before.
+// It should be called with the store's lock held
+func (s *store) suppress(name string) (count int, added bool) {
+	if _, ok := s.suppressed[name]; ok {
+		return 0, false
+	}
+	s.suppressed[name] = struct{}{}
+	return len(s.suppressed), true
+}
+
+// setSuppressed updates the suppressed gauge, it should be called without the store's lock
+func setSuppressed(count int) {
+	m, _ := NewMetrics(MosnMetaType, map[string]string{"mosn": "stats"})
+	m.Gauge(StatsSuppressed).Update(int64(count))
 }
 
 // NewMetrics returns a metrics
@@ -87,16 +152,21 @@ func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
 		return nil, errLabelCountExceeded
 	}
 
-	defaultStore.mutex.Lock()
-	defer defaultStore.mutex.Unlock()
+	name, keys, values := fullName(typ, labels)
 
+	defaultStore.mutex.Lock()
 	// support exclusion only
-	if defaultStore.matcher.isExclusionLabels(labels) {
+	if defaultStore.matcher.isExclusionMetrics(typ, labels) {
+		count, added := defaultStore.suppress(name)
+		defaultStore.mutex.Unlock()
+		if added {
+			setSuppressed(count)
+		}
 		return NewNilMetrics(typ, labels)
 	}
+	defer defaultStore.mutex.Unlock()
 
 	// check existence
-	name, keys, values := fullName(typ, labels)
 	if m, ok := defaultStore.metrics[name]; ok {
 		return m, nil
 	}
@@ -109,6 +179,9 @@ func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
 		prefix:    name + ".",
 		registry:  gometrics.NewRegistry(),
 	}
+	if atomic.LoadUint32(&frozen) == 1 {
+		stats.state = stateFrozen
+	}
 
 	defaultStore.metrics[name] = stats
 
@@ -149,30 +222,83 @@ func (s *metrics) SortedLabels() (keys, values []string) {
 
 func (s *metrics) Counter(key string) gometrics.Counter {
 	// support exclusion only
-	if defaultStore.matcher.isExclusionKey(key) {
+	if s.isExclusion(key) {
 		return gometrics.NilCounter{}
 	}
 
-	return s.registry.GetOrRegister(key, shm.NewShmCounterFunc(s.fullName(key))).(gometrics.Counter)
+	newCounter := shm.NewShmCounterFunc(s.fullName(key))
+	return s.registry.GetOrRegister(key, func() gometrics.Counter { return freezableCounter{newCounter(), &s.state} }).(gometrics.Counter)
 }
 
 func (s *metrics) Gauge(key string) gometrics.Gauge {
 	// support exclusion only
-	if defaultStore.matcher.isExclusionKey(key) {
+	if s.isExclusion(key) {
 		return gometrics.NilGauge{}
 	}
 
-	return s.registry.GetOrRegister(key, shm.NewShmGaugeFunc(s.fullName(key))).(gometrics.Gauge)
+	newGauge := shm.NewShmGaugeFunc(s.fullName(key))
+	return s.registry.GetOrRegister(key, func() gometrics.Gauge { return freezableGauge{newGauge(), &s.state} }).(gometrics.Gauge)
 }
 
 func (s *metrics) Histogram(key string) gometrics.Histogram {
 	// support exclusion only
-	if defaultStore.matcher.isExclusionKey(key) {
+	if s.isExclusion(key) {
 		return gometrics.NilHistogram{}
 	}
 
 	// TODO: notice the histogram only keeps 100 values as we set
-	return s.registry.GetOrRegister(key, func() gometrics.Histogram { return gometrics.NewHistogram(gometrics.NewUniformSample(100)) }).(gometrics.Histogram)
+	// the counters and gauges are kept in the shm zone during the smooth upgrade,
+	// but the histograms are process local, and start empty in the new process.
+	// there is no metrics transfer data yet to hand the histograms' aggregates off.
+	return s.registry.GetOrRegister(key, func() gometrics.Histogram {
+		return freezableHistogram{gometrics.NewHistogram(gometrics.NewUniformSample(100)), &s.state}
+	}).(gometrics.Histogram)
+}
+
+func (s *metrics) Meter(key string) gometrics.Meter {
+	// support exclusion only
+	if s.isExclusion(key) {
+		return gometrics.NilMeter{}
+	}
+
+	// the meters are process local like the histograms
+	return s.registry.GetOrRegister(key, func() gometrics.Meter {
+		return freezableMeter{gometrics.NewMeter(), &s.state}
+	}).(gometrics.Meter)
+}
+
+func (s *metrics) Timer(key string) gometrics.Timer {
+	// support exclusion only
+	if s.isExclusion(key) {
+		return gometrics.NilTimer{}
+	}
+
+	// the timers are process local like the histograms, and use the same sample
+	return s.registry.GetOrRegister(key, func() gometrics.Timer {
+		return freezableTimer{gometrics.NewCustomTimer(gometrics.NewHistogram(gometrics.NewUniformSample(100)), gometrics.NewMeter()), &s.state}
+	}).(gometrics.Timer)
+}
+
+// isExclusion returns the key will be ignored or not, the ignored one is recorded as suppressed.
+// The keys of a deleted store are ignored too, so its registry would not be refilled
+func (s *metrics) isExclusion(key string) bool {
+	if atomic.LoadUint32(&s.state) == stateDeleted {
+		return true
+	}
+	defaultStore.mutex.RLock()
+	excluded := defaultStore.matcher.isExclusionMetric(s.typ, s.labels, key)
+	defaultStore.mutex.RUnlock()
+	if !excluded {
+		return false
+	}
+
+	defaultStore.mutex.Lock()
+	count, added := defaultStore.suppress(s.fullName(key))
+	defaultStore.mutex.Unlock()
+	if added {
+		setSuppressed(count)
+	}
+	return true
 }
 
 func (s *metrics) Each(f func(string, interface{})) {
@@ -187,6 +313,28 @@ func (s *metrics) fullName(name string) string {
 	return s.prefix + name
 }
 
+// DeleteMetrics deletes the metrics of the type and labels, and frees its counters, gauges and histograms.
+// The updates of the deleted metrics are dropped, the metrics of the same type and labels
+// created later is a new one.
+func DeleteMetrics(typ string, labels map[string]string) {
+	name, _, _ := fullName(typ, labels)
+
+	defaultStore.mutex.Lock()
+	m, ok := defaultStore.metrics[name]
+	if ok {
+		delete(defaultStore.metrics, name)
+	}
+	defaultStore.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	if s, ok := m.(*metrics); ok {
+		atomic.StoreUint32(&s.state, stateDeleted)
+	}
+	m.UnregisterAll()
+}
+
 // GetAll returns all metrics data
 func GetAll() (metrics []types.Metrics) {
 	defaultStore.mutex.RLock()
@@ -208,6 +356,7 @@ func ResetAll() {
 	}
 	defaultStore.metrics = make(map[string]types.Metrics, 100)
 	defaultStore.matcher = defaultMatcher
+	defaultStore.suppressed = make(map[string]struct{})
 }
 
 func fullName(typ string, labels map[string]string) (fullName string, keys, values []string) {
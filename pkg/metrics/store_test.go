@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/metrics/shm"
 )
 
@@ -160,6 +162,186 @@ func TestExclusionKeys(t *testing.T) {
 	}
 }
 
+func TestExclusionRules(t *testing.T) {
+	zone := shm.InitMetricsZone("TestExclusionRules", 10*1024)
+	defer func() {
+		ResetAll()
+		zone.Detach()
+		shm.Reset()
+	}()
+
+	ResetAll()
+	if err := SetStatsMatcherConfig(v2.StatsMatcher{
+		ExclusionRules: []v2.StatsExclusionRule{
+			// the host stats of the dynamic clusters
+			{Type: UpstreamType, Labels: map[string]string{"cluster": "dyn-*", "host": "*"}},
+			// the keys of any type
+			{Labels: map[string]string{"listener": "*"}, Keys: []string{"request_time*"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	storeCases := []struct {
+		typ    string
+		labels map[string]string
+		isNil  bool
+	}{
+		{UpstreamType, map[string]string{"cluster": "dyn-1", "host": "127.0.0.1:80"}, true},
+		{UpstreamType, map[string]string{"cluster": "dyn-1"}, false},
+		{UpstreamType, map[string]string{"cluster": "static", "host": "127.0.0.1:80"}, false},
+		{DownstreamType, map[string]string{"cluster": "dyn-1", "host": "127.0.0.1:80"}, false},
+	}
+	for _, tc := range storeCases {
+		m, _ := NewMetrics(tc.typ, tc.labels)
+		if _, ok := m.(*NilMetrics); ok != tc.isNil {
+			t.Errorf("%s %v expected nil metrics %v", tc.typ, tc.labels, tc.isNil)
+		}
+	}
+
+	m, _ := NewMetrics(DownstreamType, map[string]string{"listener": "test"})
+	if _, ok := m.Histogram("request_time").(gometrics.NilHistogram); !ok {
+		t.Error("request_time should be excluded")
+	}
+	if _, ok := m.Counter("request_time_total").(gometrics.NilCounter); !ok {
+		t.Error("request_time_total should be excluded")
+	}
+	if _, ok := m.Counter("request_total").(gometrics.NilCounter); ok {
+		t.Error("request_total should not be excluded")
+	}
+	// the same metrics are counted once
+	m.Counter("request_time_total")
+	NewMetrics(UpstreamType, map[string]string{"cluster": "dyn-1", "host": "127.0.0.1:80"})
+
+	meta, _ := NewMetrics(MosnMetaType, map[string]string{"mosn": "stats"})
+	if suppressed := meta.Gauge(StatsSuppressed).Value(); suppressed != 3 {
+		t.Errorf("expected 3 metrics suppressed, but got %d", suppressed)
+	}
+	if config := StatsMatcherConfig(); len(config.ExclusionRules) != 2 {
+		t.Errorf("unexpected stats matcher config: %v", config)
+	}
+
+	// change at runtime, the suppressed count is reset
+	if err := SetStatsMatcherConfig(v2.StatsMatcher{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Counter("request_time_total").(gometrics.NilCounter); ok {
+		t.Error("request_time_total should not be excluded after the rules removed")
+	}
+	if suppressed := meta.Gauge(StatsSuppressed).Value(); suppressed != 0 {
+		t.Errorf("expected no metrics suppressed, but got %d", suppressed)
+	}
+
+	// invalid pattern is rejected, and the matcher in use is kept
+	if err := SetStatsMatcherConfig(v2.StatsMatcher{
+		ExclusionRules: []v2.StatsExclusionRule{{Keys: []string{"["}}},
+	}); err == nil {
+		t.Error("invalid pattern should be rejected")
+	}
+	if config := StatsMatcherConfig(); len(config.ExclusionRules) != 0 {
+		t.Errorf("unexpected stats matcher config: %v", config)
+	}
+}
+
+func TestDeleteMetrics(t *testing.T) {
+	zone := shm.InitMetricsZone("TestDeleteMetrics", 10*1024)
+	defer func() {
+		ResetAll()
+		zone.Detach()
+		shm.Reset()
+	}()
+
+	ResetAll()
+	labels := map[string]string{"cluster": "test", "host": "127.0.0.1:80"}
+	m, _ := NewMetrics(UpstreamType, labels)
+	counter := m.Counter("request_total")
+	gauge := m.Gauge("active")
+	histogram := m.Histogram("request_time")
+	counter.Inc(1)
+
+	DeleteMetrics(UpstreamType, labels)
+	if len(GetAll()) != 0 {
+		t.Fatal("deleted metrics should be removed from the store")
+	}
+	// the updates of the stale holders are dropped, and not counted as frozen drops
+	counter.Inc(1)
+	gauge.Update(1)
+	histogram.Update(1)
+	if histogram.Count() != 0 {
+		t.Error("the update of deleted histogram should be dropped")
+	}
+	if DroppedUpdates() != 0 {
+		t.Errorf("the updates of deleted metrics should not be counted, but got %d", DroppedUpdates())
+	}
+	if _, ok := m.Counter("request_total").(gometrics.NilCounter); !ok {
+		t.Error("deleted metrics should not register new counters")
+	}
+	// delete again is ok
+	DeleteMetrics(UpstreamType, labels)
+
+	// the same labels gets a new metrics
+	nm, _ := NewMetrics(UpstreamType, labels)
+	if nm == m {
+		t.Fatal("expected a new metrics")
+	}
+	if v := nm.Counter("request_total").Count(); v != 0 {
+		t.Errorf("new metrics should start from zero, but got %d", v)
+	}
+}
+
+func TestMeterTimer(t *testing.T) {
+	ResetAll()
+	defer Unfreeze()
+
+	m, _ := NewMetrics("test", map[string]string{"lk": "lv"})
+	meter := m.Meter("meter")
+	timer := m.Timer("timer")
+	meter.Mark(2)
+	timer.Update(time.Millisecond)
+	timer.Update(3 * time.Millisecond)
+	if meter.Count() != 2 {
+		t.Errorf("expected meter count 2, but got %d", meter.Count())
+	}
+	if timer.Count() != 2 || timer.Sum() != int64(4*time.Millisecond) || timer.Max() != int64(3*time.Millisecond) {
+		t.Errorf("unexpected timer, count: %d, sum: %d, max: %d", timer.Count(), timer.Sum(), timer.Max())
+	}
+	// the same key gets the same one
+	m.Meter("meter").Mark(1)
+	if meter.Count() != 3 {
+		t.Errorf("expected meter count 3, but got %d", meter.Count())
+	}
+
+	// the snapshot keeps the values
+	snap := snapshot(m)
+	if snap.Meter("meter").Count() != 3 || snap.Timer("timer").Count() != 2 {
+		t.Error("unexpected snapshot values")
+	}
+
+	// the updates are dropped if frozen, the timed function is still called
+	Freeze()
+	called := false
+	meter.Mark(1)
+	timer.Time(func() { called = true })
+	timer.UpdateSince(time.Now())
+	if !called {
+		t.Error("the timed function should be called")
+	}
+	if meter.Count() != 3 || timer.Count() != 2 || DroppedUpdates() != 3 {
+		t.Errorf("updates should be dropped, meter: %d, timer: %d, dropped: %d", meter.Count(), timer.Count(), DroppedUpdates())
+	}
+	Unfreeze()
+
+	// support exclusion
+	SetStatsMatcher(false, nil, []string{"excluded"})
+	defer SetStatsMatcher(false, nil, nil)
+	if _, ok := m.Meter("excluded").(gometrics.NilMeter); !ok {
+		t.Error("excluded meter should be nil")
+	}
+	if _, ok := m.Timer("excluded").(gometrics.NilTimer); !ok {
+		t.Error("excluded timer should be nil")
+	}
+}
+
 func BenchmarkNewMetrics_SameLabels(b *testing.B) {
 	ResetAll()
 	total := b.N
@@ -0,0 +1,37 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// StreamType represents the stream connections metrics type
+const StreamType = "stream"
+
+// stream connections metrics key
+const (
+	// ServePanicTotal counts the panics recovered in the serve loops of the stream connections
+	ServePanicTotal = "serve_panic_total"
+)
+
+// NewStreamStats returns the stats of the stream connections of the protocol
+func NewStreamStats(protocol string) types.Metrics {
+	metrics, _ := NewMetrics(StreamType, map[string]string{"protocol": protocol})
+	return metrics
+}
@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// TLSType represents the tls certificates metrics type
+const TLSType = "tls"
+
+// tls metrics key
+const (
+	// TLSCertificateExpiryDays is the days until the certificate expires, it is negative if the certificate is expired
+	TLSCertificateExpiryDays = "expiry_days"
+	TLSReloadSuccess         = "reload_success"
+	TLSReloadFailed          = "reload_failed"
+)
+
+// NewTLSStats returns the stats of the tls contexts reloading
+func NewTLSStats() types.Metrics {
+	metrics, _ := NewMetrics(TLSType, map[string]string{"tls": "reload"})
+	return metrics
+}
+
+// NewTLSCertificateStats returns the stats of a certificate
+func NewTLSCertificateStats(name string) types.Metrics {
+	metrics, _ := NewMetrics(TLSType, map[string]string{"certificate": name})
+	return metrics
+}
+
+// DeleteTLSCertificateStats deletes the stats of a certificate, it is called when the certificate is not used
+func DeleteTLSCertificateStats(name string) {
+	DeleteMetrics(TLSType, map[string]string{"certificate": name})
+}
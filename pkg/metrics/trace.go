@@ -0,0 +1,41 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// TraceType represents trace reporter metrics type
+const TraceType = "trace"
+
+// trace reporter metrics key
+const (
+	TraceSpanSampled    = "span_sampled"
+	TraceSpanReported   = "span_reported"
+	TraceSpanDropped    = "span_dropped"
+	TraceSpanSendFailed = "span_send_failed"
+	TraceSpanLogged     = "span_logged"
+	TraceBatchSent      = "batch_sent"
+)
+
+// NewTraceStats returns the stats of the span reporter of the driver
+func NewTraceStats(driver string) types.Metrics {
+	metrics, _ := NewMetrics(TraceType, map[string]string{"driver": driver})
+	return metrics
+}
@@ -36,6 +36,10 @@ const (
 	UpstreamConnectionLocalCloseWithActiveRequest  = "connection_local_close_with_active_request"
 	UpstreamConnectionRemoteCloseWithActiveRequest = "connection_remote_close_with_active_request"
 	UpstreamConnectionCloseNotify                  = "connection_close_notify"
+	UpstreamConnectionBackoff                      = "connection_backoff"
+	UpstreamConnectionPerDownstreamTotal           = "connection_per_downstream_total"
+	UpstreamConnectionPerDownstreamActive          = "connection_per_downstream_active"
+	UpstreamConnectionConnectDurationMs            = "connection_connect_duration_ms"
 	UpstreamRequestTotal                           = "request_total"
 	UpstreamRequestActive                          = "request_active"
 	UpstreamRequestLocalReset                      = "request_local_reset"
@@ -43,8 +47,12 @@ const (
 	UpstreamRequestTimeout                         = "request_timeout"
 	UpstreamRequestFailureEject                    = "request_failure_eject"
 	UpstreamRequestPendingOverflow                 = "request_pending_overflow"
+	UpstreamKeepAliveEject                         = "keepalive_eject"
+	UpstreamKeepAliveSuccessTotal                  = "keepalive_success_total"
+	UpstreamKeepAliveTimeoutTotal                  = "keepalive_timeout_total"
+	UpstreamKeepAliveClosedConnectionTotal         = "keepalive_closed_connection_total"
+	UpstreamKeepAliveOutstanding                   = "keepalive_outstanding"
 	UpstreamRequestDuration                        = "request_duration_time"
-	UpstreamRequestDurationTotal                   = "request_duration_time_total"
 	UpstreamResponseSuccess                        = "response_success"
 	UpstreamResponseFailed                         = "response_failed"
 )
@@ -59,6 +67,71 @@ const (
 	UpstreamBytesReadBuffered    = "connection_bytes_read_buffered"
 	UpstreamBytesWriteTotal      = "connection_bytes_write"
 	UpstreamBytesWriteBuffered   = "connection_bytes_write_buffered"
+	UpstreamResponseCode2xx      = "response_code_2xx"
+	UpstreamResponseCode3xx      = "response_code_3xx"
+	UpstreamResponseCode4xx      = "response_code_4xx"
+	UpstreamResponseCode5xx      = "response_code_5xx"
+	// UpstreamResponseGrpcOK, UpstreamResponseGrpc4xx and UpstreamResponseGrpc5xx are the grpc responses
+	// classified by the http status that the grpc-status maps to
+	UpstreamResponseGrpcOK  = "response_grpc_ok"
+	UpstreamResponseGrpc4xx = "response_grpc_4xx"
+	UpstreamResponseGrpc5xx = "response_grpc_5xx"
+	// UpstreamTLSHandshakeFail is the upstream connections failed by the tls handshake
+	UpstreamTLSHandshakeFail = "tls_handshake_fail"
+	// UpstreamHostSlowStart is the hosts in the slow start window
+	UpstreamHostSlowStart = "host_slow_start"
+	// UpstreamOutlierEjectionsTotal and UpstreamOutlierEjectionsActive are the hosts ejected by the outlier detector
+	UpstreamOutlierEjectionsTotal  = "outlier_ejections_total"
+	UpstreamOutlierEjectionsActive = "outlier_ejections_active"
+	// UpstreamHostDraining is the removed hosts waiting for their active requests to be done
+	UpstreamHostDraining = "host_draining"
+	// the udp sessions of the udp proxy, a datagram is dropped if the session is not created or it is not sent
+	UpstreamUDPSessionTotal       = "udp_session_total"
+	UpstreamUDPSessionActive      = "udp_session_active"
+	UpstreamUDPSessionIdleTimeout = "udp_session_idle_timeout"
+	UpstreamUDPDatagramsSent      = "udp_datagrams_sent"
+	UpstreamUDPDatagramsReceived  = "udp_datagrams_received"
+	UpstreamUDPDatagramsDropped   = "udp_datagrams_dropped"
+	// the connections of the tcp proxy, the downstream bytes are the bytes read from the downstream and written
+	// to the upstream, the upstream bytes are the bytes read from the upstream and written to the downstream
+	UpstreamTCPProxyDownstreamBytes      = "tcp_proxy_downstream_bytes"
+	UpstreamTCPProxyUpstreamBytes        = "tcp_proxy_upstream_bytes"
+	UpstreamTCPProxyIdleTimeout          = "tcp_proxy_idle_timeout"
+	UpstreamTCPProxyConnectionDurationMs = "tcp_proxy_connection_duration_ms"
+	// the connections crossed the write buffer watermarks, the backed up connections are above the high watermark now,
+	// and the configured watermarks
+	UpstreamWriteBufferHighWatermarkTotal = "write_buffer_high_watermark_total"
+	UpstreamWriteBufferLowWatermarkTotal  = "write_buffer_low_watermark_total"
+	UpstreamWriteBufferBackedUp           = "write_buffer_backed_up"
+	UpstreamWriteBufferHighWatermark      = "write_buffer_high_watermark"
+	UpstreamWriteBufferLowWatermark       = "write_buffer_low_watermark"
+	// UpstreamResponseMethodPrefix is followed by the request method, it is counted if the cluster's method stats is enabled,
+	// the methods not known are counted as UpstreamResponseMethodOther
+	UpstreamResponseMethodPrefix = "response_method_"
+	UpstreamResponseMethodOther  = "other"
+	// UpstreamCircuitBreakersPrefix is followed by the priority and the circuit breakers key, e.g. circuit_breakers.default.rq_open
+	UpstreamCircuitBreakersPrefix = "circuit_breakers."
+)
+
+//  key of the circuit breakers in cluster, the open gauges are 1 if the resource is exhausted,
+// the remaining gauges are the resources left before the circuit breakers are open
+const (
+	CircuitBreakersConnectionsOpen      = "cx_open"
+	CircuitBreakersPendingOpen          = "rq_pending_open"
+	CircuitBreakersRequestsOpen         = "rq_open"
+	CircuitBreakersRetriesOpen          = "rq_retry_open"
+	CircuitBreakersRemainingConnections = "remaining_cx"
+	CircuitBreakersRemainingPending     = "remaining_pending"
+	CircuitBreakersRemainingRequests    = "remaining_rq"
+	CircuitBreakersRemainingRetries     = "remaining_retries"
+)
+
+//  key in host
+const (
+	// the health check requests are not counted in request_total
+	HostHealthCheckAttempt = "healthcheck_attempt"
+	HostHealthCheckSuccess = "healthcheck_success"
+	HostHealthCheckFailure = "healthcheck_failure"
 )
 
 // NewHostStats returns a stats that namespace contains cluster and host address
@@ -72,3 +145,13 @@ func NewClusterStats(clusterName string) types.Metrics {
 	metrics, _ := NewMetrics(UpstreamType, map[string]string{"cluster": clusterName})
 	return metrics
 }
+
+// DeleteHostStats deletes the stats of the host in the cluster, it is called when the host is removed
+func DeleteHostStats(clusterName string, addr string) {
+	DeleteMetrics(UpstreamType, map[string]string{"cluster": clusterName, "host": addr})
+}
+
+// DeleteClusterStats deletes the stats of the cluster, it is called when the cluster is removed
+func DeleteClusterStats(clusterName string) {
+	DeleteMetrics(UpstreamType, map[string]string{"cluster": clusterName})
+}
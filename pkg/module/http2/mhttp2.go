@@ -921,6 +921,15 @@ func NewClientConn(conn types.Connection) *MClientConn {
 	return cc
 }
 
+// GoAway writes GoAway Frame for Http2 Client, the server initiates no stream so the last stream id is 0
+func (cc *MClientConn) GoAway() error {
+	buf := buffer.NewIoBuffer(frameHeaderLen + 8)
+	cc.Framer.startWrite(buf, FrameGoAway, 0, 0)
+	cc.Framer.writeUint32(buf, 0)
+	cc.Framer.writeUint32(buf, uint32(ErrCodeNo))
+	return cc.Framer.endWrite(buf)
+}
+
 // WriteHeaders wirtes Headers Frame for Http2 Client
 func (cc *MClientConn) WriteHeaders(ctx context.Context, req *http.Request, trailers string, endStream bool) (*clientStream, error) {
 	if err := checkConnHeaders(req); err != nil {
@@ -0,0 +1,290 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	_ "sofastack.io/sofa-mosn/pkg/filter/network/connectionmanager"
+	_ "sofastack.io/sofa-mosn/pkg/filter/network/proxy"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/router"
+	"sofastack.io/sofa-mosn/pkg/server"
+	_ "sofastack.io/sofa-mosn/pkg/stream/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+// DefaultLogPath is the default log path of the embedded proxy
+const DefaultLogPath = "stdout"
+
+var (
+	errNoRouter        = errors.New("router config name is required if the listener has no filter chain")
+	errStarted         = errors.New("embedded proxy is already started")
+	errStopped         = errors.New("embedded proxy is already stopped")
+	errNoClusterName   = errors.New("name is required in cluster config")
+	errNoListenAddress = errors.New("address is required in listener config")
+)
+
+// the process-wide states, such as the logger and the proxy worker pool, are initialized once
+var initOnce sync.Once
+
+// EmbeddedConfig is the config of an embedded proxy
+type EmbeddedConfig struct {
+	// Listener is the proxy listener, the address can use port 0 to bind an ephemeral port.
+	// If the listener has no filter chain, a http1 proxy filter chain using the Router is created.
+	Listener v2.Listener
+	// Router is added to the router manager, the routers in the connection manager filter
+	// of the listener are added too
+	Router *v2.RouterConfiguration
+	// Clusters are added to the cluster manager before the proxy starts
+	Clusters []v2.Cluster
+	// LogPath is the default logger's path, DefaultLogPath is used if it is empty.
+	// The logger is initialized by the first embedded proxy in the process.
+	LogPath string
+	// LogLevel is the default logger's level, such as "DEBUG", "INFO", "WARN" and "ERROR"
+	LogLevel string
+}
+
+// EmbeddedProxy runs a proxy listener in the process without the config file and the server bootstrap.
+// The cluster manager and the router manager are shared in the process, so the clusters and the routers
+// with the same name are shared by the embedded proxies.
+type EmbeddedProxy struct {
+	handler        types.ConnectionHandler
+	clusterManager types.ClusterManager
+	routerManager  types.RouterManager
+	addr           net.Addr
+
+	mux      sync.Mutex
+	clusters map[string]struct{} // clusters added by the proxy, removed when the proxy stops
+	started  bool
+	stopped  bool
+}
+
+// NewEmbeddedProxy creates an embedded proxy, the listener is bound when it returns,
+// and it starts to accept connections after Start
+func NewEmbeddedProxy(cfg EmbeddedConfig) (*EmbeddedProxy, error) {
+	initOnce.Do(func() {
+		initServer(cfg)
+	})
+
+	lc := cfg.Listener
+	if lc.AddrConfig == "" {
+		return nil, errNoListenAddress
+	}
+	if len(lc.FilterChains) == 0 {
+		if cfg.Router == nil || cfg.Router.RouterConfigName == "" {
+			return nil, errNoRouter
+		}
+		lc.FilterChains = []v2.FilterChain{newProxyFilterChain(cfg.Router.RouterConfigName)}
+	}
+
+	p := &EmbeddedProxy{
+		clusterManager: cluster.NewClusterManagerSingleton(nil, nil),
+		routerManager:  router.NewRouterManager(),
+		clusters:       make(map[string]struct{}),
+	}
+	p.handler = server.NewHandler(&clusterManagerFilter{}, p.clusterManager)
+
+	for _, c := range cfg.Clusters {
+		if err := p.AddCluster(c); err != nil {
+			p.removeClusters()
+			return nil, err
+		}
+	}
+	if cfg.Router != nil {
+		if err := p.UpdateRoute(cfg.Router); err != nil {
+			p.removeClusters()
+			return nil, err
+		}
+	}
+	for i := range lc.FilterChains {
+		if routerConfig := config.ParseRouterConfiguration(&lc.FilterChains[i]); routerConfig.RouterConfigName != "" {
+			if err := p.UpdateRoute(routerConfig); err != nil {
+				p.removeClusters()
+				return nil, err
+			}
+		}
+	}
+
+	// bind the address here, so the listen error is returned, and the ephemeral port is known
+	addr, err := net.ResolveTCPAddr("tcp", lc.AddrConfig)
+	if err != nil {
+		p.removeClusters()
+		return nil, fmt.Errorf("invalid listen address %s: %v", lc.AddrConfig, err)
+	}
+	ln, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		p.removeClusters()
+		return nil, err
+	}
+	lc.Addr = ln.Addr()
+	lc.InheritListener = ln
+	lc.BindToPort = true
+	if lc.PerConnBufferLimitBytes == 0 {
+		lc.PerConnBufferLimitBytes = 1 << 15
+	}
+	p.addr = lc.Addr
+
+	nfcf := config.GetNetworkFilters(&lc.FilterChains[0])
+	sfcf := config.GetStreamFilters(lc.StreamFilters)
+	if _, err := p.handler.AddOrUpdateListener(&lc, nfcf, sfcf); err != nil {
+		ln.Close()
+		p.removeClusters()
+		return nil, err
+	}
+	log.DefaultLogger.Infof("[embedded] create embedded proxy, listen address: %s", p.addr)
+	return p, nil
+}
+
+// Addr returns the listener's bound address
+func (p *EmbeddedProxy) Addr() net.Addr {
+	return p.addr
+}
+
+// Start starts to accept connections
+func (p *EmbeddedProxy) Start() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.stopped {
+		return errStopped
+	}
+	if p.started {
+		return errStarted
+	}
+	p.started = true
+	p.handler.StartListeners(nil)
+	return nil
+}
+
+// Stop closes the listener and the connections, and removes the clusters added by the proxy.
+// It waits for the connections to be closed until the ctx is done.
+func (p *EmbeddedProxy) Stop(ctx context.Context) error {
+	p.mux.Lock()
+	if p.stopped {
+		p.mux.Unlock()
+		return errStopped
+	}
+	p.stopped = true
+	p.mux.Unlock()
+
+	if err := p.handler.StopListeners(nil, true); err != nil {
+		log.DefaultLogger.Errorf("[embedded] close listener failed: %v", err)
+	}
+	p.handler.CloseConnections()
+	p.removeClusters()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for p.handler.NumConnections() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	log.DefaultLogger.Infof("[embedded] embedded proxy stopped, listen address: %s", p.addr)
+	return nil
+}
+
+// AddCluster adds or updates a cluster and its hosts
+func (p *EmbeddedProxy) AddCluster(c v2.Cluster) error {
+	if c.Name == "" {
+		return errNoClusterName
+	}
+	clusters, clusterMap := config.ParseClusterConfig([]v2.Cluster{c})
+	if err := p.clusterManager.AddOrUpdatePrimaryCluster(clusters[0]); err != nil {
+		return err
+	}
+	if err := p.clusterManager.UpdateClusterHosts(c.Name, clusterMap[c.Name]); err != nil {
+		return err
+	}
+	p.mux.Lock()
+	p.clusters[c.Name] = struct{}{}
+	p.mux.Unlock()
+	return nil
+}
+
+// UpdateRoute adds or updates the router config, it takes effect on the next request
+func (p *EmbeddedProxy) UpdateRoute(routerConfig *v2.RouterConfiguration) error {
+	return p.routerManager.AddOrUpdateRouters(routerConfig)
+}
+
+func (p *EmbeddedProxy) removeClusters() {
+	p.mux.Lock()
+	names := make([]string, 0, len(p.clusters))
+	for name := range p.clusters {
+		names = append(names, name)
+	}
+	p.clusters = make(map[string]struct{})
+	p.mux.Unlock()
+
+	if len(names) > 0 {
+		if err := p.clusterManager.RemovePrimaryCluster(names...); err != nil {
+			log.DefaultLogger.Errorf("[embedded] remove clusters %v failed: %v", names, err)
+		}
+	}
+}
+
+// initServer initializes the default logger and triggers the processor callbacks like the server bootstrap
+func initServer(cfg EmbeddedConfig) {
+	logPath := cfg.LogPath
+	if logPath == "" {
+		logPath = DefaultLogPath
+	}
+	sc := config.ParseServerConfig(&v2.ServerConfig{
+		DefaultLogPath:  logPath,
+		DefaultLogLevel: cfg.LogLevel,
+	})
+	server.InitDefaultLogger(server.NewConfig(sc))
+	// validate the routes' cluster references against the clusters in cluster manager
+	cluster.RegisterClustersChangeListener(router.SetClusterChecker)
+}
+
+// newProxyFilterChain returns a http1 proxy filter chain
+func newProxyFilterChain(routerConfigName string) v2.FilterChain {
+	return v2.FilterChain{
+		FilterChainConfig: v2.FilterChainConfig{
+			Filters: []v2.Filter{
+				{
+					Type: v2.DEFAULT_NETWORK_FILTER,
+					Config: map[string]interface{}{
+						"downstream_protocol": string(protocol.HTTP1),
+						"upstream_protocol":   string(protocol.HTTP1),
+						"router_config_name":  routerConfigName,
+					},
+				},
+			},
+		},
+	}
+}
+
+// the embedded proxy does not update the clusters by the connection handler
+type clusterManagerFilter struct{}
+
+func (cmf *clusterManagerFilter) OnCreated(cccb types.ClusterConfigFactoryCb, chcb types.ClusterHostFactoryCb) {
+}
@@ -0,0 +1,384 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+)
+
+func newBackend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+	}))
+}
+
+func newCluster(name string, backend *httptest.Server) v2.Cluster {
+	return v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		Hosts: []v2.Host{
+			{
+				HostConfig: v2.HostConfig{
+					Address: strings.TrimPrefix(backend.URL, "http://"),
+				},
+			},
+		},
+	}
+}
+
+func newRouter(routerConfigName, clusterName string) *v2.RouterConfiguration {
+	return &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: routerConfigName,
+		},
+		VirtualHosts: []*v2.VirtualHost{
+			{
+				Name:    "embedded",
+				Domains: []string{"*"},
+				Routers: []v2.Router{
+					{
+						RouterConfig: v2.RouterConfig{
+							Match: v2.RouterMatch{Prefix: "/"},
+							Route: v2.RouteAction{
+								RouterActionConfig: v2.RouterActionConfig{
+									ClusterName: clusterName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// proxyGoroutines returns the goroutines running the mosn code,
+// the proxy worker pool is shared in the process and is not counted
+func proxyGoroutines() (int, string) {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	n := 0
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "sofa-mosn/pkg/") && !strings.Contains(g, "(*workerPool).spawnWorker") {
+			n++
+		}
+	}
+	return n, string(buf)
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status code %d, body %s", resp.StatusCode, body)
+	}
+	return string(body)
+}
+
+func TestEmbeddedProxy(t *testing.T) {
+	backend1 := newBackend("backend1")
+	defer backend1.Close()
+	backend2 := newBackend("backend2")
+	defer backend2.Close()
+
+	proxy, err := NewEmbeddedProxy(EmbeddedConfig{
+		Listener: v2.Listener{
+			ListenerConfig: v2.ListenerConfig{
+				Name:       "embedded_listener",
+				AddrConfig: "127.0.0.1:0",
+			},
+		},
+		Router:   newRouter("embedded_router", "cluster1"),
+		Clusters: []v2.Cluster{newCluster("cluster1", backend1)},
+		LogLevel: "ERROR",
+	})
+	if err != nil {
+		t.Fatalf("create embedded proxy failed: %v", err)
+	}
+	// the goroutines created by the proxy should be gone after it stops
+	goroutines, _ := proxyGoroutines()
+
+	if err := proxy.Start(); err != nil {
+		t.Fatalf("start embedded proxy failed: %v", err)
+	}
+	if err := proxy.Start(); err != errStarted {
+		t.Fatalf("start twice expected %v, but got %v", errStarted, err)
+	}
+
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport, Timeout: 3 * time.Second}
+	url := fmt.Sprintf("http://%s/", proxy.Addr())
+	if body := get(t, client, url); body != "backend1" {
+		t.Fatalf("expected response from backend1, but got %s", body)
+	}
+
+	// route to another cluster at runtime
+	if err := proxy.AddCluster(newCluster("cluster2", backend2)); err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+	if err := proxy.UpdateRoute(newRouter("embedded_router", "cluster2")); err != nil {
+		t.Fatalf("update route failed: %v", err)
+	}
+	if body := get(t, client, url); body != "backend2" {
+		t.Fatalf("expected response from backend2, but got %s", body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := proxy.Stop(ctx); err != nil {
+		t.Fatalf("stop embedded proxy failed: %v", err)
+	}
+	transport.CloseIdleConnections()
+	if _, err := client.Get(url); err == nil {
+		t.Fatal("stopped proxy should not accept connections")
+	}
+	if err := proxy.Start(); err != errStopped {
+		t.Fatalf("start a stopped proxy expected %v, but got %v", errStopped, err)
+	}
+
+	for i := 0; ; i++ {
+		n, stack := proxyGoroutines()
+		if n <= goroutines {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("goroutines leaked, expected %d, but got %d\n%s", goroutines, n, stack)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestEmbeddedProxyConfigError(t *testing.T) {
+	if _, err := NewEmbeddedProxy(EmbeddedConfig{}); err != errNoListenAddress {
+		t.Errorf("expected %v, but got %v", errNoListenAddress, err)
+	}
+	listener := v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			AddrConfig: "127.0.0.1:0",
+		},
+	}
+	if _, err := NewEmbeddedProxy(EmbeddedConfig{Listener: listener}); err != errNoRouter {
+		t.Errorf("expected %v, but got %v", errNoRouter, err)
+	}
+	if _, err := NewEmbeddedProxy(EmbeddedConfig{
+		Listener: listener,
+		Router:   newRouter("config_error_router", "cluster"),
+		Clusters: []v2.Cluster{{}},
+	}); err != errNoClusterName {
+		t.Errorf("expected %v, but got %v", errNoClusterName, err)
+	}
+}
+
+// newLoopProxy creates a proxy routing the requests to itself
+func newLoopProxy(t *testing.T, name string, loopDetection map[string]interface{}) *EmbeddedProxy {
+	routerName := name + "_router"
+	clusterName := name + "_cluster"
+	proxyConfig := map[string]interface{}{
+		"downstream_protocol": "Http1",
+		"upstream_protocol":   "Http1",
+		"router_config_name":  routerName,
+	}
+	if loopDetection != nil {
+		proxyConfig["loop_detection"] = loopDetection
+	}
+	proxy, err := NewEmbeddedProxy(EmbeddedConfig{
+		Listener: v2.Listener{
+			ListenerConfig: v2.ListenerConfig{
+				Name:       name,
+				AddrConfig: "127.0.0.1:0",
+				FilterChains: []v2.FilterChain{
+					{
+						FilterChainConfig: v2.FilterChainConfig{
+							Filters: []v2.Filter{
+								{
+									Type:   v2.DEFAULT_NETWORK_FILTER,
+									Config: proxyConfig,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Router:   newRouter(routerName, clusterName),
+		LogLevel: "ERROR",
+	})
+	if err != nil {
+		t.Fatalf("create embedded proxy failed: %v", err)
+	}
+	if err := proxy.AddCluster(v2.Cluster{
+		Name:        clusterName,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		Hosts: []v2.Host{
+			{
+				HostConfig: v2.HostConfig{
+					Address: proxy.Addr().String(),
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+	if err := proxy.Start(); err != nil {
+		t.Fatalf("start embedded proxy failed: %v", err)
+	}
+	return proxy
+}
+
+func stopProxy(t *testing.T, proxy *EmbeddedProxy) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := proxy.Stop(ctx); err != nil {
+		t.Errorf("stop embedded proxy failed: %v", err)
+	}
+}
+
+func TestEmbeddedProxyLoop(t *testing.T) {
+	proxy := newLoopProxy(t, "loop_listener", nil)
+	defer stopProxy(t, proxy)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := fmt.Sprintf("http://%s/", proxy.Addr())
+
+	// the request is rejected after it passes the proxy 33 times
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 508 {
+		t.Fatalf("expected loop detected, but got status code %d", resp.StatusCode)
+	}
+	if hops := resp.Header.Get("x-mosn-hops"); hops != "33" {
+		t.Fatalf("expected the request rejected at hop 33, but got %s", hops)
+	}
+
+	// TRACE request is answered by the proxy when the Max-Forwards reaches zero
+	req, _ := http.NewRequest("TRACE", url, nil)
+	req.Header.Set("Max-Forwards", "3")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("trace %s failed: %v", url, err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "message/http" {
+		t.Fatalf("expected trace echoed, but got status code %d, content type %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "Max-Forwards: 0") || !strings.Contains(string(body), "X-Mosn-Hops: 3") {
+		t.Fatalf("unexpected trace body: %s", body)
+	}
+}
+
+func TestEmbeddedProxyLoopInstance(t *testing.T) {
+	proxy := newLoopProxy(t, "loop_instance_listener", map[string]interface{}{
+		"instance_check": true,
+		"instance_id":    "mosn-a",
+	})
+	defer stopProxy(t, proxy)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := fmt.Sprintf("http://%s/", proxy.Addr())
+
+	// the request is rejected once it comes back to the same instance
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 508 {
+		t.Fatalf("expected loop detected, but got status code %d", resp.StatusCode)
+	}
+	if hops := resp.Header.Get("x-mosn-hops"); hops != "2" {
+		t.Fatalf("expected the request rejected at hop 2, but got %s", hops)
+	}
+}
+
+func TestEmbeddedProxyRouteBeforeCluster(t *testing.T) {
+	backend := newBackend("late_backend")
+	defer backend.Close()
+
+	proxy, err := NewEmbeddedProxy(EmbeddedConfig{
+		Listener: v2.Listener{
+			ListenerConfig: v2.ListenerConfig{
+				Name:       "late_cluster_listener",
+				AddrConfig: "127.0.0.1:0",
+			},
+		},
+		Router:   newRouter("late_cluster_router", "late_cluster"),
+		LogLevel: "ERROR",
+	})
+	if err != nil {
+		t.Fatalf("create embedded proxy failed: %v", err)
+	}
+	if err := proxy.Start(); err != nil {
+		t.Fatalf("start embedded proxy failed: %v", err)
+	}
+	defer stopProxy(t, proxy)
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	url := fmt.Sprintf("http://%s/", proxy.Addr())
+
+	// the route references a cluster not configured yet
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected service unavailable, but got status code %d", resp.StatusCode)
+	}
+	if reply := resp.Header.Get("x-mosn-local-reply"); reply != "no_cluster_configured" {
+		t.Fatalf("expected no_cluster_configured local reply, but got %s", reply)
+	}
+	counter := metrics.NewListenerStats("late_cluster_listener").Counter(metrics.DownstreamRequestNoCluster)
+	if counter.Count() != 1 {
+		t.Fatalf("expected 1 request without cluster configured, but got %d", counter.Count())
+	}
+
+	// the route works once the cluster is added, without updating the route
+	if err := proxy.AddCluster(newCluster("late_cluster", backend)); err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+	if body := get(t, client, url); body != "late_backend" {
+		t.Fatalf("expected response from late_backend, but got %s", body)
+	}
+	if counter.Count() != 1 {
+		t.Fatalf("expected 1 request without cluster configured, but got %d", counter.Count())
+	}
+}
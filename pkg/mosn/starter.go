@@ -18,6 +18,7 @@
 package mosn
 
 import (
+	"fmt"
 	"net"
 	"sync"
 
@@ -31,6 +32,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/metrics/shm"
 	"sofastack.io/sofa-mosn/pkg/metrics/sink"
 	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/overload"
 	"sofastack.io/sofa-mosn/pkg/router"
 	"sofastack.io/sofa-mosn/pkg/server"
 	"sofastack.io/sofa-mosn/pkg/server/keeper"
@@ -41,6 +43,18 @@ import (
 	"sofastack.io/sofa-mosn/pkg/xds"
 )
 
+func init() {
+	// apply the partial cluster config updates to the running clusters
+	config.RegisterClusterConfigUpdateListener(onClusterConfigUpdate)
+	// apply the reloaded router configs to the running routers
+	config.RegisterRouterConfigUpdateListener(onRouterConfigUpdate)
+	// apply the reloaded listener limits to the running listeners
+	config.RegisterListenerLimitsUpdateListener(onListenerLimitsUpdate)
+	config.RegisterListenerTLSUpdateListener(onListenerTLSUpdate)
+	// validate the routes' cluster references against the clusters in cluster manager
+	cluster.RegisterClustersChangeListener(router.SetClusterChecker)
+}
+
 // Mosn class which wrapper server
 type Mosn struct {
 	servers        []server.Server
@@ -82,6 +96,8 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 	}
 
 	initializeMetrics(c.Metrics)
+	initializeOverload(c.Overload)
+	initializeRuntime(c.Runtime)
 
 	m := &Mosn{
 		config:           c,
@@ -129,6 +145,7 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 	}
 
 	// initialize the routerManager
+	router.SetRouteValidationMode(c.RouteValidation)
 	m.routerManager = router.NewRouterManager()
 
 	for _, serverConfig := range c.Servers {
@@ -158,9 +175,14 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 				// parse ListenerConfig
 				lc := config.ParseListenerConfig(&serverConfig.Listeners[idx], inheritListeners)
 
-				// parse routers from connection_manager filter and add it the routerManager
-				if routerConfig := config.ParseRouterConfiguration(&lc.FilterChains[0]); routerConfig.RouterConfigName != "" {
-					m.routerManager.AddOrUpdateRouters(routerConfig)
+				// parse routers from connection_manager filter and add it the routerManager,
+				// each filter chain selected by the server names has its own router
+				for i := range lc.FilterChains {
+					if routerConfig := config.ParseRouterConfiguration(&lc.FilterChains[i]); routerConfig.RouterConfigName != "" {
+						if err := m.routerManager.AddOrUpdateRouters(routerConfig); err != nil {
+							log.StartLogger.Fatalf("[mosn] [NewMosn] AddOrUpdateRouters error:%s", err.Error())
+						}
+					}
 				}
 
 				var nfcf []types.NetworkFilterChainFactory
@@ -265,9 +287,14 @@ func (m *Mosn) Close() {
 	// close service
 	store.CloseService()
 
+	// write back the config changes not dumped yet
+	config.ForceDump()
+
 	// stop reconfigure domain socket
 	server.StopReconfigureHandler()
 
+	overload.Stop()
+
 	// stop mosn server
 	for _, srv := range m.servers {
 		srv.Close()
@@ -303,18 +330,45 @@ func initializeTracing(config config.TracingConfig) {
 	}
 }
 
+func initializeOverload(cfg *v2.OverloadConfig) {
+	if cfg == nil {
+		return
+	}
+	if err := overload.Start(cfg); err != nil {
+		log.StartLogger.Errorf("[mosn] [init overload] start overload manager failed: %v, overload manager is turned off", err)
+	}
+}
+
+func initializeRuntime(values map[string]interface{}) {
+	if err := config.SetRuntimeBase(values); err != nil {
+		log.StartLogger.Fatalf("[mosn] [init runtime] set runtime failed: %v", err)
+	}
+}
+
 func initializeMetrics(config config.MetricsConfig) {
+	// the shutdown callbacks are called in order, the metrics are frozen and flushed
+	// at last before the shm zone is detached
+	keeper.OnProcessShutDown(metrics.FinalFlush)
+
 	// init shm zone
 	if config.ShmZone != "" && config.ShmSize > 0 {
 		shm.InitDefaultMetricsZone(config.ShmZone, int(config.ShmSize), store.GetMosnState() != store.Active_Reconfiguring)
 	}
 
 	// set metrics package
-	statsMatcher := config.StatsMatcher
-	metrics.SetStatsMatcher(statsMatcher.RejectAll, statsMatcher.ExclusionLabels, statsMatcher.ExclusionKeys)
+	if err := metrics.SetStatsMatcherConfig(config.StatsMatcher); err != nil {
+		log.StartLogger.Errorf("[mosn] [init metrics] set stats matcher failed: %v, stats matcher is turned off", err)
+	}
 	// create sinks
+	flush := false
 	for _, cfg := range config.SinkConfigs {
-		_, err := sink.CreateMetricsSink(cfg.Type, cfg.Config)
+		var err error
+		if sink.IsFlushSink(cfg.Type) {
+			_, err = sink.CreateFlushSink(cfg.Type, cfg.Config)
+			flush = true
+		} else {
+			_, err = sink.CreateMetricsSink(cfg.Type, cfg.Config)
+		}
 		// abort
 		if err != nil {
 			log.StartLogger.Errorf("[mosn] [init metrics] %s. %v metrics sink is turned off", err, cfg.Type)
@@ -322,6 +376,10 @@ func initializeMetrics(config config.MetricsConfig) {
 		}
 		log.StartLogger.Infof("[mosn] [init metrics] create metrics sink: %v", cfg.Type)
 	}
+	// the metrics are pushed to the flush sinks periodically
+	if flush {
+		metrics.StartFlush(config.FlushInterval.Duration)
+	}
 }
 
 func initializePidFile(pid string) {
@@ -341,3 +399,38 @@ func (cmf *clusterManagerFilter) OnCreated(cccb types.ClusterConfigFactoryCb, ch
 	cmf.cccb = cccb
 	cmf.chcb = chcb
 }
+
+func onClusterConfigUpdate(typ config.ClusterConfigUpdateType, c v2.Cluster) error {
+	adapter := cluster.GetClusterMngAdapterInstance()
+	switch typ {
+	case config.ClusterUpdateHealthCheck:
+		return adapter.TriggerClusterHealthCheckUpdate(c.Name, c.HealthCheck)
+	case config.ClusterUpdateCircuitBreakers:
+		return adapter.TriggerClusterCircuitBreakersUpdate(c.Name, c.CirBreThresholds)
+	case config.ClusterUpdateHosts:
+		return adapter.TriggerClusterHostUpdate(c.Name, c.Hosts)
+	case config.ClusterUpdateAll:
+		return adapter.TriggerClusterAndHostsAddOrUpdate(c, c.Hosts)
+	}
+	return nil
+}
+
+func onRouterConfigUpdate(routerConfig *v2.RouterConfiguration) error {
+	return router.GetRoutersMangerInstance().AddOrUpdateRouters(routerConfig)
+}
+
+func onListenerLimitsUpdate(serverName string, ln v2.Listener) error {
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		return fmt.Errorf("no server is running, listener %s is not found", ln.Name)
+	}
+	return adapter.UpdateListenerLimits(serverName, ln.Name, ln.MaxConnections, ln.MaxConcurrentStreams)
+}
+
+func onListenerTLSUpdate(serverName string, ln v2.Listener) error {
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		return fmt.Errorf("no server is running, listener %s is not found", ln.Name)
+	}
+	return adapter.UpdateTLSContext(ln.Name, ln.FilterChains[0].TLSContexts)
+}
@@ -0,0 +1,187 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/mtls/crypto/tls"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// fileWatchDebounce is how long the changed files should keep unchanged before reloading,
+// so the files that are being written are not loaded
+var fileWatchDebounce = 2 * time.Second
+
+// fileProvider is an implementation of types.Provider
+// fileProvider stored a tls context that makes by the certificate files,
+// the files are checked by the certificate monitor, the tls context is remade if the files changed
+type fileProvider struct {
+	value  atomic.Value // stored tlsContext
+	config *v2.TLSConfig
+	// checksum is the checksum of the files that are loaded
+	checksum string
+	// pending is the checksum of the changed files, it is loaded after the debounce time
+	pending     string
+	pendingTime time.Time
+	// key and refs are guarded by the certificate monitor
+	key  string
+	refs int
+	// mutex guards the replacement of the tls context after the provider is released
+	mutex    sync.Mutex
+	released bool
+}
+
+// getOrCreateFileProvider returns the file provider of the config,
+// the listeners with the same config share the provider
+func getOrCreateFileProvider(cfg *v2.TLSConfig) (*fileProvider, error) {
+	key, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := *cfg
+	return certMonitorInstance.getOrCreateFileProvider(string(key), func() (*fileProvider, error) {
+		return newFileProvider(&config)
+	})
+}
+
+func newFileProvider(cfg *v2.TLSConfig) (*fileProvider, error) {
+	checksum, err := fileChecksum(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := newTLSContext(cfg, fileSecretInfo(cfg))
+	if err != nil {
+		return nil, err
+	}
+	p := &fileProvider{
+		config:   cfg,
+		checksum: checksum,
+	}
+	p.value.Store(ctx)
+	return p, nil
+}
+
+// check reloads the tls context if the files changed and keep unchanged for the debounce time.
+// it is called in the certificate monitor only
+func (p *fileProvider) check(now time.Time) {
+	checksum, err := fileChecksum(p.config)
+	if err != nil {
+		// the files are removed or being replaced, wait for the next check
+		log.DefaultLogger.Warnf("[mtls] [file provider] read certificate files failed: %v", err)
+		return
+	}
+	switch {
+	case checksum == p.checksum:
+		p.pending = ""
+	case checksum != p.pending:
+		p.pending = checksum
+		p.pendingTime = now
+	case now.Sub(p.pendingTime) >= fileWatchDebounce:
+		// the broken files are not reloaded again until they are changed
+		p.checksum = checksum
+		p.pending = ""
+		p.reload()
+	}
+}
+
+func (p *fileProvider) reload() {
+	ctx, err := newTLSContext(p.config, fileSecretInfo(p.config))
+	RecordTLSReload(err)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyTLSReload, "[mtls] [file provider] reload certificate %s failed, keep the previous one: %v", p.config.CertChain, err)
+		return
+	}
+	p.mutex.Lock()
+	if p.released {
+		p.mutex.Unlock()
+		ctx.release()
+		return
+	}
+	old := p.value.Load().(*tlsContext)
+	p.value.Store(ctx)
+	p.mutex.Unlock()
+	old.release()
+	log.DefaultLogger.Infof("[mtls] [file provider] reload certificate %s success", p.config.CertChain)
+}
+
+// release untracks the certificates of the provider, it is called when the provider is not used
+func (p *fileProvider) release() {
+	p.mutex.Lock()
+	p.released = true
+	ctx := p.value.Load().(*tlsContext)
+	p.mutex.Unlock()
+	ctx.release()
+}
+
+func (p *fileProvider) GetTLSConfig(client bool) *tls.Config {
+	return p.value.Load().(*tlsContext).GetTLSConfig(client)
+}
+
+func (p *fileProvider) MatchedServerName(sn string) bool {
+	return p.value.Load().(*tlsContext).MatchedServerName(sn)
+}
+
+func (p *fileProvider) MatchedALPN(protos []string) bool {
+	return p.value.Load().(*tlsContext).MatchedALPN(protos)
+}
+
+func (p *fileProvider) Ready() bool {
+	return true
+}
+
+func (p *fileProvider) Empty() bool {
+	return p.value.Load().(*tlsContext).server == nil
+}
+
+func fileSecretInfo(cfg *v2.TLSConfig) *secretInfo {
+	return &secretInfo{
+		Certificate: cfg.CertChain,
+		PrivateKey:  cfg.PrivateKey,
+		Validation:  cfg.CACert,
+	}
+}
+
+// fileChecksum returns the checksum of the certificate, the private key and the ca files,
+// the pem strings are not files, they are included as is
+func fileChecksum(cfg *v2.TLSConfig) (string, error) {
+	h := sha256.New()
+	for _, index := range []string{cfg.CertChain, cfg.PrivateKey, cfg.CACert} {
+		content := []byte(index)
+		if index != "" && !strings.Contains(index, "-----BEGIN") {
+			b, err := ioutil.ReadFile(index)
+			if err != nil {
+				return "", err
+			}
+			content = b
+		}
+		h.Write(content)
+		// separates the contents
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
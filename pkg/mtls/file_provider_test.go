@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+)
+
+func writeSecretFiles(t *testing.T, dir string, secret *secretInfo) *v2.TLSConfig {
+	cfg := &v2.TLSConfig{
+		Status:     true,
+		CACert:     filepath.Join(dir, "ca.pem"),
+		CertChain:  filepath.Join(dir, "cert.pem"),
+		PrivateKey: filepath.Join(dir, "key.pem"),
+		WatchFiles: true,
+	}
+	for file, content := range map[string]string{
+		cfg.CACert:     secret.Validation,
+		cfg.CertChain:  secret.Certificate,
+		cfg.PrivateKey: secret.PrivateKey,
+	} {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secret, err := (&certInfo{"FileCert1", "P256", "www.example.com"}).CreateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := writeSecretFiles(t, dir, secret)
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("create file provider failed: %v", err)
+	}
+	if _, ok := provider.(*fileProvider); !ok || provider.Empty() || !provider.Ready() {
+		t.Fatalf("expected a ready file provider, but got %T", provider)
+	}
+	// the providers with the same config are shared
+	if p, err := NewProvider(cfg); err != nil || p != provider {
+		t.Error("expected the file provider shared")
+	}
+	if !provider.MatchedServerName("www.example.com") {
+		t.Error("expected the certificate files loaded")
+	}
+	if days := metrics.NewTLSCertificateStats("FileCert1").Gauge(metrics.TLSCertificateExpiryDays).Value(); days < 365 {
+		t.Errorf("unexpected days until the certificate expires: %d", days)
+	}
+	// the files not exists
+	if _, err := NewProvider(&v2.TLSConfig{Status: true, CertChain: "not_exists", PrivateKey: "not_exists", WatchFiles: true}); err == nil {
+		t.Error("expected the file provider created failed")
+	}
+}
+
+func TestFileProviderReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_provider_reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secret, err := (&certInfo{"ReloadCert1", "P256", "www.example.com"}).CreateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := writeSecretFiles(t, dir, secret)
+	// the provider is not checked by the certificate monitor
+	p, err := newFileProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := metrics.NewTLSStats()
+	success := stats.Counter(metrics.TLSReloadSuccess).Count()
+	failed := stats.Counter(metrics.TLSReloadFailed).Count()
+
+	now := time.Now()
+	p.check(now)
+	if stats.Counter(metrics.TLSReloadSuccess).Count() != success {
+		t.Fatal("the files are not changed, expected no reload")
+	}
+	// update the files
+	secret, err = (&certInfo{"ReloadCert2", "P256", "www.foo.com"}).CreateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSecretFiles(t, dir, secret)
+	p.check(now)
+	if p.MatchedServerName("www.foo.com") {
+		t.Fatal("expected the changed files are not reloaded before the debounce time")
+	}
+	p.check(now.Add(fileWatchDebounce))
+	if !p.MatchedServerName("www.foo.com") || p.MatchedServerName("www.example.com") {
+		t.Fatal("expected the changed files reloaded")
+	}
+	if count := stats.Counter(metrics.TLSReloadSuccess).Count() - success; count != 1 {
+		t.Errorf("expected 1 reload success, but got %d", count)
+	}
+	if days := metrics.NewTLSCertificateStats("ReloadCert2").Gauge(metrics.TLSCertificateExpiryDays).Value(); days < 365 {
+		t.Errorf("unexpected days until the reloaded certificate expires: %d", days)
+	}
+
+	// the broken files keep the previous certificate
+	if err := ioutil.WriteFile(cfg.CertChain, []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	p.check(now)
+	p.check(now.Add(fileWatchDebounce))
+	if !p.MatchedServerName("www.foo.com") {
+		t.Error("expected the previous certificate kept")
+	}
+	if count := stats.Counter(metrics.TLSReloadFailed).Count() - failed; count != 1 {
+		t.Errorf("expected 1 reload failure, but got %d", count)
+	}
+	// the broken files are not reloaded again
+	p.check(now.Add(2 * fileWatchDebounce))
+	if count := stats.Counter(metrics.TLSReloadFailed).Count() - failed; count != 1 {
+		t.Errorf("expected the broken files not reloaded again, but got %d failures", count)
+	}
+}
+
+func TestFileProviderRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_provider_release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secret, err := (&certInfo{"ReleaseCert1", "P256", "www.example.com"}).CreateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := writeSecretFiles(t, dir, secret)
+	listener := &v2.Listener{}
+	listener.FilterChains = []v2.FilterChain{{TLSContexts: []v2.TLSConfig{*cfg}}}
+	// the managers of two listeners share the provider
+	mng1, err := NewTLSServerContextManager(listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mng2, err := NewTLSServerContextManager(listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := mng1.(*serverContextManager).providers[0].(*fileProvider)
+	watched := func() bool {
+		certMonitorInstance.mutex.Lock()
+		defer certMonitorInstance.mutex.Unlock()
+		return certMonitorInstance.providers[p.key] == p
+	}
+	reported := func(name string) bool {
+		for _, m := range metrics.GetAll() {
+			if m.Type() == metrics.TLSType && m.Labels()["certificate"] == name {
+				return true
+			}
+		}
+		return false
+	}
+	if !watched() || !reported("ReleaseCert1") {
+		t.Fatal("expected the certificate files watched and the certificate reported")
+	}
+
+	ReleaseTLSContextManager(mng1)
+	if !watched() || !reported("ReleaseCert1") {
+		t.Fatal("expected the provider used by the other manager kept")
+	}
+	ReleaseTLSContextManager(mng2)
+	if watched() || reported("ReleaseCert1") {
+		t.Fatal("expected the released provider not watched and the certificate not reported")
+	}
+	// the reloaded certificate of the released provider is not reported
+	secret, err = (&certInfo{"ReleaseCert2", "P256", "www.foo.com"}).CreateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSecretFiles(t, dir, secret)
+	p.reload()
+	if reported("ReleaseCert2") {
+		t.Fatal("expected the certificate reloaded after released not reported")
+	}
+}
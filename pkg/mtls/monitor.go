@@ -0,0 +1,191 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"math"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// certMonitorInterval is the interval of checking the certificate files and refreshing the certificate metrics
+var certMonitorInterval = 5 * time.Second
+
+var certMonitorInstance = &certMonitor{
+	providers:    make(map[string]*fileProvider),
+	certificates: make(map[string]*certificateExpiry),
+}
+
+// certMonitor checks the certificate files of the file providers,
+// and refreshes the days until the certificates expire
+type certMonitor struct {
+	mutex     sync.Mutex
+	startOnce sync.Once
+	providers map[string]*fileProvider
+	// certificates stored the expiry time of the certificates by name
+	certificates map[string]*certificateExpiry
+}
+
+// certificateExpiry is the expiry time of a certificate,
+// refs is the count of the tls contexts that use the certificate
+type certificateExpiry struct {
+	notAfter time.Time
+	refs     int
+}
+
+func (m *certMonitor) start() {
+	m.startOnce.Do(func() {
+		utils.GoWithRecover(m.run, nil)
+	})
+}
+
+func (m *certMonitor) run() {
+	ticker := time.NewTicker(certMonitorInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.check(now)
+	}
+}
+
+func (m *certMonitor) getOrCreateFileProvider(key string, create func() (*fileProvider, error)) (*fileProvider, error) {
+	m.mutex.Lock()
+	p, ok := m.providers[key]
+	if ok {
+		p.refs++
+	}
+	m.mutex.Unlock()
+	if ok {
+		return p, nil
+	}
+	// the provider is created without the lock, it tracks the certificates
+	p, err := create()
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	exists, ok := m.providers[key]
+	if ok {
+		exists.refs++
+	} else {
+		p.key = key
+		p.refs = 1
+		m.providers[key] = p
+	}
+	m.mutex.Unlock()
+	if ok {
+		// the provider created by others is used, the certificates tracked by this one are untracked
+		p.release()
+		return exists, nil
+	}
+	log.DefaultLogger.Infof("[mtls] [file provider] watch certificate files %s", p.config.CertChain)
+	m.start()
+	return p, nil
+}
+
+// releaseFileProvider releases a reference of the file provider,
+// the provider is not watched any more if it is not referenced
+func (m *certMonitor) releaseFileProvider(p *fileProvider) {
+	m.mutex.Lock()
+	p.refs--
+	removed := p.refs <= 0 && m.providers[p.key] == p
+	if removed {
+		delete(m.providers, p.key)
+	}
+	m.mutex.Unlock()
+	if removed {
+		p.release()
+		log.DefaultLogger.Infof("[mtls] [file provider] stop watching certificate files %s", p.config.CertChain)
+	}
+}
+
+// track records the expiry time of the certificate, the latest loaded one takes effect.
+// it returns the name of the certificate, which should be untracked if the certificate is not used
+func (m *certMonitor) track(cert *x509.Certificate) string {
+	name := cert.Subject.CommonName
+	if name == "" {
+		name = hex.EncodeToString(cert.SerialNumber.Bytes())
+	}
+	m.mutex.Lock()
+	expiry, ok := m.certificates[name]
+	if !ok {
+		expiry = &certificateExpiry{}
+		m.certificates[name] = expiry
+	}
+	expiry.notAfter = cert.NotAfter
+	expiry.refs++
+	// the gauge is updated in the lock, so the gauge of the untracked certificate is not created again
+	setExpiryDays(name, cert.NotAfter, time.Now())
+	m.mutex.Unlock()
+	m.start()
+	return name
+}
+
+// untrack releases a reference of the certificate, the stats of the certificate are deleted
+// if the certificate is not used
+func (m *certMonitor) untrack(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	expiry, ok := m.certificates[name]
+	if !ok {
+		return
+	}
+	expiry.refs--
+	if expiry.refs <= 0 {
+		delete(m.certificates, name)
+		metrics.DeleteTLSCertificateStats(name)
+	}
+}
+
+func (m *certMonitor) check(now time.Time) {
+	m.mutex.Lock()
+	providers := make([]*fileProvider, 0, len(m.providers))
+	for _, p := range m.providers {
+		providers = append(providers, p)
+	}
+	m.mutex.Unlock()
+	// the reloaded certificates are tracked
+	for _, p := range providers {
+		p.check(now)
+	}
+	m.mutex.Lock()
+	for name, expiry := range m.certificates {
+		setExpiryDays(name, expiry.notAfter, now)
+	}
+	m.mutex.Unlock()
+}
+
+func setExpiryDays(name string, notAfter, now time.Time) {
+	days := math.Floor(notAfter.Sub(now).Hours() / 24)
+	metrics.NewTLSCertificateStats(name).Gauge(metrics.TLSCertificateExpiryDays).Update(int64(days))
+}
+
+// RecordTLSReload counts the result of reloading the tls contexts
+func RecordTLSReload(err error) {
+	stats := metrics.NewTLSStats()
+	if err != nil {
+		stats.Counter(metrics.TLSReloadFailed).Inc(1)
+	} else {
+		stats.Counter(metrics.TLSReloadSuccess).Inc(1)
+	}
+}
@@ -142,11 +142,17 @@ func (p *sdsProvider) setCertificate(name string, secret *types.SdsSecret) {
 
 func (p *sdsProvider) update() {
 	ctx, err := newTLSContext(p.config, p.info)
+	RecordTLSReload(err)
 	if err != nil {
 		log.DefaultLogger.Errorf("[mtls] [sds] update tls context failed: %v", err)
 		return
 	}
+	old, _ := p.value.Load().(*tlsContext)
 	p.value.Store(ctx)
+	// the certificates of the replaced tls context are not reported any more
+	if old != nil {
+		old.release()
+	}
 	log.DefaultLogger.Infof("[mtls] [sds] update tls context success")
 	// notify certificates updates
 	for _, cb := range sdsCallbacks {
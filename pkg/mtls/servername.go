@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+// ServerNameMatcher selects a filter chain of the listener by the tls server name
+type ServerNameMatcher struct {
+	// names stored the index of the filter chain by the server name
+	names map[string]int
+	// defaultIndex is the filter chain for the connections without a matched server name
+	defaultIndex int
+}
+
+// NewServerNameMatcher returns a ServerNameMatcher of the filter chains,
+// it returns nil if no filter chain is matched by the server names.
+// The default filter chain is the first one without server names, or the first one if all of them have
+func NewServerNameMatcher(chains []v2.FilterChain) *ServerNameMatcher {
+	m := &ServerNameMatcher{
+		names:        make(map[string]int),
+		defaultIndex: -1,
+	}
+	for i, fc := range chains {
+		if fc.MatchConfig == nil || len(fc.MatchConfig.ServerNames) == 0 {
+			if m.defaultIndex == -1 {
+				m.defaultIndex = i
+			}
+			continue
+		}
+		for _, name := range fc.MatchConfig.ServerNames {
+			name = normalizeServerName(name)
+			// the former filter chain takes precedence
+			if _, ok := m.names[name]; !ok {
+				m.names[name] = i
+			}
+		}
+	}
+	if len(m.names) == 0 {
+		return nil
+	}
+	if m.defaultIndex == -1 {
+		m.defaultIndex = 0
+	}
+	return m
+}
+
+// Match returns the index of the filter chain for the server name,
+// the server name is matched exactly first, then the wildcard names
+// e.g. www.example.com will be first matched against www.example.com, then *.example.com, then *.com
+func (m *ServerNameMatcher) Match(serverName string) int {
+	name := normalizeServerName(serverName)
+	if name == "" {
+		return m.defaultIndex
+	}
+	if i, ok := m.names[name]; ok {
+		return i
+	}
+	labels := strings.Split(name, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		labels[i] = "*"
+		if index, ok := m.names[strings.Join(labels[i:], ".")]; ok {
+			return index
+		}
+	}
+	return m.defaultIndex
+}
+
+func normalizeServerName(name string) string {
+	return strings.TrimRight(strings.ToLower(name), ".")
+}
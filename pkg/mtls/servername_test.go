@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/mtls/crypto/tls"
+)
+
+func filterChainWithServerNames(name string, serverNames ...string) v2.FilterChain {
+	fc := v2.FilterChain{
+		FilterChainConfig: v2.FilterChainConfig{
+			Name: name,
+		},
+	}
+	if len(serverNames) > 0 {
+		fc.MatchConfig = &v2.FilterChainMatchConfig{
+			ServerNames: serverNames,
+		}
+	}
+	return fc
+}
+
+func TestServerNameMatcher(t *testing.T) {
+	if m := NewServerNameMatcher([]v2.FilterChain{filterChainWithServerNames("a"), filterChainWithServerNames("b")}); m != nil {
+		t.Fatal("expected no matcher without server names")
+	}
+	m := NewServerNameMatcher([]v2.FilterChain{
+		filterChainWithServerNames("exact", "www.example.com", "Mosn.IO."),
+		filterChainWithServerNames("wildcard", "*.example.com", "*.com"),
+		filterChainWithServerNames("default"),
+		filterChainWithServerNames("duplicate", "www.example.com"),
+	})
+	for _, tc := range []struct {
+		serverName string
+		index      int
+	}{
+		{"www.example.com", 0},
+		{"WWW.example.com.", 0},
+		{"mosn.io", 0},
+		{"test.example.com", 1},
+		{"a.b.example.com", 1},
+		{"www.foo.com", 1},
+		{"example.org", 2},
+		{"", 2},
+	} {
+		if index := m.Match(tc.serverName); index != tc.index {
+			t.Errorf("server name %s expected filter chain %d, but got %d", tc.serverName, tc.index, index)
+		}
+	}
+	// no filter chain without server names, the first one is the default
+	m = NewServerNameMatcher([]v2.FilterChain{
+		filterChainWithServerNames("a", "a.example.com"),
+		filterChainWithServerNames("b", "b.example.com"),
+	})
+	if index := m.Match("c.example.com"); index != 0 {
+		t.Errorf("expected the first filter chain is the default, but got %d", index)
+	}
+}
+
+func TestServerContextManagerSelectFilterChain(t *testing.T) {
+	newChain := func(info *certInfo, serverNames ...string) v2.FilterChain {
+		cfg, err := info.CreateCertConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc := filterChainWithServerNames(info.CommonName, serverNames...)
+		fc.TLSContexts = []v2.TLSConfig{*cfg}
+		return fc
+	}
+	lc := &v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			FilterChains: []v2.FilterChain{
+				newChain(&certInfo{"Cert1", "P256", "www.example.com"}),
+				// the certificate is selected by the filter chain, even if it does not match the server name
+				newChain(&certInfo{"Cert2", "P256", "www.test.com"}, "*.example.com"),
+			},
+		},
+	}
+	mng, err := NewTLSServerContextManager(lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		serverName string
+		cert       string
+	}{
+		{"www.example.com", "Cert2"},
+		{"www.foo.com", "Cert1"},
+		{"", "Cert1"},
+	} {
+		cfg, err := mng.(*serverContextManager).GetConfigForClient(&tls.ClientHelloInfo{ServerName: tc.serverName})
+		if err != nil {
+			t.Fatalf("server name %s get config failed: %v", tc.serverName, err)
+		}
+		cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.Subject.CommonName != tc.cert {
+			t.Errorf("server name %s expected certificate %s, but got %s", tc.serverName, tc.cert, cert.Subject.CommonName)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"strings"
+	"sync"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -46,6 +47,9 @@ type tlsContext struct {
 	matches    map[string]struct{}
 	client     *tls.Config
 	server     *tls.Config
+	// certificates stored the names of the certificates tracked by the certificate monitor
+	certificates []string
+	releaseOnce  sync.Once
 }
 
 func (ctx *tlsContext) buildMatch() {
@@ -93,6 +97,21 @@ func (ctx *tlsContext) setServerConfig(tmpl tls.Config, cfg *v2.TLSConfig, hooks
 	ctx.server = tlsConfig
 	// build matches
 	ctx.buildMatch()
+	// the days until the certificates expire are reported
+	for _, cert := range tlsConfig.Certificates {
+		if x509Cert, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			ctx.certificates = append(ctx.certificates, certMonitorInstance.track(x509Cert))
+		}
+	}
+}
+
+// release untracks the certificates of the tls context, it is called when the tls context is replaced or removed
+func (ctx *tlsContext) release() {
+	ctx.releaseOnce.Do(func() {
+		for _, name := range ctx.certificates {
+			certMonitorInstance.untrack(name)
+		}
+	})
 }
 
 func (ctx *tlsContext) setClientConfig(tmpl tls.Config, cfg *v2.TLSConfig, hooks ConfigHooks) {
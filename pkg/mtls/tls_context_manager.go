@@ -33,32 +33,42 @@ type serverContextManager struct {
 	inspector bool
 	// config is a tls.config with GetConfigForClient
 	config *tls.Config
+	// matcher selects the filter chain by the server name, it is nil if the filter chains have no server names
+	matcher *ServerNameMatcher
+	// chainProviders stored the certificates of each filter chain
+	chainProviders [][]types.TLSProvider
 }
 
 // NewTLSServerContextManager returns a types.TLSContextManager used in TLS Server
 // A Server Manager can contains multiple certificates in provider
 func NewTLSServerContextManager(cfg *v2.Listener) (types.TLSContextManager, error) {
 	mng := &serverContextManager{
-		inspector: cfg.Inspector,
+		inspector:      cfg.Inspector,
+		matcher:        NewServerNameMatcher(cfg.FilterChains),
+		chainProviders: make([][]types.TLSProvider, len(cfg.FilterChains)),
 	}
 	mng.config = &tls.Config{
 		GetConfigForClient: mng.GetConfigForClient,
 	}
-	for _, c := range cfg.FilterChains {
+	for i, c := range cfg.FilterChains {
 		for _, tlsCfg := range c.TLSContexts {
 			provider, err := NewProvider(&tlsCfg)
 			if err != nil {
+				releaseProviders(mng.providers)
 				return nil, err
 			}
 			if provider != nil {
 				// if a server receive a empty provider and do not support fallback, it should be failed
 				if provider.Empty() {
+					releaseProvider(provider)
 					if !tlsCfg.Fallback {
+						releaseProviders(mng.providers)
 						return nil, ErrorNoCertConfigure
 					}
 					log.DefaultLogger.Alertf(types.ErrorKeyTLSFallback, "listener enable tls without certificate, fallback tls")
 				} else {
 					mng.providers = append(mng.providers, provider)
+					mng.chainProviders[i] = append(mng.chainProviders[i], provider)
 				}
 			}
 		}
@@ -67,8 +77,23 @@ func NewTLSServerContextManager(cfg *v2.Listener) (types.TLSContextManager, erro
 }
 
 func (mng *serverContextManager) GetConfigForClient(info *tls.ClientHelloInfo) (*tls.Config, error) {
+	// the certificates of the filter chain matched by the server name are used first
+	if mng.matcher != nil {
+		if config := getConfigForClient(mng.chainProviders[mng.matcher.Match(info.ServerName)], info); config != nil {
+			return config, nil
+		}
+	}
+	if config := getConfigForClient(mng.providers, info); config != nil {
+		return config, nil
+	}
+	return nil, ErrorNoCertConfigure
+}
+
+// getConfigForClient returns the tls config of the provider matched by the client hello,
+// if no provider matched, the default provider is used. it returns nil if no provider is ready
+func getConfigForClient(providers []types.TLSProvider, info *tls.ClientHelloInfo) *tls.Config {
 	var defaultProvider types.TLSProvider
-	for _, provider := range mng.providers {
+	for _, provider := range providers {
 		if !provider.Ready() {
 			continue
 		}
@@ -77,16 +102,16 @@ func (mng *serverContextManager) GetConfigForClient(info *tls.ClientHelloInfo) (
 			defaultProvider = provider
 		}
 		if provider.MatchedServerName(info.ServerName) {
-			return provider.GetTLSConfig(false), nil
+			return provider.GetTLSConfig(false)
 		}
 		if provider.MatchedALPN(info.SupportedProtos) {
-			return provider.GetTLSConfig(false), nil
+			return provider.GetTLSConfig(false)
 		}
 	}
 	if defaultProvider == nil {
-		return nil, ErrorNoCertConfigure
+		return nil
 	}
-	return defaultProvider.GetTLSConfig(false), nil
+	return defaultProvider.GetTLSConfig(false)
 }
 
 func (mng *serverContextManager) Conn(c net.Conn) (net.Conn, error) {
@@ -130,6 +155,35 @@ func (mng *serverContextManager) Enabled() bool {
 	return false
 }
 
+// ReleaseTLSContextManager releases the providers of the tls context manager, it is called when
+// the manager is replaced or removed. the file providers that are not used stop watching the certificate files,
+// and the stats of the certificates that are not used are deleted
+func ReleaseTLSContextManager(mng types.TLSContextManager) {
+	switch m := mng.(type) {
+	case *serverContextManager:
+		releaseProviders(m.providers)
+	case *clientContextManager:
+		releaseProvider(m.provider)
+	}
+}
+
+func releaseProviders(providers []types.TLSProvider) {
+	for _, provider := range providers {
+		releaseProvider(provider)
+	}
+}
+
+// releaseProvider releases the provider created by NewProvider, the sds providers are shared by the name
+// and updated by the sds server, they are not released
+func releaseProvider(provider types.TLSProvider) {
+	switch p := provider.(type) {
+	case *fileProvider:
+		certMonitorInstance.releaseFileProvider(p)
+	case *staticProvider:
+		p.tlsContext.release()
+	}
+}
+
 type clientContextManager struct {
 	// client support only one certificate
 	provider types.TLSProvider
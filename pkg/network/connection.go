@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -110,6 +111,21 @@ type connection struct {
 	writeLock    sync.RWMutex
 	needTransfer bool
 	useWriteLoop bool
+
+	// transferDisabled keeps the connection in the old mosn in the hot upgrade, configured by the listener
+	transferDisabled bool
+
+	// readDisableMux protects the read disable state, the reading is disabled and enabled
+	// by the write buffer watermarks of the other connections concurrently
+	readDisableMux sync.Mutex
+
+	// writeBuffered is the bytes written but not sent yet, accessed atomically. It is counted
+	// only if the high watermark is not 0, and the listeners are notified once it crosses the watermarks
+	writeBuffered      int64
+	writeBufferLow     uint32
+	writeBufferHigh    uint32
+	aboveHighWatermark bool
+	watermarkMux       sync.Mutex
 }
 
 // NewServerConnection new server-side connection, rawc is the raw connection from go/net
@@ -144,13 +160,18 @@ func NewServerConnection(ctx context.Context, rawc net.Conn, stopChan chan struc
 		conn.file = val.(*os.File)
 	}
 
+	if transfer, ok := mosnctx.Get(ctx, types.ContextKeyTransferConnection).(bool); ok && !transfer {
+		conn.transferDisabled = true
+	}
+
 	// transfer old mosn connection
 	if val := mosnctx.Get(ctx, types.ContextKeyAcceptChan); val != nil {
-		if val := mosnctx.Get(ctx, types.ContextKeyAcceptBuffer); val != nil {
-			buf := val.([]byte)
-			conn.readBuffer = buffer.GetIoBuffer(len(buf))
-			conn.readBuffer.Write(buf)
+		var buf []byte
+		if state, ok := mosnctx.Get(ctx, types.ContextKeyTransferState).(*types.TransferState); ok && state != nil {
+			buf = state.Buffer
 		}
+		conn.readBuffer = buffer.GetIoBuffer(len(buf))
+		conn.readBuffer.Write(buf)
 
 		ch := val.(chan types.Connection)
 		ch <- conn
@@ -327,7 +348,7 @@ func (c *connection) startReadLoop() {
 		select {
 		case <-c.stopChan:
 			if transferTime.IsZero() {
-				if c.transferCallbacks != nil && c.transferCallbacks() {
+				if !c.transferDisabled && c.transferCallbacks != nil && c.transferCallbacks() {
 					randTime := time.Duration(rand.Intn(int(TransferTimeout.Nanoseconds())))
 					transferTime = time.Now().Add(TransferTimeout).Add(randTime)
 					log.DefaultLogger.Infof("[network] [read loop] transferTime: Wait %d Second", (TransferTimeout+randTime)/1e9)
@@ -351,7 +372,7 @@ func (c *connection) startReadLoop() {
 			return
 		case <-c.readEnabledChan:
 		default:
-			if c.readEnabled {
+			if c.ReadEnabled() {
 				err := c.doRead()
 				if err != nil {
 					if te, ok := err.(net.Error); ok && te.Timeout() {
@@ -501,11 +522,25 @@ func (c *connection) Write(buffers ...types.IoBuffer) (err error) {
 		return nil
 	}
 
+	// the buffers are counted before they are sent, and they are released once they are sent
+	var buffered int64
+	if c.writeBufferHigh > 0 {
+		for _, buf := range buffers {
+			if buf != nil {
+				buffered += int64(buf.Len())
+			}
+		}
+		c.updateWriteBuffered(buffered)
+	}
+
 	if !UseNetpollMode {
 		if c.useWriteLoop {
 			c.writeBufferChan <- &buffers
 		} else {
 			err = c.writeDirectly(&buffers)
+			if buffered > 0 {
+				c.updateWriteBuffered(-buffered)
+			}
 		}
 	} else {
 		if atomic.LoadUint32(&c.connected) == 1 {
@@ -678,6 +713,9 @@ func (c *connection) appendBuffer(iobuffers *[]types.IoBuffer) {
 
 func (c *connection) doWrite() (int64, error) {
 	bytesSent, err := c.doWriteIo()
+	if bytesSent > 0 && c.writeBufferHigh > 0 {
+		c.updateWriteBuffered(-bytesSent)
+	}
 	if err != nil && atomic.LoadUint32(&c.closed) == 1 {
 		return 0, nil
 	}
@@ -733,6 +771,35 @@ func (c *connection) updateWriteBuffStats(bytesWrite int64, bytesBufSize int64)
 	}
 }
 
+// updateWriteBuffered adds the delta to the bytes buffered, and notifies the listeners once the bytes
+// are above the high watermark or drain to the low watermark. The bytes are written and sent concurrently,
+// so the notifications are serialized to keep the above and below notifications in pairs
+func (c *connection) updateWriteBuffered(delta int64) {
+	atomic.AddInt64(&c.writeBuffered, delta)
+
+	c.watermarkMux.Lock()
+	defer c.watermarkMux.Unlock()
+
+	buffered := atomic.LoadInt64(&c.writeBuffered)
+	var event types.ConnectionEvent
+	if !c.aboveHighWatermark && buffered > int64(c.writeBufferHigh) {
+		c.aboveHighWatermark = true
+		event = types.OnAboveWriteBufferHighWatermark
+	} else if c.aboveHighWatermark && buffered <= int64(c.writeBufferLow) {
+		c.aboveHighWatermark = false
+		event = types.OnBelowWriteBufferLowWatermark
+	} else {
+		return
+	}
+
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[network] [write buffer] connection %d buffered %d bytes, %s", c.id, buffered, event)
+	}
+	for _, cb := range c.connCallbacks {
+		cb.OnEvent(event)
+	}
+}
+
 func (c *connection) writeBufLen() (bufLen int) {
 	for _, buf := range c.writeBuffers {
 		bufLen += len(buf)
@@ -809,6 +876,10 @@ func (c *connection) SetRemoteAddr(address net.Addr) {
 	c.remoteAddr = address
 }
 
+func (c *connection) SetLocalAddr(address net.Addr) {
+	c.localAddr = address
+}
+
 func (c *connection) AddConnectionEventListener(cb types.ConnectionEventListener) {
 	c.connCallbacks = append(c.connCallbacks, cb)
 }
@@ -836,6 +907,9 @@ func (c *connection) SetNoDelay(enable bool) {
 }
 
 func (c *connection) SetReadDisable(disable bool) {
+	c.readDisableMux.Lock()
+	defer c.readDisableMux.Unlock()
+
 	if disable {
 		if !c.readEnabled {
 			c.readDisableCount++
@@ -850,12 +924,19 @@ func (c *connection) SetReadDisable(disable bool) {
 		}
 
 		c.readEnabled = true
-		// only on read disable status, we need to trigger chan to wake read loop up
-		c.readEnabledChan <- true
+		// only on read disable status, we need to trigger chan to wake read loop up,
+		// the pending one is enough if the read loop is not woken up yet
+		select {
+		case c.readEnabledChan <- true:
+		default:
+		}
 	}
 }
 
 func (c *connection) ReadEnabled() bool {
+	c.readDisableMux.Lock()
+	defer c.readDisableMux.Unlock()
+
 	return c.readEnabled
 }
 
@@ -873,6 +954,34 @@ func (c *connection) BufferLimit() uint32 {
 	return c.bufferLimit
 }
 
+func (c *connection) SetWriteBufferWatermarks(low, high uint32) {
+	if low >= high {
+		low = high / 2
+	}
+	c.writeBufferLow = low
+	c.writeBufferHigh = high
+}
+
+func (c *connection) AboveWriteBufferHighWatermark() bool {
+	c.watermarkMux.Lock()
+	defer c.watermarkMux.Unlock()
+
+	return c.aboveHighWatermark
+}
+
+// WriteBufferWatermarks returns the low and high watermarks of the config,
+// the low watermark is half of the high watermark if it is not configured
+func WriteBufferWatermarks(config *v2.WriteBufferWatermarks) (low, high uint32) {
+	if config == nil || config.High == 0 {
+		return 0, 0
+	}
+	low, high = config.Low, config.High
+	if low == 0 || low >= high {
+		low = high / 2
+	}
+	return low, high
+}
+
 func (c *connection) SetLocalAddress(localAddress net.Addr, restored bool) {
 	// TODO
 	c.localAddressRestored = restored
@@ -912,6 +1021,9 @@ type clientConnection struct {
 	connection
 
 	connectTimeout time.Duration
+	tcpOptions     types.TCPOptions
+	// proxyProtocolHeader is written before any other data
+	proxyProtocolHeader []byte
 
 	connectOnce sync.Once
 }
@@ -949,6 +1061,14 @@ func NewClientConnection(sourceAddr net.Addr, connectTimeout time.Duration, tlsM
 	return conn
 }
 
+func (cc *clientConnection) SetTCPOptions(options types.TCPOptions) {
+	cc.tcpOptions = options
+}
+
+func (cc *clientConnection) SetProxyProtocolHeader(header []byte) {
+	cc.proxyProtocolHeader = header
+}
+
 func (cc *clientConnection) Connect() (err error) {
 	cc.connectOnce.Do(func() {
 		var event types.ConnectionEvent
@@ -978,6 +1098,12 @@ func (cc *clientConnection) Connect() (err error) {
 			atomic.StoreUint32(&cc.connected, 1)
 			event = types.Connected
 
+			if tc, ok := cc.rawConnection.(*net.TCPConn); ok {
+				if err := setTCPOptions(tc, cc.tcpOptions); err != nil {
+					log.DefaultLogger.Warnf("[network] [client connection connect] set tcp options failed, remote address = %s, error = %v", cc.remoteAddr, err)
+				}
+			}
+
 			// ensure ioEnabled and UseNetpollMode
 			if UseNetpollMode {
 				// store fd
@@ -989,7 +1115,12 @@ func (cc *clientConnection) Connect() (err error) {
 				}
 			}
 
-			if cc.tlsMng != nil {
+			// the proxy protocol header is sent before the tls handshake
+			if len(cc.proxyProtocolHeader) > 0 {
+				_, err = cc.rawConnection.Write(cc.proxyProtocolHeader)
+			}
+
+			if err == nil && cc.tlsMng != nil {
 				// usually, the client tls manager will never returns an error
 				cc.rawConnection, err = cc.tlsMng.Conn(cc.rawConnection)
 
@@ -18,11 +18,16 @@
 package network
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"testing"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -126,4 +131,93 @@ func TestClientConectionRemoteaddrIsNil(t *testing.T) {
 		t.Errorf("connect should Failed")
 		return
 	}
-}
\ No newline at end of file
+}
+
+type watermarkListener struct {
+	events chan types.ConnectionEvent
+}
+
+func (l *watermarkListener) OnEvent(event types.ConnectionEvent) {
+	if event == types.OnAboveWriteBufferHighWatermark || event == types.OnBelowWriteBufferLowWatermark {
+		l.events <- event
+	}
+}
+
+func TestWriteBufferWatermarks(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	rawc, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := NewServerConnection(context.Background(), rawc, nil)
+	listener := &watermarkListener{events: make(chan types.ConnectionEvent, 16)}
+	conn.AddConnectionEventListener(listener)
+	conn.SetWriteBufferWatermarks(0, 1<<20)
+	conn.Start(nil)
+	defer conn.Close(types.NoFlush, types.LocalClose)
+
+	// the client does not read, the bytes are buffered once the socket buffers are full
+	data := make([]byte, 64<<10)
+	written := 0
+	for len(listener.events) == 0 {
+		if written > 256<<20 {
+			t.Fatalf("expected the write buffer backed up, but %d bytes are written", written)
+		}
+		if err := conn.Write(buffer.NewIoBufferBytes(data)); err != nil {
+			t.Fatal(err)
+		}
+		written += len(data)
+	}
+	if event := <-listener.events; event != types.OnAboveWriteBufferHighWatermark || !conn.AboveWriteBufferHighWatermark() {
+		t.Fatalf("expected above the high watermark, but got %s", event)
+	}
+
+	read := make(chan int64)
+	go func() {
+		n, _ := io.Copy(ioutil.Discard, client)
+		read <- n
+	}()
+	select {
+	case event := <-listener.events:
+		if event != types.OnBelowWriteBufferLowWatermark || conn.AboveWriteBufferHighWatermark() {
+			t.Fatalf("expected below the low watermark, but got %s", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the write buffer drained")
+	}
+
+	conn.Close(types.FlushWrite, types.LocalClose)
+	if n := <-read; n != int64(written) {
+		t.Errorf("expected %d bytes read, but got %d", written, n)
+	}
+	if len(listener.events) != 0 {
+		t.Errorf("expected the watermarks notified in pairs, but got %s", <-listener.events)
+	}
+}
+
+func TestWriteBufferWatermarksConfig(t *testing.T) {
+	cases := []struct {
+		config    *v2.WriteBufferWatermarks
+		low, high uint32
+	}{
+		{nil, 0, 0},
+		{&v2.WriteBufferWatermarks{Low: 100}, 0, 0},
+		{&v2.WriteBufferWatermarks{High: 100}, 50, 100},
+		{&v2.WriteBufferWatermarks{Low: 10, High: 100}, 10, 100},
+		{&v2.WriteBufferWatermarks{Low: 100, High: 100}, 50, 100},
+	}
+	for _, c := range cases {
+		if low, high := WriteBufferWatermarks(c.config); low != c.low || high != c.high {
+			t.Errorf("expected watermarks %d/%d of %+v, but got %d/%d", c.low, c.high, c.config, low, high)
+		}
+	}
+}
@@ -34,7 +34,7 @@ type mockHandler struct {
 	stopChan chan struct{}
 }
 
-func (h *mockHandler) OnAccept(rawc net.Conn, handOffRestoredDestinationConnections bool, oriRemoteAddr net.Addr, c chan types.Connection, buf []byte) {
+func (h *mockHandler) OnAccept(rawc net.Conn, handOffRestoredDestinationConnections bool, oriRemoteAddr net.Addr, c chan types.Connection, state *types.TransferState) {
 	ctx := context.Background()
 	conn := NewServerConnection(ctx, rawc, h.stopChan)
 	conn.SetIdleTimeout(3 * time.Second)
@@ -23,6 +23,7 @@ import (
 	"os"
 	"runtime/debug"
 	"sync"
+	"syscall"
 	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
@@ -128,6 +129,8 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 						// TODO: notify listener callbacks
 						log.StartLogger.Fatalf("[network] [listener start] [listen] %s listen failed, %v", l.name, err)
 					}
+				} else if err := l.setSockopts(); err != nil {
+					log.StartLogger.Fatalf("[network] [listener start] [listen] %s inherited listener set socket options failed, %v", l.name, err)
 				}
 			}
 			l.state = ListenerRunning
@@ -163,6 +166,10 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 }
 
 func (l *listener) Stop() error {
+	// the listener not bound to port has no socket
+	if l.rawl == nil {
+		return nil
+	}
 	return l.rawl.SetDeadline(time.Now())
 }
 
@@ -217,13 +224,74 @@ func (l *listener) listen(lctx context.Context) error {
 	var err error
 
 	var rawl *net.TCPListener
-	if rawl, err = net.ListenTCP("tcp", l.localAddress.(*net.TCPAddr)); err != nil {
+	options := l.config.SocketOptions
+	if options == nil {
+		if rawl, err = net.ListenTCP("tcp", l.localAddress.(*net.TCPAddr)); err != nil {
+			return err
+		}
+		l.rawl = rawl
+		return nil
+	}
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = setListenerBindSockopts(int(fd), options)
+			}); err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	ln, err := lc.Listen(context.Background(), "tcp", l.localAddress.String())
+	if err != nil {
 		return err
 	}
+	l.rawl = ln.(*net.TCPListener)
+	if err := l.setSockopts(); err != nil {
+		l.rawl.Close()
+		return err
+	}
+	return nil
+}
 
-	l.rawl = rawl
+// setSockopts applies the options of the listening socket
+func (l *listener) setSockopts() error {
+	options := l.config.SocketOptions
+	if options == nil {
+		return nil
+	}
+	rawConn, err := l.rawl.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = setListenerSockopts(int(fd), options)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
 
-	return nil
+// CheckInheritedListenerSockopts returns an error if the inherited listener does not have the socket options
+// that must be set before binding, the options of a bound socket can not be changed
+func CheckInheritedListenerSockopts(tl *net.TCPListener, options *v2.ListenerSocketOptions) error {
+	if options == nil {
+		return nil
+	}
+	rawConn, err := tl.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = checkListenerBindSockopts(int(fd), options)
+	}); err != nil {
+		return err
+	}
+	return sockErr
 }
 
 func (l *listener) accept(lctx context.Context) error {
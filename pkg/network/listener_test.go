@@ -30,7 +30,7 @@ import (
 type mockEventListener struct {
 }
 
-func (e *mockEventListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, c chan types.Connection, buf []byte) {
+func (e *mockEventListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, c chan types.Connection, state *types.TransferState) {
 }
 
 func (e *mockEventListener) OnNewConnection(ctx context.Context, conn types.Connection) {}
@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+// setListenerBindSockopts applies the options that must be set before the listener socket is bound
+func setListenerBindSockopts(fd int, options *v2.ListenerSocketOptions) error {
+	if options.ReusePort {
+		if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+			return fmt.Errorf("set reuse port failed: %v", err)
+		}
+	}
+	if options.IPv6Only != nil {
+		v6Only := 0
+		if *options.IPv6Only {
+			v6Only = 1
+		}
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY, v6Only); err != nil {
+			return fmt.Errorf("set ipv6 only failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkListenerBindSockopts returns an error if the options that must be set before binding
+// do not match the bound socket, they can not be changed any more
+func checkListenerBindSockopts(fd int, options *v2.ListenerSocketOptions) error {
+	reusePort, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT)
+	if err != nil {
+		return fmt.Errorf("get reuse port failed: %v", err)
+	}
+	if options.ReusePort != (reusePort != 0) {
+		return fmt.Errorf("reuse port %t does not match", options.ReusePort)
+	}
+	if options.IPv6Only != nil {
+		v6Only, err := unix.GetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY)
+		if err != nil {
+			return fmt.Errorf("get ipv6 only failed: %v", err)
+		}
+		if *options.IPv6Only != (v6Only != 0) {
+			return fmt.Errorf("ipv6 only %t does not match", *options.IPv6Only)
+		}
+	}
+	return nil
+}
+
+// setListenerSockopts applies the options of a listening socket, they are applied to the inherited listener too
+func setListenerSockopts(fd int, options *v2.ListenerSocketOptions) error {
+	if options.TCPFastOpenQueueLength > 0 {
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_FASTOPEN, options.TCPFastOpenQueueLength); err != nil {
+			return fmt.Errorf("set tcp fast open failed: %v", err)
+		}
+	}
+	// listen again on a listening socket changes the backlog
+	if options.Backlog > 0 {
+		if err := unix.Listen(fd, options.Backlog); err != nil {
+			return fmt.Errorf("set backlog failed: %v", err)
+		}
+	}
+	return nil
+}
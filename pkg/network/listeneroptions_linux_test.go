@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func newOptionsListener(addr string, options *v2.ListenerSocketOptions) *listener {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return NewListener(&v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name:          "socket_options_listener",
+			BindToPort:    true,
+			SocketOptions: options,
+		},
+		Addr: tcpAddr,
+	}).(*listener)
+}
+
+func getSockopt(t *testing.T, l *listener, level, opt int) int {
+	rawConn, err := l.rawl.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	var sockErr error
+	rawConn.Control(func(fd uintptr) {
+		v, sockErr = unix.GetsockoptInt(int(fd), level, opt)
+	})
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	return v
+}
+
+func TestListenerSocketOptions(t *testing.T) {
+	options := &v2.ListenerSocketOptions{
+		ReusePort:              true,
+		TCPFastOpenQueueLength: 16,
+		Backlog:                128,
+	}
+	first := newOptionsListener("127.0.0.1:0", options)
+	if err := first.listen(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer first.rawl.Close()
+	if getSockopt(t, first, unix.SOL_SOCKET, unix.SO_REUSEPORT) != 1 {
+		t.Error("expected the reuse port enabled")
+	}
+	if getSockopt(t, first, unix.IPPROTO_TCP, unix.TCP_FASTOPEN) != 16 {
+		t.Error("expected the tcp fast open queue length 16")
+	}
+	// the listeners with the reuse port share the address
+	second := newOptionsListener(first.rawl.Addr().String(), options)
+	if err := second.listen(nil); err != nil {
+		t.Fatalf("expected the address shared by the reuse port, but got %v", err)
+	}
+	second.rawl.Close()
+	third := newOptionsListener(first.rawl.Addr().String(), &v2.ListenerSocketOptions{Backlog: 128})
+	if err := third.listen(nil); err == nil {
+		third.rawl.Close()
+		t.Fatal("expected the address in use without the reuse port")
+	}
+}
+
+func TestListenerIPv6Only(t *testing.T) {
+	v6Only := true
+	l := newOptionsListener("[::]:0", &v2.ListenerSocketOptions{IPv6Only: &v6Only})
+	if err := l.listen(nil); err != nil {
+		t.Skipf("ipv6 is not supported: %v", err)
+	}
+	defer l.rawl.Close()
+	if getSockopt(t, l, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY) != 1 {
+		t.Error("expected the ipv6 only enabled")
+	}
+}
@@ -0,0 +1,48 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"errors"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+var errListenerSockopts = errors.New("the listener socket options are not supported on this platform")
+
+// setListenerBindSockopts returns an error if any option is configured, the options are supported on linux only
+func setListenerBindSockopts(fd int, options *v2.ListenerSocketOptions) error {
+	if options.ReusePort || options.IPv6Only != nil {
+		return errListenerSockopts
+	}
+	return nil
+}
+
+func checkListenerBindSockopts(fd int, options *v2.ListenerSocketOptions) error {
+	return setListenerBindSockopts(fd, options)
+}
+
+func setListenerSockopts(fd int, options *v2.ListenerSocketOptions) error {
+	if options.TCPFastOpenQueueLength > 0 || options.Backlog > 0 {
+		return errListenerSockopts
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ package network
 
 import (
 	"net"
+	"sync"
 	"time"
 
 	"sofastack.io/sofa-mosn/pkg/types"
@@ -29,18 +30,22 @@ type RequestInfo struct {
 	protocol                 types.Protocol
 	startTime                time.Time
 	responseFlag             types.ResponseFlag
-	upstreamHost             types.HostInfo
 	requestReceivedDuration  time.Duration
 	requestFinishedDuration  time.Duration
 	responseReceivedDuration time.Duration
 	bytesSent                uint64
 	bytesReceived            uint64
 	responseCode             int
-	localAddress             net.Addr
 	downstreamLocalAddress   net.Addr
 	downstreamRemoteAddress  net.Addr
 	isHealthCheckRequest     bool
 	routerRule               types.RouteRule
+	requestID                string
+	// upstreamMux protects the upstream host and local address, they are set when the upstream
+	// stream is ready, which may be in the goroutine of the connection pool
+	upstreamMux  sync.RWMutex
+	upstreamHost types.HostInfo
+	localAddress net.Addr
 }
 
 // todo check
@@ -111,6 +116,10 @@ func (r *RequestInfo) Protocol() types.Protocol {
 	return r.protocol
 }
 
+func (r *RequestInfo) SetProtocol(p types.Protocol) {
+	r.protocol = p
+}
+
 func (r *RequestInfo) ResponseCode() int {
 	return r.responseCode
 }
@@ -132,19 +141,27 @@ func (r *RequestInfo) SetResponseFlag(flag types.ResponseFlag) {
 }
 
 func (r *RequestInfo) UpstreamHost() types.HostInfo {
+	r.upstreamMux.RLock()
+	defer r.upstreamMux.RUnlock()
 	return r.upstreamHost
 }
 
 func (r *RequestInfo) OnUpstreamHostSelected(host types.HostInfo) {
+	r.upstreamMux.Lock()
 	r.upstreamHost = host
+	r.upstreamMux.Unlock()
 }
 
 func (r *RequestInfo) UpstreamLocalAddress() net.Addr {
+	r.upstreamMux.RLock()
+	defer r.upstreamMux.RUnlock()
 	return r.localAddress
 }
 
 func (r *RequestInfo) SetUpstreamLocalAddress(addr net.Addr) {
+	r.upstreamMux.Lock()
 	r.localAddress = addr
+	r.upstreamMux.Unlock()
 }
 
 func (r *RequestInfo) IsHealthCheck() bool {
@@ -178,3 +195,11 @@ func (r *RequestInfo) RouteEntry() types.RouteRule {
 func (r *RequestInfo) SetRouteEntry(routerRule types.RouteRule) {
 	r.routerRule = routerRule
 }
+
+func (r *RequestInfo) RequestID() string {
+	return r.requestID
+}
+
+func (r *RequestInfo) SetRequestID(id string) {
+	r.requestID = id
+}
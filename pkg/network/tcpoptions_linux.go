@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// setTCPOptions applies the keepalive and user timeout options to the tcp connection
+func setTCPOptions(conn *net.TCPConn, options types.TCPOptions) error {
+	if options.Keepalive {
+		if err := conn.SetKeepAlive(true); err != nil {
+			return err
+		}
+	} else if options.UserTimeout <= 0 {
+		return nil
+	}
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = setTCPSockopts(int(fd), options)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+func setTCPSockopts(fd int, options types.TCPOptions) error {
+	if options.Keepalive {
+		if options.KeepaliveIdle > 0 {
+			if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, keepaliveSeconds(options.KeepaliveIdle)); err != nil {
+				return err
+			}
+		}
+		if options.KeepaliveInterval > 0 {
+			if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, keepaliveSeconds(options.KeepaliveInterval)); err != nil {
+				return err
+			}
+		}
+		if options.KeepaliveProbes > 0 {
+			if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPCNT, options.KeepaliveProbes); err != nil {
+				return err
+			}
+		}
+	}
+	if options.UserTimeout > 0 {
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, int(options.UserTimeout/time.Millisecond)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepaliveSeconds rounds the duration up to seconds, the unit of the keepalive socket options
+func keepaliveSeconds(d time.Duration) int {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
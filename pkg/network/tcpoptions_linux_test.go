@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type closeEventListener struct {
+	closed chan types.ConnectionEvent
+}
+
+func (el *closeEventListener) OnEvent(event types.ConnectionEvent) {
+	if event.IsClose() {
+		select {
+		case el.closed <- event:
+		default:
+		}
+	}
+}
+
+// newSilentServer returns a listener that accepts connections but never reads them,
+// so the client's data will never be acknowledged by the server application
+func newSilentServer(t *testing.T) (net.Listener, chan net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	conns := make(chan net.Conn, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.(*net.TCPConn).SetReadBuffer(4096)
+			conns <- c
+		}
+	}()
+	return ln, conns
+}
+
+func getTCPSockopt(t *testing.T, conn *net.TCPConn, opt int) int {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatalf("get raw conn failed: %v", err)
+	}
+	var value int
+	var sockErr error
+	rawConn.Control(func(fd uintptr) {
+		value, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, opt)
+	})
+	if sockErr != nil {
+		t.Fatalf("getsockopt %d failed: %v", opt, sockErr)
+	}
+	return value
+}
+
+func TestSetTCPOptions(t *testing.T) {
+	ln, conns := newSilentServer(t)
+	defer ln.Close()
+
+	conn := NewClientConnection(nil, 0, nil, ln.Addr(), nil)
+	conn.SetTCPOptions(types.TCPOptions{
+		Keepalive:         true,
+		KeepaliveIdle:     10 * time.Second,
+		KeepaliveInterval: 1500 * time.Millisecond,
+		KeepaliveProbes:   3,
+		UserTimeout:       5 * time.Second,
+	})
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer conn.Close(types.NoFlush, types.LocalClose)
+	server := <-conns
+	defer server.Close()
+
+	tc := conn.RawConn().(*net.TCPConn)
+	for opt, expected := range map[int]int{
+		unix.TCP_KEEPIDLE:     10,
+		unix.TCP_KEEPINTVL:    2,
+		unix.TCP_KEEPCNT:      3,
+		unix.TCP_USER_TIMEOUT: 5000,
+	} {
+		if v := getTCPSockopt(t, tc, opt); v != expected {
+			t.Errorf("tcp option %d expected %d, but got %d", opt, expected, v)
+		}
+	}
+}
+
+func TestTCPUserTimeoutCloseConnection(t *testing.T) {
+	ln, conns := newSilentServer(t)
+	defer ln.Close()
+
+	userTimeout := time.Second
+	conn := NewClientConnection(nil, 0, nil, ln.Addr(), nil)
+	conn.SetTCPOptions(types.TCPOptions{
+		UserTimeout: userTimeout,
+	})
+	listener := &closeEventListener{
+		closed: make(chan types.ConnectionEvent, 1),
+	}
+	conn.AddConnectionEventListener(listener)
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer conn.Close(types.NoFlush, types.LocalClose)
+	server := <-conns
+	defer server.Close()
+
+	// keep writing until the server's window is full, the unacknowledged data
+	// makes the connection time out
+	begin := time.Now()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		data := make([]byte, 64*1024)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := conn.Write(buffer.NewIoBufferBytes(data)); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-listener.closed:
+		// the window is full after the buffers are filled up, then the persist probes
+		// are sent in the user timeout
+		if cost := time.Since(begin); cost > 5*userTimeout {
+			t.Errorf("connection should be closed in about %v, but cost %v", userTimeout, cost)
+		}
+	case <-time.After(10 * userTimeout):
+		t.Fatal("connection is not closed by the tcp user timeout")
+	}
+}
+
+func TestTCPOptionsDisabled(t *testing.T) {
+	ln, conns := newSilentServer(t)
+	defer ln.Close()
+
+	conn := NewClientConnection(nil, 0, nil, ln.Addr(), nil)
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer conn.Close(types.NoFlush, types.LocalClose)
+	server := <-conns
+	defer server.Close()
+
+	if v := getTCPSockopt(t, conn.RawConn().(*net.TCPConn), unix.TCP_USER_TIMEOUT); v != 0 {
+		t.Errorf("tcp user timeout should not be set, but got %d", v)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"net"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// setTCPOptions applies the keepalive options to the tcp connection,
+// only the keepalive idle is supported on this platform
+func setTCPOptions(conn *net.TCPConn, options types.TCPOptions) error {
+	if !options.Keepalive {
+		return nil
+	}
+	if err := conn.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if options.KeepaliveIdle > 0 {
+		return conn.SetKeepAlivePeriod(options.KeepaliveIdle)
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ package network
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -114,7 +115,7 @@ func transferHandler(c net.Conn, handler types.ConnectionHandler, transferMap *s
 		return
 	}
 	// recv type
-	conn, err := transferRecvType(uc)
+	conn, withState, err := transferRecvType(uc)
 	if err != nil {
 		log.DefaultLogger.Errorf("[network] [transfer] [handler] transferRecvType error :%v", err)
 		return
@@ -126,9 +127,21 @@ func transferHandler(c net.Conn, handler types.ConnectionHandler, transferMap *s
 		dataBuf, tlsBuf, err := transferReadRecvData(uc)
 		if err != nil {
 			log.DefaultLogger.Errorf("[network] [transfer] [handler] transferRecvData error :%v", err)
+			conn.Close()
 			return
 		}
-		connection := transferNewConn(conn, dataBuf, tlsBuf, handler, transferMap)
+		state := &types.TransferState{
+			Buffer: dataBuf,
+		}
+		// the old mosn of the previous versions does not send the state
+		if withState {
+			if err := transferRecvState(uc, state); err != nil {
+				log.DefaultLogger.Errorf("[network] [transfer] [handler] transferRecvState error :%v", err)
+				conn.Close()
+				return
+			}
+		}
+		connection := transferNewConn(conn, state, tlsBuf, handler, transferMap)
 		if connection != nil {
 			transferSendID(uc, connection.id)
 		} else {
@@ -174,8 +187,12 @@ func transferRead(c *connection) (uint64, error) {
 	}
 
 	uc := unixConn.(*net.UnixConn)
+	// the state is only sent if it is present, so the new mosn of the previous versions
+	// can still receive the connections without state
+	state := transferGetState(c)
+	withState := *state != (transferStateInfo{})
 	// send type and TCP FD
-	err = transferSendType(uc, file)
+	err = transferSendType(uc, file, withState)
 	if err != nil {
 		log.DefaultLogger.Errorf("[network] [transfer] [read] transferRead failed: %v", err)
 		return transferErr, err
@@ -186,6 +203,14 @@ func transferRead(c *connection) (uint64, error) {
 		log.DefaultLogger.Errorf("[network] [transfer] [read] transferRead failed: %v", err)
 		return transferErr, err
 	}
+	// send state
+	if withState {
+		err = transferSendState(uc, state)
+		if err != nil {
+			log.DefaultLogger.Errorf("[network] [transfer] [read] transferRead failed: %v", err)
+			return transferErr, err
+		}
+	}
 	// recv ID
 	id := transferRecvID(uc)
 	log.DefaultLogger.Infof("[network] [transfer] [read] TransferRead NewConn Id = %d, oldId = %d, %p, addrass = %s", id, c.id, c, c.RemoteAddr().String())
@@ -209,7 +234,7 @@ func transferWrite(c *connection, id uint64) error {
 
 	log.DefaultLogger.Infof("[network] [transfer] [write] TransferWrite id = %d, dataBuf = %d", id, c.writeBufLen())
 	uc := unixConn.(*net.UnixConn)
-	err = transferSendType(uc, nil)
+	err = transferSendType(uc, nil, false)
 	if err != nil {
 		log.DefaultLogger.Errorf("[network] [transfer] [write] transferWrite failed: %v", err)
 		return err
@@ -323,8 +348,9 @@ func transferSendHead(uc *net.UnixConn, s1 uint32, s2 uint32) error {
  * type (1 bytes)
  *  0 : transfer read and FD
  *  1 : transfer write
+ *  2 : transfer read and FD, the state follows the read data
  **/
-func transferSendType(uc *net.UnixConn, file *os.File) error {
+func transferSendType(uc *net.UnixConn, file *os.File, withState bool) error {
 	buf := make([]byte, 1)
 	// transfer write
 	if file == nil {
@@ -332,13 +358,15 @@ func transferSendType(uc *net.UnixConn, file *os.File) error {
 		return transferSendMsg(uc, buf)
 	}
 	// transfer read, send FD
-	return transferSendFD(uc, file)
+	return transferSendFD(uc, file, withState)
 }
 
-func transferSendFD(uc *net.UnixConn, file *os.File) error {
+func transferSendFD(uc *net.UnixConn, file *os.File, withState bool) error {
 	buf := make([]byte, 1)
-	// transfer read
-	buf[0] = 0
+	// transfer read with state
+	if withState {
+		buf[0] = 2
+	}
 	if file == nil {
 		return errors.New("transferSendFD conn is nil")
 	}
@@ -379,23 +407,104 @@ func transferRecvFD(oob []byte) (net.Conn, error) {
 	return conn, nil
 }
 
-func transferRecvType(uc *net.UnixConn) (net.Conn, error) {
+func transferRecvType(uc *net.UnixConn) (net.Conn, bool, error) {
 	buf := make([]byte, 1)
 	oob := make([]byte, 32)
 	_, oobn, _, _, err := uc.ReadMsgUnix(buf, oob)
 	if err != nil {
-		return nil, fmt.Errorf("ReadMsgUnix error: %v", err)
+		return nil, false, fmt.Errorf("ReadMsgUnix error: %v", err)
 	}
 	// transfer write
 	if buf[0] == 1 {
-		return nil, nil
+		return nil, false, nil
 	}
 	// transfer read, recv FD
 	conn, err := transferRecvFD(oob[0:oobn])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return conn, nil
+	return conn, buf[0] == 2, nil
+}
+
+/**
+ *  transfer state protocol
+ *  header (4 bytes) + (state json)
+ *
+ * 0                       4
+ * +-----+-----+-----+-----+
+ * |     state length      |
+ * +-----+-----+-----+-----+
+ * |      state json       |
+ * +-----+-----+-----+-----+
+ *
+**/
+
+// transferStateInfo is the connection state sent to the new mosn, the addresses are
+// only sent if they are replaced, such as by the PROXY protocol
+type transferStateInfo struct {
+	Protocol   string `json:"protocol,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+}
+
+func transferGetState(c *connection) *transferStateInfo {
+	info := &transferStateInfo{}
+	if c.remoteAddr != nil && c.remoteAddr.String() != c.rawConnection.RemoteAddr().String() {
+		info.RemoteAddr = c.remoteAddr.String()
+	}
+	if c.localAddr != nil && c.localAddr.String() != c.rawConnection.LocalAddr().String() {
+		info.LocalAddr = c.localAddr.String()
+	}
+	if c.filterManager != nil {
+		for _, rf := range c.filterManager.ListReadFilter() {
+			if pf, ok := rf.(types.ProtocolFilter); ok {
+				if protocol := pf.DownstreamProtocol(); protocol != "" {
+					info.Protocol = string(protocol)
+					break
+				}
+			}
+		}
+	}
+	return info
+}
+
+func transferSendState(uc *net.UnixConn, info *transferStateInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshal transfer state failed: %v", err)
+	}
+	head := make([]byte, 4)
+	binary.BigEndian.PutUint32(head, uint32(len(b)))
+	return transferSendMsg(uc, append(head, b...))
+}
+
+func transferRecvState(uc *net.UnixConn, state *types.TransferState) error {
+	head, err := transferRecvMsg(uc, 4)
+	if err != nil {
+		return err
+	}
+	b, err := transferRecvMsg(uc, int(binary.BigEndian.Uint32(head)))
+	if err != nil {
+		return err
+	}
+	info := &transferStateInfo{}
+	if len(b) != 0 {
+		if err := json.Unmarshal(b, info); err != nil {
+			return fmt.Errorf("unmarshal transfer state failed: %v", err)
+		}
+	}
+	state.Protocol = types.Protocol(info.Protocol)
+	if info.RemoteAddr != "" {
+		if state.RemoteAddr, err = net.ResolveTCPAddr("tcp", info.RemoteAddr); err != nil {
+			return fmt.Errorf("invalid remote address %s: %v", info.RemoteAddr, err)
+		}
+	}
+	if info.LocalAddr != "" {
+		if state.LocalAddr, err = net.ResolveTCPAddr("tcp", info.LocalAddr); err != nil {
+			return fmt.Errorf("invalid local address %s: %v", info.LocalAddr, err)
+		}
+	}
+	return nil
 }
 
 func transferReadSendData(uc *net.UnixConn, c *mtls.TLSConn, buf types.IoBuffer, logger log.ErrorLogger) error {
@@ -517,14 +626,15 @@ func transferRecvID(uc *net.UnixConn) uint64 {
 	return uint64(binary.BigEndian.Uint32(b))
 }
 
-func transferNewConn(conn net.Conn, dataBuf, tlsBuf []byte, handler types.ConnectionHandler, transferMap *sync.Map) *connection {
+func transferNewConn(conn net.Conn, state *types.TransferState, tlsBuf []byte, handler types.ConnectionHandler, transferMap *sync.Map) *connection {
 
 	listener := transferFindListen(conn.LocalAddr(), handler)
 	if listener == nil {
 		return nil
 	}
 
-	log.DefaultLogger.Infof("[network] [transfer] [new conn] transferNewConn dataBuf = %d, tlsBuf = %d", len(dataBuf), len(tlsBuf))
+	log.DefaultLogger.Infof("[network] [transfer] [new conn] transferNewConn dataBuf = %d, tlsBuf = %d, protocol = %s",
+		len(state.Buffer), len(tlsBuf), state.Protocol)
 
 	var err error
 	if len(tlsBuf) != 0 {
@@ -538,7 +648,7 @@ func transferNewConn(conn net.Conn, dataBuf, tlsBuf []byte, handler types.Connec
 	ch := make(chan types.Connection, 1)
 	// new connection
 	utils.GoWithRecover(func() {
-		listener.GetListenerCallbacks().OnAccept(conn, listener.UseOriginalDst(), nil, ch, dataBuf)
+		listener.GetListenerCallbacks().OnAccept(conn, listener.UseOriginalDst(), nil, ch, state)
 	}, nil)
 
 	select {
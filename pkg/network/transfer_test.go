@@ -0,0 +1,196 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockProtocolFilter struct {
+	protocol types.Protocol
+}
+
+func (f *mockProtocolFilter) OnData(buffer types.IoBuffer) types.FilterStatus {
+	return types.Continue
+}
+
+func (f *mockProtocolFilter) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+
+func (f *mockProtocolFilter) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {}
+
+func (f *mockProtocolFilter) DownstreamProtocol() types.Protocol {
+	return f.protocol
+}
+
+func unixConnPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: "", Net: "unix"})
+	if err != nil {
+		t.Fatalf("listen unix failed: %v", err)
+	}
+	defer l.Close()
+	client, err := net.DialUnix("unix", nil, l.Addr().(*net.UnixAddr))
+	if err != nil {
+		t.Fatalf("dial unix failed: %v", err)
+	}
+	server, err := l.AcceptUnix()
+	if err != nil {
+		t.Fatalf("accept unix failed: %v", err)
+	}
+	return client, server
+}
+
+func tcpConnPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp failed: %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial tcp failed: %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept tcp failed: %v", err)
+	}
+	return client, server
+}
+
+func TestTransferState(t *testing.T) {
+	client, server := tcpConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	c := NewServerConnection(context.Background(), server, nil).(*connection)
+	// the addresses replaced by the PROXY protocol
+	remoteAddr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 12345}
+	localAddr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 80}
+	c.SetRemoteAddr(remoteAddr)
+	c.SetLocalAddr(localAddr)
+	c.FilterManager().AddReadFilter(&mockProtocolFilter{protocol: "SofaRpc"})
+
+	info := transferGetState(c)
+	if info.Protocol != "SofaRpc" || info.RemoteAddr != remoteAddr.String() || info.LocalAddr != localAddr.String() {
+		t.Fatalf("unexpected transfer state: %+v", info)
+	}
+
+	// the file of the tcp connection and the state are sent to the new mosn
+	oldConn, newConn := unixConnPair(t)
+	defer oldConn.Close()
+	defer newConn.Close()
+	file, _, err := transferGetFile(c)
+	if err != nil {
+		t.Fatalf("get file failed: %v", err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if err := transferSendType(oldConn, file, true); err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- transferSendState(oldConn, info)
+	}()
+	conn, withState, err := transferRecvType(newConn)
+	if err != nil || conn == nil || !withState {
+		t.Fatalf("recv type failed: %v, %v, %v", conn, withState, err)
+	}
+	defer conn.Close()
+	state := &types.TransferState{}
+	if err := transferRecvState(newConn, state); err != nil {
+		t.Fatalf("recv state failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if state.Protocol != "SofaRpc" || state.RemoteAddr.String() != remoteAddr.String() || state.LocalAddr.String() != localAddr.String() {
+		t.Fatalf("unexpected received state: %+v", state)
+	}
+
+	// the received connection is the same tcp connection
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 4)
+	if n, err := conn.Read(b); err != nil || string(b[:n]) != "ping" {
+		t.Fatalf("read from the transferred connection failed: %s, %v", b[:n], err)
+	}
+}
+
+func TestTransferStateNotReplaced(t *testing.T) {
+	client, server := tcpConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	c := NewServerConnection(context.Background(), server, nil).(*connection)
+	if info := transferGetState(c); *info != (transferStateInfo{}) {
+		t.Fatalf("expected an empty transfer state, but got %+v", info)
+	}
+
+	// the connection without state is sent as the previous versions do
+	oldConn, newConn := unixConnPair(t)
+	defer oldConn.Close()
+	defer newConn.Close()
+	file, _, err := transferGetFile(c)
+	if err != nil {
+		t.Fatalf("get file failed: %v", err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- transferSendType(oldConn, file, false)
+	}()
+	conn, withState, err := transferRecvType(newConn)
+	if err != nil || conn == nil || withState {
+		t.Fatalf("recv type failed: %v, %v, %v", conn, withState, err)
+	}
+	conn.Close()
+	if err := <-errCh; err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+}
+
+func TestNewTransferredConnection(t *testing.T) {
+	client, server := tcpConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	ch := make(chan types.Connection, 1)
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyAcceptChan, ch)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyTransferState, &types.TransferState{
+		Buffer: []byte("partial"),
+	})
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyTransferConnection, false)
+	c := NewServerConnection(ctx, server, nil).(*connection)
+	if rc := <-ch; rc != c {
+		t.Fatal("expected the connection sent to the accept channel")
+	}
+	if c.readBuffer.String() != "partial" {
+		t.Fatalf("expected the read buffer restored, but got %s", c.readBuffer.String())
+	}
+	if !c.transferDisabled {
+		t.Fatal("expected the transfer disabled by the listener")
+	}
+}
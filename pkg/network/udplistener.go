@@ -0,0 +1,264 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// maxDatagramSize is the max size of a udp datagram
+const maxDatagramSize = 65535
+
+var errUDPListenerFile = errors.New("the udp listener is not inherited")
+
+// udpListener receives the datagrams from all the downstream addresses on a socket,
+// the datagrams are passed to the listener callbacks implementing types.UDPListenerEventListener
+type udpListener struct {
+	name                    string
+	localAddress            net.Addr
+	bindToPort              bool
+	listenerTag             uint64
+	perConnBufferLimitBytes uint32
+	cb                      types.ListenerEventListener
+	rawl                    *net.UDPConn
+	config                  *v2.Listener
+	mutex                   sync.Mutex
+	state                   ListenerState
+	// reading is the socket read by the running read loop, it is protected by the mutex
+	reading *net.UDPConn
+}
+
+// NewUDPListener creates a udp listener, the udp listeners are not inherited by the hot upgrade,
+// they are bound with the reuse port option, so the new process binds the address before the old one closes
+func NewUDPListener(lc *v2.Listener) types.Listener {
+	return &udpListener{
+		name:                    lc.Name,
+		localAddress:            lc.Addr,
+		bindToPort:              lc.BindToPort,
+		listenerTag:             lc.ListenerTag,
+		perConnBufferLimitBytes: lc.PerConnBufferLimitBytes,
+		config:                  lc,
+	}
+}
+
+func (l *udpListener) Config() *v2.Listener {
+	return l.config
+}
+
+func (l *udpListener) SetConfig(config *v2.Listener) {
+	l.config = config
+}
+
+func (l *udpListener) Name() string {
+	return l.name
+}
+
+func (l *udpListener) Addr() net.Addr {
+	return l.localAddress
+}
+
+func (l *udpListener) Start(lctx context.Context, restart bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.DefaultLogger.Errorf("[network] [udp listener start] panic %v\n%s", r, string(debug.Stack()))
+		}
+	}()
+
+	if !l.bindToPort {
+		return
+	}
+	var rawl *net.UDPConn
+	ignore := func() bool {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		switch l.state {
+		case ListenerRunning:
+			log.DefaultLogger.Debugf("[network] [udp listener start] %s is running", l.name)
+			return true
+		case ListenerStopped:
+			if !restart {
+				return true
+			}
+			log.DefaultLogger.Infof("[network] [udp listener start] %s restart listener ", l.name)
+			// the socket stopped by the deadline is still bound, it is read again
+			if l.rawl != nil {
+				if err := l.rawl.SetReadDeadline(time.Time{}); err != nil {
+					log.DefaultLogger.Errorf("[network] [udp listener start] %s clear the read deadline failed, %v", l.name, err)
+					return true
+				}
+			} else if err := l.listen(); err != nil {
+				log.DefaultLogger.Errorf("[network] [udp listener start] [listen] %s listen failed, %v", l.name, err)
+				return true
+			}
+		default:
+			if err := l.listen(); err != nil {
+				log.StartLogger.Fatalf("[network] [udp listener start] [listen] %s listen failed, %v", l.name, err)
+			}
+		}
+		l.state = ListenerRunning
+		// the read loop not ended by the deadline yet goes on reading
+		if l.reading == l.rawl {
+			return true
+		}
+		rawl = l.rawl
+		l.reading = rawl
+		return false
+	}()
+	if ignore {
+		return
+	}
+	l.readLoop(rawl)
+}
+
+func (l *udpListener) readLoop(rawl *net.UDPConn) {
+	cb, ok := l.cb.(types.UDPListenerEventListener)
+	if !ok {
+		l.endReading(rawl, false)
+		log.DefaultLogger.Errorf("[network] [udp listener] listener %s callbacks do not handle the datagrams", l.name)
+		return
+	}
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := rawl.ReadFrom(buf)
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				// the listener may be restarted before the deadline is noticed
+				if !l.endReading(rawl, true) {
+					continue
+				}
+				log.DefaultLogger.Infof("[network] [udp listener] listener %s stop reading datagrams by deadline", l.name)
+				return
+			}
+			if ope, ok := err.(*net.OpError); ok && !ope.Temporary() {
+				l.endReading(rawl, false)
+				log.DefaultLogger.Infof("[network] [udp listener] listener %s %s closed: %v", l.name, l.Addr(), err)
+				return
+			}
+			log.DefaultLogger.Errorf("[network] [udp listener] listener %s read datagram failed: %v", l.name, err)
+			continue
+		}
+		cb.OnDatagram(rawl, buf[:n], from)
+	}
+}
+
+// endReading marks the read loop of the socket ended, and returns true. A loop stopped by the deadline
+// is not ended if the listener is restarted meanwhile, as the restart does not start another loop
+func (l *udpListener) endReading(rawl *net.UDPConn, byDeadline bool) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.reading != rawl {
+		return true
+	}
+	if byDeadline && l.state == ListenerRunning {
+		return false
+	}
+	l.reading = nil
+	return true
+}
+
+// Stop stops reading the datagrams, the socket is kept, so the listener can be restarted
+func (l *udpListener) Stop() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.rawl == nil {
+		return nil
+	}
+	l.state = ListenerStopped
+	return l.rawl.SetReadDeadline(time.Now())
+}
+
+func (l *udpListener) ListenerTag() uint64 {
+	return l.listenerTag
+}
+
+func (l *udpListener) SetListenerTag(tag uint64) {
+	l.listenerTag = tag
+}
+
+func (l *udpListener) ListenerFile() (*os.File, error) {
+	return nil, errUDPListenerFile
+}
+
+func (l *udpListener) PerConnBufferLimitBytes() uint32 {
+	return l.perConnBufferLimitBytes
+}
+
+func (l *udpListener) SetPerConnBufferLimitBytes(limitBytes uint32) {
+	l.perConnBufferLimitBytes = limitBytes
+}
+
+func (l *udpListener) SetListenerCallbacks(cb types.ListenerEventListener) {
+	l.cb = cb
+}
+
+func (l *udpListener) GetListenerCallbacks() types.ListenerEventListener {
+	return l.cb
+}
+
+// the udp listener never uses the original dst
+func (l *udpListener) SetUseOriginalDst(use bool) {}
+
+func (l *udpListener) UseOriginalDst() bool {
+	return false
+}
+
+func (l *udpListener) Close(lctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.state = ListenerStopped
+	if l.rawl != nil {
+		l.cb.OnClose()
+		rawl := l.rawl
+		// a restart listens again
+		l.rawl = nil
+		return rawl.Close()
+	}
+	return nil
+}
+
+func (l *udpListener) listen() error {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	pc, err := lc.ListenPacket(context.Background(), "udp", l.localAddress.String())
+	if err != nil {
+		return err
+	}
+	l.rawl = pc.(*net.UDPConn)
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+type mockUDPEventListener struct {
+	mockEventListener
+	datagrams chan string
+}
+
+func (e *mockUDPEventListener) OnDatagram(conn net.PacketConn, data []byte, from net.Addr) {
+	e.datagrams <- string(data)
+}
+
+func TestUDPListenerRestart(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().(*net.UDPAddr)
+	pc.Close()
+
+	ln := NewUDPListener(&v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name:       "test_udp_listener",
+			BindToPort: true,
+		},
+		Addr: addr,
+	})
+	cb := &mockUDPEventListener{datagrams: make(chan string, 1)}
+	ln.SetListenerCallbacks(cb)
+	go ln.Start(nil, false)
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the datagrams are sent until the listener reads one
+	check := func(data string) bool {
+		for i := 0; i < 10; i++ {
+			conn.Write([]byte(data))
+			select {
+			case got := <-cb.datagrams:
+				// the datagrams sent before are skipped
+				if got == data {
+					return true
+				}
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		return false
+	}
+	if !check("start") {
+		t.Fatal("listener should read the datagram after started")
+	}
+
+	if err := ln.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	// wait the read loop stopped by the deadline
+	time.Sleep(100 * time.Millisecond)
+	// the stopped listener is not started again without restart
+	ln.Start(nil, false)
+	go ln.Start(nil, true)
+	if !check("restart") {
+		t.Fatal("listener should read the datagram after restarted")
+	}
+	ln.Close(nil)
+}
@@ -0,0 +1,290 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package overload
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// Action is the way the overload manager sheds load, the actions are engaged in order as the pressure grows
+type Action int
+
+// Group of Action
+const (
+	// StopAccepting closes the new connections of the listeners with stop_accept_on_overload
+	StopAccepting Action = iota
+	// RejectStreams answers the new http streams with 503 before they are proxied
+	RejectStreams
+	actionsCount
+)
+
+var actionNames = [actionsCount]string{metrics.OverloadStopAccepting, metrics.OverloadRejectStreams}
+
+var rejectedKeys = [actionsCount]string{metrics.OverloadConnectionsRejected, metrics.OverloadStreamsRejected}
+
+func (a Action) String() string {
+	return actionNames[a]
+}
+
+const (
+	defaultRefreshInterval = time.Second
+	defaultHysteresis      = 0.05
+)
+
+// engaged are the states of the actions, accessed atomically, so the checks in the accept and the stream paths take no lock
+var engaged [actionsCount]uint32
+
+// current stores the running *manager
+var current atomic.Value
+
+var startMutex sync.Mutex
+
+// Engaged returns true if the action is engaged
+func Engaged(action Action) bool {
+	return atomic.LoadUint32(&engaged[action]) == 1
+}
+
+// OnRejected counts a connection or a stream rejected by the action
+func OnRejected(action Action) {
+	if m, ok := current.Load().(*manager); ok && m != nil {
+		m.rejected[action].Inc(1)
+	}
+}
+
+// Status is the state of the overload manager
+type Status struct {
+	Enabled        bool            `json:"enabled"`
+	HeapInuseBytes uint64          `json:"heap_inuse_bytes"`
+	Goroutines     uint64          `json:"goroutines"`
+	Pressure       float64         `json:"pressure"`
+	Actions        map[string]bool `json:"actions"`
+}
+
+// GetStatus returns the state of the overload manager, the usage is the last sample
+func GetStatus() Status {
+	status := Status{
+		Actions: make(map[string]bool, actionsCount),
+	}
+	for i := Action(0); i < actionsCount; i++ {
+		status.Actions[i.String()] = Engaged(i)
+	}
+	if m, ok := current.Load().(*manager); ok && m != nil {
+		m.mutex.RLock()
+		status.Enabled = true
+		status.HeapInuseBytes = m.last.heapInuse
+		status.Goroutines = m.last.goroutines
+		status.Pressure = m.pressure
+		m.mutex.RUnlock()
+	}
+	return status
+}
+
+// Start starts the overload manager with the config, the running one is stopped
+func Start(config *v2.OverloadConfig) error {
+	if err := Validate(config); err != nil {
+		return err
+	}
+	m := newManager(config, sampleRuntime)
+	startMutex.Lock()
+	defer startMutex.Unlock()
+	stop()
+	current.Store(m)
+	utils.GoWithRecover(m.run, nil)
+	log.DefaultLogger.Infof("[overload] overload manager started, max heap bytes: %d, max goroutines: %d", config.MaxHeapBytes, config.MaxGoroutines)
+	return nil
+}
+
+// Stop stops the overload manager, the actions are disengaged
+func Stop() {
+	startMutex.Lock()
+	defer startMutex.Unlock()
+	stop()
+}
+
+func stop() {
+	if m, ok := current.Load().(*manager); ok && m != nil {
+		close(m.stopChan)
+		for _, gauge := range m.actionGauges {
+			gauge.Update(0)
+		}
+		current.Store((*manager)(nil))
+	}
+	for i := range engaged {
+		atomic.StoreUint32(&engaged[i], 0)
+	}
+}
+
+// Validate checks the overload manager config
+func Validate(config *v2.OverloadConfig) error {
+	if config == nil {
+		return errors.New("overload manager config is nil")
+	}
+	if config.MaxHeapBytes == 0 && config.MaxGoroutines == 0 {
+		return errors.New("overload manager has no resource configured")
+	}
+	if config.RefreshInterval != nil && config.RefreshInterval.Duration < 0 {
+		return errors.New("overload manager has negative refresh interval")
+	}
+	for _, threshold := range []float64{config.StopAcceptingThreshold, config.RejectStreamsThreshold} {
+		if threshold < 0 || threshold > 1 {
+			return errors.New("overload manager threshold should be in [0, 1]")
+		}
+	}
+	if config.StopAcceptingThreshold > 0 && config.RejectStreamsThreshold > 0 &&
+		config.RejectStreamsThreshold < config.StopAcceptingThreshold {
+		return errors.New("overload manager should stop accepting before rejecting streams")
+	}
+	if config.Hysteresis < 0 || config.Hysteresis >= 1 {
+		return errors.New("overload manager hysteresis should be in [0, 1)")
+	}
+	return nil
+}
+
+// usage is a sample of the resources
+type usage struct {
+	heapInuse  uint64
+	goroutines uint64
+}
+
+func sampleRuntime() usage {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return usage{
+		heapInuse:  ms.HeapInuse,
+		goroutines: uint64(runtime.NumGoroutine()),
+	}
+}
+
+type manager struct {
+	maxHeapBytes  uint64
+	maxGoroutines uint64
+	thresholds    [actionsCount]float64
+	hysteresis    float64
+	interval      time.Duration
+	sample        func() usage
+	stopChan      chan struct{}
+
+	heapGauge       gometrics.Gauge
+	goroutinesGauge gometrics.Gauge
+	pressureGauge   gometrics.Gauge
+	actionGauges    [actionsCount]gometrics.Gauge
+	rejected        [actionsCount]gometrics.Counter
+
+	mutex    sync.RWMutex
+	last     usage
+	pressure float64
+}
+
+func newManager(config *v2.OverloadConfig, sample func() usage) *manager {
+	s := metrics.NewOverloadStats()
+	m := &manager{
+		maxHeapBytes:    config.MaxHeapBytes,
+		maxGoroutines:   config.MaxGoroutines,
+		thresholds:      [actionsCount]float64{config.StopAcceptingThreshold, config.RejectStreamsThreshold},
+		hysteresis:      config.Hysteresis,
+		interval:        defaultRefreshInterval,
+		sample:          sample,
+		stopChan:        make(chan struct{}),
+		heapGauge:       s.Gauge(metrics.OverloadHeapInuse),
+		goroutinesGauge: s.Gauge(metrics.OverloadGoroutines),
+		pressureGauge:   s.Gauge(metrics.OverloadPressure),
+	}
+	if config.RefreshInterval != nil && config.RefreshInterval.Duration > 0 {
+		m.interval = config.RefreshInterval.Duration
+	}
+	if m.hysteresis == 0 {
+		m.hysteresis = defaultHysteresis
+	}
+	for i := range m.actionGauges {
+		m.actionGauges[i] = s.Gauge(actionNames[i])
+		m.rejected[i] = s.Counter(rejectedKeys[i])
+	}
+	return m
+}
+
+func (m *manager) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	m.refresh()
+	for {
+		select {
+		case <-m.stopChan:
+			return
+		case <-ticker.C:
+			m.refresh()
+		}
+	}
+}
+
+// pressureOf returns the max ratio of the usage to the max of the resources
+func (m *manager) pressureOf(u usage) float64 {
+	var pressure float64
+	if m.maxHeapBytes > 0 {
+		pressure = float64(u.heapInuse) / float64(m.maxHeapBytes)
+	}
+	if m.maxGoroutines > 0 {
+		if p := float64(u.goroutines) / float64(m.maxGoroutines); p > pressure {
+			pressure = p
+		}
+	}
+	return pressure
+}
+
+// refresh samples the usage, and engages or disengages the actions.
+// an engaged action is disengaged when the pressure falls below its threshold minus the hysteresis,
+// so the action does not flap when the pressure is around the threshold
+func (m *manager) refresh() {
+	u := m.sample()
+	pressure := m.pressureOf(u)
+	m.mutex.Lock()
+	m.last = u
+	m.pressure = pressure
+	m.mutex.Unlock()
+
+	m.heapGauge.Update(int64(u.heapInuse))
+	m.goroutinesGauge.Update(int64(u.goroutines))
+	m.pressureGauge.Update(int64(pressure * 100))
+
+	for i, threshold := range m.thresholds {
+		if threshold <= 0 {
+			continue
+		}
+		action := Action(i)
+		on := Engaged(action)
+		switch {
+		case !on && pressure >= threshold:
+			atomic.StoreUint32(&engaged[action], 1)
+			m.actionGauges[action].Update(1)
+			log.DefaultLogger.Warnf("[overload] action %s engaged, pressure: %.2f, heap in use: %d, goroutines: %d", action, pressure, u.heapInuse, u.goroutines)
+		case on && pressure < threshold-m.hysteresis:
+			atomic.StoreUint32(&engaged[action], 0)
+			m.actionGauges[action].Update(0)
+			log.DefaultLogger.Infof("[overload] action %s disengaged, pressure: %.2f", action, pressure)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package overload
+
+import (
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func TestValidate(t *testing.T) {
+	valid := &v2.OverloadConfig{
+		MaxHeapBytes:           1 << 30,
+		StopAcceptingThreshold: 0.9,
+		RejectStreamsThreshold: 0.95,
+	}
+	if err := Validate(valid); err != nil {
+		t.Errorf("valid config is rejected: %v", err)
+	}
+	for i, cfg := range []*v2.OverloadConfig{
+		nil,
+		{StopAcceptingThreshold: 0.9},
+		{MaxGoroutines: 10000, StopAcceptingThreshold: 1.5},
+		{MaxGoroutines: 10000, StopAcceptingThreshold: 0.95, RejectStreamsThreshold: 0.9},
+		{MaxGoroutines: 10000, Hysteresis: 1},
+		{MaxGoroutines: 10000, RefreshInterval: &v2.DurationConfig{Duration: -time.Second}},
+	} {
+		if err := Validate(cfg); err == nil {
+			t.Errorf("#%d invalid config is not rejected", i)
+		}
+	}
+}
+
+func TestRefreshHysteresis(t *testing.T) {
+	defer stop()
+	var u usage
+	m := newManager(&v2.OverloadConfig{
+		MaxHeapBytes:           1000,
+		MaxGoroutines:          100,
+		StopAcceptingThreshold: 0.8,
+		RejectStreamsThreshold: 0.9,
+		Hysteresis:             0.1,
+	}, func() usage {
+		return u
+	})
+	current.Store(m)
+	expect := func(stopAccepting, rejectStreams bool) {
+		t.Helper()
+		m.refresh()
+		if Engaged(StopAccepting) != stopAccepting || Engaged(RejectStreams) != rejectStreams {
+			t.Errorf("pressure %.2f, expected actions %v %v, but got %v %v", m.pressure,
+				stopAccepting, rejectStreams, Engaged(StopAccepting), Engaged(RejectStreams))
+		}
+	}
+	u = usage{heapInuse: 500, goroutines: 10}
+	expect(false, false)
+	// the actions are engaged in order
+	u = usage{heapInuse: 850, goroutines: 10}
+	expect(true, false)
+	// the max pressure of the resources
+	u = usage{heapInuse: 500, goroutines: 95}
+	expect(true, true)
+	// the actions keep engaged until the pressure falls below the threshold minus the hysteresis
+	u = usage{heapInuse: 850, goroutines: 10}
+	expect(true, true)
+	u = usage{heapInuse: 750, goroutines: 10}
+	expect(true, false)
+	u = usage{heapInuse: 650, goroutines: 10}
+	expect(false, false)
+
+	status := GetStatus()
+	if !status.Enabled || status.HeapInuseBytes != 650 || status.Actions[StopAccepting.String()] {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	if err := Start(&v2.OverloadConfig{MaxGoroutines: 1, RejectStreamsThreshold: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	// the running goroutines exceed the max
+	for i := 0; i < 100 && !Engaged(RejectStreams); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !Engaged(RejectStreams) || Engaged(StopAccepting) {
+		t.Error("only the configured action should be engaged")
+	}
+	OnRejected(RejectStreams)
+	Stop()
+	if Engaged(RejectStreams) || GetStatus().Enabled {
+		t.Error("the actions should be disengaged after stopped")
+	}
+	// no manager running
+	OnRejected(RejectStreams)
+}
@@ -66,7 +66,8 @@ type ProtocolConv interface {
 	ConvTrailer(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error)
 }
 
-// RegisterConv register concrete protocol convert function for specified source protocol and destination protocol
+// RegisterConv register concrete protocol convert function for specified source protocol and destination protocol.
+// The convert function returns ErrNotFound for the headers it does not handle, and the 'common' path is tried instead.
 func RegisterConv(src, dst types.Protocol, f ProtocolConv) {
 	if _, subOk := protoConvFactory[src]; !subOk {
 		protoConvFactory[src] = make(map[types.Protocol]ProtocolConv)
@@ -87,7 +88,9 @@ func ConvertHeader(ctx context.Context, src, dst types.Protocol, srcHeader types
 	// 1. try direct path
 	if sub, subOk := protoConvFactory[src]; subOk {
 		if f, ok := sub[dst]; ok {
-			return f.ConvHeader(ctx, srcHeader)
+			if dstHeader, err := f.ConvHeader(ctx, srcHeader); err != ErrNotFound {
+				return dstHeader, err
+			}
 		}
 	}
 
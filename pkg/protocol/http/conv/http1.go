@@ -46,14 +46,14 @@ func (c *common2http) ConvHeader(ctx context.Context, headerMap types.HeaderMap)
 
 		switch direction {
 		case protocol.Request:
-			headerImpl := http.RequestHeader{&fasthttp.RequestHeader{}, nil}
+			headerImpl := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}, Typed: &protocol.TypedHeader{}}
 			// copy headers
 			for k, v := range header {
 				headerImpl.Set(k, v)
 			}
 			return headerImpl, nil
 		case protocol.Response:
-			headerImpl := http.ResponseHeader{&fasthttp.ResponseHeader{}, nil}
+			headerImpl := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}, Typed: &protocol.TypedHeader{}}
 			// copy headers
 			for k, v := range header {
 				headerImpl.Set(k, v)
@@ -80,9 +80,10 @@ func (c *http2common) ConvHeader(ctx context.Context, headerMap types.HeaderMap)
 	case http.RequestHeader:
 		cheader := make(map[string]string, header.Len())
 
-		// copy headers
-		header.VisitAll(func(key, value []byte) {
-			cheader[strings.ToLower(string(key))] = string(value)
+		// copy headers, including the typed internal headers
+		header.Range(func(key, value string) bool {
+			cheader[strings.ToLower(key)] = value
+			return true
 		})
 
 		cheader[protocol.MosnHeaderDirection] = protocol.Request
@@ -91,9 +92,10 @@ func (c *http2common) ConvHeader(ctx context.Context, headerMap types.HeaderMap)
 	case http.ResponseHeader:
 		cheader := make(map[string]string, header.Len())
 
-		// copy headers
-		header.VisitAll(func(key, value []byte) {
-			cheader[strings.ToLower(string(key))] = string(value)
+		// copy headers, including the typed internal headers
+		header.Range(func(key, value string) bool {
+			cheader[strings.ToLower(key)] = value
+			return true
 		})
 
 		cheader[protocol.MosnHeaderDirection] = protocol.Response
@@ -22,10 +22,12 @@ import (
 	"testing"
 
 	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 func TestRequestHeader_Add(t *testing.T) {
-	header := RequestHeader{&fasthttp.RequestHeader{}, nil}
+	header := RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
 	header.Add("test-multiple", "value-one")
 	header.Add("test-multiple", "value-two")
 
@@ -43,7 +45,7 @@ func TestRequestHeader_Add(t *testing.T) {
 }
 
 func TestResponseHeader_Add(t *testing.T) {
-	header := ResponseHeader{&fasthttp.ResponseHeader{}, nil}
+	header := ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
 	header.Add("test-multiple", "value-one")
 	header.Add("test-multiple", "value-two")
 
@@ -59,3 +61,68 @@ func TestResponseHeader_Add(t *testing.T) {
 		t.Errorf("ResponseHeader.String not contains all header values")
 	}
 }
+
+func TestRequestHeader_Typed(t *testing.T) {
+	header := RequestHeader{RequestHeader: &fasthttp.RequestHeader{}, Typed: &protocol.TypedHeader{}}
+	header.Set("service", "test")
+	header.Set(protocol.MosnHeaderPathKey, "/path")
+	header.Set(protocol.MosnHeaderQueryStringKey, "")
+
+	// the internal headers are kept in the typed fields, not in the header map
+	if header.Peek(protocol.MosnHeaderPathKey) != nil {
+		t.Fatal("the typed header should not be set in the header map")
+	}
+	if path, ok := header.Typed.Value(protocol.TypedPath); !ok || path != "/path" {
+		t.Fatalf("unexpected typed path: %s, %v", path, ok)
+	}
+	if qs, ok := header.Get(protocol.MosnHeaderQueryStringKey); !ok || qs != "" {
+		t.Fatalf("the empty typed header should be found, but got %s, %v", qs, ok)
+	}
+	if _, ok := header.Get(protocol.MosnHeaderMethod); ok {
+		t.Fatal("the typed header not set should not be found")
+	}
+
+	// the clone does not share the typed fields
+	clone := header.Clone().(RequestHeader)
+	clone.Set(protocol.MosnHeaderPathKey, "/clone")
+	if path, _ := header.Get(protocol.MosnHeaderPathKey); path != "/path" {
+		t.Fatalf("the clone changes the typed header: %s", path)
+	}
+
+	// range visits both the typed fields and the header map
+	visited := map[string]string{}
+	header.Range(func(key, value string) bool {
+		visited[strings.ToLower(key)] = value
+		return true
+	})
+	if len(visited) != 3 || visited[protocol.MosnHeaderPathKey] != "/path" || visited["service"] != "test" {
+		t.Fatalf("unexpected range result: %v", visited)
+	}
+	expectedSize := uint64(len(protocol.MosnHeaderPathKey) + len("/path") + len(protocol.MosnHeaderQueryStringKey) + len("service") + len("test"))
+	if size := header.ByteSize(); size != expectedSize {
+		t.Fatalf("expected byte size %d, but got %d", expectedSize, size)
+	}
+
+	header.Del(protocol.MosnHeaderPathKey)
+	if _, ok := header.Get(protocol.MosnHeaderPathKey); ok {
+		t.Fatal("the typed header should be deleted")
+	}
+}
+
+func TestResponseHeader_Typed(t *testing.T) {
+	header := ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}, Typed: &protocol.TypedHeader{}}
+	header.Set(types.HeaderStatus, "200")
+	if header.Peek(types.HeaderStatus) != nil {
+		t.Fatal("the typed header should not be set in the header map")
+	}
+	if status, ok := header.Get(types.HeaderStatus); !ok || status != "200" {
+		t.Fatalf("unexpected status: %s, %v", status, ok)
+	}
+
+	// the internal headers are kept in the header map without the typed fields
+	header = ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	header.Set(types.HeaderStatus, "200")
+	if string(header.Peek(types.HeaderStatus)) != "200" {
+		t.Fatal("the header should be set in the header map")
+	}
+}
@@ -18,6 +18,7 @@
 package http
 
 import (
+	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"github.com/valyala/fasthttp"
 )
@@ -100,10 +101,19 @@ type RequestHeader struct {
 	//
 	// fasthttp do has the kv entry, but kv.value is nil, so Peek("key") return nil. But we want "" instead.
 	EmptyValueHeaders map[string]bool
+
+	// Typed overlays the internal headers like protocol.MosnHeaderPathKey, they are kept in the header map if it is nil.
+	// The stream layers read and write the typed fields directly.
+	Typed *protocol.TypedHeader
 }
 
 // Get value of key
 func (h RequestHeader) Get(key string) (string, bool) {
+	if h.Typed != nil {
+		if typedKey, ok := protocol.LookupTypedKey(key); ok {
+			return h.Typed.Value(typedKey)
+		}
+	}
 	result := h.Peek(key)
 	if result != nil || h.EmptyValueHeaders[key] {
 		return string(result), true
@@ -113,6 +123,15 @@ func (h RequestHeader) Get(key string) (string, bool) {
 
 // Set key-value pair in header map, the previous pair will be replaced if exists
 func (h RequestHeader) Set(key string, value string) {
+	if h.Typed != nil {
+		if typedKey, ok := protocol.LookupTypedKey(key); ok {
+			h.Typed.SetValue(typedKey, value)
+			// the header of the same key received from the peer is overridden
+			h.RequestHeader.Del(key)
+			delete(h.EmptyValueHeaders, key)
+			return
+		}
+	}
 	h.RequestHeader.Set(key, value)
 	if value == "" {
 		if h.EmptyValueHeaders == nil {
@@ -131,6 +150,11 @@ func (h RequestHeader) Add(key, value string) {
 
 // Del delete pair of specified key
 func (h RequestHeader) Del(key string) {
+	if h.Typed != nil {
+		if typedKey, ok := protocol.LookupTypedKey(key); ok {
+			h.Typed.DelValue(typedKey)
+		}
+	}
 	h.RequestHeader.Del(key)
 	delete(h.EmptyValueHeaders, key)
 }
@@ -138,9 +162,13 @@ func (h RequestHeader) Del(key string) {
 // Range calls f sequentially for each key and value present in the map.
 // If f returns false, range stops the iteration.
 func (h RequestHeader) Range(f func(key, value string) bool) {
+	if h.Typed != nil && !h.Typed.Range(f) {
+		return
+	}
 	stopped := false
 	h.VisitAll(func(key, value []byte) {
-		if stopped {
+		// the typed fields override the headers of the same keys in the map
+		if stopped || (h.Typed != nil && protocol.IsTypedKey(key)) {
 			return
 		}
 		stopped = !f(string(key), string(value))
@@ -158,10 +186,18 @@ func (h RequestHeader) Clone() types.HeaderMap {
 			copyEmptyMap[k] = v
 		}
 	}
-	return RequestHeader{copy, copyEmptyMap}
+	var copyTyped *protocol.TypedHeader
+	if h.Typed != nil {
+		typed := *h.Typed
+		copyTyped = &typed
+	}
+	return RequestHeader{copy, copyEmptyMap, copyTyped}
 }
 
 func (h RequestHeader) ByteSize() (size uint64) {
+	if h.Typed != nil {
+		size = h.Typed.ByteSize()
+	}
 	h.VisitAll(func(key, value []byte) {
 		size += uint64(len(key) + len(value))
 	})
@@ -181,10 +217,19 @@ type ResponseHeader struct {
 	//
 	// fasthttp do has the kv entry, but kv.value is nil, so Peek("key") return nil. But we want "" instead.
 	EmptyValueHeaders map[string]bool
+
+	// Typed overlays the internal headers like protocol.MosnHeaderPathKey, they are kept in the header map if it is nil.
+	// The stream layers read and write the typed fields directly.
+	Typed *protocol.TypedHeader
 }
 
 // Get value of key
 func (h ResponseHeader) Get(key string) (string, bool) {
+	if h.Typed != nil {
+		if typedKey, ok := protocol.LookupTypedKey(key); ok {
+			return h.Typed.Value(typedKey)
+		}
+	}
 	result := h.Peek(key)
 	if result != nil || h.EmptyValueHeaders[key] {
 		return string(result), true
@@ -194,6 +239,15 @@ func (h ResponseHeader) Get(key string) (string, bool) {
 
 // Set key-value pair in header map, the previous pair will be replaced if exists
 func (h ResponseHeader) Set(key string, value string) {
+	if h.Typed != nil {
+		if typedKey, ok := protocol.LookupTypedKey(key); ok {
+			h.Typed.SetValue(typedKey, value)
+			// the header of the same key received from the peer is overridden
+			h.ResponseHeader.Del(key)
+			delete(h.EmptyValueHeaders, key)
+			return
+		}
+	}
 	h.ResponseHeader.Set(key, value)
 	if value == "" {
 		if h.EmptyValueHeaders == nil {
@@ -212,6 +266,11 @@ func (h ResponseHeader) Add(key, value string) {
 
 // Del delete pair of specified key
 func (h ResponseHeader) Del(key string) {
+	if h.Typed != nil {
+		if typedKey, ok := protocol.LookupTypedKey(key); ok {
+			h.Typed.DelValue(typedKey)
+		}
+	}
 	h.ResponseHeader.Del(key)
 	delete(h.EmptyValueHeaders, key)
 }
@@ -219,9 +278,13 @@ func (h ResponseHeader) Del(key string) {
 // Range calls f sequentially for each key and value present in the map.
 // If f returns false, range stops the iteration.
 func (h ResponseHeader) Range(f func(key, value string) bool) {
+	if h.Typed != nil && !h.Typed.Range(f) {
+		return
+	}
 	stopped := false
 	h.VisitAll(func(key, value []byte) {
-		if stopped {
+		// the typed fields override the headers of the same keys in the map
+		if stopped || (h.Typed != nil && protocol.IsTypedKey(key)) {
 			return
 		}
 		stopped = !f(string(key), string(value))
@@ -239,10 +302,18 @@ func (h ResponseHeader) Clone() types.HeaderMap {
 			copyEmptyMap[k] = v
 		}
 	}
-	return ResponseHeader{copy, copyEmptyMap}
+	var copyTyped *protocol.TypedHeader
+	if h.Typed != nil {
+		typed := *h.Typed
+		copyTyped = &typed
+	}
+	return ResponseHeader{copy, copyEmptyMap, copyTyped}
 }
 
 func (h ResponseHeader) ByteSize() (size uint64) {
+	if h.Typed != nil {
+		size = h.Typed.ByteSize()
+	}
 	h.VisitAll(func(key, value []byte) {
 		size += uint64(len(key) + len(value))
 	})
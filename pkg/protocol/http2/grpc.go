@@ -0,0 +1,166 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// gRPC over http2, see https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+const (
+	GrpcContentType = "application/grpc"
+	GrpcStatus      = "grpc-status"
+	GrpcMessage     = "grpc-message"
+	GrpcTimeout     = "grpc-timeout"
+)
+
+// the grpc-timeout is a positive integer of at most 8 digits followed by the unit
+const grpcTimeoutMaxDigits = 8
+
+var ErrInvalidGrpcTimeout = errors.New("invalid grpc-timeout")
+
+// IsGrpc returns true if the content type is application/grpc or application/grpc+{proto,json...}
+func IsGrpc(header http.Header) bool {
+	ct := header.Get("Content-Type")
+	if !strings.HasPrefix(ct, GrpcContentType) {
+		return false
+	}
+	if len(ct) == len(GrpcContentType) {
+		return true
+	}
+	switch ct[len(GrpcContentType)] {
+	case '+', ';':
+		return true
+	}
+	return false
+}
+
+// MappingFromHttpStatus maps the status of a local reply to the grpc status,
+// the timeout of the proxy is DEADLINE_EXCEEDED, the other failures of the upstream are UNAVAILABLE
+func MappingFromHttpStatus(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case types.PermissionDeniedCode:
+		return codes.PermissionDenied
+	case types.RouterUnavailableCode:
+		return codes.Unimplemented
+	case types.TimeoutExceptionCode:
+		return codes.DeadlineExceeded
+	case types.NoHealthUpstreamCode, types.UpstreamOverFlowCode, http.StatusTooManyRequests:
+		return codes.Unavailable
+	case types.LimitExceededCode:
+		return codes.ResourceExhausted
+	default:
+		return codes.Unknown
+	}
+}
+
+// MappingToHttpStatus maps the grpc status to the http status by the google api conventions,
+// the grpc responses are classified by it
+func MappingToHttpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// client closed request
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GetGrpcStatus returns the grpc-status of a response, it is in the trailers,
+// or in the headers if the response is trailers-only
+func GetGrpcStatus(headers, trailers types.HeaderMap) (codes.Code, bool) {
+	for _, h := range []types.HeaderMap{trailers, headers} {
+		if h == nil {
+			continue
+		}
+		if value, ok := h.Get(GrpcStatus); ok && value != "" {
+			if code, err := strconv.ParseUint(value, 10, 32); err == nil {
+				return codes.Code(code), true
+			}
+		}
+	}
+	return codes.Unknown, false
+}
+
+// ParseGrpcTimeout parses the grpc-timeout header
+func ParseGrpcTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > grpcTimeoutMaxDigits+1 {
+		return 0, ErrInvalidGrpcTimeout
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, ErrInvalidGrpcTimeout
+	}
+	digits := value[:len(value)-1]
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, ErrInvalidGrpcTimeout
+		}
+	}
+	t, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidGrpcTimeout
+	}
+	return time.Duration(t) * unit, nil
+}
@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestIsGrpc(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/grpc", true},
+		{"application/grpc+proto", true},
+		{"application/grpc; charset=utf-8", true},
+		{"application/grpc-web", false},
+		{"application/json", false},
+		{"", false},
+	}
+	for i, tc := range testCases {
+		header := http.Header{}
+		header.Set("Content-Type", tc.contentType)
+		if IsGrpc(header) != tc.expected {
+			t.Errorf("#%d %s expected grpc %v", i, tc.contentType, tc.expected)
+		}
+	}
+}
+
+func TestMappingFromHttpStatus(t *testing.T) {
+	testCases := map[int]codes.Code{
+		http.StatusOK:               codes.OK,
+		types.TimeoutExceptionCode:  codes.DeadlineExceeded,
+		types.NoHealthUpstreamCode:  codes.Unavailable,
+		types.UpstreamOverFlowCode:  codes.Unavailable,
+		types.RouterUnavailableCode: codes.Unimplemented,
+		types.PermissionDeniedCode:  codes.PermissionDenied,
+		types.LimitExceededCode:     codes.ResourceExhausted,
+		http.StatusTeapot:           codes.Unknown,
+	}
+	for status, expected := range testCases {
+		if code := MappingFromHttpStatus(status); code != expected {
+			t.Errorf("status %d expected %v, but got %v", status, expected, code)
+		}
+	}
+}
+
+func TestGetGrpcStatus(t *testing.T) {
+	// trailers-only
+	if code, ok := GetGrpcStatus(protocol.CommonHeader{GrpcStatus: "14"}, nil); !ok || code != codes.Unavailable {
+		t.Errorf("expected unavailable in the headers, but got %v %v", code, ok)
+	}
+	// the trailers are preferred
+	if code, ok := GetGrpcStatus(protocol.CommonHeader{}, protocol.CommonHeader{GrpcStatus: "0"}); !ok || code != codes.OK {
+		t.Errorf("expected ok in the trailers, but got %v %v", code, ok)
+	}
+	if _, ok := GetGrpcStatus(protocol.CommonHeader{}, nil); ok {
+		t.Error("expected no grpc-status")
+	}
+	if _, ok := GetGrpcStatus(protocol.CommonHeader{GrpcStatus: "invalid"}, nil); ok {
+		t.Error("expected invalid grpc-status ignored")
+	}
+}
+
+func TestParseGrpcTimeout(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"1H", time.Hour, true},
+		{"2M", 2 * time.Minute, true},
+		{"3S", 3 * time.Second, true},
+		{"100m", 100 * time.Millisecond, true},
+		{"100u", 100 * time.Microsecond, true},
+		{"100n", 100 * time.Nanosecond, true},
+		{"99999999S", 99999999 * time.Second, true},
+		{"100000000S", 0, false},
+		{"S", 0, false},
+		{"10", 0, false},
+		{"-1S", 0, false},
+		{"1.5S", 0, false},
+		{"", 0, false},
+	}
+	for i, tc := range testCases {
+		timeout, err := ParseGrpcTimeout(tc.value)
+		if (err == nil) != tc.valid || timeout != tc.expected {
+			t.Errorf("#%d %s expected %v %v, but got %v %v", i, tc.value, tc.expected, tc.valid, timeout, err)
+		}
+	}
+}
@@ -106,6 +106,10 @@ func encodeRequest(ctx context.Context, cmd *sofarpc.BoltRequest) (types.IoBuffe
 	binary.BigEndian.PutUint16(b[0:], uint16(cmd.HeaderLen))
 	buf.Write(b[0:2])
 
+	// the content may be replaced by the filters or the protocol conversion
+	if cmd.Content != nil {
+		cmd.ContentLen = cmd.Content.Len()
+	}
 	binary.BigEndian.PutUint32(b[0:], uint32(cmd.ContentLen))
 	buf.Write(b[0:4])
 
@@ -115,7 +119,7 @@ func encodeRequest(ctx context.Context, cmd *sofarpc.BoltRequest) (types.IoBuffe
 
 	if cmd.RequestHeader != nil {
 		l := buf.Len()
-		serialize.Instance.SerializeMap(cmd.RequestHeader, buf)
+		serialize.GetSerialization(cmd.Codec).SerializeMap(cmd.RequestHeader, buf)
 		headerLen = buf.Len() - l
 
 		// reset HeaderLen
@@ -174,6 +178,10 @@ func encodeResponse(ctx context.Context, cmd *sofarpc.BoltResponse) (types.IoBuf
 	binary.BigEndian.PutUint16(b[0:], uint16(cmd.HeaderLen))
 	buf.Write(b[0:2])
 
+	// the content may be replaced by the filters or the protocol conversion
+	if cmd.Content != nil {
+		cmd.ContentLen = cmd.Content.Len()
+	}
 	binary.BigEndian.PutUint32(b[0:], uint32(cmd.ContentLen))
 	buf.Write(b[0:4])
 
@@ -183,7 +191,7 @@ func encodeResponse(ctx context.Context, cmd *sofarpc.BoltResponse) (types.IoBuf
 
 	if cmd.ResponseHeader != nil {
 		l := buf.Len()
-		serialize.Instance.SerializeMap(cmd.ResponseHeader, buf)
+		serialize.GetSerialization(cmd.Codec).SerializeMap(cmd.ResponseHeader, buf)
 		headerLen = buf.Len() - l
 
 		// reset HeaderLen
@@ -22,6 +22,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"time"
 
 	"sofastack.io/sofa-mosn/pkg/buffer"
@@ -40,8 +41,7 @@ var (
 func init() {
 	sofarpc.RegisterProtocol(sofarpc.PROTOCOL_CODE_V2, BoltCodecV2, BoltCodecV2)
 	sofarpc.RegisterResponseBuilder(sofarpc.PROTOCOL_CODE_V2, BoltCodecV2)
-	// the heartbeat processing is same with boltV1
-	sofarpc.RegisterHeartbeatBuilder(sofarpc.PROTOCOL_CODE_V2, BoltCodec)
+	sofarpc.RegisterHeartbeatBuilder(sofarpc.PROTOCOL_CODE_V2, BoltCodecV2)
 }
 
 // ~~ types.Encoder
@@ -103,6 +103,11 @@ func encodeRequestV2(ctx context.Context, cmd *sofarpc.BoltRequestV2) (types.IoB
 	binary.BigEndian.PutUint32(b[0:], uint32(cmd.Timeout))
 	buf.Write(b[0:4])
 
+	// the content may be replaced by the filters or the protocol conversion
+	if cmd.Content != nil {
+		cmd.ContentLen = cmd.Content.Len()
+	}
+
 	binary.BigEndian.PutUint16(b[0:], uint16(cmd.ClassLen))
 	buf.Write(b[0:2])
 
@@ -118,7 +123,7 @@ func encodeRequestV2(ctx context.Context, cmd *sofarpc.BoltRequestV2) (types.IoB
 
 	if cmd.RequestHeader != nil {
 		l := buf.Len()
-		serialize.Instance.SerializeMap(cmd.RequestHeader, buf)
+		serialize.GetSerialization(cmd.Codec).SerializeMap(cmd.RequestHeader, buf)
 		headerLen = buf.Len() - l
 
 		// reset HeaderLen
@@ -128,6 +133,10 @@ func encodeRequestV2(ctx context.Context, cmd *sofarpc.BoltRequestV2) (types.IoB
 		buf.Write(cmd.HeaderMap)
 	}
 
+	if cmd.CrcEnabled() {
+		appendContentAndCrc(buf, cmd.Content)
+	}
+
 	return buf, nil
 }
 
@@ -173,6 +182,11 @@ func encodeResponseV2(ctx context.Context, cmd *sofarpc.BoltResponseV2) (types.I
 	binary.BigEndian.PutUint16(b[0:], uint16(cmd.ResponseStatus))
 	buf.Write(b[0:2])
 
+	// the content may be replaced by the filters or the protocol conversion
+	if cmd.Content != nil {
+		cmd.ContentLen = cmd.Content.Len()
+	}
+
 	binary.BigEndian.PutUint16(b[0:], uint16(cmd.ClassLen))
 	buf.Write(b[0:2])
 
@@ -188,7 +202,7 @@ func encodeResponseV2(ctx context.Context, cmd *sofarpc.BoltResponseV2) (types.I
 
 	if cmd.ResponseHeader != nil {
 		l := buf.Len()
-		serialize.Instance.SerializeMap(cmd.ResponseHeader, buf)
+		serialize.GetSerialization(cmd.Codec).SerializeMap(cmd.ResponseHeader, buf)
 		headerLen = buf.Len() - l
 
 		// reset HeaderLen
@@ -198,9 +212,36 @@ func encodeResponseV2(ctx context.Context, cmd *sofarpc.BoltResponseV2) (types.I
 		buf.Write(cmd.HeaderMap)
 	}
 
+	if cmd.CrcEnabled() {
+		appendContentAndCrc(buf, cmd.Content)
+	}
+
 	return buf, nil
 }
 
+// appendContentAndCrc writes the content and the crc32 of the whole frame
+func appendContentAndCrc(buf types.IoBuffer, content types.IoBuffer) {
+	if content != nil && content.Len() > 0 {
+		buf.Write(content.Bytes())
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[0:], crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(b[0:4])
+}
+
+// checkCrc verifies the crc32 following the frame data[:frameLen]
+func checkCrc(data []byte, frameLen int) error {
+	expected := binary.BigEndian.Uint32(data[frameLen : frameLen+sofarpc.CRC_LEN])
+	if crc32.ChecksumIEEE(data[:frameLen]) != expected {
+		return sofarpc.ErrCrcCheckFailed
+	}
+	return nil
+}
+
+func crcEnabled(ver1, switchCode byte) bool {
+	return ver1 == sofarpc.PROTOCOL_VERSION_2 && switchCode&sofarpc.PROTOCOL_SWITCH_CRC != 0
+}
+
 func (c *boltCodecV2) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
 	readableBytes := data.Len()
 	read := 0
@@ -236,7 +277,12 @@ func (c *boltCodecV2) Decode(ctx context.Context, data types.IoBuffer) (interfac
 				read = sofarpc.REQUEST_HEADER_LEN_V2
 				var class, header, content []byte
 
-				if readableBytes >= read+int(classLen)+int(headerLen)+int(contentLen) {
+				crcLen := 0
+				if crcEnabled(ver1, switchCode) {
+					crcLen = sofarpc.CRC_LEN
+				}
+
+				if readableBytes >= read+int(classLen)+int(headerLen)+int(contentLen)+crcLen {
 					if classLen > 0 {
 						class = bytesData[read : read+int(classLen)]
 						read += int(classLen)
@@ -249,6 +295,12 @@ func (c *boltCodecV2) Decode(ctx context.Context, data types.IoBuffer) (interfac
 						content = bytesData[read : read+int(contentLen)]
 						read += int(contentLen)
 					}
+					if crcLen > 0 {
+						if err := checkCrc(bytesData, read); err != nil {
+							return nil, err
+						}
+						read += crcLen
+					}
 					data.Drain(read)
 				} else {
 					// not enough data
@@ -306,7 +358,12 @@ func (c *boltCodecV2) Decode(ctx context.Context, data types.IoBuffer) (interfac
 				read = sofarpc.RESPONSE_HEADER_LEN_V2
 				var class, header, content []byte
 
-				if readableBytes >= read+int(classLen)+int(headerLen)+int(contentLen) {
+				crcLen := 0
+				if crcEnabled(ver1, switchCode) {
+					crcLen = sofarpc.CRC_LEN
+				}
+
+				if readableBytes >= read+int(classLen)+int(headerLen)+int(contentLen)+crcLen {
 					if classLen > 0 {
 						class = bytesData[read : read+int(classLen)]
 						read += int(classLen)
@@ -319,6 +376,13 @@ func (c *boltCodecV2) Decode(ctx context.Context, data types.IoBuffer) (interfac
 						content = bytesData[read : read+int(contentLen)]
 						read += int(contentLen)
 					}
+					if crcLen > 0 {
+						if err := checkCrc(bytesData, read); err != nil {
+							return nil, err
+						}
+						read += crcLen
+					}
+					data.Drain(read)
 				} else {
 					// not enough data
 					if log.Proxy.GetLogLevel() >= log.DEBUG {
@@ -383,3 +447,36 @@ func (c *boltCodecV2) BuildResponse(respStatus int16) sofarpc.SofaRpcCmd {
 		SwitchCode: 0,
 	}
 }
+
+// ~ HeartbeatBuilder
+func (c *boltCodecV2) Trigger() sofarpc.SofaRpcCmd {
+	return &sofarpc.BoltRequestV2{
+		BoltRequest: sofarpc.BoltRequest{
+			Protocol: sofarpc.PROTOCOL_CODE_V2,
+			CmdType:  sofarpc.REQUEST,
+			CmdCode:  sofarpc.HEARTBEAT,
+			Version:  1,
+			ReqID:    0,                          // this would be overwrite by stream layer
+			Codec:    sofarpc.HESSIAN2_SERIALIZE, //todo: read default codec from config
+			Timeout:  -1,
+		},
+		Version1:   sofarpc.PROTOCOL_VERSION_1,
+		SwitchCode: 0,
+	}
+}
+
+func (c *boltCodecV2) Reply() sofarpc.SofaRpcCmd {
+	return &sofarpc.BoltResponseV2{
+		BoltResponse: sofarpc.BoltResponse{
+			Protocol:       sofarpc.PROTOCOL_CODE_V2,
+			CmdType:        sofarpc.RESPONSE,
+			CmdCode:        sofarpc.HEARTBEAT,
+			Version:        1,
+			ReqID:          0,                          // this would be overwrite by stream layer
+			Codec:          sofarpc.HESSIAN2_SERIALIZE, //todo: read default codec from config
+			ResponseStatus: sofarpc.RESPONSE_STATUS_SUCCESS,
+		},
+		Version1:   sofarpc.PROTOCOL_VERSION_1,
+		SwitchCode: 0,
+	}
+}
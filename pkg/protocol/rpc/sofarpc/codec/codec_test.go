@@ -26,6 +26,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
 	"sofastack.io/sofa-mosn/pkg/protocol/serialize"
+	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 // compare binary put and get
@@ -83,6 +84,43 @@ func TestDecodeAndEncode_BoltV1(t *testing.T) {
 	}
 }
 
+func TestDecodeAndEncode_BoltV1Codec(t *testing.T) {
+	// the unknown codec is passed through opaque
+	for _, codec := range []byte{sofarpc.HESSIAN2_SERIALIZE, sofarpc.PROTOBUF_SERIALIZE, sofarpc.JSON_SERIALIZE, 99} {
+		req := &sofarpc.BoltRequest{
+			Protocol:      sofarpc.PROTOCOL_CODE_V1,
+			CmdType:       sofarpc.REQUEST,
+			CmdCode:       sofarpc.RPC_REQUEST,
+			Version:       1,
+			ReqID:         1,
+			Codec:         codec,
+			Timeout:       -1,
+			RequestHeader: map[string]string{"service": "testSofa", "method": "echo"},
+			Content:       buffer.NewIoBufferString("body"),
+		}
+		buf, err := BoltCodec.Encode(context.Background(), req)
+		if err != nil {
+			t.Fatalf("codec %d encode bolt v1 request failed: %v", codec, err)
+		}
+		// the content is written after the encoded header
+		buf.Write(req.Content.Bytes())
+		v, err := BoltCodec.Decode(context.Background(), buf)
+		if err != nil {
+			t.Fatalf("codec %d decode bolt v1 data failed: %v", codec, err)
+		}
+		req1, ok := v.(*sofarpc.BoltRequest)
+		if !ok {
+			t.Fatalf("codec %d decode bolt v1 request failed", codec)
+		}
+		if req1.Codec != codec || req1.RequestHeader["service"] != "testSofa" || req1.RequestHeader["method"] != "echo" {
+			t.Errorf("codec %d decode headers failed: %v", codec, req1.RequestHeader)
+		}
+		if req1.Content.String() != "body" {
+			t.Errorf("codec %d expected the content passed through, but got %s", codec, req1.Content.String())
+		}
+	}
+}
+
 func TestDecodeAndEncode_BoltV2(t *testing.T) {
 	// just a request data for unit test
 	// may be it is a invalid boltv2 request
@@ -120,6 +158,225 @@ func TestDecodeAndEncode_BoltV2(t *testing.T) {
 	}
 }
 
+func TestDecodeAndEncode_BoltV2Crc(t *testing.T) {
+	req := &sofarpc.BoltRequestV2{
+		BoltRequest: sofarpc.BoltRequest{
+			Protocol:      sofarpc.PROTOCOL_CODE_V2,
+			CmdType:       sofarpc.REQUEST,
+			CmdCode:       sofarpc.RPC_REQUEST,
+			Version:       1,
+			ReqID:         1,
+			Codec:         sofarpc.HESSIAN2_SERIALIZE,
+			Timeout:       -1,
+			RequestClass:  "com.alipay.sofa.rpc.core.request.SofaRequest",
+			RequestHeader: map[string]string{"service": "test"},
+			Content:       buffer.NewIoBufferString("request content"),
+		},
+		Version1:   sofarpc.PROTOCOL_VERSION_2,
+		SwitchCode: sofarpc.PROTOCOL_SWITCH_CRC,
+	}
+	resp := &sofarpc.BoltResponseV2{
+		BoltResponse: sofarpc.BoltResponse{
+			Protocol:       sofarpc.PROTOCOL_CODE_V2,
+			CmdType:        sofarpc.RESPONSE,
+			CmdCode:        sofarpc.RPC_RESPONSE,
+			Version:        1,
+			ReqID:          1,
+			Codec:          sofarpc.HESSIAN2_SERIALIZE,
+			ResponseStatus: sofarpc.RESPONSE_STATUS_SUCCESS,
+			ResponseHeader: map[string]string{"service": "test"},
+			Content:        buffer.NewIoBufferString("response content"),
+		},
+		Version1:   sofarpc.PROTOCOL_VERSION_2,
+		SwitchCode: sofarpc.PROTOCOL_SWITCH_CRC,
+	}
+	for _, cmd := range []sofarpc.SofaRpcCmd{req, resp} {
+		content := cmd.Data().String()
+		buf, err := BoltCodecV2.Encode(context.Background(), cmd)
+		if err != nil {
+			t.Fatal("Encode bolt v2 command failed", err)
+		}
+		// the content and the crc32 are encoded into the frame
+		v, err := BoltCodecV2.Decode(context.Background(), buf)
+		if err != nil {
+			t.Fatal("Decode bolt v2 data failed", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected the frame drained, but %d bytes left", buf.Len())
+		}
+		cmd1, ok := v.(sofarpc.SofaRpcCmd)
+		if !ok || !sofarpc.CrcEnabled(cmd1) {
+			t.Fatalf("decode bolt v2 command failed: %v", v)
+		}
+		if cmd1.Data() == nil || cmd1.Data().String() != content {
+			t.Errorf("decode content is not equal origin content, origin: %s, got: %v", content, cmd1.Data())
+		}
+		if service, _ := cmd1.Get("service"); service != "test" {
+			t.Errorf("decode header is not equal origin header, got: %s", service)
+		}
+	}
+}
+
+func TestDecode_BoltV2CrcMismatch(t *testing.T) {
+	req := &sofarpc.BoltRequestV2{
+		BoltRequest: sofarpc.BoltRequest{
+			Protocol: sofarpc.PROTOCOL_CODE_V2,
+			CmdType:  sofarpc.REQUEST,
+			CmdCode:  sofarpc.RPC_REQUEST,
+			Version:  1,
+			ReqID:    1,
+			Codec:    sofarpc.HESSIAN2_SERIALIZE,
+			Timeout:  -1,
+			Content:  buffer.NewIoBufferString("request content"),
+		},
+		Version1:   sofarpc.PROTOCOL_VERSION_2,
+		SwitchCode: sofarpc.PROTOCOL_SWITCH_CRC,
+	}
+	buf, err := BoltCodecV2.Encode(context.Background(), req)
+	if err != nil {
+		t.Fatal("Encode bolt v2 request failed", err)
+	}
+	// modify the content
+	data := buf.Bytes()
+	data[len(data)-sofarpc.CRC_LEN-1] ^= 0xff
+	if _, err := BoltCodecV2.Decode(context.Background(), buf); err != sofarpc.ErrCrcCheckFailed {
+		t.Errorf("expected crc check failed, but got: %v", err)
+	}
+	// not enough data for the crc32
+	buf, _ = BoltCodecV2.Encode(context.Background(), req)
+	partial := buffer.NewIoBufferBytes(buf.Bytes()[:buf.Len()-1])
+	if v, err := BoltCodecV2.Decode(context.Background(), partial); v != nil || err != nil {
+		t.Errorf("expected waiting for more data, but got: %v, %v", v, err)
+	}
+}
+
+func TestBoltV2Heartbeat(t *testing.T) {
+	hb := sofarpc.NewHeartbeat(sofarpc.PROTOCOL_CODE_V2)
+	if _, ok := hb.(*sofarpc.BoltRequestV2); !ok {
+		t.Fatalf("expected bolt v2 heartbeat, but got: %v", hb)
+	}
+	ack := sofarpc.NewHeartbeatAck(sofarpc.PROTOCOL_CODE_V2)
+	if _, ok := ack.(*sofarpc.BoltResponseV2); !ok {
+		t.Fatalf("expected bolt v2 heartbeat ack, but got: %v", ack)
+	}
+	for _, cmd := range []sofarpc.SofaRpcCmd{hb, ack} {
+		cmd.SetRequestID(10)
+		buf, err := sofarpc.Engine().Encode(context.Background(), cmd)
+		if err != nil {
+			t.Fatal("Encode bolt v2 heartbeat failed", err)
+		}
+		v, err := sofarpc.Engine().Decode(context.Background(), buf)
+		if err != nil {
+			t.Fatal("Decode bolt v2 heartbeat failed", err)
+		}
+		cmd1, ok := v.(sofarpc.SofaRpcCmd)
+		if !ok || cmd1.ProtocolCode() != sofarpc.PROTOCOL_CODE_V2 || cmd1.CommandCode() != sofarpc.HEARTBEAT ||
+			cmd1.CommandType() != cmd.CommandType() || cmd1.RequestID() != 10 {
+			t.Errorf("decode heartbeat is not equal origin heartbeat, origin: %v, got: %v", cmd, v)
+		}
+	}
+}
+
+// encodeCmd encodes the command as the stream does, the content follows the frame
+func encodeCmd(t *testing.T, cmd sofarpc.SofaRpcCmd) types.IoBuffer {
+	buf, err := sofarpc.Engine().Encode(context.Background(), cmd)
+	if err != nil {
+		t.Fatal("Encode command failed", err)
+	}
+	if data := cmd.Data(); data != nil && !sofarpc.CrcEnabled(cmd) {
+		buf.Write(data.Bytes())
+	}
+	return buf
+}
+
+func decodeCmd(t *testing.T, buf types.IoBuffer) sofarpc.SofaRpcCmd {
+	v, err := sofarpc.Engine().Decode(context.Background(), buf)
+	if err != nil {
+		t.Fatal("Decode command failed", err)
+	}
+	cmd, ok := v.(sofarpc.SofaRpcCmd)
+	if !ok {
+		t.Fatalf("decode command failed: %v", v)
+	}
+	return cmd
+}
+
+func TestConvertBoltV1AndV2(t *testing.T) {
+	// v1 downstream, v2 upstream
+	req := &sofarpc.BoltRequest{
+		Protocol:      sofarpc.PROTOCOL_CODE_V1,
+		CmdType:       sofarpc.REQUEST,
+		CmdCode:       sofarpc.RPC_REQUEST,
+		Version:       1,
+		ReqID:         1,
+		Codec:         sofarpc.HESSIAN2_SERIALIZE,
+		Timeout:       3000,
+		RequestClass:  "com.alipay.sofa.rpc.core.request.SofaRequest",
+		RequestHeader: map[string]string{"service": "test"},
+		ContentLen:    len("request content"),
+		Content:       buffer.NewIoBufferString("request content"),
+	}
+	v1req := decodeCmd(t, encodeCmd(t, req))
+	v2req := decodeCmd(t, encodeCmd(t, sofarpc.ConvertCmd(v1req, sofarpc.PROTOCOL_CODE_V2)))
+	if _, ok := v2req.(*sofarpc.BoltRequestV2); !ok {
+		t.Fatalf("expected bolt v2 request, but got: %v", v2req)
+	}
+	if v2req.GetTimeout() != 3000 || v2req.RequestID() != 1 || v2req.Data().String() != "request content" {
+		t.Errorf("convert request is not equal origin request: %v", v2req)
+	}
+	if service, _ := v2req.Get("service"); service != "test" {
+		t.Errorf("convert header is not equal origin header, got: %s", service)
+	}
+	resp := &sofarpc.BoltResponseV2{
+		BoltResponse: sofarpc.BoltResponse{
+			Protocol:       sofarpc.PROTOCOL_CODE_V2,
+			CmdType:        sofarpc.RESPONSE,
+			CmdCode:        sofarpc.RPC_RESPONSE,
+			Version:        1,
+			ReqID:          1,
+			Codec:          sofarpc.HESSIAN2_SERIALIZE,
+			ResponseStatus: sofarpc.RESPONSE_STATUS_SERVER_EXCEPTION,
+			ResponseHeader: map[string]string{"service": "test"},
+			Content:        buffer.NewIoBufferString("response content"),
+		},
+		Version1:   sofarpc.PROTOCOL_VERSION_2,
+		SwitchCode: sofarpc.PROTOCOL_SWITCH_CRC,
+	}
+	v2resp := decodeCmd(t, encodeCmd(t, resp))
+	v1resp := decodeCmd(t, encodeCmd(t, sofarpc.ConvertCmd(v2resp, sofarpc.PROTOCOL_CODE_V1)))
+	if r, ok := v1resp.(*sofarpc.BoltResponse); !ok || r.ResponseStatus != sofarpc.RESPONSE_STATUS_SERVER_EXCEPTION {
+		t.Fatalf("expected bolt v1 response, but got: %v", v1resp)
+	}
+	if v1resp.Data().String() != "response content" {
+		t.Errorf("convert content is not equal origin content, got: %s", v1resp.Data().String())
+	}
+
+	// v2 downstream, v1 upstream
+	req2 := &sofarpc.BoltRequestV2{
+		BoltRequest: *req,
+		Version1:    sofarpc.PROTOCOL_VERSION_1,
+	}
+	req2.Protocol = sofarpc.PROTOCOL_CODE_V2
+	req2.Content = buffer.NewIoBufferString("request content")
+	v2req = decodeCmd(t, encodeCmd(t, req2))
+	v1req = decodeCmd(t, encodeCmd(t, sofarpc.ConvertCmd(v2req, sofarpc.PROTOCOL_CODE_V1)))
+	if _, ok := v1req.(*sofarpc.BoltRequest); !ok || v1req.Data().String() != "request content" {
+		t.Fatalf("expected bolt v1 request, but got: %v", v1req)
+	}
+	resp1 := resp.BoltResponse
+	resp1.Protocol = sofarpc.PROTOCOL_CODE_V1
+	resp1.Content = buffer.NewIoBufferString("response content")
+	v1resp = decodeCmd(t, encodeCmd(t, &resp1))
+	v2resp = decodeCmd(t, encodeCmd(t, sofarpc.ConvertCmd(v1resp, sofarpc.PROTOCOL_CODE_V2)))
+	if _, ok := v2resp.(*sofarpc.BoltResponseV2); !ok || v2resp.Data().String() != "response content" {
+		t.Fatalf("expected bolt v2 response, but got: %v", v2resp)
+	}
+	// same protocol, no conversion
+	if cmd := sofarpc.ConvertCmd(v2resp, sofarpc.PROTOCOL_CODE_V2); cmd != v2resp {
+		t.Error("expected no conversion for the same protocol")
+	}
+}
+
 func BenchmarkBoltCodec_Encode(b *testing.B) {
 	request := &sofarpc.BoltRequest{
 		Protocol: sofarpc.PROTOCOL_CODE_V1,
@@ -88,6 +88,47 @@ func MapToFields(ctx context.Context, cmd SofaRpcCmd) (map[string]string, error)
 	return nil, rpc.ErrUnrecognizedCode
 }
 
+// ConvertCmd converts the bolt command between the bolt v1 and the bolt v2, keeps the header and the content.
+// the command is returned as it is if the protocol code is same or the conversion is not supported
+func ConvertCmd(cmd SofaRpcCmd, protocolCode byte) SofaRpcCmd {
+	if cmd.ProtocolCode() == protocolCode {
+		return cmd
+	}
+	switch c := cmd.(type) {
+	case *BoltRequest:
+		if protocolCode == PROTOCOL_CODE_V2 {
+			req := &BoltRequestV2{
+				BoltRequest: *c,
+				Version1:    PROTOCOL_VERSION_1,
+			}
+			req.Protocol = PROTOCOL_CODE_V2
+			return req
+		}
+	case *BoltRequestV2:
+		if protocolCode == PROTOCOL_CODE_V1 {
+			req := c.BoltRequest
+			req.Protocol = PROTOCOL_CODE_V1
+			return &req
+		}
+	case *BoltResponse:
+		if protocolCode == PROTOCOL_CODE_V2 {
+			resp := &BoltResponseV2{
+				BoltResponse: *c,
+				Version1:     PROTOCOL_VERSION_1,
+			}
+			resp.Protocol = PROTOCOL_CODE_V2
+			return resp
+		}
+	case *BoltResponseV2:
+		if protocolCode == PROTOCOL_CODE_V1 {
+			resp := c.BoltResponse
+			resp.Protocol = PROTOCOL_CODE_V1
+			return &resp
+		}
+	}
+	return cmd
+}
+
 // common -> sofarpc converter
 type common2sofa struct{}
 
@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conv
+
+import (
+	"context"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	defaultHTTPMethod  = "POST"
+	defaultContentType = "application/octet-stream"
+)
+
+// contentTypes maps the serialization codec of the bolt command to the http content type
+var contentTypes = map[byte]string{
+	sofarpc.HESSIAN2_SERIALIZE: "application/x-hessian",
+	sofarpc.PROTOBUF_SERIALIZE: "application/x-protobuf",
+	sofarpc.JSON_SERIALIZE:     "application/json",
+}
+
+func init() {
+	protocol.RegisterConv(protocol.SofaRPC, protocol.HTTP1, &sofa2http{})
+	protocol.RegisterConv(protocol.HTTP1, protocol.SofaRPC, &http2sofa{})
+}
+
+// sofarpc -> http1 converter, maps the bolt requests matched the http mappings to the http requests.
+// other headers are converted by the common path.
+type sofa2http struct{}
+
+func (c *sofa2http) ConvHeader(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	cmd, ok := headerMap.(sofarpc.SofaRpcCmd)
+	if !ok || cmd.CommandCode() != sofarpc.RPC_REQUEST {
+		return nil, protocol.ErrNotFound
+	}
+	request, ok := sofarpc.ConvertCmd(cmd, sofarpc.PROTOCOL_CODE_V1).(*sofarpc.BoltRequest)
+	if !ok {
+		return nil, protocol.ErrNotFound
+	}
+
+	service := request.RequestHeader[models.SERVICE_KEY]
+	if service == "" {
+		service = request.RequestHeader[models.TARGET_SERVICE_KEY]
+	}
+	mapping := findHTTPMapping(ctx, service)
+	if mapping == nil {
+		return nil, protocol.ErrNotFound
+	}
+
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}, Typed: &protocol.TypedHeader{}}
+	// copy headers, the global timeout is consumed by the proxy
+	for k, v := range request.RequestHeader {
+		if k != types.HeaderGlobalTimeout {
+			header.Set(k, v)
+		}
+	}
+
+	method := mapping.Method
+	if method == "" {
+		method = defaultHTTPMethod
+	}
+	header.Typed.SetValue(protocol.TypedMethod, method)
+
+	path := strings.NewReplacer(
+		"{service}", service,
+		"{method}", request.RequestHeader[models.TARGET_METHOD],
+		"{class}", request.RequestClass,
+	).Replace(mapping.Path)
+	if path != "" {
+		header.Typed.SetValue(protocol.TypedPath, path)
+	}
+
+	// the host rewritten by the route is kept
+	if _, ok := header.Typed.Value(protocol.TypedHost); !ok && mapping.Host != "" {
+		header.Typed.SetValue(protocol.TypedHost, mapping.Host)
+	}
+
+	contentType, ok := contentTypes[request.Codec]
+	if !ok {
+		contentType = defaultContentType
+	}
+	header.SetContentType(contentType)
+
+	return header, nil
+}
+
+func (c *sofa2http) ConvData(ctx context.Context, buffer types.IoBuffer) (types.IoBuffer, error) {
+	return buffer, nil
+}
+
+func (c *sofa2http) ConvTrailer(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	return headerMap, nil
+}
+
+// http1 -> sofarpc converter, maps the http responses without the bolt fields to the bolt responses.
+// other headers are converted by the common path.
+type http2sofa struct{}
+
+func (c *http2sofa) ConvHeader(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	header, ok := headerMap.(http.ResponseHeader)
+	if !ok {
+		return nil, protocol.ErrNotFound
+	}
+	// the bolt response is tunneled by the http response
+	if _, ok := header.Get(sofarpc.SofaPropertyHeader(sofarpc.HeaderProtocolCode)); ok {
+		return nil, protocol.ErrNotFound
+	}
+
+	codec := sofarpc.HESSIAN2_SERIALIZE
+	contentType := string(header.ContentType())
+	for k, v := range contentTypes {
+		if strings.HasPrefix(contentType, v) {
+			codec = k
+			break
+		}
+	}
+
+	responseHeader := make(map[string]string, header.Len())
+	header.VisitAll(func(key, value []byte) {
+		k := strings.ToLower(string(key))
+		switch k {
+		case "content-length", "content-type", "connection", "transfer-encoding", types.HeaderStatus:
+		default:
+			responseHeader[k] = string(value)
+		}
+	})
+
+	// the response is converted to the sub protocol of the request by the stream
+	return &sofarpc.BoltResponse{
+		Protocol:       sofarpc.PROTOCOL_CODE_V1,
+		CmdType:        sofarpc.RESPONSE,
+		CmdCode:        sofarpc.RPC_RESPONSE,
+		Version:        1,
+		Codec:          codec,
+		ResponseStatus: mappingHTTPStatus(header.StatusCode()),
+		ResponseHeader: responseHeader,
+	}, nil
+}
+
+func (c *http2sofa) ConvData(ctx context.Context, buffer types.IoBuffer) (types.IoBuffer, error) {
+	return buffer, nil
+}
+
+func (c *http2sofa) ConvTrailer(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	return headerMap, nil
+}
+
+// findHTTPMapping returns the http mapping of the service in the proxy's sofarpc extend config
+func findHTTPMapping(ctx context.Context, service string) *v2.SofaRPCHTTPMapping {
+	config, ok := mosnctx.Get(ctx, types.ContextKeySofaRPCExtendConfig).(v2.SofaRPCExtendConfig)
+	if !ok {
+		return nil
+	}
+	for i := range config.HTTPMappings {
+		if mapping := &config.HTTPMappings[i]; mapping.Service == service || mapping.Service == "*" {
+			return mapping
+		}
+	}
+	return nil
+}
+
+// mappingHTTPStatus maps the http status of the upstream response to the bolt response status
+func mappingHTTPStatus(code int) int16 {
+	switch {
+	case code >= http.OK && code < http.MultipleChoices:
+		return sofarpc.RESPONSE_STATUS_SUCCESS
+	case code == http.NotFound:
+		return sofarpc.RESPONSE_STATUS_NO_PROCESSOR
+	case code == http.ServiceUnavailable:
+		return sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY
+	case code == http.GatewayTimeout:
+		return sofarpc.RESPONSE_STATUS_TIMEOUT
+	case code >= http.BadRequest && code < http.InternalServerError:
+		return sofarpc.RESPONSE_STATUS_ERROR
+	case code >= http.InternalServerError:
+		return sofarpc.RESPONSE_STATUS_SERVER_EXCEPTION
+	}
+	return sofarpc.RESPONSE_STATUS_UNKNOWN
+}
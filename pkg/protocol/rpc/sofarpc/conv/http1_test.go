@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/http/conv"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newBoltRequest() *sofarpc.BoltRequest {
+	return &sofarpc.BoltRequest{
+		Protocol:     sofarpc.PROTOCOL_CODE_V1,
+		CmdType:      sofarpc.REQUEST,
+		CmdCode:      sofarpc.RPC_REQUEST,
+		Version:      1,
+		ReqID:        1,
+		Codec:        sofarpc.HESSIAN2_SERIALIZE,
+		Timeout:      3000,
+		RequestClass: "com.alipay.sofa.rpc.core.request.SofaRequest",
+		RequestHeader: map[string]string{
+			"service":                 "com.alipay.test.EchoService:1.0",
+			"sofa_head_method_name":   "echo",
+			types.HeaderGlobalTimeout: "3000",
+		},
+	}
+}
+
+func TestConvertBoltToHTTP(t *testing.T) {
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeySofaRPCExtendConfig, v2.SofaRPCExtendConfig{
+		HTTPMappings: []v2.SofaRPCHTTPMapping{
+			{
+				Service: "com.alipay.test.EchoService:1.0",
+				Path:    "/echo/{method}",
+				Host:    "echo.test.com",
+			},
+		},
+	})
+	headers, err := protocol.ConvertHeader(ctx, protocol.SofaRPC, protocol.HTTP1, newBoltRequest())
+	if err != nil {
+		t.Fatalf("convert bolt request failed: %v", err)
+	}
+	header, ok := headers.(http.RequestHeader)
+	if !ok {
+		t.Fatalf("expected the http request header, but got %T", headers)
+	}
+	for k, v := range map[string]string{
+		protocol.MosnHeaderMethod:  "POST",
+		protocol.MosnHeaderPathKey: "/echo/echo",
+		protocol.MosnHeaderHostKey: "echo.test.com",
+		"service":                  "com.alipay.test.EchoService:1.0",
+		"Content-Type":             "application/x-hessian",
+	} {
+		if value, _ := header.Get(k); value != v {
+			t.Errorf("expected header %s: %s, but got %s", k, v, value)
+		}
+	}
+	if _, ok := header.Get(types.HeaderGlobalTimeout); ok {
+		t.Error("the global timeout should not be sent to the upstream")
+	}
+	// the bolt fields are not mapped
+	if _, ok := header.Get(sofarpc.HeaderProtocolCode); ok {
+		t.Error("the bolt fields should not be mapped")
+	}
+
+	// the host rewritten by the route is kept
+	request := newBoltRequest()
+	request.RequestHeader[protocol.MosnHeaderHostKey] = "127.0.0.1:8080"
+	headers, _ = protocol.ConvertHeader(ctx, protocol.SofaRPC, protocol.HTTP1, request)
+	if host, _ := headers.Get(protocol.MosnHeaderHostKey); host != "127.0.0.1:8080" {
+		t.Errorf("expected the rewritten host, but got %s", host)
+	}
+}
+
+func TestConvertBoltToHTTPTunnel(t *testing.T) {
+	// the requests matched no mapping are tunneled with the bolt fields
+	headers, err := protocol.ConvertHeader(context.Background(), protocol.SofaRPC, protocol.HTTP1, newBoltRequest())
+	if err != nil {
+		t.Fatalf("convert bolt request failed: %v", err)
+	}
+	if _, ok := headers.(http.RequestHeader); !ok {
+		t.Fatalf("expected the http request header, but got %T", headers)
+	}
+	if code, _ := headers.Get(sofarpc.HeaderProtocolCode); code != "1" {
+		t.Errorf("expected the bolt protocol code in the headers, but got %s", code)
+	}
+	if _, ok := headers.Get(protocol.MosnHeaderPathKey); ok {
+		t.Error("the tunneled request should not be mapped")
+	}
+}
+
+func TestConvertHTTPToBolt(t *testing.T) {
+	header := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	header.SetStatusCode(http.ServiceUnavailable)
+	header.SetContentType("application/json; charset=utf-8")
+	header.Set("x-test", "test")
+	header.Set(types.HeaderStatus, "503")
+
+	headers, err := protocol.ConvertHeader(context.Background(), protocol.HTTP1, protocol.SofaRPC, header)
+	if err != nil {
+		t.Fatalf("convert http response failed: %v", err)
+	}
+	resp, ok := headers.(*sofarpc.BoltResponse)
+	if !ok {
+		t.Fatalf("expected the bolt response, but got %T", headers)
+	}
+	if resp.ResponseStatus != sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY {
+		t.Errorf("expected response status %d, but got %d", sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY, resp.ResponseStatus)
+	}
+	if resp.Codec != sofarpc.JSON_SERIALIZE {
+		t.Errorf("expected codec %d, but got %d", sofarpc.JSON_SERIALIZE, resp.Codec)
+	}
+	if v, _ := resp.Get("x-test"); v != "test" {
+		t.Errorf("expected the header copied, but got %s", v)
+	}
+	if _, ok := resp.Get(types.HeaderStatus); ok {
+		t.Error("the status header should not be sent to the downstream")
+	}
+}
+
+func TestMappingHTTPStatus(t *testing.T) {
+	for code, status := range map[int]int16{
+		http.OK:                  sofarpc.RESPONSE_STATUS_SUCCESS,
+		http.NoContent:           sofarpc.RESPONSE_STATUS_SUCCESS,
+		http.NotFound:            sofarpc.RESPONSE_STATUS_NO_PROCESSOR,
+		http.BadRequest:          sofarpc.RESPONSE_STATUS_ERROR,
+		http.ServiceUnavailable:  sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY,
+		http.GatewayTimeout:      sofarpc.RESPONSE_STATUS_TIMEOUT,
+		http.InternalServerError: sofarpc.RESPONSE_STATUS_SERVER_EXCEPTION,
+		http.Found:               sofarpc.RESPONSE_STATUS_UNKNOWN,
+	} {
+		if s := mappingHTTPStatus(code); s != status {
+			t.Errorf("http status %d expected mapped to %d, but got %d", code, status, s)
+		}
+	}
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	if _, err := protocol.ConvertHeader(context.Background(), protocol.SofaRPC, types.Protocol("unknown"), newBoltRequest()); err != protocol.ErrNotFound {
+		t.Errorf("expected ErrNotFound, but got %v", err)
+	}
+}
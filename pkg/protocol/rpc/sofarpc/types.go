@@ -78,6 +78,9 @@ const (
 	PROTOCOL_VERSION_1 byte = 1 // version
 	PROTOCOL_VERSION_2 byte = 2
 
+	PROTOCOL_SWITCH_CRC byte = 0x01 // switch bit of the crc32 check, works when ver1 is PROTOCOL_VERSION_2
+	CRC_LEN             int  = 4
+
 	REQUEST_HEADER_LEN_V1 int = 22 // protocol header fields length
 	REQUEST_HEADER_LEN_V2 int = 24
 
@@ -96,6 +99,8 @@ const (
 	RPC_RESPONSE int16 = 2
 
 	HESSIAN2_SERIALIZE byte = 1 // serialize
+	PROTOBUF_SERIALIZE byte = 11
+	JSON_SERIALIZE     byte = 12
 
 	RESPONSE_STATUS_SUCCESS                   int16 = 0  // 0x00 response status
 	RESPONSE_STATUS_ERROR                     int16 = 1  // 0x01
@@ -117,6 +122,10 @@ const (
 	UnKnownCmdType string = "unknown cmd type"
 	UnKnownCmdCode string = "unknown cmd code"
 
+	// Sub protocol names
+	SubProtocolBoltV1 string = "boltv1"
+	SubProtocolBoltV2 string = "boltv2"
+
 	// Sofa Rpc Default HC Parameters
 	SofaRPC                             = "SofaRpc"
 	DefaultBoltHeartBeatTimeout         = 6 * 15 * time.Second
@@ -130,6 +139,7 @@ var (
 	// Encode/Decode Exception
 	ErrUnKnownCmdType = errors.New(UnKnownCmdType)
 	ErrUnKnownCmdCode = errors.New(UnKnownCmdCode)
+	ErrCrcCheckFailed = errors.New("bolt v2 crc32 check failed")
 )
 
 // DefaultSofaRPCHealthCheckConf
@@ -472,3 +482,35 @@ type BoltResponseV2 struct {
 	Version1   byte //00
 	SwitchCode byte
 }
+
+// SubProtocolCode returns the protocol code of the sub protocol name
+func SubProtocolCode(name string) (byte, bool) {
+	switch name {
+	case SubProtocolBoltV1:
+		return PROTOCOL_CODE_V1, true
+	case SubProtocolBoltV2:
+		return PROTOCOL_CODE_V2, true
+	}
+	return 0, false
+}
+
+// CrcEnabled reports whether the crc32 of the frame is appended
+func (b *BoltRequestV2) CrcEnabled() bool {
+	return b.Version1 == PROTOCOL_VERSION_2 && b.SwitchCode&PROTOCOL_SWITCH_CRC != 0
+}
+
+// CrcEnabled reports whether the crc32 of the frame is appended
+func (b *BoltResponseV2) CrcEnabled() bool {
+	return b.Version1 == PROTOCOL_VERSION_2 && b.SwitchCode&PROTOCOL_SWITCH_CRC != 0
+}
+
+// CrcEnabled reports whether the command is checked by crc32.
+// the content of the command is encoded into the frame, as the crc32 follows the content
+func CrcEnabled(cmd SofaRpcCmd) bool {
+	if c, ok := cmd.(interface {
+		CrcEnabled() bool
+	}); ok {
+		return c.CrcEnabled()
+	}
+	return false
+}
@@ -51,9 +51,15 @@ func NewHeartbeatAck(protocolCode byte) SofaRpcCmd {
 	return nil
 }
 
+func init() {
+	serialize.Register(HESSIAN2_SERIALIZE, &serialize.Instance)
+	serialize.Register(PROTOBUF_SERIALIZE, &serialize.ProtobufInstance)
+	serialize.Register(JSON_SERIALIZE, &serialize.JSONInstance)
+}
+
 func DeserializeBoltRequest(ctx context.Context, request *BoltRequest) {
-	//get instance
-	serializeIns := serialize.Instance
+	//get instance by the codec
+	serializeIns := serialize.GetSerialization(request.Codec)
 
 	protocolCtx := protocol.ProtocolBuffersByContext(ctx)
 	request.RequestHeader = protocolCtx.GetReqHeaders()
@@ -64,7 +70,10 @@ func DeserializeBoltRequest(ctx context.Context, request *BoltRequest) {
 	debugEnabled := logger.GetLogLevel() >= log.DEBUG
 
 	//deserialize header
-	serializeIns.DeserializeMap(request.HeaderMap, request.RequestHeader)
+	// the header fields decoded before the error are still routable
+	if err := serializeIns.DeserializeMap(request.HeaderMap, request.RequestHeader); err != nil {
+		logger.Errorf(ctx, "[protocol][sofarpc] deserialize bolt request header failed, codec: %d, error: %v", request.Codec, err)
+	}
 	if debugEnabled {
 		logger.Debugf(ctx, "[protocol][sofarpc] deserialize bolt request, header: %v", request.RequestHeader)
 	}
@@ -77,8 +86,8 @@ func DeserializeBoltRequest(ctx context.Context, request *BoltRequest) {
 }
 
 func DeserializeBoltResponse(ctx context.Context, response *BoltResponse) {
-	//get instance
-	serializeIns := serialize.Instance
+	//get instance by the codec
+	serializeIns := serialize.GetSerialization(response.Codec)
 
 	//logger
 	logger := log.Proxy
@@ -90,7 +99,9 @@ func DeserializeBoltResponse(ctx context.Context, response *BoltResponse) {
 	//response.ResponseHeader = make(map[string]string, 8)
 
 	//deserialize header
-	serializeIns.DeserializeMap(response.HeaderMap, response.ResponseHeader)
+	if err := serializeIns.DeserializeMap(response.HeaderMap, response.ResponseHeader); err != nil {
+		logger.Errorf(ctx, "[protocol][sofarpc] deserialize bolt response header failed, codec: %d, error: %v", response.Codec, err)
+	}
 	if debugEnabled {
 		logger.Debugf(ctx, "[protocol][sofarpc] deserialize bolt response, header: %+v", response.ResponseHeader)
 	}
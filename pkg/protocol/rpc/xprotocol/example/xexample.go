@@ -0,0 +1,225 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package example
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+	xstream "sofastack.io/sofa-mosn/pkg/stream/xprotocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// XExample is an example of the custom protocol registered as an XProtocol.
+// Once the package is imported, "XExample" is usable as the downstream_protocol and upstream_protocol of the proxy.
+//
+// frame layout, big endian:
+// | magic(2) | type(1) | request id(8) | header len(2) | data len(4) | header | data |
+// header: | key len(2) | key | value len(2) | value | ...
+const (
+	XExampleProtocol types.Protocol = "XExample"
+
+	XExampleMagic     uint16 = 0x5845
+	XExampleHeaderLen        = 17
+
+	// frame type
+	XExampleResponse          byte = 0
+	XExampleRequest           byte = 1
+	XExampleHeartbeat         byte = 2
+	XExampleHeartbeatResponse byte = 3
+
+	// XExampleStatusKey is the header of the response status
+	XExampleStatusKey = "status"
+)
+
+var (
+	ErrInvalidMagic     = errors.New("invalid xexample magic")
+	ErrInvalidFrameType = errors.New("invalid xexample frame type")
+	ErrInvalidHeader    = errors.New("invalid xexample header")
+)
+
+func init() {
+	if err := xstream.RegisterProtocol(&xexample{}); err != nil {
+		log.DefaultLogger.Errorf("[xexample] register protocol failed: %v", err)
+	}
+}
+
+// XExampleFrame is the frame of the xexample protocol, the header is the routable fields
+type XExampleFrame struct {
+	protocol.CommonHeader
+	Type      byte
+	RequestId uint64
+	Data      types.IoBuffer
+}
+
+// NewXExampleFrame creates a frame with an empty header
+func NewXExampleFrame(frameType byte, requestId uint64) *XExampleFrame {
+	return &XExampleFrame{
+		CommonHeader: make(protocol.CommonHeader),
+		Type:         frameType,
+		RequestId:    requestId,
+	}
+}
+
+func (f *XExampleFrame) IsRequest() bool {
+	return f.Type == XExampleRequest || f.Type == XExampleHeartbeat
+}
+
+func (f *XExampleFrame) IsHeartbeat() bool {
+	return f.Type == XExampleHeartbeat || f.Type == XExampleHeartbeatResponse
+}
+
+func (f *XExampleFrame) GetRequestId() uint64 {
+	return f.RequestId
+}
+
+func (f *XExampleFrame) SetRequestId(id uint64) {
+	f.RequestId = id
+}
+
+func (f *XExampleFrame) GetData() types.IoBuffer {
+	return f.Data
+}
+
+func (f *XExampleFrame) SetData(data types.IoBuffer) {
+	f.Data = data
+}
+
+// Clone keeps the frame fields, the header is deep copied
+func (f *XExampleFrame) Clone() types.HeaderMap {
+	frame := *f
+	frame.CommonHeader = f.CommonHeader.Clone().(protocol.CommonHeader)
+	return &frame
+}
+
+type xexample struct{}
+
+func (p *xexample) Name() types.Protocol {
+	return XExampleProtocol
+}
+
+func (p *xexample) Decode(ctx context.Context, data types.IoBuffer) (xprotocol.XFrame, error) {
+	bytes := data.Bytes()
+	if len(bytes) < XExampleHeaderLen {
+		return nil, nil
+	}
+	if binary.BigEndian.Uint16(bytes[0:2]) != XExampleMagic {
+		return nil, ErrInvalidMagic
+	}
+	frameType := bytes[2]
+	if frameType > XExampleHeartbeatResponse {
+		return nil, ErrInvalidFrameType
+	}
+	headerLen := int(binary.BigEndian.Uint16(bytes[11:13]))
+	dataLen := int(binary.BigEndian.Uint32(bytes[13:17]))
+	frameLen := XExampleHeaderLen + headerLen + dataLen
+	if len(bytes) < frameLen {
+		return nil, nil
+	}
+
+	frame := NewXExampleFrame(frameType, binary.BigEndian.Uint64(bytes[3:11]))
+	header := bytes[XExampleHeaderLen : XExampleHeaderLen+headerLen]
+	for len(header) > 0 {
+		key, rest, ok := readString(header)
+		if !ok {
+			return nil, ErrInvalidHeader
+		}
+		value, rest, ok := readString(rest)
+		if !ok {
+			return nil, ErrInvalidHeader
+		}
+		frame.Set(key, value)
+		header = rest
+	}
+	if dataLen > 0 {
+		// copy the data, as the buffer is drained
+		content := make([]byte, dataLen)
+		copy(content, bytes[XExampleHeaderLen+headerLen:frameLen])
+		frame.Data = buffer.NewIoBufferBytes(content)
+	}
+	data.Drain(frameLen)
+	return frame, nil
+}
+
+func readString(b []byte) (string, []byte, bool) {
+	if len(b) < 2 {
+		return "", nil, false
+	}
+	l := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+l {
+		return "", nil, false
+	}
+	return string(b[2 : 2+l]), b[2+l:], true
+}
+
+func (p *xexample) Encode(ctx context.Context, frame xprotocol.XFrame) (types.IoBuffer, error) {
+	f, ok := frame.(*XExampleFrame)
+	if !ok {
+		return nil, errors.New("not xexample frame")
+	}
+	headerLen := 0
+	f.Range(func(key, value string) bool {
+		headerLen += 4 + len(key) + len(value)
+		return true
+	})
+	dataLen := 0
+	if f.Data != nil {
+		dataLen = f.Data.Len()
+	}
+
+	buf := buffer.NewIoBuffer(XExampleHeaderLen + headerLen + dataLen)
+	b := make([]byte, XExampleHeaderLen)
+	binary.BigEndian.PutUint16(b[0:2], XExampleMagic)
+	b[2] = f.Type
+	binary.BigEndian.PutUint64(b[3:11], f.RequestId)
+	binary.BigEndian.PutUint16(b[11:13], uint16(headerLen))
+	binary.BigEndian.PutUint32(b[13:17], uint32(dataLen))
+	buf.Write(b)
+
+	var l [2]byte
+	f.Range(func(key, value string) bool {
+		binary.BigEndian.PutUint16(l[:], uint16(len(key)))
+		buf.Write(l[:])
+		buf.WriteString(key)
+		binary.BigEndian.PutUint16(l[:], uint16(len(value)))
+		buf.Write(l[:])
+		buf.WriteString(value)
+		return true
+	})
+	if dataLen > 0 {
+		buf.Write(f.Data.Bytes())
+	}
+	return buf, nil
+}
+
+func (p *xexample) Reply(request xprotocol.XFrame) xprotocol.XFrame {
+	return NewXExampleFrame(XExampleHeartbeatResponse, request.GetRequestId())
+}
+
+// Hijack replies the hijacked request with the status in header
+func (p *xexample) Hijack(request xprotocol.XFrame, statusCode int) xprotocol.XFrame {
+	resp := NewXExampleFrame(XExampleResponse, request.GetRequestId())
+	resp.Set(XExampleStatusKey, strconv.Itoa(statusCode))
+	return resp
+}
@@ -0,0 +1,314 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package example
+
+import (
+	"context"
+	"net"
+
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/network"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	xstream "sofastack.io/sofa-mosn/pkg/stream/xprotocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+// importing the package registers the protocol in init(),
+// then the protocol name is usable in the listener and the cluster configs
+func TestXExampleRegistered(t *testing.T) {
+	if xstream.GetProtocol(XExampleProtocol) == nil {
+		t.Fatal("xexample protocol is not registered")
+	}
+	if err := xstream.RegisterProtocol(&xexample{}); err != xstream.ErrDuplicateProtocol {
+		t.Errorf("expected duplicate protocol, but got %v", err)
+	}
+	if _, ok := network.ConnNewPoolFactories[XExampleProtocol]; !ok {
+		t.Error("no connection pool registered for xexample")
+	}
+	// the proxy of the listener
+	proxy := config.ParseProxyFilter(map[string]interface{}{
+		"downstream_protocol": "XExample",
+		"upstream_protocol":   "XExample",
+		"router_config_name":  "xexample_router",
+	})
+	if proxy.DownstreamProtocol != string(XExampleProtocol) || proxy.UpstreamProtocol != string(XExampleProtocol) {
+		t.Errorf("unexpected proxy config: %+v", proxy)
+	}
+	// the cluster
+	if err := config.ValidateCluster(&v2.Cluster{
+		Name:        "xexample_cluster",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		HealthCheck: v2.HealthCheck{
+			HealthCheckConfig: v2.HealthCheckConfig{
+				Protocol: string(XExampleProtocol),
+			},
+		},
+	}); err != nil {
+		t.Errorf("validate cluster failed: %v", err)
+	}
+}
+
+func newTestFrame(frameType byte, requestId uint64) *XExampleFrame {
+	frame := NewXExampleFrame(frameType, requestId)
+	frame.Set("service", "com.example.HelloService")
+	frame.Data = buffer.NewIoBufferString("hello")
+	return frame
+}
+
+func TestXExampleCodec(t *testing.T) {
+	codec := &xexample{}
+	buf, err := codec.Encode(context.Background(), newTestFrame(XExampleRequest, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// not enough data
+	partial := buffer.NewIoBufferBytes(data[:len(data)-1])
+	if frame, err := codec.Decode(context.Background(), partial); frame != nil || err != nil {
+		t.Fatalf("expected waiting for more data, but got %v, %v", frame, err)
+	}
+	frame, err := codec.Decode(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected the frame drained, but %d bytes left", buf.Len())
+	}
+	if !frame.IsRequest() || frame.IsHeartbeat() || frame.GetRequestId() != 100 || frame.GetData().String() != "hello" {
+		t.Errorf("unexpected frame: %+v", frame)
+	}
+	if service, _ := frame.Get("service"); service != "com.example.HelloService" {
+		t.Errorf("unexpected header: %s", service)
+	}
+	// invalid magic
+	if _, err := codec.Decode(context.Background(), buffer.NewIoBufferBytes(make([]byte, XExampleHeaderLen))); err != ErrInvalidMagic {
+		t.Errorf("expected invalid magic, but got %v", err)
+	}
+}
+
+type mockConnection struct {
+	types.Connection
+	written []types.IoBuffer
+	closed  bool
+}
+
+func (c *mockConnection) Write(buf ...types.IoBuffer) error {
+	c.written = append(c.written, buf...)
+	return nil
+}
+
+func (c *mockConnection) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	c.closed = true
+	return nil
+}
+
+type mockServerListener struct {
+	types.ServerStreamConnectionEventListener
+	sender   types.StreamSender
+	received []types.HeaderMap
+}
+
+func (l *mockServerListener) NewStreamDetect(ctx context.Context, sender types.StreamSender, span types.Span) types.StreamReceiveListener {
+	l.sender = sender
+	return l
+}
+
+func (l *mockServerListener) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	l.received = append(l.received, headers)
+}
+
+func (l *mockServerListener) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+func encodeFrame(t *testing.T, frame *XExampleFrame) types.IoBuffer {
+	buf, err := (&xexample{}).Encode(context.Background(), frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func decodeFrame(t *testing.T, buf types.IoBuffer) *XExampleFrame {
+	frame, err := (&xexample{}).Decode(context.Background(), buf)
+	if err != nil || frame == nil {
+		t.Fatalf("decode frame failed: %v, %v", frame, err)
+	}
+	return frame.(*XExampleFrame)
+}
+
+func TestXExampleServerStream(t *testing.T) {
+	conn := &mockConnection{}
+	listener := &mockServerListener{}
+	sc := str.CreateServerStreamConnection(context.Background(), XExampleProtocol, conn, listener)
+	if sc == nil || sc.Protocol() != XExampleProtocol {
+		t.Fatal("create xexample server stream connection failed")
+	}
+
+	// heartbeat is replied by the stream directly
+	sc.Dispatch(encodeFrame(t, NewXExampleFrame(XExampleHeartbeat, 1)))
+	if len(listener.received) != 0 || len(conn.written) != 1 {
+		t.Fatalf("expected heartbeat replied, received %d, written %d", len(listener.received), len(conn.written))
+	}
+	if ack := decodeFrame(t, conn.written[0]); ack.Type != XExampleHeartbeatResponse || ack.RequestId != 1 {
+		t.Errorf("unexpected heartbeat ack: %+v", ack)
+	}
+
+	// two requests in one buffer, the last one is not completed
+	buf := encodeFrame(t, newTestFrame(XExampleRequest, 2))
+	next := encodeFrame(t, newTestFrame(XExampleRequest, 3))
+	buf.Write(next.Bytes()[:5])
+	sc.Dispatch(buf)
+	if len(listener.received) != 1 || buf.Len() != 5 {
+		t.Fatalf("expected one request received, but got %d, %d bytes left", len(listener.received), buf.Len())
+	}
+	// the response from the upstream keeps the request id of the downstream
+	resp := newTestFrame(XExampleResponse, 1000)
+	if err := listener.sender.AppendHeaders(context.Background(), resp, true); err != nil {
+		t.Fatal(err)
+	}
+	if resp := decodeFrame(t, conn.written[1]); resp.RequestId != 2 || resp.Data.String() != "hello" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	// hijack
+	req := listener.received[0].(*XExampleFrame)
+	req.Set(types.HeaderStatus, "404")
+	if err := listener.sender.AppendHeaders(context.Background(), req, true); err != nil {
+		t.Fatal(err)
+	}
+	if resp := decodeFrame(t, conn.written[2]); resp.Type != XExampleResponse || resp.CommonHeader[XExampleStatusKey] != "404" {
+		t.Errorf("unexpected hijack response: %+v", resp)
+	}
+
+	// invalid data closes the connection
+	sc.Dispatch(buffer.NewIoBufferBytes(make([]byte, XExampleHeaderLen)))
+	if !conn.closed {
+		t.Error("expected connection closed for the invalid data")
+	}
+}
+
+// upstream server echoes the requests
+func startUpstream(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				iobuf := buffer.NewIoBuffer(1024)
+				b := make([]byte, 1024)
+				for {
+					n, err := conn.Read(b)
+					if err != nil {
+						return
+					}
+					iobuf.Write(b[:n])
+					for {
+						frame, err := (&xexample{}).Decode(context.Background(), iobuf)
+						if err != nil || frame == nil {
+							break
+						}
+						req := frame.(*XExampleFrame)
+						req.Type = XExampleResponse
+						resp, _ := (&xexample{}).Encode(context.Background(), req)
+						conn.Write(resp.Bytes())
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln
+}
+
+type mockPoolListener struct {
+	sender chan types.StreamSender
+}
+
+func (l *mockPoolListener) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	close(l.sender)
+}
+
+func (l *mockPoolListener) OnReady(sender types.StreamSender, host types.Host) {
+	l.sender <- sender
+}
+
+type mockReceiver struct {
+	received chan types.HeaderMap
+}
+
+func (r *mockReceiver) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	r.received <- headers
+}
+
+func (r *mockReceiver) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+func TestXExampleUpstream(t *testing.T) {
+	ln := startUpstream(t)
+	defer ln.Close()
+
+	c := cluster.NewCluster(v2.Cluster{
+		Name:        "xexample_upstream",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: ln.Addr().String(),
+		},
+	}, c.Snapshot().ClusterInfo())
+	pool := network.ConnNewPoolFactories[XExampleProtocol](host)
+	defer pool.Close()
+	if pool.Protocol() != XExampleProtocol {
+		t.Fatalf("unexpected pool protocol: %s", pool.Protocol())
+	}
+
+	listener := &mockPoolListener{sender: make(chan types.StreamSender, 1)}
+	receiver := &mockReceiver{received: make(chan types.HeaderMap, 1)}
+	pool.NewStream(context.Background(), receiver, listener)
+	sender, ok := <-listener.sender
+	if !ok {
+		t.Fatal("create upstream stream failed")
+	}
+	// the request id is replaced by the stream id
+	if err := sender.AppendHeaders(context.Background(), newTestFrame(XExampleRequest, 100), true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case headers := <-receiver.received:
+		resp := headers.(*XExampleFrame)
+		if resp.Type != XExampleResponse || resp.RequestId != sender.GetStream().ID() || resp.Data.String() != "hello" {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait response timeout")
+	}
+}
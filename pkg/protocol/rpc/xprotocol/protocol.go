@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// XFrame is the command of a custom protocol, decoded by the XProtocol.
+// The routable fields are viewed as a header map, which is used by the router, the access log and so on
+type XFrame interface {
+	types.HeaderMap
+
+	// IsRequest returns true if the frame is a request, false for a response
+	IsRequest() bool
+
+	// IsHeartbeat returns true if the frame is a heartbeat request or response
+	IsHeartbeat() bool
+
+	// GetRequestId returns the request id, which is used to map the response to the request
+	GetRequestId() uint64
+
+	// SetRequestId replaces the request id, the stream layer sets it before encoding
+	SetRequestId(id uint64)
+
+	// GetData returns the body of the frame
+	GetData() types.IoBuffer
+
+	// SetData replaces the body of the frame
+	SetData(data types.IoBuffer)
+}
+
+// XProtocol is the codec of a custom protocol.
+// Registers an XProtocol makes the protocol name usable as the downstream and upstream protocol of the proxy,
+// the framing, the stream id mapping, the heartbeat replying and the connection pool are driven by the xprotocol stream.
+type XProtocol interface {
+	// Name returns the protocol name used in the config
+	Name() types.Protocol
+
+	// Decode decodes a frame from the data, and drains the decoded bytes.
+	// It returns nil frame and nil error if the data is not enough for a frame
+	Decode(ctx context.Context, data types.IoBuffer) (XFrame, error)
+
+	// Encode encodes the frame including the body
+	Encode(ctx context.Context, frame XFrame) (types.IoBuffer, error)
+
+	// Reply builds the response of the heartbeat request.
+	// It returns nil if the heartbeat should be proxied as the normal request
+	Reply(request XFrame) XFrame
+}
+
+// Hijacker is an optional interface of the XProtocol, builds the response of the request hijacked by the proxy,
+// such as no route found or no healthy upstream. The status code is the http status code
+type Hijacker interface {
+	Hijack(request XFrame, statusCode int) XFrame
+}
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serialize
+
+import (
+	"encoding/json"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// JSONInstance
+// singleton of jsonSerialization
+var JSONInstance = jsonSerialization{}
+
+// jsonSerialization serializes the header map as a json object
+type jsonSerialization struct{}
+
+func (s *jsonSerialization) GetSerialNum() int {
+	return 12
+}
+
+func (s *jsonSerialization) SerializeMap(m map[string]string, b types.IoBuffer) error {
+	if len(m) == 0 {
+		return nil
+	}
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = b.Write(bytes)
+	return err
+}
+
+func (s *jsonSerialization) DeserializeMap(b []byte, m map[string]string) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, &m)
+}
@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serialize
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// ProtobufInstance
+// singleton of protobufSerialization
+var ProtobufInstance = protobufSerialization{}
+
+var ErrInvalidProtobuf = errors.New("invalid protobuf header map")
+
+const (
+	// the header map is the message { map<string, string> headers = 1; },
+	// a map entry is the message { string key = 1; string value = 2; }
+	protobufHeadersTag = 1<<3 | 2
+	protobufKeyTag     = 1<<3 | 2
+	protobufValueTag   = 2<<3 | 2
+)
+
+type protobufSerialization struct{}
+
+func (s *protobufSerialization) GetSerialNum() int {
+	return 11
+}
+
+func (s *protobufSerialization) SerializeMap(m map[string]string, b types.IoBuffer) error {
+	for key, value := range m {
+		entryLen := 1 + uvarintSize(uint64(len(key))) + len(key) + 1 + uvarintSize(uint64(len(value))) + len(value)
+
+		writeProtobufTag(b, protobufHeadersTag, entryLen)
+
+		writeProtobufTag(b, protobufKeyTag, len(key))
+		b.Write(UnsafeStrToByte(key))
+
+		writeProtobufTag(b, protobufValueTag, len(value))
+		b.Write(UnsafeStrToByte(value))
+	}
+
+	return nil
+}
+
+func (s *protobufSerialization) DeserializeMap(b []byte, m map[string]string) error {
+	for len(b) > 0 {
+		tag, entry, rest, err := readProtobufField(b)
+		if err != nil {
+			return err
+		}
+		b = rest
+		// skip the unknown fields
+		if tag != protobufHeadersTag {
+			continue
+		}
+
+		var key, value []byte
+		for len(entry) > 0 {
+			tag, field, rest, err := readProtobufField(entry)
+			if err != nil {
+				return err
+			}
+			entry = rest
+			switch tag {
+			case protobufKeyTag:
+				key = field
+			case protobufValueTag:
+				value = field
+			}
+		}
+		m[string(key)] = string(value)
+	}
+	return nil
+}
+
+// readProtobufField reads a field, the value of a varint field is ignored
+func readProtobufField(b []byte) (tag uint64, field []byte, rest []byte, err error) {
+	tag, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, nil, ErrInvalidProtobuf
+	}
+	b = b[n:]
+
+	switch tag & 0x7 {
+	case 0:
+		if _, n = binary.Uvarint(b); n <= 0 {
+			return 0, nil, nil, ErrInvalidProtobuf
+		}
+		return tag, nil, b[n:], nil
+	case 2:
+		length, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < length {
+			return 0, nil, nil, ErrInvalidProtobuf
+		}
+		b = b[n:]
+		return tag, b[:length], b[length:], nil
+	default:
+		return 0, nil, nil, ErrInvalidProtobuf
+	}
+}
+
+// writeProtobufTag writes the tag and the length of a length delimited field
+func writeProtobufTag(b types.IoBuffer, tag byte, length int) {
+	var varint [1 + binary.MaxVarintLen64]byte
+	varint[0] = tag
+	n := binary.PutUvarint(varint[1:], uint64(length))
+	b.Write(varint[:1+n])
+}
+
+func uvarintSize(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,48 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serialize
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// Serialization serializes the header map of the rpc messages
+type Serialization interface {
+	GetSerialNum() int
+
+	SerializeMap(m map[string]string, b types.IoBuffer) error
+
+	DeserializeMap(b []byte, m map[string]string) error
+}
+
+var serializations = make(map[byte]Serialization)
+
+// Register registers the serialization of the codec, the codec is the serialize type in the rpc header,
+// the serialization registered later overrides the former one
+func Register(codec byte, s Serialization) {
+	serializations[codec] = s
+}
+
+// GetSerialization returns the serialization of the codec,
+// the header map of an unknown codec is in the simple format, its body is passed through opaque
+func GetSerialization(codec byte) Serialization {
+	if s, ok := serializations[codec]; ok {
+		return s
+	}
+	return &Instance
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serialize
+
+import (
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+)
+
+func TestSerializations(t *testing.T) {
+	headers := map[string]string{
+		"service": "com.alipay.test.TestService:1.0",
+		"empty":   "",
+	}
+	for _, s := range []Serialization{&Instance, &ProtobufInstance, &JSONInstance} {
+		buf := buffer.GetIoBuffer(128)
+		if err := s.SerializeMap(headers, buf); err != nil {
+			t.Fatalf("serialization %d serialize failed: %v", s.GetSerialNum(), err)
+		}
+		m := make(map[string]string)
+		if err := s.DeserializeMap(buf.Bytes(), m); err != nil {
+			t.Fatalf("serialization %d deserialize failed: %v", s.GetSerialNum(), err)
+		}
+		if len(m) != len(headers) || m["service"] != headers["service"] {
+			t.Errorf("serialization %d expected %v, but got %v", s.GetSerialNum(), headers, m)
+		}
+		// no header map
+		if err := s.DeserializeMap(nil, m); err != nil {
+			t.Errorf("serialization %d deserialize empty failed: %v", s.GetSerialNum(), err)
+		}
+	}
+}
+
+func TestDeserializeInvalid(t *testing.T) {
+	for _, s := range []Serialization{&Instance, &ProtobufInstance, &JSONInstance} {
+		m := make(map[string]string)
+		if err := s.DeserializeMap([]byte{0x0a, 0xff, 0x01}, m); err == nil {
+			t.Errorf("serialization %d expected error", s.GetSerialNum())
+		}
+	}
+}
+
+func TestDeserializeProtobufUnknownFields(t *testing.T) {
+	// field 2 is a varint, the map entry has a unknown field 3
+	b := []byte{
+		0x10, 0x01,
+		0x0a, 0x0a,
+		0x0a, 0x01, 'k',
+		0x12, 0x01, 'v',
+		0x1a, 0x02, 'x', 'y',
+	}
+	m := make(map[string]string)
+	if err := ProtobufInstance.DeserializeMap(b, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m["k"] != "v" {
+		t.Errorf("expected k:v, but got %v", m)
+	}
+}
+
+func TestGetSerialization(t *testing.T) {
+	Register(11, &ProtobufInstance)
+	if GetSerialization(11) != &ProtobufInstance {
+		t.Error("expected the registered protobuf serialization")
+	}
+	if GetSerialization(99) != &Instance {
+		t.Error("expected the unknown codec in the simple format")
+	}
+}
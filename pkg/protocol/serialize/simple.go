@@ -64,6 +64,9 @@ func (s *simpleSerialization) DeserializeMap(b []byte, m map[string]string) erro
 
 	for index < totalLen {
 
+		if index+4 > totalLen {
+			return fmt.Errorf("index %d, totalLen %d, b %v\n", index, totalLen, b)
+		}
 		length := binary.BigEndian.Uint32(b[index:])
 		index += 4
 		end := index + int(length)
@@ -75,6 +78,9 @@ func (s *simpleSerialization) DeserializeMap(b []byte, m map[string]string) erro
 		key := b[index:end]
 		index = end
 
+		if index+4 > totalLen {
+			return fmt.Errorf("index %d, totalLen %d, b %v\n", index, totalLen, b)
+		}
 		length = binary.BigEndian.Uint32(b[index:])
 		index += 4
 		end = index + int(length)
@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"context"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/mtls/crypto/tls"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func init() {
+	buffer.RegisterBuffer(&streamValuesIns)
+}
+
+var streamValuesIns = streamValuesBufferCtx{}
+
+// StreamLogger records the stream events, log.ProxyLogger implements it
+type StreamLogger interface {
+	Infof(ctx context.Context, format string, args ...interface{})
+	Debugf(ctx context.Context, format string, args ...interface{})
+	Warnf(ctx context.Context, format string, args ...interface{})
+	Errorf(ctx context.Context, format string, args ...interface{})
+}
+
+// StreamValues carries the known per-stream data.
+// It is attached to the stream context once, and the fields are read directly
+// instead of looking up the context chain for each value.
+// Unknown or extension data still uses the context values.
+type StreamValues struct {
+	StreamID uint64
+	// Logger is the logger of the stream, log.Proxy is used if it is nil
+	Logger StreamLogger
+
+	// timings
+	StartTime           time.Time
+	RequestReceivedTime time.Time
+	ResponseStartTime   time.Time
+
+	// bytes
+	BytesReceived uint64
+	BytesSent     uint64
+
+	Policy     types.Policy
+	TLSState   *tls.ConnectionState
+	SampleSeed int64
+}
+
+// streamValuesBuffer holds the StreamValues of the streams sharing a buffer context,
+// e.g. the downstream stream and its upstream streams
+type streamValuesBuffer struct {
+	values []*StreamValues
+	used   int
+}
+
+func (b *streamValuesBuffer) take() *StreamValues {
+	if b.used == len(b.values) {
+		b.values = append(b.values, new(StreamValues))
+	}
+	sv := b.values[b.used]
+	b.used++
+	return sv
+}
+
+type streamValuesBufferCtx struct {
+	buffer.TempBufferCtx
+}
+
+func (ctx streamValuesBufferCtx) New() interface{} {
+	return new(streamValuesBuffer)
+}
+
+func (ctx streamValuesBufferCtx) Reset(i interface{}) {
+	b, _ := i.(*streamValuesBuffer)
+
+	for _, sv := range b.values[:b.used] {
+		*sv = StreamValues{}
+	}
+	b.used = 0
+}
+
+// WithStreamValues takes a StreamValues from the buffer context, and attaches it to the context.
+// The StreamValues is reset and reused after the buffer context is given back.
+func WithStreamValues(ctx context.Context) (context.Context, *StreamValues) {
+	b := buffer.PoolContext(ctx).Find(&streamValuesIns, nil).(*streamValuesBuffer)
+	sv := b.take()
+	return mosnctx.WithValue(ctx, types.ContextKeyStreamValues, sv), sv
+}
+
+// StreamValuesByContext returns the StreamValues attached to the context, or nil
+func StreamValuesByContext(ctx context.Context) *StreamValues {
+	if ctx == nil {
+		return nil
+	}
+	if sv, ok := mosnctx.Get(ctx, types.ContextKeyStreamValues).(*StreamValues); ok {
+		return sv
+	}
+	return nil
+}
+
+// StreamIDByContext returns the stream id of the context.
+// The context value is used if no StreamValues is attached.
+func StreamIDByContext(ctx context.Context) (uint64, bool) {
+	if sv := StreamValuesByContext(ctx); sv != nil {
+		return sv.StreamID, true
+	}
+	if ctx == nil {
+		return 0, false
+	}
+	id, ok := mosnctx.Get(ctx, types.ContextKeyStreamID).(uint64)
+	return id, ok
+}
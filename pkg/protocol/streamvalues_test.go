@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestStreamValues(t *testing.T) {
+	ctx := buffer.NewBufferPoolContext(context.Background())
+	ctx, sv := WithStreamValues(ctx)
+	sv.StreamID = 10
+	if StreamValuesByContext(ctx) != sv {
+		t.Fatal("stream values is not attached to the context")
+	}
+	if id, ok := StreamIDByContext(ctx); !ok || id != 10 {
+		t.Fatalf("unexpected stream id: %d", id)
+	}
+	// streams sharing a buffer context take their own values
+	ctx2, sv2 := WithStreamValues(mosnctx.Clone(ctx))
+	sv2.StreamID = 11
+	if sv2 == sv || StreamValuesByContext(ctx2) != sv2 {
+		t.Fatal("stream values should not be shared")
+	}
+	if id, _ := StreamIDByContext(ctx); id != 10 {
+		t.Fatalf("stream id is overwritten: %d", id)
+	}
+	// fall back to the context value
+	ctx3 := mosnctx.WithValue(context.Background(), types.ContextKeyStreamID, uint64(12))
+	if id, ok := StreamIDByContext(ctx3); !ok || id != 12 {
+		t.Fatalf("unexpected stream id: %d", id)
+	}
+	if _, ok := StreamIDByContext(context.Background()); ok {
+		t.Fatal("no stream id expected")
+	}
+}
+
+func TestStreamValuesReset(t *testing.T) {
+	b := streamValuesIns.New().(*streamValuesBuffer)
+	for i := 0; i < 2; i++ {
+		sv := b.take()
+		sv.StreamID = 1
+		sv.StartTime = time.Now()
+		sv.BytesReceived = 100
+		sv.BytesSent = 200
+		sv.SampleSeed = 3
+	}
+	streamValuesIns.Reset(b)
+	if b.used != 0 {
+		t.Fatalf("stream values buffer is not reset, used %d", b.used)
+	}
+	// the values are reused, and nothing leaks from the previous streams
+	for i := 0; i < 2; i++ {
+		if sv := b.take(); *sv != (StreamValues{}) {
+			t.Fatalf("stream values is not reset: %+v", sv)
+		}
+	}
+	if len(b.values) != 2 {
+		t.Fatalf("stream values should be reused, but got %d", len(b.values))
+	}
+}
+
+const benchValueNum = 8
+
+type benchKey int
+
+func BenchmarkContextValueChain(b *testing.B) {
+	ctx := context.Background()
+	for i := 0; i < benchValueNum; i++ {
+		ctx = context.WithValue(ctx, benchKey(i), uint64(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for k := 0; k < benchValueNum; k++ {
+			_ = ctx.Value(benchKey(k)).(uint64)
+		}
+	}
+}
+
+func BenchmarkStreamValues(b *testing.B) {
+	ctx, sv := WithStreamValues(buffer.NewBufferPoolContext(context.Background()))
+	sv.StreamID = 1
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sv := StreamValuesByContext(ctx)
+		_ = sv.StreamID
+		_ = sv.StartTime
+		_ = sv.RequestReceivedTime
+		_ = sv.ResponseStartTime
+		_ = sv.BytesReceived
+		_ = sv.BytesSent
+		_ = sv.Policy
+		_ = sv.SampleSeed
+	}
+}
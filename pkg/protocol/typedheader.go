@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"bytes"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// TypedKey is the internal header kept in the typed field of TypedHeader
+type TypedKey uint8
+
+// the internal headers kept in the typed fields
+const (
+	TypedMethod TypedKey = iota
+	TypedHost
+	TypedAuthority
+	TypedPath
+	TypedQueryString
+	TypedStatus
+	typedKeyCount
+)
+
+var typedKeyNames = [typedKeyCount]string{
+	TypedMethod:      MosnHeaderMethod,
+	TypedHost:        MosnHeaderHostKey,
+	TypedAuthority:   IstioHeaderHostKey,
+	TypedPath:        MosnHeaderPathKey,
+	TypedQueryString: MosnHeaderQueryStringKey,
+	TypedStatus:      types.HeaderStatus,
+}
+
+// String returns the header key of the typed key
+func (k TypedKey) String() string {
+	return typedKeyNames[k]
+}
+
+// LookupTypedKey returns the typed key of the header key, it returns false if the header is not kept in the typed fields
+func LookupTypedKey(key string) (TypedKey, bool) {
+	switch key {
+	case MosnHeaderMethod:
+		return TypedMethod, true
+	case MosnHeaderHostKey:
+		return TypedHost, true
+	case IstioHeaderHostKey:
+		return TypedAuthority, true
+	case MosnHeaderPathKey:
+		return TypedPath, true
+	case MosnHeaderQueryStringKey:
+		return TypedQueryString, true
+	case types.HeaderStatus:
+		return TypedStatus, true
+	}
+	return 0, false
+}
+
+// IsTypedKey returns whether the header key is kept in the typed fields, the key is matched case-insensitively
+// as the keys of the header map may be normalized
+func IsTypedKey(key []byte) bool {
+	for _, name := range typedKeyNames {
+		if len(key) == len(name) && bytes.EqualFold(key, []byte(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// TypedHeader keeps the request line and the status of the protocol-converted messages in the typed fields.
+// It overlays the header of the protocol: the header reads and writes the internal headers like
+// MosnHeaderPathKey through the fields instead of its header map, so the stream layers can
+// set and take them without allocations. The zero value has no fields set.
+type TypedHeader struct {
+	values [typedKeyCount]string
+	set    uint8
+}
+
+// Value returns the value of the typed field, and whether it is set
+func (h *TypedHeader) Value(key TypedKey) (string, bool) {
+	return h.values[key], h.set&(1<<key) != 0
+}
+
+// SetValue sets the typed field
+func (h *TypedHeader) SetValue(key TypedKey, value string) {
+	h.values[key] = value
+	h.set |= 1 << key
+}
+
+// DelValue unsets the typed field
+func (h *TypedHeader) DelValue(key TypedKey) {
+	h.values[key] = ""
+	h.set &^= 1 << key
+}
+
+// Reset unsets all the typed fields
+func (h *TypedHeader) Reset() {
+	*h = TypedHeader{}
+}
+
+// Range calls f sequentially for each typed field set by the header key.
+// It returns false if f returns false and the iteration stops.
+func (h *TypedHeader) Range(f func(key, value string) bool) bool {
+	for key := TypedKey(0); key < typedKeyCount; key++ {
+		if h.set&(1<<key) != 0 && !f(typedKeyNames[key], h.values[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ByteSize returns the size of the header keys and the values of the typed fields set
+func (h *TypedHeader) ByteSize() (size uint64) {
+	for key := TypedKey(0); key < typedKeyCount; key++ {
+		if h.set&(1<<key) != 0 {
+			size += uint64(len(typedKeyNames[key]) + len(h.values[key]))
+		}
+	}
+	return size
+}
@@ -46,6 +46,9 @@ const (
 	MosnOriginalHeaderPathKey = "x-mosn-original-path"
 )
 
+// HeaderRequestID is the default header the request id is propagated by
+const HeaderRequestID = "x-request-id"
+
 // Hseader with special meaning in istio
 // todo maybe use ":authority"
 const (
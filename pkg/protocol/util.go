@@ -18,26 +18,48 @@
 package protocol
 
 import (
+	"os"
 	"strconv"
 	"sync/atomic"
+	"time"
 )
 
-var defaultGenerator IDGenerator
+const (
+	// the id is made up of the 16 bits salt of the process and the 48 bits counter
+	idCounterBits = 48
+	idCounterMask = 1<<idCounterBits - 1
+)
+
+var defaultGenerator = NewIDGenerator(processSalt())
+
+// processSalt derives the salt from the start time and the pid of the process,
+// so the process started by the hot upgrade does not reuse the ids of the old one
+func processSalt() uint16 {
+	return uint16(time.Now().UnixNano()/int64(time.Millisecond)) ^ uint16(os.Getpid())
+}
 
-// IDGenerator utility to generate auto-increment ids
+// IDGenerator utility to generate auto-increment ids, the ids of the generators with different salts do not collide.
+// The zero value generates the ids from 1 without salt.
 type IDGenerator struct {
+	salt    uint64
 	counter uint64
 }
 
+// NewIDGenerator returns the generator that puts the salt in the high 16 bits of the ids
+func NewIDGenerator(salt uint16) *IDGenerator {
+	return &IDGenerator{
+		salt: uint64(salt) << idCounterBits,
+	}
+}
+
 // Get get id
 func (g *IDGenerator) Get() uint64 {
-	return atomic.AddUint64(&g.counter, 1)
+	return g.salt | atomic.AddUint64(&g.counter, 1)&idCounterMask
 }
 
 // Get get id in string format
 func (g *IDGenerator) GetString() string {
-	n := atomic.AddUint64(&g.counter, 1)
-	return strconv.FormatUint(n, 10)
+	return strconv.FormatUint(g.Get(), 10)
 }
 
 // GenerateID get id by default global generator
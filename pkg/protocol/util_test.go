@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIDGenerator(t *testing.T) {
+	g := NewIDGenerator(0xabcd)
+	last := uint64(0)
+	for i := 0; i < 100; i++ {
+		id := g.Get()
+		if id>>idCounterBits != 0xabcd {
+			t.Fatalf("id %x is not salted", id)
+		}
+		if id <= last {
+			t.Fatalf("id %d is not greater than the last id %d", id, last)
+		}
+		last = id
+	}
+
+	// the generators of different processes do not collide
+	old := NewIDGenerator(1)
+	if old.Get() == NewIDGenerator(2).Get() {
+		t.Fatal("the ids of the generators with different salts should not collide")
+	}
+
+	// the zero value generates ids from 1
+	var zero IDGenerator
+	if id := zero.Get(); id != 1 {
+		t.Fatalf("expected id 1, but got %d", id)
+	}
+	if id := zero.GetString(); id != "2" {
+		t.Fatalf("expected id 2, but got %s", id)
+	}
+}
+
+func TestIDGeneratorConcurrent(t *testing.T) {
+	g := NewIDGenerator(processSalt())
+	ids := make([][]uint64, 8)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				ids[i] = append(ids[i], g.Get())
+			}
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[uint64]bool, 8000)
+	for _, list := range ids {
+		for _, id := range list {
+			if seen[id] {
+				t.Fatalf("id %d is generated twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+// BenchmarkAtomicCounter is the baseline of the id generator
+func BenchmarkAtomicCounter(b *testing.B) {
+	var counter uint64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			atomic.AddUint64(&counter, 1)
+		}
+	})
+}
+
+func BenchmarkGenerateID(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			GenerateID()
+		}
+	})
+}
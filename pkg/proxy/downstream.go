@@ -60,6 +60,8 @@ type downStream struct {
 	// ~~~ control args
 	timeout    Timeout
 	retryState *retryState
+	// triedHosts are the hosts failed the request, the retry chooses another host if it can
+	triedHosts []types.Host
 
 	requestInfo     types.RequestInfo
 	responseSender  types.StreamSender
@@ -92,6 +94,8 @@ type downStream struct {
 	directResponse bool
 	// oneway
 	oneway bool
+	// the concurrent streams of the connection overflow
+	overflow bool
 
 	notify chan struct{}
 
@@ -131,6 +135,15 @@ func newActiveStream(ctx context.Context, proxy *proxy, responseSender types.Str
 	stream.proxy = proxy
 	stream.requestInfo = &proxyBuffers.info
 	stream.requestInfo.SetStartTime()
+	stream.requestInfo.SetProtocol(stream.getDownstreamProtocol())
+	// the stream connections of some protocols do not store the logger
+	if mosnctx.Get(ctx, types.ContextKeyLogger) == nil {
+		streamID, ok := protocol.StreamIDByContext(ctx)
+		if !ok {
+			streamID = uint64(stream.ID)
+		}
+		ctx = log.ContextWithLogger(ctx, streamID)
+	}
 	stream.context = ctx
 	stream.reuseBuffer = 1
 	stream.notify = make(chan struct{}, 1)
@@ -149,7 +162,7 @@ func newActiveStream(ctx context.Context, proxy *proxy, responseSender types.Str
 
 	// info message for new downstream
 	if log.Proxy.GetLogLevel() >= log.INFO {
-		requestId := mosnctx.Get(stream.context, types.ContextKeyStreamID)
+		requestId, _ := protocol.StreamIDByContext(stream.context)
 		log.Proxy.Infof(stream.context, "[proxy] [downstream] new stream, proxyId = %d , requestId =%v, oneway=%t", stream.ID, requestId, stream.oneway)
 	}
 	return stream
@@ -292,6 +305,7 @@ func (s *downStream) OnReceive(ctx context.Context, headers types.HeaderMap, dat
 		data.Drain(data.Len())
 	}
 	s.downstreamReqTrailers = trailers
+	s.setRequestID(headers)
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] OnReceive headers:%+v, data:%+v, trailers:%+v", headers, data, trailers)
@@ -330,11 +344,50 @@ func (s *downStream) OnReceive(ctx context.Context, headers types.HeaderMap, dat
 	})
 }
 
+// setRequestID adopts the request id of the downstream request if the listener trusts it, otherwise generates one.
+// the request id is stored in the context, the request info and the logger, and is propagated to the upstream by the header.
+// It is only set if the listener configures the request id, and the protocol carries the header
+func (s *downStream) setRequestID(headers types.HeaderMap) {
+	if s.context == nil {
+		return
+	}
+	cfg, ok := mosnctx.Get(s.context, types.ContextKeyRequestIDConfig).(*v2.RequestIDConfig)
+	if !ok || cfg == nil || !carriesRequestID(s.getDownstreamProtocol()) {
+		return
+	}
+	header := protocol.HeaderRequestID
+	if cfg.Header != "" {
+		header = cfg.Header
+	}
+	trust := cfg.TrustDownstream
+	var id string
+	if trust && headers != nil {
+		if v, ok := headers.Get(header); ok && validRequestID(v) {
+			id = v
+		}
+	}
+	if id == "" {
+		id = utils.GenerateUUID()
+	}
+	if headers != nil {
+		headers.Set(header, id)
+	}
+	s.requestInfo.SetRequestID(id)
+	s.context = mosnctx.WithValue(s.context, types.ContextKeyRequestID, id)
+	log.SetRequestID(s.context, id)
+}
+
 func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase) types.Phase {
 	for i := 0; i <= int(types.End-types.InitPhase); i++ {
 		switch phase {
 		// init phase
 		case types.InitPhase:
+			if !s.rejectOverflow() {
+				s.detectLoop()
+			}
+			if p, err := s.processError(id); err != nil {
+				return p
+			}
 			phase++
 
 			// downstream filter before route
@@ -597,6 +650,32 @@ func (s *downStream) getUpstreamProtocol() (currentProtocol types.Protocol) {
 	return currentProtocol
 }
 
+// rejectOverflow rejects the request if the concurrent streams of the connection overflow,
+// the sofarpc responses SERVER_BUSY mapped by the overflow code
+func (s *downStream) rejectOverflow() bool {
+	if !s.overflow {
+		return false
+	}
+	log.Proxy.Warnf(s.context, "[proxy] [downstream] concurrent streams overflow, proxyId = %d", s.ID)
+	s.requestInfo.SetResponseFlag(types.DownstreamOverflow)
+	s.proxy.stats.DownstreamRequestOverflow.Inc(1)
+	s.proxy.listenerStats.DownstreamRequestOverflow.Inc(1)
+	s.sendHijackReply(types.UpstreamOverFlowCode, s.downstreamReqHeaders)
+	return true
+}
+
+// detectLoop rejects the request if it is proxied in a loop
+func (s *downStream) detectLoop() {
+	if s.downstreamReqHeaders == nil || !s.proxy.loopDetector.detect(s.downstreamReqHeaders) {
+		return
+	}
+	log.Proxy.Errorf(s.context, "[proxy] [downstream] loop detected, proxyId = %d, headers = %v", s.ID, s.downstreamReqHeaders)
+	s.requestInfo.SetResponseFlag(types.LoopDetected)
+	s.proxy.stats.DownstreamRequestLoop.Inc(1)
+	s.proxy.listenerStats.DownstreamRequestLoop.Inc(1)
+	s.sendHijackReply(types.LoopDetectedCode, s.downstreamReqHeaders)
+}
+
 func (s *downStream) receiveHeaders(endStream bool) {
 	s.downstreamRecvDone = endStream
 
@@ -626,6 +705,16 @@ func (s *downStream) receiveHeaders(endStream bool) {
 		return
 	}
 	if s.snapshot == nil || reflect.ValueOf(s.snapshot).IsNil() {
+		// the route references clusters not configured
+		if !s.route.RouteRule().ClusterConfigured() {
+			log.Proxy.Warnf(s.context, "[proxy] [downstream] cluster of the route is not configured, proxyId = %d", s.ID)
+			s.requestInfo.SetResponseFlag(types.NoClusterConfigured)
+			s.proxy.stats.DownstreamRequestNoCluster.Inc(1)
+			s.proxy.listenerStats.DownstreamRequestNoCluster.Inc(1)
+			s.downstreamReqHeaders.Set(types.HeaderLocalReply, types.LocalReplyNoClusterConfigured)
+			s.sendHijackReply(types.UpstreamOverFlowCode, s.downstreamReqHeaders)
+			return
+		}
 		// no available cluster
 		log.Proxy.Alertf(s.context, types.ErrorKeyClusterGet, " cluster snapshot is nil, cluster name is: %s", s.route.RouteRule().ClusterName())
 		s.requestInfo.SetResponseFlag(types.NoRouteFound)
@@ -640,12 +729,24 @@ func (s *downStream) receiveHeaders(endStream bool) {
 	}
 
 	s.cluster = s.snapshot.ClusterInfo()
+	// the conn pools choose the circuit breakers by the route's priority
+	priority := s.route.RouteRule().Priority()
+	s.context = mosnctx.WithValue(s.context, types.ContextKeyUpstreamPriority, priority)
 
 	s.requestInfo.SetRouteEntry(s.route.RouteRule())
 	s.requestInfo.SetDownstreamLocalAddress(s.proxy.readCallbacks.Connection().LocalAddr())
 	// todo: detect remote addr
 	s.requestInfo.SetDownstreamRemoteAddress(s.proxy.readCallbacks.Connection().RemoteAddr())
 
+	s.route.RouteRule().FinalizeRequestHeaders(s.downstreamReqHeaders, s.requestInfo)
+	// the host of the request is the default server name of the upstream tls connections,
+	// the connection pools are chosen by the server name
+	if tlsMng := s.cluster.TLSMng(); tlsMng != nil && tlsMng.Enabled() {
+		if host, ok := s.downstreamReqHeaders.Get(protocol.MosnHeaderHostKey); ok && host != "" {
+			s.context = mosnctx.WithValue(s.context, types.ContextKeyUpstreamServerName, parseServerName(host))
+		}
+	}
+
 	pool, err := s.initializeUpstreamConnectionPool(s)
 	if err != nil {
 		log.Proxy.Alertf(s.context, types.ErrorKeyUpstreamConn, "initialize Upstream Connection Pool error, request can't be proxyed, error = %v", err)
@@ -654,14 +755,14 @@ func (s *downStream) receiveHeaders(endStream bool) {
 		return
 	}
 
-	parseProxyTimeout(&s.timeout, s.route, s.downstreamReqHeaders)
+	parseProxyTimeout(&s.timeout, s.route, s.downstreamReqHeaders, s.proxy.defaultTimeout())
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] timeout info: %+v", s.timeout)
 	}
 
 	prot := s.getUpstreamProtocol()
 
-	s.retryState = newRetryState(s.route.RouteRule().Policy().RetryPolicy(), s.downstreamReqHeaders, s.cluster, prot)
+	s.retryState = newRetryState(s.route.RouteRule().Policy().RetryPolicy(), s.downstreamReqHeaders, s.cluster, priority, prot)
 
 	//Build Request
 	proxyBuffers := proxyBuffersByContext(s.context)
@@ -670,7 +771,6 @@ func (s *downStream) receiveHeaders(endStream bool) {
 	s.upstreamRequest.proxy = s.proxy
 	s.upstreamRequest.protocol = prot
 	s.upstreamRequest.connPool = pool
-	s.route.RouteRule().FinalizeRequestHeaders(s.downstreamReqHeaders, s.requestInfo)
 
 	//Call upstream's append header method to build upstream's request
 	s.upstreamRequest.appendHeaders(endStream)
@@ -779,11 +879,19 @@ func (s *downStream) onResponseTimeout() {
 			log.Proxy.Errorf(s.context, "[proxy] [downstream] onResponseTimeout() panic %v\n%s", r, string(debug.Stack()))
 		}
 	}()
+	if s.downstreamResponseStarted {
+		// the response headers are in flight, pass the response through
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(s.context, "[proxy] [downstream] skip request timeout on getting upstream response")
+		}
+		return
+	}
 	s.cluster.Stats().UpstreamRequestTimeout.Inc(1)
 
 	if s.upstreamRequest != nil {
 		if s.upstreamRequest.host != nil {
 			s.upstreamRequest.host.HostStats().UpstreamRequestTimeout.Inc(1)
+			s.upstreamRequest.host.ReportResult(types.HostResultTimeout)
 
 			log.Proxy.Errorf(s.context, "[proxy] [downstream] onResponseTimeout，host: %s, time: %s",
 				s.upstreamRequest.host.AddressString(), s.timeout.GlobalTimeout.String())
@@ -833,6 +941,7 @@ func (s *downStream) onPerReqTimeout() {
 
 		if s.upstreamRequest.host != nil {
 			s.upstreamRequest.host.HostStats().UpstreamRequestTimeout.Inc(1)
+			s.upstreamRequest.host.ReportResult(types.HostResultTimeout)
 		}
 
 		s.upstreamRequest.resetStream()
@@ -862,11 +971,16 @@ func (s *downStream) initializeUpstreamConnectionPool(lbCtx types.LoadBalancerCo
 // ~~~ active stream sender wrapper
 
 func (s *downStream) appendHeaders(endStream bool) {
+	headers, err := s.convertHeader(s.downstreamRespHeaders)
+	if err != nil {
+		s.convertFailed()
+		return
+	}
 	s.upstreamProcessDone = endStream
-	headers := s.convertHeader(s.downstreamRespHeaders)
 	//Currently, just log the error
 	if err := s.responseSender.AppendHeaders(s.context, headers, endStream); err != nil {
 		log.Proxy.Alertf(s.context, types.ErrorKeyAppendHeader, "append headers error: %s", err)
+		s.onResponseSendError(err)
 	}
 
 	if endStream {
@@ -874,77 +988,106 @@ func (s *downStream) appendHeaders(endStream bool) {
 	}
 }
 
-func (s *downStream) convertHeader(headers types.HeaderMap) types.HeaderMap {
+func (s *downStream) convertHeader(headers types.HeaderMap) (types.HeaderMap, error) {
 	if s.noConvert {
-		return headers
+		return headers, nil
 	}
 
 	dp, up := s.convertProtocol()
 
 	// need protocol convert
 	if dp != up {
-		if convHeader, err := protocol.ConvertHeader(s.context, up, dp, headers); err == nil {
-			return convHeader
-		} else {
-			log.Proxy.Warnf(s.context, "[proxy] [downstream] convert header from %s to %s failed, %s", up, dp, err.Error())
+		convHeader, err := protocol.ConvertHeader(s.context, up, dp, headers)
+		if err != nil {
+			log.Proxy.Alertf(s.context, types.ErrorKeyProtocolConv, "convert header from %s to %s failed, %s", up, dp, err.Error())
 		}
+		return convHeader, err
 	}
-	return headers
+	return headers, nil
 }
 
 func (s *downStream) appendData(endStream bool) {
+	data, err := s.convertData(s.downstreamRespDataBuf)
+	if err != nil {
+		s.convertFailed()
+		return
+	}
 	s.upstreamProcessDone = endStream
 
-	data := s.convertData(s.downstreamRespDataBuf)
 	s.requestInfo.SetBytesSent(s.requestInfo.BytesSent() + uint64(data.Len()))
-	s.responseSender.AppendData(s.context, data, endStream)
+	if err := s.responseSender.AppendData(s.context, data, endStream); err != nil {
+		s.onResponseSendError(err)
+	}
 
 	if endStream {
 		s.endStream()
 	}
 }
 
-func (s *downStream) convertData(data types.IoBuffer) types.IoBuffer {
+func (s *downStream) convertData(data types.IoBuffer) (types.IoBuffer, error) {
 	if s.noConvert {
-		return data
+		return data, nil
 	}
 
 	dp, up := s.convertProtocol()
 
 	// need protocol convert
 	if dp != up {
-		if convData, err := protocol.ConvertData(s.context, up, dp, data); err == nil {
-			return convData
-		} else {
-			log.Proxy.Warnf(s.context, "[proxy] [downstream] convert data from %s to %s failed, %s", up, dp, err.Error())
+		convData, err := protocol.ConvertData(s.context, up, dp, data)
+		if err != nil {
+			log.Proxy.Alertf(s.context, types.ErrorKeyProtocolConv, "convert data from %s to %s failed, %s", up, dp, err.Error())
 		}
+		return convData, err
 	}
-	return data
+	return data, nil
 }
 
 func (s *downStream) appendTrailers() {
+	trailers, err := s.convertTrailer(s.downstreamRespTrailers)
+	if err != nil {
+		s.convertFailed()
+		return
+	}
 	s.upstreamProcessDone = true
-	trailers := s.convertTrailer(s.downstreamRespTrailers)
-	s.responseSender.AppendTrailers(s.context, trailers)
+	if err := s.responseSender.AppendTrailers(s.context, trailers); err != nil {
+		s.onResponseSendError(err)
+	}
 	s.endStream()
 }
 
-func (s *downStream) convertTrailer(trailers types.HeaderMap) types.HeaderMap {
+// onResponseSendError records the response that is not written to downstream completely
+func (s *downStream) onResponseSendError(err error) {
+	if err != types.ErrWriteResponse {
+		return
+	}
+	s.requestInfo.SetResponseFlag(types.DownstreamResponseWriteError)
+	s.proxy.stats.DownstreamResponseWriteError.Inc(1)
+	s.proxy.listenerStats.DownstreamResponseWriteError.Inc(1)
+}
+
+func (s *downStream) convertTrailer(trailers types.HeaderMap) (types.HeaderMap, error) {
 	if s.noConvert {
-		return trailers
+		return trailers, nil
 	}
 
 	dp, up := s.convertProtocol()
 
 	// need protocol convert
 	if dp != up {
-		if convTrailer, err := protocol.ConvertTrailer(s.context, up, dp, trailers); err == nil {
-			return convTrailer
-		} else {
-			log.Proxy.Warnf(s.context, "[proxy] [downstream] convert header from %s to %s failed, %s", up, dp, err.Error())
+		convTrailer, err := protocol.ConvertTrailer(s.context, up, dp, trailers)
+		if err != nil {
+			log.Proxy.Alertf(s.context, types.ErrorKeyProtocolConv, "convert trailer from %s to %s failed, %s", up, dp, err.Error())
 		}
+		return convTrailer, err
 	}
-	return trailers
+	return trailers, nil
+}
+
+// convertFailed resets the downstream stream if the response can not be converted to the downstream protocol,
+// sending the unconverted response would corrupt the downstream connection
+func (s *downStream) convertFailed() {
+	s.requestInfo.SetResponseFlag(types.ProtocolConvertFailed)
+	s.resetStream(types.StreamProtocolConvertFailed)
 }
 
 // ~~~ upstream event handler
@@ -977,7 +1120,7 @@ func (s *downStream) onUpstreamReset(reason types.StreamResetReason) {
 	// If we have not yet sent anything downstream, send a response with an appropriate status code.
 	// Otherwise just reset the ongoing response.
 	if s.downstreamResponseStarted {
-		s.resetStream()
+		s.resetStream(types.StreamLocalReset)
 	} else {
 		// send err response if response not started
 		var code int
@@ -1100,6 +1243,9 @@ func (s *downStream) onUpstreamResponseRecvFinished() {
 
 func (s *downStream) setupRetry(endStream bool) bool {
 	s.upstreamRequest.setupRetry = true
+	if s.upstreamRequest.host != nil {
+		s.triedHosts = append(s.triedHosts, s.upstreamRequest.host)
+	}
 
 	if !endStream {
 		s.upstreamRequest.resetStream()
@@ -1158,14 +1304,14 @@ func (s *downStream) doRetry() {
 // Downstream got reset in proxy context on scenario below:
 // 1. downstream filter reset downstream
 // 2. corresponding upstream got reset
-func (s *downStream) resetStream() {
+func (s *downStream) resetStream(reason types.StreamResetReason) {
 	if s.responseSender != nil && !s.upstreamProcessDone {
 		// if downstream req received not done, or local proxy process not done by handle upstream response,
 		// just mark it as done and reset stream as a failed case
 		s.upstreamProcessDone = true
 
 		// reset downstream will trigger a clean up, see OnResetStream
-		s.responseSender.GetStream().ResetStream(types.StreamLocalReset)
+		s.responseSender.GetStream().ResetStream(reason)
 	}
 }
 
@@ -1180,6 +1326,8 @@ func (s *downStream) sendHijackReply(code int, headers types.HeaderMap) {
 
 	headers.Set(types.HeaderStatus, strconv.Itoa(code))
 	atomic.StoreUint32(&s.reuseBuffer, 0)
+	// the hijack reply is built from the downstream request, it is in the downstream protocol already
+	s.noConvert = true
 	s.downstreamRespHeaders = headers
 	s.downstreamRespDataBuf = nil
 	s.downstreamRespTrailers = nil
@@ -1198,6 +1346,7 @@ func (s *downStream) sendHijackReplyWithBody(code int, headers types.HeaderMap,
 	s.requestInfo.SetResponseCode(code)
 	headers.Set(types.HeaderStatus, strconv.Itoa(code))
 	atomic.StoreUint32(&s.reuseBuffer, 0)
+	s.noConvert = true
 	s.downstreamRespHeaders = headers
 	s.downstreamRespDataBuf = buffer.NewIoBufferString(body)
 	s.downstreamRespTrailers = nil
@@ -1272,6 +1421,23 @@ func (s *downStream) DownstreamContext() context.Context {
 	return s.context
 }
 
+func (s *downStream) HashKey() (uint64, bool) {
+	route := s.requestInfo.RouteEntry()
+	if route == nil || route.Policy() == nil || route.Policy().HashPolicy() == nil {
+		return 0, false
+	}
+	return route.Policy().HashPolicy().GenerateHash(s.downstreamReqHeaders, s.proxy.readCallbacks.Connection().RemoteAddr())
+}
+
+func (s *downStream) ShouldSelectAnotherHost(host types.Host) bool {
+	for _, h := range s.triedHosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *downStream) giveStream() {
 	if atomic.LoadUint32(&s.reuseBuffer) != 1 {
 		return
@@ -18,14 +18,20 @@
 package proxy
 
 import (
+	"container/list"
 	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	gometrics "github.com/rcrowley/go-metrics"
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
 	"sofastack.io/sofa-mosn/pkg/trace"
 	"sofastack.io/sofa-mosn/pkg/types"
 
@@ -132,6 +138,7 @@ func TestDirectResponse(t *testing.T) {
 					},
 				},
 				clusterManager: &mockClusterManager{},
+				loopDetector:   newLoopDetector(nil),
 				readCallbacks:  &mockReadFilterCallbacks{},
 				stats:          globalStats,
 				listenerStats:  newListenerStats("test"),
@@ -154,6 +161,7 @@ func TestOnewayHijack(t *testing.T) {
 		config:         &v2.Proxy{},
 		routersWrapper: nil,
 		clusterManager: &mockClusterManager{},
+		loopDetector:   newLoopDetector(nil),
 		readCallbacks:  &mockReadFilterCallbacks{},
 		stats:          globalStats,
 		listenerStats:  newListenerStats("test"),
@@ -168,3 +176,359 @@ func TestOnewayHijack(t *testing.T) {
 		t.Errorf("downStream should be cleaned")
 	}
 }
+
+func TestResponseWriteError(t *testing.T) {
+	initGlobalStats()
+	client := &mockResponseSender{
+		err: types.ErrWriteResponse,
+	}
+	s := &downStream{
+		proxy: &proxy{
+			config: &v2.Proxy{},
+			routersWrapper: &mockRouterWrapper{
+				routers: &mockRouters{
+					route: &mockRoute{
+						direct: &mockDirectRule{
+							status: 200,
+							body:   "mock response",
+						},
+					},
+				},
+			},
+			clusterManager: &mockClusterManager{},
+			loopDetector:   newLoopDetector(nil),
+			readCallbacks:  &mockReadFilterCallbacks{},
+			stats:          globalStats,
+			listenerStats:  newListenerStats("test_response_write_error"),
+		},
+		responseSender: client,
+		requestInfo:    &network.RequestInfo{},
+	}
+	before := s.proxy.stats.DownstreamResponseWriteError.Count()
+	s.OnReceive(context.Background(), protocol.CommonHeader{}, buffer.NewIoBuffer(1), nil)
+	time.Sleep(100 * time.Millisecond)
+	if client.data == nil {
+		t.Fatal("want to receive a body response")
+	}
+	if !s.requestInfo.GetResponseFlag(types.DownstreamResponseWriteError) {
+		t.Error("response write error flag is not set")
+	}
+	if n := s.proxy.stats.DownstreamResponseWriteError.Count() - before; n != 1 {
+		t.Errorf("response write error count expected 1, but got %d", n)
+	}
+	if n := s.proxy.listenerStats.DownstreamResponseWriteError.Count(); n != 1 {
+		t.Errorf("listener response write error count expected 1, but got %d", n)
+	}
+}
+
+type mockTimeoutRouteRule struct {
+	mockRouteRule
+	timeout    time.Duration
+	tryTimeout time.Duration
+}
+
+func (r *mockTimeoutRouteRule) GlobalTimeout() time.Duration {
+	return r.timeout
+}
+
+func (r *mockTimeoutRouteRule) Policy() types.Policy {
+	return r
+}
+
+func (r *mockTimeoutRouteRule) RetryPolicy() types.RetryPolicy {
+	return r
+}
+
+func (r *mockTimeoutRouteRule) ShadowPolicy() types.ShadowPolicy {
+	return nil
+}
+
+func (r *mockTimeoutRouteRule) HashPolicy() types.HashPolicy {
+	return nil
+}
+
+func (r *mockTimeoutRouteRule) RetryOn() bool {
+	return false
+}
+
+func (r *mockTimeoutRouteRule) TryTimeout() time.Duration {
+	return r.tryTimeout
+}
+
+func (r *mockTimeoutRouteRule) NumRetries() uint32 {
+	return 0
+}
+
+func (r *mockTimeoutRouteRule) RetryOnConditions() types.RetryCondition {
+	return 0
+}
+
+func (r *mockTimeoutRouteRule) RetriableStatusCodes() []uint32 {
+	return nil
+}
+
+func TestParseProxyTimeout(t *testing.T) {
+	testCases := []struct {
+		rule           *mockTimeoutRouteRule
+		headers        protocol.CommonHeader
+		defaultTimeout time.Duration
+		expected       Timeout
+	}{
+		// route timeout
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second, tryTimeout: time.Second},
+			headers:  protocol.CommonHeader{},
+			expected: Timeout{GlobalTimeout: 3 * time.Second, TryTimeout: time.Second},
+		},
+		// zero inherits the listener default
+		{
+			rule:           &mockTimeoutRouteRule{},
+			headers:        protocol.CommonHeader{},
+			defaultTimeout: 5 * time.Second,
+			expected:       Timeout{GlobalTimeout: 5 * time.Second},
+		},
+		{
+			rule:     &mockTimeoutRouteRule{},
+			headers:  protocol.CommonHeader{},
+			expected: Timeout{GlobalTimeout: types.GlobalTimeout},
+		},
+		// the request tightens the timeout
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second, tryTimeout: time.Second},
+			headers:  protocol.CommonHeader{types.HeaderUpstreamTimeout: "500"},
+			expected: Timeout{GlobalTimeout: 500 * time.Millisecond},
+		},
+		// capped by the route's timeout
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second},
+			headers:  protocol.CommonHeader{types.HeaderUpstreamTimeout: "10000"},
+			expected: Timeout{GlobalTimeout: 3 * time.Second},
+		},
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second},
+			headers:  protocol.CommonHeader{types.HeaderUpstreamTimeout: "invalid"},
+			expected: Timeout{GlobalTimeout: 3 * time.Second},
+		},
+		// the grpc deadline tightens the route's timeout
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second, tryTimeout: time.Second},
+			headers:  protocol.CommonHeader{mhttp2.GrpcTimeout: "5S"},
+			expected: Timeout{GlobalTimeout: 3 * time.Second, TryTimeout: time.Second},
+		},
+		{
+			rule:           &mockTimeoutRouteRule{},
+			headers:        protocol.CommonHeader{mhttp2.GrpcTimeout: "5S"},
+			defaultTimeout: 10 * time.Second,
+			expected:       Timeout{GlobalTimeout: 5 * time.Second},
+		},
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second},
+			headers:  protocol.CommonHeader{mhttp2.GrpcTimeout: "200m"},
+			expected: Timeout{GlobalTimeout: 200 * time.Millisecond},
+		},
+		{
+			rule:     &mockTimeoutRouteRule{timeout: 3 * time.Second},
+			headers:  protocol.CommonHeader{mhttp2.GrpcTimeout: "1x"},
+			expected: Timeout{GlobalTimeout: 3 * time.Second},
+		},
+	}
+	for i, tc := range testCases {
+		timeout := Timeout{}
+		parseProxyTimeout(&timeout, &mockRoute{rule: tc.rule}, tc.headers, tc.defaultTimeout)
+		if timeout != tc.expected {
+			t.Errorf("#%d expected timeout %+v, but got %+v", i, tc.expected, timeout)
+		}
+		if _, ok := tc.headers.Get(types.HeaderUpstreamTimeout); ok {
+			t.Errorf("#%d expected the upstream timeout header removed", i)
+		}
+	}
+}
+
+func TestResponseTimeoutPassThrough(t *testing.T) {
+	info := &fakeStatsClusterInfo{
+		stats: types.ClusterStats{
+			UpstreamRequestTimeout: gometrics.NewCounter(),
+		},
+	}
+	// the response headers are in flight
+	s := &downStream{
+		cluster:                   info,
+		downstreamResponseStarted: true,
+		upstreamRequest:           &upstreamRequest{},
+	}
+	s.onResponseTimeout()
+	if info.stats.UpstreamRequestTimeout.Count() != 0 {
+		t.Errorf("response in flight should be passed through")
+	}
+	s = &downStream{
+		cluster: info,
+	}
+	s.onResponseTimeout()
+	if info.stats.UpstreamRequestTimeout.Count() != 1 {
+		t.Errorf("request timeout should be counted")
+	}
+}
+
+func TestStreamOverflow(t *testing.T) {
+	initGlobalStats()
+	max := uint32(1)
+	p := &proxy{
+		config:               &v2.Proxy{},
+		clusterManager:       &mockClusterManager{},
+		loopDetector:         newLoopDetector(nil),
+		readCallbacks:        &mockReadFilterCallbacks{},
+		stats:                globalStats,
+		listenerStats:        newListenerStats("test_stream_overflow"),
+		activeSteams:         list.New(),
+		context:              context.Background(),
+		maxConcurrentStreams: &max,
+	}
+	if s := p.NewStreamDetect(context.Background(), nil, nil).(*downStream); s.overflow {
+		t.Error("the first stream should not overflow")
+	}
+	if s := p.NewStreamDetect(context.Background(), nil, nil).(*downStream); !s.overflow {
+		t.Error("the second stream should overflow")
+	}
+	// the limit is updated by the listener
+	atomic.StoreUint32(&max, 0)
+	if s := p.NewStreamDetect(context.Background(), nil, nil).(*downStream); s.overflow {
+		t.Error("the stream should not overflow without limit")
+	}
+
+	client := &mockResponseSender{}
+	s := &downStream{
+		proxy:          p,
+		responseSender: client,
+		requestInfo:    &network.RequestInfo{},
+		overflow:       true,
+	}
+	s.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil)
+	time.Sleep(100 * time.Millisecond)
+	if client.headers == nil {
+		t.Fatal("want to receive an overflow response")
+	}
+	if code, _ := client.headers.Get(types.HeaderStatus); code != strconv.Itoa(types.UpstreamOverFlowCode) {
+		t.Errorf("overflow response code is not expected: %s", code)
+	}
+	if !s.requestInfo.GetResponseFlag(types.DownstreamOverflow) {
+		t.Error("downstream overflow flag is not set")
+	}
+	if n := p.listenerStats.DownstreamRequestOverflow.Count(); n != 1 {
+		t.Errorf("listener request overflow count expected 1, but got %d", n)
+	}
+}
+
+func TestDownstreamConvertFailed(t *testing.T) {
+	initGlobalStats()
+	client := &mockResponseSender{}
+	s := &downStream{
+		context: context.Background(),
+		proxy: &proxy{
+			// no converter between the protocols
+			config: &v2.Proxy{DownstreamProtocol: "mockDownstream", UpstreamProtocol: "mockUpstream"},
+		},
+		responseSender:        client,
+		requestInfo:           &network.RequestInfo{},
+		downstreamRespHeaders: protocol.CommonHeader{},
+	}
+	s.appendHeaders(true)
+	if client.headers != nil {
+		t.Fatal("the unconverted headers should not be sent to the downstream")
+	}
+	if !s.requestInfo.GetResponseFlag(types.ProtocolConvertFailed) {
+		t.Error("protocol convert failed flag is not set")
+	}
+	if !s.upstreamProcessDone {
+		t.Error("the downstream stream should be reset")
+	}
+
+	// the hijack reply is in the downstream protocol, no conversion is needed
+	s = &downStream{
+		context: context.Background(),
+		proxy: &proxy{
+			config: &v2.Proxy{DownstreamProtocol: "mockDownstream", UpstreamProtocol: "mockUpstream"},
+		},
+		responseSender: client,
+		requestInfo:    &network.RequestInfo{},
+	}
+	s.sendHijackReply(types.NoHealthUpstreamCode, protocol.CommonHeader{})
+	s.appendHeaders(false)
+	if client.headers == nil {
+		t.Fatal("the hijack reply should be sent to the downstream")
+	}
+}
+
+func TestSetRequestID(t *testing.T) {
+	newStream := func(cfg *v2.RequestIDConfig, prot types.Protocol) *downStream {
+		ctx := mosnctx.WithValue(context.Background(), types.ContextKeyConnectionID, uint64(1))
+		if cfg != nil {
+			ctx = mosnctx.WithValue(ctx, types.ContextKeyRequestIDConfig, cfg)
+		}
+		return &downStream{
+			context:     ctx,
+			proxy:       &proxy{config: &v2.Proxy{DownstreamProtocol: string(prot)}},
+			requestInfo: &network.RequestInfo{},
+		}
+	}
+	check := func(s *downStream, headers types.HeaderMap, header string) string {
+		t.Helper()
+		id, _ := headers.Get(header)
+		if id == "" || s.requestInfo.RequestID() != id || mosnctx.Get(s.context, types.ContextKeyRequestID) != id {
+			t.Fatalf("request id is not propagated: header %s, request info %s, context %v",
+				id, s.requestInfo.RequestID(), mosnctx.Get(s.context, types.ContextKeyRequestID))
+		}
+		return id
+	}
+
+	// the request id is not set unless the listener configures it
+	s := newStream(nil, protocol.HTTP1)
+	headers := protocol.CommonHeader{}
+	s.setRequestID(headers)
+	if _, ok := headers.Get(protocol.HeaderRequestID); ok || s.requestInfo.RequestID() != "" {
+		t.Fatal("expected no request id without the config")
+	}
+
+	// the downstream request id is regenerated if it is not trusted
+	s = newStream(&v2.RequestIDConfig{}, protocol.HTTP1)
+	headers = protocol.CommonHeader{protocol.HeaderRequestID: "downstream"}
+	s.setRequestID(headers)
+	if id := check(s, headers, protocol.HeaderRequestID); id == "downstream" {
+		t.Fatal("expected the request id regenerated")
+	}
+
+	// the protocols not carrying the header are skipped
+	s = newStream(&v2.RequestIDConfig{}, protocol.SofaRPC)
+	headers = protocol.CommonHeader{}
+	s.setRequestID(headers)
+	if _, ok := headers.Get(protocol.HeaderRequestID); ok {
+		t.Fatal("expected no request id set on the sofarpc request")
+	}
+
+	// the trusted request id is adopted
+	cfg := &v2.RequestIDConfig{
+		Header:          "x-trace-request",
+		TrustDownstream: true,
+	}
+	s = newStream(cfg, protocol.HTTP2)
+	headers = protocol.CommonHeader{"x-trace-request": "downstream"}
+	s.setRequestID(headers)
+	if id := check(s, headers, "x-trace-request"); id != "downstream" {
+		t.Fatalf("expected the downstream request id adopted, but got %s", id)
+	}
+
+	// the invalid request id is regenerated even if it is trusted
+	for _, invalid := range []string{"", "a b", `a"b`, "%s%d%n", strings.Repeat("a", maxRequestIDLength+1)} {
+		s = newStream(cfg, protocol.HTTP1)
+		headers = protocol.CommonHeader{"x-trace-request": invalid}
+		s.setRequestID(headers)
+		if id := check(s, headers, "x-trace-request"); id == invalid {
+			t.Fatalf("expected the invalid request id %q regenerated", invalid)
+		}
+	}
+
+	// the request ids are generated for the requests without the header
+	s = newStream(cfg, protocol.HTTP1)
+	headers = protocol.CommonHeader{}
+	s.setRequestID(headers)
+	check(s, headers, "x-trace-request")
+}
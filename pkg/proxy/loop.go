@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	// defaultMaxHops is the hop limit used if the proxy does not configure one
+	defaultMaxHops = 32
+	// maxInstances bounds the instance ids carried by a request
+	maxInstances = 16
+)
+
+// loopDetector rejects requests that are proxied in a loop, by the hop count the
+// stream increases on ingress and by the instance ids the request has passed through.
+type loopDetector struct {
+	maxHops        uint64
+	instanceID     string
+	externalEgress bool
+}
+
+func newLoopDetector(config *v2.LoopDetection) *loopDetector {
+	d := &loopDetector{
+		maxHops: defaultMaxHops,
+	}
+	if config == nil {
+		return d
+	}
+	if config.MaxHops > 0 {
+		d.maxHops = uint64(config.MaxHops)
+	}
+	if config.InstanceCheck {
+		d.instanceID = localInstanceID(config.InstanceID)
+	}
+	d.externalEgress = config.ExternalEgress
+	return d
+}
+
+// localInstanceID returns the configured id, or the service node, or the hostname
+func localInstanceID(id string) string {
+	if id != "" {
+		return id
+	}
+	if node := types.GetGlobalXdsInfo().ServiceNode; node != "" {
+		return node
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// detect returns true if the request is in a loop. Otherwise the local instance id
+// is recorded in the request, and the loop detection headers are removed if the
+// requests leave the mesh from here.
+func (d *loopDetector) detect(headers types.HeaderMap) bool {
+	if value, ok := headers.Get(types.HeaderHops); ok {
+		// an unparsable hop count is exhausted
+		if hops, err := strconv.ParseUint(value, 10, 64); err != nil || hops > d.maxHops {
+			return true
+		}
+	}
+
+	if d.instanceID != "" {
+		var instances []string
+		if value, ok := headers.Get(types.HeaderInstance); ok && value != "" {
+			instances = strings.Split(value, ",")
+			for _, instance := range instances {
+				if strings.TrimSpace(instance) == d.instanceID {
+					return true
+				}
+			}
+		}
+		instances = append(instances, d.instanceID)
+		if len(instances) > maxInstances {
+			instances = instances[len(instances)-maxInstances:]
+		}
+		headers.Set(types.HeaderInstance, strings.Join(instances, ","))
+	}
+
+	if d.externalEgress {
+		headers.Del(types.HeaderHops)
+		headers.Del(types.HeaderInstance)
+	}
+	return false
+}
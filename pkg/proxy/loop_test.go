@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestLoopDetectorHops(t *testing.T) {
+	d := newLoopDetector(nil)
+	for _, tc := range []struct {
+		hops string
+		loop bool
+	}{
+		{"", false},
+		{"32", false},
+		{"33", true},
+		{"invalid", true},
+		{"99999999999999999999999", true},
+	} {
+		headers := protocol.CommonHeader{}
+		if tc.hops != "" {
+			headers.Set(types.HeaderHops, tc.hops)
+		}
+		if loop := d.detect(headers); loop != tc.loop {
+			t.Errorf("hops %s expected loop %v, but got %v", tc.hops, tc.loop, loop)
+		}
+	}
+
+	d = newLoopDetector(&v2.LoopDetection{MaxHops: 2})
+	if !d.detect(protocol.CommonHeader{types.HeaderHops: "3"}) {
+		t.Error("hops over the configured limit should be a loop")
+	}
+}
+
+func TestLoopDetectorInstance(t *testing.T) {
+	d := newLoopDetector(&v2.LoopDetection{
+		InstanceCheck: true,
+		InstanceID:    "mosn-a",
+	})
+	headers := protocol.CommonHeader{types.HeaderInstance: "mosn-b"}
+	if d.detect(headers) {
+		t.Fatal("request from other instance should not be a loop")
+	}
+	if v, _ := headers.Get(types.HeaderInstance); v != "mosn-b,mosn-a" {
+		t.Fatalf("expected local instance appended, but got %s", v)
+	}
+	if !d.detect(headers) {
+		t.Fatal("request passed the instance should be a loop")
+	}
+
+	// the instance list is bounded
+	var instances []string
+	for i := 0; i < maxInstances; i++ {
+		instances = append(instances, fmt.Sprintf("mosn-%d", i))
+	}
+	headers = protocol.CommonHeader{types.HeaderInstance: strings.Join(instances, ",")}
+	if d.detect(headers) {
+		t.Fatal("request from other instances should not be a loop")
+	}
+	v, _ := headers.Get(types.HeaderInstance)
+	if got := strings.Split(v, ","); len(got) != maxInstances || got[0] != "mosn-1" || got[maxInstances-1] != "mosn-a" {
+		t.Fatalf("unexpected instance list: %s", v)
+	}
+
+	// the instance check is disabled by default
+	if newLoopDetector(nil).detect(protocol.CommonHeader{types.HeaderInstance: "mosn-a"}) {
+		t.Fatal("instance should not be checked by default")
+	}
+}
+
+func TestLoopDetectorExternalEgress(t *testing.T) {
+	d := newLoopDetector(&v2.LoopDetection{
+		InstanceCheck:  true,
+		InstanceID:     "mosn-a",
+		ExternalEgress: true,
+	})
+	headers := protocol.CommonHeader{
+		types.HeaderHops:     "3",
+		types.HeaderInstance: "mosn-b",
+	}
+	if d.detect(headers) {
+		t.Fatal("request should not be a loop")
+	}
+	if _, ok := headers.Get(types.HeaderHops); ok {
+		t.Error("hops header should be stripped at external egress")
+	}
+	if _, ok := headers.Get(types.HeaderInstance); ok {
+		t.Error("instance header should be stripped at external egress")
+	}
+}
@@ -91,6 +91,10 @@ func (r *mockRouteRule) UpstreamProtocol() string {
 	return ""
 }
 
+func (r *mockRouteRule) ClusterConfigured() bool {
+	return true
+}
+
 func (c *mockRouteRule) FinalizeResponseHeaders(headers types.HeaderMap, requestInfo types.RequestInfo) {
 	return
 }
@@ -127,15 +131,23 @@ type mockResponseSender struct {
 	headers  types.HeaderMap
 	data     types.IoBuffer
 	trailers types.HeaderMap
+	// returned when the stream ends
+	err error
 }
 
 func (s *mockResponseSender) AppendHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) error {
 	s.headers = headers
+	if endStream {
+		return s.err
+	}
 	return nil
 }
 
 func (s *mockResponseSender) AppendData(ctx context.Context, data types.IoBuffer, endStream bool) error {
 	s.data = data
+	if endStream {
+		return s.err
+	}
 	return nil
 }
 
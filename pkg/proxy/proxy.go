@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
@@ -88,6 +89,13 @@ type proxy struct {
 	stats              *Stats
 	listenerStats      *Stats
 	accessLogs         []types.AccessLog
+	loopDetector       *loopDetector
+	// maxConcurrentStreams is the listener's limit of the concurrent streams of a connection, loaded atomically
+	maxConcurrentStreams *uint32
+	// draining is 1 if the connection is closed once the active streams are done, accessed atomically
+	draining uint32
+	// upstreamReadDisabled is 1 while the write buffer of the downstream connection is backed up, accessed atomically
+	upstreamReadDisabled uint32
 }
 
 // NewProxy create proxy instance for given v2.Proxy config
@@ -99,6 +107,7 @@ func NewProxy(ctx context.Context, config *v2.Proxy, clusterManager types.Cluste
 		stats:          globalStats,
 		context:        ctx,
 		accessLogs:     mosnctx.Get(ctx, types.ContextKeyAccessLogs).([]types.AccessLog),
+		loopDetector:   newLoopDetector(config.LoopDetection),
 	}
 
 	extJSON, err := json.Marshal(proxy.config.ExtendConfig)
@@ -108,12 +117,18 @@ func NewProxy(ctx context.Context, config *v2.Proxy, clusterManager types.Cluste
 		json.Unmarshal([]byte(extJSON), &xProxyExtendConfig)
 		proxy.context = mosnctx.WithValue(proxy.context, types.ContextSubProtocol, xProxyExtendConfig.SubProtocol)
 		log.DefaultLogger.Tracef("[proxy] extend config subprotocol = %v", xProxyExtendConfig.SubProtocol)
+		var sofaRPCExtendConfig v2.SofaRPCExtendConfig
+		json.Unmarshal([]byte(extJSON), &sofaRPCExtendConfig)
+		proxy.context = mosnctx.WithValue(proxy.context, types.ContextKeySofaRPCExtendConfig, sofaRPCExtendConfig)
 	} else {
 		log.DefaultLogger.Errorf("[proxy] get proxy extend config fail = %v", err)
 	}
 
 	listenerName := mosnctx.Get(ctx, types.ContextKeyListenerName).(string)
 	proxy.listenerStats = newListenerStats(listenerName)
+	if max, ok := mosnctx.Get(ctx, types.ContextKeyMaxConcurrentStreams).(*uint32); ok {
+		proxy.maxConcurrentStreams = max
+	}
 
 	if routersWrapper := router.GetRoutersMangerInstance().GetRouterWrapperByName(proxy.config.RouterConfigName); routersWrapper != nil {
 		proxy.routersWrapper = routersWrapper
@@ -161,8 +176,6 @@ func (p *proxy) onDownstreamEvent(event types.ConnectionEvent) {
 	if event.IsClose() {
 		p.stats.DownstreamConnectionDestroy.Inc(1)
 		p.stats.DownstreamConnectionActive.Dec(1)
-		p.listenerStats.DownstreamConnectionDestroy.Inc(1)
-		p.listenerStats.DownstreamConnectionActive.Dec(1)
 		var urEleNext *list.Element
 
 		p.asMux.RLock()
@@ -177,12 +190,28 @@ func (p *proxy) onDownstreamEvent(event types.ConnectionEvent) {
 	}
 }
 
+// ReadDisableUpstream stops reading the upstream streams while the downstream connection is backed up,
+// the streams connected later are disabled once they are ready
 func (p *proxy) ReadDisableUpstream(disable bool) {
-	// TODO
+	if disable {
+		atomic.StoreUint32(&p.upstreamReadDisabled, 1)
+	} else {
+		atomic.StoreUint32(&p.upstreamReadDisabled, 0)
+	}
+
+	p.asMux.RLock()
+	defer p.asMux.RUnlock()
+
+	for ele := p.activeSteams.Front(); ele != nil; ele = ele.Next() {
+		if r := ele.Value.(*downStream).upstreamRequest; r != nil {
+			r.syncReadDisable()
+		}
+	}
 }
 
+// ReadDisableDownstream stops reading the downstream connection while the upstream connection is backed up
 func (p *proxy) ReadDisableDownstream(disable bool) {
-	// TODO
+	p.readCallbacks.Connection().SetReadDisable(disable)
 }
 
 func (p *proxy) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
@@ -193,17 +222,79 @@ func (p *proxy) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
 
 	p.stats.DownstreamConnectionTotal.Inc(1)
 	p.stats.DownstreamConnectionActive.Inc(1)
-	p.listenerStats.DownstreamConnectionTotal.Inc(1)
-	p.listenerStats.DownstreamConnectionActive.Inc(1)
+	// the listener's connections are counted by the listener
 
 	p.readCallbacks.Connection().AddConnectionEventListener(p.downstreamListener)
 	if p.config.DownstreamProtocol != string(protocol.Auto) {
 		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, types.Protocol(p.config.DownstreamProtocol), p.readCallbacks.Connection(), p)
+	} else if prot, ok := mosnctx.Get(p.context, types.ContextKeyDownstreamProtocol).(types.Protocol); ok {
+		// the connection transferred from the old mosn keeps its protocol, the protocol is not sniffed again
+		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, prot, p.readCallbacks.Connection(), p)
 	}
 }
 
+// DownstreamProtocol returns the protocol of the stream connection, it is transferred with the connection in the hot upgrade
+func (p *proxy) DownstreamProtocol() types.Protocol {
+	if p.serverStreamConn == nil {
+		return ""
+	}
+	return p.serverStreamConn.Protocol()
+}
+
 func (p *proxy) OnGoAway() {}
 
+// Drain makes the stream connection go away, and closes the connection if there is no active stream.
+// Otherwise the connection is closed by the last active stream
+func (p *proxy) Drain() {
+	if !atomic.CompareAndSwapUint32(&p.draining, 0, 1) {
+		return
+	}
+	if p.serverStreamConn != nil {
+		p.serverStreamConn.GoAway()
+	}
+	p.asMux.RLock()
+	active := p.activeSteams.Len()
+	p.asMux.RUnlock()
+	if active == 0 {
+		p.readCallbacks.Connection().Close(types.FlushWrite, types.LocalClose)
+	}
+}
+
+// MaxRequestBytes returns the max request body size of the route matched by the headers.
+// The headers are not processed by stream filters yet, so the limit follows the original request
+func (p *proxy) MaxRequestBytes(headers types.HeaderMap) uint64 {
+	if p.routersWrapper == nil {
+		return 0
+	}
+	routers := p.routersWrapper.GetRouters()
+	if routers == nil {
+		return 0
+	}
+	route := routers.MatchRoute(headers, 1)
+	if route == nil || route.RouteRule() == nil {
+		return 0
+	}
+	return route.RouteRule().MaxRequestBytes()
+}
+
+// defaultTimeout returns the upstream timeout of the routes without a timeout, zero means the global default
+func (p *proxy) defaultTimeout() time.Duration {
+	if p.config == nil || p.config.Timeout == nil {
+		return 0
+	}
+	return p.config.Timeout.Duration
+}
+
+func (p *proxy) OnRequestBodyTooLarge(declared bool) {
+	if declared {
+		p.stats.DownstreamBodyTooLarge.Inc(1)
+		p.listenerStats.DownstreamBodyTooLarge.Inc(1)
+	} else {
+		p.stats.DownstreamBodyOverrun.Inc(1)
+		p.listenerStats.DownstreamBodyOverrun.Inc(1)
+	}
+}
+
 func (p *proxy) NewStreamDetect(ctx context.Context, responseSender types.StreamSender, span types.Span) types.StreamReceiveListener {
 	stream := newActiveStream(ctx, p, responseSender, span)
 
@@ -224,11 +315,23 @@ func (p *proxy) NewStreamDetect(ctx context.Context, responseSender types.Stream
 
 	p.asMux.Lock()
 	stream.element = p.activeSteams.PushBack(stream)
+	active := p.activeSteams.Len()
 	p.asMux.Unlock()
+	// the stream is rejected in the init phase, so the response is sent in the protocol of the downstream
+	stream.overflow = p.streamOverflow(active)
 
 	return stream
 }
 
+// streamOverflow returns true if the active streams of the connection exceed the listener's max concurrent streams
+func (p *proxy) streamOverflow(active int) bool {
+	if p.maxConcurrentStreams == nil {
+		return false
+	}
+	max := atomic.LoadUint32(p.maxConcurrentStreams)
+	return max > 0 && active > int(max)
+}
+
 func (p *proxy) OnNewConnection() types.FilterStatus {
 	return types.Continue
 }
@@ -245,6 +348,8 @@ func (p *proxy) streamResetReasonToResponseFlag(reason types.StreamResetReason)
 		return types.UpstreamOverflow
 	case types.StreamRemoteReset:
 		return types.UpstreamRemoteReset
+	case types.StreamProtocolConvertFailed:
+		return types.ProtocolConvertFailed
 	}
 
 	return 0
@@ -254,7 +359,11 @@ func (p *proxy) deleteActiveStream(s *downStream) {
 	if s.element != nil {
 		p.asMux.Lock()
 		p.activeSteams.Remove(s.element)
+		drained := p.activeSteams.Len() == 0 && atomic.LoadUint32(&p.draining) == 1
 		p.asMux.Unlock()
+		if drained {
+			p.readCallbacks.Connection().Close(types.FlushWrite, types.LocalClose)
+		}
 	}
 }
 
@@ -264,5 +373,12 @@ type downstreamCallbacks struct {
 }
 
 func (dc *downstreamCallbacks) OnEvent(event types.ConnectionEvent) {
-	dc.proxy.onDownstreamEvent(event)
+	switch event {
+	case types.OnAboveWriteBufferHighWatermark:
+		dc.proxy.ReadDisableUpstream(true)
+	case types.OnBelowWriteBufferLowWatermark:
+		dc.proxy.ReadDisableUpstream(false)
+	default:
+		dc.proxy.onDownstreamEvent(event)
+	}
 }
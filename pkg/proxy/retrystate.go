@@ -23,30 +23,48 @@ import (
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
+// defaultRetryOnConditions are the conditions used if the retry is on and the conditions are not configured
+const defaultRetryOnConditions = types.RetryOn5xx | types.RetryOnConnectFailure | types.RetryOnReset
+
+// defaultNumRetries is the max retries if the retry policy does not configure it
+const defaultNumRetries = 3
+
 type retryState struct {
 	retryPolicy      types.RetryPolicy
 	requestHeaders   types.HeaderMap // TODO: support retry policy by header
 	cluster          types.ClusterInfo
-	retryOn          bool
+	resourceManager  types.ResourceManager // the cluster's resource manager of the route's priority
+	retryOn          types.RetryCondition
 	retiesRemaining  uint32
 	upstreamProtocol types.Protocol
+	// retrying is true if a retry is counted in the cluster's retries resource
+	retrying bool
 }
 
 func newRetryState(retryPolicy types.RetryPolicy,
-	requestHeaders types.HeaderMap, cluster types.ClusterInfo, proto types.Protocol) *retryState {
+	requestHeaders types.HeaderMap, cluster types.ClusterInfo, priority types.ResourcePriority, proto types.Protocol) *retryState {
 	rs := &retryState{
 		retryPolicy:      retryPolicy,
 		requestHeaders:   requestHeaders,
 		cluster:          cluster,
-		retryOn:          retryPolicy.RetryOn(),
-		retiesRemaining:  3,
+		resourceManager:  cluster.ResourceManagerByPriority(priority),
+		retiesRemaining:  defaultNumRetries,
 		upstreamProtocol: proto,
 	}
 
-	if retryPolicy.NumRetries() > rs.retiesRemaining {
+	if retryPolicy.NumRetries() > 0 {
 		rs.retiesRemaining = retryPolicy.NumRetries()
 	}
 
+	// default support connectionFailed retry
+	rs.retryOn = types.RetryOnConnectFailure
+	if retryPolicy.RetryOn() {
+		rs.retryOn = retryPolicy.RetryOnConditions()
+		if rs.retryOn == 0 {
+			rs.retryOn = defaultRetryOnConditions
+		}
+	}
+
 	return rs
 }
 
@@ -59,7 +77,8 @@ func (r *retryState) retry(headers types.HeaderMap, reason types.StreamResetReas
 		return check
 	}
 
-	r.cluster.ResourceManager().Retries().Increase()
+	r.resourceManager.Retries().Increase()
+	r.retrying = true
 	r.cluster.Stats().UpstreamRequestRetry.Inc(1)
 
 	return 0
@@ -76,7 +95,7 @@ func (r *retryState) shouldRetry(headers types.HeaderMap, reason types.StreamRes
 		return types.NoRetry
 	}
 
-	if !r.cluster.ResourceManager().Retries().CanCreate() {
+	if !r.resourceManager.Retries().CanCreate() {
 		r.cluster.Stats().UpstreamRequestRetryOverflow.Inc(1)
 
 		return types.RetryOverflow
@@ -90,38 +109,41 @@ func (r *retryState) doRetryCheck(headers types.HeaderMap, reason types.StreamRe
 		return false
 	}
 
-	if r.retryOn {
-		// TODO: add retry policy to decide retry or not. use default policy now
-		if headers != nil {
-			// default policy , mapping all headers to http status code
-			code, err := protocol.MappingHeaderStatusCode(r.upstreamProtocol, headers)
-			if err == nil {
-				// todo: support config?
-				return code >= http.InternalServerError
-			}
+	if headers != nil {
+		// mapping all headers to http status code
+		code, err := protocol.MappingHeaderStatusCode(r.upstreamProtocol, headers)
+		if err != nil {
+			return false
 		}
-		if reason == types.StreamConnectionFailed {
+		if r.retryOn&types.RetryOn5xx != 0 && code >= http.InternalServerError {
 			return true
 		}
-
-		if reason == types.UpstreamPerTryTimeout {
-			return true
+		if r.retryOn&types.RetryOnRetriableStatusCodes != 0 {
+			for _, c := range r.retryPolicy.RetriableStatusCodes() {
+				if int(c) == code {
+					return true
+				}
+			}
 		}
+		return false
+	}
 
-		if reason == types.StreamConnectionTermination {
-			return true
-		}
-		// more policy
-	} else {
-		// default support connectionFailed retry
-		if reason == types.StreamConnectionFailed {
-			return true
-		}
+	switch reason {
+	case types.StreamConnectionFailed:
+		return r.retryOn&types.RetryOnConnectFailure != 0
+	case types.UpstreamPerTryTimeout:
+		return r.retryOn&types.RetryOn5xx != 0
+	case types.StreamConnectionTermination, types.StreamRemoteReset:
+		return r.retryOn&types.RetryOnReset != 0
 	}
 
 	return false
 }
 
+// reset releases the retry counted in the cluster's retries resource
 func (r *retryState) reset() {
-	r.cluster.ResourceManager().Retries().Decrease()
+	if r.retrying {
+		r.retrying = false
+		r.resourceManager.Retries().Decrease()
+	}
 }
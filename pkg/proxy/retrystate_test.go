@@ -38,6 +38,10 @@ type fakeClusterInfo struct {
 func (ci *fakeClusterInfo) ResourceManager() types.ResourceManager {
 	return ci.mgr
 }
+
+func (ci *fakeClusterInfo) ResourceManagerByPriority(priority types.ResourcePriority) types.ResourceManager {
+	return ci.mgr
+}
 func (ci *fakeClusterInfo) Stats() types.ClusterStats {
 	return types.ClusterStats{
 		UpstreamRequestRetryOverflow: metrics.NewCounter(),
@@ -78,7 +82,7 @@ func TestRetryState(t *testing.T) {
 	clusterInfo := &fakeClusterInfo{
 		mgr: &fakeResourceManager{},
 	}
-	rs := newRetryState(policy, nil, clusterInfo, protocol.HTTP1)
+	rs := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
 	headerException := protocol.CommonHeader{
 		types.HeaderStatus: "500",
 	}
@@ -117,7 +121,7 @@ func TestRetryConnetionFailed(t *testing.T) {
 	clusterInfo := &fakeClusterInfo{
 		mgr: &fakeResourceManager{},
 	}
-	rs := newRetryState(policy, nil, clusterInfo, protocol.HTTP1)
+	rs := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
 	testcases := []struct {
 		Header   types.HeaderMap
 		Reason   types.StreamResetReason
@@ -131,3 +135,101 @@ func TestRetryConnetionFailed(t *testing.T) {
 		}
 	}
 }
+
+type countingResourceManager struct {
+	types.ResourceManager
+	retries *countingResource
+}
+
+func (mgr *countingResourceManager) Retries() types.Resource {
+	return mgr.retries
+}
+
+type countingResource struct {
+	current int64
+	max     int64
+}
+
+func (r *countingResource) CanCreate() bool {
+	return r.current < r.max
+}
+func (r *countingResource) Increase()   { r.current++ }
+func (r *countingResource) Decrease()   { r.current-- }
+func (r *countingResource) Max() uint64 { return uint64(r.max) }
+
+func TestRetryOnConditions(t *testing.T) {
+	rcfg := &v2.Router{}
+	rcfg.Route = v2.RouteAction{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			NumRetries:           10,
+			RetryOnConditions:    []string{v2.RetryOnReset, v2.RetryOnRetriableStatusCodes},
+			RetriableStatusCodes: []uint32{429},
+		},
+	}
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	rs := newRetryState(r.Policy().RetryPolicy(), nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	testcases := []struct {
+		Header   types.HeaderMap
+		Reason   types.StreamResetReason
+		Expected types.RetryCheckStatus
+	}{
+		{protocol.CommonHeader{types.HeaderStatus: "429"}, "", types.ShouldRetry},
+		{protocol.CommonHeader{types.HeaderStatus: "503"}, "", types.NoRetry},
+		{nil, types.StreamRemoteReset, types.ShouldRetry},
+		{nil, types.StreamConnectionTermination, types.ShouldRetry},
+		{nil, types.StreamConnectionFailed, types.NoRetry},
+		{nil, types.UpstreamPerTryTimeout, types.NoRetry},
+	}
+	for i, tc := range testcases {
+		if rs.retry(tc.Header, tc.Reason) != tc.Expected {
+			t.Errorf("#%d retry state failed", i)
+		}
+	}
+}
+
+func TestRetryNumAndBudget(t *testing.T) {
+	rcfg := &v2.Router{}
+	rcfg.Route = v2.RouteAction{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryOn:    true,
+			NumRetries: 1,
+		},
+	}
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	policy := r.Policy().RetryPolicy()
+	retries := &countingResource{max: 1}
+	clusterInfo := &fakeClusterInfo{
+		mgr: &countingResourceManager{retries: retries},
+	}
+	// the num retries is honored
+	rs := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	if rs.retry(nil, types.StreamConnectionFailed) != types.ShouldRetry || retries.current != 1 {
+		t.Fatalf("the first retry failed, retries: %d", retries.current)
+	}
+	if rs.retry(nil, types.StreamConnectionFailed) != types.NoRetry || retries.current != 0 {
+		t.Fatalf("the retries are exhausted, retries: %d", retries.current)
+	}
+	// the retry is released only once
+	rs.reset()
+	if retries.current != 0 {
+		t.Fatalf("the retry is released twice, retries: %d", retries.current)
+	}
+	// the budget is exhausted by another request
+	another := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	if another.retry(nil, types.StreamConnectionFailed) != types.ShouldRetry {
+		t.Fatal("the retry failed")
+	}
+	rs = newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	if rs.retry(nil, types.StreamConnectionFailed) != types.RetryOverflow {
+		t.Fatal("the retry should overflow")
+	}
+	another.reset()
+	if retries.current != 0 {
+		t.Fatalf("unexpected retries: %d", retries.current)
+	}
+}
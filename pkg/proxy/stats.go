@@ -24,18 +24,24 @@ import (
 )
 
 type Stats struct {
-	DownstreamConnectionTotal   gometrics.Counter
-	DownstreamConnectionDestroy gometrics.Counter
-	DownstreamConnectionActive  gometrics.Counter
-	DownstreamBytesReadTotal    gometrics.Counter
-	DownstreamBytesWriteTotal   gometrics.Counter
-	DownstreamRequestTotal      gometrics.Counter
-	DownstreamRequestActive     gometrics.Counter
-	DownstreamRequestReset      gometrics.Counter
-	DownstreamRequestTime       gometrics.Histogram
-	DownstreamRequestTimeTotal  gometrics.Counter
-	DownstreamProcessTime       gometrics.Histogram
-	DownstreamProcessTimeTotal  gometrics.Counter
+	DownstreamConnectionTotal    gometrics.Counter
+	DownstreamConnectionDestroy  gometrics.Counter
+	DownstreamConnectionActive   gometrics.Counter
+	DownstreamBytesReadTotal     gometrics.Counter
+	DownstreamBytesWriteTotal    gometrics.Counter
+	DownstreamRequestTotal       gometrics.Counter
+	DownstreamRequestActive      gometrics.Counter
+	DownstreamRequestReset       gometrics.Counter
+	DownstreamRequestTime        gometrics.Histogram
+	DownstreamRequestTimeTotal   gometrics.Counter
+	DownstreamProcessTime        gometrics.Histogram
+	DownstreamProcessTimeTotal   gometrics.Counter
+	DownstreamResponseWriteError gometrics.Counter
+	DownstreamRequestLoop        gometrics.Counter
+	DownstreamRequestNoCluster   gometrics.Counter
+	DownstreamBodyTooLarge       gometrics.Counter
+	DownstreamBodyOverrun        gometrics.Counter
+	DownstreamRequestOverflow    gometrics.Counter
 }
 
 func newListenerStats(listenerName string) *Stats {
@@ -49,17 +55,23 @@ func newProxyStats(proxyName string) *Stats {
 
 func newStats(s types.Metrics) *Stats {
 	return &Stats{
-		DownstreamConnectionTotal:   s.Counter(metrics.DownstreamConnectionTotal),
-		DownstreamConnectionDestroy: s.Counter(metrics.DownstreamConnectionDestroy),
-		DownstreamConnectionActive:  s.Counter(metrics.DownstreamConnectionActive),
-		DownstreamBytesReadTotal:    s.Counter(metrics.DownstreamBytesReadTotal),
-		DownstreamBytesWriteTotal:   s.Counter(metrics.DownstreamBytesWriteTotal),
-		DownstreamRequestTotal:      s.Counter(metrics.DownstreamRequestTotal),
-		DownstreamRequestActive:     s.Counter(metrics.DownstreamRequestActive),
-		DownstreamRequestReset:      s.Counter(metrics.DownstreamRequestReset),
-		DownstreamRequestTime:       s.Histogram(metrics.DownstreamRequestTime),
-		DownstreamRequestTimeTotal:  s.Counter(metrics.DownstreamRequestTimeTotal),
-		DownstreamProcessTime:       s.Histogram(metrics.DownstreamProcessTime),
-		DownstreamProcessTimeTotal:  s.Counter(metrics.DownstreamProcessTimeTotal),
+		DownstreamConnectionTotal:    s.Counter(metrics.DownstreamConnectionTotal),
+		DownstreamConnectionDestroy:  s.Counter(metrics.DownstreamConnectionDestroy),
+		DownstreamConnectionActive:   s.Counter(metrics.DownstreamConnectionActive),
+		DownstreamBytesReadTotal:     s.Counter(metrics.DownstreamBytesReadTotal),
+		DownstreamBytesWriteTotal:    s.Counter(metrics.DownstreamBytesWriteTotal),
+		DownstreamRequestTotal:       s.Counter(metrics.DownstreamRequestTotal),
+		DownstreamRequestActive:      s.Counter(metrics.DownstreamRequestActive),
+		DownstreamRequestReset:       s.Counter(metrics.DownstreamRequestReset),
+		DownstreamRequestTime:        s.Histogram(metrics.DownstreamRequestTime),
+		DownstreamRequestTimeTotal:   s.Counter(metrics.DownstreamRequestTimeTotal),
+		DownstreamProcessTime:        s.Histogram(metrics.DownstreamProcessTime),
+		DownstreamProcessTimeTotal:   s.Counter(metrics.DownstreamProcessTimeTotal),
+		DownstreamResponseWriteError: s.Counter(metrics.DownstreamResponseWriteError),
+		DownstreamRequestLoop:        s.Counter(metrics.DownstreamRequestLoop),
+		DownstreamRequestNoCluster:   s.Counter(metrics.DownstreamRequestNoCluster),
+		DownstreamBodyTooLarge:       s.Counter(metrics.DownstreamBodyTooLarge),
+		DownstreamBodyOverrun:        s.Counter(metrics.DownstreamBodyOverrun),
+		DownstreamRequestOverflow:    s.Counter(metrics.DownstreamRequestOverflow),
 	}
 }
@@ -94,6 +94,7 @@ func TestRunReiverFilters(t *testing.T) {
 			proxy: &proxy{
 				routersWrapper: &mockRouterWrapper{},
 				clusterManager: &mockClusterManager{},
+				loopDetector:   newLoopDetector(nil),
 			},
 			requestInfo: &network.RequestInfo{},
 			notify:      make(chan struct{}, 1),
@@ -141,6 +142,7 @@ func TestRunReiverFiltersStop(t *testing.T) {
 		proxy: &proxy{
 			routersWrapper: &mockRouterWrapper{},
 			clusterManager: &mockClusterManager{},
+			loopDetector:   newLoopDetector(nil),
 		},
 		requestInfo: &network.RequestInfo{},
 		notify:      make(chan struct{}, 1),
@@ -199,6 +201,7 @@ func TestRunSenderFilters(t *testing.T) {
 			proxy: &proxy{
 				routersWrapper: &mockRouterWrapper{},
 				clusterManager: &mockClusterManager{},
+				loopDetector:   newLoopDetector(nil),
 			},
 		}
 		for _, f := range tc.filters {
@@ -238,6 +241,7 @@ func TestRunSenderFiltersStop(t *testing.T) {
 		proxy: &proxy{
 			routersWrapper: &mockRouterWrapper{},
 			clusterManager: &mockClusterManager{},
+			loopDetector:   newLoopDetector(nil),
 		},
 	}
 	for _, f := range tc.filters {
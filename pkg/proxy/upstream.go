@@ -20,12 +20,17 @@ package proxy
 import (
 	"container/list"
 	"context"
+	"sync"
 	"time"
 
 	"sync/atomic"
 
+	"google.golang.org/grpc/codes"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -39,6 +44,21 @@ type upstreamRequest struct {
 	host          types.Host
 	requestSender types.StreamSender
 	connPool      types.ConnectionPool
+	// cancel the stream waiting for the connection, protected by mux
+	cancel types.Cancellable
+	// canceled is set once the request is reset, the stream created after it is canceled, protected by mux
+	canceled bool
+	// protects the request sending, OnReady may be called in the connection pool's goroutine
+	mux sync.Mutex
+
+	// flowMux protects the read disable state, the watermarks are notified in the goroutines of the connections.
+	// It is taken after mux, and nothing is written to the connections while holding it
+	flowMux sync.Mutex
+	// stream is the http1 upstream stream, the other protocols share the upstream connection among
+	// the downstream connections, so they are not disabled. It is nil once the stream is released
+	stream                 types.Stream
+	upstreamReadDisabled   bool
+	downstreamReadDisabled bool
 
 	// ~~~ upstream response buf
 	upstreamRespHeaders types.HeaderMap
@@ -63,15 +83,84 @@ type upstreamRequest struct {
 // 4. on upstream response receive error
 // 5. before a retry
 func (r *upstreamRequest) resetStream() {
+	r.mux.Lock()
+	cancel := r.cancel
+	r.cancel = nil
+	r.canceled = true
+	r.mux.Unlock()
+	// cancel first, the pool will not call OnReady after Cancel returns.
+	// The pool holds its lock while calling OnReady, so Cancel is called without holding mux
+	if cancel != nil {
+		cancel.Cancel()
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.releaseStream()
 	if r.requestSender != nil {
 		r.requestSender.GetStream().RemoveEventListener(r)
 		r.requestSender.GetStream().ResetStream(types.StreamLocalReset)
 	}
 }
 
+// syncReadDisable disables or enables reading the upstream stream by the write buffer of the downstream connection
+func (r *upstreamRequest) syncReadDisable() {
+	r.flowMux.Lock()
+	defer r.flowMux.Unlock()
+
+	disable := r.stream != nil && atomic.LoadUint32(&r.proxy.upstreamReadDisabled) == 1
+	if disable != r.upstreamReadDisabled {
+		r.upstreamReadDisabled = disable
+		r.stream.ReadDisable(disable)
+	}
+}
+
+// releaseStream enables reading the connections disabled by the stream, the upstream connection
+// is reused by the other streams, and the downstream connection is read for the next request
+func (r *upstreamRequest) releaseStream() {
+	r.flowMux.Lock()
+	defer r.flowMux.Unlock()
+
+	if r.stream == nil {
+		return
+	}
+	if r.upstreamReadDisabled {
+		r.upstreamReadDisabled = false
+		r.stream.ReadDisable(false)
+	}
+	if r.downstreamReadDisabled {
+		r.downstreamReadDisabled = false
+		r.downStream.responseSender.GetStream().ReadDisable(false)
+	}
+	r.stream = nil
+}
+
+// types.StreamWatermarkListener
+// the downstream is not read while the request is not sent to the upstream
+func (r *upstreamRequest) OnAboveWriteBufferHighWatermark() {
+	r.readDisableDownstream(true)
+}
+
+func (r *upstreamRequest) OnBelowWriteBufferLowWatermark() {
+	r.readDisableDownstream(false)
+}
+
+func (r *upstreamRequest) readDisableDownstream(disable bool) {
+	r.flowMux.Lock()
+	defer r.flowMux.Unlock()
+
+	if r.stream == nil || r.downStream.responseSender == nil || disable == r.downstreamReadDisabled {
+		return
+	}
+	r.downstreamReadDisabled = disable
+	r.downStream.responseSender.GetStream().ReadDisable(disable)
+}
+
 // types.StreamEventListener
 // Called by stream layer normally
 func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
+	r.releaseStream()
 	if r.setupRetry {
 		return
 	}
@@ -87,18 +176,70 @@ func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
 func (r *upstreamRequest) OnDestroyStream() {}
 
 func (r *upstreamRequest) endStream() {
-	upstreamResponseDurationNs := time.Now().Sub(r.startTime).Nanoseconds()
-	r.host.HostStats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
-	r.host.HostStats().UpstreamRequestDurationTotal.Inc(upstreamResponseDurationNs)
-	r.host.ClusterInfo().Stats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
-	r.host.ClusterInfo().Stats().UpstreamRequestDurationTotal.Inc(upstreamResponseDurationNs)
+	upstreamResponseDuration := time.Now().Sub(r.startTime)
+	r.host.HostStats().UpstreamRequestDuration.Update(upstreamResponseDuration)
+	r.host.ClusterInfo().Stats().UpstreamRequestDuration.Update(upstreamResponseDuration)
 
 	// todo: record upstream process time in request info
 }
 
+// recordResponseCode counts the upstream response by the code class,
+// and by the request method if the cluster's method stats is enabled
+func (r *upstreamRequest) recordResponseCode(code int) {
+	info := r.host.ClusterInfo()
+	stats := info.Stats()
+	switch code / 100 {
+	case 2:
+		stats.UpstreamResponseCode2xx.Inc(1)
+	case 3:
+		stats.UpstreamResponseCode3xx.Inc(1)
+	case 4:
+		stats.UpstreamResponseCode4xx.Inc(1)
+	case 5:
+		stats.UpstreamResponseCode5xx.Inc(1)
+	}
+	if !info.MethodStats() || r.downStream.downstreamReqHeaders == nil {
+		return
+	}
+	if method, ok := r.downStream.downstreamReqHeaders.Get(types.HeaderMethod); ok && method != "" {
+		// the methods are sent by the downstream, the unknown ones share a counter to bound the stats
+		if _, ok := statsMethods[method]; !ok {
+			method = metrics.UpstreamResponseMethodOther
+		}
+		metrics.NewClusterStats(info.Name()).Counter(metrics.UpstreamResponseMethodPrefix + method).Inc(1)
+	}
+}
+
+// statsMethods are the request methods counted by their own stats
+var statsMethods = map[string]struct{}{
+	"GET":     {},
+	"HEAD":    {},
+	"POST":    {},
+	"PUT":     {},
+	"PATCH":   {},
+	"DELETE":  {},
+	"CONNECT": {},
+	"OPTIONS": {},
+	"TRACE":   {},
+}
+
+// recordGrpcStatus counts the upstream grpc response by the class of its grpc-status
+func (r *upstreamRequest) recordGrpcStatus(code codes.Code) {
+	stats := r.host.ClusterInfo().Stats()
+	switch mhttp2.MappingToHttpStatus(code) / 100 {
+	case 2:
+		stats.UpstreamResponseGrpcOK.Inc(1)
+	case 4:
+		stats.UpstreamResponseGrpc4xx.Inc(1)
+	default:
+		stats.UpstreamResponseGrpc5xx.Inc(1)
+	}
+}
+
 // types.StreamReceiveListener
 // Method to decode upstream's response message
 func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	r.releaseStream()
 	if r.downStream.processDone() || r.setupRetry {
 		return
 	}
@@ -107,6 +248,16 @@ func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap
 
 	if code, err := protocol.MappingHeaderStatusCode(r.protocol, headers); err == nil {
 		r.downStream.requestInfo.SetResponseCode(code)
+		r.recordResponseCode(code)
+		// the host is ejected by the outlier detector if it responds 5xx too many times
+		if code >= http.InternalServerError {
+			r.host.ReportResult(types.HostResult5xx)
+		} else {
+			r.host.ReportResult(types.HostResultSuccess)
+		}
+	}
+	if code, ok := mhttp2.GetGrpcStatus(headers, trailers); ok {
+		r.recordGrpcStatus(code)
 	}
 
 	r.downStream.requestInfo.SetResponseReceivedDuration(time.Now())
@@ -163,31 +314,48 @@ func (r *upstreamRequest) appendHeaders(endStream bool) {
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] append headers: %+v", r.downStream.downstreamReqHeaders)
 	}
+	r.mux.Lock()
 	r.sendComplete = endStream
+	r.mux.Unlock()
 
+	// the pool may call OnReady before NewStream returns, so do not hold the lock here
+	var cancel types.Cancellable
 	if r.downStream.oneway {
-		r.connPool.NewStream(r.downStream.context, nil, r)
+		cancel = r.connPool.NewStream(r.downStream.context, nil, r)
 	} else {
-		r.connPool.NewStream(r.downStream.context, r, r)
+		cancel = r.connPool.NewStream(r.downStream.context, r, r)
+	}
+	if cancel == nil {
+		return
+	}
+	r.mux.Lock()
+	canceled := r.canceled
+	if !canceled {
+		r.cancel = cancel
+	}
+	r.mux.Unlock()
+	// the request is reset while the stream is created
+	if canceled {
+		cancel.Cancel()
 	}
 }
 
-func (r *upstreamRequest) convertHeader(headers types.HeaderMap) types.HeaderMap {
+func (r *upstreamRequest) convertHeader(headers types.HeaderMap) (types.HeaderMap, error) {
 	if r.downStream.noConvert {
-		return headers
+		return headers, nil
 	}
 
 	dp, up := r.downStream.convertProtocol()
 
 	// need protocol convert
 	if dp != up {
-		if convHeader, err := protocol.ConvertHeader(r.downStream.context, dp, up, headers); err == nil {
-			return convHeader
-		} else {
-			log.Proxy.Warnf(r.downStream.context, "[proxy] [upstream] convert header from %s to %s failed, %s", dp, up, err.Error())
+		convHeader, err := protocol.ConvertHeader(r.downStream.context, dp, up, headers)
+		if err != nil {
+			log.Proxy.Alertf(r.downStream.context, types.ErrorKeyProtocolConv, "convert header from %s to %s failed, %s", dp, up, err.Error())
 		}
+		return convHeader, err
 	}
-	return headers
+	return headers, nil
 }
 
 func (r *upstreamRequest) appendData(endStream bool) {
@@ -198,28 +366,39 @@ func (r *upstreamRequest) appendData(endStream bool) {
 		log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] append data:% +v", r.downStream.downstreamReqDataBuf)
 	}
 
-	data := r.downStream.downstreamReqDataBuf
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
 	r.sendComplete = endStream
 	r.dataSent = true
-	r.requestSender.AppendData(r.downStream.context, r.convertData(data), endStream)
+	// the stream is waiting for the connection, data will be sent in OnReady
+	if r.requestSender == nil {
+		return
+	}
+	data, err := r.convertData(r.downStream.downstreamReqDataBuf)
+	if err != nil {
+		r.convertFailed()
+		return
+	}
+	r.requestSender.AppendData(r.downStream.context, data, endStream)
 }
 
-func (r *upstreamRequest) convertData(data types.IoBuffer) types.IoBuffer {
+func (r *upstreamRequest) convertData(data types.IoBuffer) (types.IoBuffer, error) {
 	if r.downStream.noConvert {
-		return data
+		return data, nil
 	}
 
 	dp, up := r.downStream.convertProtocol()
 
 	// need protocol convert
 	if dp != up {
-		if convData, err := protocol.ConvertData(r.downStream.context, dp, up, data); err == nil {
-			return convData
-		} else {
-			log.Proxy.Warnf(r.downStream.context, "[proxy] [upstream] convert data from %s to %s failed, %s", dp, up, err.Error())
+		convData, err := protocol.ConvertData(r.downStream.context, dp, up, data)
+		if err != nil {
+			log.Proxy.Alertf(r.downStream.context, types.ErrorKeyProtocolConv, "convert data from %s to %s failed, %s", dp, up, err.Error())
 		}
+		return convData, err
 	}
-	return data
+	return data, nil
 }
 
 func (r *upstreamRequest) appendTrailers() {
@@ -227,28 +406,46 @@ func (r *upstreamRequest) appendTrailers() {
 		return
 	}
 	log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] append trailers:%+v", r.downStream.downstreamReqTrailers)
-	trailers := r.downStream.downstreamReqTrailers
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
 	r.sendComplete = true
 	r.trailerSent = true
+	// the stream is waiting for the connection, trailers will be sent in OnReady
+	if r.requestSender == nil {
+		return
+	}
+	trailers := r.downStream.downstreamReqTrailers
 	r.requestSender.AppendTrailers(r.downStream.context, trailers)
 }
 
-func (r *upstreamRequest) convertTrailer(trailers types.HeaderMap) types.HeaderMap {
+func (r *upstreamRequest) convertTrailer(trailers types.HeaderMap) (types.HeaderMap, error) {
 	if r.downStream.noConvert {
-		return trailers
+		return trailers, nil
 	}
 
 	dp, up := r.downStream.convertProtocol()
 
 	// need protocol convert
 	if dp != up {
-		if convTrailer, err := protocol.ConvertTrailer(r.downStream.context, dp, up, trailers); err == nil {
-			return convTrailer
-		} else {
-			log.Proxy.Warnf(r.downStream.context, "[proxy] [upstream] convert header from %s to %s failed, %s", dp, up, err.Error())
+		convTrailer, err := protocol.ConvertTrailer(r.downStream.context, dp, up, trailers)
+		if err != nil {
+			log.Proxy.Alertf(r.downStream.context, types.ErrorKeyProtocolConv, "convert trailer from %s to %s failed, %s", dp, up, err.Error())
 		}
+		return convTrailer, err
 	}
-	return trailers
+	return trailers, nil
+}
+
+// convertFailed resets the upstream request that can not be converted to the upstream protocol,
+// sending the unconverted request would corrupt the upstream connection. The caller holds the lock.
+func (r *upstreamRequest) convertFailed() {
+	if r.requestSender != nil {
+		r.requestSender.GetStream().RemoveEventListener(r)
+		r.requestSender.GetStream().ResetStream(types.StreamLocalReset)
+		r.requestSender = nil
+	}
+	r.OnResetStream(types.StreamProtocolConvertFailed)
 }
 
 // types.PoolEventListener
@@ -258,9 +455,12 @@ func (r *upstreamRequest) OnFailure(reason types.PoolFailureReason, host types.H
 	switch reason {
 	case types.Overflow:
 		resetReason = types.StreamOverflow
-	case types.ConnectionFailure:
+	case types.ConnectionFailure, types.PoolClosed:
 		resetReason = types.StreamConnectionFailed
 	}
+	if reason == types.ConnectionFailure && host != nil {
+		host.ReportResult(types.HostResultConnectFailure)
+	}
 
 	r.host = host
 	r.OnResetStream(resetReason)
@@ -272,14 +472,57 @@ func (r *upstreamRequest) OnReady(sender types.StreamSender, host types.Host) {
 		log.Proxy.Infof(r.downStream.context, "[proxy] [upstream] connPool ready, proxyId = %v, host = %s", r.downStream.ID, host.AddressString())
 	}
 
+	// the lines of the downstream and the upstream stream are prefixed by the host
+	log.SetUpstreamHost(r.downStream.context, host.AddressString())
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
 	r.requestSender = sender
 	r.host = host
 	r.requestSender.GetStream().AddEventListener(r)
+	if r.downStream.getUpstreamProtocol() == protocol.HTTP1 {
+		r.flowMux.Lock()
+		r.stream = sender.GetStream()
+		r.flowMux.Unlock()
+		r.syncReadDisable()
+	}
 	// start a upstream send
 	r.startTime = time.Now()
 
+	// the host is rewritten on every try, as the retry may select another host.
+	// OnReady may be called in the connection pool's goroutine, so a copy of the downstream headers is rewritten
+	reqHeaders := r.downStream.downstreamReqHeaders
+	if route := r.downStream.route; route != nil && route.RouteRule() != nil && route.RouteRule().AutoHostRewrite() {
+		reqHeaders = reqHeaders.Clone()
+		reqHeaders.Set(protocol.MosnHeaderHostKey, host.AddressString())
+		reqHeaders.Set(protocol.IstioHeaderHostKey, host.AddressString())
+	}
+
+	headers, err := r.convertHeader(reqHeaders)
+	if err != nil {
+		r.convertFailed()
+		return
+	}
+	var data types.IoBuffer
+	if r.dataSent {
+		if data, err = r.convertData(r.downStream.downstreamReqDataBuf); err != nil {
+			r.convertFailed()
+			return
+		}
+	}
+
 	endStream := r.sendComplete && !r.dataSent && !r.trailerSent
-	r.requestSender.AppendHeaders(r.downStream.context, r.convertHeader(r.downStream.downstreamReqHeaders), endStream)
+	r.requestSender.AppendHeaders(r.downStream.context, headers, endStream)
+
+	// send the data and trailers appended while waiting for the connection
+	if r.dataSent {
+		endStream = r.sendComplete && !r.trailerSent
+		r.requestSender.AppendData(r.downStream.context, data, endStream)
+	}
+	if r.trailerSent {
+		r.requestSender.AppendTrailers(r.downStream.context, r.downStream.downstreamReqTrailers)
+	}
 
 	r.downStream.requestInfo.OnUpstreamHostSelected(host)
 	r.downStream.requestInfo.SetUpstreamLocalAddress(host.Address())
@@ -0,0 +1,352 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"google.golang.org/grpc/codes"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type fakeStatsClusterInfo struct {
+	types.ClusterInfo
+	stats       types.ClusterStats
+	methodStats bool
+}
+
+func (ci *fakeStatsClusterInfo) Name() string {
+	return "test_response_code"
+}
+
+func (ci *fakeStatsClusterInfo) Stats() types.ClusterStats {
+	return ci.stats
+}
+
+func (ci *fakeStatsClusterInfo) MethodStats() bool {
+	return ci.methodStats
+}
+
+type fakeStatsHost struct {
+	types.Host
+	info types.ClusterInfo
+}
+
+func (h *fakeStatsHost) ClusterInfo() types.ClusterInfo {
+	return h.info
+}
+
+func TestRecordResponseCode(t *testing.T) {
+	info := &fakeStatsClusterInfo{
+		stats: types.ClusterStats{
+			UpstreamResponseCode2xx: gometrics.NewCounter(),
+			UpstreamResponseCode3xx: gometrics.NewCounter(),
+			UpstreamResponseCode4xx: gometrics.NewCounter(),
+			UpstreamResponseCode5xx: gometrics.NewCounter(),
+		},
+	}
+	r := &upstreamRequest{
+		host: &fakeStatsHost{info: info},
+		downStream: &downStream{
+			downstreamReqHeaders: protocol.CommonHeader{types.HeaderMethod: "GET"},
+		},
+	}
+	for _, code := range []int{200, 201, 302, 404, 500, 503, 504} {
+		r.recordResponseCode(code)
+	}
+	for i, c := range []gometrics.Counter{
+		info.stats.UpstreamResponseCode2xx,
+		info.stats.UpstreamResponseCode3xx,
+		info.stats.UpstreamResponseCode4xx,
+		info.stats.UpstreamResponseCode5xx,
+	} {
+		expected := []int64{2, 1, 1, 3}[i]
+		if c.Count() != expected {
+			t.Errorf("expected %d responses of %dxx, but got %d", expected, i+2, c.Count())
+		}
+	}
+
+	// the method stats is disabled by default
+	methodCounter := metrics.NewClusterStats(info.Name()).Counter(metrics.UpstreamResponseMethodPrefix + "GET")
+	if methodCounter.Count() != 0 {
+		t.Fatalf("the method should not be counted, but got %d", methodCounter.Count())
+	}
+	info.methodStats = true
+	r.recordResponseCode(200)
+	if methodCounter.Count() != 1 {
+		t.Fatalf("expected the method counted once, but got %d", methodCounter.Count())
+	}
+	// the unknown methods are counted as other
+	otherCounter := metrics.NewClusterStats(info.Name()).Counter(metrics.UpstreamResponseMethodPrefix + metrics.UpstreamResponseMethodOther)
+	r.downStream.downstreamReqHeaders = protocol.CommonHeader{types.HeaderMethod: "X-RANDOM-1"}
+	r.recordResponseCode(200)
+	r.downStream.downstreamReqHeaders = protocol.CommonHeader{types.HeaderMethod: "X-RANDOM-2"}
+	r.recordResponseCode(200)
+	if otherCounter.Count() != 2 {
+		t.Fatalf("expected the unknown methods counted as other twice, but got %d", otherCounter.Count())
+	}
+	if c := metrics.NewClusterStats(info.Name()).Counter(metrics.UpstreamResponseMethodPrefix + "X-RANDOM-1"); c.Count() != 0 {
+		t.Fatalf("the unknown method should not be counted by its own stats, but got %d", c.Count())
+	}
+}
+
+func TestRecordGrpcStatus(t *testing.T) {
+	info := &fakeStatsClusterInfo{
+		stats: types.ClusterStats{
+			UpstreamResponseGrpcOK:  gometrics.NewCounter(),
+			UpstreamResponseGrpc4xx: gometrics.NewCounter(),
+			UpstreamResponseGrpc5xx: gometrics.NewCounter(),
+		},
+	}
+	r := &upstreamRequest{
+		host: &fakeStatsHost{info: info},
+	}
+	for _, code := range []codes.Code{codes.OK, codes.NotFound, codes.InvalidArgument, codes.Unavailable, codes.DeadlineExceeded, codes.Unknown} {
+		r.recordGrpcStatus(code)
+	}
+	for i, c := range []gometrics.Counter{
+		info.stats.UpstreamResponseGrpcOK,
+		info.stats.UpstreamResponseGrpc4xx,
+		info.stats.UpstreamResponseGrpc5xx,
+	} {
+		expected := []int64{1, 2, 3}[i]
+		if c.Count() != expected {
+			t.Errorf("#%d expected %d grpc responses, but got %d", i, expected, c.Count())
+		}
+	}
+}
+
+type fakeSender struct {
+	types.StreamSender
+	stream  *fakeStream
+	headers types.HeaderMap
+}
+
+func (s *fakeSender) AppendHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) error {
+	s.headers = headers
+	return nil
+}
+
+func (s *fakeSender) GetStream() types.Stream {
+	return s.stream
+}
+
+type fakeStream struct {
+	types.Stream
+	resetReason  types.StreamResetReason
+	readDisabled int
+}
+
+func (s *fakeStream) AddEventListener(types.StreamEventListener) {}
+
+func (s *fakeStream) RemoveEventListener(types.StreamEventListener) {}
+
+func (s *fakeStream) ResetStream(reason types.StreamResetReason) {
+	s.resetReason = reason
+}
+
+func (s *fakeStream) ReadDisable(disable bool) {
+	if disable {
+		s.readDisabled++
+	} else {
+		s.readDisabled--
+	}
+}
+
+type fakeAddrHost struct {
+	types.Host
+}
+
+func (h *fakeAddrHost) AddressString() string {
+	return "127.0.0.1:8080"
+}
+
+func (h *fakeAddrHost) Address() net.Addr {
+	return nil
+}
+
+func TestUpstreamConvertFailed(t *testing.T) {
+	r := &upstreamRequest{
+		downStream: &downStream{
+			context: context.Background(),
+			proxy: &proxy{
+				// no converter between the protocols
+				config: &v2.Proxy{DownstreamProtocol: "mockDownstream", UpstreamProtocol: "mockUpstream"},
+			},
+			downstreamReqHeaders: protocol.CommonHeader{},
+			notify:               make(chan struct{}, 1),
+		},
+		sendComplete: true,
+	}
+	sender := &fakeSender{stream: &fakeStream{}}
+	r.OnReady(sender, &fakeAddrHost{})
+	if sender.headers != nil {
+		t.Fatal("the unconverted headers should not be sent to the upstream")
+	}
+	if sender.stream.resetReason != types.StreamLocalReset {
+		t.Errorf("the upstream stream should be reset, but got %s", sender.stream.resetReason)
+	}
+	if r.downStream.upstreamReset != 1 || r.downStream.resetReason != types.StreamProtocolConvertFailed {
+		t.Errorf("the upstream request should be reset with %s, but got %s", types.StreamProtocolConvertFailed, r.downStream.resetReason)
+	}
+	if flag := r.downStream.proxy.streamResetReasonToResponseFlag(r.downStream.resetReason); flag != types.ProtocolConvertFailed {
+		t.Errorf("expected the response flag %d, but got %d", types.ProtocolConvertFailed, flag)
+	}
+}
+
+type autoHostRewriteRule struct {
+	mockRouteRule
+}
+
+func (r *autoHostRewriteRule) AutoHostRewrite() bool {
+	return true
+}
+
+func TestUpstreamAutoHostRewrite(t *testing.T) {
+	r := &upstreamRequest{
+		downStream: &downStream{
+			context:              context.Background(),
+			proxy:                &proxy{config: &v2.Proxy{}},
+			route:                &mockRoute{rule: &autoHostRewriteRule{}},
+			noConvert:            true,
+			downstreamReqHeaders: protocol.CommonHeader{protocol.MosnHeaderHostKey: "downstream.host"},
+			requestInfo:          network.NewRequestInfo(),
+		},
+		sendComplete: true,
+	}
+	sender := &fakeSender{stream: &fakeStream{}}
+	host := &fakeAddrHost{}
+	r.OnReady(sender, host)
+	if v, _ := sender.headers.Get(protocol.MosnHeaderHostKey); v != host.AddressString() {
+		t.Errorf("the host sent to the upstream should be rewritten to %s, but got %s", host.AddressString(), v)
+	}
+	// the downstream headers may be read by the downstream goroutine, they are not changed
+	if v, _ := r.downStream.downstreamReqHeaders.Get(protocol.MosnHeaderHostKey); v != "downstream.host" {
+		t.Errorf("the downstream headers should not be changed, but got host %s", v)
+	}
+	if r.downStream.requestInfo.UpstreamHost() != host {
+		t.Error("the selected upstream host should be recorded")
+	}
+}
+
+func TestUpstreamReadDisable(t *testing.T) {
+	p := &proxy{activeSteams: list.New()}
+	downstream := &fakeStream{}
+	ds := &downStream{proxy: p, responseSender: &fakeSender{stream: downstream}}
+	upstream := &fakeStream{}
+	r := &upstreamRequest{downStream: ds, proxy: p, stream: upstream}
+	ds.upstreamRequest = r
+	p.activeSteams.PushBack(ds)
+
+	dc := &downstreamCallbacks{proxy: p}
+	dc.OnEvent(types.OnAboveWriteBufferHighWatermark)
+	if upstream.readDisabled != 1 {
+		t.Errorf("the upstream should be read disabled once the downstream is backed up, but got %d", upstream.readDisabled)
+	}
+	r.OnAboveWriteBufferHighWatermark()
+	if downstream.readDisabled != 1 {
+		t.Errorf("the downstream should be read disabled once the upstream is backed up, but got %d", downstream.readDisabled)
+	}
+
+	// the connections are enabled once the stream is released
+	r.resetStream()
+	if upstream.readDisabled != 0 || downstream.readDisabled != 0 {
+		t.Errorf("the released stream should enable reading, but got upstream %d, downstream %d",
+			upstream.readDisabled, downstream.readDisabled)
+	}
+	dc.OnEvent(types.OnBelowWriteBufferLowWatermark)
+	r.OnBelowWriteBufferLowWatermark()
+	if upstream.readDisabled != 0 || downstream.readDisabled != 0 {
+		t.Errorf("the released stream should not be notified, but got upstream %d, downstream %d",
+			upstream.readDisabled, downstream.readDisabled)
+	}
+}
+
+// pendingPool makes the streams wait for the connection, onNewStream is called before NewStream returns
+type pendingPool struct {
+	types.ConnectionPool
+	onNewStream func()
+	pending     *pendingCancel
+}
+
+type pendingCancel struct {
+	canceled int32
+}
+
+func (c *pendingCancel) Cancel() {
+	atomic.AddInt32(&c.canceled, 1)
+}
+
+func (p *pendingPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener, listener types.PoolEventListener) types.Cancellable {
+	p.pending = &pendingCancel{}
+	if p.onNewStream != nil {
+		p.onNewStream()
+	}
+	return p.pending
+}
+
+func TestUpstreamCancelPendingStream(t *testing.T) {
+	newRequest := func(pool *pendingPool) *upstreamRequest {
+		return &upstreamRequest{
+			downStream: &downStream{context: context.Background()},
+			connPool:   pool,
+		}
+	}
+
+	// the stream waiting for the connection is canceled by the reset
+	pool := &pendingPool{}
+	r := newRequest(pool)
+	r.appendHeaders(true)
+	r.resetStream()
+	if n := atomic.LoadInt32(&pool.pending.canceled); n != 1 {
+		t.Errorf("expected the pending stream canceled once, but got %d", n)
+	}
+
+	// the request is reset before NewStream returns
+	pool = &pendingPool{}
+	r = newRequest(pool)
+	pool.onNewStream = r.resetStream
+	r.appendHeaders(true)
+	if n := atomic.LoadInt32(&pool.pending.canceled); n != 1 {
+		t.Errorf("expected the pending stream created while resetting canceled, but got %d", n)
+	}
+
+	// the reset races with the stream creation
+	for i := 0; i < 100; i++ {
+		pool = &pendingPool{}
+		r = newRequest(pool)
+		done := make(chan struct{})
+		go func() {
+			r.resetStream()
+			close(done)
+		}()
+		r.appendHeaders(true)
+		<-done
+		if n := atomic.LoadInt32(&pool.pending.canceled); n != 1 {
+			t.Fatalf("expected the pending stream canceled once, but got %d", n)
+		}
+	}
+}
@@ -18,15 +18,27 @@
 package proxy
 
 import (
+	"net"
 	"strconv"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 var bitSize64 = 1 << 6
 
-func parseProxyTimeout(timeout *Timeout, route types.Route, headers types.HeaderMap) {
+// parseServerName strips the port of the host
+func parseServerName(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// parseProxyTimeout parses the timeouts of a request, the route's zero timeout inherits the default timeout
+func parseProxyTimeout(timeout *Timeout, route types.Route, headers types.HeaderMap, defaultTimeout time.Duration) {
 	timeout.GlobalTimeout = route.RouteRule().GlobalTimeout()
 	timeout.TryTimeout = route.RouteRule().Policy().RetryPolicy().TryTimeout()
 
@@ -45,11 +57,56 @@ func parseProxyTimeout(timeout *Timeout, route types.Route, headers types.Header
 		}
 	}
 
+	if timeout.GlobalTimeout == 0 {
+		timeout.GlobalTimeout = defaultTimeout
+	}
 	if timeout.GlobalTimeout == 0 {
 		timeout.GlobalTimeout = types.GlobalTimeout
 	}
 
+	// the deadline of a grpc request tightens the route's timeout
+	if gto, ok := headers.Get(mhttp2.GrpcTimeout); ok && gto != "" {
+		if grpcTimeout, err := mhttp2.ParseGrpcTimeout(gto); err == nil && grpcTimeout > 0 && grpcTimeout < timeout.GlobalTimeout {
+			timeout.GlobalTimeout = grpcTimeout
+		}
+	}
+
+	// the caller can only tighten the timeout, the header is not sent to the upstream
+	if uto, ok := headers.Get(types.HeaderUpstreamTimeout); ok {
+		if upstreamTimeout, err := strconv.ParseInt(uto, 10, bitSize64); err == nil && upstreamTimeout > 0 {
+			if t := time.Duration(upstreamTimeout) * time.Millisecond; t < timeout.GlobalTimeout {
+				timeout.GlobalTimeout = t
+			}
+		}
+		headers.Del(types.HeaderUpstreamTimeout)
+	}
+
 	if timeout.TryTimeout >= timeout.GlobalTimeout {
 		timeout.TryTimeout = 0
 	}
 }
+
+// maxRequestIDLength bounds the request id adopted from the downstream
+const maxRequestIDLength = 128
+
+// validRequestID checks the request id adopted from the downstream only has [A-Za-z0-9._-],
+// so it can be written to the logs and the headers as is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// carriesRequestID returns whether the request id header is propagated by the protocol,
+// the rpc protocols do not carry it to the upstream
+func carriesRequestID(prot types.Protocol) bool {
+	return prot == protocol.HTTP1 || prot == protocol.HTTP2
+}
@@ -19,12 +19,14 @@ package router
 
 import (
 	"math/rand"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	httpmosn "sofastack.io/sofa-mosn/pkg/protocol/http"
 	"sofastack.io/sofa-mosn/pkg/types"
@@ -35,41 +37,60 @@ type RouteRuleImplBase struct {
 	vHost                 *VirtualHostImpl
 	routerMatch           v2.RouterMatch
 	configHeaders         []*types.HeaderData
-	configQueryParameters []types.QueryParameterMatcher //TODO: not implement yet
+	configQueryParameters []types.QueryParameterMatcher
 	// rewrite
 	prefixRewrite         string
+	regexRewrite          *regexp.Regexp
+	regexSubstitution     string
+	keepOriginalPath      bool
 	hostRewrite           string
-	autoHostRewrite       bool // TODO: not implement yet
+	autoHostRewrite       bool
 	requestHeadersParser  *headerParser
 	responseHeadersParser *headerParser
 	// information
 	upstreamProtocol string
 	perFilterConfig  map[string]interface{}
+	maxRequestBytes  uint64
 	// policy
 	policy *policy
 	// direct response
 	directResponseRule *directResponseImpl
 	// action
-	routerAction       v2.RouteAction
-	defaultCluster     *weightedClusterEntry // cluster name and metadata
-	weightedClusters   map[string]weightedClusterEntry
-	totalClusterWeight uint32
-	lock               sync.Mutex
-	randInstance       *rand.Rand
+	routerAction     v2.RouteAction
+	defaultCluster   *weightedClusterEntry // cluster name and metadata
+	weightedClusters map[string]weightedClusterEntry
+	// weightedClusterList keeps the configured order, so the same value always selects the same cluster
+	weightedClusterList []*weightedClusterEntry
+	totalClusterWeight  uint32
+	lock                sync.Mutex
+	randInstance        *rand.Rand
+	clusterMissing      uint32 // not zero if the clusters referenced are not configured
 }
 
 func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleImplBase, error) {
+	if err := validateRouteMatch(route.Match); err != nil {
+		return nil, err
+	}
+	if err := validateWeightedClusters(route.Route.WeightedClusters); err != nil {
+		return nil, err
+	}
+	if err := validateRewrite(route.Route); err != nil {
+		return nil, err
+	}
 	base := &RouteRuleImplBase{
 		vHost:                 vHost,
 		routerMatch:           route.Match,
 		configHeaders:         getRouterHeaders(route.Match.Headers),
+		configQueryParameters: getQueryParameterMatchers(route.Match.QueryParameters),
 		prefixRewrite:         route.Route.PrefixRewrite,
 		hostRewrite:           route.Route.HostRewrite,
 		autoHostRewrite:       route.Route.AutoHostRewrite,
-		requestHeadersParser:  getHeaderParser(route.Route.RequestHeadersToAdd, nil),
+		keepOriginalPath:      route.Route.KeepOriginalPath,
+		requestHeadersParser:  getHeaderParser(route.Route.RequestHeadersToAdd, route.Route.RequestHeadersToRemove),
 		responseHeadersParser: getHeaderParser(route.Route.ResponseHeadersToAdd, route.Route.ResponseHeadersToRemove),
 		upstreamProtocol:      route.Route.UpstreamProtocol,
 		perFilterConfig:       route.PerFilterConfig,
+		maxRequestBytes:       route.MaxRequestBytes,
 		policy:                &policy{},
 		routerAction:          route.Route,
 		defaultCluster: &weightedClusterEntry{
@@ -80,14 +101,47 @@ func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleI
 	// add clusters
 	base.weightedClusters, base.totalClusterWeight = getWeightedClusterEntry(route.Route.WeightedClusters)
 	if len(route.Route.MetadataMatch) > 0 {
-		base.defaultCluster.clusterMetadataMatchCriteria = NewMetadataMatchCriteriaImpl(route.Route.MetadataMatch)
+		criteria := NewMetadataMatchCriteriaImpl(route.Route.MetadataMatch)
+		base.defaultCluster.clusterMetadataMatchCriteria = criteria
+		// the weighted cluster's metadata match overrides the route's
+		for _, weightedCluster := range route.Route.WeightedClusters {
+			entry := base.weightedClusters[weightedCluster.Cluster.Name]
+			entry.clusterMetadataMatchCriteria = criteria.merge(weightedCluster.Cluster.MetadataMatch)
+			base.weightedClusters[weightedCluster.Cluster.Name] = entry
+		}
+	}
+	if len(route.Route.WeightedClusters) > 0 {
+		var vHostName string
+		if vHost != nil {
+			vHostName = vHost.Name()
+		}
+		base.weightedClusterList = make([]*weightedClusterEntry, 0, len(route.Route.WeightedClusters))
+		for _, weightedCluster := range route.Route.WeightedClusters {
+			entry := base.weightedClusters[weightedCluster.Cluster.Name]
+			entry.stats = metrics.NewWeightedClusterStats(vHostName, entry.clusterName)
+			base.weightedClusters[weightedCluster.Cluster.Name] = entry
+			base.weightedClusterList = append(base.weightedClusterList, &entry)
+		}
+	}
+	if route.Route.RegexRewrite != nil {
+		// the pattern is validated
+		base.regexRewrite = regexp.MustCompile(route.Route.RegexRewrite.Pattern)
+		base.regexSubstitution = route.Route.RegexRewrite.Substitution
 	}
 	// add policy
 	if route.Route.RetryPolicy != nil {
-		base.policy.retryPolicy = &retryPolicyImpl{
-			retryOn:      route.Route.RetryPolicy.RetryOn,
-			retryTimeout: route.Route.RetryPolicy.RetryTimeout,
-			numRetries:   route.Route.RetryPolicy.NumRetries,
+		base.policy.retryPolicy = newRetryPolicy(route.Route.RetryPolicy)
+	}
+	// the route's per try timeout overrides the retry policy's
+	if route.Route.PerTryTimeout > 0 {
+		if base.policy.retryPolicy == nil {
+			base.policy.retryPolicy = &retryPolicyImpl{}
+		}
+		base.policy.retryPolicy.retryTimeout = route.Route.PerTryTimeout
+	}
+	if len(route.Route.HashPolicy) > 0 {
+		base.policy.hashPolicy = &hashPolicyImpl{
+			policies: route.Route.HashPolicy,
 		}
 	}
 	// add direct repsonse rule
@@ -109,24 +163,37 @@ func (rri *RouteRuleImplBase) DirectResponseRule() types.DirectResponseRule {
 // if weighted cluster is nil, return clusterName directly, else
 // select cluster from weighted-clusters
 func (rri *RouteRuleImplBase) ClusterName() string {
-	if len(rri.weightedClusters) == 0 {
-		return rri.defaultCluster.clusterName
-	}
-	rri.lock.Lock()
-	if rri.randInstance == nil {
-		rri.randInstance = rand.New(rand.NewSource(time.Now().UnixNano()))
-	}
-	rri.lock.Unlock()
-	selectedValue := rri.randInstance.Intn(int(rri.totalClusterWeight))
-	for _, weightCluster := range rri.weightedClusters {
-		selectedValue = selectedValue - int(weightCluster.clusterWeight)
-		if selectedValue <= 0 {
-			return weightCluster.clusterName
-		}
+	if entry := rri.weightedCluster(0, false); entry != nil {
+		return entry.clusterName
 	}
 	return rri.defaultCluster.clusterName
 }
 
+// weightedCluster selects a weighted cluster for a request, returns nil if the route has no weighted clusters.
+// the hash key of the request makes the selection stable, otherwise the cluster is selected randomly
+func (rri *RouteRuleImplBase) weightedCluster(hashKey uint64, hashed bool) *weightedClusterEntry {
+	if len(rri.weightedClusterList) == 0 || rri.totalClusterWeight == 0 {
+		return nil
+	}
+	if !hashed {
+		rri.lock.Lock()
+		if rri.randInstance == nil {
+			rri.randInstance = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		hashKey = rri.randInstance.Uint64()
+		rri.lock.Unlock()
+	}
+	// the weights are normalized by the total weight
+	value := hashKey % uint64(rri.totalClusterWeight)
+	for _, entry := range rri.weightedClusterList {
+		if value < uint64(entry.clusterWeight) {
+			return entry
+		}
+		value -= uint64(entry.clusterWeight)
+	}
+	return nil
+}
+
 func (rri *RouteRuleImplBase) UpstreamProtocol() string {
 	return rri.upstreamProtocol
 }
@@ -161,6 +228,28 @@ func (rri *RouteRuleImplBase) PerFilterConfig() map[string]interface{} {
 	return rri.perFilterConfig
 }
 
+// Priority returns the high priority only if it is configured, the unknown priority is the default priority
+func (rri *RouteRuleImplBase) Priority() types.ResourcePriority {
+	if rri.routerAction.Priority == v2.HIGH_PRIORITY {
+		return types.HighPriority
+	}
+	return types.DefaultPriority
+}
+
+func (rri *RouteRuleImplBase) AutoHostRewrite() bool {
+	return rri.autoHostRewrite
+}
+
+func (rri *RouteRuleImplBase) MaxRequestBytes() uint64 {
+	if rri.maxRequestBytes > 0 {
+		return rri.maxRequestBytes
+	}
+	if rri.vHost != nil {
+		return rri.vHost.maxRequestBytes
+	}
+	return 0
+}
+
 // matchRoute is a common matched for http
 func (rri *RouteRuleImplBase) matchRoute(headers types.HeaderMap, randomValue uint64) bool {
 	// 1. match headers' KV
@@ -169,11 +258,8 @@ func (rri *RouteRuleImplBase) matchRoute(headers types.HeaderMap, randomValue ui
 		return false
 	}
 	// 2. match query parameters
-	var queryParams types.QueryParams
-	if QueryString, ok := headers.Get(protocol.MosnHeaderQueryStringKey); ok {
-		queryParams = httpmosn.ParseQueryString(QueryString)
-	}
-	if len(queryParams) != 0 {
+	if len(rri.configQueryParameters) != 0 {
+		queryParams := getQueryParams(headers)
 		if !ConfigUtilityInst.MatchQueryParams(queryParams, rri.configQueryParameters) {
 			log.DefaultLogger.Debugf(RouterLogFormat, "routerule", "match query params", queryParams)
 			return false
@@ -182,16 +268,33 @@ func (rri *RouteRuleImplBase) matchRoute(headers types.HeaderMap, randomValue ui
 	return true
 }
 
+// finalizePathHeader rewrites the path by the prefix or the regex, the rewritten path is assembled
+// into the request uri by the upstream protocol
 func (rri *RouteRuleImplBase) finalizePathHeader(headers types.HeaderMap, matchedPath string) {
-	if len(rri.prefixRewrite) < 1 {
+	if len(rri.prefixRewrite) < 1 && rri.regexRewrite == nil {
 		return
 	}
-	if path, ok := headers.Get(protocol.MosnHeaderPathKey); ok {
-		if strings.HasPrefix(path, matchedPath) {
-			headers.Set(protocol.MosnOriginalHeaderPathKey, path)
-			headers.Set(protocol.MosnHeaderPathKey, rri.prefixRewrite+path[len(matchedPath):])
-			log.DefaultLogger.Infof(RouterLogFormat, "routerule", "finalizePathHeader", "add prefix to path, prefix is "+rri.prefixRewrite)
-		}
+	path, ok := headers.Get(protocol.MosnHeaderPathKey)
+	if !ok {
+		return
+	}
+	var rewritten string
+	if rri.regexRewrite != nil {
+		rewritten = rri.regexRewrite.ReplaceAllString(path, rri.regexSubstitution)
+	} else if strings.HasPrefix(path, matchedPath) {
+		rewritten = rri.prefixRewrite + path[len(matchedPath):]
+	} else {
+		return
+	}
+	if rewritten == "" {
+		rewritten = "/"
+	}
+	if rri.keepOriginalPath {
+		headers.Set(protocol.MosnOriginalHeaderPathKey, path)
+	}
+	headers.Set(protocol.MosnHeaderPathKey, rewritten)
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf(RouterLogFormat, "routerule", "finalizePathHeader", "rewrite path "+path+" to "+rewritten)
 	}
 }
 
@@ -204,7 +307,7 @@ func (rri *RouteRuleImplBase) finalizeRequestHeaders(headers types.HeaderMap, re
 	rri.vHost.requestHeadersParser.evaluateHeaders(headers, requestInfo)
 	rri.vHost.globalRouteConfig.requestHeadersParser.evaluateHeaders(headers, requestInfo)
 	if len(rri.hostRewrite) > 0 {
-		headers.Set(protocol.IstioHeaderHostKey, rri.hostRewrite)
+		setHostHeader(headers, rri.hostRewrite)
 	}
 }
 
@@ -213,3 +316,37 @@ func (rri *RouteRuleImplBase) FinalizeResponseHeaders(headers types.HeaderMap, r
 	rri.vHost.responseHeadersParser.evaluateHeaders(headers, requestInfo)
 	rri.vHost.globalRouteConfig.responseHeadersParser.evaluateHeaders(headers, requestInfo)
 }
+
+// routeMatchHeaders caches the query parameters of a request,
+// so the query string is parsed once when the request is matched by the routes
+type routeMatchHeaders struct {
+	types.HeaderMap
+	queryParams types.QueryParams
+}
+
+func (h *routeMatchHeaders) QueryParams() types.QueryParams {
+	if h.queryParams == nil {
+		h.queryParams = parseQueryParams(h.HeaderMap)
+	}
+	return h.queryParams
+}
+
+func getQueryParams(headers types.HeaderMap) types.QueryParams {
+	if h, ok := headers.(*routeMatchHeaders); ok {
+		return h.QueryParams()
+	}
+	return parseQueryParams(headers)
+}
+
+func parseQueryParams(headers types.HeaderMap) types.QueryParams {
+	if queryString, ok := headers.Get(protocol.MosnHeaderQueryStringKey); ok && queryString != "" {
+		return httpmosn.ParseQueryString(queryString)
+	}
+	return types.QueryParams{}
+}
+
+// setHostHeader sets the host of the request, the http1 upstream takes the authority header first
+func setHostHeader(headers types.HeaderMap, host string) {
+	headers.Set(protocol.MosnHeaderHostKey, host)
+	headers.Set(protocol.IstioHeaderHostKey, host)
+}
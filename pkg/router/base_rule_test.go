@@ -18,11 +18,15 @@
 package router
 
 import (
+	"context"
 	"math/rand"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -146,9 +150,63 @@ func TestWeightedClusterSelect(t *testing.T) {
 	}
 }
 
+func TestWeightedClusterMetadataMatchMerge(t *testing.T) {
+	route := &v2.Router{}
+	route.Route = v2.RouteAction{
+		RouterActionConfig: v2.RouterActionConfig{
+			ClusterName: "defaultCluster",
+			WeightedClusters: []v2.WeightedCluster{
+				{
+					Cluster: v2.ClusterWeight{
+						ClusterWeightConfig: v2.ClusterWeightConfig{
+							Name:   "canary",
+							Weight: 10,
+						},
+						MetadataMatch: v2.Metadata{
+							"version": "v2",
+						},
+					},
+				},
+				{
+					Cluster: v2.ClusterWeight{
+						ClusterWeightConfig: v2.ClusterWeightConfig{
+							Name:   "stable",
+							Weight: 90,
+						},
+					},
+				},
+			},
+		},
+		MetadataMatch: v2.Metadata{
+			"app":     "demo",
+			"version": "v1",
+		},
+	}
+	rule, _ := NewRouteRuleImplBase(nil, route)
+	testCases := []struct {
+		clusterName string
+		expected    map[string]string
+	}{
+		{"canary", map[string]string{"app": "demo", "version": "v2"}},
+		{"stable", map[string]string{"app": "demo", "version": "v1"}},
+		{"defaultCluster", map[string]string{"app": "demo", "version": "v1"}},
+	}
+	for _, tc := range testCases {
+		criteria := rule.MetadataMatchCriteria(tc.clusterName).MetadataMatchCriteria()
+		got := make(map[string]string, len(criteria))
+		for _, c := range criteria {
+			got[c.MetadataKeyName()] = c.MetadataValue()
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("cluster %s expected metadata match %v, but got %v", tc.clusterName, tc.expected, got)
+		}
+	}
+}
+
 func Test_RouteRuleImplBase_finalizePathHeader(t *testing.T) {
 	rri := &RouteRuleImplBase{
-		prefixRewrite: "/abc/",
+		prefixRewrite:    "/abc/",
+		keepOriginalPath: true,
 	}
 	type args struct {
 		headers     types.HeaderMap
@@ -269,7 +327,7 @@ func Test_RouteRuleImplBase_FinalizeRequestHeaders(t *testing.T) {
 				headers:     protocol.CommonHeader{"host": "xxx.default.svc.cluster.local"},
 				requestInfo: nil,
 			},
-			want: protocol.CommonHeader{"host": "xxx.default.svc.cluster.local", "authority": "www.xxx.com", "level": "1,2,3", "route": "true", "vhost": "true", "global": "true"},
+			want: protocol.CommonHeader{"host": "xxx.default.svc.cluster.local", "authority": "www.xxx.com", "x-mosn-host": "www.xxx.com", "level": "1,2,3", "route": "true", "vhost": "true", "global": "true"},
 		},
 
 		{
@@ -480,3 +538,284 @@ func Test_RouteRuleImplBase_FinalizeResponseHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestRouteMaxRequestBytes(t *testing.T) {
+	vh, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Name:            "test",
+		Domains:         []string{"*"},
+		MaxRequestBytes: 1024,
+		Routers: []v2.Router{
+			{RouterConfig: v2.RouterConfig{Match: v2.RouterMatch{Prefix: "/small"}, MaxRequestBytes: 16}},
+			{RouterConfig: v2.RouterConfig{Match: v2.RouterMatch{Prefix: "/"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]uint64{
+		"/small": 16,
+		"/other": 1024,
+	} {
+		headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: path}
+		route := vh.GetRouteFromEntries(headers, 1)
+		if route == nil {
+			t.Fatalf("%s should match a route", path)
+		}
+		if max := route.RouteRule().MaxRequestBytes(); max != expected {
+			t.Errorf("%s expected max request bytes %d, but got %d", path, expected, max)
+		}
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	for priority, expected := range map[v2.RoutingPriority]types.ResourcePriority{
+		"":                  types.DefaultPriority,
+		v2.DEFAULT_PRIORITY: types.DefaultPriority,
+		v2.HIGH_PRIORITY:    types.HighPriority,
+	} {
+		route := &v2.Router{}
+		route.Route.ClusterName = "cluster"
+		route.Route.Priority = priority
+		rule, err := NewRouteRuleImplBase(nil, route)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := rule.Priority(); p != expected {
+			t.Errorf("route priority %s expected %s, but got %s", priority, expected, p)
+		}
+	}
+}
+
+func TestHashPolicy(t *testing.T) {
+	route := &v2.Router{}
+	route.Route = v2.RouteAction{
+		RouterActionConfig: v2.RouterActionConfig{
+			ClusterName: "cluster",
+			HashPolicy: []v2.HashPolicy{
+				{Header: "x-user"},
+				{SourceIP: true},
+			},
+		},
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := rule.Policy().HashPolicy()
+	client1 := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 10000}
+	client2 := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 10000}
+	// the header is used first
+	k1, ok1 := hp.GenerateHash(protocol.CommonHeader{"x-user": "alice"}, client1)
+	k2, ok2 := hp.GenerateHash(protocol.CommonHeader{"x-user": "alice"}, client2)
+	k3, _ := hp.GenerateHash(protocol.CommonHeader{"x-user": "bob"}, client1)
+	if !ok1 || !ok2 || k1 != k2 || k1 == k3 {
+		t.Errorf("unexpected header hash keys: %d, %d, %d", k1, k2, k3)
+	}
+	// no header, the source ip is used, the port is ignored
+	k4, ok4 := hp.GenerateHash(protocol.CommonHeader{}, client1)
+	k5, _ := hp.GenerateHash(nil, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 20000})
+	k6, _ := hp.GenerateHash(nil, client2)
+	if !ok4 || k4 != k5 || k4 == k6 {
+		t.Errorf("unexpected source ip hash keys: %d, %d, %d", k4, k5, k6)
+	}
+	if _, ok := hp.GenerateHash(nil, nil); ok {
+		t.Error("expected no hash key without the header and the source ip")
+	}
+	// no hash policy configured
+	route.Route.HashPolicy = nil
+	rule, _ = NewRouteRuleImplBase(nil, route)
+	if _, ok := rule.Policy().HashPolicy().GenerateHash(protocol.CommonHeader{"x-user": "alice"}, client1); ok {
+		t.Error("expected no hash key without the hash policy")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	route := &v2.Router{}
+	route.Route = v2.RouteAction{
+		RouterActionConfig: v2.RouterActionConfig{
+			ClusterName: "cluster",
+			RetryPolicy: &v2.RetryPolicy{
+				RetryPolicyConfig: v2.RetryPolicyConfig{
+					NumRetries:           2,
+					RetryOnConditions:    []string{v2.RetryOnReset, v2.RetryOnRetriableStatusCodes},
+					RetriableStatusCodes: []uint32{429},
+				},
+				RetryTimeout: time.Second,
+			},
+		},
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := rule.Policy().RetryPolicy()
+	// the conditions enable the retry
+	if !rp.RetryOn() || rp.NumRetries() != 2 || rp.TryTimeout() != time.Second {
+		t.Errorf("unexpected retry policy: %v, %d, %s", rp.RetryOn(), rp.NumRetries(), rp.TryTimeout())
+	}
+	if rp.RetryOnConditions() != types.RetryOnReset|types.RetryOnRetriableStatusCodes {
+		t.Errorf("unexpected retry on conditions: %b", rp.RetryOnConditions())
+	}
+	if codes := rp.RetriableStatusCodes(); len(codes) != 1 || codes[0] != 429 {
+		t.Errorf("unexpected retriable status codes: %v", codes)
+	}
+	// no retry policy
+	route.Route.RetryPolicy = nil
+	rule, _ = NewRouteRuleImplBase(nil, route)
+	rp = rule.Policy().RetryPolicy()
+	if rp.RetryOn() || rp.RetryOnConditions() != 0 || rp.RetriableStatusCodes() != nil {
+		t.Error("the retry should be disabled")
+	}
+}
+
+func TestWeightedClusterValidation(t *testing.T) {
+	newRoute := func(clusters ...v2.ClusterWeightConfig) *v2.Router {
+		route := &v2.Router{}
+		for _, c := range clusters {
+			route.Route.WeightedClusters = append(route.Route.WeightedClusters, v2.WeightedCluster{
+				Cluster: v2.ClusterWeight{ClusterWeightConfig: c},
+			})
+		}
+		return route
+	}
+	for i, route := range []*v2.Router{
+		newRoute(v2.ClusterWeightConfig{Name: "w1"}, v2.ClusterWeightConfig{Name: "w2"}),
+		newRoute(v2.ClusterWeightConfig{Name: "w1", Weight: 10}, v2.ClusterWeightConfig{Name: "w1", Weight: 90}),
+		newRoute(v2.ClusterWeightConfig{Weight: 10}),
+	} {
+		if _, err := NewRouteRuleImplBase(nil, route); err == nil {
+			t.Errorf("#%d invalid weighted clusters are accepted", i)
+		}
+	}
+}
+
+func TestSelectWeightedCluster(t *testing.T) {
+	canaryHeader := []*v2.HeaderValueOption{
+		{Header: &v2.HeaderValue{Key: "x-canary", Value: "true"}},
+	}
+	cfg := &v2.RouterConfiguration{
+		VirtualHosts: []*v2.VirtualHost{
+			{
+				Name:    "weighted",
+				Domains: []string{"*"},
+				Routers: []v2.Router{
+					{
+						RouterConfig: v2.RouterConfig{
+							Match: v2.RouterMatch{Prefix: "/"},
+							Route: v2.RouteAction{
+								RouterActionConfig: v2.RouterActionConfig{
+									WeightedClusters: []v2.WeightedCluster{
+										{Cluster: v2.ClusterWeight{ClusterWeightConfig: v2.ClusterWeightConfig{Name: "stable", Weight: 75}}},
+										{Cluster: v2.ClusterWeight{ClusterWeightConfig: v2.ClusterWeightConfig{Name: "canary", Weight: 25, RequestHeadersToAdd: canaryHeader}}},
+									},
+									HashPolicy: []v2.HashPolicy{{Header: "x-user"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	routers, err := NewRouters(cfg)
+	if err != nil {
+		t.Fatalf("create routers failed: %v", err)
+	}
+	stableCounter := metrics.NewWeightedClusterStats("weighted", "stable").Counter(metrics.WeightedClusterSelected)
+	canaryCounter := metrics.NewWeightedClusterStats("weighted", "canary").Counter(metrics.WeightedClusterSelected)
+	stable, canary := stableCounter.Count(), canaryCounter.Count()
+	// the requests without hash key are selected randomly
+	selected := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/"}
+		route := selectWeightedCluster(context.Background(), headers, routers.MatchRoute(headers, 1))
+		selected[route.RouteRule().ClusterName()]++
+	}
+	if selected["canary"] < 2000 || selected["canary"] > 3000 || selected["stable"]+selected["canary"] != 10000 {
+		t.Errorf("weighted clusters split is not expected: %v", selected)
+	}
+	if int(stableCounter.Count()-stable) != selected["stable"] || int(canaryCounter.Count()-canary) != selected["canary"] {
+		t.Errorf("weighted cluster counters are not expected")
+	}
+	// the requests with the same hash key are routed to the same cluster
+	for _, user := range []string{"alice", "bob", "carol"} {
+		var clusterName string
+		for i := 0; i < 10; i++ {
+			headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/", "x-user": user}
+			route := selectWeightedCluster(context.Background(), headers, routers.MatchRoute(headers, 1))
+			name := route.RouteRule().ClusterName()
+			if clusterName != "" && name != clusterName {
+				t.Fatalf("user %s is routed to %s and %s", user, clusterName, name)
+			}
+			clusterName = name
+			route.RouteRule().FinalizeRequestHeaders(headers, nil)
+			if v, ok := headers.Get("x-canary"); (name == "canary") != (ok && v == "true") {
+				t.Errorf("cluster %s request headers are not expected: %v", name, headers)
+			}
+		}
+	}
+}
+
+func TestRoutePerTryTimeout(t *testing.T) {
+	route := &v2.Router{}
+	route.Route.PerTryTimeout = time.Second
+	rule, _ := NewRouteRuleImplBase(nil, route)
+	if rule.Policy().RetryPolicy().TryTimeout() != time.Second || rule.Policy().RetryPolicy().RetryOn() {
+		t.Errorf("per try timeout without retry policy is not expected")
+	}
+	route.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{RetryOn: true},
+		RetryTimeout:      3 * time.Second,
+	}
+	rule, _ = NewRouteRuleImplBase(nil, route)
+	if rule.Policy().RetryPolicy().TryTimeout() != time.Second || !rule.Policy().RetryPolicy().RetryOn() {
+		t.Errorf("per try timeout should override the retry policy's")
+	}
+}
+
+func TestRouteRewrite(t *testing.T) {
+	route := &v2.Router{}
+	route.Route.RegexRewrite = &v2.RegexRewrite{
+		Pattern:      "^/service/([^/]+)(/.*)$",
+		Substitution: "$2/instance/$1",
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/service/foo/v1/api"}
+	rule.finalizePathHeader(headers, "")
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/v1/api/instance/foo" {
+		t.Errorf("regex rewrite path is not expected: %s", path)
+	}
+	if _, ok := headers.Get(protocol.MosnOriginalHeaderPathKey); ok {
+		t.Errorf("original path should not be kept without the flag")
+	}
+	// the prefix is rewritten to empty
+	route = &v2.Router{}
+	route.Route.PrefixRewrite = "/"
+	route.Route.KeepOriginalPath = true
+	rule, _ = NewRouteRuleImplBase(nil, route)
+	headers = protocol.CommonHeader{protocol.MosnHeaderPathKey: "/prefix/api"}
+	rule.finalizePathHeader(headers, "/prefix/")
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/api" {
+		t.Errorf("prefix rewrite path is not expected: %s", path)
+	}
+	if path, _ := headers.Get(protocol.MosnOriginalHeaderPathKey); path != "/prefix/api" {
+		t.Errorf("original path is not expected: %s", path)
+	}
+}
+
+func TestRouteRewriteValidation(t *testing.T) {
+	for _, action := range []v2.RouteAction{
+		{RouterActionConfig: v2.RouterActionConfig{PrefixRewrite: "/", RegexRewrite: &v2.RegexRewrite{Pattern: "/"}}},
+		{RouterActionConfig: v2.RouterActionConfig{RegexRewrite: &v2.RegexRewrite{Pattern: "(["}}},
+		{RouterActionConfig: v2.RouterActionConfig{HostRewrite: "www.example.com", AutoHostRewrite: true}},
+	} {
+		route := &v2.Router{}
+		route.Route = action
+		if _, err := NewRouteRuleImplBase(nil, route); err == nil {
+			t.Errorf("route rewrite %+v should be invalid", action.RouterActionConfig)
+		}
+	}
+}
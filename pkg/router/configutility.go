@@ -20,6 +20,7 @@ package router
 import (
 	"regexp"
 	"sort"
+	"strings"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -44,18 +45,23 @@ func (cu *configUtility) MatchHeaders(requestHeaders types.HeaderMap, configHead
 		// if a condition is not matched, return false
 		// all condition matched, return true
 		value, ok := requestHeaders.Get(cfgName)
-		if !ok {
-			return false
-		}
-		if cfgHeaderData.IsRegex {
-			if !cfgHeaderData.RegexPattern.MatchString(value) {
-				return false
-			}
-		} else {
-			if cfgValue != value {
-				return false
+		matched := ok
+		if ok {
+			switch {
+			case cfgHeaderData.Present:
+			case cfgHeaderData.IsRegex:
+				matched = cfgHeaderData.RegexPattern.MatchString(value)
+			case cfgHeaderData.Prefix != "":
+				matched = strings.HasPrefix(value, cfgHeaderData.Prefix)
+			case cfgHeaderData.Suffix != "":
+				matched = strings.HasSuffix(value, cfgHeaderData.Suffix)
+			default:
+				matched = cfgValue == value
 			}
 		}
+		if matched == cfgHeaderData.Invert {
+			return false
+		}
 	}
 	return true
 }
@@ -79,7 +85,8 @@ type queryParameterMatcher struct {
 	name         string
 	value        string
 	isRegex      bool
-	regexPattern regexp.Regexp
+	present      bool
+	regexPattern *regexp.Regexp
 }
 
 func (qpm *queryParameterMatcher) Matches(requestQueryParams types.QueryParams) bool {
@@ -87,6 +94,9 @@ func (qpm *queryParameterMatcher) Matches(requestQueryParams types.QueryParams)
 	if !ok {
 		return false
 	}
+	if qpm.present {
+		return true
+	}
 	if qpm.isRegex {
 		return qpm.regexPattern.MatchString(requestQueryValue)
 	}
@@ -99,7 +109,7 @@ func (qpm *queryParameterMatcher) Matches(requestQueryParams types.QueryParams)
 // NewConfigImpl return an configImpl instance contains requestHeadersParser and responseHeadersParser
 func NewConfigImpl(routerConfig *v2.RouterConfiguration) *configImpl {
 	return &configImpl{
-		requestHeadersParser:  getHeaderParser(routerConfig.RequestHeadersToAdd, nil),
+		requestHeadersParser:  getHeaderParser(routerConfig.RequestHeadersToAdd, routerConfig.RequestHeadersToRemove),
 		responseHeadersParser: getHeaderParser(routerConfig.ResponseHeadersToAdd, routerConfig.ResponseHeadersToRemove),
 	}
 }
@@ -131,10 +141,22 @@ func (mmcti *MetadataMatchCriteriaImpl) MetadataMatchCriteria() []types.Metadata
 	return mmcti.MatchCriteriaArray
 }
 
-// MergeMatchCriteria
-// No usage currently
+// MergeMatchCriteria returns a new criteria that the metadataMatches overrides the criterion with the same name,
+// the values that are not strings are ignored
 func (mmcti *MetadataMatchCriteriaImpl) MergeMatchCriteria(metadataMatches map[string]interface{}) types.MetadataMatchCriteria {
-	return nil
+	matches := make(map[string]string, len(metadataMatches))
+	for k, v := range metadataMatches {
+		if value, ok := v.(string); ok {
+			matches[k] = value
+		}
+	}
+	return mmcti.merge(matches)
+}
+
+func (mmcti *MetadataMatchCriteriaImpl) merge(metadataMatches map[string]string) *MetadataMatchCriteriaImpl {
+	merged := &MetadataMatchCriteriaImpl{}
+	merged.extractMetadataMatchCriteria(mmcti, metadataMatches)
+	return merged
 }
 
 func (mmcti *MetadataMatchCriteriaImpl) Len() int {
@@ -215,3 +215,33 @@ func TestMetadataMatchCriteriaImplSort(t *testing.T) {
 		}
 	}
 }
+
+func TestMetadataMatchCriteriaImplMerge(t *testing.T) {
+	m := NewMetadataMatchCriteriaImpl(map[string]string{
+		"version": "v1",
+		"label":   "gray",
+	})
+	merged := m.MergeMatchCriteria(map[string]interface{}{
+		"version": "v2",
+		"stage":   "prod",
+		"weight":  1, // not a string
+	})
+	expected := []MetadataMatchCriterionImpl{
+		{"label", "gray"},
+		{"stage", "prod"},
+		{"version", "v2"},
+	}
+	criteria := merged.MetadataMatchCriteria()
+	if len(criteria) != len(expected) {
+		t.Fatalf("expected %d criteria, but got %d", len(expected), len(criteria))
+	}
+	for i, c := range criteria {
+		if c.MetadataKeyName() != expected[i].Name || c.MetadataValue() != expected[i].Value {
+			t.Errorf("#%d expected %v, but got %s: %s", i, expected[i], c.MetadataKeyName(), c.MetadataValue())
+		}
+	}
+	// the origin criteria is not changed
+	if len(m.MetadataMatchCriteria()) != 2 || m.MetadataMatchCriteria()[1].MetadataValue() != "v1" {
+		t.Errorf("origin criteria is changed: %v", m.MetadataMatchCriteria())
+	}
+}
@@ -92,7 +92,7 @@ func DefaultMakeHandlerChain(ctx context.Context, headers types.HeaderMap, route
 		if log.Proxy.GetLogLevel() >= log.INFO {
 			log.Proxy.Infof(ctx, RouterLogFormat, "DefaultHandklerChain", "MatchRoute", fmt.Sprintf("matched a route: %v", r))
 		}
-		handlers = append(handlers, &simpleHandler{route: r})
+		handlers = append(handlers, &simpleHandler{route: selectWeightedCluster(ctx, headers, r)})
 	}
 	return NewRouteHandlerChain(ctx, clusterManager, handlers)
 }
@@ -20,9 +20,23 @@ package router
 import (
 	"fmt"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
+// HeaderParser adds and removes the headers, the values to add can reference the request info by the %VARIABLE%s
+type HeaderParser interface {
+	EvaluateHeaders(headers types.HeaderMap, requestInfo types.RequestInfo)
+}
+
+// NewHeaderParser returns nil if there are no headers to add or remove
+func NewHeaderParser(headersToAdd []*v2.HeaderValueOption, headersToRemove []string) HeaderParser {
+	if h := getHeaderParser(headersToAdd, headersToRemove); h != nil {
+		return h
+	}
+	return nil
+}
+
 type headerParser struct {
 	headersToAdd    []*headerPair
 	headersToRemove []*lowerCaseString
@@ -44,3 +58,8 @@ func (h *headerParser) evaluateHeaders(headers types.HeaderMap, requestInfo type
 		headers.Del(toRemove.Get())
 	}
 }
+
+// EvaluateHeaders implements HeaderParser
+func (h *headerParser) EvaluateHeaders(headers types.HeaderMap, requestInfo types.RequestInfo) {
+	h.evaluateHeaders(headers, requestInfo)
+}
@@ -18,6 +18,7 @@
 package router
 
 import (
+	"fmt"
 	"strings"
 
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -25,15 +26,93 @@ import (
 )
 
 func getHeaderFormatter(value string, append bool) headerFormatter {
-	// TODO: variable headers would be support very soon
-	if strings.Index(value, "%") != -1 {
-		log.DefaultLogger.Warnf("variable headers not support yet, skip, value: %s", value)
+	if strings.Index(value, "%") == -1 {
+		return &plainHeaderFormatter{
+			isAppend:    append,
+			staticValue: value,
+		}
+	}
+	parts, err := parseHeaderVariables(value)
+	if err != nil {
+		log.DefaultLogger.Warnf("invalid variable header, skip, value: %s, error: %v", value, err)
 		return nil
 	}
-	return &plainHeaderFormatter{
-		isAppend:    append,
-		staticValue: value,
+	return &variableHeaderFormatter{
+		isAppend: append,
+		parts:    parts,
+	}
+}
+
+// parseHeaderVariables splits the value into the static strings and the %VARIABLE%s,
+// the variables are the request info fields same as the access log, and %% is a literal %
+func parseHeaderVariables(value string) ([]headerValuePart, error) {
+	var parts []headerValuePart
+	var static []byte
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' {
+			static = append(static, value[i])
+			continue
+		}
+		end := strings.IndexByte(value[i+1:], '%')
+		if end == -1 {
+			return nil, fmt.Errorf("unclosed variable at %d", i)
+		}
+		name := value[i+1 : i+1+end]
+		i += end + 1
+		if name == "" {
+			static = append(static, '%')
+			continue
+		}
+		getter, ok := log.RequestInfoFuncMap[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %s", name)
+		}
+		if len(static) > 0 {
+			parts = append(parts, headerValuePart{static: string(static)})
+			static = static[:0]
+		}
+		parts = append(parts, headerValuePart{variable: getter})
+	}
+	if len(static) > 0 {
+		parts = append(parts, headerValuePart{static: string(static)})
+	}
+	return parts, nil
+}
+
+// headerValuePart is a static string if the variable is nil
+type headerValuePart struct {
+	static   string
+	variable func(info types.RequestInfo) string
+}
+
+type variableHeaderFormatter struct {
+	isAppend bool
+	parts    []headerValuePart
+}
+
+func (f *variableHeaderFormatter) append() bool {
+	return f.isAppend
+}
+
+func (f *variableHeaderFormatter) format(requestInfo types.RequestInfo) string {
+	if len(f.parts) == 1 {
+		return f.formatPart(f.parts[0], requestInfo)
+	}
+	var b strings.Builder
+	for _, part := range f.parts {
+		b.WriteString(f.formatPart(part, requestInfo))
+	}
+	return b.String()
+}
+
+func (f *variableHeaderFormatter) formatPart(part headerValuePart, requestInfo types.RequestInfo) string {
+	if part.variable == nil {
+		return part.static
+	}
+	if requestInfo == nil {
+		return ""
 	}
+	return part.variable(requestInfo)
 }
 
 type plainHeaderFormatter struct {
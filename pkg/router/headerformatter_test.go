@@ -18,8 +18,13 @@
 package router
 
 import (
+	"net"
 	"reflect"
 	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
 )
 
 func Test_getHeaderFormatter(t *testing.T) {
@@ -110,3 +115,59 @@ func Test_plainHeaderFormatter_format(t *testing.T) {
 		})
 	}
 }
+
+func Test_variableHeaderFormatter(t *testing.T) {
+	info := network.NewRequestInfo()
+	info.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345})
+	info.SetProtocol(protocol.HTTP1)
+	for value, expected := range map[string]string{
+		"%DOWNSTREAM_REMOTE_ADDRESS%":                     "127.0.0.1:12345",
+		"client=%DOWNSTREAM_REMOTE_ADDRESS%;p=%PROTOCOL%": "client=127.0.0.1:12345;p=Http1",
+		"100%%":           "100%",
+		"%UPSTREAM_HOST%": "",
+	} {
+		formatter := getHeaderFormatter(value, true)
+		if formatter == nil {
+			t.Fatalf("variable header %s is not supported", value)
+		}
+		if got := formatter.format(info); got != expected {
+			t.Errorf("variable header %s expected %s, but got %s", value, expected, got)
+		}
+		if !formatter.append() {
+			t.Errorf("variable header %s should be appended", value)
+		}
+	}
+	for _, value := range []string{"%UNKNOWN%", "50%", "%DOWNSTREAM_REMOTE_ADDRESS"} {
+		if formatter := getHeaderFormatter(value, false); formatter != nil {
+			t.Errorf("invalid variable header %s is supported", value)
+		}
+	}
+}
+
+func TestHeaderParser(t *testing.T) {
+	if NewHeaderParser(nil, nil) != nil {
+		t.Fatal("empty header parser should be nil")
+	}
+	FALSE := false
+	parser := NewHeaderParser([]*v2.HeaderValueOption{
+		{Header: &v2.HeaderValue{Key: "x-env", Value: "prod"}, Append: &FALSE},
+		{Header: &v2.HeaderValue{Key: "x-forwarded-for", Value: "%DOWNSTREAM_REMOTE_ADDRESS%"}},
+	}, []string{"X-Powered-By"})
+	info := network.NewRequestInfo()
+	info.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345})
+	headers := protocol.CommonHeader{
+		"x-env":           "test",
+		"x-forwarded-for": "10.0.0.1",
+		"x-powered-by":    "mosn",
+		"server":          "mosn",
+	}
+	parser.EvaluateHeaders(headers, info)
+	expected := protocol.CommonHeader{
+		"x-env":           "prod",
+		"x-forwarded-for": "10.0.0.1,127.0.0.1:12345",
+		"server":          "mosn",
+	}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("headers are not expected: %v", headers)
+	}
+}
@@ -154,3 +154,88 @@ func TestRegexRouteRuleImpl(t *testing.T) {
 		}
 	}
 }
+
+func TestRouteHeaderAndQueryMatch(t *testing.T) {
+	virtualHost, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Name:    "test",
+		Domains: []string{"*"},
+		Routers: []v2.Router{
+			{
+				RouterConfig: v2.RouterConfig{
+					Match: v2.RouterMatch{
+						Prefix: "/",
+						Headers: []v2.HeaderMatcher{
+							{Name: "x-api-version", Value: "v2.*", Regex: true},
+							{Name: "x-tenant", Present: true},
+							{Name: "x-debug", Invert: true, Present: true},
+						},
+						QueryParameters: []v2.QueryParameterMatcher{
+							{Name: "region", Value: "cn-.*", Regex: true},
+							{Name: "beta", Present: true},
+						},
+					},
+					Route: v2.RouteAction{RouterActionConfig: v2.RouterActionConfig{ClusterName: "v2"}},
+				},
+			},
+			{
+				RouterConfig: v2.RouterConfig{
+					Match: v2.RouterMatch{
+						Prefix: "/",
+						Headers: []v2.HeaderMatcher{
+							{Name: "user-agent", Prefix: "curl/"},
+							{Name: "x-client", Suffix: ".internal"},
+						},
+					},
+					Route: v2.RouteAction{RouterActionConfig: v2.RouterActionConfig{ClusterName: "internal"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create virtual host failed: %v", err)
+	}
+	testCases := []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{map[string]string{"x-api-version": "v2.1", "x-tenant": "a", protocol.MosnHeaderQueryStringKey: "region=cn-east&beta=1"}, "v2"},
+		// the invert matcher rejects the header present
+		{map[string]string{"x-api-version": "v2.1", "x-tenant": "a", "x-debug": "1", protocol.MosnHeaderQueryStringKey: "region=cn-east&beta=1"}, ""},
+		{map[string]string{"x-api-version": "v2.1", protocol.MosnHeaderQueryStringKey: "region=cn-east&beta=1"}, ""},
+		{map[string]string{"x-api-version": "v1", "x-tenant": "a", protocol.MosnHeaderQueryStringKey: "region=cn-east&beta=1"}, ""},
+		{map[string]string{"x-api-version": "v2.1", "x-tenant": "a", protocol.MosnHeaderQueryStringKey: "region=us-east&beta=1"}, ""},
+		// the query parameters are required
+		{map[string]string{"x-api-version": "v2.1", "x-tenant": "a"}, ""},
+		{map[string]string{"user-agent": "curl/7.0", "x-client": "a.internal"}, "internal"},
+		{map[string]string{"user-agent": "wget/1.0", "x-client": "a.internal"}, ""},
+		{map[string]string{"user-agent": "curl/7.0", "x-client": "a.external"}, ""},
+	}
+	for i, tc := range testCases {
+		headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/"}
+		for k, v := range tc.headers {
+			headers[k] = v
+		}
+		route := virtualHost.GetRouteFromEntries(headers, 1)
+		var cluster string
+		if route != nil {
+			cluster = route.RouteRule().ClusterName()
+		}
+		if cluster != tc.expected {
+			t.Errorf("#%d expected route to %q, but got %q", i, tc.expected, cluster)
+		}
+	}
+}
+
+func TestRouteMatchValidation(t *testing.T) {
+	for i, match := range []v2.RouterMatch{
+		{Prefix: "/", Headers: []v2.HeaderMatcher{{Name: "x-version", Value: "a)", Regex: true}}},
+		{Prefix: "/", Headers: []v2.HeaderMatcher{{Name: "x-version", Prefix: "v", Suffix: "1"}}},
+		{Prefix: "/", QueryParameters: []v2.QueryParameterMatcher{{Name: "version", Value: "(", Regex: true}}},
+		{Prefix: "/", QueryParameters: []v2.QueryParameterMatcher{{Value: "v1"}}},
+	} {
+		route := &v2.Router{RouterConfig: v2.RouterConfig{Match: match}}
+		if _, err := NewRouteRuleImplBase(nil, route); err == nil {
+			t.Errorf("#%d invalid route match is accepted", i)
+		}
+	}
+}
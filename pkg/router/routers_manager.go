@@ -69,6 +69,9 @@ func (rm *routersManagerImpl) AddOrUpdateRouters(routerConfig *v2.RouterConfigur
 			log.DefaultLogger.Alertf(types.ErrorKeyRouteUpdate, "error: %v", err)
 			return err
 		}
+		if err := validateRouters(routerConfig, routers); err != nil {
+			return err
+		}
 		rw.mux.Lock()
 		rw.routers = routers
 		rw.routersConfig = routerConfig
@@ -80,6 +83,9 @@ func (rm *routersManagerImpl) AddOrUpdateRouters(routerConfig *v2.RouterConfigur
 		// we ignore the error when we addsd a new router
 		// becasue we may stored a nil routers, which is used in istio "RDS" mode
 		routers, _ := NewRouters(routerConfig)
+		if err := validateRouters(routerConfig, routers); err != nil {
+			return err
+		}
 		rm.routersWrapperMap.Store(routerConfig.RouterConfigName, &RoutersWrapper{
 			routers:       routers,
 			routersConfig: routerConfig,
@@ -121,12 +127,24 @@ func (rm *routersManagerImpl) AddRoute(routerConfigName, domain string, route *v
 			return ErrNoRouters
 		}
 		cfg := rw.routersConfig
+		exist, strict := getClusterChecker()
+		if exist != nil && strict {
+			for _, name := range routeClusterNames(route.Route) {
+				if !exist(name) {
+					err := fmt.Errorf("add route into domain: %s failed, cluster %s is not configured", domain, name)
+					log.DefaultLogger.Alertf(types.ErrorKeyRouteAppend, "error: %v", err)
+					return err
+				}
+			}
+		}
 		index := routers.AddRoute(domain, route)
 		if index == -1 {
 			errMsg := fmt.Sprintf("add route: %s into domain: %s failed", routerConfigName, domain)
 			log.DefaultLogger.Alertf(types.ErrorKeyRouteAppend, errMsg)
 			return errors.New(errMsg)
 		}
+		// the added route is tagged if its cluster is not configured
+		tagRoutes(routers, exist)
 		// modify config
 		routersCfg := cfg.VirtualHosts[index].Routers
 		routersCfg = append(routersCfg, *route)
@@ -20,6 +20,8 @@ package router
 import (
 	"context"
 	"errors"
+	"hash/fnv"
+	"net"
 	"strings"
 	"time"
 
@@ -69,6 +71,8 @@ type weightedClusterEntry struct {
 	clusterName                  string
 	clusterWeight                uint32
 	clusterMetadataMatchCriteria *MetadataMatchCriteriaImpl
+	requestHeadersParser         *headerParser
+	stats                        types.Metrics
 }
 
 type Matchable interface {
@@ -85,6 +89,7 @@ type RouteBase interface {
 type policy struct {
 	retryPolicy  *retryPolicyImpl
 	shadowPolicy *shadowPolicyImpl //TODO: not implement yet
+	hashPolicy   *hashPolicyImpl
 }
 
 func (p *policy) RetryPolicy() types.RetryPolicy {
@@ -95,10 +100,40 @@ func (p *policy) ShadowPolicy() types.ShadowPolicy {
 	return p.shadowPolicy
 }
 
+func (p *policy) HashPolicy() types.HashPolicy {
+	return p.hashPolicy
+}
+
 type retryPolicyImpl struct {
-	retryOn      bool
-	retryTimeout time.Duration
-	numRetries   uint32
+	retryOn              bool
+	retryTimeout         time.Duration
+	numRetries           uint32
+	retryOnConditions    types.RetryCondition
+	retriableStatusCodes []uint32
+}
+
+var retryConditions = map[string]types.RetryCondition{
+	v2.RetryOn5xx:                  types.RetryOn5xx,
+	v2.RetryOnConnectFailure:       types.RetryOnConnectFailure,
+	v2.RetryOnReset:                types.RetryOnReset,
+	v2.RetryOnRetriableStatusCodes: types.RetryOnRetriableStatusCodes,
+}
+
+func newRetryPolicy(config *v2.RetryPolicy) *retryPolicyImpl {
+	p := &retryPolicyImpl{
+		retryOn:              config.RetryOn,
+		retryTimeout:         config.RetryTimeout,
+		numRetries:           config.NumRetries,
+		retriableStatusCodes: config.RetriableStatusCodes,
+	}
+	for _, cond := range config.RetryOnConditions {
+		p.retryOnConditions |= retryConditions[cond]
+	}
+	// the conditions enable the retry
+	if p.retryOnConditions != 0 {
+		p.retryOn = true
+	}
+	return p
 }
 
 func (p *retryPolicyImpl) RetryOn() bool {
@@ -122,6 +157,58 @@ func (p *retryPolicyImpl) NumRetries() uint32 {
 	return p.numRetries
 }
 
+func (p *retryPolicyImpl) RetryOnConditions() types.RetryCondition {
+	if p == nil {
+		return 0
+	}
+	return p.retryOnConditions
+}
+
+func (p *retryPolicyImpl) RetriableStatusCodes() []uint32 {
+	if p == nil {
+		return nil
+	}
+	return p.retriableStatusCodes
+}
+
+type hashPolicyImpl struct {
+	policies []v2.HashPolicy
+}
+
+func (p *hashPolicyImpl) GenerateHash(headers types.HeaderMap, remoteAddr net.Addr) (uint64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	for _, policy := range p.policies {
+		if policy.Header != "" && headers != nil {
+			if value, ok := headers.Get(policy.Header); ok {
+				return hashString(value), true
+			}
+		}
+		if policy.SourceIP && remoteAddr != nil {
+			return hashString(sourceIP(remoteAddr)), true
+		}
+	}
+	return 0, false
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// sourceIP returns the ip of the address, the port is ignored so that the connections from a client get the same key
+func sourceIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
 type shadowPolicyImpl struct {
 	cluster    string
 	runtimeKey string
@@ -33,6 +33,7 @@ func getWeightedClusterEntry(weightedClusters []v2.WeightedCluster) (map[string]
 			clusterName:                  weightedCluster.Cluster.Name,
 			clusterWeight:                weightedCluster.Cluster.Weight,
 			clusterMetadataMatchCriteria: NewMetadataMatchCriteriaImpl(weightedCluster.Cluster.MetadataMatch),
+			requestHeadersParser:         getHeaderParser(weightedCluster.Cluster.RequestHeadersToAdd, nil),
 		}
 		totalWeight = totalWeight + weightedCluster.Cluster.Weight
 	}
@@ -54,6 +55,10 @@ func getRouterHeaders(headers []v2.HeaderMatcher) []*types.HeaderData {
 			},
 			Value:   header.Value,
 			IsRegex: header.Regex,
+			Prefix:  header.Prefix,
+			Suffix:  header.Suffix,
+			Present: header.Present,
+			Invert:  header.Invert,
 		}
 
 		if header.Regex {
@@ -72,6 +77,29 @@ func getRouterHeaders(headers []v2.HeaderMatcher) []*types.HeaderData {
 	return headerDatas
 }
 
+// getQueryParameterMatchers ignores the matchers with invalid regex, the route config should be validated before
+func getQueryParameterMatchers(queryParameters []v2.QueryParameterMatcher) []types.QueryParameterMatcher {
+	var matchers []types.QueryParameterMatcher
+	for _, queryParameter := range queryParameters {
+		matcher := &queryParameterMatcher{
+			name:    queryParameter.Name,
+			value:   queryParameter.Value,
+			isRegex: queryParameter.Regex,
+			present: queryParameter.Present,
+		}
+		if queryParameter.Regex {
+			pattern, err := regexp.Compile(queryParameter.Value)
+			if err != nil {
+				log.DefaultLogger.Errorf("getQueryParameterMatchers compile error")
+				continue
+			}
+			matcher.regexPattern = pattern
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers
+}
+
 func getHeaderParser(headersToAdd []*v2.HeaderValueOption, headersToRemove []string) *headerParser {
 	if headersToAdd == nil && headersToRemove == nil {
 		return nil
@@ -0,0 +1,283 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the routes' cluster references are validated by the cluster checker,
+// which is set when the clusters in cluster manager are changed. no checker means no validation.
+var (
+	validationMux  sync.RWMutex
+	validationMode v2.RouteValidationMode
+	clusterChecker func(clusterName string) bool
+)
+
+// SetRouteValidationMode sets how the routes referencing the clusters not configured are handled
+func SetRouteValidationMode(mode v2.RouteValidationMode) {
+	validationMux.Lock()
+	defer validationMux.Unlock()
+	validationMode = mode
+}
+
+// SetClusterChecker sets the function that checks whether a cluster is configured,
+// the routes of the routers in manager are validated again.
+// it is registered as the cluster manager's ClustersChangeCallback
+func SetClusterChecker(checker func(clusterName string) bool) {
+	validationMux.Lock()
+	clusterChecker = checker
+	validationMux.Unlock()
+	RevalidateClusterReferences()
+}
+
+func getClusterChecker() (func(clusterName string) bool, bool) {
+	validationMux.RLock()
+	defer validationMux.RUnlock()
+	return clusterChecker, validationMode == v2.ROUTE_VALIDATION_STRICT
+}
+
+// danglingClusterReferences returns the clusters referenced by the router config but not configured
+func danglingClusterReferences(routerConfig *v2.RouterConfiguration, exist func(clusterName string) bool) []string {
+	var dangling []string
+	for _, vh := range routerConfig.VirtualHosts {
+		for _, r := range vh.Routers {
+			for _, name := range routeClusterNames(r.Route) {
+				if !exist(name) {
+					dangling = append(dangling, fmt.Sprintf("virtual host %s references cluster %s", vh.Name, name))
+				}
+			}
+		}
+	}
+	return dangling
+}
+
+func routeClusterNames(action v2.RouteAction) []string {
+	var names []string
+	if action.ClusterName != "" {
+		names = append(names, action.ClusterName)
+	}
+	for _, wc := range action.WeightedClusters {
+		if wc.Cluster.Name != "" {
+			names = append(names, wc.Cluster.Name)
+		}
+	}
+	return names
+}
+
+// ValidateClusterReferences checks the router config's cluster references before it is installed,
+// the dangling references are rejected in the strict mode.
+// the config manager calls it before the router config is stored, so a rejected router config is not stored
+func ValidateClusterReferences(routerConfig *v2.RouterConfiguration) error {
+	exist, strict := getClusterChecker()
+	if exist == nil || !strict {
+		return nil
+	}
+	if dangling := danglingClusterReferences(routerConfig, exist); len(dangling) > 0 {
+		err := fmt.Errorf("router %s has dangling cluster references: %v", routerConfig.RouterConfigName, dangling)
+		log.DefaultLogger.Alertf(types.ErrorKeyRouteUpdate, "error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// validateRouters validates the router config's cluster references before it is installed,
+// the dangling references are rejected in the strict mode, or logged and the routes are tagged.
+func validateRouters(routerConfig *v2.RouterConfiguration, routers types.Routers) error {
+	if err := ValidateClusterReferences(routerConfig); err != nil {
+		return err
+	}
+	exist, _ := getClusterChecker()
+	if exist == nil {
+		return nil
+	}
+	for _, d := range danglingClusterReferences(routerConfig, exist) {
+		log.DefaultLogger.Warnf(RouterLogFormat, "validation", "validateRouters", "router "+routerConfig.RouterConfigName+" "+d+" not configured")
+	}
+	tagRoutes(routers, exist)
+	return nil
+}
+
+// RevalidateClusterReferences tags the routes of all routers in manager again,
+// it should be called when the clusters are added or removed.
+func RevalidateClusterReferences() {
+	singletonMutex.Lock()
+	rm := routersManagerInstance
+	singletonMutex.Unlock()
+	if rm == nil {
+		return
+	}
+	exist, _ := getClusterChecker()
+	rm.routersWrapperMap.Range(func(key, value interface{}) bool {
+		if rw, ok := value.(*RoutersWrapper); ok {
+			tagRoutes(rw.GetRouters(), exist)
+		}
+		return true
+	})
+}
+
+// clusterReference is implemented by the routes that reference clusters
+type clusterReference interface {
+	clusterNames() []string
+	setClusterConfigured(configured bool) bool
+}
+
+func tagRoutes(routers types.Routers, exist func(clusterName string) bool) {
+	ri, ok := routers.(*routersImpl)
+	if !ok || ri == nil {
+		return
+	}
+	for _, vh := range ri.virtualHosts {
+		vhi, ok := vh.(*VirtualHostImpl)
+		if !ok {
+			continue
+		}
+		vhi.mutex.RLock()
+		for _, r := range vhi.routes {
+			ref, ok := r.(clusterReference)
+			if !ok {
+				continue
+			}
+			configured := true
+			if exist != nil {
+				for _, name := range ref.clusterNames() {
+					if !exist(name) {
+						configured = false
+						break
+					}
+				}
+			}
+			if ref.setClusterConfigured(configured) {
+				log.DefaultLogger.Infof(RouterLogFormat, "validation", "tagRoutes", fmt.Sprintf("route to clusters %v in virtual host %s, configured: %v", ref.clusterNames(), vhi.Name(), configured))
+			}
+		}
+		vhi.mutex.RUnlock()
+	}
+}
+
+func (rri *RouteRuleImplBase) clusterNames() []string {
+	return routeClusterNames(rri.routerAction)
+}
+
+// setClusterConfigured returns true if the tag is changed
+func (rri *RouteRuleImplBase) setClusterConfigured(configured bool) bool {
+	var missing uint32
+	if !configured {
+		missing = 1
+	}
+	return atomic.SwapUint32(&rri.clusterMissing, missing) != missing
+}
+
+// ClusterConfigured returns false if the route references clusters not configured
+func (rri *RouteRuleImplBase) ClusterConfigured() bool {
+	return atomic.LoadUint32(&rri.clusterMissing) == 0
+}
+
+// validateWeightedClusters rejects the weighted clusters that can not be selected by the weights,
+// so a router config is installed with all the weights or not installed at all
+func validateWeightedClusters(weightedClusters []v2.WeightedCluster) error {
+	if len(weightedClusters) == 0 {
+		return nil
+	}
+	names := make(map[string]bool, len(weightedClusters))
+	var totalWeight uint64
+	for _, wc := range weightedClusters {
+		if wc.Cluster.Name == "" {
+			return fmt.Errorf("weighted cluster has no name")
+		}
+		if names[wc.Cluster.Name] {
+			return fmt.Errorf("duplicate weighted cluster %s", wc.Cluster.Name)
+		}
+		names[wc.Cluster.Name] = true
+		totalWeight += uint64(wc.Cluster.Weight)
+	}
+	if totalWeight == 0 {
+		return fmt.Errorf("total weight of the weighted clusters is zero")
+	}
+	if totalWeight > math.MaxUint32 {
+		return fmt.Errorf("total weight of the weighted clusters %d overflows", totalWeight)
+	}
+	return nil
+}
+
+// validateRouteMatch rejects the header and query parameter matchers that can not be compiled,
+// the regex are RE2 patterns
+func validateRouteMatch(match v2.RouterMatch) error {
+	for _, header := range match.Headers {
+		if header.Name == "" {
+			return fmt.Errorf("header matcher has no name")
+		}
+		specifiers := 0
+		for _, set := range []bool{header.Regex, header.Prefix != "", header.Suffix != "", header.Present} {
+			if set {
+				specifiers++
+			}
+		}
+		if specifiers > 1 {
+			return fmt.Errorf("header matcher %s has more than one of regex, prefix, suffix and present", header.Name)
+		}
+		if header.Regex {
+			if _, err := regexp.Compile(header.Value); err != nil {
+				return fmt.Errorf("header matcher %s has invalid regex: %v", header.Name, err)
+			}
+		}
+	}
+	for _, query := range match.QueryParameters {
+		if query.Name == "" {
+			return fmt.Errorf("query parameter matcher has no name")
+		}
+		if query.Regex {
+			if query.Present {
+				return fmt.Errorf("query parameter matcher %s has both regex and present", query.Name)
+			}
+			if _, err := regexp.Compile(query.Value); err != nil {
+				return fmt.Errorf("query parameter matcher %s has invalid regex: %v", query.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isExactHeaderMatcher returns true if the header matcher matches the value exactly
+func isExactHeaderMatcher(header v2.HeaderMatcher) bool {
+	return !header.Regex && header.Prefix == "" && header.Suffix == "" && !header.Present && !header.Invert
+}
+
+// validateRewrite rejects the conflicting rewrites and the invalid regex rewrite pattern
+func validateRewrite(action v2.RouteAction) error {
+	if action.RegexRewrite != nil {
+		if action.PrefixRewrite != "" {
+			return fmt.Errorf("route has both prefix rewrite and regex rewrite")
+		}
+		if _, err := regexp.Compile(action.RegexRewrite.Pattern); err != nil {
+			return fmt.Errorf("route has invalid regex rewrite pattern: %v", err)
+		}
+	}
+	if action.HostRewrite != "" && action.AutoHostRewrite {
+		return fmt.Errorf("route has both host rewrite and auto host rewrite")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func newValidationRouterConfig(name string, clusterName string, weighted ...string) *v2.RouterConfiguration {
+	action := v2.RouteAction{
+		RouterActionConfig: v2.RouterActionConfig{
+			ClusterName: clusterName,
+		},
+	}
+	for _, w := range weighted {
+		action.WeightedClusters = append(action.WeightedClusters, v2.WeightedCluster{
+			Cluster: v2.ClusterWeight{
+				ClusterWeightConfig: v2.ClusterWeightConfig{
+					Name:   w,
+					Weight: 100 / uint32(len(weighted)),
+				},
+			},
+		})
+	}
+	return &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: name,
+		},
+		VirtualHosts: []*v2.VirtualHost{
+			{
+				Name:    name + "_vh",
+				Domains: []string{"*"},
+				Routers: []v2.Router{
+					{
+						RouterConfig: v2.RouterConfig{
+							Match: v2.RouterMatch{Prefix: "/"},
+							Route: action,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func routeClusterConfigured(t *testing.T, rm *routersManagerImpl, name string) bool {
+	rw := rm.GetRouterWrapperByName(name)
+	if rw == nil {
+		t.Fatalf("router %s not found", name)
+	}
+	vh := rw.GetRouters().(*routersImpl).virtualHosts[0].(*VirtualHostImpl)
+	return vh.routes[0].RouteRule().ClusterConfigured()
+}
+
+func TestDanglingClusterReferences(t *testing.T) {
+	exist := func(clusterName string) bool {
+		return clusterName == "cluster1"
+	}
+	if dangling := danglingClusterReferences(newValidationRouterConfig("test", "cluster1"), exist); len(dangling) != 0 {
+		t.Errorf("expected no dangling references, but got %v", dangling)
+	}
+	if dangling := danglingClusterReferences(newValidationRouterConfig("test", "", "cluster1", "cluster2", "cluster3"), exist); len(dangling) != 2 {
+		t.Errorf("expected the weighted clusters are checked, but got %v", dangling)
+	}
+}
+
+func TestRouteValidationTag(t *testing.T) {
+	clusters := map[string]bool{}
+	SetClusterChecker(func(clusterName string) bool {
+		return clusters[clusterName]
+	})
+	defer SetClusterChecker(nil)
+
+	rm := NewRouterManager().(*routersManagerImpl)
+	if err := rm.AddOrUpdateRouters(newValidationRouterConfig("test_validation_tag", "", "tag_cluster1", "tag_cluster2")); err != nil {
+		t.Fatalf("dangling references should not be rejected in warn mode, but got %v", err)
+	}
+	if routeClusterConfigured(t, rm, "test_validation_tag") {
+		t.Fatal("expected the route is tagged")
+	}
+	// the tag is cleared when all the clusters are configured
+	clusters["tag_cluster1"] = true
+	RevalidateClusterReferences()
+	if routeClusterConfigured(t, rm, "test_validation_tag") {
+		t.Fatal("expected the route is tagged, tag_cluster2 is not configured")
+	}
+	clusters["tag_cluster2"] = true
+	RevalidateClusterReferences()
+	if !routeClusterConfigured(t, rm, "test_validation_tag") {
+		t.Fatal("expected the route tag is cleared")
+	}
+	// the route is tagged again when the cluster is removed
+	delete(clusters, "tag_cluster1")
+	RevalidateClusterReferences()
+	if routeClusterConfigured(t, rm, "test_validation_tag") {
+		t.Fatal("expected the route is tagged after the cluster is removed")
+	}
+}
+
+func TestRouteValidationStrict(t *testing.T) {
+	SetRouteValidationMode(v2.ROUTE_VALIDATION_STRICT)
+	SetClusterChecker(func(clusterName string) bool {
+		return clusterName == "strict_cluster"
+	})
+	defer func() {
+		SetRouteValidationMode(v2.ROUTE_VALIDATION_WARN)
+		SetClusterChecker(nil)
+	}()
+
+	rm := NewRouterManager().(*routersManagerImpl)
+	if err := rm.AddOrUpdateRouters(newValidationRouterConfig("test_validation_strict", "strict_cluster")); err != nil {
+		t.Fatalf("add router failed: %v", err)
+	}
+	// the update is rejected, the routers in use are not changed
+	if err := rm.AddOrUpdateRouters(newValidationRouterConfig("test_validation_strict", "unknown_cluster")); err == nil {
+		t.Fatal("expected dangling references are rejected in strict mode")
+	}
+	cfg := rm.GetRouterWrapperByName("test_validation_strict").GetRoutersConfig()
+	if cfg.VirtualHosts[0].Routers[0].Route.ClusterName != "strict_cluster" {
+		t.Fatal("expected the routers are not changed")
+	}
+	if err := rm.AddOrUpdateRouters(newValidationRouterConfig("test_validation_strict_new", "unknown_cluster")); err == nil {
+		t.Fatal("expected dangling references are rejected in strict mode")
+	}
+	if rw := rm.GetRouterWrapperByName("test_validation_strict_new"); rw != nil {
+		t.Fatal("expected the rejected routers are not added")
+	}
+	route := &v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Match: v2.RouterMatch{Prefix: "/unknown"},
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName: "unknown_cluster",
+				},
+			},
+		},
+	}
+	if err := rm.AddRoute("test_validation_strict", "*", route); err == nil {
+		t.Fatal("expected the route with dangling reference is rejected in strict mode")
+	}
+}
@@ -34,12 +34,20 @@ type VirtualHostImpl struct {
 	globalRouteConfig     *configImpl
 	requestHeadersParser  *headerParser
 	responseHeadersParser *headerParser
+	maxRequestBytes       uint64
+	perFilterConfig       map[string]interface{}
+	// hasQueryParameters is true if any route matches the query parameters
+	hasQueryParameters bool
 }
 
 func (vh *VirtualHostImpl) Name() string {
 	return vh.virtualHostName
 }
 
+func (vh *VirtualHostImpl) PerFilterConfig() map[string]interface{} {
+	return vh.perFilterConfig
+}
+
 func (vh *VirtualHostImpl) addRouteBase(route *v2.Router) error {
 	base, err := NewRouteRuleImplBase(vh, route)
 	if err != nil {
@@ -77,9 +85,12 @@ func (vh *VirtualHostImpl) addRouteBase(route *v2.Router) error {
 	if router != nil {
 		vh.mutex.Lock()
 		vh.routes = append(vh.routes, router)
+		if len(route.Match.QueryParameters) > 0 {
+			vh.hasQueryParameters = true
+		}
 		// make fast index, used in certain scenarios
 		// TODO: rule can be extended
-		if len(route.Match.Headers) == 1 && !route.Match.Headers[0].Regex {
+		if len(route.Match.Headers) == 1 && isExactHeaderMatcher(route.Match.Headers[0]) && len(route.Match.QueryParameters) == 0 {
 			key := route.Match.Headers[0].Name
 			value := route.Match.Headers[0].Value
 			valueMap, ok := vh.fastIndex[key]
@@ -101,6 +112,9 @@ func (vh *VirtualHostImpl) addRouteBase(route *v2.Router) error {
 func (vh *VirtualHostImpl) GetRouteFromEntries(headers types.HeaderMap, randomValue uint64) types.Route {
 	vh.mutex.RLock()
 	defer vh.mutex.RUnlock()
+	if vh.hasQueryParameters {
+		headers = &routeMatchHeaders{HeaderMap: headers}
+	}
 	for _, route := range vh.routes {
 		if routeEntry := route.Match(headers, randomValue); routeEntry != nil {
 			return routeEntry
@@ -112,6 +126,9 @@ func (vh *VirtualHostImpl) GetRouteFromEntries(headers types.HeaderMap, randomVa
 func (vh *VirtualHostImpl) GetAllRoutesFromEntries(headers types.HeaderMap, randomValue uint64) []types.Route {
 	vh.mutex.RLock()
 	defer vh.mutex.RUnlock()
+	if vh.hasQueryParameters {
+		headers = &routeMatchHeaders{HeaderMap: headers}
+	}
 	var routes []types.Route
 	for _, route := range vh.routes {
 		if r := route.Match(headers, randomValue); r != nil {
@@ -143,6 +160,7 @@ func (vh *VirtualHostImpl) RemoveAllRoutes() {
 	vh.fastIndex = make(map[string]map[string]types.Route)
 	// clear the routes
 	vh.routes = vh.routes[:0]
+	vh.hasQueryParameters = false
 	return
 }
 
@@ -150,8 +168,10 @@ func NewVirtualHostImpl(virtualHost *v2.VirtualHost) (*VirtualHostImpl, error) {
 	vhImpl := &VirtualHostImpl{
 		virtualHostName:       virtualHost.Name,
 		fastIndex:             make(map[string]map[string]types.Route),
-		requestHeadersParser:  getHeaderParser(virtualHost.RequestHeadersToAdd, nil),
+		requestHeadersParser:  getHeaderParser(virtualHost.RequestHeadersToAdd, virtualHost.RequestHeadersToRemove),
 		responseHeadersParser: getHeaderParser(virtualHost.ResponseHeadersToAdd, virtualHost.ResponseHeadersToRemove),
+		maxRequestBytes:       virtualHost.MaxRequestBytes,
+		perFilterConfig:       virtualHost.PerFilterConfig,
 	}
 	for _, route := range virtualHost.Routers {
 		if err := vhImpl.addRouteBase(&route); err != nil {
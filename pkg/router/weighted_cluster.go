@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"net"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// weightedClusterSelector is implemented by the route rules that support the weighted clusters
+type weightedClusterSelector interface {
+	weightedCluster(hashKey uint64, hashed bool) *weightedClusterEntry
+}
+
+// weightedRoute is the route of a request that is routed to a weighted cluster
+type weightedRoute struct {
+	types.Route
+	rule *weightedRouteRule
+}
+
+func (r *weightedRoute) RouteRule() types.RouteRule {
+	return r.rule
+}
+
+// weightedRouteRule fixes the cluster selected for a request, so the cluster snapshot,
+// the metadata match and the request headers of the request are consistent
+type weightedRouteRule struct {
+	types.RouteRule
+	cluster *weightedClusterEntry
+}
+
+func (r *weightedRouteRule) ClusterName() string {
+	return r.cluster.clusterName
+}
+
+func (r *weightedRouteRule) FinalizeRequestHeaders(headers types.HeaderMap, requestInfo types.RequestInfo) {
+	r.cluster.requestHeadersParser.evaluateHeaders(headers, requestInfo)
+	r.RouteRule.FinalizeRequestHeaders(headers, requestInfo)
+}
+
+// selectWeightedCluster selects the weighted cluster of the matched route for the request.
+// if the route has a hash policy, the requests with the same hash key are routed to the same cluster,
+// so the affinity of the hash based load balancers still works
+func selectWeightedCluster(ctx context.Context, headers types.HeaderMap, route types.Route) types.Route {
+	rule := route.RouteRule()
+	if rule == nil {
+		return route
+	}
+	selector, ok := rule.(weightedClusterSelector)
+	if !ok {
+		return route
+	}
+	var hashKey uint64
+	var hashed bool
+	if policy := rule.Policy(); policy != nil && policy.HashPolicy() != nil {
+		var remoteAddr net.Addr
+		if conn, ok := mosnctx.Get(ctx, types.ContextKeyConnection).(types.Connection); ok {
+			remoteAddr = conn.RemoteAddr()
+		}
+		hashKey, hashed = policy.HashPolicy().GenerateHash(headers, remoteAddr)
+	}
+	cluster := selector.weightedCluster(hashKey, hashed)
+	if cluster == nil {
+		return route
+	}
+	if cluster.stats != nil {
+		cluster.stats.Counter(metrics.WeightedClusterSelected).Inc(1)
+	}
+	return &weightedRoute{
+		Route: route,
+		rule: &weightedRouteRule{
+			RouteRule: rule,
+			cluster:   cluster,
+		},
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/mtls"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"sofastack.io/sofa-mosn/pkg/utils"
 )
@@ -132,6 +133,26 @@ func (adapter *ListenerAdapter) DeleteListener(serverName string, listenerName s
 	return nil
 }
 
+// UpdateListenerLimits updates the max connections and the max concurrent streams of the listener,
+// the limits take effects on the new connections and streams
+func (adapter *ListenerAdapter) UpdateListenerLimits(serverName string, listenerName string, maxConnections, maxConcurrentStreams uint32) error {
+	connHandler := adapter.findHandler(serverName)
+	if connHandler == nil {
+		return fmt.Errorf("UpdateListenerLimits error, servername = %s not found", serverName)
+	}
+
+	if ln := connHandler.FindListenerByName(listenerName); ln != nil {
+		cfg := *ln.Config() // should clone a config
+		cfg.MaxConnections = maxConnections
+		cfg.MaxConcurrentStreams = maxConcurrentStreams
+		if _, err := connHandler.AddOrUpdateListener(&cfg, nil, nil); err != nil {
+			return fmt.Errorf("connHandler.UpdateListenerLimits called error, server:%s, error: %s", serverName, err.Error())
+		}
+		return nil
+	}
+	return fmt.Errorf("listener %s is not found", listenerName)
+}
+
 func (adapter *ListenerAdapter) UpdateListenerTLS(serverName string, listenerName string, inspector bool, tlsConfigs []v2.TLSConfig) error {
 	connHandler := adapter.findHandler(serverName)
 	if connHandler == nil {
@@ -159,3 +180,25 @@ func (adapter *ListenerAdapter) UpdateListenerTLS(serverName string, listenerNam
 	return fmt.Errorf("listener %s is not found", listenerName)
 
 }
+
+// UpdateTLSContext updates the tls contexts of the listener, it is used by the config reloading and the xds.
+// The new handshakes use the new tls contexts, the established connections keep their sessions.
+// The result is counted by the tls reload metrics
+func (adapter *ListenerAdapter) UpdateTLSContext(listenerName string, tlsConfigs []v2.TLSConfig) error {
+	err := adapter.updateTLSContext(listenerName, tlsConfigs)
+	mtls.RecordTLSReload(err)
+	return err
+}
+
+func (adapter *ListenerAdapter) updateTLSContext(listenerName string, tlsConfigs []v2.TLSConfig) error {
+	// the listener is found in the default server first
+	if ln := adapter.FindListenerByName("", listenerName); ln != nil {
+		return adapter.UpdateListenerTLS("", listenerName, ln.Config().Inspector, tlsConfigs)
+	}
+	for serverName, connHandler := range adapter.connHandlerMap {
+		if ln := connHandler.FindListenerByName(listenerName); ln != nil {
+			return adapter.UpdateListenerTLS(serverName, listenerName, ln.Config().Inspector, tlsConfigs)
+		}
+	}
+	return fmt.Errorf("listener %s is not found", listenerName)
+}
@@ -6,12 +6,18 @@ import (
 	"io"
 	"net"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/mtls/certtool"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -376,3 +382,325 @@ func TestFindListenerByName(t *testing.T) {
 		t.Fatal("expected find listener, but not")
 	}
 }
+
+func TestMaxConnectionsAndUpdate(t *testing.T) {
+	addrStr := "127.0.0.1:8084"
+	name := "listener5"
+	cfg := baseListenerConfig(addrStr, name)
+	cfg.MaxConnections = 1
+	nfcfs := []types.NetworkFilterChainFactory{
+		&mockNetworkFilterFactory{},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg, nfcfs, nil); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+
+	// alive returns true if the connection is not closed by server in a second
+	alive := func() bool {
+		conn, err := tls.Dial("tcp", addrStr, &tls.Config{
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return false
+		}
+		// the connections are closed by the listener's close
+		readChan := make(chan error, 1)
+		go func() {
+			buf := make([]byte, 10)
+			_, err := conn.Read(buf)
+			readChan <- err
+		}()
+		select {
+		case <-readChan:
+			conn.Close()
+			return false
+		case <-time.After(time.Second):
+			return true
+		}
+	}
+	if !alive() {
+		t.Fatal("the first connection should be accepted")
+	}
+	if alive() {
+		t.Fatal("the connection beyond the max connections should be closed")
+	}
+	if err := GetListenerAdapterInstance().UpdateListenerLimits(testServerName, name, 2, 10); err != nil {
+		t.Fatalf("update listener limits failed, %v", err)
+	}
+	if !alive() {
+		t.Fatal("the connection should be accepted after the limit updated")
+	}
+	if ln := GetListenerAdapterInstance().FindListenerByName(testServerName, name); ln.Config().MaxConnections != 2 || ln.Config().MaxConcurrentStreams != 10 {
+		t.Errorf("the listener config is not updated: %d, %d", ln.Config().MaxConnections, ln.Config().MaxConcurrentStreams)
+	}
+}
+
+func TestReserveConnection(t *testing.T) {
+	al := &activeListener{maxConnections: 10}
+	var wg sync.WaitGroup
+	var accepted int64
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if al.reserveConnection(true) {
+				atomic.AddInt64(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 10 || al.numConnections != 10 {
+		t.Fatalf("expected 10 connections reserved, but got %d, %d", accepted, al.numConnections)
+	}
+	// the transferred connections are not limited
+	if !al.reserveConnection(false) {
+		t.Fatal("the transferred connection should be reserved")
+	}
+	al.releaseConnection()
+	al.releaseConnection()
+	if !al.reserveConnection(true) {
+		t.Fatal("the released connection should be reserved again")
+	}
+}
+
+func TestUpdateTLSContext(t *testing.T) {
+	addrStr := "127.0.0.1:8085"
+	name := "listener6"
+	nfcfs := []types.NetworkFilterChainFactory{
+		&mockNetworkFilterFactory{},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, baseListenerConfig(addrStr, name), nfcfs, nil); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+	dial := func() (*tls.Conn, error) {
+		return tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", addrStr, &tls.Config{
+			InsecureSkipVerify: true,
+		})
+	}
+	established, err := dial()
+	if err != nil {
+		t.Fatal("dial tls failed", err)
+	}
+	defer established.Close()
+
+	stats := metrics.NewTLSStats()
+	success := stats.Counter(metrics.TLSReloadSuccess).Count()
+	failed := stats.Counter(metrics.TLSReloadFailed).Count()
+	priv, err := certtool.GeneratePrivateKey("P256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := certtool.CreateTemplate("updated.mosn", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := certtool.SignCertificate(tmpl, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GetListenerAdapterInstance().UpdateTLSContext(name, []v2.TLSConfig{
+		{
+			Status:     true,
+			CertChain:  cert.CertPem,
+			PrivateKey: cert.KeyPem,
+		},
+	}); err != nil {
+		t.Fatalf("update tls context failed %v", err)
+	}
+	if count := stats.Counter(metrics.TLSReloadSuccess).Count() - success; count != 1 {
+		t.Errorf("expected 1 reload success, but got %d", count)
+	}
+	// the new handshakes use the new certificate
+	conn, err := dial()
+	if err != nil {
+		t.Fatal("dial tls failed", err)
+	}
+	if cn := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != "updated.mosn" {
+		t.Errorf("expected the new certificate, but got %s", cn)
+	}
+	conn.Close()
+	// the established connection keeps its session
+	established.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := established.Read(make([]byte, 10)); err == nil || err == io.EOF {
+		t.Errorf("the established connection should not be closed, but got %v", err)
+	}
+	// the listener is not found
+	if err := GetListenerAdapterInstance().UpdateTLSContext("not_exists", nil); err == nil {
+		t.Error("expected update tls context failed")
+	}
+	if count := stats.Counter(metrics.TLSReloadFailed).Count() - failed; count != 1 {
+		t.Errorf("expected 1 reload failure, but got %d", count)
+	}
+}
+
+func TestFilterChainsMatchServerName(t *testing.T) {
+	addrStr := "127.0.0.1:8086"
+	name := "listener7"
+	newCert := func(cn string) v2.TLSConfig {
+		priv, err := certtool.GeneratePrivateKey("P256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl, err := certtool.CreateTemplate(cn, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := certtool.SignCertificate(tmpl, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v2.TLSConfig{
+			Status:     true,
+			CertChain:  cert.CertPem,
+			PrivateKey: cert.KeyPem,
+		}
+	}
+	newChain := func(chainName string, serverNames ...string) v2.FilterChain {
+		fc := v2.FilterChain{
+			FilterChainConfig: v2.FilterChainConfig{
+				Name: chainName,
+				Filters: []v2.Filter{
+					{
+						Type: "chain_name",
+						Config: map[string]interface{}{
+							"name": chainName,
+						},
+					},
+				},
+			},
+			TLSContexts: []v2.TLSConfig{newCert(chainName + ".mosn")},
+		}
+		if len(serverNames) > 0 {
+			fc.MatchConfig = &v2.FilterChainMatchConfig{ServerNames: serverNames}
+		}
+		return fc
+	}
+	listenerConfig := baseListenerConfig(addrStr, name)
+	listenerConfig.FilterChains = []v2.FilterChain{
+		newChain("a", "a.example.com"),
+		newChain("default"),
+		newChain("wildcard", "*.foo.com"),
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, listenerConfig, nil, nil); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+
+	// request returns the certificate's common name and the name of the filter chain that handles the connection
+	request := func(serverName string) (string, string) {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", addrStr, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Fatalf("dial tls with server name %s failed: %v", serverName, err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 32)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read from the connection with server name %s failed: %v", serverName, err)
+		}
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, string(buf[:n])
+	}
+	for _, tc := range []struct {
+		serverName string
+		chain      string
+	}{
+		{"a.example.com", "a"},
+		{"A.Example.com", "a"},
+		{"b.example.com", "default"},
+		{"www.foo.com", "wildcard"},
+		{"", "default"},
+	} {
+		if cn, chain := request(tc.serverName); cn != tc.chain+".mosn" || chain != tc.chain {
+			t.Errorf("server name %s expected filter chain %s, but got certificate %s and filter chain %s", tc.serverName, tc.chain, cn, chain)
+		}
+	}
+
+	// update the server names of the filter chains
+	updated := *listenerConfig
+	updated.FilterChains = []v2.FilterChain{
+		newChain("a", "*.example.com"),
+		newChain("default"),
+	}
+	nfcfs := []types.NetworkFilterChainFactory{
+		&mockNetworkFilterFactory{},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, &updated, nfcfs, nil); err != nil {
+		t.Fatalf("update listener failed %v", err)
+	}
+	if cn, chain := request("b.example.com"); cn != "a.mosn" || chain != "a" {
+		t.Errorf("expected the updated filter chain a, but got certificate %s and filter chain %s", cn, chain)
+	}
+	if cn, chain := request("www.foo.com"); cn != "default.mosn" || chain != "default" {
+		t.Errorf("expected the default filter chain, but got certificate %s and filter chain %s", cn, chain)
+	}
+}
+
+func TestUseProxyProto(t *testing.T) {
+	addrStr := "127.0.0.1:8087"
+	name := "listener8"
+	cfg := baseListenerConfig(addrStr, name)
+	cfg.FilterChains[0].TLSContexts = nil
+	cfg.UseProxyProto = true
+	nfcfs := []types.NetworkFilterChainFactory{
+		&mockAddrFilterFactory{},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg, nfcfs, nil); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+
+	// request sends the header and the data in one packet, returns the addresses of the connection in mosn
+	request := func(header []byte) (string, error) {
+		conn, err := net.DialTimeout("tcp", addrStr, time.Second)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write(append(header, "ping"...)); err != nil {
+			return "", err
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 128)
+		n, err := conn.Read(buf)
+		return string(buf[:n]), err
+	}
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+	v2Header, _ := proxyprotocol.EncodeHeader(proxyprotocol.Version2, src, dst)
+	for _, header := range [][]byte{
+		[]byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n"),
+		v2Header,
+	} {
+		if addrs, err := request(header); err != nil || addrs != "192.168.1.1:56324 10.0.0.1:443" {
+			t.Errorf("expected the addresses in the header %q, but got %s, error: %v", header, addrs, err)
+		}
+	}
+	// the local connection keeps its own addresses
+	localHeader, _ := proxyprotocol.EncodeHeader(proxyprotocol.Version2, nil, nil)
+	if addrs, err := request(localHeader); err != nil || !strings.HasSuffix(addrs, " "+addrStr) {
+		t.Errorf("expected the local address %s, but got %s, error: %v", addrStr, addrs, err)
+	}
+
+	failures := metrics.NewListenerStats(name).Counter(metrics.DownstreamProxyProtocolError)
+	for i, header := range [][]byte{
+		[]byte("GET / HTTP/1.1\r\n"),
+		[]byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324\r\n"),
+		[]byte("PROXY TCP4 ::1 10.0.0.1 56324 443\r\n"),
+	} {
+		if addrs, err := request(header); err == nil {
+			t.Errorf("the connection with the invalid header %q should be closed, but got %s", header, addrs)
+		}
+		if failures.Count() != int64(i+1) {
+			t.Errorf("expected %d proxy protocol errors, but got %d", i+1, failures.Count())
+		}
+	}
+}
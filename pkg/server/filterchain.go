@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/mtls"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// tlsHandshakeTimeout is the timeout of the tls handshake to select the filter chain by the server name
+var tlsHandshakeTimeout = 10 * time.Second
+
+// activeFilterChain is a filter chain selected by the server name
+type activeFilterChain struct {
+	name                    string
+	networkFiltersFactories []types.NetworkFilterChainFactory
+	// streamFiltersFactoriesStore is nil if the filter chain uses the listener's stream filters
+	streamFiltersFactoriesStore *atomic.Value // store []types.StreamFilterChainFactory
+}
+
+// filterChains selects the filter chain of a connection by the tls server name
+type filterChains struct {
+	matcher *mtls.ServerNameMatcher
+	chains  []*activeFilterChain
+}
+
+// newFilterChains returns the filter chains of the listener, it returns nil if no filter chain has server names.
+// The filters of the filter chains are created by the filter chains config
+func newFilterChains(lc *v2.Listener) *filterChains {
+	matcher := mtls.NewServerNameMatcher(lc.FilterChains)
+	if matcher == nil || lc.UseOriginalDst {
+		return nil
+	}
+	fcs := &filterChains{
+		matcher: matcher,
+	}
+	for i := range lc.FilterChains {
+		fc := &lc.FilterChains[i]
+		chain := &activeFilterChain{
+			name:                    fc.Name,
+			networkFiltersFactories: config.GetNetworkFilters(fc),
+		}
+		if len(fc.StreamFilters) > 0 {
+			chain.streamFiltersFactoriesStore = &atomic.Value{}
+			chain.streamFiltersFactoriesStore.Store(config.GetStreamFilters(fc.StreamFilters))
+		}
+		fcs.chains = append(fcs.chains, chain)
+	}
+	return fcs
+}
+
+// match returns the filter chain of the connection by the server name,
+// the tls handshake is done to get the server name, the non-tls connections use the default filter chain
+func (fcs *filterChains) match(c net.Conn) (*activeFilterChain, error) {
+	var serverName string
+	if tlsConn, ok := c.(*mtls.TLSConn); ok {
+		tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		tlsConn.SetDeadline(time.Time{})
+		serverName = tlsConn.ConnectionState().ServerName
+	}
+	chain := fcs.chains[fcs.matcher.Match(serverName)]
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[server] [listener] select filter chain %s for server name %s, remote addr: %s", chain.name, serverName, c.RemoteAddr())
+	}
+	return chain, nil
+}
@@ -36,10 +36,12 @@ import (
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/filter/accept/originaldst"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/mtls"
 	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/overload"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"sofastack.io/sofa-mosn/pkg/utils"
 )
@@ -112,11 +114,12 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 
 		// a listener with the same name must have the same configured address
 		if al.listener.Addr().String() != lc.Addr.String() ||
-			al.listener.Addr().Network() != lc.Addr.Network() {
+			al.listener.Addr().Network() != lc.Addr.Network() ||
+			al.isUDP() != (lc.Network == v2.UDP_NETWORK) {
 			return nil, errors.New("error updating listener, listen address and listen name doesn't match")
 		}
-		// currently, we just support one filter chain
-		if len(lc.FilterChains) != 1 {
+		// currently, we just support one filter chain, or the filter chains selected by the server names
+		if len(lc.FilterChains) != 1 && mtls.NewServerNameMatcher(lc.FilterChains) == nil {
 			return nil, errors.New("error updating listener, listener have filter chains count is not 1")
 		}
 		rawConfig := al.listener.Config()
@@ -126,8 +129,20 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 		if networkFiltersFactories != nil {
 			log.DefaultLogger.Infof("[server] [AddOrUpdateListener] [update] update network filters")
 			al.networkFiltersFactories = networkFiltersFactories
-			rawConfig.FilterChains[0].FilterChainMatch = lc.FilterChains[0].FilterChainMatch
-			rawConfig.FilterChains[0].Filters = lc.FilterChains[0].Filters
+			// the udp filters are created by the next datagram
+			al.closeUDPFilters()
+			if len(rawConfig.FilterChains) != len(lc.FilterChains) {
+				rawConfig.FilterChains = make([]v2.FilterChain, len(lc.FilterChains))
+			}
+			for i := range lc.FilterChains {
+				rawConfig.FilterChains[i].Name = lc.FilterChains[i].Name
+				rawConfig.FilterChains[i].FilterChainMatch = lc.FilterChains[i].FilterChainMatch
+				rawConfig.FilterChains[i].MatchConfig = lc.FilterChains[i].MatchConfig
+				rawConfig.FilterChains[i].Filters = lc.FilterChains[i].Filters
+				rawConfig.FilterChains[i].StreamFilters = lc.FilterChains[i].StreamFilters
+			}
+		} else if len(rawConfig.FilterChains) != len(lc.FilterChains) {
+			return nil, errors.New("error updating listener, the filter chains count is changed without the network filters")
 		}
 		if streamFiltersFactories != nil {
 			log.DefaultLogger.Infof("[server] [AddOrUpdateListener] [update] update stream filters")
@@ -137,17 +152,25 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 
 		// tls update only take effects on new connections
 		// config changed
-		rawConfig.FilterChains[0].TLSContexts = lc.FilterChains[0].TLSContexts
-		rawConfig.FilterChains[0].TLSConfig = lc.FilterChains[0].TLSConfig
-		rawConfig.FilterChains[0].TLSConfigs = lc.FilterChains[0].TLSConfigs
+		for i := range lc.FilterChains {
+			rawConfig.FilterChains[i].TLSContexts = lc.FilterChains[i].TLSContexts
+			rawConfig.FilterChains[i].TLSConfig = lc.FilterChains[i].TLSConfig
+			rawConfig.FilterChains[i].TLSConfigs = lc.FilterChains[i].TLSConfigs
+		}
 		rawConfig.Inspector = lc.Inspector
 		mgr, err := mtls.NewTLSServerContextManager(rawConfig)
 		if err != nil {
 			log.DefaultLogger.Errorf("[server] [conn handler] [update listener] create tls context manager failed, %v", err)
 			return nil, err
 		}
-		// object changed
-		al.tlsMng = mgr
+		// object changed, the new connections use the new tls context manager,
+		// the established connections keep their sessions
+		old, _ := al.tlsMngStore.Load().(types.TLSContextManager)
+		al.tlsMngStore.Store(mgr)
+		if old != nil {
+			mtls.ReleaseTLSContextManager(old)
+		}
+		al.filterChainsStore.Store(newFilterChains(rawConfig))
 		// some simle config update
 		rawConfig.PerConnBufferLimitBytes = lc.PerConnBufferLimitBytes
 		al.listener.SetPerConnBufferLimitBytes(lc.PerConnBufferLimitBytes)
@@ -156,6 +179,28 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 		rawConfig.UseOriginalDst = lc.UseOriginalDst
 		al.listener.SetUseOriginalDst(lc.UseOriginalDst)
 		al.idleTimeout = lc.ConnectionIdleTimeout
+		rawConfig.ReadOriginalDst = lc.ReadOriginalDst
+		al.readOriginalDst = lc.ReadOriginalDst
+		rawConfig.DebugPayloadBytes = lc.DebugPayloadBytes
+		rawConfig.DebugRedactHeaders = lc.DebugRedactHeaders
+		al.payloadDumper = log.NewPayloadDumper(lc.DebugPayloadBytes, lc.DebugRedactHeaders)
+		// the limits take effects on the new connections and streams
+		rawConfig.MaxConnections = lc.MaxConnections
+		rawConfig.MaxConcurrentStreams = lc.MaxConcurrentStreams
+		al.setLimits(lc.MaxConnections, lc.MaxConcurrentStreams)
+		// the watermarks take effects on the new connections
+		rawConfig.WriteBufferWatermarks = lc.WriteBufferWatermarks
+		al.setWriteBufferWatermarks(lc.WriteBufferWatermarks)
+		rawConfig.StopAcceptOnOverload = lc.StopAcceptOnOverload
+		al.stopAcceptOnOverload = lc.StopAcceptOnOverload
+		rawConfig.UseProxyProto = lc.UseProxyProto
+		al.useProxyProto = lc.UseProxyProto
+		// the established connections keep the transfer gate of their creation
+		rawConfig.TransferConnection = lc.TransferConnection
+		al.transferConnection = lc.TransferConnection
+		// the request id config takes effects on the new connections
+		rawConfig.RequestID = lc.RequestID
+		al.requestID = lc.RequestID
 
 		al.listener.SetConfig(rawConfig)
 
@@ -178,14 +223,20 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 				alConfig.Path = types.MosnLogBasePath + string(os.PathSeparator) + lc.Name + "_access.log"
 			}
 
-			if al, err := log.NewAccessLog(alConfig.Path, nil, alConfig.Format); err == nil {
+			if al, err := log.NewAccessLogWithOptions(alConfig.Path, nil, alConfig.Format, alConfig.AbsentHeader,
+				newLogRoller(alConfig.Roller), newLogBuffer(alConfig.Buffer)); err == nil {
 				als = append(als, al)
 			} else {
 				return nil, fmt.Errorf("initialize listener access logger %s failed: %v", alConfig.Path, err.Error())
 			}
 		}
 
-		l := network.NewListener(lc)
+		var l types.Listener
+		if lc.Network == v2.UDP_NETWORK {
+			l = network.NewUDPListener(lc)
+		} else {
+			l = network.NewListener(lc)
+		}
 
 		var err error
 		al, err = newActiveListener(l, lc, als, networkFiltersFactories, streamFiltersFactories, ch, listenerStopChan)
@@ -241,6 +292,9 @@ func (ch *connHandler) RemoveListeners(name string) {
 		if l.listener.Name() == name {
 			log.DefaultLogger.Infof("[server] [conn handler] remove listener name: %s", name)
 			ch.listeners = append(ch.listeners[:i], ch.listeners[i+1:]...)
+			if mng, ok := l.tlsMngStore.Load().(types.TLSContextManager); ok {
+				mtls.ReleaseTLSContextManager(mng)
+			}
 		}
 	}
 }
@@ -279,15 +333,20 @@ func (ch *connHandler) StopListeners(lctx context.Context, close bool) error {
 }
 
 func (ch *connHandler) ListListenersFile(lctx context.Context) []*os.File {
-	files := make([]*os.File, len(ch.listeners))
+	files := make([]*os.File, 0, len(ch.listeners))
 
-	for idx, l := range ch.listeners {
+	for _, l := range ch.listeners {
+		// the udp listeners are bound again by the new process,
+		// and the listeners not bound to port have no socket
+		if l.isUDP() || !l.listener.Config().BindToPort {
+			continue
+		}
 		file, err := l.listener.ListenerFile()
 		if err != nil {
 			log.DefaultLogger.Errorf("[server] [conn handler] fail to get listener %s file descriptor: %v", l.listener.Name(), err)
 			return nil //stop reconfigure
 		}
-		files[idx] = file
+		files = append(files, file)
 	}
 	return files
 }
@@ -323,6 +382,29 @@ func (ch *connHandler) StopConnection() {
 	}
 }
 
+func (ch *connHandler) CloseConnections() {
+	for _, l := range ch.listeners {
+		l.closeConnections()
+	}
+}
+
+// DrainConnections drains the connections of all the listeners, the connections are closed
+// once their active streams are done
+func (ch *connHandler) DrainConnections() {
+	for _, l := range ch.listeners {
+		l.drainConnections()
+	}
+}
+
+// ActiveStreams returns the active downstream streams of all the listeners
+func (ch *connHandler) ActiveStreams() int64 {
+	var streams int64
+	for _, l := range ch.listeners {
+		streams += l.stats.DownstreamRequestActive.Count()
+	}
+	return streams
+}
+
 // ListenerEventListener
 type activeListener struct {
 	listener                    types.Listener
@@ -334,11 +416,33 @@ type activeListener struct {
 	connsMux                    sync.RWMutex
 	handler                     *connHandler
 	stopChan                    chan struct{}
-	stats                       *listenerStats
+	stats                       *types.ListenerStats
 	accessLogs                  []types.AccessLog
 	updatedLabel                bool
 	idleTimeout                 *v2.DurationConfig
-	tlsMng                      types.TLSContextManager
+	tlsMngStore                 atomic.Value // store types.TLSContextManager
+	filterChainsStore           atomic.Value // store *filterChains
+	readOriginalDst             bool
+	payloadDumper               *log.PayloadDumper
+	// the limits are updated at runtime, accessed atomically
+	maxConnections       uint32
+	maxConcurrentStreams uint32
+	// the write buffer watermarks of the new connections, updated at runtime and accessed atomically
+	writeBufferLow  uint32
+	writeBufferHigh uint32
+	// numConnections is the connections of the listener, including the ones reserved by the accept, accessed atomically
+	numConnections int64
+	// the new connections are closed while the overload manager stops accepting
+	stopAcceptOnOverload bool
+	// the PROXY protocol header is read before the tls handshake
+	useProxyProto bool
+	// transferConnection gates transferring the connections in the hot upgrade, nil means the protocol decides
+	transferConnection *bool
+	// requestID configures the request id of the streams, nil means the default config
+	requestID *v2.RequestIDConfig
+	// the udp filters of a udp listener, they are created by the first datagram
+	udpFiltersMux sync.Mutex
+	udpFilters    []types.UDPReadFilter
 }
 
 func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers []types.AccessLog,
@@ -347,12 +451,18 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 	al := &activeListener{
 		listener:                listener,
 		networkFiltersFactories: networkFiltersFactories,
-		conns:        list.New(),
-		handler:      handler,
-		stopChan:     stopChan,
-		accessLogs:   accessLoggers,
-		updatedLabel: false,
-		idleTimeout:  lc.ConnectionIdleTimeout,
+		conns:                   list.New(),
+		handler:                 handler,
+		stopChan:                stopChan,
+		accessLogs:              accessLoggers,
+		updatedLabel:            false,
+		idleTimeout:             lc.ConnectionIdleTimeout,
+		readOriginalDst:         lc.ReadOriginalDst,
+		payloadDumper:           log.NewPayloadDumper(lc.DebugPayloadBytes, lc.DebugRedactHeaders),
+		stopAcceptOnOverload:    lc.StopAcceptOnOverload,
+		useProxyProto:           lc.UseProxyProto,
+		transferConnection:      lc.TransferConnection,
+		requestID:               lc.RequestID,
 	}
 	al.streamFiltersFactoriesStore.Store(streamFiltersFactories)
 
@@ -368,13 +478,16 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 	al.listenIP = listenIP
 	al.listenPort = listenPort
 	al.stats = newListenerStats(al.listener.Name())
+	al.setLimits(lc.MaxConnections, lc.MaxConcurrentStreams)
+	al.setWriteBufferWatermarks(lc.WriteBufferWatermarks)
 
 	mgr, err := mtls.NewTLSServerContextManager(lc)
 	if err != nil {
 		log.DefaultLogger.Errorf("[server] [new listener] create tls context manager failed, %v", err)
 		return nil, err
 	}
-	al.tlsMng = mgr
+	al.tlsMngStore.Store(mgr)
+	al.filterChainsStore.Store(newFilterChains(lc))
 
 	return al, nil
 }
@@ -390,20 +503,107 @@ func (al *activeListener) GoStart(lctx context.Context) {
 	})
 }
 
+func (al *activeListener) setLimits(maxConnections, maxConcurrentStreams uint32) {
+	atomic.StoreUint32(&al.maxConnections, maxConnections)
+	atomic.StoreUint32(&al.maxConcurrentStreams, maxConcurrentStreams)
+	al.stats.DownstreamConnectionLimit.Update(int64(maxConnections))
+	al.stats.DownstreamStreamLimit.Update(int64(maxConcurrentStreams))
+}
+
+func (al *activeListener) setWriteBufferWatermarks(config *v2.WriteBufferWatermarks) {
+	low, high := network.WriteBufferWatermarks(config)
+	atomic.StoreUint32(&al.writeBufferLow, low)
+	atomic.StoreUint32(&al.writeBufferHigh, high)
+	al.stats.DownstreamWriteBufferLowWatermark.Update(int64(low))
+	al.stats.DownstreamWriteBufferHighWatermark.Update(int64(high))
+}
+
+// reserveConnection reserves a connection of the listener, it returns false if the listener reaches its max connections.
+// the transferred connections are not limited
+func (al *activeListener) reserveConnection(limited bool) bool {
+	n := atomic.AddInt64(&al.numConnections, 1)
+	if max := atomic.LoadUint32(&al.maxConnections); limited && max > 0 && n > int64(max) {
+		atomic.AddInt64(&al.numConnections, -1)
+		return false
+	}
+	return true
+}
+
+// releaseConnection releases the connection reserved, it is called when the connection is rejected or removed
+func (al *activeListener) releaseConnection() {
+	atomic.AddInt64(&al.numConnections, -1)
+}
+
 // ListenerEventListener
-func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, ch chan types.Connection, buf []byte) {
+func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, ch chan types.Connection, state *types.TransferState) {
 	var rawf *os.File
+	var originalDst net.Addr
+	var oriLocalAddr net.Addr
+	var chain *activeFilterChain
+
+	// the connection is reserved by the final working listener, the reservation is released
+	// if the connection is rejected before it is created
+	reserved := false
+	defer func() {
+		if reserved {
+			al.releaseConnection()
+		}
+	}()
 
 	// only store fd and tls conn handshake in final working listener
 	if !useOriginalDst {
+		// the connections transferred from the old mosn are not limited
+		if !al.reserveConnection(ch == nil) {
+			al.stats.DownstreamConnectionOverflow.Inc(1)
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[server] [listener] listener %s reaches the max connections, close connection from %s", al.listener.Name(), rawc.RemoteAddr())
+			}
+			rawc.Close()
+			return
+		}
+		reserved = true
+		if ch == nil && al.stopAcceptOnOverload && overload.Engaged(overload.StopAccepting) {
+			overload.OnRejected(overload.StopAccepting)
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[server] [listener] mosn is overloaded, close connection from %s", rawc.RemoteAddr())
+			}
+			rawc.Close()
+			return
+		}
+		// the transferred connections have read the PROXY protocol header already
+		if ch == nil && al.useProxyProto {
+			header, err := proxyprotocol.ReadHeader(rawc, proxyProtocolTimeout)
+			if err != nil {
+				al.stats.DownstreamProxyProtocolError.Inc(1)
+				if log.DefaultLogger.GetLogLevel() >= log.INFO {
+					log.DefaultLogger.Infof("[server] [listener] read proxy protocol header failed, close connection from %s, error: %v", rawc.RemoteAddr(), err)
+				}
+				rawc.Close()
+				return
+			}
+			// the local connections keep their own addresses
+			if header.Source != nil {
+				oriRemoteAddr = header.Source
+				oriLocalAddr = header.Destination
+			}
+		}
+		// the original destination is read before the tls handshake
+		if al.readOriginalDst {
+			addr, err := originaldst.GetOriginalDst(rawc)
+			if err != nil {
+				log.DefaultLogger.Errorf("[server] [listener] get original dst failed: %v", err)
+			} else {
+				originalDst = addr
+			}
+		}
 		if network.UseNetpollMode {
 			// store fd for further usage
 			if tc, ok := rawc.(*net.TCPConn); ok {
 				rawf, _ = tc.File()
 			}
 		}
-		if al.tlsMng != nil {
-			conn, err := al.tlsMng.Conn(rawc)
+		if tlsMng, ok := al.tlsMngStore.Load().(types.TLSContextManager); ok {
+			conn, err := tlsMng.Conn(rawc)
 			if err != nil {
 				if log.DefaultLogger.GetLogLevel() >= log.INFO {
 					log.DefaultLogger.Infof("[server] [listener] accept connection failed, error: %v", err)
@@ -413,8 +613,22 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 			}
 			rawc = conn
 		}
+		// the filter chain is selected by the server name after the tls handshake
+		if fcs, ok := al.filterChainsStore.Load().(*filterChains); ok && fcs != nil {
+			matched, err := fcs.match(rawc)
+			if err != nil {
+				if log.DefaultLogger.GetLogLevel() >= log.INFO {
+					log.DefaultLogger.Infof("[server] [listener] tls handshake failed, close connection from %s, error: %v", rawc.RemoteAddr(), err)
+				}
+				rawc.Close()
+				return
+			}
+			chain = matched
+		}
 	}
 
+	// the reserved connection is released when the connection is removed
+	reserved = false
 	arc := newActiveRawConn(rawc, al)
 	// TODO: create listener filter chain
 
@@ -429,19 +643,56 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerPort, al.listenPort)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerType, al.listener.Config().Type)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerName, al.listener.Name())
-	ctx = mosnctx.WithValue(ctx, types.ContextKeyNetworkFilterChainFactories, al.networkFiltersFactories)
-	ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, &al.streamFiltersFactoriesStore)
+	if chain != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyNetworkFilterChainFactories, chain.networkFiltersFactories)
+	} else {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyNetworkFilterChainFactories, al.networkFiltersFactories)
+	}
+	if chain != nil && chain.streamFiltersFactoriesStore != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, chain.streamFiltersFactoriesStore)
+	} else {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, &al.streamFiltersFactoriesStore)
+	}
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyAccessLogs, al.accessLogs)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerStats, al.stats)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyMaxConcurrentStreams, &al.maxConcurrentStreams)
+	if al.payloadDumper != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyPayloadDumper, al.payloadDumper)
+	}
 	if rawf != nil {
 		ctx = mosnctx.WithValue(ctx, types.ContextKeyConnectionFd, rawf)
 	}
 	if ch != nil {
 		ctx = mosnctx.WithValue(ctx, types.ContextKeyAcceptChan, ch)
-		ctx = mosnctx.WithValue(ctx, types.ContextKeyAcceptBuffer, buf)
+		if state != nil {
+			ctx = mosnctx.WithValue(ctx, types.ContextKeyTransferState, state)
+			// the transferred connections keep the addresses and the protocol of the old mosn
+			if state.RemoteAddr != nil {
+				oriRemoteAddr = state.RemoteAddr
+			}
+			if state.LocalAddr != nil {
+				oriLocalAddr = state.LocalAddr
+			}
+			if state.Protocol != "" {
+				ctx = mosnctx.WithValue(ctx, types.ContextKeyDownstreamProtocol, state.Protocol)
+			}
+		}
+	}
+	if al.transferConnection != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyTransferConnection, *al.transferConnection)
+	}
+	if al.requestID != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyRequestIDConfig, al.requestID)
 	}
 	if oriRemoteAddr != nil {
 		ctx = mosnctx.WithValue(ctx, types.ContextOriRemoteAddr, oriRemoteAddr)
 	}
+	if oriLocalAddr != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextOriLocalAddr, oriLocalAddr)
+	}
+	if originalDst != nil {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyOriginalDst, originalDst)
+	}
 
 	arc.ContinueFilterChain(ctx, true)
 }
@@ -449,7 +700,12 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connection) {
 	//Register Proxy's Filter
 	filterManager := conn.FilterManager()
-	for _, nfcf := range al.networkFiltersFactories {
+	// the network filters of the filter chain selected by the server name
+	networkFiltersFactories := al.networkFiltersFactories
+	if factories, ok := mosnctx.Get(ctx, types.ContextKeyNetworkFilterChainFactories).([]types.NetworkFilterChainFactory); ok {
+		networkFiltersFactories = factories
+	}
+	for _, nfcf := range networkFiltersFactories {
 		nfcf.CreateFilterChain(ctx, al.handler.clusterManager, filterManager)
 	}
 	filterManager.InitializeReadFilters()
@@ -458,6 +714,7 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connec
 		len(filterManager.ListWriteFilters()) == 0 {
 		// no filter found, close connection
 		conn.Close(types.NoFlush, types.LocalClose)
+		al.releaseConnection()
 		return
 	}
 	ac := newActiveConnection(al, conn)
@@ -468,6 +725,8 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connec
 	ac.element = e
 
 	atomic.AddInt64(&al.handler.numConnections, 1)
+	al.stats.DownstreamConnectionTotal.Inc(1)
+	al.stats.DownstreamConnectionActive.Inc(1)
 
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 		log.DefaultLogger.Debugf("[server] [listener] accept connection from %s, condId= %d, remote addr:%s", al.listener.Addr().String(), conn.ID(), conn.RemoteAddr().String())
@@ -477,7 +736,91 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connec
 	conn.Start(ctx)
 }
 
-func (al *activeListener) OnClose() {}
+func (al *activeListener) OnClose() {
+	al.closeUDPFilters()
+}
+
+func (al *activeListener) isUDP() bool {
+	return al.listener.Config().Network == v2.UDP_NETWORK
+}
+
+// OnDatagram passes the datagram to the udp filters, the datagrams are received by the listener's goroutine
+func (al *activeListener) OnDatagram(conn net.PacketConn, data []byte, from net.Addr) {
+	al.udpFiltersMux.Lock()
+	if al.udpFilters == nil {
+		al.udpFilters = al.newUDPFilters(conn)
+	}
+	filters := al.udpFilters
+	al.udpFiltersMux.Unlock()
+
+	for _, f := range filters {
+		f.OnData(data, from)
+	}
+}
+
+func (al *activeListener) newUDPFilters(conn net.PacketConn) []types.UDPReadFilter {
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerPort, al.listenPort)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerType, al.listener.Config().Type)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerName, al.listener.Name())
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyAccessLogs, al.accessLogs)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerStats, al.stats)
+
+	filters := make([]types.UDPReadFilter, 0, len(al.networkFiltersFactories))
+	for _, nfcf := range al.networkFiltersFactories {
+		if factory, ok := nfcf.(types.UDPFilterChainFactory); ok {
+			filters = append(filters, factory.CreateUDPFilter(ctx, al.handler.clusterManager, conn))
+		}
+	}
+	if len(filters) == 0 {
+		log.DefaultLogger.Errorf("[server] [listener] udp listener %s has no udp network filter, the datagrams are dropped", al.listener.Name())
+	}
+	return filters
+}
+
+func (al *activeListener) closeUDPFilters() {
+	al.udpFiltersMux.Lock()
+	filters := al.udpFilters
+	al.udpFilters = nil
+	al.udpFiltersMux.Unlock()
+
+	for _, f := range filters {
+		f.Close()
+	}
+}
+
+// closeConnections closes the connections out of the lock,
+// because the closed connection removes itself from the listener
+func (al *activeListener) closeConnections() {
+	al.connsMux.RLock()
+	conns := make([]types.Connection, 0, al.conns.Len())
+	for e := al.conns.Front(); e != nil; e = e.Next() {
+		conns = append(conns, e.Value.(*activeConnection).conn)
+	}
+	al.connsMux.RUnlock()
+
+	for _, conn := range conns {
+		conn.Close(types.NoFlush, types.LocalClose)
+	}
+}
+
+// drainConnections drains the connections by their read filters, the connections without
+// a drainable filter, such as the tcp proxy connections, are closed by the shutdown deadline
+func (al *activeListener) drainConnections() {
+	al.connsMux.RLock()
+	conns := make([]types.Connection, 0, al.conns.Len())
+	for e := al.conns.Front(); e != nil; e = e.Next() {
+		conns = append(conns, e.Value.(*activeConnection).conn)
+	}
+	al.connsMux.RUnlock()
+
+	for _, conn := range conns {
+		for _, rf := range conn.FilterManager().ListReadFilter() {
+			if d, ok := rf.(types.DrainableFilter); ok {
+				d.Drain()
+			}
+		}
+	}
+}
 
 func (al *activeListener) removeConnection(ac *activeConnection) {
 	al.connsMux.Lock()
@@ -485,13 +828,18 @@ func (al *activeListener) removeConnection(ac *activeConnection) {
 	al.connsMux.Unlock()
 
 	atomic.AddInt64(&al.handler.numConnections, -1)
-
+	al.releaseConnection()
+	al.stats.DownstreamConnectionDestroy.Inc(1)
+	al.stats.DownstreamConnectionActive.Dec(1)
 }
 
 // defaultIdleTimeout represents the idle timeout if listener have no such configuration
 // we declared the defaultIdleTimeout reference to the network.DefaultIdleTimeout
 var defaultIdleTimeout = network.DefaultIdleTimeout
 
+// proxyProtocolTimeout is the timeout of reading the PROXY protocol header of the new connections
+var proxyProtocolTimeout = 10 * time.Second
+
 func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
 	conn := network.NewServerConnection(ctx, rawc, al.stopChan)
 	if al.idleTimeout != nil {
@@ -505,9 +853,16 @@ func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
 	if oriRemoteAddr != nil {
 		conn.SetRemoteAddr(oriRemoteAddr.(net.Addr))
 	}
+	if oriLocalAddr, ok := mosnctx.Get(ctx, types.ContextOriLocalAddr).(net.Addr); ok {
+		conn.SetLocalAddr(oriLocalAddr)
+	}
 	newCtx := mosnctx.WithValue(ctx, types.ContextKeyConnectionID, conn.ID())
+	newCtx = mosnctx.WithValue(newCtx, types.ContextKeyConnection, conn)
 
 	conn.SetBufferLimit(al.listener.PerConnBufferLimitBytes())
+	if high := atomic.LoadUint32(&al.writeBufferHigh); high > 0 {
+		conn.SetWriteBufferWatermarks(atomic.LoadUint32(&al.writeBufferLow), high)
+	}
 
 	al.OnNewConnection(newCtx, conn)
 }
@@ -556,25 +911,23 @@ func (arc *activeRawConn) UseOriginalDst(ctx context.Context) {
 	}
 
 	var ch chan types.Connection
-	var buf []byte
+	var state *types.TransferState
 	if val := mosnctx.Get(ctx, types.ContextKeyAcceptChan); val != nil {
 		ch = val.(chan types.Connection)
-		if val := mosnctx.Get(ctx, types.ContextKeyAcceptBuffer); val != nil {
-			buf = val.([]byte)
-		}
+		state, _ = mosnctx.Get(ctx, types.ContextKeyTransferState).(*types.TransferState)
 	}
 
 	if listener != nil {
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[server] [conn] original dst:%s:%d", listener.listenIP, listener.listenPort)
 		}
-		listener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf)
+		listener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, state)
 	}
 	if localListener != nil {
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[server] [conn] original dst:%s:%d", localListener.listenIP, localListener.listenPort)
 		}
-		localListener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf)
+		localListener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, state)
 	}
 }
 
@@ -611,6 +964,8 @@ type activeConnection struct {
 	element  *list.Element
 	listener *activeListener
 	conn     types.Connection
+	// backedUp is 1 if the connection is above its write buffer high watermark, accessed atomically
+	backedUp uint32
 }
 
 func newActiveConnection(listener *activeListener, conn types.Connection) *activeConnection {
@@ -639,7 +994,23 @@ func newActiveConnection(listener *activeListener, conn types.Connection) *activ
 
 // ConnectionEventListener
 func (ac *activeConnection) OnEvent(event types.ConnectionEvent) {
-	if event.IsClose() {
+	stats := ac.listener.stats
+	switch {
+	case event == types.OnAboveWriteBufferHighWatermark:
+		if atomic.CompareAndSwapUint32(&ac.backedUp, 0, 1) {
+			stats.DownstreamWriteBufferHighWatermarkTotal.Inc(1)
+			stats.DownstreamWriteBufferBackedUp.Inc(1)
+		}
+	case event == types.OnBelowWriteBufferLowWatermark:
+		if atomic.CompareAndSwapUint32(&ac.backedUp, 1, 0) {
+			stats.DownstreamWriteBufferLowWatermarkTotal.Inc(1)
+			stats.DownstreamWriteBufferBackedUp.Dec(1)
+		}
+	case event.IsClose():
+		// the connection closed before it is drained is not backed up any more
+		if atomic.CompareAndSwapUint32(&ac.backedUp, 1, 0) {
+			stats.DownstreamWriteBufferBackedUp.Dec(1)
+		}
 		ac.listener.removeConnection(ac)
 	}
 }
@@ -48,6 +48,9 @@ var (
 	shutdownCallbacksOnce sync.Once
 	shutdownCallbacks     []func() error
 	signalCallback        = make(map[syscall.Signal][]func())
+	// gracefulShutdownCallbacks drain the connections before the shutdown callbacks
+	gracefulShutdownCallbacks []func()
+	shutdownOnce              sync.Once
 )
 
 func SetPid(pid string) {
@@ -83,30 +86,23 @@ func catchSignalsCrossPlatform() {
 		signal.Notify(sigchan, syscall.SIGTERM, syscall.SIGHUP,
 			syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
 
+		terminating := false
 		for sig := range sigchan {
 			log.DefaultLogger.Debugf("signal %s received!", sig)
 			switch sig {
 			case syscall.SIGQUIT:
 				// quit
-				for _, f := range onProcessExit {
-					f() // only perform important cleanup actions
-				}
-				os.Exit(0)
+				exitImmediately()
 			case syscall.SIGTERM:
-				// stop to quit
-				exitCode := ExecuteShutdownCallbacks("SIGTERM")
-				for _, f := range onProcessExit {
-					f() // only perform important cleanup actions
-				}
-				//Stop()
-
-				if cbs, ok := signalCallback[syscall.SIGTERM]; ok {
-					for _, cb := range cbs {
-						cb()
-					}
+				// a second SIGTERM quits without waiting for the drain
+				if terminating {
+					exitImmediately()
 				}
-
-				os.Exit(exitCode)
+				terminating = true
+				// drain and stop to quit, the signals are still handled while draining
+				utils.GoWithRecover(func() {
+					Shutdown("SIGTERM", true)
+				}, nil)
 			case syscall.SIGUSR1:
 				// reopen
 				log.Reopen()
@@ -123,6 +119,14 @@ func catchSignalsCrossPlatform() {
 	}, nil)
 }
 
+// exitImmediately quits the process after the important cleanup actions only
+func exitImmediately() {
+	for _, f := range onProcessExit {
+		f()
+	}
+	os.Exit(0)
+}
+
 func catchSignalsPosix() {
 	go func() {
 		defer func() {
@@ -179,6 +183,32 @@ func ExecuteShutdownCallbacks(signame string) (exitCode int) {
 	return
 }
 
+// Shutdown drains the connections if it is graceful, executes the shutdown callbacks and exits the process.
+// It is called once, the later calls are blocked until the process exits
+func Shutdown(reason string, graceful bool) {
+	shutdownOnce.Do(func() {
+		log.DefaultLogger.Infof("[keeper] %s shutdown, graceful: %t", reason, graceful)
+		if graceful {
+			for _, cb := range gracefulShutdownCallbacks {
+				cb()
+			}
+		}
+		exitCode := ExecuteShutdownCallbacks(reason)
+		for _, f := range onProcessExit {
+			f() // only perform important cleanup actions
+		}
+
+		if cbs, ok := signalCallback[syscall.SIGTERM]; ok {
+			for _, cb := range cbs {
+				cb()
+			}
+		}
+
+		os.Exit(exitCode)
+	})
+	select {}
+}
+
 func OnProcessExit(cb func()) {
 	onProcessExit = append(onProcessExit, cb)
 }
@@ -187,6 +217,11 @@ func OnProcessShutDown(cb func() error) {
 	shutdownCallbacks = append(shutdownCallbacks, cb)
 }
 
+// OnGracefulShutdown adds a callback called before the shutdown callbacks by the graceful shutdown
+func OnGracefulShutdown(cb func()) {
+	gracefulShutdownCallbacks = append(gracefulShutdownCallbacks, cb)
+}
+
 func AddSignalCallback(signal syscall.Signal, cb func()) {
 	signalCallback[signal] = append(signalCallback[signal], cb)
 }
@@ -20,6 +20,8 @@ package server
 import (
 	"context"
 
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/filter"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -119,3 +121,60 @@ Zk7xEQKBgDh4B7wTVi6eKRDYJpgWiDgT5uV0gu60mJ0CHYmjUtX9RGBsGS85zJmx
 2JMjNOmrivQ3dvL/rNKIx0ULx+iTQr3Y6B8A2xCme3yr665arvE0HOFG0hLVE1a0
 wD08P7/0q7yk5M4dDUwerGaTIRKK8RRFy1Ak9kU6EtHsbUKNo9s5
 -----END RSA PRIVATE KEY-----`
+
+// mockChainNameFilter responds the name of the filter chain
+type mockChainNameFilter struct {
+	name string
+	cb   types.ReadFilterCallbacks
+}
+
+func (nf *mockChainNameFilter) OnData(buf types.IoBuffer) types.FilterStatus {
+	buf.Drain(buf.Len())
+	nf.cb.Connection().Write(buffer.NewIoBufferString(nf.name))
+	return types.Stop
+}
+func (nf *mockChainNameFilter) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+func (nf *mockChainNameFilter) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
+	nf.cb = cb
+}
+
+type mockChainNameFilterFactory struct {
+	name string
+}
+
+func (ff *mockChainNameFilterFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(&mockChainNameFilter{name: ff.name})
+}
+
+// mockAddrFilter responds the remote and the local addresses of the connection
+type mockAddrFilter struct {
+	cb types.ReadFilterCallbacks
+}
+
+func (nf *mockAddrFilter) OnData(buf types.IoBuffer) types.FilterStatus {
+	buf.Drain(buf.Len())
+	conn := nf.cb.Connection()
+	nf.cb.Connection().Write(buffer.NewIoBufferString(conn.RemoteAddr().String() + " " + conn.LocalAddr().String()))
+	return types.Stop
+}
+func (nf *mockAddrFilter) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+func (nf *mockAddrFilter) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
+	nf.cb = cb
+}
+
+type mockAddrFilterFactory struct{}
+
+func (ff *mockAddrFilterFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(&mockAddrFilter{})
+}
+
+func init() {
+	filter.RegisterNetwork("chain_name", func(cfg map[string]interface{}) (types.NetworkFilterChainFactory, error) {
+		name, _ := cfg["name"].(string)
+		return &mockChainNameFilterFactory{name: name}, nil
+	})
+}
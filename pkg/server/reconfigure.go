@@ -34,6 +34,13 @@ import (
 
 func init() {
 	keeper.AddSignalCallback(syscall.SIGHUP, func() {
+		if config.ReloadOnSighup() {
+			// reload the config file in place
+			if _, err := config.Reload(); err != nil {
+				log.DefaultLogger.Errorf("[server] [reconfigure] reload config failed: %v", err)
+			}
+			return
+		}
 		// reload, fork new mosn
 		reconfigure(true)
 	})
@@ -52,16 +52,49 @@ type server struct {
 
 func NewConfig(c *v2.ServerConfig) *Config {
 	return &Config{
-		ServerName:      c.ServerName,
-		LogPath:         c.DefaultLogPath,
-		LogLevel:        config.ParseLogLevel(c.DefaultLogLevel),
-		LogRoller:       c.GlobalLogRoller,
-		GracefulTimeout: c.GracefulTimeout.Duration,
-		Processor:       c.Processor,
-		UseNetpollMode:  c.UseNetpollMode,
+		ServerName:       c.ServerName,
+		LogPath:          c.DefaultLogPath,
+		LogLevel:         config.ParseLogLevel(c.DefaultLogLevel),
+		LogRoller:        c.GlobalLogRoller,
+		DefaultLogRoller: newLogRoller(c.DefaultLogRoller),
+		DefaultLogBuffer: newLogBuffer(c.DefaultLogBuffer),
+		GracefulTimeout:  c.GracefulTimeout.Duration,
+		Processor:        c.Processor,
+		UseNetpollMode:   c.UseNetpollMode,
 	}
 }
 
+// newLogRoller returns nil if the roller is not configured, so the global roller is used
+func newLogRoller(cfg *v2.LogRollerConfig) *log.Roller {
+	if cfg == nil {
+		return nil
+	}
+	return &log.Roller{
+		MaxSize:    cfg.MaxSize,
+		MaxAge:     cfg.MaxAge,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+		LocalTime:  true,
+	}
+}
+
+// newLogBuffer returns nil if the buffer is not configured, so the default buffer is used
+func newLogBuffer(cfg *v2.LogBufferConfig) *log.BufferOptions {
+	if cfg == nil {
+		return nil
+	}
+	opts := &log.BufferOptions{
+		Size: cfg.BufferSize,
+	}
+	if cfg.FlushInterval != nil {
+		opts.FlushInterval = cfg.FlushInterval.Duration
+	}
+	if cfg.OverflowPolicy == v2.LOG_OVERFLOW_BLOCK {
+		opts.Overflow = log.OverflowBlock
+	}
+	return opts
+}
+
 func NewServer(config *Config, cmFilter types.ClusterManagerFilter, clMng types.ClusterManager) Server {
 	if config != nil {
 		//graceful timeout setting
@@ -170,10 +203,15 @@ func InitDefaultLogger(config *Config) {
 
 	var logPath string
 	var logLevel log.Level
+	var logOptions *log.ErrorLoggerOptions
 
 	if config != nil {
 		logPath = config.LogPath
 		logLevel = config.LogLevel
+		logOptions = &log.ErrorLoggerOptions{
+			Roller: config.DefaultLogRoller,
+			Buffer: config.DefaultLogBuffer,
+		}
 	}
 
 	//use default log path
@@ -188,7 +226,7 @@ func InitDefaultLogger(config *Config) {
 		}
 	}
 
-	err := log.InitDefaultLogger(logPath, logLevel)
+	err := log.InitDefaultLoggerWithOptions(logPath, logLevel, logOptions)
 	if err != nil {
 		log.StartLogger.Fatalln("[server] [init] initialize default logger failed : ", err)
 	}
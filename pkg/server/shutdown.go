@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/admin/store"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/server/keeper"
+)
+
+// RuntimeKeyDrainDelay is the runtime key of the duration between the health check fails and the listeners
+// stop accepting in the graceful shutdown, the external load balancers stop sending traffic in it
+const RuntimeKeyDrainDelay = "server.shutdown.drain_delay"
+
+func init() {
+	keeper.OnGracefulShutdown(func() {
+		GracefulShutdown(GracefulTimeout)
+	})
+}
+
+// shutdownCheckInterval is the interval to check the active streams while draining,
+// and the progress is logged every shutdownProgressInterval
+var (
+	shutdownCheckInterval    = 100 * time.Millisecond
+	shutdownProgressInterval = time.Second
+)
+
+// ShutdownSummary is the result of a graceful shutdown
+type ShutdownSummary struct {
+	Duration time.Duration
+	// the connections and the streams when the listeners stop accepting
+	Connections uint64
+	Streams     int64
+	// the connections and the streams closed by the deadline
+	ClosedConnections uint64
+	ResetStreams      int64
+}
+
+// GracefulShutdown fails the health check, stops accepting the new connections after the drain delay,
+// drains the connections and waits until the active streams are done or the timeout. The connections left are closed after it.
+// The access logs and the metrics are flushed by the shutdown callbacks
+func GracefulShutdown(timeout time.Duration) ShutdownSummary {
+	start := time.Now()
+	store.SetDraining(true)
+	if delay := config.GetRuntime().GetDuration(RuntimeKeyDrainDelay, 0); delay > 0 {
+		log.DefaultLogger.Infof("[server] [shutdown] health check failed, wait %s before stop accepting", delay)
+		time.Sleep(delay)
+	}
+	StopAccept()
+	summary := ShutdownSummary{
+		Connections: NumConnections(),
+		Streams:     ActiveStreams(),
+	}
+	log.DefaultLogger.Infof("[server] [shutdown] stop accepting, active connections: %d, active streams: %d, timeout: %s",
+		summary.Connections, summary.Streams, timeout)
+
+	for _, server := range servers {
+		server.handler.DrainConnections()
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(shutdownCheckInterval)
+	defer ticker.Stop()
+	lastProgress := start
+wait:
+	for ActiveStreams() > 0 {
+		select {
+		case <-deadline.C:
+			log.DefaultLogger.Warnf("[server] [shutdown] drain timeout after %s", timeout)
+			break wait
+		case now := <-ticker.C:
+			if now.Sub(lastProgress) >= shutdownProgressInterval {
+				lastProgress = now
+				log.DefaultLogger.Infof("[server] [shutdown] draining, active connections: %d, active streams: %d",
+					NumConnections(), ActiveStreams())
+			}
+		}
+	}
+
+	summary.ClosedConnections = NumConnections()
+	summary.ResetStreams = ActiveStreams()
+	for _, server := range servers {
+		server.handler.CloseConnections()
+	}
+	summary.Duration = time.Since(start)
+	log.DefaultLogger.Infof("[server] [shutdown] graceful shutdown done in %s, connections: %d, streams: %d, closed connections: %d, reset streams: %d",
+		summary.Duration, summary.Connections, summary.Streams, summary.ClosedConnections, summary.ResetStreams)
+	return summary
+}
+
+// NumConnections returns the downstream connections of all the servers
+func NumConnections() uint64 {
+	var conns uint64
+	for _, server := range servers {
+		conns += server.handler.NumConnections()
+	}
+	return conns
+}
+
+// ActiveStreams returns the active downstream streams of all the servers
+func ActiveStreams() int64 {
+	var streams int64
+	for _, server := range servers {
+		streams += server.handler.ActiveStreams()
+	}
+	return streams
+}
@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/admin/store"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// mockDrainFilter finishes its active stream when it is drained
+type mockDrainFilter struct {
+	cb      types.ReadFilterCallbacks
+	drained *int32
+	done    func()
+}
+
+func (f *mockDrainFilter) OnData(buf types.IoBuffer) types.FilterStatus {
+	return types.Stop
+}
+
+func (f *mockDrainFilter) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+
+func (f *mockDrainFilter) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
+	f.cb = cb
+}
+
+func (f *mockDrainFilter) Drain() {
+	atomic.AddInt32(f.drained, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		f.done()
+		f.cb.Connection().Close(types.FlushWrite, types.LocalClose)
+	}()
+}
+
+type mockDrainFilterFactory struct {
+	drained *int32
+	done    func()
+}
+
+func (ff *mockDrainFilterFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(&mockDrainFilter{
+		drained: ff.drained,
+		done:    ff.done,
+	})
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	// use a standalone server, so the listeners of the other tests are not affected
+	srv := &server{
+		serverName: "shutdown_server",
+		stopChan:   make(chan struct{}),
+		handler:    NewHandler(&mockClusterManagerFilter{}, &mockClusterManager{}),
+	}
+	oldServers := servers
+	servers = []*server{srv}
+	defer func() {
+		servers = oldServers
+	}()
+
+	addrStr := "127.0.0.1:8089"
+	name := "shutdown_listener"
+	lc := baseListenerConfig(addrStr, name)
+	lc.FilterChains[0].TLSContexts = nil
+	var drained int32
+	handler := srv.handler.(*connHandler)
+	ff := &mockDrainFilterFactory{
+		drained: &drained,
+		done: func() {
+			handler.findActiveListenerByName(name).stats.DownstreamRequestActive.Dec(1)
+		},
+	}
+	if _, err := srv.handler.AddOrUpdateListener(lc, []types.NetworkFilterChainFactory{ff}, nil); err != nil {
+		t.Fatalf("add listener failed: %v", err)
+	}
+	srv.handler.StartListeners(nil)
+	defer srv.handler.StopListeners(nil, true)
+	time.Sleep(time.Second) // wait listener start
+
+	conn, err := net.DialTimeout("tcp", addrStr, time.Second)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(100 * time.Millisecond) // wait connection accepted
+	// mock an active stream
+	handler.findActiveListenerByName(name).stats.DownstreamRequestActive.Inc(1)
+
+	defer store.SetDraining(false)
+	summary := GracefulShutdown(5 * time.Second)
+	if healthy, _ := store.Healthy(); healthy {
+		t.Fatal("expected the health check failed")
+	}
+	if atomic.LoadInt32(&drained) != 1 {
+		t.Fatalf("expected the connection drained once, but got %d", drained)
+	}
+	if summary.Connections != 1 || summary.Streams != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.ResetStreams != 0 {
+		t.Fatalf("expected the stream done before the timeout, but got %+v", summary)
+	}
+	// the connection is closed
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the connection closed")
+	}
+}
@@ -19,18 +19,34 @@ package server
 
 import (
 	"sofastack.io/sofa-mosn/pkg/metrics"
-	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
 )
 
-type listenerStats struct {
-	DownstreamBytesReadTotal  gometrics.Counter
-	DownstreamBytesWriteTotal gometrics.Counter
-}
-
-func newListenerStats(listenerName string) *listenerStats {
+func newListenerStats(listenerName string) *types.ListenerStats {
 	s := metrics.NewListenerStats(listenerName)
-	return &listenerStats{
-		DownstreamBytesReadTotal:  s.Counter(metrics.DownstreamBytesReadTotal),
-		DownstreamBytesWriteTotal: s.Counter(metrics.DownstreamBytesWriteTotal),
+	return &types.ListenerStats{
+		DownstreamConnectionTotal:    s.Counter(metrics.DownstreamConnectionTotal),
+		DownstreamConnectionActive:   s.Counter(metrics.DownstreamConnectionActive),
+		DownstreamConnectionDestroy:  s.Counter(metrics.DownstreamConnectionDestroy),
+		DownstreamBytesReadTotal:     s.Counter(metrics.DownstreamBytesReadTotal),
+		DownstreamBytesWriteTotal:    s.Counter(metrics.DownstreamBytesWriteTotal),
+		DownstreamRequestTotal:       s.Counter(metrics.DownstreamRequestTotal),
+		DownstreamRequestActive:      s.Counter(metrics.DownstreamRequestActive),
+		DownstreamResponseCode1xx:    s.Counter(metrics.DownstreamResponseCode1xx),
+		DownstreamResponseCode2xx:    s.Counter(metrics.DownstreamResponseCode2xx),
+		DownstreamResponseCode3xx:    s.Counter(metrics.DownstreamResponseCode3xx),
+		DownstreamResponseCode4xx:    s.Counter(metrics.DownstreamResponseCode4xx),
+		DownstreamResponseCode5xx:    s.Counter(metrics.DownstreamResponseCode5xx),
+		DownstreamHeartbeatTotal:     s.Counter(metrics.DownstreamHeartbeatTotal),
+		DownstreamConnectionOverflow: s.Counter(metrics.DownstreamConnectionOverflow),
+		DownstreamConnectionLimit:    s.Gauge(metrics.DownstreamConnectionLimit),
+		DownstreamStreamLimit:        s.Gauge(metrics.DownstreamStreamLimit),
+		DownstreamProxyProtocolError: s.Counter(metrics.DownstreamProxyProtocolError),
+
+		DownstreamWriteBufferHighWatermarkTotal: s.Counter(metrics.DownstreamWriteBufferHighWatermarkTotal),
+		DownstreamWriteBufferLowWatermarkTotal:  s.Counter(metrics.DownstreamWriteBufferLowWatermarkTotal),
+		DownstreamWriteBufferBackedUp:           s.Counter(metrics.DownstreamWriteBufferBackedUp),
+		DownstreamWriteBufferHighWatermark:      s.Gauge(metrics.DownstreamWriteBufferHighWatermark),
+		DownstreamWriteBufferLowWatermark:       s.Gauge(metrics.DownstreamWriteBufferLowWatermark),
 	}
 }
@@ -26,13 +26,15 @@ import (
 )
 
 type Config struct {
-	ServerName      string
-	LogPath         string
-	LogLevel        log.Level
-	LogRoller       string
-	GracefulTimeout time.Duration
-	Processor       int
-	UseNetpollMode  bool
+	ServerName       string
+	LogPath          string
+	LogLevel         log.Level
+	LogRoller        string
+	DefaultLogRoller *log.Roller
+	DefaultLogBuffer *log.BufferOptions
+	GracefulTimeout  time.Duration
+	Processor        int
+	UseNetpollMode   bool
 }
 
 type Server interface {
@@ -124,13 +124,19 @@ func (c *client) NewStream(context context.Context, respReceiver types.StreamRec
 	return streamSender
 }
 
+func (c *client) GoAway() {
+	c.ClientStreamConnection.GoAway()
+}
+
 func (c *client) Close() {
 	c.Connection.Close(types.NoFlush, types.LocalClose)
 }
 
 // types.StreamConnectionEventListener
 func (c *client) OnGoAway() {
-	c.StreamConnectionEventListener.OnGoAway()
+	if c.StreamConnectionEventListener != nil {
+		c.StreamConnectionEventListener.OnGoAway()
+	}
 }
 
 // types.ConnectionEventListener
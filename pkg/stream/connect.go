@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// connectDurationListener records the duration from the upstream connection
+// created to connected, no matter the connection is connected synchronously or not.
+// types.ConnectionEventListener
+type connectDurationListener struct {
+	host  types.Host
+	start time.Time
+}
+
+// RecordConnectDuration records the connect duration of the connection into the host and cluster stats,
+// it should be called before the connection connects.
+func RecordConnectDuration(host types.Host, connection types.ClientConnection) {
+	connection.AddConnectionEventListener(&connectDurationListener{
+		host:  host,
+		start: time.Now(),
+	})
+}
+
+func (l *connectDurationListener) OnEvent(event types.ConnectionEvent) {
+	if event != types.Connected {
+		return
+	}
+	duration := time.Since(l.start).Nanoseconds() / int64(time.Millisecond)
+	l.host.HostStats().UpstreamConnectionConnectDurationMs.Update(duration)
+	l.host.ClusterInfo().Stats().UpstreamConnectionConnectDurationMs.Update(duration)
+}
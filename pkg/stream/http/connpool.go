@@ -19,9 +19,13 @@ package http
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/protocol"
@@ -48,11 +52,35 @@ type connPool struct {
 	clientMux        sync.Mutex
 	availableClients []*activeClient // available clients
 	totalClientCount uint64          // total clients
+	closed           bool            // no new stream is accepted after closed
+
+	// connect backoff state, protected by clientMux
+	connectFailures uint32
+	backoffInterval time.Duration
+	backoffUntil    time.Time
+
+	// in the per downstream mode, each downstream connection has a dedicated client,
+	// the clients are keyed by the downstream connection id, protected by clientMux
+	perDownstream     bool
+	downstreamClients map[uint64]*activeClient
+	// the downstream connections listened by the pool, the listener is added once for each downstream connection,
+	// protected by clientMux
+	downstreamListened map[uint64]struct{}
+
+	// the connected clients keyed by the client id, protected by clientMux
+	clients map[string]*activeClient
 }
 
 func NewConnPool(host types.Host) types.ConnectionPool {
 	pool := &connPool{
-		host: host,
+		host:    host,
+		clients: make(map[string]*activeClient),
+	}
+
+	if host.ClusterInfo().ConnPoolMode() == v2.PER_DOWNSTREAM_CONN_POOL {
+		pool.perDownstream = true
+		pool.downstreamClients = make(map[uint64]*activeClient)
+		pool.downstreamListened = make(map[uint64]struct{})
 	}
 
 	if pool.statReport {
@@ -75,15 +103,35 @@ func (p *connPool) CheckAndInit(ctx context.Context) bool {
 }
 
 //由 PROXY 调用
-func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener, listener types.PoolEventListener) {
-	c, reason := p.getAvailableClient(ctx)
+func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener, listener types.PoolEventListener) types.Cancellable {
+	c, connecting, reason := p.getAvailableClient(ctx)
 
 	if c == nil {
 		listener.OnFailure(reason, p.host)
-		return
+		return nil
+	}
+
+	// the stream waits on the new client until the connection is established,
+	// so the proxy goroutine will not be blocked by a slow upstream
+	if connecting {
+		pending := &pendingStream{
+			ctx:      ctx,
+			receiver: receiver,
+			listener: listener,
+		}
+		c.pending = pending
+		p.connect(c)
+		return pending
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	p.newStream(ctx, c, receiver, listener)
+
+	return nil
+}
+
+func (p *connPool) newStream(ctx context.Context, c *activeClient, receiver types.StreamReceiveListener, listener types.PoolEventListener) {
+	requests := str.ResourceManagerByContext(ctx, p.host.ClusterInfo()).Requests()
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
@@ -92,50 +140,341 @@ func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveLi
 		p.host.HostStats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-		p.host.ClusterInfo().ResourceManager().Requests().Increase()
+		requests.Increase()
 
 		streamEncoder := c.client.NewStream(ctx, receiver)
 		streamEncoder.GetStream().AddEventListener(c)
+		str.DecreaseOnDestroy(streamEncoder.GetStream(), requests)
 		listener.OnReady(streamEncoder, p.host)
 	}
-
-	return
 }
 
-func (p *connPool) getAvailableClient(ctx context.Context) (*activeClient, types.PoolFailureReason) {
+// getAvailableClient returns an idle client, or a new client that is not connected yet
+func (p *connPool) getAvailableClient(ctx context.Context) (*activeClient, bool, types.PoolFailureReason) {
 	p.clientMux.Lock()
 	defer p.clientMux.Unlock()
 
+	if p.closed {
+		return nil, false, types.PoolClosed
+	}
+
+	// a stream without the downstream connection uses the shared clients
+	if p.perDownstream {
+		if id, ok := mosnctx.Get(ctx, types.ContextKeyConnectionID).(uint64); ok {
+			return p.getDownstreamClient(ctx, id)
+		}
+	}
+
 	n := len(p.availableClients)
 	// no available client
 	if n == 0 {
-		maxConns := p.host.ClusterInfo().ResourceManager().Connections().Max()
+		// do not dial a host that keeps failing until the backoff window ends
+		if p.inConnectBackoff() {
+			return nil, false, types.ConnectionFailure
+		}
+		maxConns := str.ResourceManagerByContext(ctx, p.host.ClusterInfo()).Connections().Max()
 		if p.totalClientCount < maxConns {
 			p.totalClientCount++
-			return newActiveClient(ctx, p)
+			c := newActiveClient(ctx, p)
+			c.busy = true
+			return c, true, ""
 		} else {
 			p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 			p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
-			return nil, types.Overflow
+			return nil, false, types.Overflow
 		}
 	} else {
 		n--
 		c := p.availableClients[n]
 		p.availableClients[n] = nil
 		p.availableClients = p.availableClients[:n]
-		return c, ""
+		c.busy = true
+		return c, false, ""
 	}
 }
 
-func (p *connPool) Close() {
+// getDownstreamClient returns the client bound to the downstream connection,
+// or a new client that is not connected yet, it must be called with clientMux held
+func (p *connPool) getDownstreamClient(ctx context.Context, id uint64) (*activeClient, bool, types.PoolFailureReason) {
+	if c, ok := p.downstreamClients[id]; ok {
+		// the http1 downstream connection sends the next request after the response is done,
+		// so the bound client should be idle
+		if c.busy {
+			p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+			p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+			return nil, false, types.Overflow
+		}
+		c.busy = true
+		return c, false, ""
+	}
+
+	if p.inConnectBackoff() {
+		return nil, false, types.ConnectionFailure
+	}
+	maxConns := str.ResourceManagerByContext(ctx, p.host.ClusterInfo()).Connections().Max()
+	if p.totalClientCount >= maxConns {
+		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		return nil, false, types.Overflow
+	}
+	p.totalClientCount++
+	c := newActiveClient(ctx, p)
+	c.downstreamID = id
+	c.busy = true
+	p.downstreamClients[id] = c
+
+	// the client is closed with the downstream connection, the downstream connection
+	// is listened once though its clients may be created again after closed
+	if _, ok := p.downstreamListened[id]; !ok {
+		if conn, ok := mosnctx.Get(ctx, types.ContextKeyConnection).(types.Connection); ok {
+			p.downstreamListened[id] = struct{}{}
+			conn.AddConnectionEventListener(&downstreamListener{
+				pool: p,
+				id:   id,
+			})
+		}
+	}
+	return c, true, ""
+}
+
+// onDownstreamClose closes the client bound to the downstream connection,
+// a busy client is closed when its stream is done
+func (p *connPool) onDownstreamClose(id uint64) {
+	p.clientMux.Lock()
+	delete(p.downstreamListened, id)
+	c, ok := p.downstreamClients[id]
+	if ok {
+		delete(p.downstreamClients, id)
+		c.unbound = true
+	}
+	closeClient := ok && !c.busy && !c.closed
+	p.clientMux.Unlock()
+
+	if closeClient {
+		c.client.Close()
+	}
+}
+
+// releaseClient makes an idle client available for the next stream,
+// it must be called with clientMux held, and returns whether the client should be closed
+func (p *connPool) releaseClient(c *activeClient) bool {
+	c.busy = false
+	if c.closed {
+		return false
+	}
+	if p.closed || c.draining {
+		return true
+	}
+	if c.downstreamID != 0 {
+		return c.unbound
+	}
+	p.availableClients = append(p.availableClients, c)
+	return false
+}
+
+// removeAvailableClient must be called with clientMux held
+func (p *connPool) removeAvailableClient(client *activeClient) {
+	for i, c := range p.availableClients {
+		if c == client {
+			p.availableClients[i] = nil
+			p.availableClients = append(p.availableClients[:i], p.availableClients[i+1:]...)
+			return
+		}
+	}
+}
+
+// inConnectBackoff must be called with clientMux held
+func (p *connPool) inConnectBackoff() bool {
+	return p.backoffInterval > 0 && time.Now().Before(p.backoffUntil)
+}
+
+// onConnectFailure must be called with clientMux held
+// once the consecutive failures reach the threshold, the backoff window starts
+// at the base interval and doubles on every further failure up to the max interval
+func (p *connPool) onConnectFailure() {
+	p.connectFailures++
+	cfg := p.host.ClusterInfo().ConnectBackoff()
+	if cfg.MaxFailures == 0 || p.connectFailures < cfg.MaxFailures {
+		return
+	}
+	if p.backoffInterval == 0 {
+		p.backoffInterval = cfg.BaseInterval
+	} else {
+		p.backoffInterval *= 2
+	}
+	if p.backoffInterval > cfg.MaxInterval {
+		p.backoffInterval = cfg.MaxInterval
+	}
+	p.backoffUntil = time.Now().Add(p.backoffInterval)
+	p.host.HostStats().UpstreamConnectionBackoff.Update(int64(p.backoffInterval / time.Millisecond))
+	log.DefaultLogger.Warnf("[stream] [http] [connpool] host %s connect failed %d times, backoff %s",
+		p.host.AddressString(), p.connectFailures, p.backoffInterval)
+}
+
+// resetConnectBackoff must be called with clientMux held
+func (p *connPool) resetConnectBackoff() {
+	if p.connectFailures == 0 && p.backoffInterval == 0 {
+		return
+	}
+	p.connectFailures = 0
+	p.backoffInterval = 0
+	p.backoffUntil = time.Time{}
+	p.host.HostStats().UpstreamConnectionBackoff.Update(0)
+}
+
+// ResetConnectBackoff implements types.ConnectBackoffPool
+func (p *connPool) ResetConnectBackoff() {
 	p.clientMux.Lock()
 	defer p.clientMux.Unlock()
 
-	for _, c := range p.availableClients {
+	p.resetConnectBackoff()
+}
+
+// connect dials the upstream host in a new goroutine,
+// the pending stream on the client is notified when the connect is done
+func (p *connPool) connect(ac *activeClient) {
+	utils.GoWithRecover(func() {
+		err := ac.client.Connect()
+		p.onConnectDone(ac, err)
+	}, nil)
+}
+
+func (p *connPool) onConnectDone(ac *activeClient, err error) {
+	pending := ac.pending
+	ac.pending = nil
+
+	// hold the pending stream, so Cancel waits for the notification in progress
+	pending.mux.Lock()
+	defer pending.mux.Unlock()
+
+	if err != nil {
+		p.clientMux.Lock()
+		// a failed connection never raises a close event, so release the count here
+		p.totalClientCount--
+		p.unbindClient(ac)
+		p.onConnectFailure()
+		p.clientMux.Unlock()
+
+		if !pending.canceled {
+			pending.listener.OnFailure(types.ConnectionFailure, p.host)
+		}
+		return
+	}
+
+	p.host.HostStats().UpstreamConnectionTotal.Inc(1)
+	p.host.HostStats().UpstreamConnectionActive.Inc(1)
+	p.host.ClusterInfo().Stats().UpstreamConnectionTotal.Inc(1)
+	p.host.ClusterInfo().Stats().UpstreamConnectionActive.Inc(1)
+	if ac.downstreamID != 0 {
+		p.host.HostStats().UpstreamConnectionPerDownstreamTotal.Inc(1)
+		p.host.HostStats().UpstreamConnectionPerDownstreamActive.Inc(1)
+		p.host.ClusterInfo().Stats().UpstreamConnectionPerDownstreamTotal.Inc(1)
+		p.host.ClusterInfo().Stats().UpstreamConnectionPerDownstreamActive.Inc(1)
+	}
+
+	// bytes total adds all connections data together
+	ac.client.SetConnectionCollector(p.host.ClusterInfo().Stats().UpstreamBytesReadTotal, p.host.ClusterInfo().Stats().UpstreamBytesWriteTotal)
+
+	p.clientMux.Lock()
+	p.resetConnectBackoff()
+	p.clients[ac.id] = ac
+	closeClient := false
+	// the stream is canceled while connecting, keep the connection for the next stream
+	if pending.canceled {
+		closeClient = p.releaseClient(ac)
+	}
+	p.clientMux.Unlock()
+
+	if !pending.canceled {
+		// the stream is accepted before the pool is closed, the client closes itself when the stream is done
+		p.newStream(pending.ctx, ac, pending.receiver, pending.listener)
+	} else if closeClient {
+		ac.client.Close()
+	}
+}
+
+// unbindClient removes the client from the downstream clients, it must be called with clientMux held
+func (p *connPool) unbindClient(ac *activeClient) {
+	if ac.downstreamID != 0 && p.downstreamClients[ac.downstreamID] == ac {
+		delete(p.downstreamClients, ac.downstreamID)
+	}
+}
+
+// Close closes the idle clients and rejects new streams,
+// the busy clients are closed when their streams are done
+func (p *connPool) Close() {
+	p.clientMux.Lock()
+	p.closed = true
+	clients := p.availableClients
+	p.availableClients = nil
+	for id, c := range p.downstreamClients {
+		if !c.busy {
+			clients = append(clients, c)
+		}
+		delete(p.downstreamClients, id)
+	}
+	p.clientMux.Unlock()
+
+	// closing a client raises the close event which needs the clientMux
+	for _, c := range clients {
 		c.client.Close()
 	}
 }
 
+// Snapshot implements types.ConnectionSnapshotPool
+func (p *connPool) Snapshot() types.ConnPoolSnapshot {
+	p.clientMux.Lock()
+	defer p.clientMux.Unlock()
+
+	snapshot := types.ConnPoolSnapshot{
+		Protocol:     protocol.HTTP1,
+		Host:         p.host.AddressString(),
+		TotalClients: p.totalClientCount,
+		Clients:      make([]types.UpstreamConnectionState, 0, len(p.clients)),
+	}
+	for _, c := range p.clients {
+		snapshot.Clients = append(snapshot.Clients, types.UpstreamConnectionState{
+			ID:           c.id,
+			ConnectionID: c.client.ConnID(),
+			Host:         snapshot.Host,
+			Active:       c.busy,
+			Draining:     c.draining,
+			DownstreamID: c.downstreamID,
+		})
+	}
+	sort.Slice(snapshot.Clients, func(i, j int) bool {
+		return snapshot.Clients[i].ConnectionID < snapshot.Clients[j].ConnectionID
+	})
+	return snapshot
+}
+
+// CloseConnection implements types.ConnectionSnapshotPool
+// a busy client closed gracefully is closed when its stream is done, others are closed immediately
+func (p *connPool) CloseConnection(id string, graceful bool) (bool, bool) {
+	p.clientMux.Lock()
+	c, ok := p.clients[id]
+	// the client closed by itself is removed from the clients
+	if !ok || c.closed {
+		p.clientMux.Unlock()
+		return false, false
+	}
+	active := c.busy
+	if active && graceful {
+		c.draining = true
+		p.clientMux.Unlock()
+		log.DefaultLogger.Infof("[stream] [http] [connpool] client %s is closed after the active stream is done", id)
+		return true, true
+	}
+	// an idle client should not be used by the new streams any more
+	p.removeAvailableClient(c)
+	p.clientMux.Unlock()
+
+	// closing a client raises the close event which needs the clientMux
+	log.DefaultLogger.Infof("[stream] [http] [connpool] close client %s, active stream: %v", id, active)
+	c.client.Close()
+	return true, active
+}
+
 func (p *connPool) Shutdown() {
 	// TODO: http connpool do nothing for shutdown
 }
@@ -158,15 +497,18 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 		defer p.clientMux.Unlock()
 
 		p.totalClientCount--
+		if p.clients[client.id] == client {
+			delete(p.clients, client.id)
+		}
 
-		for i, c := range p.availableClients {
-			if c == client {
-				p.availableClients[i] = nil
-				p.availableClients = append(p.availableClients[:i], p.availableClients[i+1:]...)
-				break
-			}
+		if client.downstreamID != 0 {
+			p.host.HostStats().UpstreamConnectionPerDownstreamActive.Dec(1)
+			p.host.ClusterInfo().Stats().UpstreamConnectionPerDownstreamActive.Dec(1)
+			p.unbindClient(client)
 		}
 
+		p.removeAvailableClient(client)
+
 		// set closed flag if not available
 		client.closed = true
 	} else if event == types.ConnectTimeout {
@@ -176,20 +518,52 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 	} else if event == types.ConnectFailed {
 		p.host.HostStats().UpstreamConnectionConFail.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamConnectionConFail.Inc(1)
+	} else if event == types.OnReadTimeout {
+		p.onReadTimeout(client)
+	}
+}
+
+// onReadTimeout sends a probe on the idle client,
+// the client is not used by the new streams until the probe is done
+func (p *connPool) onReadTimeout(client *activeClient) {
+	if client.keepAlive == nil {
+		return
+	}
+	p.clientMux.Lock()
+	if p.closed || client.busy || client.closed || client.draining {
+		p.clientMux.Unlock()
+		return
+	}
+	p.removeAvailableClient(client)
+	client.busy = true
+	p.clientMux.Unlock()
+
+	client.keepAlive.SendKeepAlive()
+}
+
+// onKeepAliveDone makes the client available again after the probe is done
+func (p *connPool) onKeepAliveDone(client *activeClient) {
+	p.clientMux.Lock()
+	closeClient := p.releaseClient(client)
+	p.clientMux.Unlock()
+
+	if closeClient {
+		client.client.Close()
 	}
 }
 
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
 
-	// return to pool
+	// return to pool, the client is closed if the pool or the downstream connection is closed while the stream is active
 	p.clientMux.Lock()
-	if !client.closed {
-		p.availableClients = append(p.availableClients, client)
-	}
+	closeClient := p.releaseClient(client)
 	p.clientMux.Unlock()
+
+	if closeClient {
+		client.client.Close()
+	}
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
@@ -215,13 +589,30 @@ func (p *connPool) report() {
 	utils.GoWithRecover(func() {
 		for {
 			p.clientMux.Lock()
-			log.DefaultLogger.Infof("[stream] [http] [connpool] pool = %s, available clients=%d, total clients=%d\n", p.host.Address(), len(p.availableClients), p.totalClientCount)
+			log.DefaultLogger.Infof("[stream] [http] [connpool] pool = %s, available clients=%d, downstream clients=%d, total clients=%d\n", p.host.Address(), len(p.availableClients), len(p.downstreamClients), p.totalClientCount)
 			p.clientMux.Unlock()
 			time.Sleep(time.Second)
 		}
 	}, nil)
 }
 
+// pendingStream is a stream waiting for its client to be connected
+// types.Cancellable
+type pendingStream struct {
+	mux      sync.Mutex
+	ctx      context.Context
+	receiver types.StreamReceiveListener
+	listener types.PoolEventListener
+	canceled bool
+}
+
+// Cancel makes sure the listener is not notified after it returns
+func (ps *pendingStream) Cancel() {
+	ps.mux.Lock()
+	ps.canceled = true
+	ps.mux.Unlock()
+}
+
 // types.StreamEventListener
 // types.ConnectionEventListener
 // types.StreamConnectionEventListener
@@ -233,34 +624,45 @@ type activeClient struct {
 	closeWithActiveReq bool
 	closed             bool
 	closeConn          bool
+	// the stream waiting for the connect
+	pending *pendingStream
+	// the downstream connection id of a client in the per downstream mode, zero means a shared client
+	downstreamID uint64
+	// busy means a stream is active or pending on the client, protected by pool's clientMux
+	busy bool
+	// unbound is used by the per downstream mode only, protected by pool's clientMux
+	unbound bool
+	// the stable identifier of the client, the connection id plus the host address
+	id string
+	// the client is closed after the active stream is done, protected by pool's clientMux
+	draining bool
+	// keepAlive sends the probes on the idle client, nil if the cluster has no http probe
+	keepAlive types.KeepAlive
 }
 
-func newActiveClient(ctx context.Context, pool *connPool) (*activeClient, types.PoolFailureReason) {
+// newActiveClient creates a client that is not connected yet, it must be called with pool's clientMux held
+func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
 	ac := &activeClient{
 		pool: pool,
 	}
 
 	data := pool.host.CreateConnection(ctx)
+	str.RecordConnectDuration(pool.host, data.Connection)
 	codecClient := pool.createStreamClient(ctx, data)
 	codecClient.AddConnectionEventListener(ac)
 	codecClient.SetStreamConnectionEventListener(ac)
 
 	ac.client = codecClient
 	ac.host = data
+	ac.id = fmt.Sprintf("%d@%s", codecClient.ConnID(), pool.host.AddressString())
 
-	if err := ac.client.Connect(); err != nil {
-		return nil, types.ConnectionFailure
+	// the probes are sent when the connection is idle, see connPool.onReadTimeout
+	if config := pool.host.ClusterInfo().KeepAlive(); config.HTTPProbe != nil {
+		ac.keepAlive = NewHTTPKeepAlive(codecClient, config)
+		ac.keepAlive.AddCallback(ac.onKeepAlive)
 	}
 
-	pool.host.HostStats().UpstreamConnectionTotal.Inc(1)
-	pool.host.HostStats().UpstreamConnectionActive.Inc(1)
-	pool.host.ClusterInfo().Stats().UpstreamConnectionTotal.Inc(1)
-	pool.host.ClusterInfo().Stats().UpstreamConnectionActive.Inc(1)
-
-	// bytes total adds all connections data together
-	codecClient.SetConnectionCollector(pool.host.ClusterInfo().Stats().UpstreamBytesReadTotal, pool.host.ClusterInfo().Stats().UpstreamBytesWriteTotal)
-
-	return ac, ""
+	return ac
 }
 
 // types.ConnectionEventListener
@@ -268,6 +670,18 @@ func (ac *activeClient) OnEvent(event types.ConnectionEvent) {
 	ac.pool.onConnectionEvent(ac, event)
 }
 
+// onKeepAlive records the probes in the stats, releases the client once the probe is done,
+// and ejects the host if the probes are failed too many times
+func (ac *activeClient) onKeepAlive(status types.KeepAliveStatus) {
+	str.RecordKeepAlive(ac.pool.host, status)
+	switch status {
+	case types.KeepAliveThresholdExceeded:
+		str.EjectKeepAliveFailedHost(ac.pool.host)
+	case types.KeepAliveSuccess, types.KeepAliveTimeout:
+		ac.pool.onKeepAliveDone(ac)
+	}
+}
+
 // types.StreamEventListener
 func (ac *activeClient) OnDestroyStream() {
 	if !ac.closed && ac.closeConn {
@@ -289,3 +703,16 @@ func (ac *activeClient) OnResetStream(reason types.StreamResetReason) {
 func (ac *activeClient) OnGoAway() {
 	ac.closeConn = true
 }
+
+// downstreamListener closes the client bound to the downstream connection
+// types.ConnectionEventListener
+type downstreamListener struct {
+	pool *connPool
+	id   uint64
+}
+
+func (dl *downstreamListener) OnEvent(event types.ConnectionEvent) {
+	if event.IsClose() {
+		dl.pool.onDownstreamClose(dl.id)
+	}
+}
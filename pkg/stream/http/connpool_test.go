@@ -0,0 +1,692 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+type mockPoolListener struct {
+	mux      sync.Mutex
+	failures []types.PoolFailureReason
+	ready    int
+	notify   chan struct{}
+}
+
+func newMockPoolListener() *mockPoolListener {
+	return &mockPoolListener{
+		notify: make(chan struct{}, 16),
+	}
+}
+
+func (l *mockPoolListener) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	l.mux.Lock()
+	l.failures = append(l.failures, reason)
+	l.mux.Unlock()
+	l.notify <- struct{}{}
+}
+
+func (l *mockPoolListener) OnReady(sender types.StreamSender, host types.Host) {
+	l.mux.Lock()
+	l.ready++
+	l.mux.Unlock()
+	l.notify <- struct{}{}
+}
+
+// wait waits for a pool callback
+func (l *mockPoolListener) wait(t *testing.T) {
+	select {
+	case <-l.notify:
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait pool callback timeout")
+	}
+}
+
+// deadAddress returns an address that refuses connections
+func deadAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func newTestHost(t *testing.T, name, addr string, thresholds v2.Thresholds) types.Host {
+	return newTestHostWithCluster(t, v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{thresholds},
+		},
+	}, addr)
+}
+
+func newTestHostWithCluster(t *testing.T, config v2.Cluster, addr string) types.Host {
+	c := cluster.NewCluster(config)
+	return cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: addr,
+		},
+	}, c.Snapshot().ClusterInfo())
+}
+
+func TestConnPoolConnectBackoff(t *testing.T) {
+	host := newTestHost(t, "connect_backoff", deadAddress(t), v2.Thresholds{
+		MaxConnections:     10,
+		MaxRequests:        10,
+		MaxConnectFailures: 2,
+		ConnectBackoffBase: &v2.DurationConfig{Duration: time.Second},
+		ConnectBackoffMax:  &v2.DurationConfig{Duration: 3 * time.Second},
+	})
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+
+	// first failure does not trigger backoff
+	pool.NewStream(context.Background(), nil, listener)
+	listener.wait(t)
+	if pool.connectFailures != 1 || pool.backoffInterval != 0 {
+		t.Fatalf("unexpected backoff state: failures %d, interval %s", pool.connectFailures, pool.backoffInterval)
+	}
+	// reach the threshold
+	pool.NewStream(context.Background(), nil, listener)
+	listener.wait(t)
+	if pool.connectFailures != 2 || pool.backoffInterval != time.Second {
+		t.Fatalf("unexpected backoff state: failures %d, interval %s", pool.connectFailures, pool.backoffInterval)
+	}
+	if v := host.HostStats().UpstreamConnectionBackoff.Value(); v != 1000 {
+		t.Fatalf("backoff gauge expected 1000, but got %d", v)
+	}
+	// in backoff window, no dial
+	pool.NewStream(context.Background(), nil, listener)
+	listener.wait(t)
+	if pool.connectFailures != 2 {
+		t.Fatalf("pool should not dial in backoff window, failures %d", pool.connectFailures)
+	}
+	// window ends, dial again and the window doubles up to max
+	for _, expected := range []time.Duration{2 * time.Second, 3 * time.Second} {
+		pool.backoffUntil = time.Now()
+		pool.NewStream(context.Background(), nil, listener)
+		listener.wait(t)
+		if pool.backoffInterval != expected {
+			t.Fatalf("backoff interval expected %s, but got %s", expected, pool.backoffInterval)
+		}
+	}
+	if len(listener.failures) != 5 || listener.ready != 0 {
+		t.Fatalf("unexpected pool callbacks: %v, ready %d", listener.failures, listener.ready)
+	}
+	for _, reason := range listener.failures {
+		if reason != types.ConnectionFailure {
+			t.Fatalf("unexpected failure reason: %s", reason)
+		}
+	}
+	if pool.totalClientCount != 0 {
+		t.Fatalf("failed connections should not be counted, total clients %d", pool.totalClientCount)
+	}
+	if n := host.HostStats().UpstreamConnectionConnectDurationMs.Count(); n != 0 {
+		t.Fatalf("failed connections should not record connect duration, but got %d samples", n)
+	}
+	// health checker clears the state
+	var bp types.ConnectBackoffPool = pool
+	bp.ResetConnectBackoff()
+	if pool.connectFailures != 0 || pool.inConnectBackoff() {
+		t.Fatal("reset connect backoff failed")
+	}
+	if v := host.HostStats().UpstreamConnectionBackoff.Value(); v != 0 {
+		t.Fatalf("backoff gauge expected 0, but got %d", v)
+	}
+}
+
+func TestConnPoolConnectBackoffDisabled(t *testing.T) {
+	host := newTestHost(t, "connect_backoff_disabled", deadAddress(t), v2.Thresholds{
+		MaxConnections: 10,
+		MaxRequests:    10,
+	})
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+	for i := 0; i < 3; i++ {
+		pool.NewStream(context.Background(), nil, listener)
+		listener.wait(t)
+	}
+	if pool.connectFailures != 3 || pool.inConnectBackoff() {
+		t.Fatalf("backoff should be disabled, failures %d, interval %s", pool.connectFailures, pool.backoffInterval)
+	}
+}
+
+func TestConnPoolAsyncConnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host := newTestHost(t, "async_connect", ln.Addr().String(), v2.Thresholds{
+		MaxConnections: 10,
+		MaxRequests:    10,
+	})
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+
+	// the stream waits for the connect
+	if cancel := pool.NewStream(context.Background(), nil, listener); cancel == nil {
+		t.Fatal("new stream on a new connection should be pending")
+	}
+	listener.wait(t)
+	if listener.ready != 1 || len(listener.failures) != 0 {
+		t.Fatalf("unexpected pool callbacks: %v, ready %d", listener.failures, listener.ready)
+	}
+	// the connect duration is recorded when connected
+	if n := host.HostStats().UpstreamConnectionConnectDurationMs.Count(); n != 1 {
+		t.Fatalf("host connect duration expected 1 sample, but got %d", n)
+	}
+	if n := host.ClusterInfo().Stats().UpstreamConnectionConnectDurationMs.Count(); n != 1 {
+		t.Fatalf("cluster connect duration expected 1 sample, but got %d", n)
+	}
+
+	// canceled stream is never notified, and the connection is kept in the pool
+	cancel := pool.NewStream(context.Background(), nil, listener)
+	if cancel == nil {
+		t.Fatal("new stream on a new connection should be pending")
+	}
+	cancel.Cancel()
+	for i := 0; ; i++ {
+		pool.clientMux.Lock()
+		n := len(pool.availableClients)
+		pool.clientMux.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 30 {
+			t.Fatal("canceled stream's connection is not returned to the pool")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if listener.ready != 1 {
+		t.Fatalf("canceled stream should not be notified, ready %d", listener.ready)
+	}
+
+	// the idle connection is used directly
+	if cancel := pool.NewStream(context.Background(), nil, listener); cancel != nil {
+		t.Fatal("new stream on an idle connection should not be pending")
+	}
+	if listener.ready != 2 {
+		t.Fatalf("stream on an idle connection should be ready, ready %d", listener.ready)
+	}
+}
+
+func waitClientClosed(t *testing.T, pool *connPool, c *activeClient) {
+	for i := 0; i < 30; i++ {
+		pool.clientMux.Lock()
+		closed := c.closed
+		pool.clientMux.Unlock()
+		if closed {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("client is not closed")
+}
+
+func TestConnPoolClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host := newTestHost(t, "pool_close", ln.Addr().String(), v2.Thresholds{
+		MaxConnections: 10,
+		MaxRequests:    10,
+	})
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+
+	// make two idle clients
+	for i := 0; i < 2; i++ {
+		pool.NewStream(context.Background(), nil, listener).Cancel()
+	}
+	for i := 0; ; i++ {
+		pool.clientMux.Lock()
+		n := len(pool.availableClients)
+		pool.clientMux.Unlock()
+		if n == 2 {
+			break
+		}
+		if i == 30 {
+			t.Fatalf("expected 2 idle clients, but got %d", n)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// one of them is busy
+	busy, _, _ := pool.getAvailableClient(context.Background())
+	pool.newStream(context.Background(), busy, nil, listener)
+	listener.wait(t)
+	idle := pool.availableClients[0]
+
+	// idle client is closed immediately, new stream is rejected
+	pool.Close()
+	waitClientClosed(t, pool, idle)
+	if cancel := pool.NewStream(context.Background(), nil, listener); cancel != nil {
+		t.Fatal("closed pool should not dial")
+	}
+	listener.wait(t)
+	if len(listener.failures) != 1 || listener.failures[0] != types.PoolClosed {
+		t.Fatalf("expected pool closed failure, but got %v", listener.failures)
+	}
+	if busy.closed {
+		t.Fatal("busy client should not be closed before the stream is done")
+	}
+
+	// busy client is closed after the stream is done
+	busy.OnDestroyStream()
+	waitClientClosed(t, pool, busy)
+	if len(pool.availableClients) != 0 {
+		t.Fatalf("closed pool should not keep clients, but got %d", len(pool.availableClients))
+	}
+}
+
+func TestConnPoolCloseConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host := newTestHost(t, "close_connection", ln.Addr().String(), v2.Thresholds{
+		MaxConnections: 10,
+		MaxRequests:    10,
+	})
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+
+	// make two idle clients
+	for i := 0; i < 2; i++ {
+		pool.NewStream(context.Background(), nil, listener).Cancel()
+	}
+	for i := 0; ; i++ {
+		pool.clientMux.Lock()
+		n := len(pool.availableClients)
+		pool.clientMux.Unlock()
+		if n == 2 {
+			break
+		}
+		if i == 30 {
+			t.Fatalf("expected 2 idle clients, but got %d", n)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// one of them serves a slow stream
+	busy, _, _ := pool.getAvailableClient(context.Background())
+	pool.newStream(context.Background(), busy, nil, listener)
+	listener.wait(t)
+	idle := pool.availableClients[0]
+
+	snapshot := pool.Snapshot()
+	if snapshot.TotalClients != 2 || len(snapshot.Clients) != 2 {
+		t.Fatalf("expected 2 clients in snapshot, but got %+v", snapshot)
+	}
+	for _, c := range snapshot.Clients {
+		if c.ID != fmt.Sprintf("%d@%s", c.ConnectionID, ln.Addr().String()) {
+			t.Fatalf("unexpected client id %s", c.ID)
+		}
+		if c.Active != (c.ID == busy.id) {
+			t.Fatalf("unexpected active state of client %s", c.ID)
+		}
+	}
+
+	// the busy client is closed after the stream is done
+	if found, active := pool.CloseConnection(busy.id, true); !found || !active {
+		t.Fatalf("expected the busy client is found and active, but got found %v, active %v", found, active)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if busy.closed {
+		t.Fatal("busy client should not be closed before the stream is done")
+	}
+	busy.OnDestroyStream()
+	waitClientClosed(t, pool, busy)
+
+	// the idle client is closed immediately
+	if found, active := pool.CloseConnection(idle.id, false); !found || active {
+		t.Fatalf("expected the idle client is found and idle, but got found %v, active %v", found, active)
+	}
+	waitClientClosed(t, pool, idle)
+
+	pool.clientMux.Lock()
+	available, total := len(pool.availableClients), pool.totalClientCount
+	pool.clientMux.Unlock()
+	if available != 0 || total != 0 {
+		t.Fatalf("expected no clients in pool, but got available %d, total %d", available, total)
+	}
+	if snapshot := pool.Snapshot(); len(snapshot.Clients) != 0 {
+		t.Fatalf("expected no clients in snapshot, but got %+v", snapshot.Clients)
+	}
+	// the closed client is not found
+	if found, _ := pool.CloseConnection(idle.id, false); found {
+		t.Fatal("closed client should not be found")
+	}
+	if found, _ := pool.CloseConnection("unknown", true); found {
+		t.Fatal("unknown client should not be found")
+	}
+}
+
+// newDownstreamContext returns a stream context of a downstream connection
+func newDownstreamContext() (context.Context, types.Connection) {
+	rawc, _ := net.Pipe()
+	conn := network.NewServerConnection(context.Background(), rawc, nil)
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyConnectionID, conn.ID())
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyConnection, conn)
+	return ctx, conn
+}
+
+func TestConnPoolPerDownstream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host := newTestHostWithCluster(t, v2.Cluster{
+		Name:         "per_downstream",
+		ClusterType:  v2.SIMPLE_CLUSTER,
+		LbType:       v2.LB_RANDOM,
+		ConnPoolMode: v2.PER_DOWNSTREAM_CONN_POOL,
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{{
+				MaxConnections: 10,
+				MaxRequests:    10,
+			}},
+		},
+	}, ln.Addr().String())
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+
+	ctx1, conn1 := newDownstreamContext()
+	ctx2, _ := newDownstreamContext()
+
+	// the first stream dials a client for the downstream connection
+	if cancel := pool.NewStream(ctx1, nil, listener); cancel == nil {
+		t.Fatal("new stream on a new connection should be pending")
+	}
+	listener.wait(t)
+	c1 := pool.downstreamClients[conn1.ID()]
+	if c1 == nil || listener.ready != 1 {
+		t.Fatalf("downstream client is not created, ready %d", listener.ready)
+	}
+	// the bound client serves one stream at a time
+	pool.NewStream(ctx1, nil, listener)
+	listener.wait(t)
+	if len(listener.failures) != 1 || listener.failures[0] != types.Overflow {
+		t.Fatalf("expected overflow failure, but got %v", listener.failures)
+	}
+
+	// the next stream of the same downstream connection uses the same client
+	c1.OnDestroyStream()
+	if cancel := pool.NewStream(ctx1, nil, listener); cancel != nil {
+		t.Fatal("new stream on the bound connection should not be pending")
+	}
+	listener.wait(t)
+	if listener.ready != 2 || pool.downstreamClients[conn1.ID()] != c1 {
+		t.Fatalf("stream should use the bound client, ready %d", listener.ready)
+	}
+	c1.OnDestroyStream()
+
+	// another downstream connection has its own client
+	pool.NewStream(ctx2, nil, listener)
+	listener.wait(t)
+	if len(pool.downstreamClients) != 2 || len(pool.availableClients) != 0 {
+		t.Fatalf("unexpected clients: downstream %d, shared %d", len(pool.downstreamClients), len(pool.availableClients))
+	}
+	if v := host.HostStats().UpstreamConnectionPerDownstreamTotal.Count(); v != 2 {
+		t.Fatalf("per downstream connection total expected 2, but got %d", v)
+	}
+	if v := host.HostStats().UpstreamConnectionTotal.Count(); v != 2 {
+		t.Fatalf("connection total expected 2, but got %d", v)
+	}
+
+	// the client is closed with the downstream connection
+	conn1.Close(types.NoFlush, types.RemoteClose)
+	waitClientClosed(t, pool, c1)
+	pool.clientMux.Lock()
+	_, bound := pool.downstreamClients[conn1.ID()]
+	pool.clientMux.Unlock()
+	if bound || len(pool.downstreamClients) != 1 {
+		t.Fatalf("closed downstream connection should not have a client, clients %d", len(pool.downstreamClients))
+	}
+	if v := host.HostStats().UpstreamConnectionPerDownstreamActive.Count(); v != 1 {
+		t.Fatalf("per downstream connection active expected 1, but got %d", v)
+	}
+
+	// a stream without downstream connection uses the shared clients
+	pool.NewStream(context.Background(), nil, listener).Cancel()
+	for i := 0; ; i++ {
+		pool.clientMux.Lock()
+		n := len(pool.availableClients)
+		pool.clientMux.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 30 {
+			t.Fatal("stream without downstream connection should use a shared client")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if v := host.HostStats().UpstreamConnectionPerDownstreamTotal.Count(); v != 2 {
+		t.Fatalf("shared client should not be counted as per downstream, but got %d", v)
+	}
+}
+
+// listenedConnection counts the listeners added to the downstream connection
+type listenedConnection struct {
+	types.Connection
+	id        uint64
+	listeners int
+}
+
+func (c *listenedConnection) ID() uint64 {
+	return c.id
+}
+
+func (c *listenedConnection) AddConnectionEventListener(listener types.ConnectionEventListener) {
+	c.listeners++
+}
+
+func TestConnPoolDownstreamListenedOnce(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host := newTestHostWithCluster(t, v2.Cluster{
+		Name:         "per_downstream_listened",
+		ClusterType:  v2.SIMPLE_CLUSTER,
+		LbType:       v2.LB_RANDOM,
+		ConnPoolMode: v2.PER_DOWNSTREAM_CONN_POOL,
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{{
+				MaxConnections: 10,
+				MaxRequests:    10,
+			}},
+		},
+	}, ln.Addr().String())
+	pool := NewConnPool(host).(*connPool)
+	conn := &listenedConnection{id: 1}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyConnectionID, conn.id)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyConnection, conn)
+
+	// the clients of the downstream connection are created again after closed
+	for i := 0; i < 3; i++ {
+		pool.clientMux.Lock()
+		c, _, reason := pool.getDownstreamClient(ctx, conn.id)
+		if c != nil {
+			pool.unbindClient(c)
+		}
+		pool.clientMux.Unlock()
+		if c == nil {
+			t.Fatalf("get downstream client failed: %s", reason)
+		}
+		c.client.Close()
+	}
+	if conn.listeners != 1 {
+		t.Fatalf("the downstream connection should be listened once, but got %d", conn.listeners)
+	}
+	pool.onDownstreamClose(conn.id)
+	pool.clientMux.Lock()
+	_, listened := pool.downstreamListened[conn.id]
+	pool.clientMux.Unlock()
+	if listened {
+		t.Fatal("the closed downstream connection should not be listened")
+	}
+}
+
+// waitIdleClients waits for the pool has n idle clients
+func waitIdleClients(t *testing.T, pool *connPool, n int) {
+	for i := 0; ; i++ {
+		pool.clientMux.Lock()
+		count := len(pool.availableClients)
+		pool.clientMux.Unlock()
+		if count == n {
+			return
+		}
+		if i == 30 {
+			t.Fatalf("expected %d idle clients, but got %d", n, count)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestConnPoolKeepAlive(t *testing.T) {
+	var mux sync.Mutex
+	var probes []string
+	code := http.StatusOK
+	delay := time.Duration(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		probes = append(probes, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Probe")))
+		c, d := code, delay
+		mux.Unlock()
+		time.Sleep(d)
+		w.WriteHeader(c)
+	}))
+	defer server.Close()
+	host := newTestHostWithCluster(t, v2.Cluster{
+		Name:        "keepalive",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{{MaxConnections: 10, MaxRequests: 10}},
+		},
+		KeepAlive: &v2.KeepAlive{
+			Timeout:          &v2.DurationConfig{Duration: 200 * time.Millisecond},
+			FailCountToClose: 2,
+			HTTPProbe: &v2.HTTPKeepAliveProbe{
+				Path:    "/health",
+				Headers: map[string]string{"X-Probe": "mosn"},
+			},
+		},
+	}, server.Listener.Addr().String())
+	pool := NewConnPool(host).(*connPool)
+	listener := newMockPoolListener()
+	pool.NewStream(context.Background(), nil, listener).Cancel()
+	waitIdleClients(t, pool, 1)
+	client := pool.availableClients[0]
+
+	// the probe succeeds, the client is available again
+	pool.onReadTimeout(client)
+	waitIdleClients(t, pool, 1)
+	mux.Lock()
+	if len(probes) != 1 || probes[0] != "OPTIONS /health mosn" {
+		t.Fatalf("unexpected probes: %v", probes)
+	}
+	// the failure response is counted, the client is kept until the threshold
+	code = http.StatusServiceUnavailable
+	mux.Unlock()
+	pool.onReadTimeout(client)
+	waitIdleClients(t, pool, 1)
+	if client.closed {
+		t.Fatal("client is closed before the threshold")
+	}
+	pool.onReadTimeout(client)
+	waitClientClosed(t, pool, client)
+	if !host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Error("the host is not ejected")
+	}
+	if n := host.HostStats().UpstreamKeepAliveEject.Count(); n != 1 {
+		t.Errorf("expected 1 keepalive eject, but got %d", n)
+	}
+
+	// a probe timeout closes the connection
+	mux.Lock()
+	code = http.StatusOK
+	delay = time.Second
+	mux.Unlock()
+	pool.NewStream(context.Background(), nil, listener).Cancel()
+	waitIdleClients(t, pool, 1)
+	client = pool.availableClients[0]
+	pool.onReadTimeout(client)
+	waitIdleClients(t, pool, 0)
+	waitClientClosed(t, pool, client)
+
+	// the probe results are counted in the host stats
+	stats := host.HostStats()
+	for i := 0; stats.UpstreamKeepAliveOutstanding.Count() != 0 || stats.UpstreamKeepAliveClosedConnectionTotal.Count() != 2; i++ {
+		if i == 30 {
+			t.Fatalf("unexpected outstanding probes: %d, closed connections: %d",
+				stats.UpstreamKeepAliveOutstanding.Count(), stats.UpstreamKeepAliveClosedConnectionTotal.Count())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if stats.UpstreamKeepAliveSuccessTotal.Count() != 1 || stats.UpstreamKeepAliveTimeoutTotal.Count() != 3 {
+		t.Errorf("unexpected probe results, success: %d, timeout: %d",
+			stats.UpstreamKeepAliveSuccessTotal.Count(), stats.UpstreamKeepAliveTimeoutTotal.Count())
+	}
+}
+
+func TestConnPoolKeepAliveDisabled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	host := newTestHost(t, "keepalive_disabled", ln.Addr().String(), v2.Thresholds{
+		MaxConnections: 10,
+		MaxRequests:    10,
+	})
+	pool := NewConnPool(host).(*connPool)
+	pool.NewStream(context.Background(), nil, newMockPoolListener()).Cancel()
+	waitIdleClients(t, pool, 1)
+	client := pool.availableClients[0]
+	if client.keepAlive != nil {
+		t.Fatal("no keepalive is expected without the http probe")
+	}
+	// the idle client is kept available
+	pool.onReadTimeout(client)
+	if len(pool.availableClients) != 1 || client.busy {
+		t.Fatal("the idle client is changed without the keepalive")
+	}
+}
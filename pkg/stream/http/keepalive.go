@@ -0,0 +1,242 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// the default probe is "OPTIONS /"
+const (
+	defaultProbeMethod = http.MethodOptions
+	defaultProbePath   = "/"
+)
+
+// httpKeepAlive sends a probe request on the idle http1 connection,
+// the responses other than 2xx and 4xx are failures, the connection is closed
+// if the probe fails too many times.
+// types.KeepAlive
+// types.StreamReceiveListener
+type httpKeepAlive struct {
+	Codec     str.Client
+	Probe     v2.HTTPKeepAliveProbe
+	Timeout   time.Duration
+	Threshold uint32
+	Callbacks []types.KeepAliveCallback
+	// runtime
+	failCount uint32
+	// stop channel will stop all keep alive action
+	once sync.Once
+	stop chan struct{}
+	// requests records the running probe, a probe is handled once: response or timeout
+	requests map[uint64]*utils.Timer
+	mutex    sync.Mutex
+}
+
+// NewHTTPKeepAlive creates a keepalive sends the probe in the config,
+// the method and path are "OPTIONS /" if they are not configured
+func NewHTTPKeepAlive(codec str.Client, config types.KeepAliveConfig) types.KeepAlive {
+	kp := &httpKeepAlive{
+		Codec:     codec,
+		Timeout:   config.Timeout,
+		Threshold: config.FailCountToClose,
+		Callbacks: []types.KeepAliveCallback{},
+		stop:      make(chan struct{}),
+		requests:  make(map[uint64]*utils.Timer),
+	}
+	if config.HTTPProbe != nil {
+		kp.Probe = *config.HTTPProbe
+	}
+	if kp.Probe.Method == "" {
+		kp.Probe.Method = defaultProbeMethod
+	}
+	if kp.Probe.Path == "" {
+		kp.Probe.Path = defaultProbePath
+	}
+	// if connection is closed, keepalive should stop
+	kp.Codec.AddConnectionEventListener(kp)
+	return kp
+}
+
+// keepalive should stop when connection closed
+func (kp *httpKeepAlive) OnEvent(event types.ConnectionEvent) {
+	if event.IsClose() || event.ConnectFailure() {
+		kp.Stop()
+	}
+}
+
+func (kp *httpKeepAlive) AddCallback(cb types.KeepAliveCallback) {
+	kp.Callbacks = append(kp.Callbacks, cb)
+}
+
+func (kp *httpKeepAlive) runCallback(status types.KeepAliveStatus) {
+	for _, cb := range kp.Callbacks {
+		cb(status)
+	}
+}
+
+// SendKeepAlive sends the probe request, the caller makes sure the connection is idle,
+// as the http1 connection handles one request at a time
+func (kp *httpKeepAlive) SendKeepAlive() {
+	select {
+	case <-kp.stop:
+		return
+	default:
+		kp.sendKeepAlive()
+	}
+}
+
+func (kp *httpKeepAlive) sendKeepAlive() {
+	ctx := context.Background()
+	sender := kp.Codec.NewStream(ctx, kp)
+	id := sender.GetStream().ID()
+	// start a timer before sending, the response may be received before AppendHeaders returns
+	kp.mutex.Lock()
+	kp.requests[id] = utils.NewTimer(kp.Timeout, func() {
+		kp.HandleTimeout(id)
+	})
+	kp.mutex.Unlock()
+	kp.runCallback(types.KeepAliveSent)
+	sender.AppendHeaders(ctx, kp.newProbe(), true)
+}
+
+func (kp *httpKeepAlive) newProbe() mosnhttp.RequestHeader {
+	header := mosnhttp.RequestHeader{
+		RequestHeader: &fasthttp.RequestHeader{},
+	}
+	for k, v := range kp.Probe.Headers {
+		header.Set(k, v)
+	}
+	header.Set(protocol.MosnHeaderMethod, kp.Probe.Method)
+	header.Set(protocol.MosnHeaderPathKey, kp.Probe.Path)
+	return header
+}
+
+// StartIdleTimeout does nothing, the idle http1 connections are freed by the idle checker
+func (kp *httpKeepAlive) StartIdleTimeout() {
+}
+
+func (kp *httpKeepAlive) GetTimeout() time.Duration {
+	return kp.Timeout
+}
+
+// HandleTimeout closes the connection, the late response would be taken as the response of the next request.
+func (kp *httpKeepAlive) HandleTimeout(id uint64) {
+	kp.handleFailure(id, true)
+}
+
+func (kp *httpKeepAlive) HandleSuccess(id uint64) {
+	select {
+	case <-kp.stop:
+		return
+	default:
+		kp.mutex.Lock()
+		timer, ok := kp.requests[id]
+		delete(kp.requests, id)
+		kp.mutex.Unlock()
+		if !ok {
+			return
+		}
+		timer.Stop()
+		// reset the fail count
+		atomic.StoreUint32(&kp.failCount, 0)
+		kp.runCallback(types.KeepAliveSuccess)
+	}
+}
+
+// handleFailure counts a failed probe, the failure response is reported as a timeout too
+func (kp *httpKeepAlive) handleFailure(id uint64, timeout bool) {
+	select {
+	case <-kp.stop:
+		return
+	default:
+		kp.mutex.Lock()
+		timer, ok := kp.requests[id]
+		delete(kp.requests, id)
+		kp.mutex.Unlock()
+		if !ok {
+			return
+		}
+		timer.Stop()
+		count := atomic.AddUint32(&kp.failCount, 1)
+		if count >= kp.Threshold {
+			kp.runCallback(types.KeepAliveThresholdExceeded)
+		}
+		// close the connection, stop keep alive
+		// the lock is not held, as the close event stops the keepalive
+		if timeout || count >= kp.Threshold {
+			log.DefaultLogger.Infof("[stream] [http] [keepalive] connection %d probe failed %d times, timeout: %v", kp.Codec.ConnID(), count, timeout)
+			kp.Codec.Close()
+			kp.runCallback(types.KeepAliveClosed)
+		}
+		kp.runCallback(types.KeepAliveTimeout)
+	}
+}
+
+func (kp *httpKeepAlive) Stop() {
+	kp.once.Do(func() {
+		log.DefaultLogger.Infof("[stream] [http] [keepalive] connection %d stopped keepalive", kp.Codec.ConnID())
+		close(kp.stop)
+		// the outstanding probe will never be handled
+		kp.mutex.Lock()
+		canceled := len(kp.requests)
+		for id, timer := range kp.requests {
+			timer.Stop()
+			delete(kp.requests, id)
+		}
+		kp.mutex.Unlock()
+		for i := 0; i < canceled; i++ {
+			kp.runCallback(types.KeepAliveCanceled)
+		}
+	})
+}
+
+// types.StreamReceiveListener
+func (kp *httpKeepAlive) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	resp, ok := headers.(mosnhttp.ResponseHeader)
+	if !ok {
+		return
+	}
+	id, ok := protocol.StreamIDByContext(ctx)
+	if !ok {
+		return
+	}
+	// the 4xx response means the upstream is alive, it just does not support the probe
+	code := resp.StatusCode()
+	if (code >= 200 && code < 300) || (code >= 400 && code < 500) {
+		kp.HandleSuccess(id)
+		return
+	}
+	kp.handleFailure(id, false)
+}
+
+func (kp *httpKeepAlive) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
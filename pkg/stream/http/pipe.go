@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	// pipeInitSize is the initial size of the buffer in the pipe
+	pipeInitSize = 4 * 1024
+	// pipeMaxBuffered limits the bytes buffered in the pipe, the dispatching waits
+	// until the codec has read some of them, so a slow codec still slows down the connection reading
+	pipeMaxBuffered = 64 * 1024
+)
+
+// pipe hands over the data read by the connection to the codec goroutine.
+// The data is copied into the pipe, so the dispatched buffer is not retained,
+// and the codec reads whatever is buffered without a round trip to the connection goroutine.
+type pipe struct {
+	mutex  sync.Mutex
+	cond   sync.Cond
+	buf    types.IoBuffer
+	closed bool
+}
+
+func newPipe() *pipe {
+	p := &pipe{
+		buf: buffer.NewIoBuffer(pipeInitSize),
+	}
+	p.cond.L = &p.mutex
+	return p
+}
+
+// write copies all the data of the buffer into the pipe and drains the buffer,
+// the data is dropped if the pipe is closed
+func (p *pipe) write(data types.IoBuffer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for data.Len() > 0 {
+		for !p.closed && p.buf.Len() >= pipeMaxBuffered {
+			p.cond.Wait()
+		}
+		if p.closed {
+			data.Drain(data.Len())
+			return
+		}
+
+		n := pipeMaxBuffered - p.buf.Len()
+		if n > data.Len() {
+			n = data.Len()
+		}
+		p.buf.Write(data.Bytes()[:n])
+		data.Drain(n)
+		p.cond.Broadcast()
+	}
+}
+
+// Read reads the buffered data, it blocks until some data is dispatched or the pipe is closed.
+// The data buffered before the pipe is closed is still readable.
+func (p *pipe) Read(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for !p.closed && p.buf.Len() == 0 {
+		p.cond.Wait()
+	}
+	if p.buf.Len() == 0 {
+		return 0, errConnClose
+	}
+
+	full := p.buf.Len() >= pipeMaxBuffered
+	n = copy(b, p.buf.Bytes())
+	p.buf.Drain(n)
+	if p.buf.Len() == 0 {
+		// recover the space of the buffer
+		p.buf.Reset()
+	}
+	if full {
+		p.cond.Broadcast()
+	}
+	return n, nil
+}
+
+// close wakes up the blocked reading and dispatching, it can be called more than once
+func (p *pipe) close() {
+	p.mutex.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+}
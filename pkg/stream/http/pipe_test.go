@@ -0,0 +1,146 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+)
+
+func Test_pipe_dispatchNotRetained(t *testing.T) {
+	p := newPipe()
+	data := buffer.NewIoBufferString("GET / HTTP/1.1\r\n\r\n")
+	p.write(data)
+	if data.Len() != 0 {
+		t.Fatalf("the dispatched buffer should be drained, but %d bytes left", data.Len())
+	}
+	// the caller reuses the buffer after the dispatching
+	data.Reset()
+	data.WriteString("POST")
+
+	b := make([]byte, 64)
+	n, err := p.Read(b)
+	if err != nil || string(b[:n]) != "GET / HTTP/1.1\r\n\r\n" {
+		t.Fatalf("unexpected read: %q, %v", b[:n], err)
+	}
+}
+
+func Test_pipe_readAfterClose(t *testing.T) {
+	p := newPipe()
+	p.write(buffer.NewIoBufferString("hello"))
+	p.close()
+	// closed more than once
+	p.close()
+
+	// the data dispatched before closing is still readable
+	b := make([]byte, 3)
+	n, err := p.Read(b)
+	if err != nil || string(b[:n]) != "hel" {
+		t.Fatalf("unexpected read: %q, %v", b[:n], err)
+	}
+	n, err = p.Read(b)
+	if err != nil || string(b[:n]) != "lo" {
+		t.Fatalf("unexpected read: %q, %v", b[:n], err)
+	}
+	if _, err = p.Read(b); err != errConnClose {
+		t.Fatalf("expected %v, but got %v", errConnClose, err)
+	}
+
+	// the data dispatched after closing is dropped
+	data := buffer.NewIoBufferString("world")
+	p.write(data)
+	if data.Len() != 0 {
+		t.Fatal("the dispatched buffer should be drained")
+	}
+	if _, err = p.Read(b); err != errConnClose {
+		t.Fatalf("expected %v, but got %v", errConnClose, err)
+	}
+}
+
+func Test_pipe_wakeUpReading(t *testing.T) {
+	p := newPipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Read(make([]byte, 16))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("read should block on the empty pipe, but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.close()
+	select {
+	case err := <-done:
+		if err != errConnClose {
+			t.Fatalf("expected %v, but got %v", errConnClose, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read is not woken up by closing")
+	}
+}
+
+func Test_pipe_maxBuffered(t *testing.T) {
+	p := newPipe()
+	payload := bytes.Repeat([]byte("0123456789"), pipeMaxBuffered/4)
+	done := make(chan struct{})
+	go func() {
+		p.write(buffer.NewIoBufferBytes(payload))
+		close(done)
+	}()
+
+	// the dispatching waits until the codec reads
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("the dispatching should wait for the reading")
+	default:
+	}
+	p.mutex.Lock()
+	if buffered := p.buf.Len(); buffered > pipeMaxBuffered {
+		t.Errorf("%d bytes buffered, the limit is %d", buffered, pipeMaxBuffered)
+	}
+	p.mutex.Unlock()
+
+	got := make([]byte, len(payload))
+	if _, err := io.ReadFull(p, got); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if !bytes.Equal(got, payload) {
+		t.Fatal("the data read is not the data dispatched")
+	}
+
+	// closing wakes up the blocked dispatching
+	done = make(chan struct{})
+	go func() {
+		p.write(buffer.NewIoBufferBytes(payload))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	p.close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatching is not woken up by closing")
+	}
+}
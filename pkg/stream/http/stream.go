@@ -22,6 +22,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"strconv"
@@ -34,6 +35,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/overload"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
 	str "sofastack.io/sofa-mosn/pkg/stream"
@@ -48,14 +50,22 @@ func init() {
 
 const defaultMaxRequestBodySize = 4 * 1024 * 1024
 
+// errorLogRate is the max error logs per second of the same kind, the errors on the serve loops
+// are logged per request, which floods the disk if the upstream or the downstream is broken
+const errorLogRate = 10
+
 var (
 	errConnClose = errors.New("connection closed")
 
 	strResponseContinue = []byte("HTTP/1.1 100 Continue\r\n\r\n")
 	strErrorResponse    = []byte("HTTP/1.1 400 Bad Request\r\n\r\n")
+	strTooLargeResponse = []byte("HTTP/1.1 413 Request Entity Too Large\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+
+	strInternalErrorResponse = []byte("HTTP/1.1 500 Internal Server Error\r\nContent-Length: 0\r\n\r\n")
 
-	HKConnection = []byte("Connection") // header key 'Connection'
-	HVKeepAlive  = []byte("keep-alive") // header value 'keep-alive'
+	HKConnection  = []byte("Connection")   // header key 'Connection'
+	HVKeepAlive   = []byte("keep-alive")   // header value 'keep-alive'
+	HKMaxForwards = []byte("Max-Forwards") // header key 'Max-Forwards'
 
 	minMethodLengh = len("GET")
 	maxMethodLengh = len("CONNECT")
@@ -120,19 +130,17 @@ type streamConnection struct {
 	connEventListener types.ConnectionEventListener
 	resetReason       types.StreamResetReason
 
-	bufChan    chan types.IoBuffer
+	// in hands over the data read by the connection to the codec goroutine
+	in         *pipe
 	connClosed chan bool
 
+	// br is acquired from the pool when the connection is created, and released after the connection is served
 	br *bufio.Reader
-	bw *bufio.Writer
 }
 
 // types.StreamConnection
 func (conn *streamConnection) Dispatch(buffer types.IoBuffer) {
-	for buffer.Len() > 0 {
-		conn.bufChan <- buffer
-		<-conn.bufChan
-	}
+	conn.in.write(buffer)
 }
 
 func (conn *streamConnection) Protocol() types.Protocol {
@@ -142,29 +150,41 @@ func (conn *streamConnection) Protocol() types.Protocol {
 func (conn *streamConnection) GoAway() {}
 
 func (conn *streamConnection) Read(p []byte) (n int, err error) {
-	data, ok := <-conn.bufChan
+	return conn.in.Read(p)
+}
 
-	// Connection close
-	if !ok {
-		err = errConnClose
-		return
-	}
+// Write hands over the bytes written by the body stream to the connection, see writeMessage
+func (conn *streamConnection) Write(p []byte) (n int, err error) {
+	buf := buffer.GetIoBuffer(len(p))
+	buf.Write(p)
 
-	n = copy(p, data.Bytes())
-	data.Drain(n)
-	conn.bufChan <- nil
-	return
+	if err = conn.conn.Write(buf); err != nil {
+		// nothing is written to the connection
+		return 0, err
+	}
+	return len(p), nil
 }
 
-func (conn *streamConnection) Write(p []byte) (n int, err error) {
-	n = len(p)
+// writeResult classifies the result of writing a message to the connection
+type writeResult int
 
-	// TODO avoid copy
-	buf := buffer.GetIoBuffer(n)
-	buf.Write(p)
+const (
+	writeSucceeded writeResult = iota
+	// nothing reached the wire, the connection is still usable
+	writeNothingSent
+	// part of the message reached the wire, the framing of the connection is broken
+	writePartialSent
+)
 
-	err = conn.conn.Write(buf)
-	return
+// classifyWrite classifies the result by the bytes written before the error
+func classifyWrite(n int64, err error) writeResult {
+	if err == nil {
+		return writeSucceeded
+	}
+	if n == 0 {
+		return writeNothingSent
+	}
+	return writePartialSent
 }
 
 // types.ClientStreamConnection
@@ -176,6 +196,11 @@ type clientStreamConnection struct {
 	mutex                         sync.RWMutex
 	connectionEventListener       types.ConnectionEventListener
 	streamConnectionEventListener types.StreamConnectionEventListener
+
+	// the stream whose response is being read and whether the response is read completely,
+	// they are only accessed by the serve goroutine
+	serving      *clientStream
+	responseRead bool
 }
 
 func newClientStreamConnection(ctx context.Context, connection types.ClientConnection,
@@ -186,7 +211,7 @@ func newClientStreamConnection(ctx context.Context, connection types.ClientConne
 		streamConnection: streamConnection{
 			context:    ctx,
 			conn:       connection,
-			bufChan:    make(chan types.IoBuffer),
+			in:         newPipe(),
 			connClosed: make(chan bool, 1),
 		},
 		connectionEventListener:       connCallbacks,
@@ -194,8 +219,10 @@ func newClientStreamConnection(ctx context.Context, connection types.ClientConne
 		requestSent:                   make(chan bool, 1),
 	}
 
-	csc.br = bufio.NewReader(csc)
-	csc.bw = bufio.NewWriter(csc)
+	csc.br = acquireReader(csc)
+
+	// the stream is notified when the write buffer of the connection is backed up
+	connection.AddConnectionEventListener(csc)
 
 	utils.GoWithRecover(func() {
 		csc.serve()
@@ -204,52 +231,88 @@ func newClientStreamConnection(ctx context.Context, connection types.ClientConne
 	return csc
 }
 
+// OnEvent notifies the stream of the write buffer watermarks, the other events are handled by the connection pool
+func (conn *clientStreamConnection) OnEvent(event types.ConnectionEvent) {
+	if event != types.OnAboveWriteBufferHighWatermark && event != types.OnBelowWriteBufferLowWatermark {
+		return
+	}
+	conn.mutex.RLock()
+	s := conn.stream
+	conn.mutex.RUnlock()
+	if s == nil {
+		return
+	}
+	if event == types.OnAboveWriteBufferHighWatermark {
+		s.AboveWriteBufferHighWatermark()
+	} else {
+		s.BelowWriteBufferLowWatermark()
+	}
+}
+
 func (conn *clientStreamConnection) serve() {
-	for {
-		select {
-		case <-conn.requestSent:
-		case <-conn.connClosed:
-			return
-		}
+	defer releaseReader(conn.br)
+	if !str.ServeLoop(conn.context, protocol.HTTP1, conn.readResponse, conn.recoverServe) {
+		conn.conn.Close(types.NoFlush, types.LocalClose)
+	}
+}
 
-		s := conn.stream
-		buffers := httpBuffersByContext(s.ctx)
-		s.response = &buffers.clientResponse
-
-		// 1. blocking read using fasthttp.Response.Read
-		err := s.response.Read(conn.br)
-		if err != nil {
-			if s != nil {
-				log.Proxy.Errorf(s.connection.context, "[stream] [http] client stream connection wait response error: %s", err)
-				reason := conn.resetReason
-				if reason == "" {
-					reason = types.StreamRemoteReset
-				}
-				s.ResetStream(reason)
-			}
-			return
-		}
+// recoverServe resets the stream broken by the panic, the connection is served on only if its response is read completely
+func (conn *clientStreamConnection) recoverServe() bool {
+	if s := conn.serving; s != nil {
+		conn.serving = nil
+		s.ResetStream(types.StreamLocalReset)
+	}
+	return conn.responseRead
+}
 
-		if log.Proxy.GetLogLevel() >= log.INFO {
-			log.Proxy.Infof(s.stream.ctx, "[stream] [http] receive response, requestId = %v", s.stream.id)
-		}
+// readResponse reads the response of the request sent, it returns false if the connection should not be served any more
+func (conn *clientStreamConnection) readResponse() bool {
+	select {
+	case <-conn.requestSent:
+	case <-conn.connClosed:
+		return false
+	}
 
-		// 2. response processing
-		resetConn := false
-		if s.response.ConnectionClose() {
-			resetConn = true
-		}
+	s := conn.stream
+	conn.serving = s
+	conn.responseRead = false
+	buffers := httpBuffersByContext(s.ctx)
+	s.response = &buffers.clientResponse
 
-		// 3. local reset if header 'Connection: close' exists
-		if resetConn {
-			// goaway the connpool
-			s.connection.streamConnectionEventListener.OnGoAway()
+	// 1. blocking read using fasthttp.Response.Read
+	err := s.response.Read(conn.br)
+	if err != nil {
+		if s != nil {
+			log.ByContext(s.stream.ctx).ErrorfThrottled("stream.http.client.read", errorLogRate, "[stream] [http] client stream connection wait response error: %s", err)
+			reason := conn.resetReason
+			if reason == "" {
+				reason = types.StreamRemoteReset
+			}
+			s.ResetStream(reason)
 		}
+		return false
+	}
+	conn.responseRead = true
 
-		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
-			s.handleResponse()
-		}
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(s.stream.ctx, "[stream] [http] receive response, requestId = %v", s.stream.id)
+	}
+
+	// 2. response processing
+	resetConn := false
+	if s.response.ConnectionClose() {
+		resetConn = true
 	}
+
+	// 3. local reset if header 'Connection: close' exists
+	if resetConn {
+		// goaway the connpool
+		s.connection.streamConnectionEventListener.OnGoAway()
+	}
+
+	s.handleResponse()
+	conn.serving = nil
+	return true
 }
 
 func (conn *clientStreamConnection) GoAway() {}
@@ -258,9 +321,13 @@ func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver type
 	id := protocol.GenerateID()
 	buffers := httpBuffersByContext(ctx)
 	s := &buffers.clientStream
+	// the client stream shares the buffer context with the downstream, so it takes its own context and values
+	ctx, values := protocol.WithStreamValues(mosnctx.Clone(ctx))
+	values.StreamID = id
+	values.StartTime = time.Now()
 	s.stream = stream{
 		id:       id,
-		ctx:      mosnctx.WithValue(ctx, types.ContextKeyStreamID, id),
+		ctx:      ctx,
 		request:  &buffers.clientRequest,
 		receiver: receiver,
 	}
@@ -284,7 +351,7 @@ func (conn *clientStreamConnection) ActiveStreamsNum() int {
 }
 
 func (conn *clientStreamConnection) Reset(reason types.StreamResetReason) {
-	close(conn.bufChan)
+	conn.in.close()
 	close(conn.connClosed)
 	conn.resetReason = reason
 }
@@ -294,7 +361,9 @@ type serverStreamConnection struct {
 	streamConnection
 	contextManager *str.ContextManager
 
-	close bool
+	// close is 1 if the connection is closed after the response, it is set by the goaway
+	// and the connection transfer out of the serve goroutine, accessed atomically
+	close uint32
 
 	stream                   *serverStream
 	mutex                    sync.RWMutex
@@ -307,7 +376,7 @@ func newServerStreamConnection(ctx context.Context, connection types.Connection,
 		streamConnection: streamConnection{
 			context:    ctx,
 			conn:       connection,
-			bufChan:    make(chan types.IoBuffer),
+			in:         newPipe(),
 			connClosed: make(chan bool, 1),
 		},
 		contextManager:           str.NewContextManager(ctx),
@@ -317,15 +386,14 @@ func newServerStreamConnection(ctx context.Context, connection types.Connection,
 	// init first context
 	ssc.contextManager.Next()
 
-	ssc.br = bufio.NewReader(ssc)
-	ssc.bw = bufio.NewWriter(ssc)
+	ssc.br = acquireReader(ssc)
 
 	// Reset would not be called in server-side scene, so add listener for connection event
 	connection.AddConnectionEventListener(ssc)
 
 	// set not support transfer connection
 	ssc.conn.SetTransferEventListener(func() bool {
-		ssc.close = true
+		ssc.setClose()
 		return false
 	})
 
@@ -338,93 +406,244 @@ func newServerStreamConnection(ctx context.Context, connection types.Connection,
 
 func (conn *serverStreamConnection) OnEvent(event types.ConnectionEvent) {
 	if event.IsClose() {
-		close(conn.bufChan)
+		conn.in.close()
 		close(conn.connClosed)
 	}
 }
 
 func (conn *serverStreamConnection) serve() {
-	for {
-		// 1. pre alloc stream-level ctx with bufferCtx
-		ctx := conn.contextManager.Get()
-		buffers := httpBuffersByContext(ctx)
-		request := &buffers.serverRequest
-
-		// 2. blocking read using fasthttp.Request.Read
-		err := request.ReadLimitBody(conn.br, defaultMaxRequestBodySize)
-		if err == nil {
-			// 3. 'Expect: 100-continue' request handling.
-			// See http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html for details.
-			if request.MayContinue() {
-				// Send 'HTTP/1.1 100 Continue' response.
-				conn.conn.Write(buffer.NewIoBufferBytes(strResponseContinue))
-
-				// read request body
-				err = request.ContinueReadBody(conn.br, defaultMaxRequestBodySize)
-
-				// remove 'Expect' header, so it would not be sent to the upstream
-				request.Header.Del("Expect")
-			}
+	defer releaseReader(conn.br)
+	if !str.ServeLoop(conn.context, protocol.HTTP1, conn.serveRequest, conn.recoverServe) {
+		conn.conn.Close(types.NoFlush, types.LocalClose)
+	}
+}
+
+// recoverServe resets the stream broken by the panic. The response of the request may be lost or written
+// partially, the client can not tell the next response from it, so the connection is not served on
+func (conn *serverStreamConnection) recoverServe() bool {
+	conn.mutex.Lock()
+	s := conn.stream
+	conn.stream = nil
+	conn.mutex.Unlock()
+	if s != nil {
+		s.ResetStream(types.StreamLocalReset)
+	}
+	return false
+}
+
+// serveRequest serves a request of the connection, it returns false if the connection should not be served any more
+func (conn *serverStreamConnection) serveRequest() bool {
+	// 1. pre alloc stream-level ctx with bufferCtx
+	ctx := conn.contextManager.Get()
+	buffers := httpBuffersByContext(ctx)
+	request := &buffers.serverRequest
+
+	// 2. blocking read the request header using fasthttp.RequestHeader.Read
+	request.Reset()
+	err := request.Header.Read(conn.br)
+	if err == nil {
+		// a declared Content-Length over the limit is rejected before the body is read
+		limit := conn.maxRequestBodySize(request)
+		if request.Header.ContentLength() > limit {
+			conn.rejectTooLarge(true)
+			return false
 		}
-		if err != nil {
-			// "read timeout with nothing read" is the error of returned by fasthttp v1.2.0
-			// if connection closed with nothing read.
-			if err != errConnClose && err != io.EOF && err.Error() != "read timeout with nothing read" {
-				// write error response
-				conn.conn.Write(buffer.NewIoBufferBytes(strErrorResponse))
-
-				// close connection with flush
-				conn.conn.Close(types.FlushWrite, types.LocalClose)
-			}
-			return
+
+		// 3. 'Expect: 100-continue' request handling.
+		// See http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html for details.
+		mayContinue := request.MayContinue()
+		if mayContinue {
+			// Send 'HTTP/1.1 100 Continue' response.
+			conn.conn.Write(buffer.NewIoBufferBytes(strResponseContinue))
 		}
 
-		id := protocol.GenerateID()
-		s := &buffers.serverStream
+		// read request body, a chunked body is limited while it is read
+		err = request.ContinueReadBody(conn.br, limit)
+		if err == fasthttp.ErrBodyTooLarge {
+			conn.rejectTooLarge(false)
+			return false
+		}
 
-		// 4. request processing
-		s.stream = stream{
-			id:       id,
-			ctx:      context.WithValue(ctx, types.ContextKeyStreamID, id),
-			request:  request,
-			response: &buffers.serverResponse,
+		if mayContinue {
+			// remove 'Expect' header, so it would not be sent to the upstream
+			request.Header.Del("Expect")
 		}
-		s.connection = conn
-		s.responseDoneChan = make(chan bool, 1)
-		s.header = mosnhttp.RequestHeader{&s.request.Header, nil}
-
-		var span types.Span
-		if trace.IsEnabled() {
-			tracer := trace.Tracer(protocol.HTTP1)
-			if tracer != nil {
-				span = tracer.Start(ctx, s.header, time.Now())
-			}
+	}
+	if err != nil {
+		// "read timeout with nothing read" is the error of returned by fasthttp v1.2.0
+		// if connection closed with nothing read.
+		if err != errConnClose && err != io.EOF && err.Error() != "read timeout with nothing read" {
+			// write error response
+			conn.conn.Write(buffer.NewIoBufferBytes(strErrorResponse))
+
+			// close connection with flush
+			conn.conn.Close(types.FlushWrite, types.LocalClose)
 		}
-		s.stream.ctx = s.connection.contextManager.InjectTrace(ctx, span)
+		return false
+	}
 
-		if log.Proxy.GetLogLevel() >= log.INFO {
-			log.Proxy.Infof(s.stream.ctx, "[stream] [http] new stream detect, requestId = %v", s.stream.id)
+	// 4. the request is answered with 503 while mosn is overloaded, it is not proxied
+	if overload.Engaged(overload.RejectStreams) {
+		if !conn.replyOverloaded(request, &buffers.serverResponse) {
+			return false
 		}
+		return true
+	}
 
-		s.receiver = conn.serverStreamConnListener.NewStreamDetect(s.stream.ctx, s, span)
+	// count the hops, the request can not be forwarded any more is replied here
+	if !increaseHops(&request.Header) {
+		if !conn.replyFinalRecipient(request, &buffers.serverResponse) {
+			return false
+		}
+		return true
+	}
 
-		conn.mutex.Lock()
-		conn.stream = s
-		conn.mutex.Unlock()
+	id := protocol.GenerateID()
+	s := &buffers.serverStream
+	ctx, values := protocol.WithStreamValues(ctx)
+	values.StreamID = id
+	values.StartTime = time.Now()
 
-		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
-			s.handleRequest()
+	// 5. request processing
+	s.stream = stream{
+		id:       id,
+		ctx:      ctx,
+		request:  request,
+		response: &buffers.serverResponse,
+	}
+	s.connection = conn
+	s.responseDoneChan = make(chan bool, 1)
+	s.requestTyped.Reset()
+	s.header = mosnhttp.RequestHeader{RequestHeader: &s.request.Header, Typed: &s.requestTyped}
+
+	var span types.Span
+	if trace.IsEnabled() {
+		tracer := trace.Tracer(protocol.HTTP1)
+		if tracer != nil {
+			span = tracer.Start(ctx, s.header, values.StartTime)
 		}
+	}
+	s.stream.ctx = s.connection.contextManager.InjectTrace(s.stream.ctx, span)
+	// the logger is stored after the trace id, its lines have the trace id too
+	s.stream.ctx = log.ContextWithLogger(s.stream.ctx, id)
 
-		// 5. wait for proxy done
-		select {
-		case <-s.responseDoneChan:
-		case <-conn.connClosed:
-			return
-		}
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(s.stream.ctx, "[stream] [http] new stream detect, requestId = %v", s.stream.id)
+	}
+
+	s.receiver = conn.serverStreamConnListener.NewStreamDetect(s.stream.ctx, s, span)
 
-		conn.contextManager.Next()
+	conn.mutex.Lock()
+	conn.stream = s
+	conn.mutex.Unlock()
+
+	s.handleRequest()
+
+	// 6. wait for proxy done
+	select {
+	case <-s.responseDoneChan:
+	case <-conn.connClosed:
+		return false
+	}
+
+	conn.contextManager.Next()
+	return true
+}
+
+// maxRequestBodySize returns the request body limit, a route can only make the
+// listener-wide limit more restrictive.
+// The body is read completely before the request is forwarded, so a body exceeds the
+// limit is always rejected before anything is sent to the upstream.
+func (conn *serverStreamConnection) maxRequestBodySize(request *fasthttp.Request) int {
+	limit := defaultMaxRequestBodySize
+	// only requests with a body are matched, -1 means a chunked body
+	if cl := request.Header.ContentLength(); cl <= 0 && cl != -1 {
+		return limit
+	}
+	limiter, ok := conn.serverStreamConnListener.(types.RequestBodyLimiter)
+	if !ok {
+		return limit
+	}
+	// the route is matched by the internal headers, as the proxy does
+	header := mosnhttp.RequestHeader{RequestHeader: &request.Header, Typed: &protocol.TypedHeader{}}
+	injectInternalHeaders(header, request.URI())
+	if max := limiter.MaxRequestBytes(header); max > 0 && max < uint64(limit) {
+		limit = int(max)
+	}
+	return limit
+}
+
+// rejectTooLarge replies 413 and closes the connection, as the rest of the body
+// is not read
+func (conn *serverStreamConnection) rejectTooLarge(declared bool) {
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(conn.context, "[stream] [http] request body too large, declared: %v", declared)
+	}
+	if limiter, ok := conn.serverStreamConnListener.(types.RequestBodyLimiter); ok {
+		limiter.OnRequestBodyTooLarge(declared)
+	}
+	conn.conn.Write(buffer.NewIoBufferBytes(strTooLargeResponse))
+	conn.conn.Close(types.FlushWrite, types.LocalClose)
+}
+
+// replyFinalRecipient answers the request as its final recipient, TRACE requests are
+// echoed back. It returns false if the connection is closed.
+func (conn *serverStreamConnection) replyFinalRecipient(request *fasthttp.Request, response *fasthttp.Response) bool {
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(conn.context, "[stream] [http] max forwards reached, reply %s request locally", request.Header.Method())
+	}
+	response.SetStatusCode(fasthttp.StatusOK)
+	if request.Header.IsTrace() {
+		// the credentials are not echoed back
+		header := &fasthttp.RequestHeader{}
+		request.Header.CopyTo(header)
+		header.Del("Authorization")
+		header.Del("Proxy-Authorization")
+		header.DelAllCookies()
+		response.Header.SetContentType("message/http")
+		response.SetBody(header.Header())
+	}
+	return conn.writeLocalReply(request, response)
+}
+
+// replyOverloaded answers the request with 503 while mosn is overloaded.
+// It returns false if the connection is closed.
+func (conn *serverStreamConnection) replyOverloaded(request *fasthttp.Request, response *fasthttp.Response) bool {
+	overload.OnRejected(overload.RejectStreams)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(conn.context, "[stream] [http] mosn is overloaded, reply %s %s locally", request.Header.Method(), request.Header.RequestURI())
+	}
+	response.SetStatusCode(fasthttp.StatusServiceUnavailable)
+	return conn.writeLocalReply(request, response)
+}
+
+// writeLocalReply writes the response replied by mosn itself, it returns false if the connection is closed
+func (conn *serverStreamConnection) writeLocalReply(request *fasthttp.Request, response *fasthttp.Response) bool {
+	closeConn := conn.closing() || request.Header.ConnectionClose()
+	if closeConn {
+		response.SetConnectionClose()
+	}
+	_, err := conn.writeMessage(response)
+	response.Reset()
+	if err != nil || closeConn {
+		conn.conn.Close(types.FlushWrite, types.LocalClose)
+		return false
 	}
+	return true
+}
+
+// GoAway makes the next response close the connection
+func (conn *serverStreamConnection) GoAway() {
+	conn.setClose()
+}
+
+func (conn *serverStreamConnection) setClose() {
+	atomic.StoreUint32(&conn.close, 1)
+}
+
+// closing returns whether the connection is closed after the response
+func (conn *serverStreamConnection) closing() bool {
+	return atomic.LoadUint32(&conn.close) == 1
 }
 
 func (conn *serverStreamConnection) ActiveStreamsNum() int {
@@ -439,7 +658,7 @@ func (conn *serverStreamConnection) ActiveStreamsNum() int {
 }
 
 func (conn *serverStreamConnection) Reset(reason types.StreamResetReason) {
-	close(conn.bufChan)
+	conn.in.close()
 }
 
 // types.Stream
@@ -447,9 +666,8 @@ func (conn *serverStreamConnection) Reset(reason types.StreamResetReason) {
 type stream struct {
 	str.BaseStream
 
-	id               uint64
-	readDisableCount int32
-	ctx              context.Context
+	id  uint64
+	ctx context.Context
 
 	// NOTICE: fasthttp ctx and its member not allowed holding by others after request handle finished
 	request  *fasthttp.Request
@@ -467,13 +685,22 @@ func (s *stream) ID() uint64 {
 type clientStream struct {
 	stream
 
-	connection *clientStreamConnection
+	// requestTyped is the copy of the typed headers of the request, the headers of the request are kept intact for retry
+	requestTyped  protocol.TypedHeader
+	responseTyped protocol.TypedHeader
+	connection    *clientStreamConnection
 }
 
 // types.StreamSender
 func (s *clientStream) AppendHeaders(context context.Context, headersIn types.HeaderMap, endStream bool) error {
-	// clone for retry case
-	headers := headersIn.Clone().(mosnhttp.RequestHeader)
+	// the headers are copied into the request instead of modified for retry case
+	in := headersIn.(mosnhttp.RequestHeader)
+	in.CopyTo(&s.request.Header)
+	headers := mosnhttp.RequestHeader{RequestHeader: &s.request.Header}
+	if in.Typed != nil {
+		s.requestTyped = *in.Typed
+		headers.Typed = &s.requestTyped
+	}
 
 	// TODO: protocol convert in pkg/protocol
 	//if the request contains body, use "POST" as default, the http request method will be setted by MosnHeaderMethod
@@ -485,8 +712,12 @@ func (s *clientStream) AppendHeaders(context context.Context, headersIn types.He
 
 	removeInternalHeaders(headers, s.connection.conn.RemoteAddr())
 
-	// copy headers
-	headers.CopyTo(&s.request.Header)
+	// propagate the trace context to the upstream
+	if trace.IsEnabled() {
+		if span := trace.SpanFromContext(context); span != nil {
+			span.InjectContext(headers)
+		}
+	}
 
 	if endStream {
 		s.endStream()
@@ -511,14 +742,20 @@ func (s *clientStream) AppendTrailers(context context.Context, trailers types.He
 }
 
 func (s *clientStream) endStream() {
-	err := s.doSend()
+	n, err := s.doSend()
 
 	if err != nil {
-		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send client request error: %+v", err)
-
-		if err == types.ErrConnectionHasClosed {
-			s.ResetStream(types.StreamConnectionFailed)
-		} else {
+		switch classifyWrite(n, err) {
+		case writeNothingSent:
+			log.ByContext(s.stream.ctx).ErrorfThrottled("stream.http.client.write", errorLogRate, "[stream] [http] send client request error: %+v", err)
+			if err == types.ErrConnectionHasClosed {
+				s.ResetStream(types.StreamConnectionFailed)
+			} else {
+				s.ResetStream(types.StreamLocalReset)
+			}
+		case writePartialSent:
+			// the connection will not be reused after the local reset
+			log.ByContext(s.stream.ctx).ErrorfThrottled("stream.http.client.write_partial", errorLogRate, "[stream] [http] send client request error after %d bytes written: %+v", n, err)
 			s.ResetStream(types.StreamLocalReset)
 		}
 		return
@@ -530,31 +767,29 @@ func (s *clientStream) endStream() {
 	s.connection.requestSent <- true
 }
 
+// ReadDisable stops reading the upstream connection, the response being read waits for the data in the pipe
 func (s *clientStream) ReadDisable(disable bool) {
-	if disable {
-		atomic.AddInt32(&s.readDisableCount, 1)
-	} else {
-		newCount := atomic.AddInt32(&s.readDisableCount, -1)
-
-		if newCount <= 0 {
-			s.handleResponse()
-		}
-	}
+	s.connection.conn.SetReadDisable(disable)
 }
 
-func (s *clientStream) doSend() (err error) {
-	_, err = s.request.WriteTo(s.connection)
-	return
+func (s *clientStream) doSend() (int64, error) {
+	if log.PayloadDumpEnabled(s.stream.ctx) {
+		log.DumpPayload(s.stream.ctx, "[stream] [http] send client request", requestLine(&s.request.Header),
+			mosnhttp.RequestHeader{RequestHeader: &s.request.Header}, s.request.Body())
+	}
+	return s.connection.writeMessage(s.request)
 }
 
 func (s *clientStream) handleResponse() {
 	if s.response != nil {
-		header := mosnhttp.ResponseHeader{&s.response.Header, nil}
+		s.responseTyped.Reset()
+		header := mosnhttp.ResponseHeader{ResponseHeader: &s.response.Header, Typed: &s.responseTyped}
+		if log.PayloadDumpEnabled(s.stream.ctx) {
+			log.DumpPayload(s.stream.ctx, "[stream] [http] receive client response", statusLine(&s.response.Header), header, s.response.Body())
+		}
 
-		statusCode := header.StatusCode()
-		status := strconv.Itoa(statusCode)
 		// inherit upstream's response status
-		header.Set(types.HeaderStatus, status)
+		s.responseTyped.SetValue(protocol.TypedStatus, strconv.Itoa(header.StatusCode()))
 
 		hasData := true
 		if len(s.response.Body()) == 0 {
@@ -586,6 +821,7 @@ type serverStream struct {
 	stream
 
 	header           mosnhttp.RequestHeader
+	requestTyped     protocol.TypedHeader
 	connection       *serverStreamConnection
 	responseDoneChan chan bool
 }
@@ -618,9 +854,10 @@ func (s *serverStream) AppendHeaders(context context.Context, headersIn types.He
 
 		headers.CopyTo(&s.response.Header)
 	}
+	str.RecordResponseCode(s.connection.context, s.response.StatusCode())
 
 	if endStream {
-		s.endStream()
+		return s.endStream()
 	}
 
 	return nil
@@ -630,21 +867,20 @@ func (s *serverStream) AppendData(context context.Context, data types.IoBuffer,
 	s.response.SetBody(data.Bytes())
 
 	if endStream {
-		s.endStream()
+		return s.endStream()
 	}
 
 	return nil
 }
 
 func (s *serverStream) AppendTrailers(context context.Context, trailers types.HeaderMap) error {
-	s.endStream()
-	return nil
+	return s.endStream()
 }
 
-func (s *serverStream) endStream() {
+func (s *serverStream) endStream() error {
 	resetConn := false
 	// check if we need close connection
-	if s.connection.close || s.request.Header.ConnectionClose() {
+	if s.connection.closing() || s.request.Header.ConnectionClose() {
 		s.response.SetConnectionClose()
 		resetConn = true
 	} else if !s.request.Header.IsHTTP11() {
@@ -655,7 +891,7 @@ func (s *serverStream) endStream() {
 	}
 	defer s.DestroyStream()
 
-	s.doSend()
+	err := s.doSend()
 	s.responseDoneChan <- true
 
 	if resetConn {
@@ -667,32 +903,46 @@ func (s *serverStream) endStream() {
 	s.connection.mutex.Lock()
 	s.connection.stream = nil
 	s.connection.mutex.Unlock()
+
+	return err
 }
 
+// ReadDisable stops reading the downstream connection, the request being read waits for the data in the pipe
 func (s *serverStream) ReadDisable(disable bool) {
-	if disable {
-		atomic.AddInt32(&s.readDisableCount, 1)
-	} else {
-		newCount := atomic.AddInt32(&s.readDisableCount, -1)
-
-		if newCount <= 0 {
-			s.handleRequest()
-		}
-	}
+	s.connection.conn.SetReadDisable(disable)
 }
 
-func (s *serverStream) doSend() {
-	if _, err := s.response.WriteTo(s.connection); err != nil {
-		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send server response error: %+v", err)
-	} else {
+func (s *serverStream) doSend() error {
+	if log.PayloadDumpEnabled(s.stream.ctx) {
+		log.DumpPayload(s.stream.ctx, "[stream] [http] send server response", statusLine(&s.response.Header),
+			mosnhttp.ResponseHeader{ResponseHeader: &s.response.Header}, s.response.Body())
+	}
+	n, err := s.connection.writeMessage(s.response)
+	switch classifyWrite(n, err) {
+	case writeSucceeded:
 		if log.Proxy.GetLogLevel() >= log.INFO {
 			log.Proxy.Infof(s.stream.ctx, "[stream] [http] send server response, requestId = %v", s.stream.id)
 		}
+		return nil
+	case writeNothingSent:
+		// nothing reached the downstream, reply 500 instead
+		log.ByContext(s.stream.ctx).ErrorfThrottled("stream.http.server.write", errorLogRate, "[stream] [http] send server response error: %+v, reply 500 instead", err)
+		if err := s.connection.conn.Write(buffer.NewIoBufferBytes(strInternalErrorResponse)); err != nil {
+			s.connection.conn.Close(types.NoFlush, types.LocalClose)
+		}
+	case writePartialSent:
+		// the downstream has received part of the response, the connection can not be reused
+		log.ByContext(s.stream.ctx).ErrorfThrottled("stream.http.server.write_partial", errorLogRate, "[stream] [http] send server response error after %d bytes written: %+v, close the connection", n, err)
+		s.connection.conn.Close(types.NoFlush, types.LocalClose)
 	}
+	return types.ErrWriteResponse
 }
 
 func (s *serverStream) handleRequest() {
 	if s.request != nil {
+		if log.PayloadDumpEnabled(s.stream.ctx) {
+			log.DumpPayload(s.stream.ctx, "[stream] [http] receive server request", requestLine(&s.request.Header), s.header, s.request.Body())
+		}
 		// set non-header info in request-line, like method, uri
 		injectInternalHeaders(s.header, s.request.URI())
 
@@ -713,12 +963,40 @@ func (s *serverStream) GetStream() types.Stream {
 	return s
 }
 
+// increaseHops increases the proxy hops of the request. TRACE and OPTIONS requests
+// with Max-Forwards are limited by it as RFC 7231 section 5.1.2, false is returned
+// if such a request reaches its final recipient.
+func increaseHops(header *fasthttp.RequestHeader) bool {
+	if header.IsTrace() || header.IsOptions() {
+		if value := header.PeekBytes(HKMaxForwards); len(value) > 0 {
+			if forwards, err := strconv.Atoi(string(value)); err == nil && forwards >= 0 {
+				if forwards == 0 {
+					return false
+				}
+				header.SetBytesK(HKMaxForwards, strconv.Itoa(forwards-1))
+			}
+		}
+	}
+	var hops uint64 = 1
+	if value := header.Peek(types.HeaderHops); len(value) > 0 {
+		// an overflowing or unparsable hop count is exhausted, the loop detection rejects it
+		if n, err := strconv.ParseUint(string(value), 10, 32); err == nil && n < math.MaxUint32 {
+			hops = n + 1
+		} else {
+			hops = math.MaxUint32
+		}
+	}
+	header.Set(types.HeaderHops, strconv.FormatUint(hops, 10))
+	return true
+}
+
 // consider host, method, path are necessary, but check querystring
 func injectInternalHeaders(headers mosnhttp.RequestHeader, uri *fasthttp.URI) {
+	host := string(uri.Host())
 	// 1. host
-	headers.Set(protocol.MosnHeaderHostKey, string(uri.Host()))
+	headers.Set(protocol.MosnHeaderHostKey, host)
 	// 2. :authority
-	headers.Set(protocol.IstioHeaderHostKey, string(uri.Host()))
+	headers.Set(protocol.IstioHeaderHostKey, host)
 	// 3. method
 	headers.Set(protocol.MosnHeaderMethod, string(headers.Method()))
 	// 4. path
@@ -727,47 +1005,70 @@ func injectInternalHeaders(headers mosnhttp.RequestHeader, uri *fasthttp.URI) {
 	qs := uri.QueryString()
 	if len(qs) > 0 {
 		headers.Set(protocol.MosnHeaderQueryStringKey, string(qs))
+	} else {
+		// the query string header sent by the client is not taken as the query string
+		headers.Del(protocol.MosnHeaderQueryStringKey)
 	}
 }
 
-func removeInternalHeaders(headers mosnhttp.RequestHeader, remoteAddr net.Addr) {
-	// assemble uri
-	uri := ""
-
-	// path
-	if path, ok := headers.Get(protocol.MosnHeaderPathKey); ok && path != "" {
-		headers.Del(protocol.MosnHeaderPathKey)
-		uri += path
-	} else {
-		uri += "/"
+// requestLine returns the request line of the header for the payload dump
+func requestLine(header *fasthttp.RequestHeader) string {
+	proto := "HTTP/1.1"
+	if !header.IsHTTP11() {
+		proto = "HTTP/1.0"
 	}
+	return string(header.Method()) + " " + string(header.RequestURI()) + " " + proto
+}
 
-	// querystring
-	queryString, ok := headers.Get(protocol.MosnHeaderQueryStringKey)
-	if ok && queryString != "" {
-		headers.Del(protocol.MosnHeaderQueryStringKey)
-		uri += "?" + queryString
+// statusLine returns the status line of the header for the payload dump
+func statusLine(header *fasthttp.ResponseHeader) string {
+	proto := "HTTP/1.1"
+	if !header.IsHTTP11() {
+		proto = "HTTP/1.0"
 	}
+	return proto + " " + strconv.Itoa(header.StatusCode())
+}
 
-	headers.SetRequestURI(uri)
+func removeInternalHeaders(headers mosnhttp.RequestHeader, remoteAddr net.Addr) {
+	// assemble uri
+	path, ok := takeInternalHeader(headers, protocol.TypedPath)
+	if !ok || path == "" {
+		path = "/"
+	}
+	if queryString, ok := takeInternalHeader(headers, protocol.TypedQueryString); ok && queryString != "" {
+		headers.SetRequestURI(path + "?" + queryString)
+	} else {
+		headers.SetRequestURI(path)
+	}
 
-	if method, ok := headers.Get(protocol.MosnHeaderMethod); ok {
-		headers.Del(protocol.MosnHeaderMethod)
+	if method, ok := takeInternalHeader(headers, protocol.TypedMethod); ok {
 		headers.SetMethod(method)
 	}
 
-	if host, ok := headers.Get(protocol.MosnHeaderHostKey); ok {
-		headers.Del(protocol.MosnHeaderHostKey)
+	if host, ok := takeInternalHeader(headers, protocol.TypedHost); ok {
 		headers.SetHost(host)
 	} else {
 		headers.SetHost(remoteAddr.String())
 	}
 
-	if host, ok := headers.Get(protocol.IstioHeaderHostKey); ok {
-		headers.Del(protocol.IstioHeaderHostKey)
+	if host, ok := takeInternalHeader(headers, protocol.TypedAuthority); ok {
 		headers.SetHost(host)
 	}
+}
 
+// takeInternalHeader returns the internal header and removes it, the typed field is used if the headers have.
+// The header of the same key in the map is removed too, it is not forwarded to the upstream
+func takeInternalHeader(headers mosnhttp.RequestHeader, key protocol.TypedKey) (string, bool) {
+	if headers.Typed != nil {
+		value, ok := headers.Typed.Value(key)
+		headers.Del(key.String())
+		return value, ok
+	}
+	value, ok := headers.Get(key.String())
+	if ok {
+		headers.Del(key.String())
+	}
+	return value, ok
 }
 
 // contextManager
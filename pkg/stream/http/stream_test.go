@@ -18,16 +18,31 @@
 package http
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"net"
+	"strconv"
 
 	"bytes"
 	"fmt"
+	gometrics "github.com/rcrowley/go-metrics"
 	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/overload"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 func Test_clientStream_AppendHeaders(t *testing.T) {
@@ -163,7 +178,7 @@ func Test_header_conflict(t *testing.T) {
 
 func Test_internal_header(t *testing.T) {
 	remoteAddr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:12200")
-	header := http.RequestHeader{&fasthttp.RequestHeader{}, nil}
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
 	uri := fasthttp.AcquireURI()
 
 	// headers.Get return
@@ -219,6 +234,86 @@ func Test_internal_header(t *testing.T) {
 	}
 }
 
+func Test_clientStream_AppendTypedHeaders(t *testing.T) {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:12200")
+	request := fasthttp.AcquireRequest()
+	request.Header.SetMethod("PUT")
+	request.SetRequestURI("http://mosn.io/typed?query=string")
+	request.Header.Set("service", "test")
+	header := http.RequestHeader{RequestHeader: &request.Header, Typed: &protocol.TypedHeader{}}
+	injectInternalHeaders(header, request.URI())
+
+	s := &clientStream{
+		stream: stream{request: fasthttp.AcquireRequest()},
+		connection: &clientStreamConnection{
+			streamConnection: streamConnection{
+				conn: network.NewClientConnection(nil, 0, nil, remoteAddr, nil),
+			},
+		},
+	}
+	// the request is sent twice as retried
+	for i := 0; i < 2; i++ {
+		s.request.Reset()
+		s.AppendHeaders(context.Background(), header, false)
+		sent := &s.request.Header
+		if string(sent.RequestURI()) != "/typed?query=string" || string(sent.Method()) != "PUT" ||
+			string(sent.Host()) != "mosn.io" || string(sent.Peek("service")) != "test" {
+			t.Fatalf("#%d unexpected request sent: %s", i, sent.String())
+		}
+		if sent.Peek(protocol.MosnHeaderPathKey) != nil || sent.Peek(protocol.MosnHeaderHostKey) != nil {
+			t.Fatalf("#%d the internal headers should not be sent: %s", i, sent.String())
+		}
+		// the headers of the downstream request are kept
+		if path, ok := header.Get(protocol.MosnHeaderPathKey); !ok || path != "/typed" {
+			t.Fatalf("#%d the typed headers of the request are changed: %s", i, path)
+		}
+	}
+}
+
+func Test_internal_header_spoofed(t *testing.T) {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:12200")
+	request := fasthttp.AcquireRequest()
+	request.Header.SetMethod("GET")
+	request.SetRequestURI("http://mosn.io/real")
+	// the internal headers sent by the client
+	request.Header.Set("x-mosn-path", "/spoofed")
+	request.Header.Set("x-mosn-querystring", "spoofed=true")
+	header := http.RequestHeader{RequestHeader: &request.Header, Typed: &protocol.TypedHeader{}}
+	injectInternalHeaders(header, request.URI())
+
+	if path, _ := header.Get(protocol.MosnHeaderPathKey); path != "/real" {
+		t.Fatalf("expected the path /real, but got %s", path)
+	}
+	var paths []string
+	header.Range(func(key, value string) bool {
+		switch strings.ToLower(key) {
+		case protocol.MosnHeaderPathKey:
+			paths = append(paths, value)
+		case protocol.MosnHeaderQueryStringKey:
+			t.Errorf("the query string sent by the client should be removed, but got %s", value)
+		}
+		return true
+	})
+	if len(paths) != 1 || paths[0] != "/real" {
+		t.Fatalf("expected the path /real ranged once, but got %v", paths)
+	}
+
+	s := &clientStream{
+		stream: stream{request: fasthttp.AcquireRequest()},
+		connection: &clientStreamConnection{
+			streamConnection: streamConnection{
+				conn: network.NewClientConnection(nil, 0, nil, remoteAddr, nil),
+			},
+		},
+	}
+	s.AppendHeaders(context.Background(), header, false)
+	sent := &s.request.Header
+	if string(sent.RequestURI()) != "/real" || sent.Peek(protocol.MosnHeaderPathKey) != nil ||
+		sent.Peek(protocol.MosnHeaderQueryStringKey) != nil {
+		t.Fatalf("unexpected request sent: %s", sent.String())
+	}
+}
+
 func Test_serverStream_handleRequest(t *testing.T) {
 	type fields struct {
 		stream           stream
@@ -245,7 +340,7 @@ func Test_serverStream_handleRequest(t *testing.T) {
 }
 
 func convertHeader(payload protocol.CommonHeader) http.RequestHeader {
-	header := http.RequestHeader{&fasthttp.RequestHeader{}, nil}
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
 
 	for k, v := range payload {
 		header.Set(k, v)
@@ -253,3 +348,662 @@ func convertHeader(payload protocol.CommonHeader) http.RequestHeader {
 
 	return header
 }
+
+type mockConnection struct {
+	types.Connection
+	failures  int // the first failures writes return error
+	writes    int
+	written   bytes.Buffer
+	closed    bool
+	closeType types.ConnectionCloseType
+}
+
+func (c *mockConnection) Write(buffers ...types.IoBuffer) error {
+	if c.failures > 0 {
+		c.failures--
+		return types.ErrConnectionHasClosed
+	}
+	c.writes++
+	for _, buf := range buffers {
+		c.written.Write(buf.Bytes())
+	}
+	return nil
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 12200}
+}
+
+func (c *mockConnection) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	c.closed = true
+	c.closeType = ccType
+	return nil
+}
+
+// errReader returns error after n bytes read
+type errReader struct {
+	n int
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("mock body read error")
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 'a'
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func newMockServerStream(conn types.Connection) *serverStream {
+	return &serverStream{
+		stream: stream{
+			ctx:      context.Background(),
+			request:  fasthttp.AcquireRequest(),
+			response: fasthttp.AcquireResponse(),
+		},
+		connection: &serverStreamConnection{
+			streamConnection: streamConnection{
+				context: context.Background(),
+				conn:    conn,
+			},
+		},
+		responseDoneChan: make(chan bool, 1),
+	}
+}
+
+func Test_serverStream_responseCodeStats(t *testing.T) {
+	stats := &types.ListenerStats{
+		DownstreamResponseCode2xx: gometrics.NewCounter(),
+		DownstreamResponseCode5xx: gometrics.NewCounter(),
+	}
+	conn := &mockConnection{}
+	s := newMockServerStream(conn)
+	s.connection.context = mosnctx.WithValue(context.Background(), types.ContextKeyListenerStats, stats)
+	headers := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	headers.Set(types.HeaderStatus, "503")
+	s.AppendHeaders(context.Background(), headers, false)
+	if stats.DownstreamResponseCode5xx.Count() != 1 || stats.DownstreamResponseCode2xx.Count() != 0 {
+		t.Fatalf("expected one 5xx response, but got 2xx: %d, 5xx: %d", stats.DownstreamResponseCode2xx.Count(), stats.DownstreamResponseCode5xx.Count())
+	}
+
+	// the response without status is 200
+	s = newMockServerStream(conn)
+	s.connection.context = mosnctx.WithValue(context.Background(), types.ContextKeyListenerStats, stats)
+	s.AppendHeaders(context.Background(), http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}, false)
+	if stats.DownstreamResponseCode2xx.Count() != 1 {
+		t.Fatalf("expected one 2xx response, but got %d", stats.DownstreamResponseCode2xx.Count())
+	}
+}
+
+func Test_serverStream_writePartialError(t *testing.T) {
+	conn := &mockConnection{}
+	s := newMockServerStream(conn)
+	s.response.SetBodyStream(&errReader{n: 10}, -1)
+
+	if err := s.AppendHeaders(context.Background(), http.ResponseHeader{ResponseHeader: &s.response.Header}, true); err != types.ErrWriteResponse {
+		t.Fatalf("expected write response error, but got %v", err)
+	}
+	if conn.written.Len() == 0 {
+		t.Fatal("part of the response should be written")
+	}
+	if !conn.closed || conn.closeType != types.NoFlush {
+		t.Fatal("connection should be closed immediately")
+	}
+	if len(s.responseDoneChan) != 1 {
+		t.Fatal("response done should be notified once")
+	}
+}
+
+func Test_serverStream_writeNothingError(t *testing.T) {
+	conn := &mockConnection{
+		failures: 1,
+	}
+	s := newMockServerStream(conn)
+	s.response.SetBodyString("hello")
+
+	if err := s.AppendHeaders(context.Background(), http.ResponseHeader{ResponseHeader: &s.response.Header}, true); err != types.ErrWriteResponse {
+		t.Fatalf("expected write response error, but got %v", err)
+	}
+	if !bytes.Equal(conn.written.Bytes(), strInternalErrorResponse) {
+		t.Fatalf("expected 500 reply, but got %q", conn.written.String())
+	}
+	if conn.closed {
+		t.Fatal("connection should be reused after 500 reply")
+	}
+	if len(s.responseDoneChan) != 1 {
+		t.Fatal("response done should be notified once")
+	}
+
+	// the 500 reply failed too
+	conn = &mockConnection{
+		failures: 2,
+	}
+	s = newMockServerStream(conn)
+	s.AppendHeaders(context.Background(), http.ResponseHeader{ResponseHeader: &s.response.Header}, true)
+	if !conn.closed {
+		t.Fatal("connection should be closed if 500 reply failed")
+	}
+}
+
+func Test_serverStream_writeOnce(t *testing.T) {
+	conn := &mockConnection{}
+	s := newMockServerStream(conn)
+	body := bytes.Repeat([]byte("a"), 64*1024)
+	s.response.SetBody(body)
+
+	if err := s.AppendHeaders(context.Background(), http.ResponseHeader{ResponseHeader: &s.response.Header}, true); err != nil {
+		t.Fatal(err)
+	}
+	if conn.writes != 1 {
+		t.Fatalf("expected the response written once, but got %d writes", conn.writes)
+	}
+	resp := &fasthttp.Response{}
+	if err := resp.Read(bufio.NewReader(&conn.written)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp.Body(), body) {
+		t.Fatalf("unexpected response body of %d bytes", len(resp.Body()))
+	}
+}
+
+type resetRecorder struct {
+	reasons []types.StreamResetReason
+}
+
+func (r *resetRecorder) OnResetStream(reason types.StreamResetReason) {
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *resetRecorder) OnDestroyStream() {}
+
+func Test_clientStream_writeConnectionClosed(t *testing.T) {
+	conn := &mockConnection{
+		failures: 1,
+	}
+	s := &clientStream{
+		stream: stream{
+			ctx:     context.Background(),
+			request: fasthttp.AcquireRequest(),
+		},
+		connection: &clientStreamConnection{
+			streamConnection: streamConnection{
+				context: context.Background(),
+				conn:    conn,
+			},
+			requestSent: make(chan bool, 1),
+		},
+	}
+	recorder := &resetRecorder{}
+	s.AddEventListener(recorder)
+	headers := convertHeader(protocol.CommonHeader{
+		protocol.MosnHeaderHostKey: "example.com",
+		protocol.MosnHeaderPathKey: "/",
+	})
+	s.AppendHeaders(context.Background(), headers, false)
+	s.AppendData(context.Background(), buffer.NewIoBufferString("hello"), true)
+
+	if len(recorder.reasons) != 1 || recorder.reasons[0] != types.StreamConnectionFailed {
+		t.Fatalf("expected the stream reset by the connection failure, but got %v", recorder.reasons)
+	}
+	if len(s.connection.requestSent) != 0 || conn.written.Len() != 0 {
+		t.Fatal("the request should not be sent")
+	}
+}
+
+func Test_classifyWrite(t *testing.T) {
+	err := errors.New("mock error")
+	for _, tc := range []struct {
+		n      int64
+		err    error
+		expect writeResult
+	}{
+		{10, nil, writeSucceeded},
+		{0, err, writeNothingSent},
+		{10, err, writePartialSent},
+	} {
+		if r := classifyWrite(tc.n, tc.err); r != tc.expect {
+			t.Errorf("classify write (%d, %v) expected %d, but got %d", tc.n, tc.err, tc.expect, r)
+		}
+	}
+}
+
+func Test_increaseHops(t *testing.T) {
+	tests := []struct {
+		method      string
+		hops        string
+		maxForwards string
+		forward     bool
+		wantHops    string
+		wantForward string
+	}{
+		{"GET", "", "", true, "1", ""},
+		{"GET", "3", "", true, "4", ""},
+		// an overflowing or unparsable hop count is exhausted
+		{"GET", "invalid", "", true, "4294967295", ""},
+		{"GET", "4294967295", "", true, "4294967295", ""},
+		{"GET", "99999999999", "", true, "4294967295", ""},
+		// Max-Forwards only limits TRACE and OPTIONS
+		{"GET", "", "0", true, "1", "0"},
+		{"TRACE", "", "2", true, "1", "1"},
+		{"OPTIONS", "1", "1", true, "2", "0"},
+		{"TRACE", "", "0", false, "", "0"},
+		{"OPTIONS", "", "0", false, "", "0"},
+		{"TRACE", "", "invalid", true, "1", "invalid"},
+	}
+	for _, tc := range tests {
+		header := &fasthttp.RequestHeader{}
+		header.SetMethod(tc.method)
+		if tc.hops != "" {
+			header.Set(types.HeaderHops, tc.hops)
+		}
+		if tc.maxForwards != "" {
+			header.SetBytesK(HKMaxForwards, tc.maxForwards)
+		}
+		if forward := increaseHops(header); forward != tc.forward {
+			t.Errorf("%s request with max forwards %s expected forward %v, but got %v", tc.method, tc.maxForwards, tc.forward, forward)
+			continue
+		}
+		if hops := string(header.Peek(types.HeaderHops)); tc.forward && hops != tc.wantHops {
+			t.Errorf("%s request with hops %s expected hops %s, but got %s", tc.method, tc.hops, tc.wantHops, hops)
+		}
+		if maxForwards := string(header.PeekBytes(HKMaxForwards)); maxForwards != tc.wantForward {
+			t.Errorf("%s request with max forwards %s expected %s, but got %s", tc.method, tc.maxForwards, tc.wantForward, maxForwards)
+		}
+	}
+}
+
+func Test_serverStreamConnection_replyFinalRecipient(t *testing.T) {
+	conn := &mockConnection{}
+	ssc := &serverStreamConnection{
+		streamConnection: streamConnection{
+			context: context.Background(),
+			conn:    conn,
+		},
+	}
+	request := fasthttp.AcquireRequest()
+	request.Header.SetMethod("TRACE")
+	request.Header.SetRequestURI("/trace")
+	request.Header.SetBytesK(HKMaxForwards, "0")
+	request.Header.Set("Authorization", "Basic secret")
+	request.Header.SetCookie("session", "secret")
+	response := fasthttp.AcquireResponse()
+
+	if !ssc.replyFinalRecipient(request, response) || conn.closed {
+		t.Fatal("connection should be kept alive")
+	}
+	resp := fasthttp.AcquireResponse()
+	if err := resp.Read(bufio.NewReader(&conn.written)); err != nil {
+		t.Fatalf("read reply failed: %v", err)
+	}
+	if resp.StatusCode() != fasthttp.StatusOK || string(resp.Header.ContentType()) != "message/http" {
+		t.Fatalf("unexpected reply: %s", resp.String())
+	}
+	if !bytes.HasPrefix(resp.Body(), []byte("TRACE /trace HTTP/1.1")) {
+		t.Fatalf("trace request should be echoed, but got %s", resp.Body())
+	}
+	if bytes.Contains(resp.Body(), []byte("secret")) {
+		t.Fatalf("the credentials should not be echoed, but got %s", resp.Body())
+	}
+	if string(request.Header.Peek("Authorization")) != "Basic secret" {
+		t.Fatal("the request should not be changed")
+	}
+
+	// the connection is closed if the request asks
+	conn = &mockConnection{}
+	ssc.conn = conn
+	request.Header.SetMethod("OPTIONS")
+	request.Header.SetConnectionClose()
+	if ssc.replyFinalRecipient(request, response) || !conn.closed {
+		t.Fatal("connection should be closed")
+	}
+}
+
+type mockBodyLimiter struct {
+	types.ServerStreamConnectionEventListener
+	maxBytes uint64
+	declared []bool
+	body     []byte
+	received bool
+	conn     *serverStreamConnection
+}
+
+func (l *mockBodyLimiter) MaxRequestBytes(headers types.HeaderMap) uint64 {
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/limited" {
+		return 0
+	}
+	return l.maxBytes
+}
+
+func (l *mockBodyLimiter) OnRequestBodyTooLarge(declared bool) {
+	l.declared = append(l.declared, declared)
+}
+
+func (l *mockBodyLimiter) NewStreamDetect(ctx context.Context, sender types.StreamSender, span types.Span) types.StreamReceiveListener {
+	return l
+}
+
+func (l *mockBodyLimiter) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	l.received = true
+	if data != nil {
+		l.body = append(l.body, data.Bytes()...)
+	}
+	// stop serving
+	close(l.conn.connClosed)
+}
+
+func (l *mockBodyLimiter) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {}
+
+func serveRequest(raw string, limiter *mockBodyLimiter) *mockConnection {
+	conn := &mockConnection{}
+	ssc := &serverStreamConnection{
+		streamConnection: streamConnection{
+			context:    context.Background(),
+			conn:       conn,
+			connClosed: make(chan bool, 1),
+		},
+		contextManager:           str.NewContextManager(context.Background()),
+		serverStreamConnListener: limiter,
+	}
+	ssc.contextManager.Next()
+	ssc.br = bufio.NewReader(strings.NewReader(raw))
+	limiter.conn = ssc
+	ssc.serve()
+	return conn
+}
+
+func Test_serverStreamConnection_maxRequestBytes(t *testing.T) {
+	// the declared Content-Length is rejected before the body is read
+	limiter := &mockBodyLimiter{maxBytes: 10}
+	conn := serveRequest("POST /limited HTTP/1.1\r\nHost: mosn\r\nContent-Length: 11\r\n\r\n", limiter)
+	if !bytes.Equal(conn.written.Bytes(), strTooLargeResponse) || !conn.closed {
+		t.Fatalf("expected 413 reply and connection closed, but got %q", conn.written.String())
+	}
+	if len(limiter.declared) != 1 || !limiter.declared[0] || limiter.received {
+		t.Fatalf("expected declared length rejected, but got %v", limiter.declared)
+	}
+
+	// the chunked body is rejected when it runs over the limit
+	limiter = &mockBodyLimiter{maxBytes: 10}
+	conn = serveRequest("POST /limited HTTP/1.1\r\nHost: mosn\r\nTransfer-Encoding: chunked\r\n\r\n6\r\nhello \r\n5\r\nworld\r\n0\r\n\r\n", limiter)
+	if !bytes.Equal(conn.written.Bytes(), strTooLargeResponse) || !conn.closed {
+		t.Fatalf("expected 413 reply and connection closed, but got %q", conn.written.String())
+	}
+	if len(limiter.declared) != 1 || limiter.declared[0] || limiter.received {
+		t.Fatalf("expected streamed overrun rejected, but got %v", limiter.declared)
+	}
+
+	// the 100-continue is not sent if the declared length is too large
+	limiter = &mockBodyLimiter{maxBytes: 10}
+	conn = serveRequest("POST /limited HTTP/1.1\r\nHost: mosn\r\nExpect: 100-continue\r\nContent-Length: 11\r\n\r\n", limiter)
+	if !bytes.Equal(conn.written.Bytes(), strTooLargeResponse) {
+		t.Fatalf("expected 413 reply only, but got %q", conn.written.String())
+	}
+
+	// the request exactly at the limit is forwarded
+	limiter = &mockBodyLimiter{maxBytes: 10}
+	conn = serveRequest("POST /limited HTTP/1.1\r\nHost: mosn\r\nContent-Length: 10\r\n\r\nhelloworld", limiter)
+	if conn.written.Len() != 0 || conn.closed || len(limiter.declared) != 0 {
+		t.Fatalf("request at the limit should not be rejected, but got %q", conn.written.String())
+	}
+	if !limiter.received || string(limiter.body) != "helloworld" {
+		t.Fatalf("expected body forwarded, but got %q", limiter.body)
+	}
+
+	// the chunked body at the limit is forwarded too
+	limiter = &mockBodyLimiter{maxBytes: 10}
+	serveRequest("POST /limited HTTP/1.1\r\nHost: mosn\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n5\r\nworld\r\n0\r\n\r\n", limiter)
+	if !limiter.received || string(limiter.body) != "helloworld" {
+		t.Fatalf("expected body forwarded, but got %q", limiter.body)
+	}
+
+	// routes without limit are not affected
+	limiter = &mockBodyLimiter{maxBytes: 10}
+	serveRequest("POST /other HTTP/1.1\r\nHost: mosn\r\nContent-Length: 11\r\n\r\nhello world", limiter)
+	if !limiter.received || len(limiter.declared) != 0 {
+		t.Fatal("request of route without limit should be forwarded")
+	}
+}
+
+func Test_serverStreamConnection_maxRequestBodySize(t *testing.T) {
+	request := fasthttp.AcquireRequest()
+	request.Header.SetMethod("POST")
+	request.Header.SetRequestURI("/limited")
+	request.Header.SetContentLength(100)
+	for _, tc := range []struct {
+		maxBytes uint64
+		expect   int
+	}{
+		{0, defaultMaxRequestBodySize},
+		{100, 100},
+		// the route can not loosen the listener-wide limit
+		{defaultMaxRequestBodySize + 1, defaultMaxRequestBodySize},
+	} {
+		ssc := &serverStreamConnection{
+			serverStreamConnListener: &mockBodyLimiter{maxBytes: tc.maxBytes},
+		}
+		if limit := ssc.maxRequestBodySize(request); limit != tc.expect {
+			t.Errorf("route limit %d expected %d, but got %d", tc.maxBytes, tc.expect, limit)
+		}
+	}
+}
+
+func Test_serverStreamConnection_replyOverloaded(t *testing.T) {
+	if err := overload.Start(&v2.OverloadConfig{MaxGoroutines: 1, RejectStreamsThreshold: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	defer overload.Stop()
+	for i := 0; i < 100 && !overload.Engaged(overload.RejectStreams); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	limiter := &mockBodyLimiter{}
+	conn := serveRequest("GET / HTTP/1.1\r\nHost: mosn\r\n\r\nGET / HTTP/1.1\r\nHost: mosn\r\n\r\n", limiter)
+	if limiter.received {
+		t.Fatal("the request should not be proxied while overloaded")
+	}
+	// the connection is kept alive, both requests are answered
+	br := bufio.NewReader(&conn.written)
+	for i := 0; i < 2; i++ {
+		resp := fasthttp.AcquireResponse()
+		if err := resp.Read(br); err != nil {
+			t.Fatalf("read reply #%d failed: %v", i, err)
+		}
+		if resp.StatusCode() != fasthttp.StatusServiceUnavailable {
+			t.Fatalf("unexpected reply: %s", resp.String())
+		}
+	}
+}
+
+// discardConnection drops the written buffers and gives them back to the pool like the connection does
+type discardConnection struct {
+	types.Connection
+	written int64
+}
+
+func (c *discardConnection) Write(buffers ...types.IoBuffer) error {
+	for _, buf := range buffers {
+		c.written += int64(buf.Len())
+		buffer.PutIoBuffer(buf)
+	}
+	return nil
+}
+
+func benchmarkServerStreamWrite(b *testing.B, size int) {
+	body := bytes.Repeat([]byte("a"), size)
+	level := log.Proxy.GetLogLevel()
+	log.Proxy.SetLogLevel(log.ERROR)
+	defer log.Proxy.SetLogLevel(level)
+	conn := &discardConnection{}
+	s := newMockServerStream(conn)
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.response.Reset()
+		s.response.Header.Set("Content-Type", "text/plain")
+		s.response.SetBody(body)
+		if err := s.doSend(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServerStreamWrite4KB(b *testing.B) {
+	benchmarkServerStreamWrite(b, 4*1024)
+}
+
+func BenchmarkServerStreamWrite64KB(b *testing.B) {
+	benchmarkServerStreamWrite(b, 64*1024)
+}
+
+// keepAliveConnection gives the responses back to the pool and notifies the client
+type keepAliveConnection struct {
+	types.Connection
+	responses chan struct{}
+	closed    chan struct{}
+}
+
+func (c *keepAliveConnection) Write(buffers ...types.IoBuffer) error {
+	for _, buf := range buffers {
+		buffer.PutIoBuffer(buf)
+	}
+	c.responses <- struct{}{}
+	return nil
+}
+
+func (c *keepAliveConnection) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	close(c.closed)
+	return nil
+}
+
+func (c *keepAliveConnection) AddConnectionEventListener(listener types.ConnectionEventListener) {}
+
+func (c *keepAliveConnection) SetTransferEventListener(listener func() bool) {}
+
+// keepAliveListener replies 200 to every request, the first panics requests panic
+type keepAliveListener struct {
+	sender      types.StreamSender
+	panics      int
+	resetReason types.StreamResetReason
+}
+
+func (l *keepAliveListener) OnResetStream(reason types.StreamResetReason) {
+	l.resetReason = reason
+}
+
+func (l *keepAliveListener) OnDestroyStream() {}
+
+func (l *keepAliveListener) OnGoAway() {}
+
+func (l *keepAliveListener) NewStreamDetect(ctx context.Context, sender types.StreamSender, span types.Span) types.StreamReceiveListener {
+	l.sender = sender
+	sender.GetStream().AddEventListener(l)
+	return l
+}
+
+func (l *keepAliveListener) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	if l.panics > 0 {
+		l.panics--
+		panic("receiver panic")
+	}
+	l.sender.AppendHeaders(ctx, http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}, true)
+}
+
+func (l *keepAliveListener) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {}
+
+// benchmarkServerStreamConnection dispatches the requests of a keep-alive connection,
+// pipelined requests are dispatched by one read
+func benchmarkServerStreamConnection(b *testing.B, pipelined int) {
+	level := log.Proxy.GetLogLevel()
+	log.Proxy.SetLogLevel(log.ERROR)
+	defer log.Proxy.SetLogLevel(level)
+	conn := &keepAliveConnection{responses: make(chan struct{}, pipelined)}
+	ssc := newServerStreamConnection(context.Background(), conn, &keepAliveListener{}).(*serverStreamConnection)
+	defer ssc.OnEvent(types.RemoteClose)
+
+	raw := bytes.Repeat([]byte("GET / HTTP/1.1\r\nHost: mosn\r\n\r\n"), pipelined)
+	data := buffer.NewIoBuffer(len(raw))
+	requests := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for requests < b.N {
+		data.Write(raw)
+		ssc.Dispatch(data)
+		for i := 0; i < pipelined; i++ {
+			<-conn.responses
+		}
+		requests += pipelined
+	}
+	b.ReportMetric(float64(requests)/b.Elapsed().Seconds(), "req/s")
+}
+
+func BenchmarkServerStreamConnectionKeepAlive(b *testing.B) {
+	benchmarkServerStreamConnection(b, 1)
+}
+
+func BenchmarkServerStreamConnectionPipelined(b *testing.B) {
+	benchmarkServerStreamConnection(b, 16)
+}
+
+func Test_serverStreamConnection_servePanic(t *testing.T) {
+	stats := metrics.NewStreamStats(string(protocol.HTTP1))
+	panics := stats.Counter(metrics.ServePanicTotal).Count()
+	request := buffer.NewIoBuffer(64)
+
+	// the response of the panicking request is lost, the connection is closed on the first panic
+	conn := &keepAliveConnection{responses: make(chan struct{}, 1), closed: make(chan struct{})}
+	listener := &keepAliveListener{panics: 1}
+	ssc := newServerStreamConnection(context.Background(), conn, listener).(*serverStreamConnection)
+	for i := 0; i < 2; i++ {
+		request.Reset()
+		request.WriteString("GET / HTTP/1.1\r\nHost: mosn\r\n\r\n")
+		ssc.Dispatch(request)
+	}
+	select {
+	case <-conn.closed:
+	case <-conn.responses:
+		t.Fatal("the request after the panic should not be answered")
+	case <-time.After(time.Second):
+		t.Fatal("the connection is not closed")
+	}
+	ssc.OnEvent(types.RemoteClose)
+	if n := stats.Counter(metrics.ServePanicTotal).Count() - panics; n != 1 {
+		t.Errorf("expected 1 panic counted, but got %d", n)
+	}
+	// the listeners of the broken stream are reset
+	if listener.resetReason != types.StreamLocalReset {
+		t.Errorf("expected the stream reset, but got %q", listener.resetReason)
+	}
+}
+
+// BenchmarkClientStreamAppendHeaders converts a downstream request with 20 headers to the upstream request
+func BenchmarkClientStreamAppendHeaders(b *testing.B) {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:12200")
+	request := fasthttp.AcquireRequest()
+	request.Header.SetMethod("GET")
+	request.SetRequestURI("http://mosn.io/benchmark/path?query=string")
+	for i := 0; i < 20; i++ {
+		request.Header.Set("X-Benchmark-Header-"+strconv.Itoa(i), "value-"+strconv.Itoa(i))
+	}
+	header := http.RequestHeader{RequestHeader: &request.Header, Typed: &protocol.TypedHeader{}}
+	injectInternalHeaders(header, request.URI())
+	s := &clientStream{
+		stream: stream{request: fasthttp.AcquireRequest()},
+		connection: &clientStreamConnection{
+			streamConnection: streamConnection{
+				conn: network.NewClientConnection(nil, 0, nil, remoteAddr, nil),
+			},
+		},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.request.Reset()
+		s.AppendHeaders(context.Background(), header, false)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"io"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+)
+
+const (
+	// headerSizeHint is added to the body size to get the size of the IoBuffer a message is written into
+	headerSizeHint = 512
+	// passThroughSize is the buffer size of the writer that writes the message into the IoBuffer,
+	// the header and the body are larger than it, so they are written to the IoBuffer without staging
+	passThroughSize = 16
+)
+
+var (
+	readerPool      sync.Pool
+	writerPool      sync.Pool
+	passThroughPool sync.Pool
+)
+
+// acquireReader gets a reader of the connection from the pool, it is released after the connection is served
+func acquireReader(r io.Reader) *bufio.Reader {
+	if v := readerPool.Get(); v != nil {
+		br := v.(*bufio.Reader)
+		br.Reset(r)
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+func releaseReader(br *bufio.Reader) {
+	if br == nil {
+		return
+	}
+	br.Reset(nil)
+	readerPool.Put(br)
+}
+
+func acquireWriter(pool *sync.Pool, w io.Writer, size int) *bufio.Writer {
+	if v := pool.Get(); v != nil {
+		bw := v.(*bufio.Writer)
+		bw.Reset(w)
+		return bw
+	}
+	return bufio.NewWriterSize(w, size)
+}
+
+func releaseWriter(pool *sync.Pool, bw *bufio.Writer) {
+	bw.Reset(nil)
+	pool.Put(bw)
+}
+
+// httpMessage is the fasthttp request or response
+type httpMessage interface {
+	Write(w *bufio.Writer) error
+	Body() []byte
+	IsBodyStream() bool
+}
+
+// countWriter counts the bytes handed over to the connection
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeMessage writes the request or the response to the connection, and returns the bytes handed over to the connection.
+// The message is written into one IoBuffer owned by the connection after the write, so the body is copied once and
+// the message is handed over by one write, it is never partially sent.
+// The message with a body stream is handed over every time the writer is full, part of it is sent if the body stream fails.
+func (conn *streamConnection) writeMessage(msg httpMessage) (int64, error) {
+	if msg.IsBodyStream() {
+		cw := &countWriter{w: conn}
+		bw := acquireWriter(&writerPool, cw, 0)
+		err := msg.Write(bw)
+		// the bytes read from the body stream before the failure are flushed too
+		if ferr := bw.Flush(); err == nil {
+			err = ferr
+		}
+		releaseWriter(&writerPool, bw)
+		return cw.n, err
+	}
+
+	buf := buffer.GetIoBuffer(len(msg.Body()) + headerSizeHint)
+	bw := acquireWriter(&passThroughPool, buf, passThroughSize)
+	err := msg.Write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	releaseWriter(&passThroughPool, bw)
+	if err != nil {
+		buffer.PutIoBuffer(buf)
+		return 0, err
+	}
+	n := int64(buf.Len())
+	if err := conn.conn.Write(buf); err != nil {
+		// nothing is written to the connection
+		return 0, err
+	}
+	return n, nil
+}
@@ -19,6 +19,7 @@ package http2
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 
@@ -41,6 +42,7 @@ func init() {
 type connPool struct {
 	activeClient *activeClient
 	host         types.Host
+	closed       bool
 
 	mux sync.Mutex
 }
@@ -65,46 +67,125 @@ func (p *connPool) CheckAndInit(ctx context.Context) bool {
 }
 
 func (p *connPool) NewStream(ctx context.Context,
-	responseDecoder types.StreamReceiveListener, listener types.PoolEventListener) {
+	responseDecoder types.StreamReceiveListener, listener types.PoolEventListener) types.Cancellable {
 
+	closed := false
 	activeClient := func() *activeClient {
 		p.mux.Lock()
 		defer p.mux.Unlock()
+		if p.closed {
+			closed = true
+			return nil
+		}
 		if p.activeClient == nil {
 			p.activeClient = newActiveClient(ctx, p)
 		}
+		if p.activeClient != nil {
+			// counted under the lock, so Close never sees the client idle while the stream is being created
+			atomic.AddInt64(&p.activeClient.activeStreams, 1)
+		}
 		return p.activeClient
 	}()
 
+	if closed {
+		listener.OnFailure(types.PoolClosed, p.host)
+		return nil
+	}
+
 	if activeClient == nil {
 		listener.OnFailure(types.ConnectionFailure, p.host)
-		return
+		return nil
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	requests := str.ResourceManagerByContext(ctx, p.host.ClusterInfo()).Requests()
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		activeClient.releaseStream()
 	} else {
 		atomic.AddUint64(&activeClient.totalStream, 1)
 		p.host.HostStats().UpstreamRequestTotal.Inc(1)
-		p.host.HostStats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
-		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-		p.host.ClusterInfo().ResourceManager().Requests().Increase()
-		streamEncoder := activeClient.client.NewStream(ctx, responseDecoder)
-		streamEncoder.GetStream().AddEventListener(activeClient)
+
+		var streamEncoder types.StreamSender
+		// oneway
+		if responseDecoder == nil {
+			streamEncoder = activeClient.client.NewStream(ctx, nil)
+		} else {
+			streamEncoder = activeClient.client.NewStream(ctx, responseDecoder)
+			streamEncoder.GetStream().AddEventListener(activeClient)
+
+			p.host.HostStats().UpstreamRequestActive.Inc(1)
+			p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
+			requests.Increase()
+			str.DecreaseOnDestroy(streamEncoder.GetStream(), requests)
+		}
 
 		listener.OnReady(streamEncoder, p.host)
+
+		// a oneway stream is never destroyed, it is done once it is sent
+		if responseDecoder == nil {
+			activeClient.releaseStream()
+		}
 	}
 
-	return
+	return nil
 }
 
+// Close rejects new streams and drains the client: it sends go away
+// and closes the connection once the streams in flight are done
 func (p *connPool) Close() {
-	if p.activeClient != nil {
-		p.activeClient.client.Close()
+	p.mux.Lock()
+	p.closed = true
+	ac := p.activeClient
+	p.mux.Unlock()
+
+	if ac != nil {
+		ac.drain()
+	}
+}
+
+// Snapshot implements types.ConnectionSnapshotPool
+func (p *connPool) Snapshot() types.ConnPoolSnapshot {
+	p.mux.Lock()
+	ac := p.activeClient
+	p.mux.Unlock()
+
+	snapshot := types.ConnPoolSnapshot{
+		Protocol: protocol.HTTP2,
+		Host:     p.host.AddressString(),
+		Clients:  make([]types.UpstreamConnectionState, 0, 1),
 	}
+	if ac != nil {
+		snapshot.TotalClients = 1
+		snapshot.Clients = append(snapshot.Clients, ac.connectionState())
+	}
+	return snapshot
+}
+
+// CloseConnection implements types.ConnectionSnapshotPool
+// the client is removed from the pool, so the new streams use a new client.
+// a client closed gracefully sends go away and is closed when its streams are done
+func (p *connPool) CloseConnection(id string, graceful bool) (bool, bool) {
+	p.mux.Lock()
+	ac := p.activeClient
+	if ac == nil || ac.id != id {
+		p.mux.Unlock()
+		return false, false
+	}
+	p.activeClient = nil
+	p.mux.Unlock()
+
+	// the close event is handled with the lock, so close the client without it
+	active := atomic.LoadInt64(&ac.activeStreams) > 0
+	log.DefaultLogger.Infof("[stream] [http2] [connpool] close client %s, graceful: %v, active stream: %v", id, graceful, active)
+	if graceful {
+		ac.drain()
+	} else {
+		ac.client.Close()
+	}
+	return true, active
 }
 
 func (p *connPool) Shutdown() {
@@ -124,23 +205,33 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 				p.host.ClusterInfo().Stats().UpstreamConnectionRemoteCloseWithActiveRequest.Inc(1)
 			}
 		}
-		p.activeClient = nil
+		p.removeClient(client)
 	} else if event == types.ConnectTimeout {
 		p.host.HostStats().UpstreamRequestTimeout.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTimeout.Inc(1)
 		client.client.Close()
-		p.activeClient = nil
+		p.removeClient(client)
 	} else if event == types.ConnectFailed {
 		p.host.HostStats().UpstreamConnectionConFail.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamConnectionConFail.Inc(1)
+		p.removeClient(client)
+	}
+}
+
+// removeClient removes the client if it is still used by the pool,
+// the client closed by CloseConnection may be replaced by a new one
+func (p *connPool) removeClient(client *activeClient) {
+	p.mux.Lock()
+	if p.activeClient == client {
 		p.activeClient = nil
 	}
+	p.mux.Unlock()
 }
 
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
+	client.releaseStream()
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
@@ -161,6 +252,13 @@ func (p *connPool) createStreamClient(context context.Context, connData types.Cr
 	return str.NewStreamClient(context, protocol.HTTP2, connData.Connection, connData.HostInfo)
 }
 
+// the drain state of an activeClient
+const (
+	clientActive uint32 = iota
+	clientDraining
+	clientClosed
+)
+
 // types.StreamEventListener
 // types.ConnectionEventListener
 // types.StreamConnectionEventListener
@@ -170,6 +268,10 @@ type activeClient struct {
 	host               types.CreateConnectionData
 	closeWithActiveReq bool
 	totalStream        uint64
+	activeStreams      int64
+	drainState         uint32
+	// the stable identifier of the client, the connection id plus the host address
+	id string
 }
 
 func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
@@ -178,6 +280,7 @@ func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
 	}
 
 	data := pool.host.CreateConnection(ctx)
+	str.RecordConnectDuration(pool.host, data.Connection)
 	ac.host = data
 	if err := ac.host.Connection.Connect(); err != nil {
 		return nil
@@ -189,6 +292,7 @@ func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
 	codecClient.SetStreamConnectionEventListener(ac)
 
 	ac.client = codecClient
+	ac.id = fmt.Sprintf("%d@%s", codecClient.ConnID(), pool.host.AddressString())
 
 	pool.host.HostStats().UpstreamConnectionTotal.Inc(1)
 	pool.host.HostStats().UpstreamConnectionActive.Inc(1)
@@ -201,6 +305,36 @@ func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
 	return ac
 }
 
+// drain sends go away and closes the client when it is idle
+func (ac *activeClient) drain() {
+	if atomic.CompareAndSwapUint32(&ac.drainState, clientActive, clientDraining) {
+		ac.client.GoAway()
+		ac.closeIfIdle()
+	}
+}
+
+func (ac *activeClient) releaseStream() {
+	atomic.AddInt64(&ac.activeStreams, -1)
+	ac.closeIfIdle()
+}
+
+func (ac *activeClient) closeIfIdle() {
+	if atomic.LoadInt64(&ac.activeStreams) == 0 &&
+		atomic.CompareAndSwapUint32(&ac.drainState, clientDraining, clientClosed) {
+		ac.client.Close()
+	}
+}
+
+func (ac *activeClient) connectionState() types.UpstreamConnectionState {
+	return types.UpstreamConnectionState{
+		ID:           ac.id,
+		ConnectionID: ac.client.ConnID(),
+		Host:         ac.pool.host.AddressString(),
+		Active:       atomic.LoadInt64(&ac.activeStreams) > 0,
+		Draining:     atomic.LoadUint32(&ac.drainState) == clientDraining,
+	}
+}
+
 func (ac *activeClient) OnEvent(event types.ConnectionEvent) {
 	ac.pool.onConnectionEvent(ac, event)
 }
@@ -27,15 +27,19 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 
+	"google.golang.org/grpc/codes"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/module/http2"
 	"sofastack.io/sofa-mosn/pkg/mtls"
+	"sofastack.io/sofa-mosn/pkg/overload"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
 	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/trace"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -156,7 +160,9 @@ type serverStreamConnection struct {
 	streamConnection
 	mutex   sync.RWMutex
 	streams map[uint32]*serverStream
-	sc      *http2.MServerConn
+	// the streams answered locally while mosn is overloaded, the frames received later are dropped
+	rejected map[uint32]struct{}
+	sc       *http2.MServerConn
 
 	serverCallbacks types.ServerStreamConnectionEventListener
 }
@@ -187,6 +193,7 @@ func newServerStreamConnection(ctx context.Context, connection types.Connection,
 	})
 
 	sc.streams = make(map[uint32]*serverStream, 32)
+	sc.rejected = make(map[uint32]struct{})
 	log.Proxy.Debugf(ctx, "new http2 server stream connection")
 
 	return sc
@@ -256,6 +263,11 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 
 	// header
 	if h2s != nil {
+		// the new stream is answered with 503 while mosn is overloaded, it is not proxied
+		if overload.Engaged(overload.RejectStreams) {
+			conn.replyOverloaded(ctx, h2s, endStream)
+			return
+		}
 		stream, err := conn.onNewStreamDetect(ctx, h2s, endStream)
 		if err != nil {
 			conn.handleError(ctx, f, err)
@@ -297,6 +309,10 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 
 	stream := conn.onStreamRecv(ctx, id, endStream)
 	if stream == nil {
+		if conn.onRejectedRecv(id, endStream) {
+			log.Proxy.Debugf(ctx, "http2 server drop the frame of the rejected stream, id = %d", id)
+			return
+		}
 		log.Proxy.Errorf(ctx, "http2 server OnStreamRecv error, invaild id = %d", id)
 		return
 	}
@@ -342,6 +358,7 @@ func (conn *serverStreamConnection) handleError(ctx context.Context, f http2.Fra
 			if s != nil {
 				delete(conn.streams, err.StreamID)
 			}
+			delete(conn.rejected, err.StreamID)
 			conn.mutex.Unlock()
 			if s != nil {
 				s.ResetStream(types.StreamLocalReset)
@@ -370,10 +387,50 @@ func (conn *serverStreamConnection) onNewStreamDetect(ctx context.Context, h2s *
 		conn.mutex.Unlock()
 	}
 
-	stream.receiver = conn.serverCallbacks.NewStreamDetect(stream.ctx, stream, nil)
+	var span types.Span
+	if trace.IsEnabled() {
+		tracer := trace.Tracer(protocol.HTTP2)
+		if tracer != nil {
+			span = tracer.Start(ctx, mhttp2.NewReqHeader(h2s.Request), time.Now())
+		}
+	}
+
+	stream.receiver = conn.serverCallbacks.NewStreamDetect(stream.ctx, stream, span)
 	return stream, nil
 }
 
+// replyOverloaded answers the stream with 503, the frames of the stream received later are dropped
+func (conn *serverStreamConnection) replyOverloaded(ctx context.Context, h2s *http2.MStream, endStream bool) {
+	overload.OnRejected(overload.RejectStreams)
+	if !endStream {
+		conn.mutex.Lock()
+		conn.rejected[h2s.ID()] = struct{}{}
+		conn.mutex.Unlock()
+	}
+	stream := &serverStream{}
+	stream.id = h2s.ID()
+	stream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, stream.id)
+	stream.sc = conn
+	stream.h2s = h2s
+	stream.conn = conn.conn
+	log.Proxy.Debugf(stream.ctx, "http2 server mosn is overloaded, reply stream id = %d locally", stream.id)
+	stream.AppendHeaders(stream.ctx, protocol.CommonHeader{types.HeaderStatus: strconv.Itoa(http.StatusServiceUnavailable)}, true)
+}
+
+// onRejectedRecv returns true if the stream is rejected by replyOverloaded,
+// the stream is forgotten once the request is ended
+func (conn *serverStreamConnection) onRejectedRecv(id uint32, endStream bool) bool {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	if _, ok := conn.rejected[id]; !ok {
+		return false
+	}
+	if endStream {
+		delete(conn.rejected, id)
+	}
+	return true
+}
+
 func (conn *serverStreamConnection) onStreamRecv(ctx context.Context, id uint32, endStream bool) *serverStream {
 	conn.mutex.Lock()
 	defer conn.mutex.Unlock()
@@ -392,6 +449,8 @@ type serverStream struct {
 	stream
 	h2s *http2.MStream
 	sc  *serverStreamConnection
+	// the grpc-status of a grpc failure is sent in the headers
+	grpcTrailersOnly bool
 }
 
 // types.StreamSender
@@ -412,11 +471,19 @@ func (s *serverStream) AppendHeaders(ctx context.Context, headers types.HeaderMa
 	case *mhttp2.RspHeader:
 		rsp = header.Rsp
 	case protocol.CommonHeader:
+		if s.isGrpcFailure(headers, status) {
+			rsp = s.grpcReply(headers, status)
+			break
+		}
 		rsp = new(http.Response)
 		rsp.StatusCode = status
 		rsp.Header = mhttp2.EncodeHeader(headers.(protocol.CommonHeader))
 	case *mhttp2.ReqHeader:
 		// indicates the invocation is under hijack scene
+		if s.isGrpcFailure(headers, status) {
+			rsp = s.grpcReply(headers, status)
+			break
+		}
 		rsp = new(http.Response)
 		rsp.StatusCode = status
 		rsp.Header = s.h2s.Request.Header
@@ -436,8 +503,41 @@ func (s *serverStream) AppendHeaders(ctx context.Context, headers types.HeaderMa
 	return nil
 }
 
+// isGrpcFailure returns true if a grpc request is answered with a failure status, and the grpc-status is absent
+func (s *serverStream) isGrpcFailure(headers types.HeaderMap, status int) bool {
+	if status == http.StatusOK || s.h2s.Request == nil || !mhttp2.IsGrpc(s.h2s.Request.Header) {
+		return false
+	}
+	value, _ := headers.Get(mhttp2.GrpcStatus)
+	return value == ""
+}
+
+// grpcReply answers the grpc request with a trailers-only response, the grpc clients only
+// recognize the grpc-status, the x-mosn-rpc-status overrides the grpc-status mapped from the status
+func (s *serverStream) grpcReply(headers types.HeaderMap, status int) *http.Response {
+	code := mhttp2.MappingFromHttpStatus(status)
+	if rpcStatus, ok := headers.Get(types.HeaderRPCStatus); ok && rpcStatus != "" {
+		if c, err := strconv.ParseUint(rpcStatus, 10, 32); err == nil {
+			code = codes.Code(c)
+		}
+	}
+	rsp := new(http.Response)
+	rsp.StatusCode = http.StatusOK
+	rsp.Header = make(http.Header)
+	rsp.Header.Set("Content-Type", mhttp2.GrpcContentType)
+	rsp.Header.Set(mhttp2.GrpcStatus, strconv.Itoa(int(code)))
+	if msg := http.StatusText(status); msg != "" {
+		rsp.Header.Set(mhttp2.GrpcMessage, msg)
+	}
+	s.grpcTrailersOnly = true
+	return rsp
+}
+
 func (s *serverStream) AppendData(context context.Context, data types.IoBuffer, endStream bool) error {
-	s.h2s.SendData = data
+	// the body of a local reply is not a grpc message
+	if !s.grpcTrailersOnly {
+		s.h2s.SendData = data
+	}
 	log.Proxy.Debugf(s.ctx, "http2 server ApppendData id = %d", s.id)
 
 	if endStream {
@@ -448,6 +548,10 @@ func (s *serverStream) AppendData(context context.Context, data types.IoBuffer,
 }
 
 func (s *serverStream) AppendTrailers(context context.Context, trailers types.HeaderMap) error {
+	if s.grpcTrailersOnly {
+		s.endStream()
+		return nil
+	}
 	switch trailer := trailers.(type) {
 	case protocol.CommonHeader:
 		s.h2s.Response.Trailer = mhttp2.EncodeHeader(trailer)
@@ -551,6 +655,13 @@ func (conn *clientStreamConnection) ActiveStreamsNum() int {
 	return len(conn.streams)
 }
 
+// GoAway tells the server that no new stream will be sent on the connection
+func (conn *clientStreamConnection) GoAway() {
+	if err := conn.mClientConn.GoAway(); err != nil {
+		log.DefaultLogger.Errorf("http2 client send goaway failed: %v", err)
+	}
+}
+
 func (conn *clientStreamConnection) Reset(reason types.StreamResetReason) {
 	conn.mutex.Lock()
 	defer conn.mutex.Unlock()
@@ -744,7 +855,7 @@ func (s *clientStream) AppendHeaders(ctx context.Context, headersIn types.Header
 	if path, ok := headersIn.Get(protocol.MosnHeaderPathKey); ok {
 		headersIn.Del(protocol.MosnHeaderPathKey)
 		if query != "" {
-			URI := fmt.Sprintf(scheme+"://%s%s?", req.Host, path, query)
+			URI := fmt.Sprintf(scheme+"://%s%s?%s", req.Host, path, query)
 			URL, _ = url.Parse(URI)
 		} else {
 			URI := fmt.Sprintf(scheme+"://%s%s", req.Host, path)
@@ -755,6 +866,13 @@ func (s *clientStream) AppendHeaders(ctx context.Context, headersIn types.Header
 		URL, _ = url.Parse(URI)
 	}
 
+	// propagate the trace context to the upstream
+	if trace.IsEnabled() {
+		if span := trace.SpanFromContext(ctx); span != nil {
+			span.InjectContext(headersIn)
+		}
+	}
+
 	if !isReqHeader {
 		req.Method = method
 		req.Host = host
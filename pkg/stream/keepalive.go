@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// EjectKeepAliveFailedHost asks the cluster's outlier detector to eject the host whose heartbeats failed too many times,
+// otherwise the load balancer chooses the dead upstream again and again.
+func EjectKeepAliveFailedHost(host types.Host) {
+	detector := host.ClusterInfo().OutlierDetector()
+	if detector == nil {
+		return
+	}
+	if detector.EjectHost(host) {
+		host.HostStats().UpstreamKeepAliveEject.Inc(1)
+		host.ClusterInfo().Stats().UpstreamKeepAliveEject.Inc(1)
+	}
+}
+
+// RecordKeepAlive records the keepalive status in the host and cluster stats.
+// The outstanding heartbeats are counted up when sent, and counted down when they are done or canceled.
+func RecordKeepAlive(host types.Host, status types.KeepAliveStatus) {
+	hostStats := host.HostStats()
+	clusterStats := host.ClusterInfo().Stats()
+	switch status {
+	case types.KeepAliveSent:
+		hostStats.UpstreamKeepAliveOutstanding.Inc(1)
+		clusterStats.UpstreamKeepAliveOutstanding.Inc(1)
+	case types.KeepAliveSuccess:
+		hostStats.UpstreamKeepAliveSuccessTotal.Inc(1)
+		clusterStats.UpstreamKeepAliveSuccessTotal.Inc(1)
+		hostStats.UpstreamKeepAliveOutstanding.Dec(1)
+		clusterStats.UpstreamKeepAliveOutstanding.Dec(1)
+	case types.KeepAliveTimeout:
+		hostStats.UpstreamKeepAliveTimeoutTotal.Inc(1)
+		clusterStats.UpstreamKeepAliveTimeoutTotal.Inc(1)
+		hostStats.UpstreamKeepAliveOutstanding.Dec(1)
+		clusterStats.UpstreamKeepAliveOutstanding.Dec(1)
+	case types.KeepAliveCanceled:
+		hostStats.UpstreamKeepAliveOutstanding.Dec(1)
+		clusterStats.UpstreamKeepAliveOutstanding.Dec(1)
+	case types.KeepAliveClosed:
+		hostStats.UpstreamKeepAliveClosedConnectionTotal.Inc(1)
+		clusterStats.UpstreamKeepAliveClosedConnectionTotal.Inc(1)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stream
+
+import (
+	"context"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// ResourceManagerByContext returns the cluster's resource manager of the priority in the context,
+// the default priority's is returned if the context has no priority
+func ResourceManagerByContext(ctx context.Context, info types.ClusterInfo) types.ResourceManager {
+	if priority, ok := mosnctx.Get(ctx, types.ContextKeyUpstreamPriority).(types.ResourcePriority); ok {
+		return info.ResourceManagerByPriority(priority)
+	}
+	return info.ResourceManager()
+}
+
+// DecreaseOnDestroy decreases the resource when the stream is destroyed,
+// the streams on a connection may be counted in the resources of different priorities
+func DecreaseOnDestroy(stream types.Stream, resource types.Resource) {
+	stream.AddEventListener(&resourceReleaser{
+		resource: resource,
+	})
+}
+
+// resourceReleaser decreases the resource once
+// types.StreamEventListener
+type resourceReleaser struct {
+	resource types.Resource
+}
+
+func (r *resourceReleaser) OnResetStream(reason types.StreamResetReason) {}
+
+func (r *resourceReleaser) OnDestroyStream() {
+	if r.resource != nil {
+		r.resource.Decrease()
+		r.resource = nil
+	}
+}
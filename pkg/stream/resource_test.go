@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stream
+
+import (
+	"context"
+	"testing"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockClusterInfo struct {
+	types.ClusterInfo
+	managers [types.NumResourcePriorities]types.ResourceManager
+}
+
+func (ci *mockClusterInfo) ResourceManager() types.ResourceManager {
+	return ci.managers[types.DefaultPriority]
+}
+
+func (ci *mockClusterInfo) ResourceManagerByPriority(priority types.ResourcePriority) types.ResourceManager {
+	return ci.managers[priority]
+}
+
+type mockResourceManager struct {
+	types.ResourceManager
+}
+
+type mockResource struct {
+	types.Resource
+	current int
+}
+
+func (r *mockResource) Decrease() {
+	r.current--
+}
+
+type mockStream struct {
+	BaseStream
+}
+
+func (s *mockStream) ID() uint64 {
+	return 0
+}
+
+func (s *mockStream) ReadDisable(disable bool) {}
+
+func TestResourceManagerByContext(t *testing.T) {
+	info := &mockClusterInfo{}
+	for i := range info.managers {
+		info.managers[i] = &mockResourceManager{}
+	}
+	if ResourceManagerByContext(context.Background(), info) != info.managers[types.DefaultPriority] {
+		t.Error("the context without priority should use the default priority")
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyUpstreamPriority, types.HighPriority)
+	if ResourceManagerByContext(ctx, info) != info.managers[types.HighPriority] {
+		t.Error("the context with high priority should use the high priority")
+	}
+}
+
+func TestDecreaseOnDestroy(t *testing.T) {
+	r := &mockResource{current: 1}
+	s := &mockStream{}
+	DecreaseOnDestroy(s, r)
+	s.ResetStream(types.StreamRemoteReset)
+	s.DestroyStream()
+	if r.current != 0 {
+		t.Errorf("the resource should be decreased once, but got %d", r.current)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	// maxServePanics is the max panics recovered in servePanicWindow,
+	// the serve loop gives up on the next panic
+	maxServePanics   = 3
+	servePanicWindow = time.Minute
+)
+
+// ServeLoop runs the iteration of the serve loop of a stream connection until it returns false.
+// A panic in the iteration is recovered and counted in the stream stats of the protocol,
+// recovered is called to clean up the broken iteration, it returns whether the connection can be served on,
+// a connection whose message is not consumed completely can not, so ServeLoop gives up and returns false.
+// A deterministic panic would break every iteration, so ServeLoop also gives up and returns false
+// if the iteration panics more than maxServePanics times in servePanicWindow, the caller should close the connection.
+func ServeLoop(ctx context.Context, protocol types.Protocol, iteration func() bool, recovered func() bool) bool {
+	var panics []time.Time
+	for {
+		next, panicked := serveIteration(ctx, protocol, iteration)
+		if !panicked {
+			if !next {
+				return true
+			}
+			continue
+		}
+
+		if !recovered() {
+			return false
+		}
+		now := time.Now()
+		recent := panics[:0]
+		for _, t := range panics {
+			if now.Sub(t) < servePanicWindow {
+				recent = append(recent, t)
+			}
+		}
+		panics = append(recent, now)
+		if len(panics) > maxServePanics {
+			log.DefaultLogger.Errorf("[stream] [%s] serve loop panics %d times in %s, give up", protocol, len(panics), servePanicWindow)
+			return false
+		}
+	}
+}
+
+func serveIteration(ctx context.Context, protocol types.Protocol, iteration func() bool) (next bool, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.ByContext(ctx).Errorf("[stream] [%s] serve loop panic: %v\n%s", protocol, r, string(debug.Stack()))
+			metrics.NewStreamStats(string(protocol)).Counter(metrics.ServePanicTotal).Inc(1)
+			panicked = true
+		}
+	}()
+	return iteration(), false
+}
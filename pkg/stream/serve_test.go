@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+)
+
+func TestServeLoopPanic(t *testing.T) {
+	// the iteration panics until it is stopped
+	panicking := func(iterations *int, stop int) func() bool {
+		return func() bool {
+			*iterations++
+			if *iterations >= stop {
+				return false
+			}
+			panic("iteration panic")
+		}
+	}
+
+	// the message is not consumed completely, the loop gives up on the first panic
+	iterations := 0
+	if ServeLoop(context.Background(), protocol.HTTP1, panicking(&iterations, 100), func() bool { return false }) {
+		t.Error("expected the serve loop gives up")
+	}
+	if iterations != 1 {
+		t.Errorf("expected 1 iteration, but got %d", iterations)
+	}
+
+	// the loop goes on after the recovered panics
+	iterations = 0
+	if !ServeLoop(context.Background(), protocol.HTTP1, panicking(&iterations, maxServePanics+1), func() bool { return true }) {
+		t.Error("expected the serve loop goes on")
+	}
+
+	// the repeated panics are bounded
+	iterations = 0
+	if ServeLoop(context.Background(), protocol.HTTP1, panicking(&iterations, 100), func() bool { return true }) {
+		t.Error("expected the serve loop gives up")
+	}
+	if iterations != maxServePanics+1 {
+		t.Errorf("expected %d iterations, but got %d", maxServePanics+1, iterations)
+	}
+}
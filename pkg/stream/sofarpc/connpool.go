@@ -19,9 +19,10 @@ package sofarpc
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -38,6 +39,13 @@ const (
 	Connected
 )
 
+// the drain state of an activeClient
+const (
+	clientActive uint32 = iota
+	clientDraining
+	clientClosed
+)
+
 func init() {
 	network.RegisterNewPoolFactory(protocol.SofaRPC, NewConnPool)
 	types.RegisterConnPoolFactory(protocol.SofaRPC, true)
@@ -51,6 +59,7 @@ var defaultSubProtocol byte = 0x00
 type connPool struct {
 	activeClients sync.Map //sub protocol -> activeClient
 	host          types.Host
+	closed        uint32 // 1 if no new stream is accepted, accessed atomically
 
 	mux sync.Mutex
 }
@@ -75,6 +84,10 @@ func (p *connPool) init(client *activeClient, sub byte) {
 
 		p.mux.Lock()
 		defer p.mux.Unlock()
+		if atomic.LoadUint32(&p.closed) == 1 {
+			p.activeClients.Delete(sub)
+			return
+		}
 		client := newActiveClient(context.Background(), sub, p)
 		if client != nil {
 			client.state = Connected
@@ -86,6 +99,11 @@ func (p *connPool) init(client *activeClient, sub byte) {
 }
 
 func (p *connPool) CheckAndInit(ctx context.Context) bool {
+	// a closed pool rejects the stream in NewStream
+	if atomic.LoadUint32(&p.closed) == 1 {
+		return true
+	}
+
 	var client *activeClient
 
 	subProtocol := getSubProtocol(ctx)
@@ -116,26 +134,42 @@ func (p *connPool) Protocol() types.Protocol {
 }
 
 func (p *connPool) NewStream(ctx context.Context,
-	responseDecoder types.StreamReceiveListener, listener types.PoolEventListener) {
+	responseDecoder types.StreamReceiveListener, listener types.PoolEventListener) types.Cancellable {
+	if atomic.LoadUint32(&p.closed) == 1 {
+		listener.OnFailure(types.PoolClosed, p.host)
+		return nil
+	}
+
 	subProtocol := getSubProtocol(ctx)
 
 	client, _ := p.activeClients.Load(subProtocol)
 
 	if client == nil {
 		listener.OnFailure(types.ConnectionFailure, p.host)
-		return
+		return nil
 	}
 
 	activeClient := client.(*activeClient)
 	if atomic.LoadUint32(&activeClient.state) != Connected {
 		listener.OnFailure(types.ConnectionFailure, p.host)
-		return
+		return nil
+	}
+
+	// the stream is counted before the closed flag is checked again, so Close either
+	// sees the stream in flight or the stream sees the pool closed
+	atomic.AddInt64(&activeClient.activeStreams, 1)
+	if atomic.LoadUint32(&p.closed) == 1 {
+		activeClient.releaseStream()
+		listener.OnFailure(types.PoolClosed, p.host)
+		return nil
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	requests := str.ResourceManagerByContext(ctx, p.host.ClusterInfo()).Requests()
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		activeClient.releaseStream()
 	} else {
 		atomic.AddUint64(&activeClient.totalStream, 1)
 		p.host.HostStats().UpstreamRequestTotal.Inc(1)
@@ -151,20 +185,33 @@ func (p *connPool) NewStream(ctx context.Context,
 
 			p.host.HostStats().UpstreamRequestActive.Inc(1)
 			p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-			p.host.ClusterInfo().ResourceManager().Requests().Increase()
+			requests.Increase()
+			str.DecreaseOnDestroy(streamEncoder.GetStream(), requests)
 		}
 
 		listener.OnReady(streamEncoder, p.host)
+
+		// a oneway stream is done once it is sent
+		if responseDecoder == nil {
+			activeClient.releaseStream()
+		}
 	}
 
-	return
+	return nil
 }
 
+// Close rejects new streams and drains the clients,
+// a client is closed once the streams in flight are done
 func (p *connPool) Close() {
+	// the clients connecting are not stored after closed
+	p.mux.Lock()
+	atomic.StoreUint32(&p.closed, 1)
+	p.mux.Unlock()
+
 	f := func(k, v interface{}) bool {
 		ac, _ := v.(*activeClient)
 		if ac.client != nil {
-			ac.client.Close()
+			ac.drain()
 		}
 		return true
 	}
@@ -172,6 +219,64 @@ func (p *connPool) Close() {
 	p.activeClients.Range(f)
 }
 
+// Snapshot implements types.ConnectionSnapshotPool
+func (p *connPool) Snapshot() types.ConnPoolSnapshot {
+	snapshot := types.ConnPoolSnapshot{
+		Protocol: protocol.SofaRPC,
+		Host:     p.host.AddressString(),
+		Clients:  []types.UpstreamConnectionState{},
+	}
+	p.activeClients.Range(func(k, v interface{}) bool {
+		ac := v.(*activeClient)
+		// the clients connecting are not exposed
+		if atomic.LoadUint32(&ac.state) == Connected {
+			snapshot.Clients = append(snapshot.Clients, types.UpstreamConnectionState{
+				ID:           ac.id,
+				ConnectionID: ac.client.ConnID(),
+				Host:         snapshot.Host,
+				Active:       atomic.LoadInt64(&ac.activeStreams) > 0,
+				Draining:     atomic.LoadUint32(&ac.drainState) == clientDraining,
+			})
+		}
+		return true
+	})
+	snapshot.TotalClients = uint64(len(snapshot.Clients))
+	sort.Slice(snapshot.Clients, func(i, j int) bool {
+		return snapshot.Clients[i].ConnectionID < snapshot.Clients[j].ConnectionID
+	})
+	return snapshot
+}
+
+// CloseConnection implements types.ConnectionSnapshotPool
+// the client is removed from the pool, so a new client is connected for the new streams.
+// a client closed gracefully sends go away and is closed when its streams are done
+func (p *connPool) CloseConnection(id string, graceful bool) (bool, bool) {
+	var ac *activeClient
+	p.mux.Lock()
+	p.activeClients.Range(func(k, v interface{}) bool {
+		if c := v.(*activeClient); atomic.LoadUint32(&c.state) == Connected && c.id == id {
+			ac = c
+			p.activeClients.Delete(k)
+			return false
+		}
+		return true
+	})
+	p.mux.Unlock()
+	if ac == nil {
+		return false, false
+	}
+
+	// the close event is handled with the lock, so close the client without it
+	active := atomic.LoadInt64(&ac.activeStreams) > 0
+	log.DefaultLogger.Infof("[stream] [sofarpc] [connpool] close client %s, graceful: %v, active stream: %v", id, graceful, active)
+	if graceful {
+		ac.drain()
+	} else {
+		ac.client.Close()
+	}
+	return true, active
+}
+
 // Shutdown stop the keepalive, so the connection will be idle after requests finished
 func (p *connPool) Shutdown() {
 	f := func(k, v interface{}) bool {
@@ -215,8 +320,11 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 		default:
 			// do nothing
 		}
+		// the client closed by CloseConnection may be replaced by a new one
 		p.mux.Lock()
-		p.activeClients.Delete(client.subProtocol)
+		if v, ok := p.activeClients.Load(client.subProtocol); ok && v == client {
+			p.activeClients.Delete(client.subProtocol)
+		}
 		p.mux.Unlock()
 	} else if event == types.ConnectTimeout {
 		p.host.HostStats().UpstreamRequestTimeout.Inc(1)
@@ -231,7 +339,7 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
+	client.releaseStream()
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
@@ -275,6 +383,10 @@ type activeClient struct {
 	closeWithActiveReq bool
 	totalStream        uint64
 	state              uint32
+	activeStreams      int64
+	drainState         uint32
+	// the stable identifier of the client, the connection id plus the host address
+	id string
 }
 
 func newActiveClient(ctx context.Context, subProtocol byte, pool *connPool) *activeClient {
@@ -284,6 +396,7 @@ func newActiveClient(ctx context.Context, subProtocol byte, pool *connPool) *act
 	}
 
 	data := pool.host.CreateConnection(ctx)
+	str.RecordConnectDuration(pool.host, data.Connection)
 	connCtx := mosnctx.WithValue(ctx, types.ContextKeyConnectionID, data.Connection.ID())
 	codecClient := pool.createStreamClient(connCtx, data)
 	codecClient.AddConnectionEventListener(ac)
@@ -291,19 +404,24 @@ func newActiveClient(ctx context.Context, subProtocol byte, pool *connPool) *act
 
 	ac.client = codecClient
 	ac.host = data
+	ac.id = fmt.Sprintf("%d@%s", codecClient.ConnID(), pool.host.AddressString())
 
 	// Add Keep Alive
 	// protocol is from onNewDetectStream
 	// TODO: support protocol convert
 
-	// TODO: support config
 	if subProtocol != defaultSubProtocol {
-		rpcKeepAlive := NewSofaRPCKeepAlive(codecClient, subProtocol, time.Second, 6)
-		rpcKeepAlive.StartIdleTimeout()
+		config := pool.host.ClusterInfo().KeepAlive()
+		rpcKeepAlive := NewSofaRPCKeepAliveWithConfig(codecClient, subProtocol, config)
+		rpcKeepAlive.AddCallback(ac.onKeepAlive)
 		ac.keepAlive = &keepAliveListener{
 			keepAlive: rpcKeepAlive,
 		}
-		ac.client.AddConnectionEventListener(ac.keepAlive)
+		// the ticker mode sends the heartbeats by itself once the connection is connected
+		if config.Interval <= 0 {
+			rpcKeepAlive.StartIdleTimeout()
+			ac.client.AddConnectionEventListener(ac.keepAlive)
+		}
 	}
 
 	if err := ac.client.Connect(); err != nil {
@@ -326,6 +444,35 @@ func (ac *activeClient) OnEvent(event types.ConnectionEvent) {
 	ac.pool.onConnectionEvent(ac, event)
 }
 
+// drain sends go away and closes the client when it is idle
+func (ac *activeClient) drain() {
+	if atomic.CompareAndSwapUint32(&ac.drainState, clientActive, clientDraining) {
+		ac.client.GoAway()
+		ac.closeIfIdle()
+	}
+}
+
+func (ac *activeClient) releaseStream() {
+	atomic.AddInt64(&ac.activeStreams, -1)
+	ac.closeIfIdle()
+}
+
+func (ac *activeClient) closeIfIdle() {
+	if atomic.LoadInt64(&ac.activeStreams) == 0 &&
+		atomic.CompareAndSwapUint32(&ac.drainState, clientDraining, clientClosed) {
+		ac.client.Close()
+	}
+}
+
+// onKeepAlive records the heartbeats in the stats,
+// and ejects the host if the heartbeats are timeout too many times
+func (ac *activeClient) onKeepAlive(status types.KeepAliveStatus) {
+	str.RecordKeepAlive(ac.pool.host, status)
+	if status == types.KeepAliveThresholdExceeded {
+		str.EjectKeepAliveFailedHost(ac.pool.host)
+	}
+}
+
 // types.StreamEventListener
 func (ac *activeClient) OnDestroyStream() {
 	ac.pool.onStreamDestroy(ac)
@@ -0,0 +1,192 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sofarpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+type mockPoolListener struct {
+	failures []types.PoolFailureReason
+	sender   types.StreamSender
+}
+
+func (l *mockPoolListener) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	l.failures = append(l.failures, reason)
+}
+
+func (l *mockPoolListener) OnReady(sender types.StreamSender, host types.Host) {
+	l.sender = sender
+}
+
+type mockResponseListener struct{}
+
+func (l *mockResponseListener) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+}
+
+func (l *mockResponseListener) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+func TestConnPoolCloseDrain(t *testing.T) {
+	srv, err := newMockServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.GoServe()
+	defer srv.Close()
+
+	c := cluster.NewCluster(v2.Cluster{
+		Name:        "pool_drain",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: srv.AddrString(),
+		},
+	}, c.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+
+	ctx := context.Background()
+	for i := 0; !pool.CheckAndInit(ctx); i++ {
+		if i == 30 {
+			t.Fatal("pool is not connected")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	v, _ := pool.activeClients.Load(defaultSubProtocol)
+	ac := v.(*activeClient)
+
+	listener := &mockPoolListener{}
+	pool.NewStream(ctx, &mockResponseListener{}, listener)
+	if listener.sender == nil {
+		t.Fatalf("stream should be ready, failures %v", listener.failures)
+	}
+	request := listener.sender
+	// the oneway stream is done once it is sent
+	pool.NewStream(ctx, nil, listener)
+	if n := atomic.LoadInt64(&ac.activeStreams); n != 1 {
+		t.Fatalf("expected 1 active stream, but got %d", n)
+	}
+
+	// the busy client is drained, new stream is rejected
+	pool.Close()
+	if state := atomic.LoadUint32(&ac.drainState); state != clientDraining {
+		t.Fatalf("busy client should be draining, but got state %d", state)
+	}
+	if !pool.CheckAndInit(ctx) {
+		t.Fatal("closed pool should not connect")
+	}
+	pool.NewStream(ctx, &mockResponseListener{}, listener)
+	if len(listener.failures) != 1 || listener.failures[0] != types.PoolClosed {
+		t.Fatalf("expected pool closed failure, but got %v", listener.failures)
+	}
+
+	// the client is closed after the stream is done
+	request.GetStream().ResetStream(types.StreamLocalReset)
+	if state := atomic.LoadUint32(&ac.drainState); state != clientClosed {
+		t.Fatalf("client should be closed after the stream is done, but got state %d", state)
+	}
+	for i := 0; ; i++ {
+		if _, ok := pool.activeClients.Load(defaultSubProtocol); !ok {
+			break
+		}
+		if i == 30 {
+			t.Fatal("closed client is not removed")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestConnPoolCloseConnection(t *testing.T) {
+	srv, err := newMockServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.GoServe()
+	defer srv.Close()
+
+	c := cluster.NewCluster(v2.Cluster{
+		Name:        "pool_close_connection",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: srv.AddrString(),
+		},
+	}, c.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+
+	ctx := context.Background()
+	for i := 0; !pool.CheckAndInit(ctx); i++ {
+		if i == 30 {
+			t.Fatal("pool is not connected")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	listener := &mockPoolListener{}
+	pool.NewStream(ctx, &mockResponseListener{}, listener)
+	if listener.sender == nil {
+		t.Fatalf("stream should be ready, failures %v", listener.failures)
+	}
+
+	snapshot := pool.Snapshot()
+	if snapshot.TotalClients != 1 || len(snapshot.Clients) != 1 || !snapshot.Clients[0].Active {
+		t.Fatalf("expected 1 active client, but got %+v", snapshot)
+	}
+	if found, _ := pool.CloseConnection("unknown", true); found {
+		t.Fatal("unknown client should not be found")
+	}
+
+	// the busy client is removed from the pool and closed after the stream is done
+	id := snapshot.Clients[0].ID
+	v, _ := pool.activeClients.Load(defaultSubProtocol)
+	ac := v.(*activeClient)
+	if found, active := pool.CloseConnection(id, true); !found || !active {
+		t.Fatalf("busy client should be found and active, but got %v %v", found, active)
+	}
+	if snapshot := pool.Snapshot(); len(snapshot.Clients) != 0 {
+		t.Fatalf("closed client should be removed, but got %+v", snapshot)
+	}
+	if state := atomic.LoadUint32(&ac.drainState); state != clientDraining {
+		t.Fatalf("busy client should be draining, but got state %d", state)
+	}
+	listener.sender.GetStream().ResetStream(types.StreamLocalReset)
+	if state := atomic.LoadUint32(&ac.drainState); state != clientClosed {
+		t.Fatalf("client should be closed after the stream is done, but got state %d", state)
+	}
+
+	// a new client is connected for the new streams
+	for i := 0; !pool.CheckAndInit(ctx); i++ {
+		if i == 30 {
+			t.Fatal("pool is not connected")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if snapshot := pool.Snapshot(); len(snapshot.Clients) != 1 || snapshot.Clients[0].ID == id {
+		t.Fatalf("expected a new client, but got %+v", snapshot)
+	}
+}
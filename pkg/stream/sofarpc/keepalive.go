@@ -19,6 +19,7 @@ package sofarpc
 
 import (
 	"context"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -37,10 +38,18 @@ type sofaRPCKeepAlive struct {
 	ProtocolByte byte
 	Timeout      time.Duration
 	Threshold    uint32
-	Callbacks    []types.KeepAliveCallback
+	// Interval is the heartbeat interval of the ticker mode,
+	// zero means the heartbeats are sent by SendKeepAlive only, such as the connection is idle
+	Interval time.Duration
+	// IntervalJitter and MaxInterval make the interval random and backoff after timeouts, see nextInterval
+	IntervalJitter float64
+	MaxInterval    time.Duration
+	Callbacks      []types.KeepAliveCallback
 	// runtime
 	timeoutCount uint32
 	idleFree     *idleFree
+	startOnce    sync.Once
+	random       func() float64
 	// stop channel will stop all keep alive action
 	once sync.Once
 	stop chan struct{}
@@ -51,16 +60,30 @@ type sofaRPCKeepAlive struct {
 }
 
 func NewSofaRPCKeepAlive(codec str.Client, proto byte, timeout time.Duration, thres uint32) types.KeepAlive {
+	return NewSofaRPCKeepAliveWithConfig(codec, proto, types.KeepAliveConfig{
+		Timeout:          timeout,
+		FailCountToClose: thres,
+	})
+}
+
+// NewSofaRPCKeepAliveWithConfig creates a keepalive with the config.
+// If the interval is configured, the heartbeats are sent every interval after the connection is connected,
+// no matter there is traffic or not.
+func NewSofaRPCKeepAliveWithConfig(codec str.Client, proto byte, config types.KeepAliveConfig) types.KeepAlive {
 	kp := &sofaRPCKeepAlive{
-		Codec:        codec,
-		ProtocolByte: proto,
-		Timeout:      timeout,
-		Threshold:    thres,
-		Callbacks:    []types.KeepAliveCallback{},
-		timeoutCount: 0,
-		stop:         make(chan struct{}),
-		requests:     make(map[uint64]*keepAliveTimeout),
-		mutex:        sync.Mutex{},
+		Codec:          codec,
+		ProtocolByte:   proto,
+		Timeout:        config.Timeout,
+		Threshold:      config.FailCountToClose,
+		Interval:       config.Interval,
+		IntervalJitter: config.IntervalJitter,
+		MaxInterval:    config.MaxInterval,
+		Callbacks:      []types.KeepAliveCallback{},
+		random:         rand.Float64,
+		timeoutCount:   0,
+		stop:           make(chan struct{}),
+		requests:       make(map[uint64]*keepAliveTimeout),
+		mutex:          sync.Mutex{},
 	}
 	// register keepalive to connection event listener
 	// if connection is closed, keepalive should stop
@@ -68,13 +91,76 @@ func NewSofaRPCKeepAlive(codec str.Client, proto byte, timeout time.Duration, th
 	return kp
 }
 
-// keepalive should stop when connection closed
+// keepalive should start when connection connected, and stop when connection closed
 func (kp *sofaRPCKeepAlive) OnEvent(event types.ConnectionEvent) {
+	if event == types.Connected {
+		kp.Start()
+	}
 	if event.IsClose() || event.ConnectFailure() {
 		kp.Stop()
 	}
 }
 
+// Start starts the ticker that sends a heartbeat every interval, see nextInterval.
+// It does nothing if the interval is not configured, the heartbeats are sent by SendKeepAlive.
+func (kp *sofaRPCKeepAlive) Start() {
+	if kp.Interval <= 0 {
+		return
+	}
+	kp.startOnce.Do(func() {
+		utils.GoWithRecover(kp.runTicker, nil)
+	})
+}
+
+func (kp *sofaRPCKeepAlive) runTicker() {
+	timer := time.NewTimer(kp.nextInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-kp.stop:
+			return
+		case <-timer.C:
+			kp.tick()
+			timer.Reset(kp.nextInterval())
+		}
+	}
+}
+
+// nextInterval returns the delay of the next heartbeat.
+// The interval is doubled after each timeout up to the max interval, so an overloaded upstream is not
+// hammered by the heartbeats, and it is reset after a success. A random jitter is added, so the heartbeats
+// of the connections created at the same time are not synchronized.
+func (kp *sofaRPCKeepAlive) nextInterval() time.Duration {
+	interval := kp.Interval
+	if kp.MaxInterval > interval {
+		for i := atomic.LoadUint32(&kp.timeoutCount); i > 0 && interval < kp.MaxInterval; i-- {
+			interval *= 2
+		}
+		if interval > kp.MaxInterval {
+			interval = kp.MaxInterval
+		}
+	}
+	if kp.IntervalJitter > 0 {
+		// the jitter is in [-IntervalJitter, IntervalJitter) of the interval
+		interval += time.Duration(float64(interval) * kp.IntervalJitter * (2*kp.random() - 1))
+	}
+	return interval
+}
+
+// tick sends a heartbeat, unless the previous one is still waiting for the response
+func (kp *sofaRPCKeepAlive) tick() {
+	kp.mutex.Lock()
+	outstanding := len(kp.requests)
+	kp.mutex.Unlock()
+	if outstanding > 0 {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[stream] [sofarpc] [keepalive] connection %d has %d outstanding heartbeats, skip this tick", kp.Codec.ConnID(), outstanding)
+		}
+		return
+	}
+	kp.SendKeepAlive()
+}
+
 func (kp *sofaRPCKeepAlive) AddCallback(cb types.KeepAliveCallback) {
 	kp.Callbacks = append(kp.Callbacks, cb)
 }
@@ -108,15 +194,17 @@ func (kp *sofaRPCKeepAlive) sendKeepAlive() {
 	// check idle free
 	if kp.idleFree.CheckFree(id) {
 		kp.Codec.Close()
+		kp.runCallback(types.KeepAliveClosed)
 		return
 	}
-	// we send sofa rpc cmd as "header", but it maybe contains "body"
-	hb := sofarpc.NewHeartbeat(kp.ProtocolByte)
-	sender.AppendHeaders(ctx, hb, true)
-	// start a timer for request
+	// start a timer for request, the response may be received before AppendHeaders returns
 	kp.mutex.Lock()
 	kp.requests[id] = startTimeout(id, kp)
 	kp.mutex.Unlock()
+	kp.runCallback(types.KeepAliveSent)
+	// we send sofa rpc cmd as "header", but it maybe contains "body"
+	hb := sofarpc.NewHeartbeat(kp.ProtocolByte)
+	sender.AppendHeaders(ctx, hb, true)
 }
 
 func (kp *sofaRPCKeepAlive) GetTimeout() time.Duration {
@@ -129,15 +217,20 @@ func (kp *sofaRPCKeepAlive) HandleTimeout(id uint64) {
 		return
 	default:
 		kp.mutex.Lock()
-		defer kp.mutex.Unlock()
-		if _, ok := kp.requests[id]; ok {
-			delete(kp.requests, id)
-			atomic.AddUint32(&kp.timeoutCount, 1)
-			// close the connection, stop keep alive
-			if kp.timeoutCount >= kp.Threshold {
-				kp.Codec.Close()
-			}
-			kp.runCallback(types.KeepAliveTimeout)
+		_, ok := kp.requests[id]
+		delete(kp.requests, id)
+		kp.mutex.Unlock()
+		if !ok {
+			return
+		}
+		count := atomic.AddUint32(&kp.timeoutCount, 1)
+		kp.runCallback(types.KeepAliveTimeout)
+		// close the connection, stop keep alive
+		// the lock is not held, as the close event stops the keepalive
+		if count >= kp.Threshold {
+			kp.runCallback(types.KeepAliveThresholdExceeded)
+			kp.Codec.Close()
+			kp.runCallback(types.KeepAliveClosed)
 		}
 	}
 }
@@ -148,14 +241,16 @@ func (kp *sofaRPCKeepAlive) HandleSuccess(id uint64) {
 		return
 	default:
 		kp.mutex.Lock()
-		defer kp.mutex.Unlock()
-		if timeout, ok := kp.requests[id]; ok {
-			delete(kp.requests, id)
-			timeout.timer.Stop()
-			// reset the tiemout count
-			atomic.StoreUint32(&kp.timeoutCount, 0)
-			kp.runCallback(types.KeepAliveSuccess)
+		timeout, ok := kp.requests[id]
+		delete(kp.requests, id)
+		kp.mutex.Unlock()
+		if !ok {
+			return
 		}
+		timeout.timer.Stop()
+		// reset the tiemout count
+		atomic.StoreUint32(&kp.timeoutCount, 0)
+		kp.runCallback(types.KeepAliveSuccess)
 	}
 }
 
@@ -163,6 +258,17 @@ func (kp *sofaRPCKeepAlive) Stop() {
 	kp.once.Do(func() {
 		log.DefaultLogger.Infof("[stream] [sofarpc] [keepalive] connection %d stopped keepalive", kp.Codec.ConnID())
 		close(kp.stop)
+		// the outstanding heartbeats will never be handled
+		kp.mutex.Lock()
+		canceled := len(kp.requests)
+		for id, timeout := range kp.requests {
+			timeout.timer.Stop()
+			delete(kp.requests, id)
+		}
+		kp.mutex.Unlock()
+		for i := 0; i < canceled; i++ {
+			kp.runCallback(types.KeepAliveCanceled)
+		}
 	})
 }
 
@@ -19,11 +19,13 @@ package sofarpc
 
 import (
 	"context"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	gometrics "github.com/rcrowley/go-metrics"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/protocol"
@@ -34,8 +36,10 @@ import (
 )
 
 type testStats struct {
-	success uint32
-	timeout uint32
+	success  uint32
+	timeout  uint32
+	exceeded uint32
+	canceled uint32
 }
 
 func (s *testStats) Record(status types.KeepAliveStatus) {
@@ -44,6 +48,10 @@ func (s *testStats) Record(status types.KeepAliveStatus) {
 		atomic.AddUint32(&s.success, 1)
 	case types.KeepAliveTimeout:
 		atomic.AddUint32(&s.timeout, 1)
+	case types.KeepAliveThresholdExceeded:
+		atomic.AddUint32(&s.exceeded, 1)
+	case types.KeepAliveCanceled:
+		atomic.AddUint32(&s.canceled, 1)
 	}
 }
 
@@ -54,6 +62,19 @@ type testCase struct {
 }
 
 func newTestCase(t *testing.T, srvTimeout, keepTimeout time.Duration, thres uint32) *testCase {
+	tc := newTestCaseWithConfig(t, srvTimeout, types.KeepAliveConfig{
+		Timeout:          keepTimeout,
+		FailCountToClose: thres,
+	})
+	tc.KeepAlive.StartIdleTimeout()
+	return tc
+}
+
+func newTestCaseWithConfig(t *testing.T, srvTimeout time.Duration, config types.KeepAliveConfig) *testCase {
+	return newTestCaseWithProtocol(t, srvTimeout, sofarpc.PROTOCOL_CODE_V1, config)
+}
+
+func newTestCaseWithProtocol(t *testing.T, srvTimeout time.Duration, proto byte, config types.KeepAliveConfig) *testCase {
 	// start a mock server
 	srv, err := newMockServer(srvTimeout)
 	if err != nil {
@@ -82,8 +103,7 @@ func newTestCase(t *testing.T, srvTimeout, keepTimeout time.Duration, thres uint
 		t.Fatal("codec is nil")
 	}
 	// start a keep alive
-	keepAlive := NewSofaRPCKeepAlive(codec, sofarpc.PROTOCOL_CODE_V1, keepTimeout, thres)
-	keepAlive.StartIdleTimeout()
+	keepAlive := NewSofaRPCKeepAliveWithConfig(codec, proto, config)
 	return &testCase{
 		KeepAlive: keepAlive.(*sofaRPCKeepAlive),
 		Server:    srv,
@@ -107,6 +127,23 @@ func TestKeepAlive(t *testing.T) {
 	}
 }
 
+func TestKeepAliveBoltV2(t *testing.T) {
+	tc := newTestCaseWithProtocol(t, 0, sofarpc.PROTOCOL_CODE_V2, types.KeepAliveConfig{
+		Timeout:          time.Second,
+		FailCountToClose: 6,
+	})
+	tc.KeepAlive.StartIdleTimeout()
+	defer tc.Server.Close()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.SendKeepAlive()
+	// wait response
+	time.Sleep(time.Second)
+	if testStats.success != 1 {
+		t.Error("keep alive with bolt v2 heartbeat failed", testStats)
+	}
+}
+
 func TestKeepAliveTimeout(t *testing.T) {
 	tc := newTestCase(t, 50*time.Millisecond, 10*time.Millisecond, 6)
 	defer tc.Server.Close()
@@ -122,6 +159,9 @@ func TestKeepAliveTimeout(t *testing.T) {
 	if testStats.timeout != 6 { // 6 is the max try times
 		t.Error("keep alive handle failure not enough", testStats)
 	}
+	if testStats.exceeded != 1 {
+		t.Error("keep alive threshold exceeded not notified", testStats)
+	}
 }
 
 func TestKeepAliveTimeoutAndSuccess(t *testing.T) {
@@ -229,3 +269,262 @@ func TestKeepAliveIdleFreeWithData(t *testing.T) {
 	close(ch)
 	wg.Wait()
 }
+
+func TestKeepAliveTicker(t *testing.T) {
+	tc := newTestCaseWithConfig(t, 0, types.KeepAliveConfig{
+		Interval:         20 * time.Millisecond,
+		Timeout:          time.Second,
+		FailCountToClose: 6,
+	})
+	defer tc.Server.Close()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	// the connection is connected already, start the ticker directly
+	tc.KeepAlive.Start()
+	tc.KeepAlive.Start() // start twice is ok
+	time.Sleep(300 * time.Millisecond)
+	if success := atomic.LoadUint32(&testStats.success); success < 5 {
+		t.Errorf("expected heartbeats sent by the ticker, but got %d", success)
+	}
+	// no more heartbeats after stopped
+	tc.KeepAlive.Stop()
+	time.Sleep(50 * time.Millisecond)
+	success := atomic.LoadUint32(&testStats.success)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadUint32(&testStats.success) != success {
+		t.Error("heartbeats are sent after the keepalive stopped")
+	}
+}
+
+func TestKeepAliveTickerSkipOutstanding(t *testing.T) {
+	// the response is slower than the interval
+	tc := newTestCaseWithConfig(t, 100*time.Millisecond, types.KeepAliveConfig{
+		Interval:         10 * time.Millisecond,
+		Timeout:          time.Second,
+		FailCountToClose: 6,
+	})
+	defer tc.Server.Close()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.Start()
+	time.Sleep(350 * time.Millisecond)
+	tc.KeepAlive.Stop()
+	// a heartbeat is sent only if the previous one is responsed
+	if success := atomic.LoadUint32(&testStats.success); success == 0 || success > 4 {
+		t.Errorf("expected the ticks are skipped when a heartbeat is outstanding, but got %d responses", success)
+	}
+	if atomic.LoadUint32(&testStats.timeout) != 0 {
+		t.Errorf("expected no timeout, but got %d", testStats.timeout)
+	}
+}
+
+func TestKeepAliveTickerIdleMode(t *testing.T) {
+	tc := newTestCase(t, 0, time.Second, 6)
+	defer tc.Server.Close()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	// no interval, start does nothing
+	tc.KeepAlive.Start()
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadUint32(&testStats.success) != 0 {
+		t.Error("expected no heartbeats without SendKeepAlive")
+	}
+}
+
+type mockOutlierDetector struct {
+	ejected []types.Host
+}
+
+func (d *mockOutlierDetector) EjectHost(host types.Host) bool {
+	for _, h := range d.ejected {
+		if h == host {
+			return false
+		}
+	}
+	d.ejected = append(d.ejected, host)
+	return true
+}
+
+type mockEjectClusterInfo struct {
+	mockClusterInfo
+	detector *mockOutlierDetector
+	stats    types.ClusterStats
+}
+
+func (ci *mockEjectClusterInfo) OutlierDetector() types.OutlierDetector {
+	return ci.detector
+}
+
+func (ci *mockEjectClusterInfo) Stats() types.ClusterStats {
+	return ci.stats
+}
+
+func TestKeepAliveEjectHost(t *testing.T) {
+	info := &mockEjectClusterInfo{
+		mockClusterInfo: mockClusterInfo{
+			name:  "test_eject",
+			limit: 1024,
+		},
+		detector: &mockOutlierDetector{},
+		stats: types.ClusterStats{
+			UpstreamKeepAliveEject:                 gometrics.NewCounter(),
+			UpstreamKeepAliveSuccessTotal:          gometrics.NewCounter(),
+			UpstreamKeepAliveTimeoutTotal:          gometrics.NewCounter(),
+			UpstreamKeepAliveClosedConnectionTotal: gometrics.NewCounter(),
+			UpstreamKeepAliveOutstanding:           gometrics.NewCounter(),
+		},
+	}
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: "127.0.0.1:12200",
+		},
+	}, info)
+	ac := &activeClient{
+		pool: NewConnPool(host).(*connPool),
+	}
+	ac.onKeepAlive(types.KeepAliveTimeout)
+	if len(info.detector.ejected) != 0 {
+		t.Fatal("the host is ejected by a single timeout")
+	}
+	ac.onKeepAlive(types.KeepAliveThresholdExceeded)
+	ac.onKeepAlive(types.KeepAliveThresholdExceeded)
+	if len(info.detector.ejected) != 1 || info.detector.ejected[0] != host {
+		t.Fatalf("unexpected ejected hosts: %v", info.detector.ejected)
+	}
+	if info.stats.UpstreamKeepAliveEject.Count() != 1 || host.HostStats().UpstreamKeepAliveEject.Count() != 1 {
+		t.Error("the keepalive eject is not counted")
+	}
+}
+
+func TestKeepAliveRecordStats(t *testing.T) {
+	info := &mockEjectClusterInfo{
+		mockClusterInfo: mockClusterInfo{
+			name:  "test_stats",
+			limit: 1024,
+		},
+		stats: types.ClusterStats{
+			UpstreamKeepAliveSuccessTotal:          gometrics.NewCounter(),
+			UpstreamKeepAliveTimeoutTotal:          gometrics.NewCounter(),
+			UpstreamKeepAliveClosedConnectionTotal: gometrics.NewCounter(),
+			UpstreamKeepAliveOutstanding:           gometrics.NewCounter(),
+		},
+	}
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: "127.0.0.1:12200",
+		},
+	}, info)
+	ac := &activeClient{
+		pool: NewConnPool(host).(*connPool),
+	}
+	for _, status := range []types.KeepAliveStatus{
+		types.KeepAliveSent, types.KeepAliveSent, types.KeepAliveSent, types.KeepAliveSent,
+		types.KeepAliveSuccess,
+		types.KeepAliveTimeout,
+		types.KeepAliveCanceled,
+		types.KeepAliveClosed,
+	} {
+		ac.onKeepAlive(status)
+	}
+	checkKeepAliveStats(t, "host", host.HostStats().UpstreamKeepAliveSuccessTotal, host.HostStats().UpstreamKeepAliveTimeoutTotal,
+		host.HostStats().UpstreamKeepAliveClosedConnectionTotal, host.HostStats().UpstreamKeepAliveOutstanding)
+	checkKeepAliveStats(t, "cluster", info.stats.UpstreamKeepAliveSuccessTotal, info.stats.UpstreamKeepAliveTimeoutTotal,
+		info.stats.UpstreamKeepAliveClosedConnectionTotal, info.stats.UpstreamKeepAliveOutstanding)
+}
+
+// checkKeepAliveStats expects 1 success, 1 timeout, 1 closed connection and 1 outstanding heartbeat
+func checkKeepAliveStats(t *testing.T, name string, counters ...gometrics.Counter) {
+	for i, c := range counters {
+		if c.Count() != 1 {
+			t.Errorf("unexpected %s keepalive stats at %d, expected 1, but got %d", name, i, c.Count())
+		}
+	}
+}
+
+func TestKeepAliveStopCancelOutstanding(t *testing.T) {
+	tc := newTestCase(t, time.Second, 500*time.Millisecond, 6)
+	defer tc.Server.Close()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.SendKeepAlive()
+	tc.KeepAlive.Stop()
+	tc.KeepAlive.mutex.Lock()
+	outstanding := len(tc.KeepAlive.requests)
+	tc.KeepAlive.mutex.Unlock()
+	if outstanding != 0 {
+		t.Fatalf("expected no outstanding heartbeats after stop, but got %d", outstanding)
+	}
+	// the timer of the canceled heartbeat is stopped
+	time.Sleep(time.Second)
+	if atomic.LoadUint32(&testStats.canceled) != 1 || atomic.LoadUint32(&testStats.timeout) != 0 {
+		t.Errorf("expected the heartbeat canceled, but got: %v", testStats)
+	}
+}
+
+func TestKeepAliveNextInterval(t *testing.T) {
+	kp := &sofaRPCKeepAlive{
+		Interval:    time.Second,
+		MaxInterval: 5 * time.Second,
+		random:      func() float64 { return 0.5 }, // no jitter
+	}
+	// the interval is doubled after each timeout, and capped by the max interval
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, interval := range expected {
+		kp.timeoutCount = uint32(i)
+		if next := kp.nextInterval(); next != interval {
+			t.Errorf("#%d expected interval %s, but got %s", i, interval, next)
+		}
+	}
+	// reset after a success
+	kp.timeoutCount = 0
+	if next := kp.nextInterval(); next != time.Second {
+		t.Errorf("expected interval reset, but got %s", next)
+	}
+	// the backoff is disabled
+	kp.MaxInterval = 0
+	kp.timeoutCount = 3
+	if next := kp.nextInterval(); next != time.Second {
+		t.Errorf("expected no backoff, but got %s", next)
+	}
+	// the jitter is in the range
+	kp.IntervalJitter = 0.2
+	kp.timeoutCount = 0
+	for random, interval := range map[float64]time.Duration{
+		0:   800 * time.Millisecond,
+		0.5: time.Second,
+		1:   1200 * time.Millisecond,
+	} {
+		r := random
+		kp.random = func() float64 { return r }
+		if next := kp.nextInterval(); next != interval {
+			t.Errorf("random %f expected interval %s, but got %s", random, interval, next)
+		}
+	}
+	kp.random = rand.Float64
+	for i := 0; i < 100; i++ {
+		if next := kp.nextInterval(); next < 800*time.Millisecond || next >= 1200*time.Millisecond {
+			t.Fatalf("the interval %s is out of the jitter range", next)
+		}
+	}
+}
+
+func TestKeepAliveTickerBackoff(t *testing.T) {
+	// every heartbeat is timeout
+	tc := newTestCaseWithConfig(t, time.Second, types.KeepAliveConfig{
+		Interval:         20 * time.Millisecond,
+		Timeout:          10 * time.Millisecond,
+		FailCountToClose: 100,
+		MaxInterval:      160 * time.Millisecond,
+	})
+	defer tc.Server.Close()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.Start()
+	time.Sleep(500 * time.Millisecond)
+	tc.KeepAlive.Stop()
+	// the heartbeats are sent at about 20ms, 40ms, 80ms, 160ms, 320ms and 480ms,
+	// more than 15 heartbeats are sent without the backoff
+	if timeout := atomic.LoadUint32(&testStats.timeout); timeout < 3 || timeout > 9 {
+		t.Errorf("expected the heartbeats backoff after timeouts, but got %d timeouts", timeout)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"net"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
@@ -145,4 +146,39 @@ func (ci *mockClusterInfo) SourceAddress() net.Addr {
 
 func (ci *mockClusterInfo) ConnectTimeout() time.Duration {
 	return network.DefaultConnectTimeout
-}
\ No newline at end of file
+}
+
+func (ci *mockClusterInfo) ConnectBackoff() types.ConnectBackoffConfig {
+	return types.ConnectBackoffConfig{}
+}
+
+func (ci *mockClusterInfo) TCPOptions() types.TCPOptions {
+	return types.TCPOptions{}
+}
+
+func (ci *mockClusterInfo) ConnPoolMode() v2.ConnPoolMode {
+	return v2.SHARED_CONN_POOL
+}
+
+func (ci *mockClusterInfo) ProxyProtocol() v2.ProxyProtocolVersion {
+	return ""
+}
+
+func (ci *mockClusterInfo) WriteBufferWatermarks() (uint32, uint32) {
+	return 0, 0
+}
+
+func (ci *mockClusterInfo) MethodStats() bool {
+	return false
+}
+
+func (ci *mockClusterInfo) KeepAlive() types.KeepAliveConfig {
+	return types.KeepAliveConfig{
+		Timeout:          types.DefaultKeepAliveTimeout,
+		FailCountToClose: types.DefaultKeepAliveFailCount,
+	}
+}
+
+func (ci *mockClusterInfo) OutlierDetector() types.OutlierDetector {
+	return nil
+}
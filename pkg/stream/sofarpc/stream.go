@@ -25,6 +25,7 @@ import (
 	"strconv"
 	"sync/atomic"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -79,7 +80,30 @@ func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connec
 	return newStreamConnection(context, connection, clientCallbacks, serverCallbacks)
 }
 
+// ProtocolMatch recognizes the bolt v1 and the bolt v2 by the protocol code in the first byte
+// bolt v1: proto(1) type(1)
+// bolt v2: proto(1) ver1(1) type(1)
 func (f *streamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	if len(magic) == 0 {
+		return str.EAGAIN
+	}
+	switch magic[0] {
+	case sofarpc.PROTOCOL_CODE_V1:
+		if len(magic) < 2 {
+			return str.EAGAIN
+		}
+		if magic[1] <= sofarpc.REQUEST_ONEWAY {
+			return nil
+		}
+	case sofarpc.PROTOCOL_CODE_V2:
+		if len(magic) < 3 {
+			return str.EAGAIN
+		}
+		if (magic[1] == sofarpc.PROTOCOL_VERSION_1 || magic[1] == sofarpc.PROTOCOL_VERSION_2) &&
+			magic[2] <= sofarpc.REQUEST_ONEWAY {
+			return nil
+		}
+	}
 	return str.FAILED
 }
 
@@ -92,11 +116,14 @@ type streamConnection struct {
 	conn                                types.Connection
 	contextManager                      *str.ContextManager
 	mutex                               sync.RWMutex
-	currStreamID                        uint64
+	currStreamID                        uint32             // the request id of the client streams, it is 32 bits on the wire
 	streams                             map[uint64]*stream // client conn fields
 	codecEngine                         types.ProtocolEngine
 	streamConnectionEventListener       types.StreamConnectionEventListener
 	serverStreamConnectionEventListener types.ServerStreamConnectionEventListener
+	heartbeatPassThrough                bool
+	upstreamProtocol                    byte   // the sub protocol of the upstream, 0 means same as the downstream
+	draining                            uint32 // 1 if the new requests are rejected, accessed atomically
 }
 
 func newStreamConnection(ctx context.Context, connection types.Connection, clientCallbacks types.StreamConnectionEventListener,
@@ -118,6 +145,17 @@ func newStreamConnection(ctx context.Context, connection types.Connection, clien
 		sc.streams = make(map[uint64]*stream, 32)
 	}
 
+	if config, ok := mosnctx.Get(ctx, types.ContextKeySofaRPCExtendConfig).(v2.SofaRPCExtendConfig); ok {
+		sc.heartbeatPassThrough = config.HeartbeatPassThrough
+		if config.UpstreamSubProtocol != "" {
+			if code, ok := sofarpc.SubProtocolCode(config.UpstreamSubProtocol); ok {
+				sc.upstreamProtocol = code
+			} else {
+				log.Proxy.Errorf(ctx, "[stream] [sofarpc] unknown upstream sub protocol: %s, ignored", config.UpstreamSubProtocol)
+			}
+		}
+	}
+
 	// set support transfer connection
 	sc.conn.SetTransferEventListener(func() bool {
 		return true
@@ -154,8 +192,10 @@ func (conn *streamConnection) Protocol() types.Protocol {
 	return protocol.SofaRPC
 }
 
+// GoAway rejects the new requests of the server connection, the requests in flight are served,
+// the connection is closed by the proxy once they are done
 func (conn *streamConnection) GoAway() {
-	// unsupported
+	atomic.StoreUint32(&conn.draining, 1)
 }
 
 func (conn *streamConnection) ActiveStreamsNum() int {
@@ -181,8 +221,9 @@ func (conn *streamConnection) NewStream(ctx context.Context, receiver types.Stre
 
 	//stream := &stream{}
 
-	stream.id = atomic.AddUint64(&conn.currStreamID, 1)
-	stream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, stream.id)
+	stream.id = conn.nextRequestID()
+	stream.streamID = protocol.GenerateID()
+	stream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, stream.streamID)
 	stream.direction = ClientStream
 	stream.sc = conn
 	stream.receiver = receiver
@@ -196,6 +237,15 @@ func (conn *streamConnection) NewStream(ctx context.Context, receiver types.Stre
 	return stream
 }
 
+// nextRequestID returns the request id of the client stream, the request id 0 is skipped when the counter wraps
+func (conn *streamConnection) nextRequestID() uint64 {
+	id := atomic.AddUint32(&conn.currStreamID, 1)
+	if id == 0 {
+		id = atomic.AddUint32(&conn.currStreamID, 1)
+	}
+	return uint64(id)
+}
+
 func (conn *streamConnection) handleCommand(ctx context.Context, model interface{}, err error) {
 	if err != nil {
 		conn.handleError(ctx, model, err)
@@ -209,21 +259,118 @@ func (conn *streamConnection) handleCommand(ctx context.Context, model interface
 		return
 	}
 
+	if conn.handleHeartbeat(ctx, cmd) {
+		return
+	}
+
+	if conn.rejectDraining(ctx, cmd) {
+		return
+	}
+
 	stream := conn.processStream(ctx, cmd)
 
 	// header, data notify
 	if stream != nil {
-		timeoutInt := cmd.GetTimeout()
-		timeout := strconv.Itoa(timeoutInt) // timeout, ms
-		cmd.Set(types.HeaderGlobalTimeout, timeout)
+		if log.PayloadDumpEnabled(stream.ctx) {
+			title := "[stream] [sofarpc] receive request"
+			if stream.direction == ClientStream {
+				title = "[stream] [sofarpc] receive response"
+			}
+			log.DumpPayload(stream.ctx, title, commandLine(cmd), cmd, payloadBytes(cmd))
+		}
+		// the bolt timeout is the global timeout of the proxy, whatever the upstream protocol is
+		if timeout := cmd.GetTimeout(); timeout > 0 {
+			cmd.Set(types.HeaderGlobalTimeout, strconv.Itoa(timeout)) // timeout, ms
+		}
 
 		stream.receiver.OnReceive(stream.ctx, cmd, cmd.Data(), nil)
 	}
 }
 
+// handleHeartbeat replies the heartbeat request from the downstream directly, without a proxy stream.
+// It returns false if the command should be processed as a normal request.
+func (conn *streamConnection) handleHeartbeat(ctx context.Context, cmd sofarpc.SofaRpcCmd) bool {
+	if conn.serverStreamConnectionEventListener == nil || conn.heartbeatPassThrough ||
+		cmd.CommandType() != sofarpc.REQUEST || cmd.CommandCode() != sofarpc.HEARTBEAT {
+		return false
+	}
+
+	ack := sofarpc.NewHeartbeatAck(cmd.ProtocolCode())
+	if ack == nil {
+		// no heartbeat builder for the sub protocol, let the proxy handle it
+		return false
+	}
+	replyTo(cmd, ack)
+
+	buf, err := conn.codecEngine.Encode(ctx, ack)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream] [sofarpc] heartbeat ack encode error: %v, requestId = %v", err, cmd.RequestID())
+		return true
+	}
+	if err := conn.conn.Write(buf); err != nil {
+		log.Proxy.Errorf(ctx, "[stream] [sofarpc] heartbeat ack write error: %v, requestId = %v", err, cmd.RequestID())
+		return true
+	}
+	str.RecordHeartbeat(conn.ctx)
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream] [sofarpc] reply heartbeat, requestId = %v", cmd.RequestID())
+	}
+	return true
+}
+
+// replyTo makes the response built by mosn reply the request
+func replyTo(request, response sofarpc.SofaRpcCmd) {
+	response.SetRequestID(request.RequestID())
+	// the bolt v2 response keeps the ver1 and the switch of the request
+	if req, ok := request.(*sofarpc.BoltRequestV2); ok {
+		if resp, ok := response.(*sofarpc.BoltResponseV2); ok {
+			resp.Version1 = req.Version1
+			resp.SwitchCode = req.SwitchCode
+		}
+	}
+}
+
+// rejectDraining replies the request received after the goaway with the server busy response, the client
+// retries it on another connection. It returns false if the command should be processed as a normal request.
+func (conn *streamConnection) rejectDraining(ctx context.Context, cmd sofarpc.SofaRpcCmd) bool {
+	if conn.serverStreamConnectionEventListener == nil || atomic.LoadUint32(&conn.draining) == 0 {
+		return false
+	}
+	switch cmd.CommandType() {
+	case sofarpc.REQUEST_ONEWAY:
+		return true
+	case sofarpc.REQUEST:
+	default:
+		return false
+	}
+
+	resp := sofarpc.NewResponse(cmd.ProtocolCode(), sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY)
+	if resp == nil {
+		// no response builder for the sub protocol, serve the request
+		return false
+	}
+	replyTo(cmd, resp)
+
+	buf, err := conn.codecEngine.Encode(ctx, resp)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream] [sofarpc] draining reply encode error: %v, requestId = %v", err, cmd.RequestID())
+		return true
+	}
+	if err := conn.conn.Write(buf); err != nil {
+		log.Proxy.Errorf(ctx, "[stream] [sofarpc] draining reply write error: %v, requestId = %v", err, cmd.RequestID())
+		return true
+	}
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream] [sofarpc] connection is draining, reject request, requestId = %v", cmd.RequestID())
+	}
+	return true
+}
+
 func (conn *streamConnection) handleError(ctx context.Context, cmd interface{}, err error) {
 	switch err {
-	case rpc.ErrUnrecognizedCode, sofarpc.ErrUnKnownCmdType, sofarpc.ErrUnKnownCmdCode, ErrNotSofarpcCmd:
+	case rpc.ErrUnrecognizedCode, sofarpc.ErrUnKnownCmdType, sofarpc.ErrUnKnownCmdCode, sofarpc.ErrCrcCheckFailed, ErrNotSofarpcCmd:
 		log.Proxy.Alertf(conn.ctx, types.ErrorKeyCodec, "error occurs while proceeding codec logic: %v. close connection", err)
 		//protocol decode error, close the connection directly
 		conn.conn.Close(types.NoFlush, types.LocalClose)
@@ -270,11 +417,19 @@ func (conn *streamConnection) onNewStreamDetect(ctx context.Context, cmd sofarpc
 
 	//stream := &stream{}
 	stream.id = cmd.RequestID()
-	stream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, stream.id)
-	stream.ctx = mosnctx.WithValue(ctx, types.ContextSubProtocol, cmd.ProtocolCode())
+	stream.streamID = protocol.GenerateID()
+	stream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, stream.streamID)
+	// the sub protocol in context selects the upstream connection, and the request is converted to it by the client stream
+	subProtocol := cmd.ProtocolCode()
+	if conn.upstreamProtocol != 0 {
+		subProtocol = conn.upstreamProtocol
+	}
+	stream.ctx = mosnctx.WithValue(stream.ctx, types.ContextSubProtocol, subProtocol)
 	stream.ctx = conn.contextManager.InjectTrace(stream.ctx, span)
+	stream.ctx = log.ContextWithLogger(stream.ctx, stream.streamID)
 	stream.direction = ServerStream
 	stream.sc = conn
+	stream.protocolCode = cmd.ProtocolCode()
 
 	if log.Proxy.GetLogLevel() >= log.INFO {
 		log.Proxy.Infof(stream.ctx, "[stream] [sofarpc] new stream detect, requestId = %v", stream.id)
@@ -321,11 +476,16 @@ type stream struct {
 	ctx context.Context
 	sc  *streamConnection
 
-	id        uint64
+	// id is the request id on the wire, the client streams are mapped by it
+	id uint64
+	// streamID is unique across the processes, it correlates the logs of the stream
+	streamID  uint64
 	direction StreamDirection // 0: out, 1: in
 	receiver  types.StreamReceiveListener
 	sendCmd   sofarpc.SofaRpcCmd
 	sendBuf   types.IoBuffer
+
+	protocolCode byte // the sub protocol of the downstream request, for server stream
 }
 
 // ~~ types.Stream
@@ -353,17 +513,27 @@ func (s *stream) AppendHeaders(ctx context.Context, headers types.HeaderMap, end
 
 	switch s.direction {
 	case ClientStream:
-		// use origin request from downstream
+		// use origin request from downstream, converted to the sub protocol of the upstream connection
+		cmd = sofarpc.ConvertCmd(cmd, getSubProtocol(s.ctx))
 		s.sendCmd = cmd
+		// map the trace context into the bolt header, so the trace is kept across the protocol conversion
+		if trace.IsEnabled() {
+			if span := trace.SpanFromContext(ctx); span != nil {
+				span.InjectContext(cmd)
+			}
+		}
 	case ServerStream:
 		switch cmd.CommandType() {
 		case sofarpc.RESPONSE:
-			// use origin response from upstream
-			s.sendCmd = cmd
+			// use origin response from upstream, converted back to the sub protocol of the request
+			s.sendCmd = sofarpc.ConvertCmd(cmd, s.protocolCode)
 		case sofarpc.REQUEST, sofarpc.REQUEST_ONEWAY:
 			// the command type is request, indicates the invocation is under hijack scene
 			s.sendCmd, err = s.buildHijackResp(cmd)
 		}
+		if code, e := protocol.MappingHeaderStatusCode(protocol.SofaRPC, s.sendCmd); e == nil {
+			str.RecordResponseCode(s.sc.ctx, code)
+		}
 	}
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
@@ -381,8 +551,15 @@ func (s *stream) buildHijackResp(request sofarpc.SofaRpcCmd) (sofarpc.SofaRpcCmd
 	if status, ok := request.Get(types.HeaderStatus); ok {
 		request.Del(types.HeaderStatus)
 		statusCode, _ := strconv.Atoi(status)
+		respStatus := sofarpc.MappingFromHttpStatus(statusCode)
+		if rpcStatus, ok := request.Get(types.HeaderRPCStatus); ok {
+			request.Del(types.HeaderRPCStatus)
+			if code, err := strconv.ParseInt(rpcStatus, 10, 16); err == nil {
+				respStatus = int16(code)
+			}
+		}
 
-		hijackResp := sofarpc.NewResponse(request.ProtocolCode(), sofarpc.MappingFromHttpStatus(statusCode))
+		hijackResp := sofarpc.NewResponse(request.ProtocolCode(), respStatus)
 		if hijackResp != nil {
 			return hijackResp, nil
 		}
@@ -431,15 +608,20 @@ func (s *stream) endStream() {
 		// remove the inject header
 		s.sendCmd.Del(types.HeaderGlobalTimeout)
 
+		if log.PayloadDumpEnabled(s.ctx) {
+			log.DumpPayload(s.ctx, "[stream] [sofarpc] send "+directionText[s.direction], commandLine(s.sendCmd), s.sendCmd, payloadBytes(s.sendCmd))
+		}
+
 		// TODO: replaced with EncodeTo, and pre-alloc send buf
 		buf, err := s.sc.codecEngine.Encode(s.ctx, s.sendCmd)
 		if err != nil {
-			log.Proxy.Errorf(s.ctx, "[stream] [sofarpc] %s encode error:%s", directionText[s.direction], err.Error())
+			log.ByContext(s.ctx).Errorf("[stream] [sofarpc] %s encode error:%s", directionText[s.direction], err.Error())
 			s.ResetStream(types.StreamLocalReset)
 			return
 		}
 
-		if dataBuf := s.sendCmd.Data(); dataBuf != nil {
+		// the content is encoded into the frame if the crc32 is enabled
+		if dataBuf := s.sendCmd.Data(); dataBuf != nil && !sofarpc.CrcEnabled(s.sendCmd) {
 			err = s.sc.conn.Write(buf, dataBuf)
 		} else {
 			err = s.sc.conn.Write(buf)
@@ -451,7 +633,7 @@ func (s *stream) endStream() {
 		}
 
 		if err != nil {
-			log.Proxy.Errorf(s.ctx, "[stream] [sofarpc] requestId = %v, error = %v", s.id, err)
+			log.ByContext(s.ctx).Errorf("[stream] [sofarpc] requestId = %v, error = %v", s.id, err)
 			if err == types.ErrConnectionHasClosed {
 				s.ResetStream(types.StreamConnectionFailed)
 			} else {
@@ -474,3 +656,19 @@ func (s *stream) ResetStream(reason types.StreamResetReason) {
 
 	s.BaseStream.ResetStream(reason)
 }
+
+// commandLine describes the command for the payload dump
+func commandLine(cmd sofarpc.SofaRpcCmd) string {
+	return "protocol=" + strconv.Itoa(int(cmd.ProtocolCode())) +
+		" type=" + strconv.Itoa(int(cmd.CommandType())) +
+		" code=" + strconv.Itoa(int(cmd.CommandCode())) +
+		" requestId=" + strconv.FormatUint(cmd.RequestID(), 10)
+}
+
+// payloadBytes returns the body of the command for the payload dump
+func payloadBytes(cmd sofarpc.SofaRpcCmd) []byte {
+	if data := cmd.Data(); data != nil {
+		return data.Bytes()
+	}
+	return nil
+}
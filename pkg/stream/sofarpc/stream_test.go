@@ -0,0 +1,321 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sofarpc
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockWriteConnection struct {
+	types.Connection
+	written []types.IoBuffer
+}
+
+func (c *mockWriteConnection) SetTransferEventListener(listener func() bool) {}
+
+func (c *mockWriteConnection) Write(buf ...types.IoBuffer) error {
+	c.written = append(c.written, buf...)
+	return nil
+}
+
+type mockServerStreamListener struct {
+	types.ServerStreamConnectionEventListener
+	received []types.HeaderMap
+	ctx      context.Context
+	sender   types.StreamSender
+}
+
+func (l *mockServerStreamListener) NewStreamDetect(ctx context.Context, sender types.StreamSender, span types.Span) types.StreamReceiveListener {
+	l.ctx = ctx
+	l.sender = sender
+	return l
+}
+
+func (l *mockServerStreamListener) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	l.received = append(l.received, headers)
+}
+
+func (l *mockServerStreamListener) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+func newHeartbeatBuffer(t *testing.T, requestID uint64) types.IoBuffer {
+	return newHeartbeatBufferWithProtocol(t, sofarpc.PROTOCOL_CODE_V1, requestID)
+}
+
+func newHeartbeatBufferWithProtocol(t *testing.T, proto byte, requestID uint64) types.IoBuffer {
+	hb := sofarpc.NewHeartbeat(proto)
+	hb.SetRequestID(requestID)
+	buf, err := sofarpc.Engine().Encode(context.Background(), hb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestServerStreamReplyHeartbeat(t *testing.T) {
+	stats := &types.ListenerStats{
+		DownstreamHeartbeatTotal: gometrics.NewCounter(),
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerStats, stats)
+	conn := &mockWriteConnection{}
+	listener := &mockServerStreamListener{}
+	sc := newStreamConnection(ctx, conn, nil, listener)
+	sc.Dispatch(newHeartbeatBuffer(t, 10))
+
+	if len(listener.received) != 0 {
+		t.Fatal("the heartbeat is passed to the proxy")
+	}
+	if len(conn.written) != 1 {
+		t.Fatalf("expected a heartbeat ack written, but got %d buffers", len(conn.written))
+	}
+	cmd, err := sofarpc.Engine().Decode(context.Background(), conn.written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, ok := cmd.(sofarpc.SofaRpcCmd)
+	if !ok || ack.CommandType() != sofarpc.RESPONSE || ack.CommandCode() != sofarpc.HEARTBEAT || ack.RequestID() != 10 {
+		t.Fatalf("unexpected heartbeat ack: %v", cmd)
+	}
+	if stats.DownstreamHeartbeatTotal.Count() != 1 {
+		t.Errorf("expected 1 heartbeat counted, but got %d", stats.DownstreamHeartbeatTotal.Count())
+	}
+}
+
+func TestServerStreamHeartbeatPassThrough(t *testing.T) {
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeySofaRPCExtendConfig, v2.SofaRPCExtendConfig{
+		HeartbeatPassThrough: true,
+	})
+	conn := &mockWriteConnection{}
+	listener := &mockServerStreamListener{}
+	sc := newStreamConnection(ctx, conn, nil, listener)
+	sc.Dispatch(newHeartbeatBuffer(t, 10))
+
+	if len(conn.written) != 0 {
+		t.Fatal("the heartbeat is replied by the stream in pass through mode")
+	}
+	if len(listener.received) != 1 {
+		t.Fatalf("expected the heartbeat passed to the proxy, but got %d", len(listener.received))
+	}
+	if cmd := listener.received[0].(sofarpc.SofaRpcCmd); cmd.CommandCode() != sofarpc.HEARTBEAT {
+		t.Errorf("unexpected command passed to the proxy: %v", cmd)
+	}
+}
+
+func TestServerStreamReplyHeartbeatV2(t *testing.T) {
+	conn := &mockWriteConnection{}
+	listener := &mockServerStreamListener{}
+	sc := newStreamConnection(context.Background(), conn, nil, listener)
+	sc.Dispatch(newHeartbeatBufferWithProtocol(t, sofarpc.PROTOCOL_CODE_V2, 10))
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected a heartbeat ack written, but got %d buffers", len(conn.written))
+	}
+	cmd, err := sofarpc.Engine().Decode(context.Background(), conn.written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, ok := cmd.(*sofarpc.BoltResponseV2)
+	if !ok || ack.CommandCode() != sofarpc.HEARTBEAT || ack.RequestID() != 10 || ack.Version1 != sofarpc.PROTOCOL_VERSION_1 {
+		t.Fatalf("unexpected heartbeat ack: %v", cmd)
+	}
+}
+
+func TestServerStreamRejectDraining(t *testing.T) {
+	conn := &mockWriteConnection{}
+	listener := &mockServerStreamListener{}
+	sc := newStreamConnection(context.Background(), conn, nil, listener)
+	sc.(types.ServerStreamConnection).GoAway()
+	req := &sofarpc.BoltRequest{
+		Protocol: sofarpc.PROTOCOL_CODE_V1,
+		CmdType:  sofarpc.REQUEST,
+		CmdCode:  sofarpc.RPC_REQUEST,
+		Version:  1,
+		ReqID:    10,
+		Codec:    sofarpc.HESSIAN2_SERIALIZE,
+		Timeout:  -1,
+	}
+	buf, err := sofarpc.Engine().Encode(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Dispatch(buf)
+	// the heartbeat is still replied
+	sc.Dispatch(newHeartbeatBuffer(t, 11))
+
+	if len(listener.received) != 0 {
+		t.Fatal("the request after the goaway is passed to the proxy")
+	}
+	if len(conn.written) != 2 {
+		t.Fatalf("expected a busy response and a heartbeat ack written, but got %d buffers", len(conn.written))
+	}
+	cmd, err := sofarpc.Engine().Decode(context.Background(), conn.written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := cmd.(*sofarpc.BoltResponse)
+	if !ok || resp.RequestID() != 10 || resp.RespStatus() != uint32(sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY) {
+		t.Fatalf("unexpected response: %v", cmd)
+	}
+}
+
+func TestProtocolMatch(t *testing.T) {
+	f := &streamConnFactory{}
+	for i, tc := range []struct {
+		magic    []byte
+		expected error
+	}{
+		{[]byte{}, str.EAGAIN},
+		{[]byte{sofarpc.PROTOCOL_CODE_V1}, str.EAGAIN},
+		{[]byte{sofarpc.PROTOCOL_CODE_V1, sofarpc.REQUEST}, nil},
+		{[]byte{sofarpc.PROTOCOL_CODE_V1, sofarpc.RESPONSE, 0x00}, nil},
+		{[]byte{sofarpc.PROTOCOL_CODE_V1, 0x10}, str.FAILED},
+		{[]byte{sofarpc.PROTOCOL_CODE_V2, sofarpc.PROTOCOL_VERSION_1}, str.EAGAIN},
+		{[]byte{sofarpc.PROTOCOL_CODE_V2, sofarpc.PROTOCOL_VERSION_1, sofarpc.REQUEST}, nil},
+		{[]byte{sofarpc.PROTOCOL_CODE_V2, sofarpc.PROTOCOL_VERSION_2, sofarpc.REQUEST_ONEWAY}, nil},
+		{[]byte{sofarpc.PROTOCOL_CODE_V2, 0x03, sofarpc.REQUEST}, str.FAILED},
+		{[]byte("GET / HTTP/1.1"), str.FAILED},
+	} {
+		if err := f.ProtocolMatch(context.Background(), "", tc.magic); err != tc.expected {
+			t.Errorf("#%d expected %v, but got %v", i, tc.expected, err)
+		}
+	}
+}
+
+func TestServerStreamUpstreamSubProtocol(t *testing.T) {
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeySofaRPCExtendConfig, v2.SofaRPCExtendConfig{
+		UpstreamSubProtocol: sofarpc.SubProtocolBoltV2,
+	})
+	conn := &mockWriteConnection{}
+	listener := &mockServerStreamListener{}
+	sc := newStreamConnection(ctx, conn, nil, listener)
+	req := &sofarpc.BoltRequest{
+		Protocol: sofarpc.PROTOCOL_CODE_V1,
+		CmdType:  sofarpc.REQUEST,
+		CmdCode:  sofarpc.RPC_REQUEST,
+		Version:  1,
+		ReqID:    10,
+		Codec:    sofarpc.HESSIAN2_SERIALIZE,
+		Timeout:  -1,
+	}
+	buf, err := sofarpc.Engine().Encode(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Dispatch(buf)
+	if len(listener.received) != 1 || listener.sender == nil {
+		t.Fatalf("expected the request passed to the proxy, but got %d", len(listener.received))
+	}
+	// the upstream connection is selected by the sub protocol
+	if sub := getSubProtocol(listener.ctx); sub != sofarpc.PROTOCOL_CODE_V2 {
+		t.Fatalf("expected upstream sub protocol boltv2, but got %d", sub)
+	}
+	// the request is converted to the upstream sub protocol
+	if cmd := sofarpc.ConvertCmd(listener.received[0].(sofarpc.SofaRpcCmd), getSubProtocol(listener.ctx)); cmd.ProtocolCode() != sofarpc.PROTOCOL_CODE_V2 {
+		t.Fatalf("expected request converted to boltv2, but got: %v", cmd)
+	}
+	// the bolt v2 response from the upstream is converted back to bolt v1
+	resp := sofarpc.NewResponse(sofarpc.PROTOCOL_CODE_V2, sofarpc.RESPONSE_STATUS_SUCCESS)
+	if err := listener.sender.AppendHeaders(context.Background(), resp, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.written) != 1 {
+		t.Fatalf("expected a response written, but got %d buffers", len(conn.written))
+	}
+	cmd, err := sofarpc.Engine().Decode(context.Background(), conn.written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack, ok := cmd.(*sofarpc.BoltResponse); !ok || ack.RequestID() != 10 || ack.ProtocolCode() != sofarpc.PROTOCOL_CODE_V1 {
+		t.Fatalf("unexpected response: %v", cmd)
+	}
+}
+
+type mockClientStreamListener struct{}
+
+func (l *mockClientStreamListener) OnGoAway() {}
+
+func TestStreamIDCorrelation(t *testing.T) {
+	// the server streams of the same request id on different connections get different stream ids
+	var ids []uint64
+	for i := 0; i < 2; i++ {
+		conn := &mockWriteConnection{}
+		listener := &mockServerStreamListener{}
+		sc := newStreamConnection(context.Background(), conn, nil, listener)
+		req := &sofarpc.BoltRequest{
+			Protocol: sofarpc.PROTOCOL_CODE_V1,
+			CmdType:  sofarpc.REQUEST,
+			CmdCode:  sofarpc.RPC_REQUEST,
+			Version:  1,
+			ReqID:    10,
+			Codec:    sofarpc.HESSIAN2_SERIALIZE,
+			Timeout:  -1,
+		}
+		buf, err := sofarpc.Engine().Encode(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc.Dispatch(buf)
+		if listener.sender == nil {
+			t.Fatal("expected the request passed to the proxy")
+		}
+		id, ok := protocol.StreamIDByContext(listener.ctx)
+		if !ok || id == 10 {
+			t.Fatalf("expected the generated stream id in the context, but got %d", id)
+		}
+		if listener.sender.GetStream().ID() != 10 {
+			t.Fatalf("expected the stream mapped by the request id 10, but got %d", listener.sender.GetStream().ID())
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] == ids[1] {
+		t.Fatalf("the stream ids collide: %v", ids)
+	}
+
+	// the client streams are mapped by the request id on the wire, which skips 0 when it wraps
+	conn := &mockWriteConnection{}
+	sc := newStreamConnection(context.Background(), conn, &mockClientStreamListener{}, nil).(*streamConnection)
+	sc.currStreamID = math.MaxUint32
+	receiver := &mockServerStreamListener{}
+	sender := sc.NewStream(context.Background(), receiver)
+	if id := sender.GetStream().ID(); id != 1 {
+		t.Fatalf("expected request id 1 after the counter wraps, but got %d", id)
+	}
+	resp := sofarpc.NewResponse(sofarpc.PROTOCOL_CODE_V1, sofarpc.RESPONSE_STATUS_SUCCESS)
+	resp.SetRequestID(1)
+	buf, err := sofarpc.Engine().Encode(context.Background(), resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Dispatch(buf)
+	if len(receiver.received) != 1 {
+		t.Fatal("expected the response received by the client stream")
+	}
+	if sc.ActiveStreamsNum() != 0 {
+		t.Fatalf("expected the client stream removed, but got %d streams", sc.ActiveStreamsNum())
+	}
+}
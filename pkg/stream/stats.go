@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stream
+
+import (
+	"context"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// RecordResponseCode counts the response code class in the listener stats of the context,
+// it is called by the server streams when the response headers are sent
+func RecordResponseCode(ctx context.Context, code int) {
+	stats, ok := mosnctx.Get(ctx, types.ContextKeyListenerStats).(*types.ListenerStats)
+	if !ok || stats == nil {
+		return
+	}
+	switch code / 100 {
+	case 1:
+		stats.DownstreamResponseCode1xx.Inc(1)
+	case 2:
+		stats.DownstreamResponseCode2xx.Inc(1)
+	case 3:
+		stats.DownstreamResponseCode3xx.Inc(1)
+	case 4:
+		stats.DownstreamResponseCode4xx.Inc(1)
+	case 5:
+		stats.DownstreamResponseCode5xx.Inc(1)
+	}
+}
+
+// RecordHeartbeat counts the heartbeat in the listener stats of the context,
+// it is called by the server streams that reply the heartbeats by themselves
+func RecordHeartbeat(ctx context.Context) {
+	stats, ok := mosnctx.Get(ctx, types.ContextKeyListenerStats).(*types.ListenerStats)
+	if !ok || stats == nil {
+		return
+	}
+	stats.DownstreamHeartbeatTotal.Inc(1)
+}
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stream
+
+import (
+	"context"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestRecordResponseCode(t *testing.T) {
+	stats := &types.ListenerStats{
+		DownstreamResponseCode1xx: gometrics.NewCounter(),
+		DownstreamResponseCode2xx: gometrics.NewCounter(),
+		DownstreamResponseCode3xx: gometrics.NewCounter(),
+		DownstreamResponseCode4xx: gometrics.NewCounter(),
+		DownstreamResponseCode5xx: gometrics.NewCounter(),
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerStats, stats)
+	for _, code := range []int{100, 200, 204, 302, 404, 502, 504, 600} {
+		RecordResponseCode(ctx, code)
+	}
+	for i, c := range []gometrics.Counter{
+		stats.DownstreamResponseCode1xx,
+		stats.DownstreamResponseCode2xx,
+		stats.DownstreamResponseCode3xx,
+		stats.DownstreamResponseCode4xx,
+		stats.DownstreamResponseCode5xx,
+	} {
+		expected := []int64{1, 2, 1, 1, 2}[i]
+		if c.Count() != expected {
+			t.Errorf("expected %d responses of %dxx, but got %d", expected, i+1, c.Count())
+		}
+	}
+	// no listener stats in the context
+	RecordResponseCode(context.Background(), 200)
+}
@@ -15,6 +15,8 @@ const (
 
 type BaseStream struct {
 	sync.Mutex
+	// streamListeners is copied on write, so the listeners are notified without holding the lock,
+	// and a listener can add or remove listeners in the notification
 	streamListeners []types.StreamEventListener
 
 	state uint32
@@ -22,7 +24,9 @@ type BaseStream struct {
 
 func (s *BaseStream) AddEventListener(streamCb types.StreamEventListener) {
 	s.Lock()
-	s.streamListeners = append(s.streamListeners, streamCb)
+	listeners := make([]types.StreamEventListener, len(s.streamListeners), len(s.streamListeners)+1)
+	copy(listeners, s.streamListeners)
+	s.streamListeners = append(listeners, streamCb)
 	s.Unlock()
 }
 
@@ -39,19 +43,27 @@ func (s *BaseStream) RemoveEventListener(streamCb types.StreamEventListener) {
 	}
 
 	if cbIdx > -1 {
-		s.streamListeners = append(s.streamListeners[:cbIdx], s.streamListeners[cbIdx+1:]...)
+		listeners := make([]types.StreamEventListener, 0, len(s.streamListeners)-1)
+		listeners = append(listeners, s.streamListeners[:cbIdx]...)
+		s.streamListeners = append(listeners, s.streamListeners[cbIdx+1:]...)
 	}
 }
 
+// listeners returns the listeners registered now, the listeners added or removed
+// in the notification take effect in the next notification
+func (s *BaseStream) listeners() []types.StreamEventListener {
+	s.Lock()
+	defer s.Unlock()
+	return s.streamListeners
+}
+
 func (s *BaseStream) ResetStream(reason types.StreamResetReason) {
 	if atomic.LoadUint32(&s.state) != streamStateReset {
 		return
 	}
 	defer s.DestroyStream()
-	s.Lock()
-	defer s.Unlock()
 
-	for _, listener := range s.streamListeners {
+	for _, listener := range s.listeners() {
 		listener.OnResetStream(reason)
 	}
 }
@@ -60,10 +72,28 @@ func (s *BaseStream) DestroyStream() {
 	if !atomic.CompareAndSwapUint32(&s.state, streamStateReset, streamStateDestroying) {
 		return
 	}
-	s.Lock()
-	defer s.Unlock()
-	for _, listener := range s.streamListeners {
+	for _, listener := range s.listeners() {
 		listener.OnDestroyStream()
 	}
 	atomic.StoreUint32(&s.state, streamStateDestroyed)
 }
+
+// AboveWriteBufferHighWatermark notifies the listeners implementing types.StreamWatermarkListener
+// that the write buffer of the connection is backed up
+func (s *BaseStream) AboveWriteBufferHighWatermark() {
+	for _, listener := range s.listeners() {
+		if wl, ok := listener.(types.StreamWatermarkListener); ok {
+			wl.OnAboveWriteBufferHighWatermark()
+		}
+	}
+}
+
+// BelowWriteBufferLowWatermark notifies the listeners implementing types.StreamWatermarkListener
+// that the write buffer of the connection is drained
+func (s *BaseStream) BelowWriteBufferLowWatermark() {
+	for _, listener := range s.listeners() {
+		if wl, ok := listener.(types.StreamWatermarkListener); ok {
+			wl.OnBelowWriteBufferLowWatermark()
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/types"
 	"testing"
 	"errors"
+	"time"
 )
 
 type event struct {
@@ -59,3 +60,82 @@ func TestStreamEventListener(t *testing.T) {
 		base.ResetStream(types.StreamLocalReset)
 	}
 }
+
+// recordListener records the notifications, and runs the hook on reset
+type recordListener struct {
+	name    string
+	records *[]string
+	onReset func()
+}
+
+func (l *recordListener) OnResetStream(reason types.StreamResetReason) {
+	*l.records = append(*l.records, "reset "+l.name)
+	if l.onReset != nil {
+		l.onReset()
+	}
+}
+
+func (l *recordListener) OnDestroyStream() {
+	*l.records = append(*l.records, "destroy "+l.name)
+}
+
+func expectRecords(t *testing.T, got []string, expected ...string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v, but got %v", expected, got)
+		}
+	}
+}
+
+func TestRemoveEventListener(t *testing.T) {
+	var records []string
+	var base BaseStream
+	a := &recordListener{name: "a", records: &records}
+	b := &recordListener{name: "b", records: &records}
+	c := &recordListener{name: "c", records: &records}
+	base.AddEventListener(a)
+	base.AddEventListener(b)
+	base.AddEventListener(c)
+
+	// only the listener requested is removed
+	base.RemoveEventListener(b)
+	// the listener not registered is ignored
+	base.RemoveEventListener(&recordListener{name: "d", records: &records})
+
+	base.ResetStream(types.StreamLocalReset)
+	expectRecords(t, records, "reset a", "reset c", "destroy a", "destroy c")
+}
+
+func TestRemoveEventListenerOnReset(t *testing.T) {
+	var records []string
+	var base BaseStream
+	a := &recordListener{name: "a", records: &records}
+	b := &recordListener{name: "b", records: &records}
+	c := &recordListener{name: "c", records: &records}
+	// a removes itself and b in the notification
+	a.onReset = func() {
+		base.RemoveEventListener(a)
+		base.RemoveEventListener(b)
+	}
+	base.AddEventListener(a)
+	base.AddEventListener(b)
+	base.AddEventListener(c)
+
+	done := make(chan struct{})
+	go func() {
+		base.ResetStream(types.StreamLocalReset)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("removing listener on reset is blocked")
+	}
+	// the reset notification goes to the listeners registered when the stream is reset,
+	// the removal takes effect from the destroy notification
+	expectRecords(t, records, "reset a", "reset b", "reset c", "destroy c")
+}
@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// ResetError is returned by the calls if the stream is reset before the response is received
+type ResetError struct {
+	Reason types.StreamResetReason
+}
+
+func (e *ResetError) Error() string {
+	return fmt.Sprintf("stream reset: %s", e.Reason)
+}
+
+// SyncClient sends the requests by a Client and waits for the responses,
+// it only uses the Client, StreamSender and StreamReceiveListener, so it works for any registered protocol
+type SyncClient struct {
+	Client Client
+}
+
+// NewSyncClient wraps the client, the client should be connected before any calls
+func NewSyncClient(client Client) *SyncClient {
+	return &SyncClient{
+		Client: client,
+	}
+}
+
+// Call sends the request and blocks until the response is received, the stream is reset
+// if the ctx is done before the response.
+// If the body is nil, the request is header only.
+func (c *SyncClient) Call(ctx context.Context, headers types.HeaderMap, body types.IoBuffer) (types.HeaderMap, types.IoBuffer, error) {
+	return c.AsyncCall(ctx, headers, body).Get(ctx)
+}
+
+// AsyncCall sends the request and returns a Future of the response without blocking
+func (c *SyncClient) AsyncCall(ctx context.Context, headers types.HeaderMap, body types.IoBuffer) *Future {
+	f := &Future{
+		done: make(chan struct{}),
+	}
+	sender := c.Client.NewStream(ctx, f)
+	if sender == nil {
+		f.complete(nil, nil, errors.New("create stream failed"))
+		return f
+	}
+	f.stream = sender.GetStream()
+	f.stream.AddEventListener(f)
+
+	endStream := body == nil
+	err := sender.AppendHeaders(ctx, headers, endStream)
+	if err == nil && !endStream {
+		err = sender.AppendData(ctx, body, true)
+	}
+	if err != nil {
+		f.complete(nil, nil, err)
+	}
+	return f
+}
+
+// Future is the pending response of an AsyncCall
+// types.StreamReceiveListener
+// types.StreamEventListener
+type Future struct {
+	stream types.Stream
+	once   sync.Once
+	done   chan struct{}
+
+	headers types.HeaderMap
+	data    types.IoBuffer
+	err     error
+}
+
+// Done is closed when the response is received or the call failed
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get blocks until the response is received, the stream is reset if the ctx is done before the response.
+func (f *Future) Get(ctx context.Context) (types.HeaderMap, types.IoBuffer, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		if f.complete(nil, nil, ctx.Err()) && f.stream != nil {
+			f.stream.ResetStream(types.StreamLocalReset)
+		}
+		<-f.done
+	}
+	return f.headers, f.data, f.err
+}
+
+// complete sets the result once, returns false if the future is already completed
+func (f *Future) complete(headers types.HeaderMap, data types.IoBuffer, err error) bool {
+	completed := false
+	f.once.Do(func() {
+		f.headers = headers
+		f.data = data
+		f.err = err
+		completed = true
+		close(f.done)
+	})
+	return completed
+}
+
+func (f *Future) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	f.complete(headers, data, nil)
+}
+
+func (f *Future) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+	f.complete(headers, nil, err)
+}
+
+func (f *Future) OnResetStream(reason types.StreamResetReason) {
+	f.complete(nil, nil, &ResetError{Reason: reason})
+}
+
+func (f *Future) OnDestroyStream() {}
@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc/codec"
+	"sofastack.io/sofa-mosn/pkg/stream"
+	_ "sofastack.io/sofa-mosn/pkg/stream/http"
+	_ "sofastack.io/sofa-mosn/pkg/stream/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/test/util"
+)
+
+func newSyncClient(t *testing.T, addr string, prot types.Protocol) *stream.SyncClient {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	conn := network.NewClientConnection(nil, 0, nil, remoteAddr, make(chan struct{}))
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("connect to %s failed: %v", addr, err)
+	}
+	client := stream.NewStreamClient(context.Background(), prot, conn, nil)
+	if client == nil {
+		t.Fatalf("protocol %s is not registered", prot)
+	}
+	return stream.NewSyncClient(client)
+}
+
+func TestSyncClientHTTP1(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Write(body)
+	}))
+	defer server.Close()
+	client := newSyncClient(t, server.Listener.Addr().String(), protocol.HTTP1)
+	defer client.Client.Close()
+
+	for i := 0; i < 3; i++ {
+		headers := mosnhttp.RequestHeader{
+			RequestHeader: &fasthttp.RequestHeader{},
+		}
+		headers.Set(protocol.MosnHeaderMethod, http.MethodPost)
+		headers.Set(protocol.MosnHeaderPathKey, "/echo")
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		respHeaders, body, err := client.Call(ctx, headers, buffer.NewIoBufferString("hello"))
+		cancel()
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		resp, ok := respHeaders.(mosnhttp.ResponseHeader)
+		if !ok || resp.StatusCode() != http.StatusOK {
+			t.Fatalf("unexpected response headers: %v", respHeaders)
+		}
+		if v, _ := resp.Get("X-Method"); v != http.MethodPost {
+			t.Fatalf("unexpected method: %s", v)
+		}
+		if body == nil || body.String() != "hello" {
+			t.Fatalf("unexpected response body: %v", body)
+		}
+	}
+}
+
+func TestSyncClientSofaRPC(t *testing.T) {
+	server := util.NewRPCServer(t, "127.0.0.1:12345", util.Bolt1)
+	server.GoServe()
+	defer server.Close()
+	time.Sleep(100 * time.Millisecond)
+	client := newSyncClient(t, server.Addr(), protocol.SofaRPC)
+	defer client.Client.Close()
+
+	var futures []*stream.Future
+	for i := 1; i <= 3; i++ {
+		futures = append(futures, client.AsyncCall(context.Background(), util.BuildBoltV1Request(uint64(i)), nil))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for i, f := range futures {
+		headers, _, err := f.Get(ctx)
+		if err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+		resp, ok := headers.(*sofarpc.BoltResponse)
+		if !ok || resp.ResponseStatus != sofarpc.RESPONSE_STATUS_SUCCESS {
+			t.Fatalf("unexpected response: %v", headers)
+		}
+	}
+}
+
+func TestSyncClientTimeout(t *testing.T) {
+	// the server reads the requests but never responds
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go ioutil.ReadAll(conn)
+		}
+	}()
+	client := newSyncClient(t, ln.Addr().String(), protocol.SofaRPC)
+	defer client.Client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = client.Call(ctx, util.BuildBoltV1Request(1), nil)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, but got %v", err)
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Fatalf("call returns too late: %v", cost)
+	}
+
+	// the connection close resets the pending streams
+	f := client.AsyncCall(context.Background(), util.BuildBoltV1Request(2), nil)
+	client.Client.Close()
+	select {
+	case <-f.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the pending call is not done after the connection closed")
+	}
+	if _, _, err := f.Get(context.Background()); err == nil {
+		t.Fatal("expected the call failed")
+	} else if _, ok := err.(*stream.ResetError); !ok {
+		t.Fatalf("expected reset error, but got %v", err)
+	}
+}
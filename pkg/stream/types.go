@@ -46,6 +46,8 @@ type Client interface {
 
 	SetStreamConnectionEventListener(listener types.StreamConnectionEventListener)
 
+	GoAway()
+
 	Close()
 }
 
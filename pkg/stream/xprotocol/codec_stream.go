@@ -0,0 +1,346 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	ErrNotXFrame = errors.New("not xprotocol frame")
+)
+
+// codecStreamConnFactory creates the stream connections driven by a registered XProtocol
+type codecStreamConnFactory struct {
+	codec xprotocol.XProtocol
+}
+
+func (f *codecStreamConnFactory) CreateClientStream(context context.Context, connection types.ClientConnection,
+	clientCallbacks types.StreamConnectionEventListener, connCallbacks types.ConnectionEventListener) types.ClientStreamConnection {
+	return newCodecStreamConnection(context, f.codec, connection, clientCallbacks, nil)
+}
+
+func (f *codecStreamConnFactory) CreateServerStream(context context.Context, connection types.Connection,
+	serverCallbacks types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+	return newCodecStreamConnection(context, f.codec, connection, nil, serverCallbacks)
+}
+
+func (f *codecStreamConnFactory) CreateBiDirectStream(context context.Context, connection types.ClientConnection,
+	clientCallbacks types.StreamConnectionEventListener,
+	serverCallbacks types.ServerStreamConnectionEventListener) types.ClientStreamConnection {
+	return newCodecStreamConnection(context, f.codec, connection, clientCallbacks, serverCallbacks)
+}
+
+func (f *codecStreamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	return str.FAILED
+}
+
+// types.StreamConnection
+// types.ClientStreamConnection
+// types.ServerStreamConnection
+type codecStreamConnection struct {
+	ctx                                 context.Context
+	conn                                types.Connection
+	codec                               xprotocol.XProtocol
+	mutex                               sync.Mutex
+	currStreamID                        uint64
+	streams                             map[uint64]*codecStream // client conn fields
+	streamConnectionEventListener       types.StreamConnectionEventListener
+	serverStreamConnectionEventListener types.ServerStreamConnectionEventListener
+}
+
+func newCodecStreamConnection(ctx context.Context, codec xprotocol.XProtocol, connection types.Connection,
+	clientCallbacks types.StreamConnectionEventListener,
+	serverCallbacks types.ServerStreamConnectionEventListener) types.ClientStreamConnection {
+
+	sc := &codecStreamConnection{
+		ctx:                                 ctx,
+		conn:                                connection,
+		codec:                               codec,
+		streamConnectionEventListener:       clientCallbacks,
+		serverStreamConnectionEventListener: serverCallbacks,
+	}
+	if sc.streamConnectionEventListener != nil {
+		sc.streams = make(map[uint64]*codecStream, 32)
+	}
+	return sc
+}
+
+// Dispatch decodes the frames as many as possible,
+// the requests are passed to the proxy as the new streams, and the responses are passed to the client streams
+func (conn *codecStreamConnection) Dispatch(buf types.IoBuffer) {
+	for {
+		frame, err := conn.codec.Decode(conn.ctx, buf)
+		if err != nil {
+			log.Proxy.Alertf(conn.ctx, types.ErrorKeyCodec, "[stream] [xprotocol] %s decode error: %v. close connection", conn.codec.Name(), err)
+			// protocol decode error, close the connection directly
+			conn.conn.Close(types.NoFlush, types.LocalClose)
+			return
+		}
+		// no enough data
+		if frame == nil {
+			return
+		}
+		conn.handleFrame(frame)
+	}
+}
+
+func (conn *codecStreamConnection) handleFrame(frame xprotocol.XFrame) {
+	if !frame.IsRequest() {
+		conn.onStreamRecv(frame)
+		return
+	}
+	if conn.serverStreamConnectionEventListener == nil {
+		log.Proxy.Errorf(conn.ctx, "[stream] [xprotocol] %s receive request on the client connection, requestId = %v", conn.codec.Name(), frame.GetRequestId())
+		return
+	}
+	if frame.IsHeartbeat() && conn.handleHeartbeat(frame) {
+		return
+	}
+	conn.onNewStreamDetect(frame)
+}
+
+// handleHeartbeat replies the heartbeat request from the downstream directly, without a proxy stream.
+// It returns false if the heartbeat should be processed as a normal request.
+func (conn *codecStreamConnection) handleHeartbeat(frame xprotocol.XFrame) bool {
+	ack := conn.codec.Reply(frame)
+	if ack == nil {
+		return false
+	}
+	ack.SetRequestId(frame.GetRequestId())
+
+	buf, err := conn.codec.Encode(conn.ctx, ack)
+	if err != nil {
+		log.Proxy.Errorf(conn.ctx, "[stream] [xprotocol] heartbeat ack encode error: %v, requestId = %v", err, frame.GetRequestId())
+		return true
+	}
+	if err := conn.conn.Write(buf); err != nil {
+		log.Proxy.Errorf(conn.ctx, "[stream] [xprotocol] heartbeat ack write error: %v, requestId = %v", err, frame.GetRequestId())
+		return true
+	}
+	str.RecordHeartbeat(conn.ctx)
+	return true
+}
+
+func (conn *codecStreamConnection) onNewStreamDetect(frame xprotocol.XFrame) {
+	stream := &codecStream{
+		id:        frame.GetRequestId(),
+		direction: ServerStream,
+		sc:        conn,
+	}
+	stream.ctx = mosnctx.WithValue(conn.ctx, types.ContextKeyStreamID, stream.id)
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "[stream] [xprotocol] new stream detect, requestId = %v", stream.id)
+	}
+	stream.receiver = conn.serverStreamConnectionEventListener.NewStreamDetect(stream.ctx, stream, nil)
+	stream.receiver.OnReceive(stream.ctx, frame, frame.GetData(), nil)
+}
+
+func (conn *codecStreamConnection) onStreamRecv(frame xprotocol.XFrame) {
+	requestID := frame.GetRequestId()
+
+	// for client stream, remove stream on response read
+	conn.mutex.Lock()
+	stream, ok := conn.streams[requestID]
+	if ok {
+		delete(conn.streams, requestID)
+	}
+	conn.mutex.Unlock()
+
+	if !ok {
+		log.Proxy.Errorf(conn.ctx, "[stream] [xprotocol] no stream found for the response, requestId = %v", requestID)
+		return
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "[stream] [xprotocol] receive response, requestId = %v", requestID)
+	}
+	stream.receiver.OnReceive(stream.ctx, frame, frame.GetData(), nil)
+}
+
+func (conn *codecStreamConnection) Protocol() types.Protocol {
+	return conn.codec.Name()
+}
+
+func (conn *codecStreamConnection) GoAway() {
+	// unsupported
+}
+
+func (conn *codecStreamConnection) ActiveStreamsNum() int {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	return len(conn.streams)
+}
+
+func (conn *codecStreamConnection) Reset(reason types.StreamResetReason) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	for _, stream := range conn.streams {
+		stream.connReset = true
+		stream.ResetStream(reason)
+	}
+}
+
+func (conn *codecStreamConnection) NewStream(ctx context.Context, receiver types.StreamReceiveListener) types.StreamSender {
+	stream := &codecStream{
+		id:        atomic.AddUint64(&conn.currStreamID, 1),
+		direction: ClientStream,
+		sc:        conn,
+		receiver:  receiver,
+	}
+	stream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, stream.id)
+
+	if stream.receiver != nil {
+		conn.mutex.Lock()
+		conn.streams[stream.id] = stream
+		conn.mutex.Unlock()
+	}
+	return stream
+}
+
+// types.Stream
+// types.StreamSender
+type codecStream struct {
+	str.BaseStream
+
+	ctx       context.Context
+	sc        *codecStreamConnection
+	id        uint64
+	direction StreamDirection
+	receiver  types.StreamReceiveListener
+	sendFrame xprotocol.XFrame
+	connReset bool // the stream is reset by the connection, which removes the streams itself
+}
+
+func (s *codecStream) ID() uint64 {
+	return s.id
+}
+
+func (s *codecStream) ReadDisable(disable bool) {
+	s.sc.conn.SetReadDisable(disable)
+}
+
+func (s *codecStream) BufferLimit() uint32 {
+	return s.sc.conn.BufferLimit()
+}
+
+func (s *codecStream) AppendHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) error {
+	frame, ok := headers.(xprotocol.XFrame)
+	if !ok {
+		return ErrNotXFrame
+	}
+
+	var err error
+	s.sendFrame = frame
+	// the request is sent by the server stream, indicates the invocation is under hijack scene
+	if s.direction == ServerStream && frame.IsRequest() {
+		s.sendFrame, err = s.buildHijackResp(frame)
+	}
+
+	if endStream {
+		s.endStream()
+	}
+	return err
+}
+
+func (s *codecStream) buildHijackResp(request xprotocol.XFrame) (xprotocol.XFrame, error) {
+	hijacker, ok := s.sc.codec.(xprotocol.Hijacker)
+	if !ok {
+		return nil, types.ErrNoStatusCodeForHijack
+	}
+	if status, ok := request.Get(types.HeaderStatus); ok {
+		request.Del(types.HeaderStatus)
+		statusCode, _ := strconv.Atoi(status)
+		return hijacker.Hijack(request, statusCode), nil
+	}
+	return nil, types.ErrNoStatusCodeForHijack
+}
+
+func (s *codecStream) AppendData(context context.Context, data types.IoBuffer, endStream bool) error {
+	if s.sendFrame != nil {
+		s.sendFrame.SetData(data)
+	}
+
+	if endStream {
+		s.endStream()
+	}
+	return nil
+}
+
+func (s *codecStream) AppendTrailers(context context.Context, trailers types.HeaderMap) error {
+	s.endStream()
+	return nil
+}
+
+// endStream writes out the response for server stream, and the request for client stream
+func (s *codecStream) endStream() {
+	defer func() {
+		if s.direction == ServerStream {
+			s.DestroyStream()
+		}
+	}()
+
+	if s.sendFrame == nil {
+		return
+	}
+	// replace the request id
+	s.sendFrame.SetRequestId(s.id)
+
+	buf, err := s.sc.codec.Encode(s.ctx, s.sendFrame)
+	if err != nil {
+		log.Proxy.Errorf(s.ctx, "[stream] [xprotocol] %s encode error: %v, requestId = %v", s.sc.codec.Name(), err, s.id)
+		s.ResetStream(types.StreamLocalReset)
+		return
+	}
+
+	if err := s.sc.conn.Write(buf); err != nil {
+		log.Proxy.Errorf(s.ctx, "[stream] [xprotocol] write error: %v, requestId = %v", err, s.id)
+		if err == types.ErrConnectionHasClosed {
+			s.ResetStream(types.StreamConnectionFailed)
+		} else {
+			s.ResetStream(types.StreamLocalReset)
+		}
+	}
+}
+
+// ResetStream removes the client stream from the connection, so the stream reset or timeout is not counted as active
+func (s *codecStream) ResetStream(reason types.StreamResetReason) {
+	if s.direction == ClientStream && !s.connReset {
+		s.sc.mutex.Lock()
+		delete(s.sc.streams, s.id)
+		s.sc.mutex.Unlock()
+	}
+
+	s.BaseStream.ResetStream(reason)
+}
+
+func (s *codecStream) GetStream() types.Stream {
+	return s
+}
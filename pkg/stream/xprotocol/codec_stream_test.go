@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockCodec struct {
+	xprotocol.XProtocol
+}
+
+func (c *mockCodec) Name() types.Protocol {
+	return "mock_codec"
+}
+
+type mockClientStreamListener struct{}
+
+func (l *mockClientStreamListener) OnGoAway() {}
+
+type mockResponseListener struct{}
+
+func (l *mockResponseListener) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+}
+
+func (l *mockResponseListener) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+func TestCodecStreamReset(t *testing.T) {
+	sc := newCodecStreamConnection(context.Background(), &mockCodec{}, nil, &mockClientStreamListener{}, nil)
+	s1 := sc.NewStream(context.Background(), &mockResponseListener{})
+	sc.NewStream(context.Background(), &mockResponseListener{})
+	// the oneway stream is not active
+	sc.NewStream(context.Background(), nil)
+	if n := sc.ActiveStreamsNum(); n != 2 {
+		t.Fatalf("expected 2 active streams, but got %d", n)
+	}
+	// a reset or timeout stream is removed
+	s1.GetStream().ResetStream(types.StreamLocalReset)
+	if n := sc.ActiveStreamsNum(); n != 1 {
+		t.Fatalf("expected 1 active stream after reset, but got %d", n)
+	}
+	// the streams reset by the connection do not lock the connection again
+	sc.Reset(types.StreamConnectionTermination)
+}
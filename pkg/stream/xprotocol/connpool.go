@@ -19,8 +19,8 @@ package xprotocol
 
 import (
 	"context"
+	"fmt"
 	"sync"
-
 	"sync/atomic"
 
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -39,14 +39,27 @@ func init() {
 type connPool struct {
 	primaryClient  *activeClient
 	drainingClient *activeClient
+	closed         bool // no new stream is accepted after closed
 	mux            sync.Mutex
 	host           types.Host
+	protocol       types.Protocol
 }
 
 // NewConnPool for xprotocol upstream host
 func NewConnPool(host types.Host) types.ConnectionPool {
 	return &connPool{
-		host: host,
+		host:     host,
+		protocol: protocol.Xprotocol,
+	}
+}
+
+// newConnPoolFactory returns the connection pool factory of the registered XProtocol
+func newConnPoolFactory(proto types.Protocol) func(host types.Host) types.ConnectionPool {
+	return func(host types.Host) types.ConnectionPool {
+		return &connPool{
+			host:     host,
+			protocol: proto,
+		}
 	}
 }
 
@@ -56,7 +69,7 @@ func (p *connPool) SupportTLS() bool {
 
 // Protocol return xprotocol
 func (p *connPool) Protocol() types.Protocol {
-	return protocol.Xprotocol
+	return p.protocol
 }
 
 func (p *connPool) CheckAndInit(ctx context.Context) bool {
@@ -68,55 +81,142 @@ func (p *connPool) DrainConnections() {}
 
 // NewStream invoked by Proxy
 func (p *connPool) NewStream(context context.Context, responseDecoder types.StreamReceiveListener,
-	listener types.PoolEventListener) {
+	listener types.PoolEventListener) types.Cancellable {
 	log.DefaultLogger.Tracef("xprotocol conn pool new stream")
 
+	closed := false
 	activeClient := func() *activeClient {
 		p.mux.Lock()
 		defer p.mux.Unlock()
+		if p.closed {
+			closed = true
+			return nil
+		}
 		if p.primaryClient == nil {
 			p.primaryClient = newActiveClient(context, p)
 		}
+		// counted under the lock, so Close never sees the client idle while the stream is being created
+		atomic.AddInt64(&p.primaryClient.activeStreams, 1)
 		return p.primaryClient
 	}()
 
+	if closed {
+		listener.OnFailure(types.PoolClosed, p.host)
+		return nil
+	}
+
 	if activeClient == nil {
 		listener.OnFailure(types.ConnectionFailure, p.host)
-		return
+		return nil
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	requests := str.ResourceManagerByContext(context, p.host.ClusterInfo()).Requests()
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		activeClient.releaseStream()
 	} else {
 		atomic.AddUint64(&activeClient.totalStream, 1)
 		p.host.HostStats().UpstreamRequestTotal.Inc(1)
-		p.host.HostStats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
-		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-		p.host.ClusterInfo().ResourceManager().Requests().Increase()
 		log.DefaultLogger.Tracef("xprotocol conn pool codec client new stream")
-		streamSender := activeClient.client.NewStream(context, responseDecoder)
-		streamSender.GetStream().AddEventListener(activeClient)
+
+		var streamSender types.StreamSender
+		// oneway
+		if responseDecoder == nil {
+			streamSender = activeClient.client.NewStream(context, nil)
+		} else {
+			streamSender = activeClient.client.NewStream(context, responseDecoder)
+			streamSender.GetStream().AddEventListener(activeClient)
+
+			p.host.HostStats().UpstreamRequestActive.Inc(1)
+			p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
+			requests.Increase()
+			str.DecreaseOnDestroy(streamSender.GetStream(), requests)
+		}
 
 		log.DefaultLogger.Tracef("xprotocol conn pool codec client new stream success,invoked OnPoolReady")
 		listener.OnReady(streamSender, p.host)
+
+		// a oneway stream is never destroyed, it is done once it is sent
+		if responseDecoder == nil {
+			activeClient.releaseStream()
+		}
 	}
 
-	return
+	return nil
 }
 
-// Close close connection pool
+// Close rejects new streams and drains the clients,
+// a client is closed once the streams in flight are done
 func (p *connPool) Close() {
 	p.mux.Lock()
-	defer p.mux.Unlock()
+	p.closed = true
+	primary, draining := p.primaryClient, p.drainingClient
+	p.mux.Unlock()
 
-	if p.primaryClient != nil {
-		p.primaryClient.client.Close()
+	// the close event is handled with the lock, so drain the clients without it
+	if primary != nil {
+		primary.drain()
+	}
+	if draining != nil {
+		draining.drain()
 	}
 }
 
+// Snapshot implements types.ConnectionSnapshotPool
+func (p *connPool) Snapshot() types.ConnPoolSnapshot {
+	p.mux.Lock()
+	primary, draining := p.primaryClient, p.drainingClient
+	p.mux.Unlock()
+
+	snapshot := types.ConnPoolSnapshot{
+		Protocol: p.protocol,
+		Host:     p.host.AddressString(),
+		Clients:  make([]types.UpstreamConnectionState, 0, 2),
+	}
+	if primary != nil {
+		snapshot.Clients = append(snapshot.Clients, primary.connectionState())
+	}
+	if draining != nil {
+		state := draining.connectionState()
+		state.Draining = true
+		snapshot.Clients = append(snapshot.Clients, state)
+	}
+	snapshot.TotalClients = uint64(len(snapshot.Clients))
+	return snapshot
+}
+
+// CloseConnection implements types.ConnectionSnapshotPool
+// the client is removed from the pool, so the new streams use a new client.
+// a client closed gracefully sends go away and is closed when its streams are done
+func (p *connPool) CloseConnection(id string, graceful bool) (bool, bool) {
+	p.mux.Lock()
+	var ac *activeClient
+	if p.primaryClient != nil && p.primaryClient.id == id {
+		ac = p.primaryClient
+		p.primaryClient = nil
+	} else if p.drainingClient != nil && p.drainingClient.id == id {
+		ac = p.drainingClient
+		p.drainingClient = nil
+	}
+	p.mux.Unlock()
+	if ac == nil {
+		return false, false
+	}
+
+	// the close event is handled with the lock, so close the client without it
+	active := atomic.LoadInt64(&ac.activeStreams) > 0
+	log.DefaultLogger.Infof("[stream] [xprotocol] [connpool] close client %s, graceful: %v, active stream: %v", id, graceful, active)
+	if graceful {
+		ac.drain()
+	} else {
+		ac.client.Close()
+	}
+	return true, active
+}
+
 func (p *connPool) Shutdown() {
 	// TODO: xprotocol connpool do nothing for shutdown
 }
@@ -140,6 +240,9 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 		if p.primaryClient == client {
 			p.primaryClient = nil
 		}
+		if p.drainingClient == client {
+			p.drainingClient = nil
+		}
 	} else if event == types.ConnectTimeout {
 		p.host.HostStats().UpstreamRequestTimeout.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTimeout.Inc(1)
@@ -153,7 +256,7 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
+	client.releaseStream()
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
@@ -183,7 +286,7 @@ func (p *connPool) onGoAway(client *activeClient) {
 }
 
 func (p *connPool) createStreamClient(context context.Context, connData types.CreateConnectionData) str.Client {
-	return str.NewStreamClient(context, protocol.Xprotocol, connData.Connection, connData.HostInfo)
+	return str.NewStreamClient(context, p.protocol, connData.Connection, connData.HostInfo)
 }
 
 func (p *connPool) movePrimaryToDraining() {
@@ -199,6 +302,13 @@ func (p *connPool) movePrimaryToDraining() {
 	}
 }
 
+// the drain state of an activeClient
+const (
+	clientActive uint32 = iota
+	clientDraining
+	clientClosed
+)
+
 // types.StreamEventListener
 // types.ConnectionEventListener
 // types.StreamConnectionEventListener
@@ -208,6 +318,10 @@ type activeClient struct {
 	host               types.HostInfo
 	totalStream        uint64
 	closeWithActiveReq bool
+	activeStreams      int64
+	drainState         uint32
+	// the stable identifier of the client, the connection id plus the host address
+	id string
 }
 
 func newActiveClient(context context.Context, pool *connPool) *activeClient {
@@ -217,6 +331,7 @@ func newActiveClient(context context.Context, pool *connPool) *activeClient {
 
 	log.DefaultLogger.Tracef("xprotocol new active client , try to create connection")
 	data := pool.host.CreateConnection(context)
+	str.RecordConnectDuration(pool.host, data.Connection)
 	data.Connection.Connect()
 	log.DefaultLogger.Tracef("xprotocol new active client , connect success %v", data)
 
@@ -228,6 +343,7 @@ func newActiveClient(context context.Context, pool *connPool) *activeClient {
 
 	ac.client = codecClient
 	ac.host = data.HostInfo
+	ac.id = fmt.Sprintf("%d@%s", codecClient.ConnID(), pool.host.AddressString())
 
 	pool.host.HostStats().UpstreamConnectionTotal.Inc(1)
 	pool.host.HostStats().UpstreamConnectionActive.Inc(1)
@@ -240,6 +356,36 @@ func newActiveClient(context context.Context, pool *connPool) *activeClient {
 	return ac
 }
 
+// drain sends go away and closes the client when it is idle
+func (ac *activeClient) drain() {
+	if atomic.CompareAndSwapUint32(&ac.drainState, clientActive, clientDraining) {
+		ac.client.GoAway()
+		ac.closeIfIdle()
+	}
+}
+
+func (ac *activeClient) releaseStream() {
+	atomic.AddInt64(&ac.activeStreams, -1)
+	ac.closeIfIdle()
+}
+
+func (ac *activeClient) closeIfIdle() {
+	if atomic.LoadInt64(&ac.activeStreams) == 0 &&
+		atomic.CompareAndSwapUint32(&ac.drainState, clientDraining, clientClosed) {
+		ac.client.Close()
+	}
+}
+
+func (ac *activeClient) connectionState() types.UpstreamConnectionState {
+	return types.UpstreamConnectionState{
+		ID:           ac.id,
+		ConnectionID: ac.client.ConnID(),
+		Host:         ac.pool.host.AddressString(),
+		Active:       atomic.LoadInt64(&ac.activeStreams) > 0,
+		Draining:     atomic.LoadUint32(&ac.drainState) == clientDraining,
+	}
+}
+
 // types.ConnectionEventListener
 // OnEvent handle connection event
 func (ac *activeClient) OnEvent(event types.ConnectionEvent) {
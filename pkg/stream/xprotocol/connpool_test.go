@@ -0,0 +1,147 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+// mockStreamClient sends the streams of the codec stream connection without a connection
+type mockStreamClient struct {
+	str.Client
+	sc     types.ClientStreamConnection
+	closed bool
+}
+
+func (c *mockStreamClient) NewStream(ctx context.Context, receiver types.StreamReceiveListener) types.StreamSender {
+	return c.sc.NewStream(ctx, receiver)
+}
+
+func (c *mockStreamClient) ConnID() uint64 {
+	return 1
+}
+
+func (c *mockStreamClient) GoAway() {}
+
+func (c *mockStreamClient) Close() {
+	c.closed = true
+}
+
+type mockPoolListener struct {
+	failures []types.PoolFailureReason
+	sender   types.StreamSender
+}
+
+func (l *mockPoolListener) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	l.failures = append(l.failures, reason)
+}
+
+func (l *mockPoolListener) OnReady(sender types.StreamSender, host types.Host) {
+	l.sender = sender
+}
+
+func TestConnPoolCloseDrainOneway(t *testing.T) {
+	c := cluster.NewCluster(v2.Cluster{
+		Name:        "xprotocol_pool_drain",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: "127.0.0.1:8080",
+		},
+	}, c.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+	client := &mockStreamClient{
+		sc: newCodecStreamConnection(context.Background(), &mockCodec{}, nil, &mockClientStreamListener{}, nil),
+	}
+	ac := &activeClient{pool: pool, client: client}
+	pool.primaryClient = ac
+
+	ctx := context.Background()
+	listener := &mockPoolListener{}
+	pool.NewStream(ctx, &mockResponseListener{}, listener)
+	if listener.sender == nil {
+		t.Fatalf("stream should be ready, failures %v", listener.failures)
+	}
+	request := listener.sender
+	// the oneway stream is done once it is sent
+	pool.NewStream(ctx, nil, listener)
+	if n := atomic.LoadInt64(&ac.activeStreams); n != 1 {
+		t.Fatalf("expected 1 active stream, but got %d", n)
+	}
+
+	pool.Close()
+	if state := atomic.LoadUint32(&ac.drainState); state != clientDraining {
+		t.Fatalf("busy client should be draining, but got state %d", state)
+	}
+
+	// the client is closed after the request is done
+	request.GetStream().ResetStream(types.StreamLocalReset)
+	if state := atomic.LoadUint32(&ac.drainState); state != clientClosed || !client.closed {
+		t.Fatalf("client should be closed after the streams are done, but got state %d", state)
+	}
+}
+
+func TestConnPoolCloseConnection(t *testing.T) {
+	c := cluster.NewCluster(v2.Cluster{
+		Name:        "xprotocol_pool_close_connection",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: "127.0.0.1:8080",
+		},
+	}, c.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+	client := &mockStreamClient{
+		sc: newCodecStreamConnection(context.Background(), &mockCodec{}, nil, &mockClientStreamListener{}, nil),
+	}
+	ac := &activeClient{pool: pool, client: client, id: "1@127.0.0.1:8080"}
+	pool.primaryClient = ac
+
+	listener := &mockPoolListener{}
+	pool.NewStream(context.Background(), &mockResponseListener{}, listener)
+	snapshot := pool.Snapshot()
+	if snapshot.TotalClients != 1 || len(snapshot.Clients) != 1 || snapshot.Clients[0].ID != ac.id || !snapshot.Clients[0].Active {
+		t.Fatalf("expected 1 active client, but got %+v", snapshot)
+	}
+	if found, _ := pool.CloseConnection("unknown", true); found {
+		t.Fatal("unknown client should not be found")
+	}
+
+	// the busy client is removed from the pool and closed after the stream is done
+	if found, active := pool.CloseConnection(ac.id, true); !found || !active {
+		t.Fatalf("busy client should be found and active, but got %v %v", found, active)
+	}
+	if pool.primaryClient != nil || client.closed {
+		t.Fatal("busy client should be removed from the pool and not closed")
+	}
+	listener.sender.GetStream().ResetStream(types.StreamLocalReset)
+	if !client.closed {
+		t.Fatal("client should be closed after the stream is done")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"errors"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	ErrInvalidProtocol   = errors.New("invalid xprotocol, no protocol name")
+	ErrDuplicateProtocol = errors.New("protocol is already registered")
+)
+
+var (
+	protocols    = make(map[types.Protocol]xprotocol.XProtocol)
+	protocolsMux sync.RWMutex
+)
+
+// RegisterProtocol registers a custom protocol codec, it is usually called in the init() of the protocol package.
+// The protocol name is usable as the downstream and upstream protocol of the proxy once registered,
+// the stream connection and the connection pool of the protocol are created by this package.
+func RegisterProtocol(codec xprotocol.XProtocol) error {
+	if codec == nil || codec.Name() == "" {
+		return ErrInvalidProtocol
+	}
+	name := codec.Name()
+
+	protocolsMux.Lock()
+	defer protocolsMux.Unlock()
+
+	if _, ok := protocols[name]; ok {
+		return ErrDuplicateProtocol
+	}
+	// the builtin protocols can not be replaced
+	if _, ok := network.ConnNewPoolFactories[name]; ok {
+		return ErrDuplicateProtocol
+	}
+	protocols[name] = codec
+
+	str.Register(name, &codecStreamConnFactory{codec: codec})
+	network.RegisterNewPoolFactory(name, newConnPoolFactory(name))
+	types.RegisterConnPoolFactory(name, true)
+	config.RegisterProtocolParser(string(name))
+
+	log.DefaultLogger.Infof("[stream] [xprotocol] register protocol: %s", name)
+	return nil
+}
+
+// GetProtocol returns the registered protocol codec, nil if not found
+func GetProtocol(name types.Protocol) xprotocol.XProtocol {
+	protocolsMux.RLock()
+	defer protocolsMux.RUnlock()
+
+	return protocols[name]
+}
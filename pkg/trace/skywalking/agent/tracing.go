@@ -0,0 +1,228 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package agent is the subset of the skywalking v3 data collect protocol used by mosn,
+// the messages mirror language-agent/Tracing.proto and common/Common.proto of
+// https://github.com/apache/skywalking-data-collect-protocol, the field numbers must be kept
+package agent
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+type RefType int32
+
+const (
+	RefType_CrossProcess RefType = 0
+	RefType_CrossThread  RefType = 1
+)
+
+type SpanType int32
+
+const (
+	SpanType_Entry SpanType = 0
+	SpanType_Exit  SpanType = 1
+	SpanType_Local SpanType = 2
+)
+
+type SpanLayer int32
+
+const (
+	SpanLayer_Unknown      SpanLayer = 0
+	SpanLayer_Database     SpanLayer = 1
+	SpanLayer_RPCFramework SpanLayer = 2
+	SpanLayer_Http         SpanLayer = 3
+	SpanLayer_MQ           SpanLayer = 4
+	SpanLayer_Cache        SpanLayer = 5
+)
+
+type KeyStringValuePair struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *KeyStringValuePair) Reset()         { *m = KeyStringValuePair{} }
+func (m *KeyStringValuePair) String() string { return proto.CompactTextString(m) }
+func (*KeyStringValuePair) ProtoMessage()    {}
+
+type Command struct {
+	Command string                `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Args    []*KeyStringValuePair `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+}
+
+func (m *Command) Reset()         { *m = Command{} }
+func (m *Command) String() string { return proto.CompactTextString(m) }
+func (*Command) ProtoMessage()    {}
+
+type Commands struct {
+	Commands []*Command `protobuf:"bytes,1,rep,name=commands,proto3" json:"commands,omitempty"`
+}
+
+func (m *Commands) Reset()         { *m = Commands{} }
+func (m *Commands) String() string { return proto.CompactTextString(m) }
+func (*Commands) ProtoMessage()    {}
+
+type SegmentObject struct {
+	TraceId         string        `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	TraceSegmentId  string        `protobuf:"bytes,2,opt,name=traceSegmentId,proto3" json:"traceSegmentId,omitempty"`
+	Spans           []*SpanObject `protobuf:"bytes,3,rep,name=spans,proto3" json:"spans,omitempty"`
+	Service         string        `protobuf:"bytes,4,opt,name=service,proto3" json:"service,omitempty"`
+	ServiceInstance string        `protobuf:"bytes,5,opt,name=serviceInstance,proto3" json:"serviceInstance,omitempty"`
+	IsSizeLimited   bool          `protobuf:"varint,6,opt,name=isSizeLimited,proto3" json:"isSizeLimited,omitempty"`
+}
+
+func (m *SegmentObject) Reset()         { *m = SegmentObject{} }
+func (m *SegmentObject) String() string { return proto.CompactTextString(m) }
+func (*SegmentObject) ProtoMessage()    {}
+
+type SegmentReference struct {
+	RefType                  RefType `protobuf:"varint,1,opt,name=refType,proto3" json:"refType,omitempty"`
+	TraceId                  string  `protobuf:"bytes,2,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	ParentTraceSegmentId     string  `protobuf:"bytes,3,opt,name=parentTraceSegmentId,proto3" json:"parentTraceSegmentId,omitempty"`
+	ParentSpanId             int32   `protobuf:"varint,4,opt,name=parentSpanId,proto3" json:"parentSpanId,omitempty"`
+	ParentService            string  `protobuf:"bytes,5,opt,name=parentService,proto3" json:"parentService,omitempty"`
+	ParentServiceInstance    string  `protobuf:"bytes,6,opt,name=parentServiceInstance,proto3" json:"parentServiceInstance,omitempty"`
+	ParentEndpoint           string  `protobuf:"bytes,7,opt,name=parentEndpoint,proto3" json:"parentEndpoint,omitempty"`
+	NetworkAddressUsedAtPeer string  `protobuf:"bytes,8,opt,name=networkAddressUsedAtPeer,proto3" json:"networkAddressUsedAtPeer,omitempty"`
+}
+
+func (m *SegmentReference) Reset()         { *m = SegmentReference{} }
+func (m *SegmentReference) String() string { return proto.CompactTextString(m) }
+func (*SegmentReference) ProtoMessage()    {}
+
+type SpanObject struct {
+	SpanId        int32                 `protobuf:"varint,1,opt,name=spanId,proto3" json:"spanId"`
+	ParentSpanId  int32                 `protobuf:"varint,2,opt,name=parentSpanId,proto3" json:"parentSpanId"`
+	StartTime     int64                 `protobuf:"varint,3,opt,name=startTime,proto3" json:"startTime,omitempty"`
+	EndTime       int64                 `protobuf:"varint,4,opt,name=endTime,proto3" json:"endTime,omitempty"`
+	Refs          []*SegmentReference   `protobuf:"bytes,5,rep,name=refs,proto3" json:"refs,omitempty"`
+	OperationName string                `protobuf:"bytes,6,opt,name=operationName,proto3" json:"operationName,omitempty"`
+	Peer          string                `protobuf:"bytes,7,opt,name=peer,proto3" json:"peer,omitempty"`
+	SpanType      SpanType              `protobuf:"varint,8,opt,name=spanType,proto3" json:"spanType"`
+	SpanLayer     SpanLayer             `protobuf:"varint,9,opt,name=spanLayer,proto3" json:"spanLayer"`
+	ComponentId   int32                 `protobuf:"varint,10,opt,name=componentId,proto3" json:"componentId,omitempty"`
+	IsError       bool                  `protobuf:"varint,11,opt,name=isError,proto3" json:"isError,omitempty"`
+	Tags          []*KeyStringValuePair `protobuf:"bytes,12,rep,name=tags,proto3" json:"tags,omitempty"`
+	SkipAnalysis  bool                  `protobuf:"varint,14,opt,name=skipAnalysis,proto3" json:"skipAnalysis,omitempty"`
+}
+
+func (m *SpanObject) Reset()         { *m = SpanObject{} }
+func (m *SpanObject) String() string { return proto.CompactTextString(m) }
+func (*SpanObject) ProtoMessage()    {}
+
+// TraceSegmentReportServiceClient is the client of skywalking.v3.TraceSegmentReportService
+type TraceSegmentReportServiceClient interface {
+	Collect(ctx context.Context, opts ...grpc.CallOption) (TraceSegmentReportService_CollectClient, error)
+}
+
+type TraceSegmentReportService_CollectClient interface {
+	Send(*SegmentObject) error
+	CloseAndRecv() (*Commands, error)
+	grpc.ClientStream
+}
+
+type traceSegmentReportServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTraceSegmentReportServiceClient(cc *grpc.ClientConn) TraceSegmentReportServiceClient {
+	return &traceSegmentReportServiceClient{cc}
+}
+
+var collectStreamDesc = &grpc.StreamDesc{
+	StreamName:    "collect",
+	ClientStreams: true,
+}
+
+func (c *traceSegmentReportServiceClient) Collect(ctx context.Context, opts ...grpc.CallOption) (TraceSegmentReportService_CollectClient, error) {
+	stream, err := c.cc.NewStream(ctx, collectStreamDesc, "/skywalking.v3.TraceSegmentReportService/collect", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &traceSegmentReportServiceCollectClient{stream}, nil
+}
+
+type traceSegmentReportServiceCollectClient struct {
+	grpc.ClientStream
+}
+
+func (x *traceSegmentReportServiceCollectClient) Send(m *SegmentObject) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *traceSegmentReportServiceCollectClient) CloseAndRecv() (*Commands, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Commands)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TraceSegmentReportServiceServer is the server of skywalking.v3.TraceSegmentReportService, it is used in the tests
+type TraceSegmentReportServiceServer interface {
+	Collect(TraceSegmentReportService_CollectServer) error
+}
+
+type TraceSegmentReportService_CollectServer interface {
+	SendAndClose(*Commands) error
+	Recv() (*SegmentObject, error)
+	grpc.ServerStream
+}
+
+func RegisterTraceSegmentReportServiceServer(s *grpc.Server, srv TraceSegmentReportServiceServer) {
+	s.RegisterService(&traceSegmentReportServiceDesc, srv)
+}
+
+func collectHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TraceSegmentReportServiceServer).Collect(&traceSegmentReportServiceCollectServer{stream})
+}
+
+type traceSegmentReportServiceCollectServer struct {
+	grpc.ServerStream
+}
+
+func (x *traceSegmentReportServiceCollectServer) SendAndClose(m *Commands) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *traceSegmentReportServiceCollectServer) Recv() (*SegmentObject, error) {
+	m := new(SegmentObject)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var traceSegmentReportServiceDesc = grpc.ServiceDesc{
+	ServiceName: "skywalking.v3.TraceSegmentReportService",
+	HandlerType: (*TraceSegmentReportServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "collect",
+			Handler:       collectHandler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "language-agent/Tracing.proto",
+}
@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package skywalking
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// skywalking propagation headers, see https://skywalking.apache.org/docs/main/latest/en/api/x-process-propagation-headers-v3/
+const (
+	SW8Header = "sw8"
+	// BoltHeaderKey is the bolt header key carrying the sw8 value
+	BoltHeaderKey = "rpc_trace_context.sw8"
+)
+
+// carrier is the cross process context of the sw8 header
+type carrier struct {
+	sampled               bool
+	traceId               string
+	parentSegmentId       string
+	parentSpanId          int32
+	parentService         string
+	parentServiceInstance string
+	parentEndpoint        string
+	addressUsedAtClient   string
+}
+
+func headerKey(headers types.HeaderMap) string {
+	if _, isBolt := headers.(*sofarpc.BoltRequest); isBolt {
+		return BoltHeaderKey
+	}
+	return SW8Header
+}
+
+// extract reads the sw8 header from the request headers
+func extract(headers types.HeaderMap) (c carrier, ok bool) {
+	if headers == nil {
+		return c, false
+	}
+	value, exists := headers.Get(headerKey(headers))
+	if !exists {
+		return c, false
+	}
+	return decode(value)
+}
+
+// inject writes the sw8 header into the request headers
+func inject(headers types.HeaderMap, c carrier) {
+	headers.Set(headerKey(headers), encode(c))
+}
+
+// encode formats the carrier as sample-traceId-segmentId-spanId-service-instance-endpoint-address,
+// the string fields are base64 encoded
+func encode(c carrier) string {
+	sample := "0"
+	if c.sampled {
+		sample = "1"
+	}
+	return strings.Join([]string{
+		sample,
+		base64.StdEncoding.EncodeToString([]byte(c.traceId)),
+		base64.StdEncoding.EncodeToString([]byte(c.parentSegmentId)),
+		strconv.Itoa(int(c.parentSpanId)),
+		base64.StdEncoding.EncodeToString([]byte(c.parentService)),
+		base64.StdEncoding.EncodeToString([]byte(c.parentServiceInstance)),
+		base64.StdEncoding.EncodeToString([]byte(c.parentEndpoint)),
+		base64.StdEncoding.EncodeToString([]byte(c.addressUsedAtClient)),
+	}, "-")
+}
+
+func decode(value string) (c carrier, ok bool) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 8 {
+		return c, false
+	}
+	switch parts[0] {
+	case "1":
+		c.sampled = true
+	case "0":
+	default:
+		return c, false
+	}
+	spanId, err := strconv.ParseInt(parts[3], 10, 32)
+	if err != nil || spanId < 0 {
+		return c, false
+	}
+	c.parentSpanId = int32(spanId)
+	fields := []*string{nil, &c.traceId, &c.parentSegmentId, nil,
+		&c.parentService, &c.parentServiceInstance, &c.parentEndpoint, &c.addressUsedAtClient}
+	for i, field := range fields {
+		if field == nil {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(parts[i])
+		if err != nil {
+			return c, false
+		}
+		*field = string(decoded)
+	}
+	if c.traceId == "" || c.parentSegmentId == "" {
+		return c, false
+	}
+	return c, true
+}
+
+// newId returns a random 128 bits id in hex, it is used as the trace id and the segment id
+func newId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
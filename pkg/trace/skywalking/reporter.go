@@ -0,0 +1,222 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package skywalking
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/trace/skywalking/agent"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const sendTimeout = 5 * time.Second
+
+// reporter buffers the finished segments and sends them to the backend over grpc in batches.
+// the segments are written into the log file if the backend is not configured or unreachable,
+// and dropped if the buffer is full, so the requests are never blocked by the backend
+type reporter struct {
+	options  reporterOptions
+	conn     *grpc.ClientConn
+	client   agent.TraceSegmentReportServiceClient
+	logger   *log.Logger
+	segments chan *agent.SegmentObject
+	stats    types.Metrics
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type reporterOptions struct {
+	backend       string
+	batchSize     int
+	queueSize     int
+	flushInterval time.Duration
+	logPath       string
+}
+
+var (
+	reporterMutex   sync.Mutex
+	currentReporter *reporter
+)
+
+// getReporter returns the reporter of the config, all the tracers of a driver share the same reporter.
+// the reporter of the previous config is stopped after its buffered segments are sent
+func getReporter(config *Config) (*reporter, error) {
+	options := reporterOptions{
+		backend:       config.BackendService,
+		batchSize:     config.BatchSize,
+		queueSize:     config.QueueSize,
+		flushInterval: config.FlushInterval.Duration,
+		logPath:       config.LogPath,
+	}
+	reporterMutex.Lock()
+	defer reporterMutex.Unlock()
+	if currentReporter != nil && currentReporter.options == options {
+		return currentReporter, nil
+	}
+	r, err := newReporter(options)
+	if err != nil {
+		return nil, err
+	}
+	if currentReporter != nil {
+		currentReporter.Stop()
+	}
+	currentReporter = r
+	return r, nil
+}
+
+func newReporter(options reporterOptions) (*reporter, error) {
+	logger, err := log.GetOrCreateLogger(options.logPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &reporter{
+		options:  options,
+		logger:   logger,
+		segments: make(chan *agent.SegmentObject, options.queueSize),
+		stats:    metrics.NewTraceStats("skywalking"),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	// the dial does not block, the segments are logged until the backend is connected
+	if options.backend != "" {
+		r.conn, err = grpc.Dial(options.backend, grpc.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+		r.client = agent.NewTraceSegmentReportServiceClient(r.conn)
+	}
+	utils.GoWithRecover(r.run, nil)
+	return r, nil
+}
+
+// Report buffers the segment, the segment is dropped if the buffer is full
+func (r *reporter) Report(segment *agent.SegmentObject) {
+	r.stats.Counter(metrics.TraceSpanSampled).Inc(1)
+	select {
+	case r.segments <- segment:
+	default:
+		r.stats.Counter(metrics.TraceSpanDropped).Inc(1)
+	}
+}
+
+// Stop sends the buffered segments and stops the reporter
+func (r *reporter) Stop() {
+	close(r.stop)
+	<-r.done
+	if r.conn != nil {
+		r.conn.Close()
+	}
+}
+
+func (r *reporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.options.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*agent.SegmentObject, 0, r.options.batchSize)
+	for {
+		select {
+		case segment := <-r.segments:
+			batch = append(batch, segment)
+			if len(batch) >= r.options.batchSize {
+				r.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.send(batch)
+				batch = batch[:0]
+			}
+		case <-r.stop:
+			for {
+				select {
+				case segment := <-r.segments:
+					batch = append(batch, segment)
+					if len(batch) >= r.options.batchSize {
+						r.send(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						r.send(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *reporter) send(batch []*agent.SegmentObject) {
+	if r.client == nil {
+		r.writeLog(batch)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	stream, err := r.client.Collect(ctx)
+	if err != nil {
+		r.onSendFailed(batch, err)
+		return
+	}
+	for i, segment := range batch {
+		if err := stream.Send(segment); err != nil {
+			r.onSendFailed(batch[i:], err)
+			return
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		r.onSendFailed(batch, err)
+		return
+	}
+	r.stats.Counter(metrics.TraceSpanReported).Inc(int64(len(batch)))
+	r.stats.Counter(metrics.TraceBatchSent).Inc(1)
+}
+
+// onSendFailed falls back to write the segments into the log file
+func (r *reporter) onSendFailed(batch []*agent.SegmentObject, err error) {
+	r.stats.Counter(metrics.TraceSpanSendFailed).Inc(int64(len(batch)))
+	log.DefaultLogger.Warnf("[trace] [skywalking] send %d segments to %s failed: %v, write them into the log", len(batch), r.options.backend, err)
+	r.writeLog(batch)
+}
+
+func (r *reporter) writeLog(batch []*agent.SegmentObject) {
+	for _, segment := range batch {
+		data, err := json.Marshal(segment)
+		if err != nil {
+			r.stats.Counter(metrics.TraceSpanDropped).Inc(1)
+			continue
+		}
+		buf := buffer.GetIoBuffer(len(data) + 1)
+		buf.Write(data)
+		buf.WriteString("\n")
+		if err := r.logger.Print(buf, true); err != nil {
+			r.stats.Counter(metrics.TraceSpanDropped).Inc(1)
+			continue
+		}
+		r.stats.Counter(metrics.TraceSpanLogged).Inc(1)
+	}
+}
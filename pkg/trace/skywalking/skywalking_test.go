@@ -0,0 +1,295 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package skywalking
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/trace"
+	"sofastack.io/sofa-mosn/pkg/trace/skywalking/agent"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestCarrierEncode(t *testing.T) {
+	c := carrier{
+		sampled:               true,
+		traceId:               "a.b.c",
+		parentSegmentId:       "d.e.f",
+		parentSpanId:          3,
+		parentService:         "service",
+		parentServiceInstance: "instance@127.0.0.1",
+		parentEndpoint:        "/api/v1",
+		addressUsedAtClient:   "127.0.0.1:8080",
+	}
+	value := encode(c)
+	if !strings.HasPrefix(value, "1-YS5iLmM=-ZC5lLmY=-3-") {
+		t.Errorf("unexpected sw8 value: %s", value)
+	}
+	decoded, ok := decode(value)
+	if !ok || decoded != c {
+		t.Errorf("decode sw8 failed, expected %+v, but got %+v", c, decoded)
+	}
+	for _, invalid := range []string{
+		"",
+		"1-YS5iLmM=-ZC5lLmY=-3",
+		"2-YS5iLmM=-ZC5lLmY=-3-c2VydmljZQ==-aW5zdGFuY2U=-Lw==-LQ==",
+		"1-YS5iLmM=-ZC5lLmY=-x-c2VydmljZQ==-aW5zdGFuY2U=-Lw==-LQ==",
+		"1-!!!-ZC5lLmY=-3-c2VydmljZQ==-aW5zdGFuY2U=-Lw==-LQ==",
+		"1--ZC5lLmY=-3-c2VydmljZQ==-aW5zdGFuY2U=-Lw==-LQ==",
+	} {
+		if _, ok := decode(invalid); ok {
+			t.Errorf("sw8 %s should be invalid", invalid)
+		}
+	}
+}
+
+func TestBoltCarrier(t *testing.T) {
+	c := carrier{
+		traceId:         "a.b.c",
+		parentSegmentId: "d.e.f",
+	}
+	request := &sofarpc.BoltRequest{
+		RequestHeader: map[string]string{},
+	}
+	inject(request, c)
+	if _, ok := request.RequestHeader[BoltHeaderKey]; !ok {
+		t.Fatalf("the bolt request should carry the context in %s: %v", BoltHeaderKey, request.RequestHeader)
+	}
+	if _, ok := request.RequestHeader[SW8Header]; ok {
+		t.Errorf("the bolt request should not carry the sw8 header: %v", request.RequestHeader)
+	}
+	if got, ok := extract(request); !ok || got != c {
+		t.Errorf("extract the bolt context failed: %+v", got)
+	}
+}
+
+func TestTracerStart(t *testing.T) {
+	tracer := &Tracer{
+		proto:        protocol.HTTP1,
+		serviceName:  "mosn",
+		instanceName: "mosn@127.0.0.1",
+		sampleRate:   0,
+	}
+	parent := carrier{
+		sampled:               true,
+		traceId:               "a.b.c",
+		parentSegmentId:       "d.e.f",
+		parentSpanId:          1,
+		parentService:         "client",
+		parentServiceInstance: "client@127.0.0.2",
+		parentEndpoint:        "/client",
+		addressUsedAtClient:   "127.0.0.1:2045",
+	}
+	headers := protocol.CommonHeader{SW8Header: encode(parent)}
+	span := tracer.Start(context.Background(), headers, time.Now()).(*Span)
+	if span.TraceId() != "a.b.c" || span.ParentSpanId() != "d.e.f" || !span.Sampled() {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+
+	// the upstream refers to the exit span of the segment
+	span.SetOperation("/server")
+	upstream := protocol.CommonHeader{protocol.MosnHeaderHostKey: "upstream.com"}
+	span.InjectContext(upstream)
+	c, ok := decode(upstream[SW8Header])
+	if !ok || c.traceId != "a.b.c" || c.parentSegmentId != span.SpanId() || c.parentSpanId != exitSpanId ||
+		c.parentService != "mosn" || c.parentEndpoint != "/server" || c.addressUsedAtClient != "upstream.com" {
+		t.Errorf("unexpected upstream context: %+v", c)
+	}
+
+	span.SetTag(UPSTREAM_ADDRESS, "127.0.0.1:8080")
+	span.SetTag(STATUS_CODE, "200")
+	span.endTime = time.Now()
+	segment := span.segment()
+	if len(segment.Spans) != 2 || segment.Service != "mosn" || segment.ServiceInstance != "mosn@127.0.0.1" {
+		t.Fatalf("unexpected segment: %+v", segment)
+	}
+	entry, exit := segment.Spans[0], segment.Spans[1]
+	if entry.SpanType != agent.SpanType_Entry || entry.ParentSpanId != -1 || len(entry.Refs) != 1 ||
+		entry.Refs[0].ParentTraceSegmentId != "d.e.f" || entry.Refs[0].NetworkAddressUsedAtPeer != "127.0.0.1:2045" {
+		t.Errorf("unexpected entry span: %+v", entry)
+	}
+	if exit.SpanType != agent.SpanType_Exit || exit.ParentSpanId != entrySpanId || exit.Peer != "127.0.0.1:8080" {
+		t.Errorf("unexpected exit span: %+v", exit)
+	}
+
+	// a new trace is started, the sample rate decides the sampling
+	span = tracer.Start(context.Background(), protocol.CommonHeader{}, time.Now()).(*Span)
+	if span.TraceId() == "" || span.ParentSpanId() != "" || span.Sampled() {
+		t.Errorf("unexpected new span: %+v", span)
+	}
+}
+
+type collectServer struct {
+	mutex    sync.Mutex
+	segments []*agent.SegmentObject
+}
+
+func (s *collectServer) Collect(stream agent.TraceSegmentReportService_CollectServer) error {
+	for {
+		segment, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&agent.Commands{})
+		}
+		if err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.segments = append(s.segments, segment)
+		s.mutex.Unlock()
+	}
+}
+
+func TestReporterGrpc(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &collectServer{}
+	server := grpc.NewServer()
+	agent.RegisterTraceSegmentReportServiceServer(server, srv)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	dir, _ := ioutil.TempDir("", "skywalking")
+	defer os.RemoveAll(dir)
+
+	r, err := newReporter(reporterOptions{
+		backend:       lis.Addr().String(),
+		batchSize:     2,
+		queueSize:     10,
+		flushInterval: time.Hour,
+		logPath:       filepath.Join(dir, "segment.log"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported := r.stats.Counter(metrics.TraceSpanReported).Count()
+	for i := 0; i < 3; i++ {
+		r.Report(&agent.SegmentObject{TraceId: "trace", TraceSegmentId: "segment", Service: "mosn"})
+	}
+	r.Stop()
+
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	if len(srv.segments) != 3 || srv.segments[0].Service != "mosn" {
+		t.Fatalf("expected 3 segments received, but got %v", srv.segments)
+	}
+	if count := r.stats.Counter(metrics.TraceSpanReported).Count() - reported; count != 3 {
+		t.Errorf("expected 3 segments reported, but got %d", count)
+	}
+}
+
+func TestReporterFallbackLog(t *testing.T) {
+	// no backend listens on the address
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := lis.Addr().String()
+	lis.Close()
+
+	dir, _ := ioutil.TempDir("", "skywalking")
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "segment.log")
+
+	r, err := newReporter(reporterOptions{
+		backend:       backend,
+		batchSize:     10,
+		queueSize:     10,
+		flushInterval: time.Hour,
+		logPath:       logPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := r.stats.Counter(metrics.TraceSpanLogged).Count()
+	r.Report(&agent.SegmentObject{TraceId: "fallback-trace", TraceSegmentId: "segment"})
+	r.Stop()
+
+	if count := r.stats.Counter(metrics.TraceSpanLogged).Count() - logged; count != 1 {
+		t.Fatalf("expected 1 segment logged, but got %d", count)
+	}
+	// wait the log flushed
+	logger, _ := log.GetOrCreateLogger(logPath, nil)
+	logger.Close()
+	time.Sleep(100 * time.Millisecond)
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil || !strings.Contains(string(data), "fallback-trace") {
+		t.Errorf("the segment should be written into the log, data: %s, error: %v", data, err)
+	}
+}
+
+func TestReporterDrop(t *testing.T) {
+	// the reporter is not running, the segments out of the buffer are dropped
+	r := &reporter{
+		segments: make(chan *agent.SegmentObject, 1),
+		stats:    metrics.NewTraceStats("test_drop"),
+	}
+	dropped := r.stats.Counter(metrics.TraceSpanDropped).Count()
+	r.Report(&agent.SegmentObject{})
+	r.Report(&agent.SegmentObject{})
+	if count := r.stats.Counter(metrics.TraceSpanDropped).Count() - dropped; count != 1 {
+		t.Errorf("expected 1 segment dropped, but got %d", count)
+	}
+}
+
+func TestDriverInit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "skywalking")
+	defer os.RemoveAll(dir)
+	defer trace.Disable()
+
+	if err := trace.Init(DriverName, map[string]interface{}{
+		"sample_rate": 2,
+	}); err == nil {
+		t.Error("expected invalid sample rate error")
+	}
+	if err := trace.Init(DriverName, map[string]interface{}{
+		"service_name": "mosn-test",
+		"log_path":     filepath.Join(dir, "segment.log"),
+	}); err != nil {
+		t.Fatalf("init skywalking driver failed: %v", err)
+	}
+	for _, proto := range []types.Protocol{protocol.HTTP1, protocol.HTTP2, protocol.SofaRPC} {
+		tracer, ok := trace.Tracer(proto).(*Tracer)
+		if !ok {
+			t.Fatalf("no skywalking tracer of %s", proto)
+		}
+		if tracer.serviceName != "mosn-test" || !strings.HasPrefix(tracer.instanceName, "mosn-test@") {
+			t.Errorf("unexpected tracer: %+v", tracer)
+		}
+	}
+	// log only without the backend service
+	if trace.Tracer(protocol.HTTP1).(*Tracer).reporter.client != nil {
+		t.Error("the reporter should be log only")
+	}
+}
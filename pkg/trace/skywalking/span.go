@@ -0,0 +1,228 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package skywalking
+
+import (
+	"strconv"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/trace/skywalking/agent"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the tag keys of the skywalking span
+const (
+	HTTP_METHOD = iota
+	HTTP_PATH
+	STATUS_CODE
+	REQUEST_SIZE
+	RESPONSE_SIZE
+	UPSTREAM_ADDRESS
+	DOWNSTREAM_ADDRESS
+	REQUEST_ID
+
+	TAG_END
+)
+
+var tagNames = [TAG_END]string{
+	HTTP_METHOD:   "http.method",
+	HTTP_PATH:     "url",
+	STATUS_CODE:   "status_code",
+	REQUEST_SIZE:  "request.size",
+	RESPONSE_SIZE: "response.size",
+	REQUEST_ID:    "request.id",
+}
+
+// the span ids in the segment of mosn, the entry span receives the downstream request
+// and the exit span sends the upstream request
+const (
+	entrySpanId int32 = 0
+	exitSpanId  int32 = 1
+)
+
+// unknownPeer is the address used at client if the upstream host is unknown
+const unknownPeer = "-"
+
+// Span is a skywalking segment of the request proxied by mosn, it is reported when finished if it is sampled
+type Span struct {
+	tracer    *Tracer
+	traceId   string
+	segmentId string
+	sampled   bool
+	// ref is the cross process context of the parent segment, nil if the trace is started by mosn
+	ref       *carrier
+	layer     agent.SpanLayer
+	operation string
+	startTime time.Time
+	endTime   time.Time
+	isError   bool
+	tags      [TAG_END]string
+}
+
+func (s *Span) TraceId() string {
+	return s.traceId
+}
+
+func (s *Span) SpanId() string {
+	return s.segmentId
+}
+
+func (s *Span) ParentSpanId() string {
+	if s.ref != nil {
+		return s.ref.parentSegmentId
+	}
+	return ""
+}
+
+func (s *Span) Sampled() bool {
+	return s.sampled
+}
+
+func (s *Span) SetOperation(operation string) {
+	s.operation = operation
+}
+
+func (s *Span) SetTag(key uint64, value string) {
+	if key < TAG_END {
+		s.tags[key] = value
+	}
+}
+
+func (s *Span) Tag(key uint64) string {
+	if key < TAG_END {
+		return s.tags[key]
+	}
+	return ""
+}
+
+func (s *Span) SetRequestInfo(reqinfo types.RequestInfo) {
+	s.tags[REQUEST_SIZE] = strconv.FormatUint(reqinfo.BytesReceived(), 10)
+	s.tags[RESPONSE_SIZE] = strconv.FormatUint(reqinfo.BytesSent(), 10)
+	if reqinfo.UpstreamHost() != nil {
+		s.tags[UPSTREAM_ADDRESS] = reqinfo.UpstreamHost().AddressString()
+	}
+	if reqinfo.DownstreamRemoteAddress() != nil {
+		s.tags[DOWNSTREAM_ADDRESS] = reqinfo.DownstreamRemoteAddress().String()
+	}
+	s.tags[REQUEST_ID] = reqinfo.RequestID()
+	code := reqinfo.ResponseCode()
+	s.tags[STATUS_CODE] = strconv.Itoa(code)
+	s.isError = code >= 500
+}
+
+func (s *Span) FinishSpan() {
+	s.endTime = time.Now()
+	if s.sampled && s.tracer != nil && s.tracer.reporter != nil {
+		s.tracer.reporter.Report(s.segment())
+	}
+}
+
+// InjectContext sets the sw8 header referring to the exit span into the upstream request headers
+func (s *Span) InjectContext(requestHeaders types.HeaderMap) {
+	if requestHeaders == nil || s.tracer == nil {
+		return
+	}
+	peer := unknownPeer
+	if host, ok := requestHeaders.Get(protocol.MosnHeaderHostKey); ok && host != "" {
+		peer = host
+	} else if host, ok := requestHeaders.Get("Host"); ok && host != "" {
+		peer = host
+	}
+	inject(requestHeaders, carrier{
+		sampled:               s.sampled,
+		traceId:               s.traceId,
+		parentSegmentId:       s.segmentId,
+		parentSpanId:          exitSpanId,
+		parentService:         s.tracer.serviceName,
+		parentServiceInstance: s.tracer.instanceName,
+		parentEndpoint:        s.operation,
+		addressUsedAtClient:   peer,
+	})
+}
+
+// SpawnChild is not supported, all the spans of mosn are in the same segment
+func (s *Span) SpawnChild(operationName string, startTime time.Time) types.Span {
+	return nil
+}
+
+// segment builds the skywalking segment of the span, the exit span is added if the request is
+// sent to the upstream
+func (s *Span) segment() *agent.SegmentObject {
+	startTime := s.startTime.UnixNano() / int64(time.Millisecond)
+	endTime := s.endTime.UnixNano() / int64(time.Millisecond)
+
+	var tags []*agent.KeyStringValuePair
+	for key, value := range s.tags {
+		if name := tagNames[key]; name != "" && value != "" {
+			tags = append(tags, &agent.KeyStringValuePair{Key: name, Value: value})
+		}
+	}
+
+	entry := &agent.SpanObject{
+		SpanId:        entrySpanId,
+		ParentSpanId:  -1,
+		StartTime:     startTime,
+		EndTime:       endTime,
+		OperationName: s.operation,
+		Peer:          s.tags[DOWNSTREAM_ADDRESS],
+		SpanType:      agent.SpanType_Entry,
+		SpanLayer:     s.layer,
+		IsError:       s.isError,
+		Tags:          tags,
+	}
+	if s.ref != nil {
+		entry.Refs = []*agent.SegmentReference{
+			{
+				RefType:                  agent.RefType_CrossProcess,
+				TraceId:                  s.ref.traceId,
+				ParentTraceSegmentId:     s.ref.parentSegmentId,
+				ParentSpanId:             s.ref.parentSpanId,
+				ParentService:            s.ref.parentService,
+				ParentServiceInstance:    s.ref.parentServiceInstance,
+				ParentEndpoint:           s.ref.parentEndpoint,
+				NetworkAddressUsedAtPeer: s.ref.addressUsedAtClient,
+			},
+		}
+	}
+	spans := []*agent.SpanObject{entry}
+	if upstream := s.tags[UPSTREAM_ADDRESS]; upstream != "" {
+		spans = append(spans, &agent.SpanObject{
+			SpanId:        exitSpanId,
+			ParentSpanId:  entrySpanId,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			OperationName: s.operation,
+			Peer:          upstream,
+			SpanType:      agent.SpanType_Exit,
+			SpanLayer:     s.layer,
+			IsError:       s.isError,
+		})
+	}
+
+	segment := &agent.SegmentObject{
+		TraceId:        s.traceId,
+		TraceSegmentId: s.segmentId,
+		Spans:          spans,
+	}
+	if s.tracer != nil {
+		segment.Service = s.tracer.serviceName
+		segment.ServiceInstance = s.tracer.instanceName
+	}
+	return segment
+}
@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package skywalking
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+	"sofastack.io/sofa-mosn/pkg/trace/skywalking/agent"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// Tracer builds the skywalking spans of a protocol
+type Tracer struct {
+	proto        types.Protocol
+	serviceName  string
+	instanceName string
+	sampleRate   float64
+	reporter     *reporter
+}
+
+// Start builds the span of the request, the segment refers to the parent segment in the sw8 header,
+// a new trace is started if no sw8 header is found
+func (t *Tracer) Start(ctx context.Context, request interface{}, startTime time.Time) types.Span {
+	span := &Span{
+		tracer:    t,
+		segmentId: newId(),
+		startTime: startTime,
+		layer:     agent.SpanLayer_Http,
+	}
+	if t.proto == protocol.SofaRPC {
+		span.layer = agent.SpanLayer_RPCFramework
+	}
+
+	headers, _ := request.(types.HeaderMap)
+	if c, ok := extract(headers); ok {
+		span.traceId = c.traceId
+		span.sampled = c.sampled
+		span.ref = &c
+	} else {
+		span.traceId = newId()
+		span.sampled = t.sample()
+	}
+
+	t.setRequestTags(span, request)
+	return span
+}
+
+func (t *Tracer) sample() bool {
+	if t.sampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < t.sampleRate
+}
+
+// setRequestTags sets the operation name and the tags of the request, the operation name is
+// the endpoint of the service in skywalking
+func (t *Tracer) setRequestTags(span *Span, request interface{}) {
+	switch req := request.(type) {
+	case http.RequestHeader:
+		if req.RequestHeader != nil {
+			path := string(req.RequestURI())
+			if idx := strings.IndexByte(path, '?'); idx >= 0 {
+				path = path[:idx]
+			}
+			span.SetTag(HTTP_METHOD, string(req.Method()))
+			span.SetTag(HTTP_PATH, path)
+			span.SetOperation(path)
+			return
+		}
+	case *mhttp2.ReqHeader:
+		if req.Req != nil && req.Req.URL != nil {
+			span.SetTag(HTTP_METHOD, req.Req.Method)
+			span.SetTag(HTTP_PATH, req.Req.URL.Path)
+			span.SetOperation(req.Req.URL.Path)
+			return
+		}
+	case sofarpc.SofaRpcCmd:
+		if service, ok := req.Get(models.SERVICE_KEY); ok {
+			method, _ := req.Get(models.TARGET_METHOD)
+			span.SetOperation(service + "#" + method)
+			return
+		}
+	}
+	span.SetOperation(string(t.proto))
+}
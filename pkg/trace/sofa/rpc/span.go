@@ -77,6 +77,7 @@ func (s *SofaRPCSpan) SetRequestInfo(reqinfo types.RequestInfo) {
 		s.tags[DOWNSTEAM_HOST_ADDRESS] = reqinfo.DownstreamRemoteAddress().String()
 	}
 	s.tags[RESULT_STATUS] = strconv.Itoa(reqinfo.ResponseCode())
+	s.tags[REQUEST_ID] = reqinfo.RequestID()
 }
 
 func (s *SofaRPCSpan) Tag(key uint64) string {
@@ -154,6 +155,11 @@ func (s *SofaRPCSpan) log() error {
 	printData.WriteString("\"baggage\":")
 	printData.WriteString("\"" + s.tags[BAGGAGE_DATA] + "\",")
 
+	if requestID := s.tags[REQUEST_ID]; requestID != "" {
+		printData.WriteString("\"request.id\":")
+		printData.WriteString("\"" + requestID + "\",")
+	}
+
 	// Set status code. TODO can not get the result code if server throw an exception.
 
 	statusCode, _ := strconv.Atoi(s.tags[RESULT_STATUS])
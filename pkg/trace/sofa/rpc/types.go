@@ -45,6 +45,7 @@ const (
 	TARGET_IDC
 	TARGET_CITY
 	ROUTE_RECORD
+	REQUEST_ID
 	//30-60 for other extends
 
 	TRACE_END = 60
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/trace"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// DriverName is the name of the zipkin tracing driver.
+// the spans are reported in zipkin v2 json, so a jaeger collector with the zipkin endpoint enabled is supported too
+const DriverName = "Zipkin"
+
+const (
+	defaultServiceName   = "mosn"
+	defaultSampleRate    = 1.0
+	defaultBatchSize     = 100
+	defaultQueueSize     = 10240
+	defaultFlushInterval = time.Second
+)
+
+var (
+	ErrNoCollector       = errors.New("zipkin collector endpoint is required")
+	ErrInvalidSampleRate = errors.New("zipkin sample rate should be in [0, 1]")
+)
+
+func init() {
+	trace.RegisterDriver(DriverName, trace.NewDefaultDriverImpl())
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP1, newTracerBuilder(protocol.HTTP1))
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP2, newTracerBuilder(protocol.HTTP2))
+	trace.RegisterTracerBuilder(DriverName, protocol.SofaRPC, newTracerBuilder(protocol.SofaRPC))
+}
+
+// Config is the zipkin driver config, parsed from the config field of the tracing config
+type Config struct {
+	CollectorEndpoint string             `json:"collector_endpoint"`
+	ServiceName       string             `json:"service_name,omitempty"`
+	SampleRate        *float64           `json:"sample_rate,omitempty"`
+	BatchSize         int                `json:"batch_size,omitempty"`
+	QueueSize         int                `json:"queue_size,omitempty"`
+	FlushInterval     *v2.DurationConfig `json:"flush_interval,omitempty"`
+}
+
+// ParseConfig parses the tracing config into the zipkin config, and sets the default values
+func ParseConfig(cfg map[string]interface{}) (*Config, error) {
+	config := &Config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if config.CollectorEndpoint == "" {
+		return nil, ErrNoCollector
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	if config.SampleRate == nil {
+		rate := defaultSampleRate
+		config.SampleRate = &rate
+	} else if *config.SampleRate < 0 || *config.SampleRate > 1 {
+		return nil, ErrInvalidSampleRate
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	if config.FlushInterval == nil || config.FlushInterval.Duration <= 0 {
+		config.FlushInterval = &v2.DurationConfig{Duration: defaultFlushInterval}
+	}
+	return config, nil
+}
+
+func newTracerBuilder(proto types.Protocol) types.TracerBuilder {
+	return func(cfg map[string]interface{}) (types.Tracer, error) {
+		config, err := ParseConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &Tracer{
+			proto:       proto,
+			serviceName: config.ServiceName,
+			sampleRate:  *config.SampleRate,
+			localIp:     trace.GetIp(),
+			reporter:    getReporter(config),
+		}, nil
+	}
+}
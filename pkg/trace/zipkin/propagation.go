@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// B3 propagation headers, see https://github.com/openzipkin/b3-propagation
+const (
+	B3TraceID      = "x-b3-traceid"
+	B3SpanID       = "x-b3-spanid"
+	B3ParentSpanID = "x-b3-parentspanid"
+	B3Sampled      = "x-b3-sampled"
+	B3Flags        = "x-b3-flags"
+)
+
+// spanContext is the trace context propagated between the services
+type spanContext struct {
+	traceId      string
+	spanId       string
+	parentSpanId string
+	// sampled is nil if the sampling decision is deferred to mosn
+	sampled *bool
+	debug   bool
+}
+
+// extract reads the b3 headers from the request headers.
+// the bolt request without b3 headers takes the sofa trace id instead, so the trace is kept
+// across the protocol conversion
+func extract(headers types.HeaderMap) (sc spanContext, ok bool) {
+	if headers == nil {
+		return sc, false
+	}
+	if flags, exists := headers.Get(B3Flags); exists && flags == "1" {
+		sc.debug = true
+	}
+	if sampled, exists := headers.Get(B3Sampled); exists {
+		switch strings.ToLower(sampled) {
+		case "1", "true", "d":
+			v := true
+			sc.sampled = &v
+		case "0", "false":
+			v := false
+			sc.sampled = &v
+		}
+	}
+	if sc.debug {
+		v := true
+		sc.sampled = &v
+	}
+
+	traceId, exists := headers.Get(B3TraceID)
+	if !exists {
+		if _, isBolt := headers.(*sofarpc.BoltRequest); isBolt {
+			traceId, exists = headers.Get(models.TRACER_ID_KEY)
+		}
+	}
+	if !exists {
+		return sc, false
+	}
+	if sc.traceId, ok = normalizeId(traceId, 32); !ok {
+		return sc, false
+	}
+	if spanId, exists := headers.Get(B3SpanID); exists {
+		if sc.spanId, ok = normalizeId(spanId, 16); !ok {
+			return sc, false
+		}
+	}
+	if parentSpanId, exists := headers.Get(B3ParentSpanID); exists {
+		if sc.parentSpanId, ok = normalizeId(parentSpanId, 16); !ok {
+			return sc, false
+		}
+	}
+	return sc, true
+}
+
+// inject writes the b3 headers into the request headers.
+// the sofa trace id of the bolt request is set if it is absent, so the sofa tracer keeps the same trace
+func inject(headers types.HeaderMap, sc spanContext) {
+	headers.Set(B3TraceID, sc.traceId)
+	headers.Set(B3SpanID, sc.spanId)
+	if sc.parentSpanId != "" {
+		headers.Set(B3ParentSpanID, sc.parentSpanId)
+	} else {
+		headers.Del(B3ParentSpanID)
+	}
+	if sc.debug {
+		headers.Set(B3Flags, "1")
+	} else if sc.sampled != nil {
+		if *sc.sampled {
+			headers.Set(B3Sampled, "1")
+		} else {
+			headers.Set(B3Sampled, "0")
+		}
+	}
+	if _, isBolt := headers.(*sofarpc.BoltRequest); isBolt {
+		if _, exists := headers.Get(models.TRACER_ID_KEY); !exists {
+			headers.Set(models.TRACER_ID_KEY, sc.traceId)
+		}
+	}
+}
+
+// normalizeId checks the id is a hex string no longer than max, the short id is left padded with zeros
+// to 16 or 32 characters
+func normalizeId(id string, max int) (string, bool) {
+	if len(id) == 0 || len(id) > max {
+		return "", false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return "", false
+		}
+	}
+	id = strings.ToLower(id)
+	size := 16
+	if len(id) > 16 {
+		size = 32
+	}
+	if len(id) < size {
+		id = strings.Repeat("0", size-len(id)) + id
+	}
+	return id, true
+}
+
+// newId returns a random 64 bits id in hex
+func newId() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
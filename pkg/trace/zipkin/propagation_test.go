@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		headers protocol.CommonHeader
+		ok      bool
+		expect  spanContext
+		sampled string
+	}{
+		{
+			headers: protocol.CommonHeader{},
+			ok:      false,
+		},
+		{
+			headers: protocol.CommonHeader{
+				B3TraceID:      "463ac35c9f6413ad48485a3953bb6124",
+				B3SpanID:       "a2fb4a1d1a96d312",
+				B3ParentSpanID: "0020000000000001",
+				B3Sampled:      "1",
+			},
+			ok: true,
+			expect: spanContext{
+				traceId:      "463ac35c9f6413ad48485a3953bb6124",
+				spanId:       "a2fb4a1d1a96d312",
+				parentSpanId: "0020000000000001",
+			},
+			sampled: "true",
+		},
+		{
+			// short ids are padded
+			headers: protocol.CommonHeader{
+				B3TraceID: "ABC",
+				B3SpanID:  "1",
+				B3Sampled: "0",
+			},
+			ok: true,
+			expect: spanContext{
+				traceId: "0000000000000abc",
+				spanId:  "0000000000000001",
+			},
+			sampled: "false",
+		},
+		{
+			headers: protocol.CommonHeader{
+				B3TraceID: "not a hex id",
+				B3SpanID:  "a2fb4a1d1a96d312",
+			},
+			ok: false,
+		},
+		{
+			headers: protocol.CommonHeader{
+				B3TraceID: "463ac35c9f6413ad",
+				B3SpanID:  "463ac35c9f6413ad48485a3953bb6124",
+			},
+			ok: false,
+		},
+		{
+			// debug flag implies sampled
+			headers: protocol.CommonHeader{
+				B3TraceID: "463ac35c9f6413ad",
+				B3Flags:   "1",
+			},
+			ok: true,
+			expect: spanContext{
+				traceId: "463ac35c9f6413ad",
+				debug:   true,
+			},
+			sampled: "true",
+		},
+	}
+	for i, tc := range testCases {
+		sc, ok := extract(tc.headers)
+		if ok != tc.ok {
+			t.Errorf("#%d extract expected %v, but got %v", i, tc.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if sc.traceId != tc.expect.traceId || sc.spanId != tc.expect.spanId ||
+			sc.parentSpanId != tc.expect.parentSpanId || sc.debug != tc.expect.debug {
+			t.Errorf("#%d extract expected %+v, but got %+v", i, tc.expect, sc)
+		}
+		sampled := ""
+		if sc.sampled != nil {
+			if *sc.sampled {
+				sampled = "true"
+			} else {
+				sampled = "false"
+			}
+		}
+		if sampled != tc.sampled {
+			t.Errorf("#%d extract sampled expected %s, but got %s", i, tc.sampled, sampled)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	sampled := true
+	sc := spanContext{
+		traceId: "463ac35c9f6413ad48485a3953bb6124",
+		spanId:  "a2fb4a1d1a96d312",
+		sampled: &sampled,
+	}
+	headers := protocol.CommonHeader{
+		B3ParentSpanID: "0020000000000001",
+	}
+	inject(headers, sc)
+	if headers[B3TraceID] != sc.traceId || headers[B3SpanID] != sc.spanId || headers[B3Sampled] != "1" {
+		t.Errorf("inject b3 headers failed: %v", headers)
+	}
+	if _, ok := headers[B3ParentSpanID]; ok {
+		t.Errorf("the parent span id of the root span should be removed: %v", headers)
+	}
+	// the injected headers are extracted as the same context
+	if got, ok := extract(headers); !ok || got.traceId != sc.traceId || got.spanId != sc.spanId {
+		t.Errorf("extract the injected context failed: %+v", got)
+	}
+}
+
+func TestBoltTraceContext(t *testing.T) {
+	// the sofa trace id is taken if the bolt request has no b3 headers
+	request := &sofarpc.BoltRequest{
+		RequestHeader: map[string]string{
+			models.TRACER_ID_KEY: "0a0fe8ec1566894560154100113553",
+		},
+	}
+	sc, ok := extract(request)
+	if !ok || sc.traceId != "000a0fe8ec1566894560154100113553" {
+		t.Fatalf("extract bolt trace context failed: %+v", sc)
+	}
+
+	// the trace context is mapped into the bolt header, the sofa trace id is kept
+	sc.spanId = "a2fb4a1d1a96d312"
+	inject(request, sc)
+	if request.RequestHeader[B3TraceID] != sc.traceId || request.RequestHeader[B3SpanID] != sc.spanId {
+		t.Errorf("inject bolt trace context failed: %v", request.RequestHeader)
+	}
+	if request.RequestHeader[models.TRACER_ID_KEY] != "0a0fe8ec1566894560154100113553" {
+		t.Errorf("sofa trace id should be kept: %v", request.RequestHeader)
+	}
+
+	// the bolt request from the other protocol gets the sofa trace id
+	converted := &sofarpc.BoltRequest{
+		RequestHeader: map[string]string{},
+	}
+	inject(converted, sc)
+	if converted.RequestHeader[models.TRACER_ID_KEY] != sc.traceId {
+		t.Errorf("sofa trace id should be set: %v", converted.RequestHeader)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const sendTimeout = 5 * time.Second
+
+// reporter buffers the finished spans and sends them to the collector in batches.
+// the spans are dropped if the buffer is full, so the requests are never blocked by the collector
+type reporter struct {
+	options reporterOptions
+	client  *http.Client
+	spans   chan *spanModel
+	stats   types.Metrics
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type reporterOptions struct {
+	endpoint      string
+	batchSize     int
+	queueSize     int
+	flushInterval time.Duration
+}
+
+// reporterRef refers to the reporter of the current config, all the tracers share the ref,
+// so the tracers built before the config changes report to the reporter of the new config
+type reporterRef struct {
+	value atomic.Value // stores *reporter
+}
+
+func newReporterRef(r *reporter) *reporterRef {
+	ref := &reporterRef{}
+	ref.value.Store(r)
+	return ref
+}
+
+func (ref *reporterRef) load() *reporter {
+	r, _ := ref.value.Load().(*reporter)
+	return r
+}
+
+var (
+	reporterMutex   sync.Mutex
+	currentReporter = &reporterRef{}
+)
+
+// getReporter returns the ref of the reporter of the config, all the tracers share the same ref.
+// the reporter of the previous config is replaced, and stopped after its buffered spans are sent
+func getReporter(config *Config) *reporterRef {
+	options := reporterOptions{
+		endpoint:      config.CollectorEndpoint,
+		batchSize:     config.BatchSize,
+		queueSize:     config.QueueSize,
+		flushInterval: config.FlushInterval.Duration,
+	}
+	reporterMutex.Lock()
+	defer reporterMutex.Unlock()
+	old := currentReporter.load()
+	if old != nil && old.options == options {
+		return currentReporter
+	}
+	// the new spans are reported to the new reporter before the old one is stopped
+	currentReporter.value.Store(newReporter(options))
+	if old != nil {
+		old.Stop()
+	}
+	return currentReporter
+}
+
+func newReporter(options reporterOptions) *reporter {
+	r := &reporter{
+		options: options,
+		client:  &http.Client{Timeout: sendTimeout},
+		spans:   make(chan *spanModel, options.queueSize),
+		stats:   metrics.NewTraceStats("zipkin"),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	utils.GoWithRecover(r.run, nil)
+	return r
+}
+
+// Report buffers the span, the span is dropped if the buffer is full
+func (r *reporter) Report(span *spanModel) {
+	r.stats.Counter(metrics.TraceSpanSampled).Inc(1)
+	select {
+	case r.spans <- span:
+	default:
+		r.stats.Counter(metrics.TraceSpanDropped).Inc(1)
+	}
+}
+
+// Stop sends the buffered spans and stops the reporter
+func (r *reporter) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *reporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.options.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*spanModel, 0, r.options.batchSize)
+	for {
+		select {
+		case span := <-r.spans:
+			batch = append(batch, span)
+			if len(batch) >= r.options.batchSize {
+				r.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.send(batch)
+				batch = batch[:0]
+			}
+		case <-r.stop:
+			for {
+				select {
+				case span := <-r.spans:
+					batch = append(batch, span)
+					if len(batch) >= r.options.batchSize {
+						r.send(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						r.send(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *reporter) send(batch []*spanModel) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		r.onSendFailed(len(batch), err)
+		return
+	}
+	resp, err := r.client.Post(r.options.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		r.onSendFailed(len(batch), err)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		r.onSendFailed(len(batch), errors.New(resp.Status))
+		return
+	}
+	r.stats.Counter(metrics.TraceSpanReported).Inc(int64(len(batch)))
+	r.stats.Counter(metrics.TraceBatchSent).Inc(1)
+}
+
+func (r *reporter) onSendFailed(n int, err error) {
+	r.stats.Counter(metrics.TraceSpanSendFailed).Inc(int64(n))
+	log.DefaultLogger.Errorf("[trace] [zipkin] send %d spans to %s failed: %v", n, r.options.endpoint, err)
+}
@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the tag keys of the zipkin span
+const (
+	HTTP_METHOD = iota
+	HTTP_PATH
+	HTTP_STATUS_CODE
+	REQUEST_SIZE
+	RESPONSE_SIZE
+	UPSTREAM_ADDRESS
+	DOWNSTREAM_ADDRESS
+	REQUEST_ID
+	ERROR
+
+	TAG_END
+)
+
+var tagNames = [TAG_END]string{
+	HTTP_METHOD:        "http.method",
+	HTTP_PATH:          "http.path",
+	HTTP_STATUS_CODE:   "http.status_code",
+	REQUEST_SIZE:       "request.size",
+	RESPONSE_SIZE:      "response.size",
+	UPSTREAM_ADDRESS:   "upstream.address",
+	DOWNSTREAM_ADDRESS: "downstream.address",
+	REQUEST_ID:         "request.id",
+	ERROR:              "error",
+}
+
+// Span is a zipkin span, it is reported to the collector when finished if it is sampled
+type Span struct {
+	tracer    *Tracer
+	context   spanContext
+	kind      string
+	operation string
+	startTime time.Time
+	endTime   time.Time
+	tags      [TAG_END]string
+}
+
+func (s *Span) TraceId() string {
+	return s.context.traceId
+}
+
+func (s *Span) SpanId() string {
+	return s.context.spanId
+}
+
+func (s *Span) ParentSpanId() string {
+	return s.context.parentSpanId
+}
+
+func (s *Span) Sampled() bool {
+	return s.context.sampled != nil && *s.context.sampled
+}
+
+func (s *Span) SetOperation(operation string) {
+	s.operation = operation
+}
+
+func (s *Span) SetTag(key uint64, value string) {
+	if key < TAG_END {
+		s.tags[key] = value
+	}
+}
+
+func (s *Span) Tag(key uint64) string {
+	if key < TAG_END {
+		return s.tags[key]
+	}
+	return ""
+}
+
+func (s *Span) SetRequestInfo(reqinfo types.RequestInfo) {
+	s.tags[REQUEST_SIZE] = strconv.FormatUint(reqinfo.BytesReceived(), 10)
+	s.tags[RESPONSE_SIZE] = strconv.FormatUint(reqinfo.BytesSent(), 10)
+	if reqinfo.UpstreamHost() != nil {
+		s.tags[UPSTREAM_ADDRESS] = reqinfo.UpstreamHost().AddressString()
+	}
+	if reqinfo.DownstreamRemoteAddress() != nil {
+		s.tags[DOWNSTREAM_ADDRESS] = reqinfo.DownstreamRemoteAddress().String()
+	}
+	s.tags[REQUEST_ID] = reqinfo.RequestID()
+	code := reqinfo.ResponseCode()
+	s.tags[HTTP_STATUS_CODE] = strconv.Itoa(code)
+	if code >= 500 {
+		s.tags[ERROR] = strconv.Itoa(code)
+	}
+}
+
+func (s *Span) FinishSpan() {
+	s.endTime = time.Now()
+	if s.Sampled() && s.tracer != nil && s.tracer.reporter != nil {
+		if r := s.tracer.reporter.load(); r != nil {
+			r.Report(s.model())
+		}
+	}
+}
+
+// InjectContext sets the b3 headers of the span into the upstream request headers
+func (s *Span) InjectContext(requestHeaders types.HeaderMap) {
+	if requestHeaders != nil {
+		inject(requestHeaders, s.context)
+	}
+}
+
+func (s *Span) SpawnChild(operationName string, startTime time.Time) types.Span {
+	child := &Span{
+		tracer:    s.tracer,
+		kind:      KindClient,
+		operation: operationName,
+		startTime: startTime,
+		context: spanContext{
+			traceId:      s.context.traceId,
+			spanId:       newId(),
+			parentSpanId: s.context.spanId,
+			sampled:      s.context.sampled,
+			debug:        s.context.debug,
+		},
+	}
+	return child
+}
+
+// spanModel is the zipkin v2 json model of the span
+type spanModel struct {
+	TraceId        string            `json:"traceId"`
+	Id             string            `json:"id"`
+	ParentId       string            `json:"parentId,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Kind           string            `json:"kind,omitempty"`
+	Timestamp      int64             `json:"timestamp"`
+	Duration       int64             `json:"duration"`
+	Debug          bool              `json:"debug,omitempty"`
+	LocalEndpoint  *endpoint         `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *endpoint         `json:"remoteEndpoint,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type endpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+func (s *Span) model() *spanModel {
+	m := &spanModel{
+		TraceId:   s.context.traceId,
+		Id:        s.context.spanId,
+		ParentId:  s.context.parentSpanId,
+		Name:      s.operation,
+		Kind:      s.kind,
+		Timestamp: s.startTime.UnixNano() / int64(time.Microsecond),
+		Duration:  int64(s.endTime.Sub(s.startTime) / time.Microsecond),
+		Debug:     s.context.debug,
+		Tags:      make(map[string]string),
+	}
+	// zipkin ignores the duration of zero
+	if m.Duration <= 0 {
+		m.Duration = 1
+	}
+	if s.tracer != nil {
+		m.LocalEndpoint = &endpoint{
+			ServiceName: s.tracer.serviceName,
+			IPv4:        s.tracer.localIp,
+		}
+	}
+	for key, value := range s.tags {
+		if value != "" {
+			m.Tags[tagNames[key]] = value
+		}
+	}
+	// the remote of the server span is the downstream, the remote of the client span is the upstream
+	if s.kind == KindServer {
+		m.RemoteEndpoint = newEndpoint(s.tags[DOWNSTREAM_ADDRESS])
+	} else {
+		m.RemoteEndpoint = newEndpoint(s.tags[UPSTREAM_ADDRESS])
+	}
+	return m
+}
+
+func newEndpoint(address string) *endpoint {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	ep := &endpoint{}
+	if ip.To4() != nil {
+		ep.IPv4 = ip.String()
+	} else {
+		ep.IPv6 = ip.String()
+	}
+	ep.Port, _ = strconv.Atoi(port)
+	return ep
+}
@@ -0,0 +1,121 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// zipkin span kinds
+const (
+	KindServer = "SERVER"
+	KindClient = "CLIENT"
+)
+
+// Tracer builds the zipkin spans of a protocol
+type Tracer struct {
+	proto       types.Protocol
+	serviceName string
+	sampleRate  float64
+	localIp     string
+	reporter    *reporterRef
+}
+
+// Start builds the span of the request, the trace context is extracted from the request headers.
+// the span is the child of the extracted span, a new trace is started if no trace context is found
+func (t *Tracer) Start(ctx context.Context, request interface{}, startTime time.Time) types.Span {
+	span := &Span{
+		tracer:    t,
+		kind:      KindServer,
+		startTime: startTime,
+	}
+
+	headers, _ := request.(types.HeaderMap)
+	if sc, ok := extract(headers); ok {
+		span.context = sc
+		span.context.parentSpanId = sc.spanId
+	} else {
+		span.context = spanContext{
+			traceId: newId(),
+			sampled: sc.sampled,
+			debug:   sc.debug,
+		}
+	}
+	span.context.spanId = newId()
+	if span.context.sampled == nil {
+		sampled := t.sample()
+		span.context.sampled = &sampled
+	}
+
+	// the egress listener proxies the requests of the local service, mosn is the client of the upstream
+	if lType, ok := mosnctx.Get(ctx, types.ContextKeyListenerType).(v2.ListenerType); ok && lType == v2.EGRESS {
+		span.kind = KindClient
+	}
+
+	t.setRequestTags(span, request)
+	return span
+}
+
+func (t *Tracer) sample() bool {
+	if t.sampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < t.sampleRate
+}
+
+// setRequestTags sets the operation name and the tags of the request
+func (t *Tracer) setRequestTags(span *Span, request interface{}) {
+	switch req := request.(type) {
+	case http.RequestHeader:
+		if req.RequestHeader != nil {
+			path := string(req.RequestURI())
+			if idx := strings.IndexByte(path, '?'); idx >= 0 {
+				path = path[:idx]
+			}
+			span.SetTag(HTTP_METHOD, string(req.Method()))
+			span.SetTag(HTTP_PATH, path)
+		}
+	case *mhttp2.ReqHeader:
+		if req.Req != nil && req.Req.URL != nil {
+			span.SetTag(HTTP_METHOD, req.Req.Method)
+			span.SetTag(HTTP_PATH, req.Req.URL.Path)
+		}
+	case sofarpc.SofaRpcCmd:
+		if service, ok := req.Get(models.SERVICE_KEY); ok {
+			method, _ := req.Get(models.TARGET_METHOD)
+			span.SetOperation(service + "#" + method)
+			return
+		}
+	}
+	if method, path := span.Tag(HTTP_METHOD), span.Tag(HTTP_PATH); method != "" {
+		span.SetOperation(method + " " + path)
+		return
+	}
+	span.SetOperation(string(t.proto))
+}
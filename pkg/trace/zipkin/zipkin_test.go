@@ -0,0 +1,246 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/trace"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestParseConfig(t *testing.T) {
+	if _, err := ParseConfig(map[string]interface{}{}); err != ErrNoCollector {
+		t.Errorf("expected no collector error, but got %v", err)
+	}
+	if _, err := ParseConfig(map[string]interface{}{
+		"collector_endpoint": "http://127.0.0.1:9411/api/v2/spans",
+		"sample_rate":        1.5,
+	}); err != ErrInvalidSampleRate {
+		t.Errorf("expected invalid sample rate error, but got %v", err)
+	}
+	config, err := ParseConfig(map[string]interface{}{
+		"collector_endpoint": "http://127.0.0.1:9411/api/v2/spans",
+		"service_name":       "test",
+		"sample_rate":        0.5,
+		"flush_interval":     "100ms",
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if config.ServiceName != "test" || *config.SampleRate != 0.5 ||
+		config.FlushInterval.Duration != 100*time.Millisecond ||
+		config.BatchSize != defaultBatchSize || config.QueueSize != defaultQueueSize {
+		t.Errorf("unexpected config: %+v", config)
+	}
+}
+
+func TestTracerStart(t *testing.T) {
+	tracer := &Tracer{
+		proto:       protocol.HTTP1,
+		serviceName: "test",
+		sampleRate:  0,
+	}
+	// the span is the child of the downstream span
+	headers := protocol.CommonHeader{
+		B3TraceID: "463ac35c9f6413ad48485a3953bb6124",
+		B3SpanID:  "a2fb4a1d1a96d312",
+		B3Sampled: "1",
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerType, v2.INGRESS)
+	span := tracer.Start(ctx, headers, time.Now()).(*Span)
+	if span.TraceId() != "463ac35c9f6413ad48485a3953bb6124" || span.ParentSpanId() != "a2fb4a1d1a96d312" ||
+		span.SpanId() == "" || span.SpanId() == "a2fb4a1d1a96d312" {
+		t.Errorf("unexpected span context: %+v", span.context)
+	}
+	if !span.Sampled() || span.kind != KindServer {
+		t.Errorf("expected sampled server span, but got sampled: %v, kind: %s", span.Sampled(), span.kind)
+	}
+	// the upstream request carries the context of the span
+	upstream := protocol.CommonHeader{}
+	span.InjectContext(upstream)
+	if upstream[B3TraceID] != span.TraceId() || upstream[B3SpanID] != span.SpanId() ||
+		upstream[B3ParentSpanID] != "a2fb4a1d1a96d312" || upstream[B3Sampled] != "1" {
+		t.Errorf("unexpected upstream headers: %v", upstream)
+	}
+
+	// a new trace is started, the sample rate decides the sampling
+	ctx = mosnctx.WithValue(context.Background(), types.ContextKeyListenerType, v2.EGRESS)
+	span = tracer.Start(ctx, protocol.CommonHeader{}, time.Now()).(*Span)
+	if span.TraceId() == "" || span.ParentSpanId() != "" || span.Sampled() || span.kind != KindClient {
+		t.Errorf("unexpected new span: %+v, kind: %s", span.context, span.kind)
+	}
+	if span.operation != string(protocol.HTTP1) {
+		t.Errorf("unexpected operation: %s", span.operation)
+	}
+	// not sampled span is not reported
+	span.FinishSpan()
+}
+
+func TestReporterBatch(t *testing.T) {
+	var mutex sync.Mutex
+	var received []*spanModel
+	var batches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var spans []*spanModel
+		if err := json.Unmarshal(body, &spans); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		received = append(received, spans...)
+		batches++
+		mutex.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	r := newReporter(reporterOptions{
+		endpoint:      server.URL,
+		batchSize:     2,
+		queueSize:     10,
+		flushInterval: time.Hour,
+	})
+	tracer := &Tracer{
+		proto:       protocol.HTTP1,
+		serviceName: "test",
+		sampleRate:  1,
+		reporter:    newReporterRef(r),
+	}
+	for i := 0; i < 5; i++ {
+		span := tracer.Start(context.Background(), protocol.CommonHeader{}, time.Now())
+		span.SetTag(UPSTREAM_ADDRESS, "127.0.0.1:8080")
+		span.FinishSpan()
+	}
+	// the last span is sent when the reporter is stopped
+	r.Stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(received) != 5 || batches != 3 {
+		t.Fatalf("expected 5 spans in 3 batches, but got %d spans in %d batches", len(received), batches)
+	}
+	s := received[0]
+	if s.Kind != KindServer || s.LocalEndpoint == nil || s.LocalEndpoint.ServiceName != "test" ||
+		s.Tags["upstream.address"] != "127.0.0.1:8080" || s.Duration <= 0 {
+		t.Errorf("unexpected span: %+v", s)
+	}
+	if count := r.stats.Counter(metrics.TraceSpanReported).Count(); count < 5 {
+		t.Errorf("expected 5 spans reported, but got %d", count)
+	}
+}
+
+func TestReporterReplaced(t *testing.T) {
+	var mutex sync.Mutex
+	received := map[string]int{}
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var spans []*spanModel
+			json.Unmarshal(body, &spans)
+			mutex.Lock()
+			received[name] += len(spans)
+			mutex.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	}
+	server1 := newServer("server1")
+	defer server1.Close()
+	server2 := newServer("server2")
+	defer server2.Close()
+
+	config := &Config{
+		BatchSize:     1,
+		QueueSize:     10,
+		FlushInterval: &v2.DurationConfig{Duration: time.Hour},
+	}
+	config.CollectorEndpoint = server1.URL
+	tracer := &Tracer{
+		proto:      protocol.HTTP1,
+		sampleRate: 1,
+		reporter:   getReporter(config),
+	}
+	old := tracer.reporter.load()
+	// the reporter of the new config replaces the old one
+	config.CollectorEndpoint = server2.URL
+	if ref := getReporter(config); ref != tracer.reporter || ref.load() == old {
+		t.Fatal("expected the reporter replaced in the shared ref")
+	}
+	// the tracer built before the config changed reports to the new reporter
+	tracer.Start(context.Background(), protocol.CommonHeader{}, time.Now()).FinishSpan()
+	r := tracer.reporter.load()
+	r.Stop()
+	// the next test gets a new reporter
+	currentReporter.value.Store((*reporter)(nil))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if received["server1"] != 0 || received["server2"] != 1 {
+		t.Fatalf("expected the span reported to the new collector, but got %v", received)
+	}
+}
+
+func TestReporterDrop(t *testing.T) {
+	// the reporter is not running, the spans out of the buffer are dropped
+	r := &reporter{
+		spans: make(chan *spanModel, 1),
+		stats: metrics.NewTraceStats("test_drop"),
+	}
+	dropped := r.stats.Counter(metrics.TraceSpanDropped).Count()
+	r.Report(&spanModel{})
+	r.Report(&spanModel{})
+	r.Report(&spanModel{})
+	if count := r.stats.Counter(metrics.TraceSpanDropped).Count() - dropped; count != 2 {
+		t.Errorf("expected 2 spans dropped, but got %d", count)
+	}
+}
+
+func TestDriverInit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	defer trace.Disable()
+
+	if err := trace.Init(DriverName, map[string]interface{}{
+		"collector_endpoint": server.URL,
+	}); err != nil {
+		t.Fatalf("init zipkin driver failed: %v", err)
+	}
+	for _, proto := range []types.Protocol{protocol.HTTP1, protocol.HTTP2, protocol.SofaRPC} {
+		if _, ok := trace.Tracer(proto).(*Tracer); !ok {
+			t.Errorf("no zipkin tracer of %s", proto)
+		}
+	}
+	// the tracers share the reporter
+	if trace.Tracer(protocol.HTTP1).(*Tracer).reporter != trace.Tracer(protocol.SofaRPC).(*Tracer).reporter {
+		t.Error("the tracers should share the reporter")
+	}
+}
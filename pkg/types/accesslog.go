@@ -59,11 +59,38 @@ const (
 	LogUpstreamHostSelectedGetter string = "UpstreamHostSelected"
 )
 
+// The identification of a request info's content in the upper case style, such as %START_TIME%
+const (
+	LogKeyStartTime                = "START_TIME"
+	LogKeyRequestReceivedDuration  = "REQUEST_RECEIVED_DURATION"
+	LogKeyResponseReceivedDuration = "RESPONSE_RECEIVED_DURATION"
+	LogKeyUpstreamDuration         = "UPSTREAM_DURATION"
+	LogKeyRequestFinishedDuration  = "REQUEST_FINISHED_DURATION"
+	LogKeyDuration                 = "DURATION"
+	LogKeyBytesSent                = "BYTES_SENT"
+	LogKeyBytesReceived            = "BYTES_RECEIVED"
+	LogKeyProtocol                 = "PROTOCOL"
+	LogKeyResponseCode             = "RESPONSE_CODE"
+	LogKeyResponseFlags            = "RESPONSE_FLAGS"
+	LogKeyUpstreamHost             = "UPSTREAM_HOST"
+	LogKeyUpstreamLocalAddress     = "UPSTREAM_LOCAL_ADDRESS"
+	LogKeyDownstreamLocalAddress   = "DOWNSTREAM_LOCAL_ADDRESS"
+	LogKeyDownstreamRemoteAddress  = "DOWNSTREAM_REMOTE_ADDRESS"
+	LogKeyRequestID                = "REQUEST_ID"
+	// LogKeyMethod and LogKeyPath are got from the request headers
+	LogKeyMethod = "METHOD"
+	LogKeyPath   = "PATH"
+)
+
 const (
 	// ReqHeaderPrefix is the prefix of request header's formatter
 	ReqHeaderPrefix string = "REQ."
 	// RespHeaderPrefix is the prefix of response header's formatter
 	RespHeaderPrefix string = "RESP."
+	// ReqHeaderFunc is the request header's formatter in the upper case style, such as %REQ(service)%
+	ReqHeaderFunc string = "REQ"
+	// RespHeaderFunc is the response header's formatter in the upper case style, such as %RESP(server)%
+	RespHeaderFunc string = "RESP"
 )
 
 const (
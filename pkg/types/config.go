@@ -34,8 +34,10 @@ var (
 
 	MosnConfigPath = MosnBasePath + string(os.PathSeparator) + "conf"
 
-	ReconfigureDomainSocket    = MosnConfigPath + string(os.PathSeparator) + "reconfig.sock"
-	TransferConnDomainSocket   = MosnConfigPath + string(os.PathSeparator) + "conn.sock"
+	ReconfigureDomainSocket  = MosnConfigPath + string(os.PathSeparator) + "reconfig.sock"
+	TransferConnDomainSocket = MosnConfigPath + string(os.PathSeparator) + "conn.sock"
+	// TransferStatsDomainSocket is reserved, the metrics are not transferred by socket,
+	// the counters and gauges are kept in the shm zone during the smooth upgrade
 	TransferStatsDomainSocket  = MosnConfigPath + string(os.PathSeparator) + "stats.sock"
 	TransferListenDomainSocket = MosnConfigPath + string(os.PathSeparator) + "listen.sock"
 )
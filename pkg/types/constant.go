@@ -33,14 +33,30 @@ const (
 	HeaderStremEnd      = "x-mosn-endstream"
 	HeaderRPCService    = "x-mosn-rpc-service"
 	HeaderRPCMethod     = "x-mosn-rpc-method"
+	HeaderHops          = "x-mosn-hops"
+	HeaderInstance      = "x-mosn-instance"
+	HeaderLocalReply    = "x-mosn-local-reply"
+	// HeaderOriginalDstHost is the destination ip:port of the ORIGINAL_DST clusters using the http header
+	HeaderOriginalDstHost = "x-mosn-original-dst-host"
+	// HeaderRPCStatus is the protocol status of a rpc hijack reply, it overrides the status mapped from HeaderStatus
+	HeaderRPCStatus = "x-mosn-rpc-status"
+	// HeaderUpstreamTimeout tightens the timeout of a request in milliseconds, it is capped by the route's timeout
+	// and removed before the request is sent to the upstream
+	HeaderUpstreamTimeout = "x-mosn-upstream-timeout-ms"
+)
+
+// Local reply reasons, the value of HeaderLocalReply
+const (
+	LocalReplyNoClusterConfigured = "no_cluster_configured"
 )
 
 // Error messages
 const (
-	ChannelFullException = "Channel is full"
-	CodecException       = "codec exception occurs"
-	SerializeException   = "serialize exception occurs"
-	DeserializeException = "deserialize exception occurs"
+	ChannelFullException   = "Channel is full"
+	CodecException         = "codec exception occurs"
+	SerializeException     = "serialize exception occurs"
+	DeserializeException   = "deserialize exception occurs"
+	WriteResponseException = "write response exception occurs"
 
 	NoStatusCodeForHijackException = "no status code found for hijack reply"
 )
@@ -51,6 +67,7 @@ var (
 	ErrCodecException       = errors.New(CodecException)
 	ErrSerializeException   = errors.New(SerializeException)
 	ErrDeserializeException = errors.New(DeserializeException)
+	ErrWriteResponse        = errors.New(WriteResponseException)
 
 	ErrNoStatusCodeForHijack = errors.New(NoStatusCodeForHijackException)
 )
@@ -66,5 +83,6 @@ const (
 	NoHealthUpstreamCode  = 502
 	UpstreamOverFlowCode  = 503
 	TimeoutExceptionCode  = 504
+	LoopDetectedCode      = 508
 	LimitExceededCode     = 509
 )
@@ -34,12 +34,35 @@ const (
 	ContextKeyAccessLogs
 	ContextOriRemoteAddr
 	ContextKeyAcceptChan
-	ContextKeyAcceptBuffer
+	// ContextKeyTransferState stores the *TransferState of a connection transferred from the old mosn
+	ContextKeyTransferState
 	ContextKeyConnectionFd
 	ContextSubProtocol
 	ContextKeyTraceSpanKey
 	ContextKeyActiveSpan
 	ContextKeyTraceId
+	ContextKeyStreamValues
+	ContextKeyConnection
+	ContextKeyListenerStats
+	ContextKeySofaRPCExtendConfig
+	ContextKeyUpstreamPriority
+	ContextKeyOriginalDst
+	ContextKeyLogger
+	ContextKeyPayloadDumper
+	// ContextKeyMaxConcurrentStreams stores a *uint32 of the listener's max concurrent streams, loaded atomically
+	ContextKeyMaxConcurrentStreams
+	// ContextKeyUpstreamServerName stores the host of the request, it is the default server name of the upstream tls connections
+	ContextKeyUpstreamServerName
+	// ContextOriLocalAddr stores the net.Addr replaced the local address of the connection, such as the PROXY protocol destination
+	ContextOriLocalAddr
+	// ContextKeyTransferConnection stores the transfer_connection bool of the listener if it is configured
+	ContextKeyTransferConnection
+	// ContextKeyDownstreamProtocol stores the stream protocol of the connection transferred from the old mosn
+	ContextKeyDownstreamProtocol
+	// ContextKeyRequestIDConfig stores the *v2.RequestIDConfig of the listener if it is configured
+	ContextKeyRequestIDConfig
+	// ContextKeyRequestID stores the request id string of the stream
+	ContextKeyRequestID
 	ContextKeyEnd
 )
 
@@ -17,8 +17,13 @@
 
 package types
 
-import "time"
+import (
+	"time"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+// KeepAlive sends the heartbeats on an upstream connection, it is implemented by the stream packages
 type KeepAlive interface {
 	// SendKeepAlive sends a heartbeat request for keepalive
 	SendKeepAlive()
@@ -37,7 +42,42 @@ type KeepAliveStatus int
 const (
 	KeepAliveSuccess KeepAliveStatus = iota
 	KeepAliveTimeout
+	// KeepAliveThresholdExceeded means the heartbeats are timeout too many times, the connection is closed
+	KeepAliveThresholdExceeded
+	// KeepAliveSent means a heartbeat is sent, it is followed by a success, a timeout or a cancel
+	KeepAliveSent
+	// KeepAliveCanceled means an outstanding heartbeat is dropped, as the keepalive is stopped
+	KeepAliveCanceled
+	// KeepAliveClosed means the connection is closed by the keepalive
+	KeepAliveClosed
 )
 
 // KeepAliveCallback is a callback when keep alive handle response/timeout
 type KeepAliveCallback func(KeepAliveStatus)
+
+// Group of the keep alive default config
+const (
+	DefaultKeepAliveTimeout   = time.Second
+	DefaultKeepAliveFailCount = 6
+	DefaultKeepAliveJitter    = 0.2
+	// DefaultKeepAliveBackoff is the max interval as times of the interval
+	DefaultKeepAliveBackoff = 8
+)
+
+// KeepAliveConfig is the config of the heartbeats on the upstream connections
+type KeepAliveConfig struct {
+	// Interval is the heartbeat interval, zero means the heartbeats are sent only when the connection is idle
+	Interval time.Duration
+	// Timeout is the max time waiting for a heartbeat response
+	Timeout time.Duration
+	// FailCountToClose is the consecutive heartbeat timeouts that close the connection
+	FailCountToClose uint32
+	// IntervalJitter is the max ratio of the interval that is randomly added or subtracted,
+	// so the heartbeats of the connections are not synchronized
+	IntervalJitter float64
+	// MaxInterval caps the interval, which is doubled after each timeout and reset after a success,
+	// the backoff is disabled if it is not greater than the interval
+	MaxInterval time.Duration
+	// HTTPProbe is the heartbeat request of the http1 connections, nil means no heartbeats are sent
+	HTTPProbe *v2.HTTPKeepAliveProbe
+}
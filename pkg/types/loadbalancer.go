@@ -20,6 +20,7 @@ package types
 import (
 	"context"
 	"net"
+	"time"
 )
 
 // LoadBalancerType is the load balancer's type
@@ -29,8 +30,37 @@ type LoadBalancerType string
 const (
 	RoundRobin LoadBalancerType = "LB_ROUNDROBIN"
 	Random     LoadBalancerType = "LB_RANDOM"
+	RingHash   LoadBalancerType = "LB_RINGHASH"
 )
 
+// The default ring size of the ring hash load balancer
+const (
+	DefaultRingHashMinimumSize = uint64(1024)
+	DefaultRingHashMaximumSize = uint64(8 * 1024 * 1024)
+)
+
+// RingHashConfig controls how many virtual nodes are built in the ring of the ring hash load balancer
+type RingHashConfig struct {
+	MinimumRingSize uint64
+	MaximumRingSize uint64
+}
+
+// The default ramp of the slow start
+const (
+	DefaultSlowStartAggression      = 1.0
+	DefaultSlowStartMinWeightFactor = 0.1
+)
+
+// SlowStartConfig controls how the traffic to a host is ramped up after it is added or becomes healthy
+type SlowStartConfig struct {
+	// Duration is the slow start window, zero means disabled
+	Duration time.Duration
+	// Aggression is the exponent of the ramp, the weight factor is (elapsed / duration) ^ (1 / aggression)
+	Aggression float64
+	// MinWeightFactor is the min weight factor during the slow start
+	MinWeightFactor float64
+}
+
 // LoadBalancer is a upstream load balancer.
 // When a request comes, the LoadBalancer will choose a upstream cluster's host to handle the request.
 type LoadBalancer interface {
@@ -57,6 +87,17 @@ type LoadBalancerContext interface {
 
 	// DownstreamContext returns the downstream context
 	DownstreamContext() context.Context
+
+	// HashKey returns the hash key computed from the request for the hash based load balancers,
+	// false means no key is computed, and the load balancer chooses a host randomly
+	HashKey() (uint64, bool)
+}
+
+// RetryLoadBalancerContext is implemented by the load balancer context of a request that may be retried,
+// the host tried already is not chosen again if there are other healthy hosts
+type RetryLoadBalancerContext interface {
+	// ShouldSelectAnotherHost returns true if the host is tried already
+	ShouldSelectAnotherHost(host Host) bool
 }
 
 // LBSubsetEntry is a entry that stored in the subset hierarchy.
@@ -37,6 +37,7 @@ const (
 	ErrorKeyConfigDump             = ErrorModuleMosn + ErrorSubModuleCommon + "config_dump_failed"
 	ErrorKeyReconfigure            = ErrorModuleMosn + ErrorSubModuleCommon + "reconfigure_failed"
 	ErrorKeyTLSFallback            = ErrorModuleMosn + ErrorSubModuleCommon + "tls_fallback"
+	ErrorKeyTLSReload              = ErrorModuleMosn + ErrorSubModuleCommon + "tls_reload_failed"
 	ErrorKeyRouteUpdate            = ErrorModuleMosn + ErrorSubModuleDynamicConfig + "route_update_failed"
 	ErrorKeyRouteAppend            = ErrorModuleMosn + ErrorSubModuleDynamicConfig + "route_append_failed"
 	ErrorKeyRouteClean             = ErrorModuleMosn + ErrorSubModuleDynamicConfig + "route_clean_failed"
@@ -52,5 +53,6 @@ const (
 	ErrorKeyUpstreamConn           = ErrorModuleMosn + ErrorSubModuleProxy + "upstream_conn_failed"
 	ErrorKeyCodec                  = ErrorModuleMosn + ErrorSubModuleProxy + "codec_error"
 	ErrorKeyHeartBeat              = ErrorModuleMosn + ErrorSubModuleProxy + "heartbeat_unknown"
+	ErrorKeyProtocolConv           = ErrorModuleMosn + ErrorSubModuleProxy + "protocol_convert_failed"
 	// TODO: more keys
 )
@@ -23,7 +23,7 @@ import (
 )
 
 // Metrics is a wrapper interface for go-metrics
-// support Counter, Gauge Histogram
+// support Counter, Gauge, Histogram, Meter and Timer
 type Metrics interface {
 	// Type returns metrics logical type, e.g. 'downstream'/'upstream', this is more like the Subsystem concept
 	Type() string
@@ -46,6 +46,14 @@ type Metrics interface {
 	// if the key is registered by other interface, it will be panic
 	Histogram(key string) metrics.Histogram
 
+	// Meter creates or returns a go-metrics meter by key, it records the 1, 5 and 15 minutes rates
+	// if the key is registered by other interface, it will be panic
+	Meter(key string) metrics.Meter
+
+	// Timer creates or returns a go-metrics timer by key, it is a histogram of durations with a meter of rates
+	// if the key is registered by other interface, it will be panic
+	Timer(key string) metrics.Timer
+
 	// Each call the given function for each registered metric.
 	Each(func(string, interface{}))
 
@@ -128,10 +128,43 @@ type Listener interface {
 	Close(lctx context.Context) error
 }
 
+// ListenerStats defines a listener's statistics information,
+// the requests are counted by the proxy, the response codes and the heartbeats are counted by the server streams
+type ListenerStats struct {
+	DownstreamConnectionTotal   metrics.Counter
+	DownstreamConnectionActive  metrics.Counter
+	DownstreamConnectionDestroy metrics.Counter
+	DownstreamBytesReadTotal    metrics.Counter
+	DownstreamBytesWriteTotal   metrics.Counter
+	DownstreamRequestTotal      metrics.Counter
+	DownstreamRequestActive     metrics.Counter
+	DownstreamResponseCode1xx   metrics.Counter
+	DownstreamResponseCode2xx   metrics.Counter
+	DownstreamResponseCode3xx   metrics.Counter
+	DownstreamResponseCode4xx   metrics.Counter
+	DownstreamResponseCode5xx   metrics.Counter
+	DownstreamHeartbeatTotal    metrics.Counter
+	// the connections closed because of the listener's max connections
+	DownstreamConnectionOverflow metrics.Counter
+	// the configured limits, 0 means no limit
+	DownstreamConnectionLimit metrics.Gauge
+	DownstreamStreamLimit     metrics.Gauge
+	// the connections closed because of a missing or malformed PROXY protocol header
+	DownstreamProxyProtocolError metrics.Counter
+	// the connections crossed the write buffer watermarks, and the connections above the high watermark now
+	DownstreamWriteBufferHighWatermarkTotal metrics.Counter
+	DownstreamWriteBufferLowWatermarkTotal  metrics.Counter
+	DownstreamWriteBufferBackedUp           metrics.Counter
+	// the configured watermarks, 0 means no flow control
+	DownstreamWriteBufferHighWatermark metrics.Gauge
+	DownstreamWriteBufferLowWatermark  metrics.Gauge
+}
+
 // ListenerEventListener is a Callback invoked by a listener.
 type ListenerEventListener interface {
-	// OnAccept is called on new connection accepted
-	OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, c chan Connection, buf []byte)
+	// OnAccept is called on new connection accepted, the connections transferred from the old mosn
+	// are sent to the channel c, and their state is restored by the transfer state
+	OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, c chan Connection, state *TransferState)
 
 	// OnNewConnection is called on new mosn connection created
 	OnNewConnection(ctx context.Context, conn Connection)
@@ -140,6 +173,24 @@ type ListenerEventListener interface {
 	OnClose()
 }
 
+// TransferState is the state of a connection transferred from the old mosn in the hot upgrade
+type TransferState struct {
+	// Buffer is the data read but not consumed by the old mosn, the partial frames are resumed with it
+	Buffer []byte
+	// Protocol is the stream protocol of the connection, the protocol is sniffed again if it is empty
+	Protocol Protocol
+	// RemoteAddr and LocalAddr replace the addresses of the connection, such as the PROXY protocol addresses
+	RemoteAddr net.Addr
+	LocalAddr  net.Addr
+}
+
+// UDPListenerEventListener is a Callback invoked by a udp listener.
+type UDPListenerEventListener interface {
+	// OnDatagram is called on each datagram received from the address,
+	// the data is reused after the callback returns
+	OnDatagram(conn net.PacketConn, data []byte, from net.Addr)
+}
+
 // FilterStatus type
 type FilterStatus string
 
@@ -233,6 +284,9 @@ type Connection interface {
 	// SetRemoteAddr is used for originaldst we need to replace remoteAddr
 	SetRemoteAddr(address net.Addr)
 
+	// SetLocalAddr replaces the local address, such as the destination address of the PROXY protocol header
+	SetLocalAddr(address net.Addr)
+
 	// AddConnectionEventListener add a listener method will be called when connection event occur.
 	AddConnectionEventListener(listener ConnectionEventListener)
 
@@ -264,6 +318,15 @@ type Connection interface {
 	// BufferLimit returns the buffer limit.
 	BufferLimit() uint32
 
+	// SetWriteBufferWatermarks sets the watermarks of the bytes written but not sent, it should be called before the connection starts.
+	// OnAboveWriteBufferHighWatermark is notified once the bytes are above the high watermark,
+	// and OnBelowWriteBufferLowWatermark is notified once they drain to the low watermark.
+	// The high watermark 0 disables the notifications, the low watermark is half of the high watermark if it is not less than it
+	SetWriteBufferWatermarks(low, high uint32)
+
+	// AboveWriteBufferHighWatermark returns whether the write buffer is above the high watermark and not drained yet
+	AboveWriteBufferHighWatermark() bool
+
 	// SetLocalAddress sets a local address
 	SetLocalAddress(localAddress net.Addr, restored bool)
 
@@ -309,6 +372,26 @@ type ClientConnection interface {
 
 	// connect to server in a async way
 	Connect() error
+
+	// SetTCPOptions sets the socket options applied to the connection once it is dialed,
+	// it should be called before Connect
+	SetTCPOptions(options TCPOptions)
+
+	// SetProxyProtocolHeader sets the PROXY protocol header written once the connection is dialed,
+	// before the tls handshake, it should be called before Connect
+	SetProxyProtocolHeader(header []byte)
+}
+
+// TCPOptions is the socket options of a client connection
+type TCPOptions struct {
+	// Keepalive enables the tcp keepalive, zero idle, interval and probes use the system default
+	Keepalive         bool
+	KeepaliveIdle     time.Duration
+	KeepaliveInterval time.Duration
+	KeepaliveProbes   int
+	// UserTimeout is the max time that transmitted data may remain unacknowledged
+	// before the connection is closed, zero means the system default
+	UserTimeout time.Duration
 }
 
 // ConnectionEvent type
@@ -326,6 +409,9 @@ const (
 	ConnectFailed   ConnectionEvent = "ConnectFailed"
 	OnReadTimeout   ConnectionEvent = "OnReadTimeout"
 	OnWriteTimeout  ConnectionEvent = "OnWriteTimeout"
+	// the write buffer crosses the watermarks, the reading of the peer connection should be disabled and enabled
+	OnAboveWriteBufferHighWatermark ConnectionEvent = "OnAboveWriteBufferHighWatermark"
+	OnBelowWriteBufferLowWatermark  ConnectionEvent = "OnBelowWriteBufferLowWatermark"
 )
 
 // IsClose represents whether the event is triggered by connection close
@@ -383,6 +469,18 @@ type ConnectionHandler interface {
 
 	// StopConnection Stop Connection
 	StopConnection()
+
+	// NumConnections reports the connections accepted by the listeners
+	NumConnections() uint64
+
+	// CloseConnections closes all the connections accepted by the listeners
+	CloseConnections()
+
+	// DrainConnections drains the connections accepted by the listeners for the graceful shutdown
+	DrainConnections()
+
+	// ActiveStreams reports the active streams of the connections accepted by the listeners
+	ActiveStreams() int64
 }
 
 // ReadFilter is a connection binary read filter, registered by FilterManager.AddReadFilter
@@ -459,6 +557,34 @@ type NetworkFilterChainFactory interface {
 	CreateFilterChain(context context.Context, clusterManager ClusterManager, callbacks NetWorkFilterChainFactoryCallbacks)
 }
 
+// DrainableFilter is implemented by the read filters that drain the connection for the graceful shutdown
+type DrainableFilter interface {
+	// Drain stops keeping the connection alive, the connection is closed once its active streams are done
+	Drain()
+}
+
+// ProtocolFilter is implemented by the read filters that know the stream protocol of the connection,
+// the protocol is transferred with the connection in the hot upgrade
+type ProtocolFilter interface {
+	// DownstreamProtocol returns the protocol of the connection, it is empty if the protocol is not known yet
+	DownstreamProtocol() Protocol
+}
+
+// UDPReadFilter handles the datagrams received by a udp listener
+type UDPReadFilter interface {
+	// OnData is called on each datagram received from the address, the data is reused after it returns
+	OnData(data []byte, from net.Addr)
+
+	// Close releases the filter's resources, it is called when the listener is closed or its filters are updated
+	Close()
+}
+
+// UDPFilterChainFactory is implemented by the network filter factories supporting the udp listeners,
+// the filter writes the datagrams to the downstream by the listener's conn
+type UDPFilterChainFactory interface {
+	CreateUDPFilter(context context.Context, clusterManager ClusterManager, conn net.PacketConn) UDPReadFilter
+}
+
 // Addresses defines a group of network address
 type Addresses []net.Addr
 
@@ -51,6 +51,16 @@ const (
 	RateLimited ResponseFlag = 0x800
 	// payload limit
 	ReqEntityTooLarge ResponseFlag = 0x1000
+	// write response to downstream failed
+	DownstreamResponseWriteError ResponseFlag = 0x2000
+	// request is proxied in a loop
+	LoopDetected ResponseFlag = 0x4000
+	// the cluster of the route is not configured
+	NoClusterConfigured ResponseFlag = 0x8000
+	// the concurrent streams of the downstream connection overflow
+	DownstreamOverflow ResponseFlag = 0x10000
+	// the protocol conversion between the downstream and the upstream failed
+	ProtocolConvertFailed ResponseFlag = 0x20000
 )
 
 // RequestInfo has information for a request, include the basic information,
@@ -95,6 +105,9 @@ type RequestInfo interface {
 	// Protocol returns the request's protocol type
 	Protocol() Protocol
 
+	// SetProtocol sets the request's protocol type
+	SetProtocol(p Protocol)
+
 	// ResponseCode reports the request's response code
 	// The code is http standard status code.
 	ResponseCode() int
@@ -148,4 +161,10 @@ type RequestInfo interface {
 
 	// SetRouteEntry sets the route rule
 	SetRouteEntry(routerRule RouteRule)
+
+	// RequestID reports the request id that correlates the downstream request and its upstream requests
+	RequestID() string
+
+	// SetRequestID sets the request id
+	SetRequestID(id string)
 }
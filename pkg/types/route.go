@@ -19,6 +19,7 @@ package types
 
 import (
 	"context"
+	"net"
 	"regexp"
 	"time"
 
@@ -132,6 +133,19 @@ type RouteRule interface {
 
 	// PathMatchCriterion returns the route's PathMatchCriterion
 	PathMatchCriterion() PathMatchCriterion
+
+	// ClusterConfigured returns false if the route references clusters that are not configured
+	ClusterConfigured() bool
+
+	// AutoHostRewrite returns true if the host of the request is rewritten to the selected upstream host's address
+	AutoHostRewrite() bool
+
+	// MaxRequestBytes returns the max request body size allowed by the route, 0 means no limit.
+	// A route's limit overrides its virtual host's limit
+	MaxRequestBytes() uint64
+
+	// Priority returns the priority of the upstream cluster's circuit breakers
+	Priority() ResourcePriority
 }
 
 // Policy defines a group of route policy
@@ -139,6 +153,14 @@ type Policy interface {
 	RetryPolicy() RetryPolicy
 
 	ShadowPolicy() ShadowPolicy
+
+	HashPolicy() HashPolicy
+}
+
+// HashPolicy computes the hash key of a request for the hash based load balancers
+type HashPolicy interface {
+	// GenerateHash returns the hash key, false if no key can be computed from the request
+	GenerateHash(headers HeaderMap, remoteAddr net.Addr) (uint64, bool)
 }
 
 // RetryCheckStatus type
@@ -151,6 +173,17 @@ const (
 	RetryOverflow RetryCheckStatus = -2
 )
 
+// RetryCondition is the bit set of the conditions to retry a request
+type RetryCondition uint32
+
+// RetryCondition types
+const (
+	RetryOn5xx RetryCondition = 1 << iota
+	RetryOnConnectFailure
+	RetryOnReset
+	RetryOnRetriableStatusCodes
+)
+
 // RetryPolicy is a type of Policy
 type RetryPolicy interface {
 	RetryOn() bool
@@ -158,6 +191,12 @@ type RetryPolicy interface {
 	TryTimeout() time.Duration
 
 	NumRetries() uint32
+
+	// RetryOnConditions returns the conditions to retry a request, zero means the default conditions
+	RetryOnConditions() RetryCondition
+
+	// RetriableStatusCodes returns the codes retried by the RetryOnRetriableStatusCodes condition
+	RetriableStatusCodes() []uint32
 }
 
 type DoRetryCallback func()
@@ -188,6 +227,8 @@ type VirtualHost interface {
 	AddRoute(route *v2.Router) error
 	// RemoveAllRoutes clear all the routes in the virtual host
 	RemoveAllRoutes()
+	// PerFilterConfig returns the stream filters config of the virtual host, the route config overrides it
+	PerFilterConfig() map[string]interface{}
 }
 
 // DirectResponseRule contains direct response info
@@ -250,6 +291,12 @@ type HeaderData struct {
 	Value        string
 	IsRegex      bool
 	RegexPattern *regexp.Regexp
+	// Prefix and Suffix match the value by the prefix and the suffix, Present matches the presence only
+	Prefix  string
+	Suffix  string
+	Present bool
+	// Invert inverts the match result
+	Invert bool
 }
 
 // ConfigUtility is utility routines for loading route configuration and matching runtime request headers.
@@ -105,6 +105,7 @@ const (
 	UpstreamReset               StreamResetReason = "UpstreamReset"
 	UpstreamGlobalTimeout       StreamResetReason = "UpstreamGlobalTimeout"
 	UpstreamPerTryTimeout       StreamResetReason = "UpstreamPerTryTimeout"
+	StreamProtocolConvertFailed StreamResetReason = "ProtocolConvertFailed"
 )
 
 // Stream is a generic protocol stream, it is the core model in stream layer
@@ -125,6 +126,9 @@ type Stream interface {
 	// DestroyStream destroys stream, called after stream process in client/server cases.
 	// Any registered StreamEventListener.OnDestroyStream will be called.
 	DestroyStream()
+
+	// ReadDisable disables or enables reading the stream, the calls should be in pairs
+	ReadDisable(disable bool)
 }
 
 // StreamEventListener is a stream event listener
@@ -136,6 +140,16 @@ type StreamEventListener interface {
 	OnDestroyStream()
 }
 
+// StreamWatermarkListener is an optional interface of StreamEventListener,
+// it is notified when the write buffer of the stream's connection is backed up and drained
+type StreamWatermarkListener interface {
+	// OnAboveWriteBufferHighWatermark is called when the bytes buffered are above the high watermark
+	OnAboveWriteBufferHighWatermark()
+
+	// OnBelowWriteBufferLowWatermark is called when the bytes buffered drain to the low watermark
+	OnBelowWriteBufferLowWatermark()
+}
+
 // StreamSender encodes and sends protocol stream
 // On server scenario, StreamSender sends response
 // On client scenario, StreamSender sends request
@@ -215,6 +229,17 @@ type ServerStreamConnectionEventListener interface {
 	NewStreamDetect(context context.Context, sender StreamSender, span Span) StreamReceiveListener
 }
 
+// RequestBodyLimiter is an optional interface of ServerStreamConnectionEventListener,
+// the server stream connection asks it for the request body limit before the body is read
+type RequestBodyLimiter interface {
+	// MaxRequestBytes returns the max request body size of the route matched by the headers, 0 means no limit
+	MaxRequestBytes(headers HeaderMap) uint64
+
+	// OnRequestBodyTooLarge is called when a request is rejected for its body size,
+	// declared is true if the request is rejected by its Content-Length before the body is read
+	OnRequestBodyTooLarge(declared bool)
+}
+
 type StreamFilterBase interface {
 	OnDestroy()
 }
@@ -346,13 +371,17 @@ type PoolFailureReason string
 const (
 	Overflow          PoolFailureReason = "Overflow"
 	ConnectionFailure PoolFailureReason = "ConnectionFailure"
+	PoolClosed        PoolFailureReason = "PoolClosed"
 )
 
 //  ConnectionPool is a connection pool interface to extend various of protocols
 type ConnectionPool interface {
 	Protocol() Protocol
 
-	NewStream(ctx context.Context, receiver StreamReceiveListener, listener PoolEventListener)
+	// NewStream creates a stream on a connection of the pool, the listener is notified when the
+	// stream is ready or failed. A Cancellable is returned if the stream is waiting for a connection,
+	// otherwise the listener is already notified and nil is returned
+	NewStream(ctx context.Context, receiver StreamReceiveListener, listener PoolEventListener) Cancellable
 
 	// check host health and init host
 	CheckAndInit(ctx context.Context) bool
@@ -363,9 +392,53 @@ type ConnectionPool interface {
 	// Shutdown gracefully shuts down the connection pool without interrupting any active requests
 	Shutdown()
 
+	// Close closes the connection pool, no new stream is created after closed
 	Close()
 }
 
+// ConnectBackoffPool is implemented by the connection pools that stop dialing
+// a host for a while after consecutive connect failures
+type ConnectBackoffPool interface {
+	// ResetConnectBackoff clears the failure count and ends the backoff window
+	ResetConnectBackoff()
+}
+
+// ConnectionSnapshotPool is implemented by the connection pools that expose their upstream connections
+type ConnectionSnapshotPool interface {
+	// Snapshot returns the state of the pool and its connected clients
+	Snapshot() ConnPoolSnapshot
+
+	// CloseConnection closes the client with the id, a graceful close waits for the active stream to be done.
+	// It returns whether the client is found and whether a stream is active on it
+	CloseConnection(id string, graceful bool) (found bool, active bool)
+}
+
+// ConnPoolSnapshot is the state of a connection pool
+type ConnPoolSnapshot struct {
+	Protocol     Protocol                  `json:"protocol"`
+	Host         string                    `json:"host"`
+	TotalClients uint64                    `json:"total_clients"`
+	Clients      []UpstreamConnectionState `json:"clients"`
+}
+
+// UpstreamConnectionState is the state of a connected client in the connection pool
+type UpstreamConnectionState struct {
+	// ID is the stable identifier of the client, the connection id plus the host address
+	ID           string `json:"id"`
+	ConnectionID uint64 `json:"connection_id"`
+	Host         string `json:"host"`
+	Active       bool   `json:"active"`
+	// Draining means the client is closed after the active stream is done
+	Draining     bool   `json:"draining"`
+	DownstreamID uint64 `json:"downstream_id,omitempty"`
+}
+
+// Cancellable is returned by ConnectionPool.NewStream when the stream is pending
+type Cancellable interface {
+	// Cancel aborts the pending stream, the PoolEventListener will not be notified after Cancel returns
+	Cancel()
+}
+
 type PoolEventListener interface {
 	OnFailure(reason PoolFailureReason, host Host)
 
@@ -56,6 +56,9 @@ type ClusterManager interface {
 	// Get or Create tcp conn pool for a cluster
 	TCPConnForCluster(balancerContext LoadBalancerContext, snapshot ClusterSnapshot) CreateConnectionData
 
+	// UDPConnForCluster chooses a host of the cluster and creates a udp connection to it
+	UDPConnForCluster(balancerContext LoadBalancerContext, snapshot ClusterSnapshot) (CreateUDPConnectionData, error)
+
 	// ConnPoolForCluster used to get protocol related conn pool
 	ConnPoolForCluster(balancerContext LoadBalancerContext, snapshot ClusterSnapshot, protocol Protocol) ConnectionPool
 
@@ -68,6 +71,22 @@ type ClusterManager interface {
 	// RemoveClusterHosts, remove the host by address string
 	RemoveClusterHosts(clusterName string, hosts []string) error
 
+	// UpdateClusterHealthCheck updates the cluster's health check only, the hosts and conn pools are not changed
+	UpdateClusterHealthCheck(clusterName string, hc v2.HealthCheck) error
+
+	// UpdateClusterCircuitBreakers updates the cluster's circuit breakers only, the hosts and conn pools are not changed
+	UpdateClusterCircuitBreakers(clusterName string, cb v2.CircuitBreakers) error
+
+	// ConnPoolSnapshots returns the state of the connection pools of the cluster's hosts
+	ConnPoolSnapshots(clusterName string) []ConnPoolSnapshot
+
+	// CloseUpstreamConnection closes the upstream connection with the id in the connection pools of the cluster's hosts,
+	// it returns whether the connection is found and whether a stream is active on it
+	CloseUpstreamConnection(clusterName, connectionID string, graceful bool) (found bool, active bool)
+
+	// ClusterStatuses returns the state of the cluster and its hosts, all the clusters if the name is empty
+	ClusterStatuses(clusterName string) []ClusterStatus
+
 	// Destroy the cluster manager
 	Destroy()
 }
@@ -87,6 +106,8 @@ type ClusterSnapshot interface {
 	// if metadata is nil, check the cluster snapshot contains host or not
 	IsExistsHosts(metadata MetadataMatchCriteria) bool
 
+	// HostNum returns the hosts number that the metadata's host is chosen from,
+	// it is the fallback subset's if no subset matches the metadata
 	HostNum(metadata MetadataMatchCriteria) int
 }
 
@@ -100,6 +121,12 @@ type Cluster interface {
 
 	// Add health check callbacks in health checker
 	AddHealthCheckCallbacks(cb HealthCheckCb)
+
+	// UpdateHealthCheck replaces the running health checker with a new config
+	UpdateHealthCheck(cfg v2.HealthCheck)
+
+	// UpdateCircuitBreakers swaps the cluster's circuit breakers thresholds
+	UpdateCircuitBreakers(cb v2.CircuitBreakers)
 }
 
 // HostPredicate checks wether the host is matched the metadata
@@ -122,6 +149,8 @@ const (
 	FAILED_ACTIVE_HC HealthFlag = 0x1
 	// The host is currently considered an outlier and has been ejected.
 	FAILED_OUTLIER_CHECK HealthFlag = 0x02
+	// The host is removed from the cluster and waits for its active requests to be done.
+	DRAINING HealthFlag = 0x04
 )
 
 // Host is an upstream host
@@ -145,6 +174,16 @@ type Host interface {
 
 	// Health checks whether the host is healthy or not
 	Health() bool
+
+	// ReportResult reports the result of a request to the host, the host is ejected
+	// by the cluster's outlier detector if it fails too many times in a row
+	ReportResult(result HostResult)
+
+	// LastHealthyTime returns the time the host is added or becomes healthy, it is used by the slow start
+	LastHealthyTime() time.Time
+
+	// SetLastHealthyTime sets the time the host is added or becomes healthy
+	SetLastHealthyTime(t time.Time)
 }
 
 // HostInfo defines a host's basic information
@@ -191,6 +230,10 @@ type HostStats struct {
 	UpstreamConnectionLocalCloseWithActiveRequest  metrics.Counter
 	UpstreamConnectionRemoteCloseWithActiveRequest metrics.Counter
 	UpstreamConnectionCloseNotify                  metrics.Counter
+	UpstreamConnectionPerDownstreamTotal           metrics.Counter
+	UpstreamConnectionPerDownstreamActive          metrics.Counter
+	UpstreamConnectionConnectDurationMs            metrics.Histogram
+	UpstreamConnectionBackoff                      metrics.Gauge
 	UpstreamRequestTotal                           metrics.Counter
 	UpstreamRequestActive                          metrics.Counter
 	UpstreamRequestLocalReset                      metrics.Counter
@@ -198,10 +241,18 @@ type HostStats struct {
 	UpstreamRequestTimeout                         metrics.Counter
 	UpstreamRequestFailureEject                    metrics.Counter
 	UpstreamRequestPendingOverflow                 metrics.Counter
-	UpstreamRequestDuration                        metrics.Histogram
-	UpstreamRequestDurationTotal                   metrics.Counter
+	UpstreamKeepAliveEject                         metrics.Counter
+	UpstreamKeepAliveSuccessTotal                  metrics.Counter
+	UpstreamKeepAliveTimeoutTotal                  metrics.Counter
+	UpstreamKeepAliveClosedConnectionTotal         metrics.Counter
+	UpstreamKeepAliveOutstanding                   metrics.Counter
+	UpstreamRequestDuration                        metrics.Timer
 	UpstreamResponseSuccess                        metrics.Counter
 	UpstreamResponseFailed                         metrics.Counter
+	// the health check requests are counted apart from the upstream requests
+	HealthCheckAttempt metrics.Counter
+	HealthCheckSuccess metrics.Counter
+	HealthCheckFailure metrics.Counter
 }
 
 // ClusterInfo defines a cluster's information
@@ -209,21 +260,30 @@ type ClusterInfo interface {
 	// Name returns the cluster name
 	Name() string
 
+	// ClusterType returns the cluster's type
+	ClusterType() v2.ClusterType
+
 	// LbType returns the cluster's load balancer type
 	LbType() LoadBalancerType
 
 	// ConnBufferLimitBytes returns the connection buffer limits
 	ConnBufferLimitBytes() uint32
 
+	// WriteBufferWatermarks returns the write buffer watermarks of the connections, the high watermark 0 means no flow control
+	WriteBufferWatermarks() (low, high uint32)
+
 	// MaxRequestsPerConn returns a connection's max request
 	MaxRequestsPerConn() uint32
 
 	// Stats returns the cluster's stats metrics
 	Stats() ClusterStats
 
-	// ResourceManager returns the ResourceManager
+	// ResourceManager returns the ResourceManager of the default priority
 	ResourceManager() ResourceManager
 
+	// ResourceManagerByPriority returns the ResourceManager of the priority
+	ResourceManagerByPriority(priority ResourcePriority) ResourceManager
+
 	// TLSMng returns the tls manager
 	TLSMng() TLSContextManager
 
@@ -232,6 +292,126 @@ type ClusterInfo interface {
 
 	// ConectTimeout returns the connect timeout
 	ConnectTimeout() time.Duration
+
+	// ConnectBackoff returns the connect failure backoff config
+	ConnectBackoff() ConnectBackoffConfig
+
+	// TCPOptions returns the socket options of the upstream connections
+	TCPOptions() TCPOptions
+
+	// ConnPoolMode returns how the connection pool shares the upstream connections
+	ConnPoolMode() v2.ConnPoolMode
+
+	// ProxyProtocol returns the PROXY protocol version sent on the upstream connections, empty means no header is sent
+	ProxyProtocol() v2.ProxyProtocolVersion
+
+	// MethodStats returns true if the upstream responses are counted by the request method too
+	MethodStats() bool
+
+	// KeepAlive returns the heartbeat config of the upstream connections
+	KeepAlive() KeepAliveConfig
+
+	// OutlierDetector returns the detector that ejects the cluster's outlier hosts
+	OutlierDetector() OutlierDetector
+
+	// OutlierDetection returns the config of the outlier detector
+	OutlierDetection() OutlierDetectionConfig
+
+	// RingHashConfig returns the ring size config of the ring hash load balancer
+	RingHashConfig() RingHashConfig
+
+	// SlowStart returns the slow start config of the hosts
+	SlowStart() SlowStartConfig
+
+	// DrainTimeout returns how long the connection pools of a removed host wait for the active requests
+	DrainTimeout() time.Duration
+}
+
+// ClusterStatus is the state of a cluster and its hosts
+type ClusterStatus struct {
+	Name               string           `json:"name"`
+	Type               v2.ClusterType   `json:"type,omitempty"`
+	LbType             LoadBalancerType `json:"lb_type,omitempty"`
+	MaxRequestsPerConn uint32           `json:"max_requests_per_conn,omitempty"`
+	ConnectTimeout     string           `json:"connect_timeout"`
+	DrainTimeout       string           `json:"drain_timeout"`
+	// TotalHosts and HealthyHosts do not count the draining hosts
+	TotalHosts   int `json:"total_hosts"`
+	HealthyHosts int `json:"healthy_hosts"`
+	// Hosts contains the draining hosts removed from the cluster
+	Hosts []HostStatus `json:"hosts"`
+}
+
+// HostStatus is the state of an upstream host
+type HostStatus struct {
+	Address  string `json:"address"`
+	Hostname string `json:"hostname,omitempty"`
+	Weight   uint32 `json:"weight"`
+	Healthy  bool   `json:"healthy"`
+	// ActiveHealthCheckFailed, OutlierEjected and Draining are the reasons of an unhealthy host
+	ActiveHealthCheckFailed bool  `json:"active_health_check_failed,omitempty"`
+	OutlierEjected          bool  `json:"outlier_ejected,omitempty"`
+	Draining                bool  `json:"draining,omitempty"`
+	ActiveRequests          int64 `json:"active_requests"`
+}
+
+// HostResult is the result of a request to an upstream host
+type HostResult int
+
+const (
+	HostResultSuccess HostResult = iota
+	// HostResult5xx means the host responds a 5xx code
+	HostResult5xx
+	// HostResultTimeout means the host does not respond in time, it is counted as a 5xx
+	HostResultTimeout
+	// HostResultConnectFailure means the connection to the host is failed
+	HostResultConnectFailure
+)
+
+// OutlierDetectionConfig controls when and how long the outlier hosts are ejected
+type OutlierDetectionConfig struct {
+	// BaseEjectionTime is multiplied by the times the host is ejected, and capped by MaxEjectionTime
+	BaseEjectionTime time.Duration
+	MaxEjectionTime  time.Duration
+	// Consecutive5xx and ConsecutiveConnectFailure are the thresholds to eject a host, zero means disabled
+	Consecutive5xx            uint32
+	ConsecutiveConnectFailure uint32
+	// MaxEjectionPercent is the max percent of the ejected hosts in the cluster
+	MaxEjectionPercent uint32
+}
+
+// OutlierDetector ejects the outlier hosts from the load balancing for a while
+type OutlierDetector interface {
+	// EjectHost marks the host as an outlier, returns false if the host is ejected already
+	EjectHost(host Host) bool
+}
+
+// ConnectBackoffConfig controls how a connection pool backs off dialing a host
+// after consecutive connect failures
+type ConnectBackoffConfig struct {
+	// MaxFailures is the consecutive failures that trigger a backoff, zero means disabled
+	MaxFailures uint32
+	// BaseInterval is the first backoff window, it doubles on every failure up to MaxInterval
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+}
+
+// ResourcePriority selects the ResourceManager, each priority has independent thresholds
+type ResourcePriority int
+
+// Group of resource priority
+const (
+	DefaultPriority ResourcePriority = iota
+	HighPriority
+	// NumResourcePriorities is the count of the priorities
+	NumResourcePriorities
+)
+
+func (p ResourcePriority) String() string {
+	if p == HighPriority {
+		return "high"
+	}
+	return "default"
 }
 
 // ResourceManager manages different types of Resource
@@ -269,6 +449,9 @@ type ClusterStats struct {
 	UpstreamConnectionLocalCloseWithActiveRequest  metrics.Counter
 	UpstreamConnectionRemoteCloseWithActiveRequest metrics.Counter
 	UpstreamConnectionCloseNotify                  metrics.Counter
+	UpstreamConnectionPerDownstreamTotal           metrics.Counter
+	UpstreamConnectionPerDownstreamActive          metrics.Counter
+	UpstreamConnectionConnectDurationMs            metrics.Histogram
 	UpstreamBytesReadTotal                         metrics.Counter
 	UpstreamBytesWriteTotal                        metrics.Counter
 	UpstreamRequestTotal                           metrics.Counter
@@ -280,12 +463,47 @@ type ClusterStats struct {
 	UpstreamRequestTimeout                         metrics.Counter
 	UpstreamRequestFailureEject                    metrics.Counter
 	UpstreamRequestPendingOverflow                 metrics.Counter
-	UpstreamRequestDuration                        metrics.Histogram
-	UpstreamRequestDurationTotal                   metrics.Counter
+	UpstreamKeepAliveEject                         metrics.Counter
+	UpstreamKeepAliveSuccessTotal                  metrics.Counter
+	UpstreamKeepAliveTimeoutTotal                  metrics.Counter
+	UpstreamKeepAliveClosedConnectionTotal         metrics.Counter
+	UpstreamKeepAliveOutstanding                   metrics.Counter
+	UpstreamRequestDuration                        metrics.Timer
 	UpstreamResponseSuccess                        metrics.Counter
 	UpstreamResponseFailed                         metrics.Counter
+	UpstreamResponseCode2xx                        metrics.Counter
+	UpstreamResponseCode3xx                        metrics.Counter
+	UpstreamResponseCode4xx                        metrics.Counter
+	UpstreamResponseCode5xx                        metrics.Counter
+	UpstreamResponseGrpcOK                         metrics.Counter
+	UpstreamResponseGrpc4xx                        metrics.Counter
+	UpstreamResponseGrpc5xx                        metrics.Counter
+	UpstreamTLSHandshakeFail                       metrics.Counter
+	UpstreamHostSlowStart                          metrics.Counter
+	UpstreamOutlierEjectionsTotal                  metrics.Counter
+	UpstreamOutlierEjectionsActive                 metrics.Counter
+	UpstreamHostDraining                           metrics.Gauge
 	LBSubSetsFallBack                              metrics.Counter
 	LBSubsetsCreated                               metrics.Gauge
+	// the udp sessions of the udp proxy, the bytes are counted by the connection bytes
+	UpstreamUDPSessionTotal       metrics.Counter
+	UpstreamUDPSessionActive      metrics.Counter
+	UpstreamUDPSessionIdleTimeout metrics.Counter
+	UpstreamUDPDatagramsSent      metrics.Counter
+	UpstreamUDPDatagramsReceived  metrics.Counter
+	UpstreamUDPDatagramsDropped   metrics.Counter
+	// the connections of the tcp proxy
+	UpstreamTCPProxyDownstreamBytes      metrics.Counter
+	UpstreamTCPProxyUpstreamBytes        metrics.Counter
+	UpstreamTCPProxyIdleTimeout          metrics.Counter
+	UpstreamTCPProxyConnectionDurationMs metrics.Histogram
+	// the connections crossed the write buffer watermarks, and the connections above the high watermark now
+	UpstreamWriteBufferHighWatermarkTotal metrics.Counter
+	UpstreamWriteBufferLowWatermarkTotal  metrics.Counter
+	UpstreamWriteBufferBackedUp           metrics.Counter
+	// the configured watermarks, 0 means no flow control
+	UpstreamWriteBufferHighWatermark metrics.Gauge
+	UpstreamWriteBufferLowWatermark  metrics.Gauge
 }
 
 type CreateConnectionData struct {
@@ -293,6 +511,13 @@ type CreateConnectionData struct {
 	HostInfo   HostInfo
 }
 
+// CreateUDPConnectionData is a connected udp socket to the chosen host,
+// each Write sends a datagram and each Read receives a datagram from the host
+type CreateUDPConnectionData struct {
+	Connection net.Conn
+	HostInfo   HostInfo
+}
+
 // SimpleCluster is a simple cluster in memory
 type SimpleCluster interface {
 	UpdateHosts(newHosts []Host)
@@ -372,3 +372,38 @@ func BenchmarkSubsetLB(b *testing.B) {
 		})
 	})
 }
+
+// BenchmarkRingHash test the ring hash load balancer with 1000 hosts
+func BenchmarkRingHash(b *testing.B) {
+	hosts := makePool(1000).MakeHosts(1000, nil)
+	config := types.RingHashConfig{
+		MinimumRingSize: types.DefaultRingHashMinimumSize,
+		MaximumRingSize: types.DefaultRingHashMaximumSize,
+	}
+	b.Run("BuildRing1000", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buildHashRing(hosts, config)
+		}
+	})
+	b.Run("BuildRing1000MinSize1M", func(b *testing.B) {
+		config := types.RingHashConfig{
+			MinimumRingSize: 1024 * 1024,
+			MaximumRingSize: types.DefaultRingHashMaximumSize,
+		}
+		for i := 0; i < b.N; i++ {
+			buildHashRing(hosts, config)
+		}
+	})
+	b.Run("ChooseHost1000", func(b *testing.B) {
+		lb := newRingHashTestLB(hosts)
+		key := uint64(0)
+		ctx := &mockLbContext{
+			hashKey: &key,
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			key += 0x9e3779b97f4a7c15
+			lb.ChooseHost(ctx)
+		}
+	})
+}
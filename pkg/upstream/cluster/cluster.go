@@ -18,20 +18,35 @@
 package cluster
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
-	"sofastack.io/sofa-mosn/pkg/mtls"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"sofastack.io/sofa-mosn/pkg/upstream/healthcheck"
 	"sofastack.io/sofa-mosn/pkg/utils"
 )
 
+// DefaultBaseEjectionTime is the time an outlier host is ejected if it is not configured
+const DefaultBaseEjectionTime = 30 * time.Second
+
+// DefaultDrainTimeout is how long a removed host is drained if it is not configured
+const DefaultDrainTimeout = 30 * time.Second
+
+// The default limits of the outlier detection
+const (
+	DefaultMaxEjectionTime    = 300 * time.Second
+	DefaultMaxEjectionPercent = uint32(10)
+)
+
 func NewCluster(clusterConfig v2.Cluster) types.Cluster {
 	// TODO: support cluster type registered
+	if clusterConfig.ClusterType == v2.ORIGINAL_DST_CLUSTER {
+		return newOriginalDstCluster(clusterConfig)
+	}
 	return newSimpleCluster(clusterConfig)
 }
 
@@ -42,6 +57,15 @@ type simpleCluster struct {
 	lbInstance    types.LoadBalancer // load balancer used for this cluster
 	hostSet       *hostSet
 	snapshot      atomic.Value
+	// healthCheckCbs keeps the callbacks, so they can be added to a new health checker
+	healthCheckCbs []types.HealthCheckCb
+	// ejectTimers re-admit the ejected hosts after the ejection time
+	ejectTimers map[types.Host]*utils.Timer
+	// ejections keeps how many times the hosts are ejected, keyed by the host address
+	ejections map[string]*hostEjection
+	// slowStartTimers end the slow start of the hosts, keyed by the host address
+	slowStartTimers map[string]*utils.Timer
+	mutex           sync.Mutex
 }
 
 func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
@@ -53,8 +77,20 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 		stats:                newClusterStats(clusterConfig.Name),
 		lbSubsetInfo:         NewLBSubsetInfo(&clusterConfig.LBSubSetConfig), // new subset load balancer info
 		lbType:               types.LoadBalancerType(clusterConfig.LbType),
-		resourceManager:      NewResourceManager(clusterConfig.CirBreThresholds),
+		resourceManagers:     newResourceManagers(clusterConfig.Name, clusterConfig.CirBreThresholds),
+		connPoolMode:         clusterConfig.ConnPoolMode,
+		methodStats:          clusterConfig.MethodStats,
+		proxyProtocol:        clusterConfig.ProxyProtocol,
 	}
+	info.connectBackoff.Store(newConnectBackoffConfig(clusterConfig.CirBreThresholds))
+	info.tcpOptions = newTCPOptions(clusterConfig)
+	info.keepAlive = newKeepAliveConfig(clusterConfig)
+	info.ringHashConfig = newRingHashConfig(clusterConfig)
+	info.slowStart = newSlowStartConfig(clusterConfig)
+	info.outlierDetection = newOutlierDetectionConfig(clusterConfig)
+	info.writeBufferLow, info.writeBufferHigh = network.WriteBufferWatermarks(clusterConfig.WriteBufferWatermarks)
+	info.stats.UpstreamWriteBufferLowWatermark.Update(int64(info.writeBufferLow))
+	info.stats.UpstreamWriteBufferHighWatermark.Update(int64(info.writeBufferHigh))
 
 	// set ConnectTimeout
 	if clusterConfig.ConnectTimeout != nil {
@@ -62,16 +98,25 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 	} else {
 		info.connectTimeout = network.DefaultConnectTimeout
 	}
+	if clusterConfig.DrainTimeout != nil {
+		info.drainTimeout = clusterConfig.DrainTimeout.Duration
+	} else {
+		info.drainTimeout = DefaultDrainTimeout
+	}
 
 	// tls mng
-	mgr, err := mtls.NewTLSClientContextManager(&clusterConfig.TLS)
+	mgr, err := updateClusterTLSContextManager(&clusterConfig)
 	if err != nil {
 		log.DefaultLogger.Errorf("[upstream] [cluster] [new cluster] create tls context manager failed, %v", err)
 	}
 	info.tlsMng = mgr
 	cluster := &simpleCluster{
-		info: info,
+		info:            info,
+		ejectTimers:     make(map[types.Host]*utils.Timer),
+		ejections:       make(map[string]*hostEjection),
+		slowStartTimers: make(map[string]*utils.Timer),
 	}
+	info.outlierDetector = cluster
 	// init a empty
 	hostSet := &hostSet{}
 	cluster.snapshot.Store(&clusterSnapshot{
@@ -79,22 +124,45 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 		hostSet: hostSet,
 		lb:      NewLoadBalancer(info.lbType, hostSet),
 	})
-	if clusterConfig.HealthCheck.ServiceName != "" {
-		log.DefaultLogger.Infof("[upstream] [cluster] [new cluster] cluster %s have health check", clusterConfig.Name)
-		cluster.healthChecker = healthcheck.CreateHealthCheck(clusterConfig.HealthCheck)
-		cluster.healthChecker.AddHostCheckCompleteCb(func(host types.Host, changedState bool, isHealthy bool) {
+	cluster.healthCheckCbs = []types.HealthCheckCb{
+		func(host types.Host, changedState bool, isHealthy bool) {
 			if changedState {
 				log.DefaultLogger.Infof("[upstream] [cluster] host %s state change to %v", host.AddressString(), isHealthy)
+				if isHealthy {
+					cluster.mutex.Lock()
+					cluster.startSlowStart(host)
+					cluster.mutex.Unlock()
+				}
 				cluster.hostSet.refreshHealthHost(host)
 			}
-		})
-
+			// the ejected host is re-admitted once it is checked as healthy
+			if isHealthy && host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+				cluster.readmitHost(host)
+			}
+		},
+	}
+	if clusterConfig.HealthCheck.ServiceName != "" {
+		log.DefaultLogger.Infof("[upstream] [cluster] [new cluster] cluster %s have health check", clusterConfig.Name)
+		cluster.healthChecker = cluster.createHealthCheck(clusterConfig.HealthCheck)
 	}
 	return cluster
 }
 
+func (sc *simpleCluster) createHealthCheck(cfg v2.HealthCheck) types.HealthChecker {
+	hc := healthcheck.CreateHealthCheck(cfg)
+	for _, cb := range sc.healthCheckCbs {
+		hc.AddHostCheckCompleteCb(cb)
+	}
+	return hc
+}
+
 func (sc *simpleCluster) UpdateHosts(newHosts []types.Host) {
 	info := sc.info
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	// the health flags carried over are set before the healthy hosts are built
+	sc.updateSlowStart(newHosts)
+	sc.updateEjections(newHosts)
 	hostSet := &hostSet{}
 	hostSet.setFinalHost(newHosts)
 	// load balance
@@ -111,14 +179,45 @@ func (sc *simpleCluster) UpdateHosts(newHosts []types.Host) {
 		hostSet: hostSet,
 		info:    info,
 	})
-	if sc.healthChecker != nil {
+	if hc := sc.healthChecker; hc != nil {
 		utils.GoWithRecover(func() {
-			sc.healthChecker.SetHealthCheckerHostSet(hostSet)
+			hc.SetHealthCheckerHostSet(hostSet)
 		}, nil)
 	}
 
 }
 
+// UpdateHealthCheck stops the running health checker and starts a new one with the config
+// the hosts and the registered callbacks are kept
+func (sc *simpleCluster) UpdateHealthCheck(cfg v2.HealthCheck) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if sc.healthChecker != nil {
+		sc.healthChecker.Stop()
+		sc.healthChecker = nil
+	}
+	if cfg.ServiceName == "" {
+		log.DefaultLogger.Infof("[upstream] [cluster] [update health check] cluster %s health check removed", sc.info.name)
+		return
+	}
+	hc := sc.createHealthCheck(cfg)
+	sc.healthChecker = hc
+	hostSet := sc.Snapshot().HostSet()
+	utils.GoWithRecover(func() {
+		hc.SetHealthCheckerHostSet(hostSet)
+	}, nil)
+	log.DefaultLogger.Infof("[upstream] [cluster] [update health check] cluster %s health check updated", sc.info.name)
+}
+
+// UpdateCircuitBreakers swaps the cluster's thresholds, the connection pools are not changed
+func (sc *simpleCluster) UpdateCircuitBreakers(cb v2.CircuitBreakers) {
+	for _, rm := range sc.info.resourceManagers {
+		rm.updateThresholds(cb)
+	}
+	sc.info.connectBackoff.Store(newConnectBackoffConfig(cb))
+	log.DefaultLogger.Infof("[upstream] [cluster] [update circuit breakers] cluster %s circuit breakers updated", sc.info.name)
+}
+
 func (sc *simpleCluster) Snapshot() types.ClusterSnapshot {
 	si := sc.snapshot.Load()
 	if snap, ok := si.(*clusterSnapshot); ok {
@@ -128,22 +227,217 @@ func (sc *simpleCluster) Snapshot() types.ClusterSnapshot {
 }
 
 func (sc *simpleCluster) AddHealthCheckCallbacks(cb types.HealthCheckCb) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.healthCheckCbs = append(sc.healthCheckCbs, cb)
 	if sc.healthChecker != nil {
 		sc.healthChecker.AddHostCheckCompleteCb(cb)
 	}
 }
 
+// hostEjection is the ejection history of a host
+type hostEjection struct {
+	count uint32
+	// until is the time the last ejection ends
+	until time.Time
+}
+
+// EjectHost ejects the host from the load balancing for the base ejection time multiplied by the times it is ejected,
+// the host is re-admitted after the ejection time, or once the health checker checks it as healthy.
+// the host is not ejected if the ejected hosts reach the max ejection percent of the cluster
+func (sc *simpleCluster) EjectHost(host types.Host) bool {
+	sc.mutex.Lock()
+	if host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		sc.mutex.Unlock()
+		return false
+	}
+	config := sc.info.outlierDetection
+	if sc.hostSet != nil {
+		total, ejected := len(sc.hostSet.Hosts()), len(sc.ejectTimers)
+		if ejected*100 >= total*int(config.MaxEjectionPercent) {
+			sc.mutex.Unlock()
+			log.DefaultLogger.Warnf("[upstream] [cluster] cluster %s host %s is not ejected, %d of %d hosts are ejected already",
+				sc.info.name, host.AddressString(), ejected, total)
+			return false
+		}
+	}
+	now := time.Now()
+	ejection, ok := sc.ejections[host.AddressString()]
+	// the ejection count is reset if the host is not ejected for the max ejection time
+	if !ok || now.Sub(ejection.until) > config.MaxEjectionTime {
+		ejection = &hostEjection{}
+		sc.ejections[host.AddressString()] = ejection
+	}
+	ejection.count++
+	ejectionTime := config.BaseEjectionTime * time.Duration(ejection.count)
+	if ejectionTime > config.MaxEjectionTime {
+		ejectionTime = config.MaxEjectionTime
+	}
+	ejection.until = now.Add(ejectionTime)
+	host.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+	sc.ejectTimers[host] = utils.NewTimer(ejectionTime, func() {
+		sc.readmitHost(host)
+	})
+	sc.info.stats.UpstreamOutlierEjectionsTotal.Inc(1)
+	sc.info.stats.UpstreamOutlierEjectionsActive.Inc(1)
+	hostSet := sc.hostSet
+	sc.mutex.Unlock()
+	log.DefaultLogger.Infof("[upstream] [cluster] cluster %s host %s is ejected for %s", sc.info.name, host.AddressString(), ejectionTime)
+	if hostSet != nil {
+		hostSet.refreshHealthHost(host)
+	}
+	return true
+}
+
+func (sc *simpleCluster) readmitHost(host types.Host) {
+	sc.mutex.Lock()
+	if !host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		sc.mutex.Unlock()
+		return
+	}
+	host.ClearHealthFlag(types.FAILED_OUTLIER_CHECK)
+	if timer, ok := sc.ejectTimers[host]; ok {
+		timer.Stop()
+		delete(sc.ejectTimers, host)
+		sc.info.stats.UpstreamOutlierEjectionsActive.Dec(1)
+	}
+	sc.startSlowStart(host)
+	hostSet := sc.hostSet
+	sc.mutex.Unlock()
+	log.DefaultLogger.Infof("[upstream] [cluster] cluster %s host %s is re-admitted", sc.info.name, host.AddressString())
+	if hostSet != nil {
+		hostSet.refreshHealthHost(host)
+	}
+}
+
+// updateEjections carries the ejections over to the new hosts by the address, a new host is ejected until
+// the last ejection of its address ends. the ejections of the removed addresses are dropped.
+// it should be called with the mutex locked
+func (sc *simpleCluster) updateEjections(newHosts []types.Host) {
+	ejected := make(map[string]types.Host, len(sc.ejectTimers))
+	for host := range sc.ejectTimers {
+		ejected[host.AddressString()] = host
+	}
+	now := time.Now()
+	addrs := make(map[string]struct{}, len(newHosts))
+	for _, host := range newHosts {
+		addr := host.AddressString()
+		addrs[addr] = struct{}{}
+		old, ok := ejected[addr]
+		if !ok {
+			continue
+		}
+		delete(ejected, addr)
+		if old == host {
+			continue
+		}
+		sc.ejectTimers[old].Stop()
+		delete(sc.ejectTimers, old)
+		ejection, ok := sc.ejections[addr]
+		if !ok || !ejection.until.After(now) {
+			sc.info.stats.UpstreamOutlierEjectionsActive.Dec(1)
+			continue
+		}
+		newHost := host
+		newHost.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+		sc.ejectTimers[newHost] = utils.NewTimer(ejection.until.Sub(now), func() {
+			sc.readmitHost(newHost)
+		})
+	}
+	// the ejected hosts left are removed
+	for _, host := range ejected {
+		sc.ejectTimers[host].Stop()
+		delete(sc.ejectTimers, host)
+		sc.info.stats.UpstreamOutlierEjectionsActive.Dec(1)
+	}
+	for addr := range sc.ejections {
+		if _, ok := addrs[addr]; !ok {
+			delete(sc.ejections, addr)
+		}
+	}
+}
+
+// updateSlowStart starts the slow start of the new healthy hosts, the hosts of the first update are not in slow start.
+// the hosts kept in the update keep their slow start. it should be called with the mutex locked
+func (sc *simpleCluster) updateSlowStart(newHosts []types.Host) {
+	if sc.info.slowStart.Duration <= 0 {
+		return
+	}
+	oldHosts := make(map[string]types.Host)
+	if sc.hostSet != nil {
+		for _, host := range sc.hostSet.Hosts() {
+			oldHosts[host.AddressString()] = host
+		}
+	}
+	newAddrs := make(map[string]struct{}, len(newHosts))
+	for _, host := range newHosts {
+		addr := host.AddressString()
+		newAddrs[addr] = struct{}{}
+		if old, ok := oldHosts[addr]; ok {
+			host.SetLastHealthyTime(old.LastHealthyTime())
+		} else if len(oldHosts) > 0 && host.Health() {
+			sc.startSlowStart(host)
+		}
+	}
+	for addr, timer := range sc.slowStartTimers {
+		if _, ok := newAddrs[addr]; !ok {
+			timer.Stop()
+			delete(sc.slowStartTimers, addr)
+			sc.info.stats.UpstreamHostSlowStart.Dec(1)
+		}
+	}
+}
+
+// startSlowStart resets the host's last healthy time, and counts the host in slow start until the slow start ends.
+// it should be called with the mutex locked
+func (sc *simpleCluster) startSlowStart(host types.Host) {
+	duration := sc.info.slowStart.Duration
+	if duration <= 0 {
+		return
+	}
+	host.SetLastHealthyTime(time.Now())
+	addr := host.AddressString()
+	if timer, ok := sc.slowStartTimers[addr]; ok {
+		timer.Stop()
+	} else {
+		sc.info.stats.UpstreamHostSlowStart.Inc(1)
+	}
+	var timer *utils.Timer
+	timer = utils.NewTimer(duration, func() {
+		sc.mutex.Lock()
+		defer sc.mutex.Unlock()
+		if sc.slowStartTimers[addr] == timer {
+			delete(sc.slowStartTimers, addr)
+			sc.info.stats.UpstreamHostSlowStart.Dec(1)
+		}
+	})
+	sc.slowStartTimers[addr] = timer
+}
+
 type clusterInfo struct {
 	name                 string
 	clusterType          v2.ClusterType
 	lbType               types.LoadBalancerType // if use subset lb , lbType is used as inner LB algorithm for choosing subset's host
 	connBufferLimitBytes uint32
+	writeBufferLow       uint32
+	writeBufferHigh      uint32
 	maxRequestsPerConn   uint32
-	resourceManager      types.ResourceManager
+	resourceManagers     []*resourcemanager // indexed by the priority
 	stats                types.ClusterStats
 	lbSubsetInfo         types.LBSubsetInfo
 	tlsMng               types.TLSContextManager
 	connectTimeout       time.Duration
+	connectBackoff       atomic.Value // types.ConnectBackoffConfig
+	tcpOptions           types.TCPOptions
+	connPoolMode         v2.ConnPoolMode
+	proxyProtocol        v2.ProxyProtocolVersion
+	methodStats          bool
+	keepAlive            types.KeepAliveConfig
+	outlierDetection     types.OutlierDetectionConfig
+	outlierDetector      types.OutlierDetector
+	ringHashConfig       types.RingHashConfig
+	slowStart            types.SlowStartConfig
+	drainTimeout         time.Duration
 }
 
 func (ci *clusterInfo) Name() string {
@@ -162,6 +456,10 @@ func (ci *clusterInfo) ConnBufferLimitBytes() uint32 {
 	return ci.connBufferLimitBytes
 }
 
+func (ci *clusterInfo) WriteBufferWatermarks() (low, high uint32) {
+	return ci.writeBufferLow, ci.writeBufferHigh
+}
+
 func (ci *clusterInfo) MaxRequestsPerConn() uint32 {
 	return ci.maxRequestsPerConn
 }
@@ -171,7 +469,15 @@ func (ci *clusterInfo) Stats() types.ClusterStats {
 }
 
 func (ci *clusterInfo) ResourceManager() types.ResourceManager {
-	return ci.resourceManager
+	return ci.resourceManagers[types.DefaultPriority]
+}
+
+// ResourceManagerByPriority returns the default priority's resource manager if the priority is unknown
+func (ci *clusterInfo) ResourceManagerByPriority(priority types.ResourcePriority) types.ResourceManager {
+	if priority < 0 || int(priority) >= len(ci.resourceManagers) {
+		return ci.resourceManagers[types.DefaultPriority]
+	}
+	return ci.resourceManagers[priority]
 }
 
 func (ci *clusterInfo) TLSMng() types.TLSContextManager {
@@ -186,6 +492,170 @@ func (ci *clusterInfo) ConnectTimeout() time.Duration {
 	return ci.connectTimeout
 }
 
+func (ci *clusterInfo) ConnectBackoff() types.ConnectBackoffConfig {
+	return ci.connectBackoff.Load().(types.ConnectBackoffConfig)
+}
+
+func (ci *clusterInfo) TCPOptions() types.TCPOptions {
+	return ci.tcpOptions
+}
+
+func (ci *clusterInfo) ConnPoolMode() v2.ConnPoolMode {
+	return ci.connPoolMode
+}
+
+func (ci *clusterInfo) ProxyProtocol() v2.ProxyProtocolVersion {
+	return ci.proxyProtocol
+}
+
+func (ci *clusterInfo) MethodStats() bool {
+	return ci.methodStats
+}
+
+func (ci *clusterInfo) KeepAlive() types.KeepAliveConfig {
+	return ci.keepAlive
+}
+
+func (ci *clusterInfo) OutlierDetector() types.OutlierDetector {
+	return ci.outlierDetector
+}
+
+func (ci *clusterInfo) OutlierDetection() types.OutlierDetectionConfig {
+	return ci.outlierDetection
+}
+
+func (ci *clusterInfo) RingHashConfig() types.RingHashConfig {
+	return ci.ringHashConfig
+}
+
+func (ci *clusterInfo) SlowStart() types.SlowStartConfig {
+	return ci.slowStart
+}
+
+func (ci *clusterInfo) DrainTimeout() time.Duration {
+	return ci.drainTimeout
+}
+
+// newTCPOptions returns the socket options of the cluster config,
+// the options are all disabled if they are not configured
+func newTCPOptions(clusterConfig v2.Cluster) types.TCPOptions {
+	options := types.TCPOptions{}
+	if ka := clusterConfig.TCPKeepalive; ka != nil && ka.Enable {
+		options.Keepalive = true
+		if ka.Idle != nil {
+			options.KeepaliveIdle = ka.Idle.Duration
+		}
+		if ka.Interval != nil {
+			options.KeepaliveInterval = ka.Interval.Duration
+		}
+		options.KeepaliveProbes = int(ka.Probes)
+	}
+	if clusterConfig.TCPUserTimeout != nil {
+		options.UserTimeout = clusterConfig.TCPUserTimeout.Duration
+	}
+	return options
+}
+
+// newKeepAliveConfig returns the heartbeat config of the cluster config,
+// the timeout uses the health check timeout if it is not configured
+func newKeepAliveConfig(clusterConfig v2.Cluster) types.KeepAliveConfig {
+	config := types.KeepAliveConfig{
+		Timeout:          types.DefaultKeepAliveTimeout,
+		FailCountToClose: types.DefaultKeepAliveFailCount,
+		IntervalJitter:   types.DefaultKeepAliveJitter,
+	}
+	if clusterConfig.HealthCheck.Timeout > 0 {
+		config.Timeout = clusterConfig.HealthCheck.Timeout
+	}
+	if ka := clusterConfig.KeepAlive; ka != nil {
+		if ka.Interval != nil {
+			config.Interval = ka.Interval.Duration
+		}
+		if ka.Timeout != nil && ka.Timeout.Duration > 0 {
+			config.Timeout = ka.Timeout.Duration
+		}
+		if ka.FailCountToClose > 0 {
+			config.FailCountToClose = ka.FailCountToClose
+		}
+		if ka.JitterPercent != nil {
+			config.IntervalJitter = float64(*ka.JitterPercent) / 100
+		}
+		config.MaxInterval = config.Interval * types.DefaultKeepAliveBackoff
+		if ka.MaxInterval != nil {
+			config.MaxInterval = ka.MaxInterval.Duration
+		}
+		config.HTTPProbe = ka.HTTPProbe
+	}
+	return config
+}
+
+// newRingHashConfig returns the ring size config of the cluster config,
+// the default size is used if it is not configured
+func newRingHashConfig(clusterConfig v2.Cluster) types.RingHashConfig {
+	config := types.RingHashConfig{
+		MinimumRingSize: types.DefaultRingHashMinimumSize,
+		MaximumRingSize: types.DefaultRingHashMaximumSize,
+	}
+	if rh := clusterConfig.RingHashConfig; rh != nil {
+		if rh.MinimumRingSize > 0 {
+			config.MinimumRingSize = rh.MinimumRingSize
+		}
+		if rh.MaximumRingSize > 0 {
+			config.MaximumRingSize = rh.MaximumRingSize
+		}
+	}
+	return config
+}
+
+// newOutlierDetectionConfig returns the outlier detection config of the cluster config,
+// the hosts are ejected only by the keepalive if the thresholds are not configured
+func newOutlierDetectionConfig(clusterConfig v2.Cluster) types.OutlierDetectionConfig {
+	config := types.OutlierDetectionConfig{
+		BaseEjectionTime:   DefaultBaseEjectionTime,
+		MaxEjectionTime:    DefaultMaxEjectionTime,
+		MaxEjectionPercent: DefaultMaxEjectionPercent,
+	}
+	if od := clusterConfig.OutlierDetection; od != nil {
+		if od.BaseEjectionTime != nil && od.BaseEjectionTime.Duration > 0 {
+			config.BaseEjectionTime = od.BaseEjectionTime.Duration
+		}
+		if od.MaxEjectionTime != nil && od.MaxEjectionTime.Duration > 0 {
+			config.MaxEjectionTime = od.MaxEjectionTime.Duration
+		}
+		if od.MaxEjectionPercent > 0 {
+			config.MaxEjectionPercent = od.MaxEjectionPercent
+		}
+		config.Consecutive5xx = od.Consecutive5xx
+		config.ConsecutiveConnectFailure = od.ConsecutiveConnectFailure
+	}
+	// the max ejection time is not less than the base
+	if config.MaxEjectionTime < config.BaseEjectionTime {
+		config.MaxEjectionTime = config.BaseEjectionTime
+	}
+	return config
+}
+
+// newSlowStartConfig returns the slow start config of the cluster config,
+// the slow start is disabled if the duration is not configured
+func newSlowStartConfig(clusterConfig v2.Cluster) types.SlowStartConfig {
+	config := types.SlowStartConfig{
+		Aggression:      types.DefaultSlowStartAggression,
+		MinWeightFactor: types.DefaultSlowStartMinWeightFactor,
+	}
+	if ss := clusterConfig.SlowStart; ss != nil {
+		if ss.SlowStartDuration != nil {
+			config.Duration = ss.SlowStartDuration.Duration
+		}
+		if ss.Aggression > 0 {
+			config.Aggression = ss.Aggression
+		}
+		if ss.MinWeightPercent > 0 {
+			config.MinWeightFactor = float64(ss.MinWeightPercent) / 100
+		}
+	}
+	return config
+}
+
 type clusterSnapshot struct {
 	info    types.ClusterInfo
 	hostSet types.HostSet
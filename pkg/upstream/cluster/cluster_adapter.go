@@ -63,3 +63,11 @@ func (ca *MngAdapter) TriggerHostDel(clusterName string, hosts []string) error {
 func (ca *MngAdapter) TriggerHostAppend(clusterName string, hostAppend []v2.Host) error {
 	return ca.AppendClusterHosts(clusterName, hostAppend)
 }
+
+func (ca *MngAdapter) TriggerClusterHealthCheckUpdate(clusterName string, hc v2.HealthCheck) error {
+	return ca.UpdateClusterHealthCheck(clusterName, hc)
+}
+
+func (ca *MngAdapter) TriggerClusterCircuitBreakersUpdate(clusterName string, cb v2.CircuitBreakers) error {
+	return ca.UpdateClusterCircuitBreakers(clusterName, cb)
+}
@@ -19,9 +19,13 @@ package cluster
 
 import (
 	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -279,3 +283,348 @@ func TestConnPoolUpdateTLS(t *testing.T) {
 	}
 
 }
+
+func TestConnPoolByServerName(t *testing.T) {
+	clusterConfig := v2.Cluster{
+		Name:   "tls_server_name",
+		LbType: v2.LB_RANDOM,
+		TLS: v2.TLSConfig{
+			Status:       true,
+			InsecureSkip: true,
+		},
+	}
+	defer removeClusterTLSContextManager(clusterConfig.Name)
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{clusterConfig}, map[string][]v2.Host{
+		"tls_server_name": []v2.Host{{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}}},
+	})
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "tls_server_name")
+	connPool := func(serverName string) *mockConnPool {
+		lbCtx := newMockLbContext(nil).(*mockLbContext)
+		lbCtx.ctx = mosnctx.WithValue(context.Background(), types.ContextKeyUpstreamServerName, serverName)
+		return GetClusterMngAdapterInstance().ConnPoolForCluster(lbCtx, snap, mockProtocol).(*mockConnPool)
+	}
+	configured := connPool("")
+	named := connPool("mosn.test")
+	// the connections of a server name are not shared with the other server names
+	if named == configured || connPool("mosn.test") != named || connPool("other.test") == named {
+		t.Fatal("expected the conn pools chosen by the server name")
+	}
+	if connPool("") != configured {
+		t.Fatal("expected the conn pool of the configured tls context")
+	}
+	// the pools of the server names are closed with the host
+	if err := GetClusterMngAdapterInstance().TriggerHostDel("tls_server_name", []string{"127.0.0.1:10000"}); err != nil {
+		t.Fatal(err)
+	}
+	if !configured.closed || !named.closed {
+		t.Fatal("removed host's conn pools should be closed")
+	}
+}
+
+func TestConnPoolCloseOnHostRemoved(t *testing.T) {
+	host1 := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: "127.0.0.1:10000",
+		},
+	}
+	host2 := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: "127.0.0.1:10001",
+		},
+	}
+	clusterMangerInstance.Destroy() // Destroy for test
+	// test2 shares a host with test1
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "test1", LbType: v2.LB_RANDOM},
+		{Name: "test2", LbType: v2.LB_RANDOM},
+	}, map[string][]v2.Host{
+		"test1": []v2.Host{host1, host2},
+		"test2": []v2.Host{host1},
+	})
+	pools := map[string]*mockConnPool{}
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "test1")
+	for i := 0; i < 100 && len(pools) < 2; i++ {
+		pool := GetClusterMngAdapterInstance().ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol).(*mockConnPool)
+		pools[pool.h.AddressString()] = pool
+	}
+	if len(pools) != 2 {
+		t.Fatalf("expected 2 conn pools, but got %d", len(pools))
+	}
+	if err := GetClusterMngAdapterInstance().TriggerHostDel("test1", []string{"127.0.0.1:10000", "127.0.0.1:10001"}); err != nil {
+		t.Fatal(err)
+	}
+	if !pools["127.0.0.1:10001"].closed {
+		t.Fatal("removed host's conn pool should be closed")
+	}
+	if pools["127.0.0.1:10000"].closed {
+		t.Fatal("conn pool of the host still in use should not be closed")
+	}
+}
+
+func TestConnPoolDrainOnHostRemoved(t *testing.T) {
+	interval := drainCheckInterval
+	drainCheckInterval = time.Hour // the test finishes the draining
+	defer func() {
+		drainCheckInterval = interval
+	}()
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "drain1", LbType: v2.LB_RANDOM},
+		{Name: "drain2", LbType: v2.LB_RANDOM, DrainTimeout: &v2.DurationConfig{}},
+	}, map[string][]v2.Host{
+		"drain1": []v2.Host{{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}}},
+		"drain2": []v2.Host{{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001"}}},
+	})
+	cm := clusterMangerInstance.clusterManager
+	connPool := func(clusterName string) *mockConnPool {
+		snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, clusterName)
+		pool := GetClusterMngAdapterInstance().ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol).(*mockConnPool)
+		pool.h.HostStats().UpstreamRequestActive.Inc(1)
+		return pool
+	}
+	pool1 := connPool("drain1")
+	host1 := pool1.h
+	if err := GetClusterMngAdapterInstance().TriggerHostDel("drain1", []string{"127.0.0.1:10000"}); err != nil {
+		t.Fatal(err)
+	}
+	// the pool with an active request is drained
+	if pool1.closed {
+		t.Fatal("draining host's conn pool should not be closed")
+	}
+	if !host1.ContainHealthFlag(types.DRAINING) || host1.Health() {
+		t.Fatal("removed host should be draining")
+	}
+	stats := host1.ClusterInfo().Stats()
+	if stats.UpstreamHostDraining.Value() != 1 {
+		t.Fatalf("expected 1 draining host, but got %d", stats.UpstreamHostDraining.Value())
+	}
+	cm.mux.Lock()
+	dh := cm.drainingHosts["127.0.0.1:10000"]
+	cm.mux.Unlock()
+	if dh == nil || cm.finishDraining(dh) {
+		t.Fatal("host with active requests should be draining")
+	}
+	host1.HostStats().UpstreamRequestActive.Dec(1)
+	if !cm.finishDraining(dh) || !pool1.closed {
+		t.Fatal("conn pool should be closed after the active requests are done")
+	}
+	if _, ok := cm.drainingHosts["127.0.0.1:10000"]; ok || stats.UpstreamHostDraining.Value() != 0 {
+		t.Fatal("drained host should not be tracked")
+	}
+	// zero drain timeout closes the pool at once
+	pool2 := connPool("drain2")
+	if err := GetClusterMngAdapterInstance().TriggerHostDel("drain2", []string{"127.0.0.1:10001"}); err != nil {
+		t.Fatal(err)
+	}
+	if !pool2.closed {
+		t.Fatal("conn pool should be closed if the drain timeout expires")
+	}
+	if _, ok := cm.drainingHosts["127.0.0.1:10001"]; ok {
+		t.Fatal("drained host should not be tracked")
+	}
+}
+
+func TestConnPoolDrainOnClusterRemoved(t *testing.T) {
+	interval := drainCheckInterval
+	drainCheckInterval = time.Hour // the test finishes the draining
+	defer func() {
+		drainCheckInterval = interval
+	}()
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "drain3", LbType: v2.LB_RANDOM},
+	}, map[string][]v2.Host{
+		"drain3": []v2.Host{{HostConfig: v2.HostConfig{Address: "127.0.0.1:10002"}}},
+	})
+	cm := clusterMangerInstance.clusterManager
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "drain3")
+	pool := GetClusterMngAdapterInstance().ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol).(*mockConnPool)
+	pool.h.HostStats().UpstreamRequestActive.Inc(1)
+	removedStats := pool.h.ClusterInfo().Stats()
+	if err := cm.RemovePrimaryCluster("drain3"); err != nil {
+		t.Fatal(err)
+	}
+	cm.mux.Lock()
+	dh := cm.drainingHosts["127.0.0.1:10002"]
+	cm.mux.Unlock()
+	if dh == nil || pool.closed {
+		t.Fatal("host of the removed cluster should be draining")
+	}
+	// the cluster added again with the same name has new stats
+	if err := cm.AddOrUpdatePrimaryCluster(v2.Cluster{Name: "drain3", LbType: v2.LB_RANDOM}); err != nil {
+		t.Fatal(err)
+	}
+	stats := cm.GetClusterSnapshot(nil, "drain3").ClusterInfo().Stats()
+	pool.h.HostStats().UpstreamRequestActive.Dec(1)
+	if !cm.finishDraining(dh) || !pool.closed {
+		t.Fatal("conn pool should be closed after the active requests are done")
+	}
+	// the stats of the removed cluster are deleted, they are not updated
+	if removedStats.UpstreamHostDraining.Value() != 1 || stats.UpstreamHostDraining.Value() != 0 {
+		t.Fatalf("expected the stats of the removed cluster and the new cluster not changed, but got %d, %d",
+			removedStats.UpstreamHostDraining.Value(), stats.UpstreamHostDraining.Value())
+	}
+}
+
+func TestClusterStatuses(t *testing.T) {
+	interval := drainCheckInterval
+	drainCheckInterval = time.Hour // keep the host draining
+	defer func() {
+		drainCheckInterval = interval
+	}()
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "status2", LbType: v2.LB_RANDOM},
+		{Name: "status1", LbType: v2.LB_ROUNDROBIN},
+	}, map[string][]v2.Host{
+		"status1": []v2.Host{
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001", Weight: 10}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000", Hostname: "h0"}},
+		},
+		"status2": []v2.Host{
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10002"}},
+		},
+	})
+	cm := clusterMangerInstance.clusterManager
+	snap := cm.GetClusterSnapshot(nil, "status1")
+	for _, h := range snap.HostSet().Hosts() {
+		if h.AddressString() == "127.0.0.1:10001" {
+			h.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+		}
+	}
+	// the removed host with an active request is draining
+	pool := cm.ConnPoolForCluster(newMockLbContext(nil), cm.GetClusterSnapshot(nil, "status2"), mockProtocol).(*mockConnPool)
+	pool.h.HostStats().UpstreamRequestActive.Inc(1)
+	if err := cm.RemoveClusterHosts("status2", []string{"127.0.0.1:10002"}); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := cm.ClusterStatuses("")
+	if len(statuses) != 2 || statuses[0].Name != "status1" || statuses[1].Name != "status2" {
+		t.Fatalf("expected the clusters sorted by the name, but got %v", statuses)
+	}
+	s1 := statuses[0]
+	if s1.LbType != types.RoundRobin || s1.TotalHosts != 2 || s1.HealthyHosts != 1 || len(s1.Hosts) != 2 {
+		t.Fatalf("unexpected cluster status: %+v", s1)
+	}
+	if h := s1.Hosts[0]; h.Address != "127.0.0.1:10000" || h.Hostname != "h0" || !h.Healthy || h.OutlierEjected {
+		t.Fatalf("unexpected host status: %+v", h)
+	}
+	if h := s1.Hosts[1]; h.Address != "127.0.0.1:10001" || h.Weight != 10 || h.Healthy || !h.OutlierEjected {
+		t.Fatalf("unexpected host status: %+v", h)
+	}
+	s2 := statuses[1]
+	if s2.TotalHosts != 0 || len(s2.Hosts) != 1 {
+		t.Fatalf("unexpected cluster status: %+v", s2)
+	}
+	if h := s2.Hosts[0]; h.Address != "127.0.0.1:10002" || !h.Draining || h.Healthy || h.ActiveRequests != 1 {
+		t.Fatalf("unexpected draining host status: %+v", h)
+	}
+	// filtered by the name
+	if statuses := cm.ClusterStatuses("status2"); len(statuses) != 1 || statuses[0].Name != "status2" {
+		t.Fatalf("expected the cluster status2, but got %v", statuses)
+	}
+	if statuses := cm.ClusterStatuses("unknown"); len(statuses) != 0 {
+		t.Fatalf("expected no cluster, but got %v", statuses)
+	}
+	pool.h.HostStats().UpstreamRequestActive.Dec(1)
+	cm.mux.Lock()
+	dh := cm.drainingHosts["127.0.0.1:10002"]
+	cm.mux.Unlock()
+	if !cm.finishDraining(dh) {
+		t.Fatal("host without active requests should be drained")
+	}
+	if statuses := cm.ClusterStatuses("status2"); len(statuses[0].Hosts) != 0 {
+		t.Fatalf("expected no hosts, but got %v", statuses[0].Hosts)
+	}
+}
+
+func TestCloseUpstreamConnection(t *testing.T) {
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "test1", LbType: v2.LB_RANDOM},
+		{Name: "test2", LbType: v2.LB_RANDOM},
+	}, map[string][]v2.Host{
+		"test1": []v2.Host{
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001"}},
+		},
+		"test2": []v2.Host{
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10002"}},
+		},
+	})
+	pools := map[string]*mockConnPool{}
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "test1")
+	for i := 0; i < 100 && len(pools) < 2; i++ {
+		pool := GetClusterMngAdapterInstance().ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol).(*mockConnPool)
+		pools[pool.h.AddressString()] = pool
+	}
+	if len(pools) != 2 {
+		t.Fatalf("expected 2 conn pools, but got %d", len(pools))
+	}
+	snapshots := GetClusterMngAdapterInstance().ConnPoolSnapshots("test1")
+	if len(snapshots) != 2 || snapshots[0].Host != "127.0.0.1:10000" || snapshots[1].Host != "127.0.0.1:10001" {
+		t.Fatalf("unexpected conn pool snapshots: %v", snapshots)
+	}
+	// the connection is located across the pools of the cluster
+	if found, active := GetClusterMngAdapterInstance().CloseUpstreamConnection("test1", "127.0.0.1:10001", false); !found || active {
+		t.Fatalf("expected the connection is found and idle, but got found %v, active %v", found, active)
+	}
+	if !pools["127.0.0.1:10001"].closed || pools["127.0.0.1:10000"].closed {
+		t.Fatal("only the connection with the id should be closed")
+	}
+	// the connection of other cluster is not found
+	if found, _ := GetClusterMngAdapterInstance().CloseUpstreamConnection("test2", "127.0.0.1:10000", false); found {
+		t.Fatal("the connection of other cluster should not be found")
+	}
+	if found, _ := GetClusterMngAdapterInstance().CloseUpstreamConnection("unknown", "127.0.0.1:10000", false); found {
+		t.Fatal("the connection of unknown cluster should not be found")
+	}
+}
+
+func TestStatsDeletedOnRemoved(t *testing.T) {
+	hasStats := func(labels map[string]string) bool {
+		for _, m := range metrics.GetAll() {
+			if reflect.DeepEqual(m.Labels(), labels) {
+				return true
+			}
+		}
+		return false
+	}
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "stats1", LbType: v2.LB_RANDOM},
+	}, map[string][]v2.Host{
+		"stats1": []v2.Host{
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001"}},
+		},
+	})
+	host1 := map[string]string{"cluster": "stats1", "host": "127.0.0.1:10000"}
+	host2 := map[string]string{"cluster": "stats1", "host": "127.0.0.1:10001"}
+	if !hasStats(host1) || !hasStats(host2) {
+		t.Fatal("hosts stats should be created")
+	}
+	if err := GetClusterMngAdapterInstance().TriggerHostDel("stats1", []string{"127.0.0.1:10001"}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasStats(host1) || hasStats(host2) {
+		t.Fatal("only the removed host's stats should be deleted")
+	}
+	// the hosts are updated with the same address, the stats are kept
+	if err := GetClusterMngAdapterInstance().TriggerClusterHostUpdate("stats1", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasStats(host1) {
+		t.Fatal("the stats of the host still in the cluster should be kept")
+	}
+	if err := GetClusterMngAdapterInstance().TriggerClusterDel("stats1"); err != nil {
+		t.Fatal(err)
+	}
+	if hasStats(host1) || hasStats(map[string]string{"cluster": "stats1"}) {
+		t.Fatal("the stats of the removed cluster should be deleted")
+	}
+}
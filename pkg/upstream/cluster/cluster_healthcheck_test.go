@@ -314,3 +314,77 @@ func TestHealthCheckWithDynamicCluster(t *testing.T) {
 	// stop health checker
 	cluster.(*simpleCluster).healthChecker.Stop()
 }
+
+func TestClusterUpdateHealthCheck(t *testing.T) {
+	var testServers []*healthCheckTestServer
+	for i := 0; i < 2; i++ {
+		testServers = append(testServers, newHealthCheckTestServer())
+	}
+	cluster := createHealthCheckCluster(testServers)
+	cbTest := &mockCbServer{}
+	cluster.AddHealthCheckCallbacks(cbTest.Record)
+	hosts := cluster.Snapshot().HostSet().Hosts()
+	oldChecker := cluster.(*simpleCluster).healthChecker
+	cluster.UpdateHealthCheck(v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			ServiceName:        "test",
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+		Interval: 100 * time.Millisecond,
+	})
+	newChecker := cluster.(*simpleCluster).healthChecker
+	if newChecker == nil || newChecker == oldChecker {
+		t.Fatal("health checker is not updated")
+	}
+	// hosts are not changed
+	newHosts := cluster.Snapshot().HostSet().Hosts()
+	if len(newHosts) != len(hosts) {
+		t.Fatalf("hosts changed, expected %d, got %d", len(hosts), len(newHosts))
+	}
+	for i := range hosts {
+		if hosts[i] != newHosts[i] {
+			t.Fatal("hosts should not be changed")
+		}
+	}
+	// the new health checker runs with the callbacks
+	testServers[0].server.Close()
+	time.Sleep(time.Second)
+	if atomic.LoadUint32(&cbTest.ToUnhealthy) != 1 {
+		t.Errorf("callbacks should be kept in new health checker, %v", cbTest)
+	}
+	// disable health check
+	cluster.UpdateHealthCheck(v2.HealthCheck{})
+	if cluster.(*simpleCluster).healthChecker != nil {
+		t.Error("health checker should be removed")
+	}
+}
+
+func TestClusterUpdateCircuitBreakers(t *testing.T) {
+	cluster := NewCluster(v2.Cluster{
+		Name:        "test_update_cb",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	rm := cluster.Snapshot().ClusterInfo().ResourceManager()
+	rm.Requests().Increase()
+	cluster.UpdateCircuitBreakers(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{MaxConnections: 1, MaxPendingRequests: 2, MaxRequests: 1, MaxRetries: 4, MaxConnectFailures: 5},
+		},
+	})
+	info := cluster.Snapshot().ClusterInfo()
+	if info.ResourceManager() != rm {
+		t.Fatal("resource manager should not be replaced")
+	}
+	if rm.Connections().Max() != 1 || rm.PendingRequests().Max() != 2 || rm.Requests().Max() != 1 || rm.Retries().Max() != 4 {
+		t.Error("thresholds are not updated")
+	}
+	// current value is kept
+	if rm.Requests().CanCreate() {
+		t.Error("current requests should be kept")
+	}
+	if info.ConnectBackoff().MaxFailures != 5 {
+		t.Error("connect backoff is not updated")
+	}
+}
@@ -21,20 +21,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	gometrics "github.com/rcrowley/go-metrics"
 	"sofastack.io/sofa-mosn/pkg/admin/store"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
 )
 
 var errNilCluster = errors.New("cannot update nil cluster")
 
+var errOriginalDstHosts = errors.New("the hosts of original dst cluster are created for the destinations")
+
+// drainCheckInterval is how often the draining hosts check their active requests
+var drainCheckInterval = time.Second
+
 // refreshHostsConfig refresh the stored config for admin api
 func refreshHostsConfig(name string, hosts []types.Host) {
 	hostsConfig := make([]v2.Host, 0, len(hosts))
@@ -54,7 +64,28 @@ func refreshHostsConfig(name string, hosts []types.Host) {
 type clusterManager struct {
 	clustersMap      sync.Map
 	protocolConnPool sync.Map
-	mux              sync.Mutex
+	// drainingHosts are the removed hosts whose connection pools are not closed yet, keyed by the address
+	drainingHosts map[string]*drainingHost
+	mux           sync.RWMutex
+}
+
+// drainingHost is a host removed from the clusters, its connection pools are kept
+// until the active requests are done or the drain timeout expires
+type drainingHost struct {
+	addr string
+	// hosts are the removed hosts with the address, keyed by the cluster name
+	hosts    map[string]types.Host
+	pools    []types.ConnectionPool
+	deadline time.Time
+}
+
+// activeRequests returns the requests still sent to the removed hosts
+func (dh *drainingHost) activeRequests() int64 {
+	var active int64
+	for _, h := range dh.hosts {
+		active += h.HostStats().UpstreamRequestActive.Count()
+	}
+	return active
 }
 
 type clusterManagerSingleton struct {
@@ -66,17 +97,38 @@ func (singleton *clusterManagerSingleton) Destroy() {
 	clusterMangerInstance.instanceMutex.Lock()
 	defer clusterMangerInstance.instanceMutex.Unlock()
 	clusterMangerInstance.clusterManager = nil
+	notifyClustersChange(nil)
 }
 
 var clusterMangerInstance = &clusterManagerSingleton{}
 
+// ClustersChangeCallback is called when the clusters are added into or removed from the cluster manager,
+// the exist checks whether a cluster is in the cluster manager, it is nil if the cluster manager is destroyed
+type ClustersChangeCallback func(exist func(clusterName string) bool)
+
+var clustersChangeCBs []ClustersChangeCallback
+
+// RegisterClustersChangeListener
+// used to register ClustersChangeCallback
+func RegisterClustersChangeListener(cb ClustersChangeCallback) {
+	clustersChangeCBs = append(clustersChangeCBs, cb)
+}
+
+func notifyClustersChange(exist func(clusterName string) bool) {
+	for _, cb := range clustersChangeCBs {
+		cb(exist)
+	}
+}
+
 func NewClusterManagerSingleton(clusters []v2.Cluster, clusterMap map[string][]v2.Host) types.ClusterManager {
 	clusterMangerInstance.instanceMutex.Lock()
 	defer clusterMangerInstance.instanceMutex.Unlock()
 	if clusterMangerInstance.clusterManager != nil {
 		return clusterMangerInstance
 	}
-	clusterMangerInstance.clusterManager = &clusterManager{}
+	clusterMangerInstance.clusterManager = &clusterManager{
+		drainingHosts: make(map[string]*drainingHost),
+	}
 	for k := range types.ConnPoolFactories {
 		clusterMangerInstance.protocolConnPool.Store(k, &sync.Map{})
 	}
@@ -93,6 +145,7 @@ func NewClusterManagerSingleton(clusters []v2.Cluster, clusterMap map[string][]v
 			log.DefaultLogger.Errorf("[upstream] [cluster manager] NewClusterManager: UpdateClusterHosts failure, cluster name = %s, error: %v", clusterName, err)
 		}
 	}
+	notifyClustersChange(clusterMangerInstance.clusterManager.ClusterExist)
 	return clusterMangerInstance
 }
 
@@ -105,22 +158,44 @@ func (cm *clusterManager) AddOrUpdatePrimaryCluster(cluster v2.Cluster) error {
 		log.DefaultLogger.Alertf(types.ErrorKeyClusterUpdate, "update cluster %s failed", cluster.Name)
 		return errNilCluster
 	}
+	// a healthy host should be dialed again, even if its connection pools are backing off
+	newCluster.AddHealthCheckCallbacks(cm.resetConnectBackoff)
 	// check update or new
 	clusterName := cluster.Name
 	// set config
 	store.SetClusterConfig(clusterName, cluster)
+	if odc, ok := newCluster.(*originalDstCluster); ok {
+		odc.hostRemovedCb = func(host types.Host) {
+			cm.drainRemovedHosts([]types.Host{host}, nil)
+			deleteRemovedHostsStats(clusterName, []types.Host{host}, odc.Snapshot().HostSet().Hosts())
+		}
+	}
 	// add or update
+	var removedHosts []types.Host
 	ci, exists := cm.clustersMap.Load(clusterName)
 	if exists {
 		c := ci.(types.Cluster)
 		//FIXME: cluster info in hosts should be updated too
 		hosts := c.Snapshot().HostSet().Hosts()
-		// update hosts, refresh
-		newCluster.UpdateHosts(hosts)
-		refreshHostsConfig(clusterName, hosts)
+		if isOriginalDstCluster(c) || isOriginalDstCluster(newCluster) {
+			// the hosts created for the destinations and the configured hosts are not exchanged
+			removedHosts = hosts
+			refreshHostsConfig(clusterName, nil)
+		} else {
+			// update hosts, refresh
+			newCluster.UpdateHosts(hosts)
+			refreshHostsConfig(clusterName, hosts)
+		}
 	}
 	cm.clustersMap.Store(clusterName, newCluster)
+	if len(removedHosts) > 0 {
+		cm.drainRemovedHosts(removedHosts, nil)
+		deleteRemovedHostsStats(clusterName, removedHosts, nil)
+	}
 	log.DefaultLogger.Infof("[cluster] [cluster manager] [AddOrUpdatePrimaryCluster] cluster %s updated", clusterName)
+	if !exists {
+		notifyClustersChange(cm.ClusterExist)
+	}
 	return nil
 }
 
@@ -135,6 +210,152 @@ func (cm *clusterManager) AddClusterHealthCheckCallbacks(name string, cb types.H
 	return fmt.Errorf("cluster %s is not exists", name)
 }
 
+// resetConnectBackoff is a health check callback that clears the connect backoff state
+// of the host's connection pools when the host is checked as healthy
+func (cm *clusterManager) resetConnectBackoff(host types.Host, changed bool, isHealthy bool) {
+	if !isHealthy {
+		return
+	}
+	addr := host.AddressString()
+	cm.protocolConnPool.Range(func(k, v interface{}) bool {
+		rangeHostConnPools(v.(*sync.Map), addr, func(key string, connPool types.ConnectionPool) {
+			if pool, ok := connPool.(types.ConnectBackoffPool); ok {
+				pool.ResetConnectBackoff()
+			}
+		})
+		return true
+	})
+}
+
+// clusterConnPools returns the connection pools of the cluster's hosts that expose their connections
+func (cm *clusterManager) clusterConnPools(clusterName string) []types.ConnectionSnapshotPool {
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		return nil
+	}
+	var pools []types.ConnectionSnapshotPool
+	hosts := ci.(types.Cluster).Snapshot().HostSet().Hosts()
+	cm.protocolConnPool.Range(func(k, v interface{}) bool {
+		connectionPool := v.(*sync.Map)
+		for _, h := range hosts {
+			rangeHostConnPools(connectionPool, h.AddressString(), func(key string, connPool types.ConnectionPool) {
+				if pool, ok := connPool.(types.ConnectionSnapshotPool); ok {
+					pools = append(pools, pool)
+				}
+			})
+		}
+		return true
+	})
+	return pools
+}
+
+// ConnPoolSnapshots returns the connection pools' state of the cluster, sorted by the protocol and the host
+func (cm *clusterManager) ConnPoolSnapshots(clusterName string) []types.ConnPoolSnapshot {
+	var snapshots []types.ConnPoolSnapshot
+	for _, pool := range cm.clusterConnPools(clusterName) {
+		snapshots = append(snapshots, pool.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Protocol != snapshots[j].Protocol {
+			return snapshots[i].Protocol < snapshots[j].Protocol
+		}
+		return snapshots[i].Host < snapshots[j].Host
+	})
+	return snapshots
+}
+
+// ClusterStatuses returns the state of the cluster and its hosts, all the clusters sorted by the name if the name is empty.
+// The hosts are copied from the clusters' snapshots, and the draining hosts are copied under the read lock
+func (cm *clusterManager) ClusterStatuses(clusterName string) []types.ClusterStatus {
+	var clusters []types.Cluster
+	if clusterName != "" {
+		if ci, ok := cm.clustersMap.Load(clusterName); ok {
+			clusters = append(clusters, ci.(types.Cluster))
+		}
+	} else {
+		cm.clustersMap.Range(func(k, v interface{}) bool {
+			clusters = append(clusters, v.(types.Cluster))
+			return true
+		})
+	}
+	statuses := make([]types.ClusterStatus, 0, len(clusters))
+	indexes := make(map[string]int, len(clusters))
+	for _, c := range clusters {
+		status := newClusterStatus(c.Snapshot())
+		indexes[status.Name] = len(statuses)
+		statuses = append(statuses, status)
+	}
+	// the draining hosts are removed from the snapshots already
+	cm.mux.RLock()
+	for _, dh := range cm.drainingHosts {
+		for name, h := range dh.hosts {
+			if i, ok := indexes[name]; ok {
+				statuses[i].Hosts = append(statuses[i].Hosts, newHostStatus(h))
+			}
+		}
+	}
+	cm.mux.RUnlock()
+	for _, status := range statuses {
+		hosts := status.Hosts
+		sort.Slice(hosts, func(i, j int) bool {
+			return hosts[i].Address < hosts[j].Address
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func newClusterStatus(snap types.ClusterSnapshot) types.ClusterStatus {
+	info := snap.ClusterInfo()
+	hosts := snap.HostSet().Hosts()
+	status := types.ClusterStatus{
+		Name:               info.Name(),
+		Type:               info.ClusterType(),
+		LbType:             info.LbType(),
+		MaxRequestsPerConn: info.MaxRequestsPerConn(),
+		ConnectTimeout:     info.ConnectTimeout().String(),
+		DrainTimeout:       info.DrainTimeout().String(),
+		TotalHosts:         len(hosts),
+		Hosts:              make([]types.HostStatus, 0, len(hosts)),
+	}
+	// the health flags may be changed after the host set is created
+	for _, h := range hosts {
+		hs := newHostStatus(h)
+		if hs.Healthy {
+			status.HealthyHosts++
+		}
+		status.Hosts = append(status.Hosts, hs)
+	}
+	return status
+}
+
+func newHostStatus(h types.Host) types.HostStatus {
+	return types.HostStatus{
+		Address:                 h.AddressString(),
+		Hostname:                h.Hostname(),
+		Weight:                  h.Weight(),
+		Healthy:                 h.Health(),
+		ActiveHealthCheckFailed: h.ContainHealthFlag(types.FAILED_ACTIVE_HC),
+		OutlierEjected:          h.ContainHealthFlag(types.FAILED_OUTLIER_CHECK),
+		Draining:                h.ContainHealthFlag(types.DRAINING),
+		ActiveRequests:          h.HostStats().UpstreamRequestActive.Count(),
+	}
+}
+
+// CloseUpstreamConnection locates the connection in the cluster's connection pools and closes it
+func (cm *clusterManager) CloseUpstreamConnection(clusterName, connectionID string, graceful bool) (bool, bool) {
+	for _, pool := range cm.clusterConnPools(clusterName) {
+		if found, active := pool.CloseConnection(connectionID, graceful); found {
+			log.DefaultLogger.Infof("[upstream] [cluster manager] close upstream connection %s of cluster %s, graceful: %v, active stream: %v",
+				connectionID, clusterName, graceful, active)
+			return true, active
+		}
+	}
+	return false, false
+}
+
 func (cm *clusterManager) ClusterExist(clusterName string) bool {
 	_, ok := cm.clustersMap.Load(clusterName)
 	return ok
@@ -151,13 +372,27 @@ func (cm *clusterManager) RemovePrimaryCluster(clusterNames ...string) error {
 		}
 	}
 	// delete all of them
+	var hosts []types.Host
+	clustersHosts := make(map[string][]types.Host, len(clusterNames))
 	for _, clusterName := range clusterNames {
+		if ci, ok := cm.clustersMap.Load(clusterName); ok {
+			clusterHosts := ci.(types.Cluster).Snapshot().HostSet().Hosts()
+			hosts = append(hosts, clusterHosts...)
+			clustersHosts[clusterName] = clusterHosts
+		}
 		cm.clustersMap.Delete(clusterName)
 		store.RemoveClusterConfig(clusterName)
+		removeClusterTLSContextManager(clusterName)
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[upstream] [cluster manager] Remove Primary Cluster, Cluster Name = %s", clusterName)
 		}
 	}
+	// the hosts of the removed clusters are not used any more, unless other clusters have them
+	cm.drainRemovedHosts(hosts, nil)
+	for clusterName, clusterHosts := range clustersHosts {
+		deleteClusterStats(clusterName, clusterHosts)
+	}
+	notifyClustersChange(cm.ClusterExist)
 	return nil
 }
 
@@ -169,6 +404,9 @@ func (cm *clusterManager) UpdateClusterHosts(clusterName string, hostConfigs []v
 		return fmt.Errorf("cluster %s is not exists", clusterName)
 	}
 	c := ci.(types.Cluster)
+	if isOriginalDstCluster(c) {
+		return checkOriginalDstHosts(len(hostConfigs))
+	}
 	snap := c.Snapshot()
 	hosts := make([]types.Host, 0, len(hostConfigs))
 	for _, hc := range hostConfigs {
@@ -176,6 +414,8 @@ func (cm *clusterManager) UpdateClusterHosts(clusterName string, hostConfigs []v
 	}
 	c.UpdateHosts(hosts)
 	refreshHostsConfig(clusterName, hosts)
+	cm.drainRemovedHosts(snap.HostSet().Hosts(), hosts)
+	deleteRemovedHostsStats(clusterName, snap.HostSet().Hosts(), hosts)
 	return nil
 }
 
@@ -187,6 +427,9 @@ func (cm *clusterManager) AppendClusterHosts(clusterName string, hostConfigs []v
 		return fmt.Errorf("cluster %s is not exists", clusterName)
 	}
 	c := ci.(types.Cluster)
+	if isOriginalDstCluster(c) {
+		return checkOriginalDstHosts(len(hostConfigs))
+	}
 	snap := c.Snapshot()
 	hosts := make([]types.Host, 0, len(hostConfigs))
 	for _, hc := range hostConfigs {
@@ -206,6 +449,9 @@ func (cm *clusterManager) RemoveClusterHosts(clusterName string, addrs []string)
 		return fmt.Errorf("cluster %s is not exists", clusterName)
 	}
 	c := ci.(types.Cluster)
+	if isOriginalDstCluster(c) {
+		return checkOriginalDstHosts(len(addrs))
+	}
 	snap := c.Snapshot()
 	hosts := snap.HostSet().Hosts()
 	newHosts := make([]types.Host, len(hosts))
@@ -223,6 +469,186 @@ func (cm *clusterManager) RemoveClusterHosts(clusterName string, addrs []string)
 	}
 	c.UpdateHosts(sortedHosts)
 	refreshHostsConfig(clusterName, sortedHosts)
+	cm.drainRemovedHosts(hosts, sortedHosts)
+	deleteRemovedHostsStats(clusterName, hosts, sortedHosts)
+	return nil
+}
+
+// isOriginalDstCluster checks whether the cluster's hosts are created for the destinations
+func isOriginalDstCluster(c types.Cluster) bool {
+	_, ok := c.(*originalDstCluster)
+	return ok
+}
+
+// checkOriginalDstHosts ignores the empty hosts update of the original dst cluster, as all the clusters
+// are updated with the configured hosts at start, and rejects the others
+func checkOriginalDstHosts(hostsNum int) error {
+	if hostsNum > 0 {
+		return errOriginalDstHosts
+	}
+	return nil
+}
+
+// drainRemovedHosts drains the hosts removed from a cluster. The removed hosts are not chosen
+// by the load balancers any more, and their connection pools are closed once the active requests
+// are done or the cluster's drain timeout expires, the stats of the draining hosts are kept until then.
+// The pool is kept if the address is still used by any cluster.
+func (cm *clusterManager) drainRemovedHosts(oldHosts, newHosts []types.Host) {
+	removed := make(map[string][]types.Host, len(oldHosts))
+	for _, h := range oldHosts {
+		removed[h.AddressString()] = append(removed[h.AddressString()], h)
+	}
+	for _, h := range newHosts {
+		delete(removed, h.AddressString())
+	}
+	if len(removed) == 0 {
+		return
+	}
+	cm.clustersMap.Range(func(k, v interface{}) bool {
+		for _, h := range v.(types.Cluster).Snapshot().HostSet().Hosts() {
+			delete(removed, h.AddressString())
+		}
+		return true
+	})
+	var draining []*drainingHost
+	cm.mux.Lock()
+	for addr, hosts := range removed {
+		var pools []types.ConnectionPool
+		cm.protocolConnPool.Range(func(k, v interface{}) bool {
+			connectionPool := v.(*sync.Map)
+			rangeHostConnPools(connectionPool, addr, func(key string, connPool types.ConnectionPool) {
+				connectionPool.Delete(key)
+				pools = append(pools, connPool)
+				if log.DefaultLogger.GetLogLevel() >= log.INFO {
+					log.DefaultLogger.Infof("[upstream] [cluster manager] host %s removed, drain %v connection pool", key, k)
+				}
+			})
+			return true
+		})
+		dh, exists := cm.drainingHosts[addr]
+		// no request is sent to the host without a connection pool
+		if len(pools) == 0 && !exists {
+			continue
+		}
+		if !exists {
+			dh = &drainingHost{
+				addr:  addr,
+				hosts: make(map[string]types.Host, len(hosts)),
+			}
+			cm.drainingHosts[addr] = dh
+			draining = append(draining, dh)
+		}
+		dh.pools = append(dh.pools, pools...)
+		for _, h := range hosts {
+			h.SetHealthFlag(types.DRAINING)
+			info := h.ClusterInfo()
+			if _, ok := dh.hosts[info.Name()]; !ok {
+				dh.hosts[info.Name()] = h
+				// the draining gauges are updated with the lock held
+				draining := info.Stats().UpstreamHostDraining
+				draining.Update(draining.Value() + 1)
+			}
+			if deadline := time.Now().Add(info.DrainTimeout()); deadline.After(dh.deadline) {
+				dh.deadline = deadline
+			}
+		}
+	}
+	cm.mux.Unlock()
+	for _, dh := range draining {
+		if cm.finishDraining(dh) {
+			continue
+		}
+		dh := dh
+		interval := drainCheckInterval
+		utils.GoWithRecover(func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if cm.finishDraining(dh) {
+					return
+				}
+			}
+		}, nil)
+	}
+}
+
+// finishDraining closes the draining host's connection pools if there is no active request
+// or the drain timeout expires, it returns false if the host is still draining
+func (cm *clusterManager) finishDraining(dh *drainingHost) bool {
+	cm.mux.Lock()
+	active := dh.activeRequests()
+	if active > 0 && time.Now().Before(dh.deadline) {
+		cm.mux.Unlock()
+		return false
+	}
+	delete(cm.drainingHosts, dh.addr)
+	for clusterName, h := range dh.hosts {
+		// the stats of a removed cluster are deleted, and not shared with the cluster added again with the same name
+		if draining := h.ClusterInfo().Stats().UpstreamHostDraining; cm.clusterHasStats(clusterName, draining) {
+			draining.Update(draining.Value() - 1)
+		}
+		// the stats are shared with the host added back to the cluster
+		if !cm.clusterHasHost(clusterName, dh.addr) {
+			metrics.DeleteHostStats(clusterName, dh.addr)
+		}
+	}
+	pools := dh.pools
+	cm.mux.Unlock()
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[upstream] [cluster manager] host %s drained, close %d connection pools, active requests: %d", dh.addr, len(pools), active)
+	}
+	for _, pool := range pools {
+		pool.Close()
+	}
+	return true
+}
+
+// clusterHasStats checks whether the cluster exists and its stats contain the draining gauge
+func (cm *clusterManager) clusterHasStats(clusterName string, draining gometrics.Gauge) bool {
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		return false
+	}
+	return ci.(types.Cluster).Snapshot().ClusterInfo().Stats().UpstreamHostDraining == draining
+}
+
+// clusterHasHost checks whether the cluster has a host with the address
+func (cm *clusterManager) clusterHasHost(clusterName, addr string) bool {
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		return false
+	}
+	for _, h := range ci.(types.Cluster).Snapshot().HostSet().Hosts() {
+		if h.AddressString() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateClusterHealthCheck updates the cluster's health check without creating a new cluster
+func (cm *clusterManager) UpdateClusterHealthCheck(clusterName string, hc v2.HealthCheck) error {
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		log.DefaultLogger.Alertf(types.ErrorKeyClusterUpdate, "cluster %s not found", clusterName)
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	c := ci.(types.Cluster)
+	c.UpdateHealthCheck(hc)
+	store.SetClusterHealthCheck(clusterName, hc)
+	return nil
+}
+
+// UpdateClusterCircuitBreakers updates the cluster's circuit breakers without creating a new cluster
+func (cm *clusterManager) UpdateClusterCircuitBreakers(clusterName string, cb v2.CircuitBreakers) error {
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		log.DefaultLogger.Alertf(types.ErrorKeyClusterUpdate, "cluster %s not found", clusterName)
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	c := ci.(types.Cluster)
+	c.UpdateCircuitBreakers(cb)
+	store.SetClusterCircuitBreakers(clusterName, cb)
 	return nil
 }
 
@@ -243,33 +669,103 @@ func (cm *clusterManager) TCPConnForCluster(lbCtx types.LoadBalancerContext, sna
 	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
 		return types.CreateConnectionData{}
 	}
-	host := snapshot.LoadBalancer().ChooseHost(lbCtx)
+	// a retried connection chooses another host than the ones it tried
+	host := chooseHost(lbCtx, snapshot)
 	if host == nil {
 		return types.CreateConnectionData{}
 	}
-	return host.CreateConnection(context.Background())
+	// the downstream context carries the downstream connection
+	ctx := context.Background()
+	if lbCtx != nil && lbCtx.DownstreamContext() != nil {
+		ctx = lbCtx.DownstreamContext()
+	}
+	return host.CreateConnection(ctx)
+}
+
+// UDPConnForCluster dials a connected udp socket, the datagrams are sent to the host without the connect handshake
+func (cm *clusterManager) UDPConnForCluster(lbCtx types.LoadBalancerContext, snapshot types.ClusterSnapshot) (types.CreateUDPConnectionData, error) {
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
+		return types.CreateUDPConnectionData{}, errNilSnapshot
+	}
+	host := snapshot.LoadBalancer().ChooseHost(lbCtx)
+	if host == nil {
+		return types.CreateUDPConnectionData{}, errNilHostChoose
+	}
+	conn, err := net.Dial("udp", host.AddressString())
+	if err != nil {
+		return types.CreateUDPConnectionData{}, err
+	}
+	return types.CreateUDPConnectionData{
+		Connection: conn,
+		HostInfo:   host,
+	}, nil
 }
 
+// connPoolErrorLogRate is the max connection pool error logs per second of the same kind,
+// the errors are logged per request, which floods the disk if the cluster is missing or has no healthy host
+const connPoolErrorLogRate = 10
+
 func (cm *clusterManager) ConnPoolForCluster(balancerContext types.LoadBalancerContext, snapshot types.ClusterSnapshot, protocol types.Protocol) types.ConnectionPool {
 	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
-		log.DefaultLogger.Errorf("[upstream] [cluster manager]  %s ConnPool For Cluster is nil", protocol)
+		log.DefaultLogger.ErrorfThrottled("upstream.connpool.nil_snapshot", connPoolErrorLogRate, "[upstream] [cluster manager]  %s ConnPool For Cluster is nil", protocol)
 		return nil
 	}
 	pool, err := cm.getActiveConnectionPool(balancerContext, snapshot, protocol)
 	if err != nil {
-		log.DefaultLogger.Errorf("[upstream] [cluster manager] ConnPoolForCluster Failed; %v", err)
+		log.DefaultLogger.ErrorfThrottled("upstream.connpool.failed", connPoolErrorLogRate, "[upstream] [cluster manager] ConnPoolForCluster Failed; %v", err)
 	}
 	return pool
 }
 
 const cycleTimes = 3
 
+// chooseHostTimes is the max times to choose a host not tried by the retried request
+const chooseHostTimes = 3
+
 var (
 	errNilHostChoose   = errors.New("cluster snapshot choose host is nil")
 	errUnknownProtocol = errors.New("protocol pool can not found protocol")
 	errNoHealthyHost   = errors.New("no health hosts")
+	errNilSnapshot     = errors.New("cluster snapshot is nil")
 )
 
+// chooseHost chooses a host by the cluster's load balancer, a retried request
+// chooses another host than the ones it tried if there are other healthy hosts
+func chooseHost(balancerContext types.LoadBalancerContext, clusterSnapshot types.ClusterSnapshot) types.Host {
+	lb := clusterSnapshot.LoadBalancer()
+	host := lb.ChooseHost(balancerContext)
+	retryContext, ok := balancerContext.(types.RetryLoadBalancerContext)
+	if !ok || host == nil || len(clusterSnapshot.HostSet().HealthyHosts()) < 2 {
+		return host
+	}
+	for i := 0; i < chooseHostTimes && retryContext.ShouldSelectAnotherHost(host); i++ {
+		if another := lb.ChooseHost(balancerContext); another != nil {
+			host = another
+		}
+	}
+	return host
+}
+
+// connPoolKey is the key of the host's connection pool, the tls connections
+// of a server name are pooled apart from the host's other connections
+func connPoolKey(addr, serverName string) string {
+	if serverName == "" {
+		return addr
+	}
+	return addr + "#" + serverName
+}
+
+// rangeHostConnPools calls f with the connection pools of the host address
+func rangeHostConnPools(connectionPool *sync.Map, addr string, f func(key string, pool types.ConnectionPool)) {
+	prefix := addr + "#"
+	connectionPool.Range(func(k, v interface{}) bool {
+		if key := k.(string); key == addr || strings.HasPrefix(key, prefix) {
+			f(key, v.(types.ConnectionPool))
+		}
+		return true
+	})
+}
+
 func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBalancerContext, clusterSnapshot types.ClusterSnapshot, protocol types.Protocol) (types.ConnectionPool, error) {
 	factory, ok := network.ConnNewPoolFactories[protocol]
 	if !ok {
@@ -286,7 +782,7 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 		try = cycleTimes
 	}
 	for i := 0; i < try; i++ {
-		host := clusterSnapshot.LoadBalancer().ChooseHost(balancerContext)
+		host := chooseHost(balancerContext, clusterSnapshot)
 		if host == nil {
 			return nil, errNilHostChoose
 		}
@@ -295,6 +791,8 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 			log.DefaultLogger.Debugf("[upstream] [cluster manager] clusterSnapshot.loadbalancer.ChooseHost result is %s, cluster name = %s", addr, clusterSnapshot.ClusterInfo().Name())
 		}
+		// the tls connections of the different server names are not shared
+		key := connPoolKey(addr, upstreamServerName(balancerContext.DownstreamContext(), host))
 		value, ok := cm.protocolConnPool.Load(protocol)
 		if !ok {
 			return nil, errUnknownProtocol
@@ -304,40 +802,40 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 		// we cannot use sync.Map.LoadOrStore directly, becasue we do not want to new a connpool every time
 		loadOrStoreConnPool := func() (types.ConnectionPool, bool) {
 			// avoid locking if it is already exists
-			if connPool, ok := connectionPool.Load(addr); ok {
+			if connPool, ok := connectionPool.Load(key); ok {
 				pool := connPool.(types.ConnectionPool)
 				return pool, true
 			}
 			cm.mux.Lock()
 			defer cm.mux.Unlock()
-			if connPool, ok := connectionPool.Load(addr); ok {
+			if connPool, ok := connectionPool.Load(key); ok {
 				pool := connPool.(types.ConnectionPool)
 				return pool, true
 			}
 			pool := factory(host)
-			connectionPool.Store(addr, pool)
+			connectionPool.Store(key, pool)
 			return pool, false
 		}
 		pool, loaded := loadOrStoreConnPool()
 		if loaded {
 			if pool.SupportTLS() != host.SupportTLS() {
 				if log.DefaultLogger.GetLogLevel() >= log.INFO {
-					log.DefaultLogger.Infof("[upstream] [cluster manager] %s tls state changed", addr)
+					log.DefaultLogger.Infof("[upstream] [cluster manager] %s tls state changed", key)
 				}
 				func() {
 					// lock the load and delete
 					cm.mux.Lock()
 					defer cm.mux.Unlock()
 					// recheck whether the pool is changed
-					if connPool, ok := connectionPool.Load(addr); ok {
+					if connPool, ok := connectionPool.Load(key); ok {
 						pool = connPool.(types.ConnectionPool)
 						if pool.SupportTLS() == host.SupportTLS() {
 							return
 						}
-						connectionPool.Delete(addr)
+						connectionPool.Delete(key)
 						pool.Shutdown()
 						pool = factory(host)
-						connectionPool.Store(addr, pool)
+						connectionPool.Store(key, pool)
 					}
 				}()
 			}
@@ -1,9 +1,16 @@
 package cluster
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"testing"
+	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
+	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -163,3 +170,409 @@ func TestUpdateHostLabels(t *testing.T) {
 		}
 	}
 }
+
+func TestNewKeepAliveConfig(t *testing.T) {
+	// the default config sends the heartbeats when the connection is idle
+	config := newKeepAliveConfig(v2.Cluster{})
+	if config.Interval != 0 || config.Timeout != types.DefaultKeepAliveTimeout || config.FailCountToClose != types.DefaultKeepAliveFailCount {
+		t.Errorf("unexpected default keepalive config: %+v", config)
+	}
+	// the timeout uses the health check timeout
+	clusterConfig := v2.Cluster{
+		HealthCheck: v2.HealthCheck{
+			Timeout: 3 * time.Second,
+		},
+		KeepAlive: &v2.KeepAlive{
+			Interval:         &v2.DurationConfig{Duration: 15 * time.Second},
+			FailCountToClose: 3,
+		},
+	}
+	config = newKeepAliveConfig(clusterConfig)
+	if config.Interval != 15*time.Second || config.Timeout != 3*time.Second || config.FailCountToClose != 3 ||
+		config.IntervalJitter != types.DefaultKeepAliveJitter || config.MaxInterval != 120*time.Second {
+		t.Errorf("unexpected keepalive config: %+v", config)
+	}
+	clusterConfig.KeepAlive.Timeout = &v2.DurationConfig{Duration: 2 * time.Second}
+	jitter := uint32(0)
+	clusterConfig.KeepAlive.JitterPercent = &jitter
+	clusterConfig.KeepAlive.MaxInterval = &v2.DurationConfig{}
+	config = newKeepAliveConfig(clusterConfig)
+	if config.Timeout != 2*time.Second || config.IntervalJitter != 0 || config.MaxInterval != 0 {
+		t.Errorf("unexpected keepalive config: %+v", config)
+	}
+}
+
+func TestEjectHost(t *testing.T) {
+	clusterConfig := v2.Cluster{
+		Name:   "test_eject",
+		LbType: v2.LB_RANDOM,
+		OutlierDetection: &v2.OutlierDetection{
+			BaseEjectionTime: &v2.DurationConfig{Duration: 100 * time.Millisecond},
+		},
+	}
+	cluster := newSimpleCluster(clusterConfig)
+	var hosts []types.Host
+	for _, addr := range []string{"127.0.0.1:10000", "127.0.0.1:10001"} {
+		hosts = append(hosts, NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: addr}}, cluster.info))
+	}
+	cluster.UpdateHosts(hosts)
+	detector := cluster.Snapshot().ClusterInfo().OutlierDetector()
+	if detector == nil {
+		t.Fatal("no outlier detector")
+	}
+	healthy := func() int {
+		return len(cluster.Snapshot().HostSet().HealthyHosts())
+	}
+	if !detector.EjectHost(hosts[0]) || healthy() != 1 {
+		t.Fatalf("eject host failed, healthy hosts: %d", healthy())
+	}
+	if detector.EjectHost(hosts[0]) {
+		t.Fatal("the host is ejected already")
+	}
+	// re-admitted after the base ejection time
+	time.Sleep(200 * time.Millisecond)
+	if healthy() != 2 || !hosts[0].Health() {
+		t.Fatalf("the host is not re-admitted, healthy hosts: %d", healthy())
+	}
+	// re-admitted by the health checker
+	if !detector.EjectHost(hosts[1]) || healthy() != 1 {
+		t.Fatalf("eject host failed, healthy hosts: %d", healthy())
+	}
+	for _, cb := range cluster.healthCheckCbs {
+		cb(hosts[1], false, true)
+	}
+	if healthy() != 2 || len(cluster.ejectTimers) != 0 {
+		t.Fatalf("the host is not re-admitted, healthy hosts: %d", healthy())
+	}
+}
+
+func TestEjectHostKeptOnUpdate(t *testing.T) {
+	clusterConfig := v2.Cluster{
+		Name:   "test_eject_update",
+		LbType: v2.LB_RANDOM,
+		OutlierDetection: &v2.OutlierDetection{
+			BaseEjectionTime: &v2.DurationConfig{Duration: 300 * time.Millisecond},
+		},
+	}
+	cluster := newSimpleCluster(clusterConfig)
+	newHosts := func() []types.Host {
+		var hosts []types.Host
+		for _, addr := range []string{"127.0.0.1:10000", "127.0.0.1:10001"} {
+			hosts = append(hosts, NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: addr}}, cluster.info))
+		}
+		return hosts
+	}
+	hosts := newHosts()
+	cluster.UpdateHosts(hosts)
+	healthy := func() int {
+		return len(cluster.Snapshot().HostSet().HealthyHosts())
+	}
+	if !cluster.EjectHost(hosts[0]) || healthy() != 1 {
+		t.Fatalf("eject host failed, healthy hosts: %d", healthy())
+	}
+	// the refreshed host of the same address is still ejected
+	hosts = newHosts()
+	cluster.UpdateHosts(hosts)
+	if healthy() != 1 || hosts[0].Health() || !hosts[0].ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Fatalf("the refreshed host is not ejected, healthy hosts: %d", healthy())
+	}
+	cluster.mutex.Lock()
+	timers, count := len(cluster.ejectTimers), cluster.ejections["127.0.0.1:10000"].count
+	cluster.mutex.Unlock()
+	if timers != 1 || count != 1 {
+		t.Fatalf("the ejection is not carried over, timers %d, count %d", timers, count)
+	}
+	if active := cluster.info.stats.UpstreamOutlierEjectionsActive.Count(); active != 1 {
+		t.Fatalf("unexpected active ejections: %d", active)
+	}
+	// and re-admitted when the ejection ends
+	time.Sleep(500 * time.Millisecond)
+	if healthy() != 2 || !hosts[0].Health() {
+		t.Fatalf("the host is not re-admitted, healthy hosts: %d", healthy())
+	}
+	// the ejection of a removed host is dropped
+	if !cluster.EjectHost(hosts[1]) {
+		t.Fatal("eject host failed")
+	}
+	cluster.UpdateHosts(hosts[:1])
+	cluster.mutex.Lock()
+	timers = len(cluster.ejectTimers)
+	cluster.mutex.Unlock()
+	if timers != 0 || cluster.info.stats.UpstreamOutlierEjectionsActive.Count() != 0 {
+		t.Fatalf("the ejection of the removed host is kept, timers %d", timers)
+	}
+}
+
+func TestSlowStartFactor(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name: "test_slow_start_factor",
+		SlowStart: &v2.SlowStart{
+			SlowStartDuration: &v2.DurationConfig{Duration: 100 * time.Second},
+			Aggression:        2,
+			MinWeightPercent:  20,
+		},
+	})
+	host := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}}, cluster.info)
+	// the host is not in slow start if it has never become healthy
+	if f := slowStartFactor(host); f != 1 {
+		t.Fatalf("unexpected factor %v", f)
+	}
+	now := time.Now()
+	for _, tc := range []struct {
+		elapsed time.Duration
+		min     float64
+		max     float64
+	}{
+		{0, 0.2, 0.2},
+		{time.Second, 0.2, 0.2},
+		{25 * time.Second, 0.49, 0.51},
+		{64 * time.Second, 0.79, 0.81},
+		{100 * time.Second, 1, 1},
+	} {
+		host.SetLastHealthyTime(now.Add(-tc.elapsed))
+		if f := slowStartFactor(host); f < tc.min || f > tc.max {
+			t.Errorf("elapsed %s, expected factor in [%v, %v], but got %v", tc.elapsed, tc.min, tc.max, f)
+		}
+	}
+	// disabled
+	cluster = newSimpleCluster(v2.Cluster{Name: "test_slow_start_disabled"})
+	host = NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}}, cluster.info)
+	host.SetLastHealthyTime(time.Now())
+	if f := slowStartFactor(host); f != 1 {
+		t.Fatalf("unexpected factor %v", f)
+	}
+}
+
+func TestSlowStart(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:   "test_slow_start",
+		LbType: v2.LB_RANDOM,
+		SlowStart: &v2.SlowStart{
+			SlowStartDuration: &v2.DurationConfig{Duration: 200 * time.Millisecond},
+		},
+	})
+	newHost := func(addr string) types.Host {
+		return NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: addr}}, cluster.info)
+	}
+	stats := cluster.info.stats
+	var hosts []types.Host
+	for _, addr := range []string{"127.0.0.1:10000", "127.0.0.1:10001", "127.0.0.1:10002", "127.0.0.1:10003"} {
+		hosts = append(hosts, newHost(addr))
+	}
+	// the hosts of the first update are not in slow start
+	cluster.UpdateHosts(hosts)
+	if c := stats.UpstreamHostSlowStart.Count(); c != 0 {
+		t.Fatalf("unexpected slow start hosts: %d", c)
+	}
+	added := newHost("127.0.0.1:10004")
+	cluster.UpdateHosts(append(hosts, added))
+	if c := stats.UpstreamHostSlowStart.Count(); c != 1 {
+		t.Fatalf("unexpected slow start hosts: %d", c)
+	}
+	// the new host receives less requests
+	lb := cluster.Snapshot().LoadBalancer()
+	count := 0
+	for i := 0; i < 10000; i++ {
+		if lb.ChooseHost(nil) == added {
+			count++
+		}
+	}
+	if count > 1000 {
+		t.Errorf("the host in slow start receives too many requests: %d", count)
+	}
+	// the kept host keeps its slow start
+	kept := newHost("127.0.0.1:10004")
+	cluster.UpdateHosts(append(hosts, kept))
+	if !kept.LastHealthyTime().Equal(added.LastHealthyTime()) || stats.UpstreamHostSlowStart.Count() != 1 {
+		t.Fatalf("the slow start is not kept, slow start hosts: %d", stats.UpstreamHostSlowStart.Count())
+	}
+	time.Sleep(300 * time.Millisecond)
+	if c := stats.UpstreamHostSlowStart.Count(); c != 0 {
+		t.Fatalf("the slow start is not ended, slow start hosts: %d", c)
+	}
+	// the host becomes healthy
+	for _, cb := range cluster.healthCheckCbs {
+		cb(hosts[0], true, true)
+	}
+	if c := stats.UpstreamHostSlowStart.Count(); c != 1 || slowStartFactor(hosts[0]) >= 1 {
+		t.Fatalf("unexpected slow start hosts: %d", c)
+	}
+	// the removed host ends its slow start
+	cluster.UpdateHosts(hosts[1:])
+	if c := stats.UpstreamHostSlowStart.Count(); c != 0 || len(cluster.slowStartTimers) != 0 {
+		t.Fatalf("unexpected slow start hosts: %d", c)
+	}
+}
+
+func TestOutlierDetection(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:   "test_outlier_detection",
+		LbType: v2.LB_RANDOM,
+		OutlierDetection: &v2.OutlierDetection{
+			BaseEjectionTime:          &v2.DurationConfig{Duration: 100 * time.Millisecond},
+			Consecutive5xx:            3,
+			ConsecutiveConnectFailure: 2,
+			MaxEjectionPercent:        50,
+		},
+	})
+	var hosts []types.Host
+	for _, addr := range []string{"127.0.0.1:10000", "127.0.0.1:10001", "127.0.0.1:10002", "127.0.0.1:10003"} {
+		hosts = append(hosts, NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: addr}}, cluster.info))
+	}
+	cluster.UpdateHosts(hosts)
+	stats := cluster.info.stats
+	ejectionsTotal := stats.UpstreamOutlierEjectionsTotal.Count()
+	healthy := func() int {
+		return len(cluster.Snapshot().HostSet().HealthyHosts())
+	}
+	// the consecutive 5xx is reset by a success
+	for _, result := range []types.HostResult{types.HostResult5xx, types.HostResultTimeout, types.HostResultSuccess, types.HostResult5xx, types.HostResult5xx} {
+		hosts[0].ReportResult(result)
+	}
+	if !hosts[0].Health() || healthy() != 4 {
+		t.Fatal("the host should not be ejected")
+	}
+	hosts[0].ReportResult(types.HostResultTimeout)
+	if hosts[0].Health() || healthy() != 3 {
+		t.Fatal("the host is not ejected after consecutive 5xx")
+	}
+	for i := 0; i < 2; i++ {
+		hosts[1].ReportResult(types.HostResultConnectFailure)
+	}
+	if hosts[1].Health() || healthy() != 2 {
+		t.Fatal("the host is not ejected after consecutive connect failures")
+	}
+	// the max ejection percent is reached
+	for i := 0; i < 2; i++ {
+		hosts[2].ReportResult(types.HostResultConnectFailure)
+	}
+	if !hosts[2].Health() || healthy() != 2 {
+		t.Fatal("the host should not be ejected over the max ejection percent")
+	}
+	if n := stats.UpstreamOutlierEjectionsTotal.Count() - ejectionsTotal; n != 2 || stats.UpstreamOutlierEjectionsActive.Count() != 2 {
+		t.Fatalf("unexpected ejection stats, total: %d, active: %d", n, stats.UpstreamOutlierEjectionsActive.Count())
+	}
+	time.Sleep(150 * time.Millisecond)
+	if healthy() != 4 || stats.UpstreamOutlierEjectionsActive.Count() != 0 {
+		t.Fatalf("the hosts are not re-admitted, healthy hosts: %d", healthy())
+	}
+	// the ejection time is multiplied by the ejection count
+	for i := 0; i < 3; i++ {
+		hosts[0].ReportResult(types.HostResult5xx)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if hosts[0].Health() {
+		t.Fatal("the host should be ejected for twice the base ejection time")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !hosts[0].Health() {
+		t.Fatal("the host is not re-admitted")
+	}
+	// the ejection of the removed host is dropped
+	hosts[3].ReportResult(types.HostResultConnectFailure)
+	hosts[3].ReportResult(types.HostResultConnectFailure)
+	if hosts[3].Health() || stats.UpstreamOutlierEjectionsActive.Count() != 1 {
+		t.Fatal("the host is not ejected")
+	}
+	cluster.UpdateHosts(hosts[:3])
+	if len(cluster.ejectTimers) != 0 || len(cluster.ejections) != 2 || stats.UpstreamOutlierEjectionsActive.Count() != 0 {
+		t.Fatalf("the ejection is not dropped, active: %d", stats.UpstreamOutlierEjectionsActive.Count())
+	}
+}
+
+type retryLbContext struct {
+	mockLbContext
+	tried types.Host
+}
+
+func (ctx *retryLbContext) ShouldSelectAnotherHost(host types.Host) bool {
+	return host == ctx.tried
+}
+
+func TestChooseHostForRetry(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:   "test_choose_host_for_retry",
+		LbType: v2.LB_RANDOM,
+	})
+	var hosts []types.Host
+	for _, addr := range []string{"127.0.0.1:10000", "127.0.0.1:10001"} {
+		hosts = append(hosts, NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: addr}}, cluster.info))
+	}
+	cluster.UpdateHosts(hosts)
+	ctx := &retryLbContext{tried: hosts[0]}
+	count := 0
+	for i := 0; i < 1000; i++ {
+		if chooseHost(ctx, cluster.Snapshot()) == hosts[0] {
+			count++
+		}
+	}
+	// a random choice is the tried host in 1 of 16 with 3 more tries
+	if count > 150 {
+		t.Errorf("the tried host is chosen %d times", count)
+	}
+	// the only healthy host is chosen
+	cluster.EjectHost(hosts[1])
+	if host := chooseHost(ctx, cluster.Snapshot()); host != hosts[0] {
+		t.Errorf("unexpected host %v", host)
+	}
+}
+
+func TestCreateConnectionWithProxyProtocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	headers := make(chan *proxyprotocol.Header, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			header, _ := proxyprotocol.ReadHeader(c, time.Second)
+			headers <- header
+			c.Close()
+		}
+	}()
+
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+	downstream := network.NewClientConnection(dst, 0, nil, src, nil)
+	defer removeClusterTLSContextManager("proxy_protocol_cluster")
+	for _, version := range []v2.ProxyProtocolVersion{v2.PROXY_PROTOCOL_V1, v2.PROXY_PROTOCOL_V2} {
+		info := NewCluster(v2.Cluster{
+			Name:          "proxy_protocol_cluster",
+			LbType:        v2.LB_RANDOM,
+			ProxyProtocol: version,
+		}).Snapshot().ClusterInfo()
+		host := NewSimpleHost(v2.Host{
+			HostConfig: v2.HostConfig{
+				Address: ln.Addr().String(),
+			},
+		}, info)
+		// the header carries the addresses of the downstream connection
+		ctx := mosnctx.WithValue(context.Background(), types.ContextKeyConnection, downstream)
+		for _, tc := range []struct {
+			ctx      context.Context
+			src, dst string
+		}{
+			{ctx, src.String(), dst.String()},
+			{context.Background(), "<nil>", "<nil>"},
+		} {
+			conn := host.CreateConnection(tc.ctx).Connection
+			if err := conn.Connect(); err != nil {
+				t.Fatalf("connect failed: %v", err)
+			}
+			select {
+			case header := <-headers:
+				if header == nil || fmt.Sprint(header.Source) != tc.src || fmt.Sprint(header.Destination) != tc.dst {
+					t.Errorf("%s header expected %s %s, but got %+v", version, tc.src, tc.dst, header)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s header is not received", version)
+			}
+			conn.Close(types.NoFlush, types.LocalClose)
+		}
+	}
+}
@@ -21,8 +21,12 @@ import (
 	"context"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
@@ -38,6 +42,11 @@ type simpleHost struct {
 	tlsDisable    bool
 	weight        uint32
 	healthFlags   uint64
+	// lastHealthyTime is the unix nano time the host is added or becomes healthy
+	lastHealthyTime int64
+	// the consecutive failures reported, they are reset by a success
+	consecutive5xx            uint32
+	consecutiveConnectFailure uint32
 }
 
 func NewSimpleHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
@@ -51,10 +60,22 @@ func NewSimpleHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
 		stats:         newHostStats(clusterInfo.Name(), config.Address),
 		metaData:      config.MetaData,
 		tlsDisable:    config.TLSDisable,
-		weight:        config.Weight,
+		weight:        clampHostWeight(config.Weight),
 	}
 }
 
+// clampHostWeight keeps the weight in the range of [v2.MinHostWeight, v2.MaxHostWeight],
+// so the weights of the dynamic hosts are the same as the static config
+func clampHostWeight(weight uint32) uint32 {
+	if weight < v2.MinHostWeight {
+		return v2.MinHostWeight
+	}
+	if weight > v2.MaxHostWeight {
+		return v2.MaxHostWeight
+	}
+	return weight
+}
+
 // types.HostInfo Implement
 func (sh *simpleHost) Hostname() string {
 	return sh.hostname
@@ -105,9 +126,25 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 	var tlsMng types.TLSContextManager
 	if !sh.tlsDisable {
 		tlsMng = sh.clusterInfo.TLSMng()
+		// the host of the request is the server name, unless it is configured
+		if mng, ok := tlsMng.(*clusterTLSContextManager); ok && mng.Enabled() {
+			serverName, _ := mosnctx.Get(context, types.ContextKeyUpstreamServerName).(string)
+			tlsMng = &hostTLSContextManager{
+				TLSContextManager: mng.manager(serverName),
+				host:              sh,
+			}
+		}
 	}
 	clientConn := network.NewClientConnection(nil, sh.clusterInfo.ConnectTimeout(), tlsMng, sh.Address(), nil)
 	clientConn.SetBufferLimit(sh.clusterInfo.ConnBufferLimitBytes())
+	clientConn.SetTCPOptions(sh.clusterInfo.TCPOptions())
+	if low, high := sh.clusterInfo.WriteBufferWatermarks(); high > 0 {
+		clientConn.SetWriteBufferWatermarks(low, high)
+		clientConn.AddConnectionEventListener(&watermarkListener{stats: sh.clusterInfo.Stats()})
+	}
+	if version := sh.clusterInfo.ProxyProtocol(); version != "" {
+		clientConn.SetProxyProtocolHeader(proxyProtocolHeader(context, version))
+	}
 
 	return types.CreateConnectionData{
 		Connection: clientConn,
@@ -115,24 +152,120 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 	}
 }
 
+// proxyProtocolHeader encodes the addresses of the downstream connection in the context,
+// the header of a local connection is encoded if there is no downstream connection, such as the health checks
+func proxyProtocolHeader(ctx context.Context, version v2.ProxyProtocolVersion) []byte {
+	var src, dst net.Addr
+	if conn, ok := mosnctx.Get(ctx, types.ContextKeyConnection).(types.Connection); ok {
+		src, dst = conn.RemoteAddr(), conn.LocalAddr()
+	}
+	v := proxyprotocol.Version1
+	if version == v2.PROXY_PROTOCOL_V2 {
+		v = proxyprotocol.Version2
+	}
+	// the version is validated, so it never fails
+	header, _ := proxyprotocol.EncodeHeader(v, src, dst)
+	return header
+}
+
+// watermarkListener counts the write buffer watermarks crossed by an upstream connection in the cluster stats
+type watermarkListener struct {
+	stats types.ClusterStats
+	// backedUp is 1 if the connection is above its high watermark, accessed atomically
+	backedUp uint32
+}
+
+func (l *watermarkListener) OnEvent(event types.ConnectionEvent) {
+	switch {
+	case event == types.OnAboveWriteBufferHighWatermark:
+		if atomic.CompareAndSwapUint32(&l.backedUp, 0, 1) {
+			l.stats.UpstreamWriteBufferHighWatermarkTotal.Inc(1)
+			l.stats.UpstreamWriteBufferBackedUp.Inc(1)
+		}
+	case event == types.OnBelowWriteBufferLowWatermark:
+		if atomic.CompareAndSwapUint32(&l.backedUp, 1, 0) {
+			l.stats.UpstreamWriteBufferLowWatermarkTotal.Inc(1)
+			l.stats.UpstreamWriteBufferBackedUp.Dec(1)
+		}
+	case event.IsClose():
+		// the connection closed before it is drained is not backed up any more
+		if atomic.CompareAndSwapUint32(&l.backedUp, 1, 0) {
+			l.stats.UpstreamWriteBufferBackedUp.Dec(1)
+		}
+	}
+}
+
+// the health flags are changed by the health checker and the outlier detector concurrently
 func (sh *simpleHost) ClearHealthFlag(flag types.HealthFlag) {
-	sh.healthFlags &= ^uint64(flag)
+	for {
+		old := atomic.LoadUint64(&sh.healthFlags)
+		if atomic.CompareAndSwapUint64(&sh.healthFlags, old, old&^uint64(flag)) {
+			return
+		}
+	}
 }
 
 func (sh *simpleHost) ContainHealthFlag(flag types.HealthFlag) bool {
-	return sh.healthFlags&uint64(flag) > 0
+	return atomic.LoadUint64(&sh.healthFlags)&uint64(flag) > 0
 }
 
 func (sh *simpleHost) SetHealthFlag(flag types.HealthFlag) {
-	sh.healthFlags |= uint64(flag)
+	for {
+		old := atomic.LoadUint64(&sh.healthFlags)
+		if atomic.CompareAndSwapUint64(&sh.healthFlags, old, old|uint64(flag)) {
+			return
+		}
+	}
 }
 
 func (sh *simpleHost) HealthFlag() types.HealthFlag {
-	return types.HealthFlag(sh.healthFlags)
+	return types.HealthFlag(atomic.LoadUint64(&sh.healthFlags))
 }
 
 func (sh *simpleHost) Health() bool {
-	return sh.healthFlags == 0
+	return atomic.LoadUint64(&sh.healthFlags) == 0
+}
+
+func (sh *simpleHost) ReportResult(result types.HostResult) {
+	var consecutive, threshold uint32
+	config := sh.clusterInfo.OutlierDetection()
+	switch result {
+	case types.HostResultSuccess:
+		// avoid writing the counters on every success
+		if atomic.LoadUint32(&sh.consecutive5xx) != 0 {
+			atomic.StoreUint32(&sh.consecutive5xx, 0)
+		}
+		if atomic.LoadUint32(&sh.consecutiveConnectFailure) != 0 {
+			atomic.StoreUint32(&sh.consecutiveConnectFailure, 0)
+		}
+		return
+	case types.HostResult5xx, types.HostResultTimeout:
+		// the host is connected
+		if atomic.LoadUint32(&sh.consecutiveConnectFailure) != 0 {
+			atomic.StoreUint32(&sh.consecutiveConnectFailure, 0)
+		}
+		consecutive, threshold = atomic.AddUint32(&sh.consecutive5xx, 1), config.Consecutive5xx
+	case types.HostResultConnectFailure:
+		consecutive, threshold = atomic.AddUint32(&sh.consecutiveConnectFailure, 1), config.ConsecutiveConnectFailure
+	default:
+		return
+	}
+	if threshold == 0 || consecutive < threshold {
+		return
+	}
+	atomic.StoreUint32(&sh.consecutive5xx, 0)
+	atomic.StoreUint32(&sh.consecutiveConnectFailure, 0)
+	if detector := sh.clusterInfo.OutlierDetector(); detector != nil && detector.EjectHost(sh) {
+		log.DefaultLogger.Infof("[upstream] [host] host %s is ejected after %d consecutive failures", sh.addressString, consecutive)
+	}
+}
+
+func (sh *simpleHost) LastHealthyTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&sh.lastHealthyTime))
+}
+
+func (sh *simpleHost) SetLastHealthyTime(t time.Time) {
+	atomic.StoreInt64(&sh.lastHealthyTime, t.UnixNano())
 }
 
 // net.Addr reuse for same address, valid in simple type
@@ -18,11 +18,14 @@
 package cluster
 
 import (
+	"math"
 	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -34,6 +37,8 @@ func RegisterLBType(lbType types.LoadBalancerType, f func(types.HostSet) types.L
 		lbFactories = make(map[types.LoadBalancerType]func(types.HostSet) types.LoadBalancer)
 	}
 	lbFactories[lbType] = f
+	// the clusters using the registered type are valid
+	config.RegisterLbType(v2.LbType(lbType))
 }
 
 var rrFactory *roundRobinLoadBalancerFactory
@@ -44,6 +49,7 @@ func init() {
 	}
 	RegisterLBType(types.RoundRobin, rrFactory.newRoundRobinLoadBalancer)
 	RegisterLBType(types.Random, newRandomLoadBalancer)
+	RegisterLBType(types.RingHash, newRingHashLoadBalancer)
 }
 
 func NewLoadBalancer(lbType types.LoadBalancerType, hosts types.HostSet) types.LoadBalancer {
@@ -53,6 +59,31 @@ func NewLoadBalancer(lbType types.LoadBalancerType, hosts types.HostSet) types.L
 	return rrFactory.newRoundRobinLoadBalancer(hosts)
 }
 
+// slowStartRetry is the max times to choose another host if the chosen host is skipped in slow start
+const slowStartRetry = 3
+
+// slowStartFactor returns the weight factor of the host in (0, 1], the factor ramps up
+// from the min weight factor to 1 in the slow start window after the host becomes healthy
+func slowStartFactor(host types.Host) float64 {
+	info := host.ClusterInfo()
+	if info == nil {
+		return 1
+	}
+	config := info.SlowStart()
+	if config.Duration <= 0 {
+		return 1
+	}
+	elapsed := time.Since(host.LastHealthyTime())
+	if elapsed >= config.Duration {
+		return 1
+	}
+	factor := math.Pow(float64(elapsed)/float64(config.Duration), 1/config.Aggression)
+	if factor < config.MinWeightFactor {
+		factor = config.MinWeightFactor
+	}
+	return factor
+}
+
 // LoadBalancer Implementations
 
 type randomLoadBalancer struct {
@@ -75,8 +106,15 @@ func (lb *randomLoadBalancer) ChooseHost(context types.LoadBalancerContext) type
 	}
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
-	idx := lb.rand.Intn(len(targets))
-	return targets[idx]
+	var host types.Host
+	// the host in slow start is skipped by chance, so it receives less requests
+	for i := 0; i <= slowStartRetry; i++ {
+		host = targets[lb.rand.Intn(len(targets))]
+		if factor := slowStartFactor(host); factor >= 1 || lb.rand.Float64() < factor {
+			break
+		}
+	}
+	return host
 }
 
 func (lb *randomLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
@@ -87,9 +125,15 @@ func (lb *randomLoadBalancer) HostNum(metadata types.MetadataMatchCriteria) int
 	return len(lb.hosts.Hosts())
 }
 
+// roundRobinLoadBalancer chooses the hosts in turn, the hosts with different weights
+// are chosen by the smooth weighted round robin, the same as nginx
 type roundRobinLoadBalancer struct {
 	hosts   types.HostSet
 	rrIndex uint32
+	// weights is nil if the hosts have the same weight
+	mutex          sync.Mutex
+	weights        []int64
+	currentWeights []int64
 }
 
 type roundRobinLoadBalancerFactory struct {
@@ -105,13 +149,49 @@ func (f *roundRobinLoadBalancerFactory) newRoundRobinLoadBalancer(hosts types.Ho
 	if len(hostsList) != 0 {
 		idx = f.rand.Uint32() % uint32(len(hostsList))
 	}
-	return &roundRobinLoadBalancer{
+	lb := &roundRobinLoadBalancer{
 		hosts:   hosts,
 		rrIndex: idx,
 	}
+	for _, host := range hostsList {
+		if hostWeight(host) != hostWeight(hostsList[0]) {
+			lb.weights = make([]int64, len(hostsList))
+			lb.currentWeights = make([]int64, len(hostsList))
+			for i, h := range hostsList {
+				lb.weights[i] = int64(hostWeight(h))
+			}
+			break
+		}
+	}
+	return lb
+}
+
+// hostWeight returns the weight of the host, a missing weight is 1
+func hostWeight(host types.Host) uint64 {
+	if w := host.Weight(); w > 0 {
+		return uint64(w)
+	}
+	return 1
 }
 
 func (lb *roundRobinLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	var host types.Host
+	for i := 0; i <= slowStartRetry; i++ {
+		host = lb.next()
+		if host == nil {
+			return nil
+		}
+		if factor := slowStartFactor(host); factor >= 1 || rand.Float64() < factor {
+			break
+		}
+	}
+	return host
+}
+
+func (lb *roundRobinLoadBalancer) next() types.Host {
+	if lb.weights != nil {
+		return lb.nextWeighted()
+	}
 	targets := lb.hosts.HealthyHosts()
 	if len(targets) == 0 {
 		return nil
@@ -120,6 +200,31 @@ func (lb *roundRobinLoadBalancer) ChooseHost(context types.LoadBalancerContext)
 	return targets[index]
 }
 
+// nextWeighted increases the current weight of each healthy host by its weight, and chooses the host
+// with the max current weight, whose current weight is decreased by the total weight then.
+func (lb *roundRobinLoadBalancer) nextWeighted() types.Host {
+	hosts := lb.hosts.Hosts()
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	var total int64
+	best := -1
+	for i, host := range hosts {
+		if !host.Health() {
+			continue
+		}
+		lb.currentWeights[i] += lb.weights[i]
+		total += lb.weights[i]
+		if best < 0 || lb.currentWeights[i] > lb.currentWeights[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	lb.currentWeights[best] -= total
+	return hosts[best]
+}
+
 func (lb *roundRobinLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
 	return len(lb.hosts.Hosts()) > 0
 }
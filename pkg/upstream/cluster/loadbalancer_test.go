@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"math"
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newWeightedHosts(info types.ClusterInfo, weights map[string]uint32, meta map[string]v2.Metadata) []types.Host {
+	var hosts []types.Host
+	for addr, weight := range weights {
+		hosts = append(hosts, NewSimpleHost(v2.Host{
+			HostConfig: v2.HostConfig{Address: addr, Weight: weight},
+			MetaData:   meta[addr],
+		}, info))
+	}
+	return hosts
+}
+
+// checkDistribution chooses the hosts and checks each host's share matches its weight within 1%
+func checkDistribution(t *testing.T, lb types.LoadBalancer, ctx types.LoadBalancerContext, expected map[string]uint32) {
+	var total uint32
+	for _, w := range expected {
+		total += w
+	}
+	count := map[string]int{}
+	n := int(total) * 100
+	for i := 0; i < n; i++ {
+		count[lb.ChooseHost(ctx).AddressString()]++
+	}
+	for addr, c := range count {
+		if _, ok := expected[addr]; !ok {
+			t.Errorf("unexpected host %s is chosen %d times", addr, c)
+		}
+	}
+	for addr, w := range expected {
+		share, expectedShare := float64(count[addr])/float64(n), float64(w)/float64(total)
+		if math.Abs(share-expectedShare) > 0.01 {
+			t.Errorf("host %s expected share %.4f, but got %.4f", addr, expectedShare, share)
+		}
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:   "test_weighted_round_robin",
+		LbType: v2.LB_ROUNDROBIN,
+	})
+	hosts := newWeightedHosts(cluster.info, map[string]uint32{
+		"127.0.0.1:10000": 5,
+		"127.0.0.1:10001": 100,
+		"127.0.0.1:10002": 100,
+		"127.0.0.1:10003": 0,
+		"127.0.0.1:10004": 1000,
+	}, nil)
+	cluster.UpdateHosts(hosts)
+	// the weights are clamped to [1, 128]
+	checkDistribution(t, cluster.Snapshot().LoadBalancer(), nil, map[string]uint32{
+		"127.0.0.1:10000": 5,
+		"127.0.0.1:10001": 100,
+		"127.0.0.1:10002": 100,
+		"127.0.0.1:10003": 1,
+		"127.0.0.1:10004": 128,
+	})
+	// the unhealthy host is skipped
+	for _, host := range hosts {
+		if host.AddressString() == "127.0.0.1:10004" {
+			cluster.EjectHost(host)
+		}
+	}
+	checkDistribution(t, cluster.Snapshot().LoadBalancer(), nil, map[string]uint32{
+		"127.0.0.1:10000": 5,
+		"127.0.0.1:10001": 100,
+		"127.0.0.1:10002": 100,
+		"127.0.0.1:10003": 1,
+	})
+	// the weights are updated
+	cluster.UpdateHosts(newWeightedHosts(cluster.info, map[string]uint32{
+		"127.0.0.1:10000": 100,
+		"127.0.0.1:10001": 5,
+		"127.0.0.1:10002": 5,
+	}, nil))
+	checkDistribution(t, cluster.Snapshot().LoadBalancer(), nil, map[string]uint32{
+		"127.0.0.1:10000": 100,
+		"127.0.0.1:10001": 5,
+		"127.0.0.1:10002": 5,
+	})
+}
+
+func TestWeightedRoundRobinSmooth(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:   "test_weighted_round_robin_smooth",
+		LbType: v2.LB_ROUNDROBIN,
+	})
+	cluster.UpdateHosts(newWeightedHosts(cluster.info, map[string]uint32{
+		"127.0.0.1:10000": 5,
+		"127.0.0.1:10001": 1,
+		"127.0.0.1:10002": 1,
+	}, nil))
+	lb := cluster.Snapshot().LoadBalancer()
+	// the smooth sequence is "a a b a c a a", so the heavy host is chosen at most 4 times in a row across the cycles,
+	// instead of 5 times in a row by choosing each host weight times
+	heavy := 0
+	for i := 0; i < 70; i++ {
+		if lb.ChooseHost(nil).AddressString() != "127.0.0.1:10000" {
+			heavy = 0
+			continue
+		}
+		if heavy++; heavy > 4 {
+			t.Fatal("the weighted round robin is not smooth")
+		}
+	}
+}
+
+func TestWeightedSubsetLoadBalancer(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:   "test_weighted_subset",
+		LbType: v2.LB_ROUNDROBIN,
+		LBSubSetConfig: v2.LBSubsetConfig{
+			SubsetSelectors: [][]string{{"version"}},
+		},
+	})
+	cluster.UpdateHosts(newWeightedHosts(cluster.info, map[string]uint32{
+		"127.0.0.1:10000": 10,
+		"127.0.0.1:10001": 30,
+		"127.0.0.1:10002": 100,
+	}, map[string]v2.Metadata{
+		"127.0.0.1:10000": {"version": "1"},
+		"127.0.0.1:10001": {"version": "1"},
+		"127.0.0.1:10002": {"version": "2"},
+	}))
+	checkDistribution(t, cluster.Snapshot().LoadBalancer(), newMockLbContext(map[string]string{"version": "1"}), map[string]uint32{
+		"127.0.0.1:10000": 10,
+		"127.0.0.1:10001": 30,
+	})
+}
@@ -32,6 +32,7 @@ type mockHost struct {
 	addr       string
 	meta       v2.Metadata
 	healthFlag uint64
+	weight     uint32
 	types.Host
 }
 
@@ -47,6 +48,14 @@ func (h *mockHost) Metadata() v2.Metadata {
 	return h.meta
 }
 
+func (h *mockHost) Weight() uint32 {
+	return h.weight
+}
+
+func (h *mockHost) ClusterInfo() types.ClusterInfo {
+	return nil
+}
+
 func (h *mockHost) Health() bool {
 	return h.healthFlag == 0
 }
@@ -101,7 +110,8 @@ func makePool(size int) *ipPool {
 }
 
 type mockConnPool struct {
-	h types.Host
+	h      types.Host
+	closed bool
 	types.ConnectionPool
 }
 
@@ -122,6 +132,30 @@ func (p *mockConnPool) SupportTLS() bool {
 func (p *mockConnPool) Shutdown() {
 }
 
+func (p *mockConnPool) Close() {
+	p.closed = true
+}
+
+// the mock pool has one connection identified by its host address
+func (p *mockConnPool) Snapshot() types.ConnPoolSnapshot {
+	return types.ConnPoolSnapshot{
+		Protocol:     mockProtocol,
+		Host:         p.h.AddressString(),
+		TotalClients: 1,
+		Clients: []types.UpstreamConnectionState{
+			{ID: p.h.AddressString(), Host: p.h.AddressString()},
+		},
+	}
+}
+
+func (p *mockConnPool) CloseConnection(id string, graceful bool) (bool, bool) {
+	if id != p.h.AddressString() {
+		return false, false
+	}
+	p.closed = true
+	return true, false
+}
+
 func init() {
 	network.RegisterNewPoolFactory(mockProtocol, func(h types.Host) types.ConnectionPool {
 		return &mockConnPool{
@@ -133,8 +167,10 @@ func init() {
 
 type mockLbContext struct {
 	types.LoadBalancerContext
-	mmc    types.MetadataMatchCriteria
-	header types.HeaderMap
+	mmc     types.MetadataMatchCriteria
+	header  types.HeaderMap
+	hashKey *uint64
+	ctx     context.Context
 }
 
 func newMockLbContext(m map[string]string) types.LoadBalancerContext {
@@ -161,5 +197,12 @@ func (ctx *mockLbContext) DownstreamHeaders() types.HeaderMap {
 }
 
 func (ctx *mockLbContext) DownstreamContext() context.Context {
-	return nil
+	return ctx.ctx
+}
+
+func (ctx *mockLbContext) HashKey() (uint64, bool) {
+	if ctx.hashKey == nil {
+		return 0, false
+	}
+	return *ctx.hashKey, true
 }
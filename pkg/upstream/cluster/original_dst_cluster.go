@@ -0,0 +1,227 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// DefaultOriginalDstIdleTimeout is how long an unused destination host is kept if it is not configured
+const DefaultOriginalDstIdleTimeout = 5 * time.Second
+
+var (
+	errInvalidDestinationIP   = errors.New("destination host is not an ip")
+	errInvalidDestinationPort = errors.New("invalid destination port")
+)
+
+// originalDstCluster forwards the requests to the original destination of the downstream connection.
+// The hosts are created for the destinations instead of configured, and they are removed
+// after they are not used for the idle timeout, so the connection pools of the hot destinations are reused
+type originalDstCluster struct {
+	*simpleCluster
+	lb            *originalDstLoadBalancer
+	useHTTPHeader bool
+	idleTimeout   time.Duration
+	// hosts are the destination hosts, keyed by the address
+	hosts    map[string]*originalDstHost
+	hostsMux sync.RWMutex
+	// hostRemovedCb is called after an idle host is removed, so its connection pools are closed
+	hostRemovedCb func(host types.Host)
+}
+
+type originalDstHost struct {
+	types.Host
+	// lastUsed is the unix nano time the host is chosen
+	lastUsed int64
+}
+
+func newOriginalDstCluster(clusterConfig v2.Cluster) *originalDstCluster {
+	cluster := &originalDstCluster{
+		simpleCluster: newSimpleCluster(clusterConfig),
+		idleTimeout:   DefaultOriginalDstIdleTimeout,
+		hosts:         make(map[string]*originalDstHost),
+	}
+	if lbConfig := clusterConfig.OriginalDstLbConfig; lbConfig != nil {
+		cluster.useHTTPHeader = lbConfig.UseHTTPHeader
+		if lbConfig.IdleTimeout != nil && lbConfig.IdleTimeout.Duration > 0 {
+			cluster.idleTimeout = lbConfig.IdleTimeout.Duration
+		}
+	}
+	cluster.lb = &originalDstLoadBalancer{cluster: cluster}
+	cluster.storeSnapshot()
+	return cluster
+}
+
+// UpdateHosts ignores the hosts, the hosts of the original dst cluster are created for the destinations
+func (c *originalDstCluster) UpdateHosts(hosts []types.Host) {
+	if len(hosts) > 0 {
+		log.DefaultLogger.Warnf("[upstream] [original dst cluster] cluster %s ignores %d hosts", c.info.name, len(hosts))
+	}
+}
+
+// storeSnapshot stores a snapshot with the destination hosts, the caller should hold the hostsMux
+func (c *originalDstCluster) storeSnapshot() {
+	hosts := make([]types.Host, 0, len(c.hosts))
+	for _, h := range c.hosts {
+		hosts = append(hosts, h.Host)
+	}
+	hostSet := &hostSet{}
+	hostSet.setFinalHost(hosts)
+	c.snapshot.Store(&clusterSnapshot{
+		info:    c.info,
+		hostSet: hostSet,
+		lb:      c.lb,
+	})
+}
+
+// destination returns the original destination address of the downstream request, it is empty if not found.
+// The downstream connection's original destination is used if the http header is not found, but not if it is invalid
+func (c *originalDstCluster) destination(lbCtx types.LoadBalancerContext) string {
+	if c.useHTTPHeader {
+		if headers := lbCtx.DownstreamHeaders(); headers != nil {
+			if dst, ok := headers.Get(types.HeaderOriginalDstHost); ok && dst != "" {
+				addr, err := parseDestination(dst)
+				if err != nil {
+					log.DefaultLogger.Errorf("[upstream] [original dst cluster] cluster %s invalid destination %s: %v", c.info.name, dst, err)
+				}
+				return addr
+			}
+		}
+	}
+	if ctx := lbCtx.DownstreamContext(); ctx != nil {
+		if addr, ok := mosnctx.Get(ctx, types.ContextKeyOriginalDst).(net.Addr); ok {
+			return addr.String()
+		}
+	}
+	return ""
+}
+
+// parseDestination checks the destination is an ip:port one, the domain is not resolved
+func parseDestination(dst string) (string, error) {
+	host, port, err := net.SplitHostPort(dst)
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", errInvalidDestinationIP
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return "", errInvalidDestinationPort
+	}
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// getOrCreateHost returns the host of the destination, a new host is created if the destination is not used recently
+func (c *originalDstCluster) getOrCreateHost(addr string) types.Host {
+	now := time.Now().UnixNano()
+	c.hostsMux.RLock()
+	h, ok := c.hosts[addr]
+	c.hostsMux.RUnlock()
+	if !ok {
+		c.hostsMux.Lock()
+		if h, ok = c.hosts[addr]; !ok {
+			h = &originalDstHost{
+				Host:     NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: addr}}, c.info),
+				lastUsed: now,
+			}
+			c.hosts[addr] = h
+			c.storeSnapshot()
+			utils.NewTimer(c.idleTimeout, func() {
+				c.removeIdleHost(addr)
+			})
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[upstream] [original dst cluster] cluster %s add host %s", c.info.name, addr)
+			}
+		}
+		c.hostsMux.Unlock()
+	}
+	atomic.StoreInt64(&h.lastUsed, now)
+	return h.Host
+}
+
+// removeIdleHost removes the host if it is not used for the idle timeout, otherwise it is checked again later
+func (c *originalDstCluster) removeIdleHost(addr string) {
+	c.hostsMux.Lock()
+	h, ok := c.hosts[addr]
+	if !ok {
+		c.hostsMux.Unlock()
+		return
+	}
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&h.lastUsed)))
+	// the host with active requests is not idle
+	if idle < c.idleTimeout || h.HostStats().UpstreamRequestActive.Count() > 0 {
+		c.hostsMux.Unlock()
+		next := c.idleTimeout - idle
+		if next <= 0 {
+			next = c.idleTimeout
+		}
+		utils.NewTimer(next, func() {
+			c.removeIdleHost(addr)
+		})
+		return
+	}
+	delete(c.hosts, addr)
+	c.storeSnapshot()
+	cb := c.hostRemovedCb
+	c.hostsMux.Unlock()
+	// the resolved address is not kept for the destinations no longer used
+	AddrStore.Delete(addr)
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[upstream] [original dst cluster] cluster %s remove idle host %s", c.info.name, addr)
+	}
+	if cb != nil {
+		cb(h.Host)
+	}
+}
+
+// originalDstLoadBalancer ignores the cluster's hosts, and chooses the host of the downstream request's original destination
+type originalDstLoadBalancer struct {
+	cluster *originalDstCluster
+}
+
+func (lb *originalDstLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	if context == nil {
+		return nil
+	}
+	addr := lb.cluster.destination(context)
+	if addr == "" {
+		return nil
+	}
+	return lb.cluster.getOrCreateHost(addr)
+}
+
+// IsExistsHosts is always true, the host is created for the destination
+func (lb *originalDstLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return true
+}
+
+// HostNum is always 1, there is only one host for the destination
+func (lb *originalDstLoadBalancer) HostNum(metadata types.MetadataMatchCriteria) int {
+	return 1
+}
@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newOriginalDstLbContext(dst string, header types.HeaderMap) types.LoadBalancerContext {
+	ctx := &mockLbContext{
+		header: header,
+		ctx:    context.Background(),
+	}
+	if dst != "" {
+		addr, _ := net.ResolveTCPAddr("tcp", dst)
+		ctx.ctx = mosnctx.WithValue(ctx.ctx, types.ContextKeyOriginalDst, addr)
+	}
+	return ctx
+}
+
+func TestOriginalDstCluster(t *testing.T) {
+	c := NewCluster(v2.Cluster{
+		Name:        "original_dst",
+		ClusterType: v2.ORIGINAL_DST_CLUSTER,
+		OriginalDstLbConfig: &v2.OriginalDstLbConfig{
+			IdleTimeout: &v2.DurationConfig{Duration: 50 * time.Millisecond},
+		},
+	}).(*originalDstCluster)
+	removed := make(chan types.Host, 1)
+	c.hostRemovedCb = func(host types.Host) {
+		removed <- host
+	}
+	// the configured hosts are ignored
+	c.UpdateHosts([]types.Host{&mockHost{addr: "127.0.0.1:80"}})
+	snap := c.Snapshot()
+	if snap.LoadBalancer().ChooseHost(newOriginalDstLbContext("", nil)) != nil {
+		t.Fatal("no host should be chosen without the original destination")
+	}
+	host := snap.LoadBalancer().ChooseHost(newOriginalDstLbContext("10.0.0.1:8080", nil))
+	if host == nil || host.AddressString() != "10.0.0.1:8080" {
+		t.Fatalf("expected the host of the original destination, but got %v", host)
+	}
+	// the host is reused for the destination
+	if c.Snapshot().LoadBalancer().ChooseHost(newOriginalDstLbContext("10.0.0.1:8080", nil)) != host {
+		t.Fatal("the host of the destination should be reused")
+	}
+	if hosts := c.Snapshot().HostSet().Hosts(); len(hosts) != 1 || hosts[0] != host {
+		t.Fatalf("expected the destination host in the snapshot, but got %v", hosts)
+	}
+	select {
+	case h := <-removed:
+		if h != host {
+			t.Fatalf("unexpected removed host %v", h)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("idle host should be removed")
+	}
+	if hosts := c.Snapshot().HostSet().Hosts(); len(hosts) != 0 {
+		t.Fatalf("expected no hosts after the idle host is removed, but got %v", hosts)
+	}
+}
+
+func TestOriginalDstClusterHTTPHeader(t *testing.T) {
+	c := NewCluster(v2.Cluster{
+		Name:        "original_dst_header",
+		ClusterType: v2.ORIGINAL_DST_CLUSTER,
+		OriginalDstLbConfig: &v2.OriginalDstLbConfig{
+			UseHTTPHeader: true,
+		},
+	})
+	lb := c.Snapshot().LoadBalancer()
+	testCases := []struct {
+		dst      string
+		header   types.HeaderMap
+		expected string
+	}{
+		{"10.0.0.1:8080", protocol.CommonHeader{types.HeaderOriginalDstHost: "10.0.0.2:9090"}, "10.0.0.2:9090"},
+		// the connection's original destination is used without the header
+		{"10.0.0.1:8080", protocol.CommonHeader{}, "10.0.0.1:8080"},
+		{"10.0.0.1:8080", nil, "10.0.0.1:8080"},
+		// the domain is not resolved
+		{"10.0.0.1:8080", protocol.CommonHeader{types.HeaderOriginalDstHost: "mosn.io:80"}, ""},
+		{"", protocol.CommonHeader{types.HeaderOriginalDstHost: "10.0.0.2:0"}, ""},
+		{"", protocol.CommonHeader{types.HeaderOriginalDstHost: "[::1]:80"}, "[::1]:80"},
+	}
+	for i, tc := range testCases {
+		host := lb.ChooseHost(newOriginalDstLbContext(tc.dst, tc.header))
+		if tc.expected == "" {
+			if host != nil {
+				t.Errorf("#%d expected no host, but got %s", i, host.AddressString())
+			}
+			continue
+		}
+		if host == nil || host.AddressString() != tc.expected {
+			t.Errorf("#%d expected host %s, but got %v", i, tc.expected, host)
+		}
+	}
+}
+
+func TestOriginalDstClusterManager(t *testing.T) {
+	clusterMangerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{Name: "original_dst_cm", ClusterType: v2.ORIGINAL_DST_CLUSTER},
+	}, map[string][]v2.Host{
+		"original_dst_cm": nil,
+	})
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "original_dst_cm")
+	pool := GetClusterMngAdapterInstance().ConnPoolForCluster(newOriginalDstLbContext("10.0.0.1:8080", nil), snap, mockProtocol)
+	if pool == nil || pool.(*mockConnPool).h.AddressString() != "10.0.0.1:8080" {
+		t.Fatalf("expected the conn pool of the original destination, but got %v", pool)
+	}
+	if err := GetClusterMngAdapterInstance().TriggerClusterHostUpdate("original_dst_cm", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:80"}},
+	}); err == nil {
+		t.Fatal("the hosts of original dst cluster should not be updated")
+	}
+}
@@ -19,8 +19,11 @@ package cluster
 
 import (
 	"sync/atomic"
+	"time"
 
+	gometrics "github.com/rcrowley/go-metrics"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -30,44 +33,129 @@ const (
 	DefaultMaxPendingRequests = uint64(10240)
 	DefaultMaxRequests        = uint64(10240)
 	DefaultMaxRetries         = uint64(3)
+
+	DefaultRetryBudgetPercent        = uint64(20)
+	DefaultRetryBudgetMinConcurrency = uint64(3)
+
+	DefaultConnectBackoffBase = time.Second
+	DefaultConnectBackoffMax  = 30 * time.Second
 )
 
 // ResourceManager
 type resourcemanager struct {
+	priority        types.ResourcePriority
 	connections     *resource
 	pendingRequests *resource
 	requests        *resource
-	retries         *resource
+	retries         *retryResource
 }
 
+// NewResourceManager creates the resource manager of the default priority, the state is not reported
 func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManager {
+	return newResourceManager(circuitBreakers, types.DefaultPriority, nil)
+}
+
+// newResourceManagers creates a resource manager for each priority,
+// the state of the circuit breakers is reported to the cluster's stats
+func newResourceManagers(clusterName string, circuitBreakers v2.CircuitBreakers) []*resourcemanager {
+	stats := metrics.NewClusterStats(clusterName)
+	rms := make([]*resourcemanager, types.NumResourcePriorities)
+	for p := range rms {
+		rms[p] = newResourceManager(circuitBreakers, types.ResourcePriority(p), stats)
+	}
+	return rms
+}
+
+func newResourceManager(circuitBreakers v2.CircuitBreakers, priority types.ResourcePriority, stats types.Metrics) *resourcemanager {
+	requests := newResource(stats, priority, metrics.CircuitBreakersRequestsOpen, metrics.CircuitBreakersRemainingRequests)
+	rm := &resourcemanager{
+		priority:        priority,
+		connections:     newResource(stats, priority, metrics.CircuitBreakersConnectionsOpen, metrics.CircuitBreakersRemainingConnections),
+		pendingRequests: newResource(stats, priority, metrics.CircuitBreakersPendingOpen, metrics.CircuitBreakersRemainingPending),
+		requests:        requests,
+		retries: &retryResource{
+			resource: *newResource(stats, priority, metrics.CircuitBreakersRetriesOpen, metrics.CircuitBreakersRemainingRetries),
+			requests: requests,
+		},
+	}
+	rm.updateThresholds(circuitBreakers)
+	return rm
+}
+
+// thresholdsByPriority returns the first thresholds of the priority, or nil if the priority is not configured
+func thresholdsByPriority(circuitBreakers v2.CircuitBreakers, priority types.ResourcePriority) *v2.Thresholds {
+	for i := range circuitBreakers.Thresholds {
+		p := types.DefaultPriority
+		if circuitBreakers.Thresholds[i].Priority == v2.HIGH_PRIORITY {
+			p = types.HighPriority
+		}
+		if p == priority {
+			return &circuitBreakers.Thresholds[i]
+		}
+	}
+	return nil
+}
+
+// updateThresholds swaps the max value of the resources, the current value is kept
+func (rm *resourcemanager) updateThresholds(circuitBreakers v2.CircuitBreakers) {
 	maxConnections := DefaultMaxConnections
 	maxPendingRequests := DefaultMaxPendingRequests
 	maxRequests := DefaultMaxRequests
 	maxRetries := DefaultMaxRetries
+	// the retry budget is disabled by default
+	budgetPercent, minRetryConcurrency := uint64(0), uint64(0)
 
-	// note: we don't support group cb by priority
-	if circuitBreakers.Thresholds != nil && len(circuitBreakers.Thresholds) > 0 {
-		maxConnections = uint64(circuitBreakers.Thresholds[0].MaxConnections)
-		maxPendingRequests = uint64(circuitBreakers.Thresholds[0].MaxPendingRequests)
-		maxRequests = uint64(circuitBreakers.Thresholds[0].MaxRequests)
-		maxRetries = uint64(circuitBreakers.Thresholds[0].MaxRetries)
+	// the priority without thresholds uses the default values
+	if threshold := thresholdsByPriority(circuitBreakers, rm.priority); threshold != nil {
+		maxConnections = uint64(threshold.MaxConnections)
+		maxPendingRequests = uint64(threshold.MaxPendingRequests)
+		maxRequests = uint64(threshold.MaxRequests)
+		maxRetries = uint64(threshold.MaxRetries)
+		if rb := threshold.RetryBudget; rb != nil {
+			budgetPercent, minRetryConcurrency = DefaultRetryBudgetPercent, DefaultRetryBudgetMinConcurrency
+			if rb.BudgetPercent > 0 {
+				budgetPercent = uint64(rb.BudgetPercent)
+			}
+			if rb.MinRetryConcurrency > 0 {
+				minRetryConcurrency = uint64(rb.MinRetryConcurrency)
+			}
+		}
 	}
 
-	return &resourcemanager{
-		connections: &resource{
-			max: maxConnections,
-		},
-		pendingRequests: &resource{
-			max: maxPendingRequests,
-		},
-		requests: &resource{
-			max: maxRequests,
-		},
-		retries: &resource{
-			max: maxRetries,
-		},
+	atomic.StoreUint64(&rm.connections.max, maxConnections)
+	atomic.StoreUint64(&rm.pendingRequests.max, maxPendingRequests)
+	atomic.StoreUint64(&rm.requests.max, maxRequests)
+	atomic.StoreUint64(&rm.retries.max, maxRetries)
+	atomic.StoreUint64(&rm.retries.budgetPercent, budgetPercent)
+	atomic.StoreUint64(&rm.retries.minConcurrency, minRetryConcurrency)
+
+	rm.connections.report(rm.connections.Max())
+	rm.pendingRequests.report(rm.pendingRequests.Max())
+	rm.requests.report(rm.requests.Max())
+	rm.retries.report(rm.retries.Max())
+}
+
+// newConnectBackoffConfig creates the connect failure backoff config, the backoff is disabled
+// if the circuit breakers do not set max connect failures
+func newConnectBackoffConfig(circuitBreakers v2.CircuitBreakers) types.ConnectBackoffConfig {
+	cfg := types.ConnectBackoffConfig{
+		BaseInterval: DefaultConnectBackoffBase,
+		MaxInterval:  DefaultConnectBackoffMax,
+	}
+	// the backoff is the connection's, so only the default priority's thresholds are used
+	if threshold := thresholdsByPriority(circuitBreakers, types.DefaultPriority); threshold != nil {
+		cfg.MaxFailures = threshold.MaxConnectFailures
+		if threshold.ConnectBackoffBase != nil && threshold.ConnectBackoffBase.Duration > 0 {
+			cfg.BaseInterval = threshold.ConnectBackoffBase.Duration
+		}
+		if threshold.ConnectBackoffMax != nil && threshold.ConnectBackoffMax.Duration > 0 {
+			cfg.MaxInterval = threshold.ConnectBackoffMax.Duration
+		}
+	}
+	if cfg.MaxInterval < cfg.BaseInterval {
+		cfg.MaxInterval = cfg.BaseInterval
 	}
+	return cfg
 }
 
 func (rm *resourcemanager) Connections() types.Resource {
@@ -90,6 +178,20 @@ func (rm *resourcemanager) Retries() types.Resource {
 type resource struct {
 	current int64
 	max     uint64
+	// open is 1 if the resource is exhausted, remaining is the count left before it is exhausted,
+	// they are nil if the state is not reported
+	open      gometrics.Gauge
+	remaining gometrics.Gauge
+}
+
+func newResource(stats types.Metrics, priority types.ResourcePriority, openKey, remainingKey string) *resource {
+	r := &resource{}
+	if stats != nil {
+		prefix := metrics.UpstreamCircuitBreakersPrefix + priority.String() + "."
+		r.open = stats.Gauge(prefix + openKey)
+		r.remaining = stats.Gauge(prefix + remainingKey)
+	}
+	return r
 }
 
 func (r *resource) CanCreate() bool {
@@ -104,12 +206,73 @@ func (r *resource) CanCreate() bool {
 
 func (r *resource) Increase() {
 	atomic.AddInt64(&r.current, 1)
+	r.report(r.Max())
 }
 
 func (r *resource) Decrease() {
 	atomic.AddInt64(&r.current, -1)
+	r.report(r.Max())
 }
 
 func (r *resource) Max() uint64 {
-	return r.max
+	return atomic.LoadUint64(&r.max)
+}
+
+// report updates the state of the resource by the max value
+func (r *resource) report(max uint64) {
+	if r.open == nil {
+		return
+	}
+	remaining := int64(max) - atomic.LoadInt64(&r.current)
+	if remaining > 0 {
+		r.open.Update(0)
+		r.remaining.Update(remaining)
+	} else {
+		r.open.Update(1)
+		r.remaining.Update(0)
+	}
+}
+
+// retryResource limits the retries by the max retries,
+// or by the percent of the active requests if the retry budget is configured.
+// the state is reported when the retries are changed, the budget changed by the requests is not reported
+type retryResource struct {
+	resource
+	requests       *resource
+	budgetPercent  uint64
+	minConcurrency uint64
+}
+
+func (r *retryResource) CanCreate() bool {
+	curValue := atomic.LoadInt64(&r.current)
+
+	if curValue < 0 {
+		return true
+	}
+
+	return uint64(curValue) < r.Max()
+}
+
+func (r *retryResource) Increase() {
+	atomic.AddInt64(&r.current, 1)
+	r.report(r.Max())
+}
+
+func (r *retryResource) Decrease() {
+	atomic.AddInt64(&r.current, -1)
+	r.report(r.Max())
+}
+
+func (r *retryResource) Max() uint64 {
+	percent := atomic.LoadUint64(&r.budgetPercent)
+	if percent == 0 {
+		return r.resource.Max()
+	}
+	max := atomic.LoadUint64(&r.minConcurrency)
+	if requests := atomic.LoadInt64(&r.requests.current); requests > 0 {
+		if budget := uint64(requests) * percent / 100; budget > max {
+			max = budget
+		}
+	}
+	return max
 }
@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestRetryBudget(t *testing.T) {
+	// the max retries is used by default
+	rm := NewResourceManager(v2.CircuitBreakers{})
+	if rm.Retries().Max() != DefaultMaxRetries {
+		t.Fatalf("unexpected max retries: %d", rm.Retries().Max())
+	}
+	rm = NewResourceManager(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{
+			MaxRequests: 1000,
+			MaxRetries:  1,
+			RetryBudget: &v2.RetryBudget{BudgetPercent: 10},
+		}},
+	})
+	retries, requests := rm.Retries(), rm.Requests()
+	// the min retry concurrency is allowed without requests
+	if retries.Max() != DefaultRetryBudgetMinConcurrency {
+		t.Fatalf("unexpected max retries: %d", retries.Max())
+	}
+	for i := 0; i < 100; i++ {
+		requests.Increase()
+	}
+	if retries.Max() != 10 {
+		t.Fatalf("unexpected max retries: %d", retries.Max())
+	}
+	for i := 0; i < 10; i++ {
+		if !retries.CanCreate() {
+			t.Fatalf("retry %d should be in the budget", i)
+		}
+		retries.Increase()
+	}
+	if retries.CanCreate() {
+		t.Fatal("the retry budget should be exhausted")
+	}
+	// the budget is removed by an update
+	rm.(*resourcemanager).updateThresholds(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{MaxRetries: 20}},
+	})
+	if retries.Max() != 20 || !retries.CanCreate() {
+		t.Fatalf("unexpected max retries: %d", retries.Max())
+	}
+}
+
+func TestResourceManagerPriority(t *testing.T) {
+	cb := v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{MaxConnections: 10, MaxRequests: 2, MaxRetries: 1},
+			{Priority: v2.HIGH_PRIORITY, MaxConnections: 20, MaxRequests: 4, MaxRetries: 2},
+		},
+	}
+	clusterName := "test_resource_manager_priority"
+	info := &clusterInfo{
+		resourceManagers: newResourceManagers(clusterName, cb),
+	}
+	def, high := info.ResourceManager(), info.ResourceManagerByPriority(types.HighPriority)
+	if def != info.ResourceManagerByPriority(types.DefaultPriority) || def == high {
+		t.Fatal("each priority should have a resource manager")
+	}
+	if info.ResourceManagerByPriority(types.ResourcePriority(10)) != def {
+		t.Fatal("unknown priority should use the default resource manager")
+	}
+	if def.Connections().Max() != 10 || def.Requests().Max() != 2 || def.Retries().Max() != 1 ||
+		high.Connections().Max() != 20 || high.Requests().Max() != 4 || high.Retries().Max() != 2 {
+		t.Fatal("unexpected thresholds")
+	}
+	// the priorities are independent
+	def.Requests().Increase()
+	def.Requests().Increase()
+	if def.Requests().CanCreate() || !high.Requests().CanCreate() {
+		t.Fatal("the default priority should be open only")
+	}
+	stats := metrics.NewClusterStats(clusterName)
+	gauge := func(priority types.ResourcePriority, key string) int64 {
+		return stats.Gauge(metrics.UpstreamCircuitBreakersPrefix + priority.String() + "." + key).Value()
+	}
+	if gauge(types.DefaultPriority, metrics.CircuitBreakersRequestsOpen) != 1 ||
+		gauge(types.DefaultPriority, metrics.CircuitBreakersRemainingRequests) != 0 ||
+		gauge(types.HighPriority, metrics.CircuitBreakersRequestsOpen) != 0 ||
+		gauge(types.HighPriority, metrics.CircuitBreakersRemainingRequests) != 4 ||
+		gauge(types.HighPriority, metrics.CircuitBreakersRemainingConnections) != 20 {
+		t.Fatal("unexpected circuit breakers state")
+	}
+	def.Requests().Decrease()
+	if gauge(types.DefaultPriority, metrics.CircuitBreakersRequestsOpen) != 0 ||
+		gauge(types.DefaultPriority, metrics.CircuitBreakersRemainingRequests) != 1 {
+		t.Fatal("unexpected circuit breakers state after decrease")
+	}
+	// the priority without thresholds uses the default values
+	sc := &simpleCluster{info: info}
+	sc.UpdateCircuitBreakers(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{MaxConnections: 10, MaxRequests: 1}},
+	})
+	if high.Requests().Max() != DefaultMaxRequests || def.Requests().Max() != 1 {
+		t.Fatalf("unexpected thresholds after update, default: %d, high: %d", def.Requests().Max(), high.Requests().Max())
+	}
+	if gauge(types.DefaultPriority, metrics.CircuitBreakersRequestsOpen) != 1 ||
+		gauge(types.HighPriority, metrics.CircuitBreakersRemainingRequests) != int64(DefaultMaxRequests) {
+		t.Fatal("unexpected circuit breakers state after update")
+	}
+}
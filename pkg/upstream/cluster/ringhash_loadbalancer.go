@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// ringHashLoadBalancer chooses the host by the hash key of the request on a consistent hash ring,
+// so the requests with the same key are sent to the same host as long as it is healthy
+type ringHashLoadBalancer struct {
+	hosts types.HostSet
+	ring  []ringEntry
+}
+
+type ringEntry struct {
+	hash uint64
+	host types.Host
+}
+
+func newRingHashLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	hostsList := hosts.Hosts()
+	// the hosts in a host set belong to the same cluster
+	config := types.RingHashConfig{
+		MinimumRingSize: types.DefaultRingHashMinimumSize,
+		MaximumRingSize: types.DefaultRingHashMaximumSize,
+	}
+	if len(hostsList) > 0 && hostsList[0].ClusterInfo() != nil {
+		config = hostsList[0].ClusterInfo().RingHashConfig()
+	}
+	return &ringHashLoadBalancer{
+		hosts: hosts,
+		ring:  buildHashRing(hostsList, config),
+	}
+}
+
+// buildHashRing puts the virtual nodes of the hosts on the ring, the nodes of a host are proportional to its weight.
+// The nodes per weight are rounded up to a power of two, so they are kept when a few hosts are added or removed,
+// and only the keys of the changed hosts are remapped.
+func buildHashRing(hosts []types.Host, config types.RingHashConfig) []ringEntry {
+	var totalWeight uint64
+	for _, host := range hosts {
+		totalWeight += hostWeight(host)
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	nodesPerWeight := uint64(1)
+	for nodesPerWeight*totalWeight < config.MinimumRingSize {
+		nodesPerWeight <<= 1
+	}
+	if config.MaximumRingSize > 0 && nodesPerWeight*totalWeight > config.MaximumRingSize {
+		nodesPerWeight = config.MaximumRingSize / totalWeight
+		if nodesPerWeight == 0 {
+			nodesPerWeight = 1
+		}
+	}
+	ring := make([]ringEntry, 0, nodesPerWeight*totalWeight)
+	for _, host := range hosts {
+		nodes := nodesPerWeight * hostWeight(host)
+		for i := uint64(0); i < nodes; i++ {
+			ring = append(ring, ringEntry{
+				hash: hashRingNode(host.AddressString(), i),
+				host: host,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	return ring
+}
+
+func hashRingNode(address string, index uint64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(address))
+	h.Write([]byte("_"))
+	h.Write([]byte(strconv.FormatUint(index, 10)))
+	// the fnv hashes of the similar addresses are close, mix the bits to spread the nodes on the ring
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func (lb *ringHashLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	if len(lb.ring) == 0 || len(lb.hosts.HealthyHosts()) == 0 {
+		return nil
+	}
+	var key uint64
+	var ok bool
+	if context != nil {
+		key, ok = context.HashKey()
+	}
+	if !ok {
+		key = rand.Uint64()
+	}
+	// the first node clockwise from the key, the unhealthy hosts are skipped
+	idx := sort.Search(len(lb.ring), func(i int) bool {
+		return lb.ring[i].hash >= key
+	})
+	for i := 0; i < len(lb.ring); i++ {
+		entry := lb.ring[(idx+i)%len(lb.ring)]
+		if entry.host.Health() {
+			return entry.host
+		}
+	}
+	return nil
+}
+
+func (lb *ringHashLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return len(lb.hosts.Hosts()) > 0
+}
+
+func (lb *ringHashLoadBalancer) HostNum(metadata types.MetadataMatchCriteria) int {
+	return len(lb.hosts.Hosts())
+}
@@ -0,0 +1,170 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"math/rand"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newRingHashTestLB(hosts []types.Host) types.LoadBalancer {
+	hs := &hostSet{}
+	hs.setFinalHost(hosts)
+	return NewLoadBalancer(types.RingHash, hs)
+}
+
+func chooseByKey(lb types.LoadBalancer, key uint64) types.Host {
+	return lb.ChooseHost(&mockLbContext{
+		hashKey: &key,
+	})
+}
+
+func ringHashTestKeys(n int) []uint64 {
+	r := rand.New(rand.NewSource(1))
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = r.Uint64()
+	}
+	return keys
+}
+
+func TestRingHashChooseHost(t *testing.T) {
+	hosts := makePool(10).MakeHosts(10, nil)
+	lb := newRingHashTestLB(hosts)
+	keys := ringHashTestKeys(10000)
+	counts := map[types.Host]int{}
+	for _, key := range keys {
+		host := chooseByKey(lb, key)
+		if host == nil {
+			t.Fatal("no host is chosen")
+		}
+		if chooseByKey(lb, key) != host {
+			t.Fatalf("key %d is not sticky", key)
+		}
+		counts[host]++
+	}
+	// the keys are balanced, each host gets 10% of the keys
+	for _, host := range hosts {
+		if counts[host] < 500 || counts[host] > 1500 {
+			t.Errorf("host %s gets %d keys of 10000", host.AddressString(), counts[host])
+		}
+	}
+	// no hash key, a host is chosen randomly
+	if lb.ChooseHost(&mockLbContext{}) == nil || lb.ChooseHost(nil) == nil {
+		t.Error("no host is chosen without the hash key")
+	}
+	// no hosts
+	if newRingHashTestLB(nil).ChooseHost(&mockLbContext{}) != nil {
+		t.Error("a host is chosen from the empty hosts")
+	}
+}
+
+func TestRingHashHostSetChanged(t *testing.T) {
+	pool := makePool(11)
+	hosts := pool.MakeHosts(11, nil)
+	lb := newRingHashTestLB(hosts[:10])
+	keys := ringHashTestKeys(10000)
+	chosen := make([]types.Host, len(keys))
+	for i, key := range keys {
+		chosen[i] = chooseByKey(lb, key)
+	}
+	// a host is added, only the keys moved to the new host are remapped
+	added := newRingHashTestLB(hosts)
+	moved := 0
+	for i, key := range keys {
+		if host := chooseByKey(added, key); host != chosen[i] {
+			if host != hosts[10] {
+				t.Fatalf("key %d is moved from %s to %s", key, chosen[i].AddressString(), host.AddressString())
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 2000 {
+		t.Errorf("%d keys of 10000 are moved to the new host", moved)
+	}
+	// a host is removed, only the keys of the removed host are remapped
+	removed := newRingHashTestLB(hosts[1:10])
+	for i, key := range keys {
+		if host := chooseByKey(removed, key); host != chosen[i] && chosen[i] != hosts[0] {
+			t.Fatalf("key %d is moved from %s to %s", key, chosen[i].AddressString(), host.AddressString())
+		}
+	}
+}
+
+func TestRingHashUnhealthyHost(t *testing.T) {
+	hosts := makePool(5).MakeHosts(5, nil)
+	lb := newRingHashTestLB(hosts)
+	keys := ringHashTestKeys(1000)
+	chosen := make([]types.Host, len(keys))
+	for i, key := range keys {
+		chosen[i] = chooseByKey(lb, key)
+	}
+	hosts[0].SetHealthFlag(types.FAILED_ACTIVE_HC)
+	for i, key := range keys {
+		host := chooseByKey(lb, key)
+		if host == hosts[0] {
+			t.Fatal("the unhealthy host is chosen")
+		}
+		if chosen[i] != hosts[0] && host != chosen[i] {
+			t.Fatalf("key %d of the healthy host is moved", key)
+		}
+	}
+	hosts[0].ClearHealthFlag(types.FAILED_ACTIVE_HC)
+	for i, key := range keys {
+		if chooseByKey(lb, key) != chosen[i] {
+			t.Fatalf("key %d is not sticky after the host is healthy again", key)
+		}
+	}
+}
+
+func TestBuildHashRing(t *testing.T) {
+	hosts := makePool(3).MakeHosts(3, nil)
+	hosts[2].(*mockHost).weight = 2
+	for _, tc := range []struct {
+		config   types.RingHashConfig
+		expected int
+	}{
+		// 4 weights, 256 nodes per weight
+		{types.RingHashConfig{MinimumRingSize: 1024, MaximumRingSize: 8192}, 1024},
+		// 512 nodes per weight
+		{types.RingHashConfig{MinimumRingSize: 1025, MaximumRingSize: 8192}, 2048},
+		// capped by the max size, 250 nodes per weight
+		{types.RingHashConfig{MinimumRingSize: 1025, MaximumRingSize: 1000}, 1000},
+		// at least 1 node per weight
+		{types.RingHashConfig{MinimumRingSize: 1, MaximumRingSize: 2}, 4},
+	} {
+		ring := buildHashRing(hosts, tc.config)
+		if len(ring) != tc.expected {
+			t.Errorf("config %+v, expected %d nodes, but got %d", tc.config, tc.expected, len(ring))
+			continue
+		}
+		// the nodes are proportional to the weight
+		nodes := map[types.Host]int{}
+		for i, entry := range ring {
+			if i > 0 && ring[i-1].hash > entry.hash {
+				t.Fatal("the ring is not sorted")
+			}
+			nodes[entry.host]++
+		}
+		if nodes[hosts[0]] != nodes[hosts[1]] || nodes[hosts[2]] != 2*nodes[hosts[0]] {
+			t.Errorf("config %+v, the nodes are not weighted: %d, %d, %d", tc.config, nodes[hosts[0]], nodes[hosts[1]], nodes[hosts[2]])
+		}
+	}
+}
@@ -35,6 +35,10 @@ func newHostStats(clustername string, addr string) types.HostStats {
 		UpstreamConnectionLocalCloseWithActiveRequest:  s.Counter(metrics.UpstreamConnectionLocalCloseWithActiveRequest),
 		UpstreamConnectionRemoteCloseWithActiveRequest: s.Counter(metrics.UpstreamConnectionRemoteCloseWithActiveRequest),
 		UpstreamConnectionCloseNotify:                  s.Counter(metrics.UpstreamConnectionCloseNotify),
+		UpstreamConnectionPerDownstreamTotal:           s.Counter(metrics.UpstreamConnectionPerDownstreamTotal),
+		UpstreamConnectionPerDownstreamActive:          s.Counter(metrics.UpstreamConnectionPerDownstreamActive),
+		UpstreamConnectionConnectDurationMs:            s.Histogram(metrics.UpstreamConnectionConnectDurationMs),
+		UpstreamConnectionBackoff:                      s.Gauge(metrics.UpstreamConnectionBackoff),
 		UpstreamRequestTotal:                           s.Counter(metrics.UpstreamRequestTotal),
 		UpstreamRequestActive:                          s.Counter(metrics.UpstreamRequestActive),
 		UpstreamRequestLocalReset:                      s.Counter(metrics.UpstreamRequestLocalReset),
@@ -42,13 +46,43 @@ func newHostStats(clustername string, addr string) types.HostStats {
 		UpstreamRequestTimeout:                         s.Counter(metrics.UpstreamRequestTimeout),
 		UpstreamRequestFailureEject:                    s.Counter(metrics.UpstreamRequestFailureEject),
 		UpstreamRequestPendingOverflow:                 s.Counter(metrics.UpstreamRequestPendingOverflow),
-		UpstreamRequestDuration:                        s.Histogram(metrics.UpstreamRequestDuration),
-		UpstreamRequestDurationTotal:                   s.Counter(metrics.UpstreamRequestDurationTotal),
+		UpstreamKeepAliveEject:                         s.Counter(metrics.UpstreamKeepAliveEject),
+		UpstreamKeepAliveSuccessTotal:                  s.Counter(metrics.UpstreamKeepAliveSuccessTotal),
+		UpstreamKeepAliveTimeoutTotal:                  s.Counter(metrics.UpstreamKeepAliveTimeoutTotal),
+		UpstreamKeepAliveClosedConnectionTotal:         s.Counter(metrics.UpstreamKeepAliveClosedConnectionTotal),
+		UpstreamKeepAliveOutstanding:                   s.Counter(metrics.UpstreamKeepAliveOutstanding),
+		UpstreamRequestDuration:                        s.Timer(metrics.UpstreamRequestDuration),
 		UpstreamResponseSuccess:                        s.Counter(metrics.UpstreamResponseSuccess),
 		UpstreamResponseFailed:                         s.Counter(metrics.UpstreamResponseFailed),
+		HealthCheckAttempt:                             s.Counter(metrics.HostHealthCheckAttempt),
+		HealthCheckSuccess:                             s.Counter(metrics.HostHealthCheckSuccess),
+		HealthCheckFailure:                             s.Counter(metrics.HostHealthCheckFailure),
 	}
 }
 
+// deleteRemovedHostsStats deletes the stats of the hosts removed from the cluster,
+// the draining hosts' stats are deleted after they are drained
+func deleteRemovedHostsStats(clustername string, oldHosts, newHosts []types.Host) {
+	removed := make(map[string]struct{}, len(oldHosts))
+	for _, h := range oldHosts {
+		if !h.ContainHealthFlag(types.DRAINING) {
+			removed[h.AddressString()] = struct{}{}
+		}
+	}
+	for _, h := range newHosts {
+		delete(removed, h.AddressString())
+	}
+	for addr := range removed {
+		metrics.DeleteHostStats(clustername, addr)
+	}
+}
+
+// deleteClusterStats deletes the stats of the removed cluster and its hosts
+func deleteClusterStats(clustername string, hosts []types.Host) {
+	deleteRemovedHostsStats(clustername, hosts, nil)
+	metrics.DeleteClusterStats(clustername)
+}
+
 func newClusterStats(clustername string) types.ClusterStats {
 	s := metrics.NewClusterStats(clustername)
 	return types.ClusterStats{
@@ -62,6 +96,9 @@ func newClusterStats(clustername string) types.ClusterStats {
 		UpstreamConnectionLocalCloseWithActiveRequest:  s.Counter(metrics.UpstreamConnectionLocalCloseWithActiveRequest),
 		UpstreamConnectionRemoteCloseWithActiveRequest: s.Counter(metrics.UpstreamConnectionRemoteCloseWithActiveRequest),
 		UpstreamConnectionCloseNotify:                  s.Counter(metrics.UpstreamConnectionCloseNotify),
+		UpstreamConnectionPerDownstreamTotal:           s.Counter(metrics.UpstreamConnectionPerDownstreamTotal),
+		UpstreamConnectionPerDownstreamActive:          s.Counter(metrics.UpstreamConnectionPerDownstreamActive),
+		UpstreamConnectionConnectDurationMs:            s.Histogram(metrics.UpstreamConnectionConnectDurationMs),
 		UpstreamBytesReadTotal:                         s.Counter(metrics.UpstreamBytesReadTotal),
 		UpstreamBytesWriteTotal:                        s.Counter(metrics.UpstreamBytesWriteTotal),
 		UpstreamRequestTotal:                           s.Counter(metrics.UpstreamRequestTotal),
@@ -73,10 +110,41 @@ func newClusterStats(clustername string) types.ClusterStats {
 		UpstreamRequestTimeout:                         s.Counter(metrics.UpstreamRequestTimeout),
 		UpstreamRequestFailureEject:                    s.Counter(metrics.UpstreamRequestFailureEject),
 		UpstreamRequestPendingOverflow:                 s.Counter(metrics.UpstreamRequestPendingOverflow),
-		UpstreamRequestDuration:                        s.Histogram(metrics.UpstreamRequestDuration),
-		UpstreamRequestDurationTotal:                   s.Counter(metrics.UpstreamRequestDurationTotal),
+		UpstreamKeepAliveEject:                         s.Counter(metrics.UpstreamKeepAliveEject),
+		UpstreamKeepAliveSuccessTotal:                  s.Counter(metrics.UpstreamKeepAliveSuccessTotal),
+		UpstreamKeepAliveTimeoutTotal:                  s.Counter(metrics.UpstreamKeepAliveTimeoutTotal),
+		UpstreamKeepAliveClosedConnectionTotal:         s.Counter(metrics.UpstreamKeepAliveClosedConnectionTotal),
+		UpstreamKeepAliveOutstanding:                   s.Counter(metrics.UpstreamKeepAliveOutstanding),
+		UpstreamRequestDuration:                        s.Timer(metrics.UpstreamRequestDuration),
 		UpstreamResponseSuccess:                        s.Counter(metrics.UpstreamResponseSuccess),
 		UpstreamResponseFailed:                         s.Counter(metrics.UpstreamResponseFailed),
+		UpstreamResponseCode2xx:                        s.Counter(metrics.UpstreamResponseCode2xx),
+		UpstreamResponseCode3xx:                        s.Counter(metrics.UpstreamResponseCode3xx),
+		UpstreamResponseCode4xx:                        s.Counter(metrics.UpstreamResponseCode4xx),
+		UpstreamResponseCode5xx:                        s.Counter(metrics.UpstreamResponseCode5xx),
+		UpstreamResponseGrpcOK:                         s.Counter(metrics.UpstreamResponseGrpcOK),
+		UpstreamResponseGrpc4xx:                        s.Counter(metrics.UpstreamResponseGrpc4xx),
+		UpstreamResponseGrpc5xx:                        s.Counter(metrics.UpstreamResponseGrpc5xx),
+		UpstreamTLSHandshakeFail:                       s.Counter(metrics.UpstreamTLSHandshakeFail),
+		UpstreamHostSlowStart:                          s.Counter(metrics.UpstreamHostSlowStart),
+		UpstreamOutlierEjectionsTotal:                  s.Counter(metrics.UpstreamOutlierEjectionsTotal),
+		UpstreamOutlierEjectionsActive:                 s.Counter(metrics.UpstreamOutlierEjectionsActive),
+		UpstreamHostDraining:                           s.Gauge(metrics.UpstreamHostDraining),
+		UpstreamUDPSessionTotal:                        s.Counter(metrics.UpstreamUDPSessionTotal),
+		UpstreamUDPSessionActive:                       s.Counter(metrics.UpstreamUDPSessionActive),
+		UpstreamUDPSessionIdleTimeout:                  s.Counter(metrics.UpstreamUDPSessionIdleTimeout),
+		UpstreamUDPDatagramsSent:                       s.Counter(metrics.UpstreamUDPDatagramsSent),
+		UpstreamUDPDatagramsReceived:                   s.Counter(metrics.UpstreamUDPDatagramsReceived),
+		UpstreamUDPDatagramsDropped:                    s.Counter(metrics.UpstreamUDPDatagramsDropped),
+		UpstreamTCPProxyDownstreamBytes:                s.Counter(metrics.UpstreamTCPProxyDownstreamBytes),
+		UpstreamTCPProxyUpstreamBytes:                  s.Counter(metrics.UpstreamTCPProxyUpstreamBytes),
+		UpstreamTCPProxyIdleTimeout:                    s.Counter(metrics.UpstreamTCPProxyIdleTimeout),
+		UpstreamTCPProxyConnectionDurationMs:           s.Histogram(metrics.UpstreamTCPProxyConnectionDurationMs),
+		UpstreamWriteBufferHighWatermarkTotal:          s.Counter(metrics.UpstreamWriteBufferHighWatermarkTotal),
+		UpstreamWriteBufferLowWatermarkTotal:           s.Counter(metrics.UpstreamWriteBufferLowWatermarkTotal),
+		UpstreamWriteBufferBackedUp:                    s.Counter(metrics.UpstreamWriteBufferBackedUp),
+		UpstreamWriteBufferHighWatermark:               s.Gauge(metrics.UpstreamWriteBufferHighWatermark),
+		UpstreamWriteBufferLowWatermark:                s.Gauge(metrics.UpstreamWriteBufferLowWatermark),
 		LBSubSetsFallBack:                              s.Counter(metrics.UpstreamLBSubSetsFallBack),
 		LBSubsetsCreated:                               s.Gauge(metrics.UpstreamLBSubsetsCreated),
 	}
@@ -65,8 +65,12 @@ func (sslb *subsetLoadBalancer) ChooseHost(ctx types.LoadBalancerContext) types.
 		log.DefaultLogger.Errorf("[upstream] [subset lb] subset load balancer: failure, fallback subset is nil")
 		return nil
 	}
-	sslb.stats.LBSubSetsFallBack.Inc(1)
-	return sslb.fallbackSubset.LoadBalancer().ChooseHost(ctx)
+	host := sslb.fallbackSubset.LoadBalancer().ChooseHost(ctx)
+	// only counts the fallback that chooses a host
+	if host != nil {
+		sslb.stats.LBSubSetsFallBack.Inc(1)
+	}
+	return host
 }
 
 func (sslb *subsetLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
@@ -83,10 +87,14 @@ func (sslb *subsetLoadBalancer) HostNum(metadata types.MetadataMatchCriteria) in
 	if metadata != nil && !reflect.ValueOf(metadata).IsNil() {
 		matchCriteria := metadata.MetadataMatchCriteria()
 		entry := sslb.findSubset(matchCriteria)
-		if entry == nil {
-			return 0
+		if entry != nil && entry.Active() {
+			return entry.HostNum()
+		}
+		// the host is chosen from the fallback subset if no subset matches
+		if sslb.fallbackSubset != nil {
+			return sslb.fallbackSubset.HostNum()
 		}
-		return entry.HostNum()
+		return 0
 	}
 	return len(sslb.hostSet.Hosts())
 }
@@ -292,9 +300,22 @@ func (info *LBSubsetInfoImpl) SubsetKeys() []types.SortedStringSetType {
 	return info.subSetKeys
 }
 
+// fallbackPolicy returns the named fall back policy if it is configured
+func fallbackPolicy(subsetCfg *v2.LBSubsetConfig) types.FallBackPolicy {
+	switch subsetCfg.FallbackPolicy {
+	case v2.NO_FALLBACK:
+		return types.NoFallBack
+	case v2.ANY_ENDPOINT:
+		return types.AnyEndPoint
+	case v2.DEFAULT_SUBSET:
+		return types.DefaultSubset
+	}
+	return types.FallBackPolicy(subsetCfg.FallBackPolicy)
+}
+
 func NewLBSubsetInfo(subsetCfg *v2.LBSubsetConfig) types.LBSubsetInfo {
 	lbSubsetInfo := &LBSubsetInfoImpl{
-		fallbackPolicy: types.FallBackPolicy(subsetCfg.FallBackPolicy),
+		fallbackPolicy: fallbackPolicy(subsetCfg),
 		subSetKeys:     GenerateSubsetKeys(subsetCfg.SubsetSelectors),
 		defaultSubSet:  make(types.SubsetMetadata, 0, len(subsetCfg.DefaultSubset)),
 		enabled:        len(subsetCfg.SubsetSelectors) != 0,
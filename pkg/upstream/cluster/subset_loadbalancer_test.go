@@ -393,6 +393,64 @@ func TestFallbackWithDefaultSubset(t *testing.T) {
 	}
 }
 
+// TestFallbackPolicyByName configures the named fallback policy, the fallback stats is
+// only counted if the fallback chooses a host
+func TestFallbackPolicyByName(t *testing.T) {
+	testCases := []struct {
+		policy        v2.SubsetFallbackPolicy
+		defaultSubset map[string]string
+		hostNum       int
+		expectedHost  string
+	}{
+		{policy: v2.NO_FALLBACK, hostNum: 0},
+		{policy: v2.ANY_ENDPOINT, hostNum: 7},
+		{policy: v2.DEFAULT_SUBSET, defaultSubset: map[string]string{"stage": "dev"}, hostNum: 1, expectedHost: "e7"},
+		{policy: v2.DEFAULT_SUBSET, defaultSubset: map[string]string{"stage": "unknown"}, hostNum: 0},
+	}
+	for i, tc := range testCases {
+		cfg := &v2.LBSubsetConfig{
+			FallBackPolicy: uint8(types.AnyEndPoint), // overridden by the named policy
+			FallbackPolicy: tc.policy,
+			DefaultSubset:  tc.defaultSubset,
+			SubsetSelectors: [][]string{
+				[]string{
+					"version", "xlarge",
+				},
+			},
+		}
+		stats := newClusterStats(fmt.Sprintf("TestFallbackPolicyByName%d", i))
+		lb := newSubsetLoadBalancer(types.RoundRobin, createHostset(exampleHostConfigs()), stats, NewLBSubsetInfo(cfg))
+		// matches the subset, no fallback
+		matched := newMockLbContext(map[string]string{
+			"version": "1.0",
+			"xlarge":  "true",
+		})
+		if h := lb.ChooseHost(matched); h == nil || h.Hostname() != "e1" || stats.LBSubSetsFallBack.Count() != 0 {
+			t.Fatalf("#%d choose host from the matched subset failed, host: %v, fallback: %d", i, h, stats.LBSubSetsFallBack.Count())
+		}
+		ctx := newMockLbContext(map[string]string{
+			"version": "1.2",
+			"xlarge":  "true",
+		})
+		if n := lb.HostNum(ctx.MetadataMatchCriteria()); n != tc.hostNum {
+			t.Fatalf("#%d expected %d hosts, but got %d", i, tc.hostNum, n)
+		}
+		h := lb.ChooseHost(ctx)
+		var expectedFallback int64
+		if tc.hostNum > 0 {
+			expectedFallback = 1
+			if h == nil || (tc.expectedHost != "" && h.Hostname() != tc.expectedHost) {
+				t.Fatalf("#%d choose host is not expected, expected %s, got %v", i, tc.expectedHost, h)
+			}
+		} else if h != nil {
+			t.Fatalf("#%d expected no host, but got %s", i, h.Hostname())
+		}
+		if stats.LBSubSetsFallBack.Count() != expectedFallback {
+			t.Fatalf("#%d expected fallback %d, but got %d", i, expectedFallback, stats.LBSubSetsFallBack.Count())
+		}
+	}
+}
+
 // TestFallbackWithAllHosts configure all hosts as fallback, without default subset
 func TestFallbackWithAllHosts(t *testing.T) {
 	// fallback policy is any point
@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/mtls"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// maxTLSServerNames is the max server names of a cluster whose tls contexts are cached,
+// the connections with the other server names use the configured tls context
+const maxTLSServerNames = 128
+
+// clusterTLSManagers stores the tls context managers by the cluster name, the updated cluster
+// shares the manager with the hosts created before the update, so the certificates are reloaded
+// for the new connections, and the established connections are not affected
+var clusterTLSManagers sync.Map
+
+// clusterTLSContextManager is the tls context manager of a cluster, the tls contexts are cached by the server name
+type clusterTLSContextManager struct {
+	mutex  sync.RWMutex
+	config v2.TLSConfig
+	// the empty server name is the configured one
+	managers map[string]types.TLSContextManager
+}
+
+// updateClusterTLSContextManager returns the tls context manager of the cluster,
+// the tls contexts are rebuilt if the tls config is changed, the previous ones are kept if the config is invalid
+func updateClusterTLSContextManager(clusterConfig *v2.Cluster) (*clusterTLSContextManager, error) {
+	value, _ := clusterTLSManagers.LoadOrStore(clusterConfig.Name, &clusterTLSContextManager{})
+	mng := value.(*clusterTLSContextManager)
+	return mng, mng.update(&clusterConfig.TLS)
+}
+
+func removeClusterTLSContextManager(clusterName string) {
+	clusterTLSManagers.Delete(clusterName)
+}
+
+func (mng *clusterTLSContextManager) update(cfg *v2.TLSConfig) error {
+	mng.mutex.Lock()
+	defer mng.mutex.Unlock()
+	if mng.managers != nil && reflect.DeepEqual(&mng.config, cfg) {
+		return nil
+	}
+	m, err := mtls.NewTLSClientContextManager(cfg)
+	if err != nil {
+		return err
+	}
+	mng.config = *cfg
+	mng.managers = map[string]types.TLSContextManager{
+		"": m,
+	}
+	return nil
+}
+
+// manager returns the tls context manager of the server name, the server name in the config takes precedence
+func (mng *clusterTLSContextManager) manager(serverName string) types.TLSContextManager {
+	_, m := mng.serverNameManager(serverName)
+	return m
+}
+
+// serverNameManager returns the server name whose tls context is used and its manager,
+// the server name is empty if the configured tls context is used
+func (mng *clusterTLSContextManager) serverNameManager(serverName string) (string, types.TLSContextManager) {
+	mng.mutex.RLock()
+	m, ok := mng.managers[serverName]
+	configured := mng.managers[""]
+	// the sds certificates are shared by the server names
+	fixed := mng.config.ServerName != "" || mng.config.SdsConfig != nil
+	mng.mutex.RUnlock()
+	if ok {
+		return serverName, m
+	}
+	if serverName == "" || fixed || configured == nil || !configured.Enabled() {
+		return "", configured
+	}
+
+	mng.mutex.Lock()
+	defer mng.mutex.Unlock()
+	if m, ok := mng.managers[serverName]; ok {
+		return serverName, m
+	}
+	if len(mng.managers) >= maxTLSServerNames {
+		return "", configured
+	}
+	cfg := mng.config
+	cfg.ServerName = serverName
+	m, err := mtls.NewTLSClientContextManager(&cfg)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [cluster] create tls context manager of server name %s failed: %v", serverName, err)
+		return "", configured
+	}
+	mng.managers[serverName] = m
+	return serverName, m
+}
+
+// upstreamServerName returns the server name of the tls connections to the host made for the context,
+// it is empty if the connections use the configured tls context
+func upstreamServerName(ctx context.Context, host types.Host) string {
+	if ctx == nil || !host.SupportTLS() {
+		return ""
+	}
+	mng, ok := host.ClusterInfo().TLSMng().(*clusterTLSContextManager)
+	if !ok {
+		return ""
+	}
+	serverName, _ := mosnctx.Get(ctx, types.ContextKeyUpstreamServerName).(string)
+	if serverName == "" {
+		return ""
+	}
+	serverName, _ = mng.serverNameManager(serverName)
+	return serverName
+}
+
+func (mng *clusterTLSContextManager) Conn(c net.Conn) (net.Conn, error) {
+	if m := mng.manager(""); m != nil {
+		return m.Conn(c)
+	}
+	return c, nil
+}
+
+func (mng *clusterTLSContextManager) Enabled() bool {
+	m := mng.manager("")
+	return m != nil && m.Enabled()
+}
+
+// hostTLSContextManager makes the tls connections to a host, the handshake is finished before the
+// connection is connected, so a handshake failure is a connection failure
+type hostTLSContextManager struct {
+	types.TLSContextManager
+	host types.Host
+}
+
+func (mng *hostTLSContextManager) Conn(c net.Conn) (net.Conn, error) {
+	conn, err := mng.TLSContextManager.Conn(c)
+	if err != nil {
+		return c, err
+	}
+	tlsConn, ok := conn.(interface {
+		Handshake() error
+	})
+	if !ok {
+		return conn, nil
+	}
+	info := mng.host.ClusterInfo()
+	conn.SetDeadline(time.Now().Add(info.ConnectTimeout()))
+	if err := tlsConn.Handshake(); err != nil {
+		info.Stats().UpstreamTLSHandshakeFail.Inc(1)
+		log.DefaultLogger.Alertf(types.ErrorKeyUpstreamConn, "[upstream] [cluster] tls handshake with %s failed: %v", mng.host.AddressString(), err)
+		return conn, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
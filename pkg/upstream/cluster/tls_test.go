@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	gotls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/mtls/certtool"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func signTestCertificate(t *testing.T, cn string, dns []string) *certtool.CertificateInfo {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := certtool.CreateTemplate(cn, false, dns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := certtool.SignCertificate(tmpl, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// startTLSEchoServer starts a tls server verifying the client certificates signed by the root ca
+func startTLSEchoServer(t *testing.T, cert *certtool.CertificateInfo) (addr string, stop func()) {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM([]byte(certtool.GetRootCA().CertPem))
+	keyPair, err := gotls.X509KeyPair([]byte(cert.CertPem), []byte(cert.KeyPem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := gotls.Listen("tcp", "127.0.0.1:0", &gotls.Config{
+		Certificates: []gotls.Certificate{keyPair},
+		ClientAuth:   gotls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() {
+		ln.Close()
+	}
+}
+
+func TestClusterTLSConnection(t *testing.T) {
+	addr, stop := startTLSEchoServer(t, signTestCertificate(t, "server", []string{"mosn.test"}))
+	defer stop()
+
+	client := signTestCertificate(t, "client", nil)
+	clusterConfig := v2.Cluster{
+		Name:   "tls_cluster",
+		LbType: v2.LB_RANDOM,
+		TLS: v2.TLSConfig{
+			Status:     true,
+			CACert:     certtool.GetRootCA().CertPem,
+			CertChain:  client.CertPem,
+			PrivateKey: client.KeyPem,
+		},
+	}
+	defer removeClusterTLSContextManager(clusterConfig.Name)
+	info := NewCluster(clusterConfig).Snapshot().ClusterInfo()
+	host := NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: addr,
+		},
+	}, info)
+	connect := func(serverName string) (types.ClientConnection, error) {
+		ctx := mosnctx.WithValue(context.Background(), types.ContextKeyUpstreamServerName, serverName)
+		conn := host.CreateConnection(ctx).Connection
+		return conn, conn.Connect()
+	}
+	handshakeFail := info.Stats().UpstreamTLSHandshakeFail
+	failed := handshakeFail.Count()
+
+	// the host of the request is the server name
+	conn, err := connect("mosn.test")
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer conn.Close(types.NoFlush, types.LocalClose)
+	// the server certificate does not match the server name
+	if _, err := connect("other.test"); err == nil {
+		t.Fatal("expected the handshake failed")
+	}
+	if count := handshakeFail.Count() - failed; count != 1 {
+		t.Fatalf("expected 1 handshake failure, but got %d", count)
+	}
+
+	// the hosts created before the update use the updated config,
+	// the configured server name takes precedence
+	clusterConfig.TLS.ServerName = "other.test"
+	NewCluster(clusterConfig)
+	if _, err := connect("mosn.test"); err == nil {
+		t.Fatal("expected the handshake failed by the updated server name")
+	}
+	if count := handshakeFail.Count() - failed; count != 2 {
+		t.Fatalf("expected 2 handshake failures, but got %d", count)
+	}
+	// the established connection is not affected
+	if err := conn.Write(buffer.NewIoBufferString("ping")); err != nil {
+		t.Errorf("the established connection should be kept, but got %v", err)
+	}
+}
+
+func TestClusterTLSContextManagerUpdate(t *testing.T) {
+	clusterConfig := v2.Cluster{
+		Name:   "tls_update_cluster",
+		LbType: v2.LB_RANDOM,
+		TLS: v2.TLSConfig{
+			Status:       true,
+			InsecureSkip: true,
+		},
+	}
+	defer removeClusterTLSContextManager(clusterConfig.Name)
+	mng, err := updateClusterTLSContextManager(&clusterConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configured := mng.manager("")
+	if !mng.Enabled() {
+		t.Fatal("expected the tls enabled")
+	}
+	// the tls contexts are cached by the server name
+	named := mng.manager("mosn.test")
+	if named == configured || mng.manager("mosn.test") != named {
+		t.Error("expected the tls context of the server name cached")
+	}
+	// the cached tls contexts are bounded
+	for i := 0; i < maxTLSServerNames; i++ {
+		mng.manager(fmt.Sprintf("%d.mosn.test", i))
+	}
+	if n := len(mng.managers); n != maxTLSServerNames {
+		t.Errorf("expected %d tls contexts cached, but got %d", maxTLSServerNames, n)
+	}
+	if serverName, m := mng.serverNameManager("full.mosn.test"); serverName != "" || m != configured {
+		t.Error("expected the configured tls context used if the cache is full")
+	}
+	// no changes
+	if m, _ := updateClusterTLSContextManager(&clusterConfig); m != mng || m.manager("") != configured {
+		t.Error("expected the tls context kept")
+	}
+	// the invalid config keeps the previous tls context
+	invalid := clusterConfig
+	invalid.TLS.CertChain = "invalid"
+	invalid.TLS.PrivateKey = "invalid"
+	if _, err := updateClusterTLSContextManager(&invalid); err == nil {
+		t.Error("expected the invalid config failed")
+	}
+	if mng.manager("") != configured {
+		t.Error("expected the previous tls context kept")
+	}
+	// disabled
+	disabled := clusterConfig
+	disabled.TLS = v2.TLSConfig{}
+	if _, err := updateClusterTLSContextManager(&disabled); err != nil || mng.Enabled() {
+		t.Errorf("expected the tls disabled, error: %v", err)
+	}
+	if mng.manager("mosn.test") != mng.manager("") {
+		t.Error("expected no tls context for the server name if the tls is disabled")
+	}
+}
@@ -19,6 +19,7 @@ package healthcheck
 
 import (
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -26,6 +27,7 @@ var sessionFactories map[types.Protocol]types.HealthCheckSessionFactory
 
 func init() {
 	sessionFactories = make(map[types.Protocol]types.HealthCheckSessionFactory)
+	sessionFactories[protocol.HTTP1] = &HTTPSessionFactory{}
 	commonCallbacks = make(map[string]types.HealthCheckCb)
 }
 
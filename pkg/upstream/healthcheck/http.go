@@ -0,0 +1,284 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the default check is "GET /", the 2xx responses are healthy
+const defaultHTTPCheckPath = "/"
+
+var defaultExpectedStatuses = []StatusRange{{Start: 200, End: 300}}
+
+// HTTPCheckConfig is the check_config of the http1 health check
+type HTTPCheckConfig struct {
+	// Path is the request path, the default is "/"
+	Path string `json:"path,omitempty"`
+	// Host is the host header, the default is the host address
+	Host string `json:"host,omitempty"`
+	// Timeout is the timeout of a request, the default is DefaultTimeout
+	Timeout v2.DurationConfig `json:"timeout,omitempty"`
+	// ExpectedStatuses are the status codes taken as healthy, the default is 200-299
+	ExpectedStatuses []StatusRange `json:"expected_statuses,omitempty"`
+}
+
+// StatusRange contains the status codes in [Start, End)
+type StatusRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func parseHTTPCheckConfig(cfg map[string]interface{}) (HTTPCheckConfig, error) {
+	c := HTTPCheckConfig{}
+	if len(cfg) > 0 {
+		b, err := json.Marshal(cfg)
+		if err != nil {
+			return c, err
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return c, err
+		}
+	}
+	if c.Path == "" {
+		c.Path = defaultHTTPCheckPath
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return c, fmt.Errorf("invalid path %s", c.Path)
+	}
+	if c.Timeout.Duration < 0 {
+		return c, fmt.Errorf("negative timeout %s", c.Timeout.Duration)
+	}
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = DefaultTimeout
+	}
+	if len(c.ExpectedStatuses) == 0 {
+		c.ExpectedStatuses = defaultExpectedStatuses
+	}
+	for _, r := range c.ExpectedStatuses {
+		if r.Start < 100 || r.End > 600 || r.Start >= r.End {
+			return c, fmt.Errorf("invalid expected status range [%d, %d)", r.Start, r.End)
+		}
+	}
+	return c, nil
+}
+
+type HTTPSessionFactory struct{}
+
+func (f *HTTPSessionFactory) NewSession(cfg map[string]interface{}, host types.Host) types.HealthCheckSession {
+	c, err := parseHTTPCheckConfig(cfg)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] invalid check config for host %s: %v", host.AddressString(), err)
+		return nil
+	}
+	return &HTTPSession{
+		host:   host,
+		config: c,
+	}
+}
+
+// HTTPSession sends the check request on a dedicated http1 connection,
+// the connection is not created by the connection pool, so the check requests are not counted in the upstream requests.
+// The connection is kept between the checks, and closed if a check is reset or timeout.
+type HTTPSession struct {
+	host   types.Host
+	config HTTPCheckConfig
+	mutex  sync.Mutex
+	client str.Client
+}
+
+func (s *HTTPSession) CheckHealth() bool {
+	client, err := s.getClient()
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] connect to host %s error: %v", s.host.AddressString(), err)
+		return false
+	}
+	ctx := context.Background()
+	resp := &httpCheckResponse{
+		status: make(chan int, 1),
+	}
+	sender := client.NewStream(ctx, resp)
+	sender.GetStream().AddEventListener(resp)
+	if err := sender.AppendHeaders(ctx, s.newRequest(), true); err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] send request to host %s error: %v", s.host.AddressString(), err)
+		s.closeClient(client)
+		return false
+	}
+	timer := time.NewTimer(s.config.Timeout.Duration)
+	defer timer.Stop()
+	select {
+	case code := <-resp.status:
+		if code == 0 {
+			log.DefaultLogger.Errorf("[upstream] [health check] [http session] request to host %s is reset", s.host.AddressString())
+			s.closeClient(client)
+			return false
+		}
+		// the connection is not reused after a "Connection: close" response
+		s.mutex.Lock()
+		goAway := s.client != client
+		s.mutex.Unlock()
+		if goAway {
+			client.Close()
+		}
+		if !s.isExpected(code) {
+			log.DefaultLogger.Errorf("[upstream] [health check] [http session] host %s responses unexpected status %d", s.host.AddressString(), code)
+			return false
+		}
+		return true
+	case <-timer.C:
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] request to host %s timeout", s.host.AddressString())
+		s.closeClient(client)
+		return false
+	}
+}
+
+// OnTimeout closes the connection, the running check is reset
+func (s *HTTPSession) OnTimeout() {
+	s.mutex.Lock()
+	client := s.client
+	s.mutex.Unlock()
+	if client != nil {
+		s.closeClient(client)
+	}
+}
+
+func (s *HTTPSession) newRequest() mosnhttp.RequestHeader {
+	header := mosnhttp.RequestHeader{
+		RequestHeader: &fasthttp.RequestHeader{},
+	}
+	header.Set(protocol.MosnHeaderMethod, http.MethodGet)
+	header.Set(protocol.MosnHeaderPathKey, s.config.Path)
+	if s.config.Host != "" {
+		header.Set(protocol.MosnHeaderHostKey, s.config.Host)
+	}
+	return header
+}
+
+func (s *HTTPSession) isExpected(code int) bool {
+	for _, r := range s.config.ExpectedStatuses {
+		if code >= r.Start && code < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *HTTPSession) getClient() (str.Client, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	ctx := context.Background()
+	data := s.host.CreateConnection(ctx)
+	if err := data.Connection.Connect(); err != nil {
+		return nil, err
+	}
+	client := str.NewStreamClient(ctx, protocol.HTTP1, data.Connection, data.HostInfo)
+	if client == nil {
+		data.Connection.Close(types.NoFlush, types.LocalClose)
+		return nil, errors.New("http1 stream is not registered")
+	}
+	listener := &httpSessionListener{
+		session: s,
+		client:  client,
+	}
+	client.SetStreamConnectionEventListener(listener)
+	client.AddConnectionEventListener(listener)
+	s.client = client
+	return client, nil
+}
+
+// closeClient closes the connection, the close event releases the client
+func (s *HTTPSession) closeClient(client str.Client) {
+	client.Close()
+	s.releaseClient(client)
+}
+
+func (s *HTTPSession) releaseClient(client str.Client) {
+	s.mutex.Lock()
+	if s.client == client {
+		s.client = nil
+	}
+	s.mutex.Unlock()
+}
+
+// httpSessionListener releases the session's client if the connection is closed
+// types.ConnectionEventListener
+// types.StreamConnectionEventListener
+type httpSessionListener struct {
+	session *HTTPSession
+	client  str.Client
+}
+
+func (l *httpSessionListener) OnEvent(event types.ConnectionEvent) {
+	if event.IsClose() || event.ConnectFailure() {
+		l.session.releaseClient(l.client)
+	}
+}
+
+// OnGoAway is called if the response contains "Connection: close", the connection is not reused
+func (l *httpSessionListener) OnGoAway() {
+	l.session.releaseClient(l.client)
+}
+
+// httpCheckResponse receives the status code of the check request, the status is 0 if the request is reset
+// types.StreamReceiveListener
+// types.StreamEventListener
+type httpCheckResponse struct {
+	status chan int
+}
+
+func (r *httpCheckResponse) notify(code int) {
+	select {
+	case r.status <- code:
+	default:
+	}
+}
+
+func (r *httpCheckResponse) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	if resp, ok := headers.(mosnhttp.ResponseHeader); ok {
+		r.notify(resp.StatusCode())
+		return
+	}
+	r.notify(0)
+}
+
+func (r *httpCheckResponse) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+	r.notify(0)
+}
+
+func (r *httpCheckResponse) OnResetStream(reason types.StreamResetReason) {
+	r.notify(0)
+}
+
+func (r *httpCheckResponse) OnDestroyStream() {}
@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	_ "sofastack.io/sofa-mosn/pkg/stream/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// checkServer responses the status stored in status, the path "/slow" is never responsed in time
+type checkServer struct {
+	*httptest.Server
+	status int32
+	host   atomic.Value
+}
+
+func newCheckServer() *checkServer {
+	s := &checkServer{
+		status: http.StatusOK,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.host.Store(r.Host)
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+	}))
+	return s
+}
+
+func (s *checkServer) Addr() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func TestHTTPCheckConfig(t *testing.T) {
+	c, err := parseHTTPCheckConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Path != "/" || c.Host != "" || c.Timeout.Duration != DefaultTimeout ||
+		len(c.ExpectedStatuses) != 1 || c.ExpectedStatuses[0] != (StatusRange{200, 300}) {
+		t.Errorf("unexpected default config %+v", c)
+	}
+	c, err = parseHTTPCheckConfig(map[string]interface{}{
+		"path":    "/health",
+		"host":    "check.mosn.io",
+		"timeout": "3s",
+		"expected_statuses": []interface{}{
+			map[string]interface{}{"start": 200, "end": 201},
+			map[string]interface{}{"start": 503, "end": 504},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Path != "/health" || c.Host != "check.mosn.io" || c.Timeout.Duration != 3*time.Second ||
+		len(c.ExpectedStatuses) != 2 || c.ExpectedStatuses[1] != (StatusRange{503, 504}) {
+		t.Errorf("unexpected config %+v", c)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"path": "health"},
+		{"timeout": "-1s"},
+		{"expected_statuses": []interface{}{map[string]interface{}{"start": 300, "end": 200}}},
+		{"expected_statuses": []interface{}{map[string]interface{}{"start": 0, "end": 200}}},
+	} {
+		if _, err := parseHTTPCheckConfig(cfg); err == nil {
+			t.Errorf("config %v expected an error", cfg)
+		}
+	}
+	if s := (&HTTPSessionFactory{}).NewSession(map[string]interface{}{"path": "health"}, &mockHost{}); s != nil {
+		t.Error("invalid config should not create a session")
+	}
+}
+
+func TestHTTPSession(t *testing.T) {
+	srv := newCheckServer()
+	defer srv.Close()
+	host := &mockHost{
+		addr: srv.Addr(),
+	}
+	session := (&HTTPSessionFactory{}).NewSession(map[string]interface{}{
+		"host": "check.mosn.io",
+	}, host).(*HTTPSession)
+	if !session.CheckHealth() {
+		t.Fatal("check a 200 response, but returns fail")
+	}
+	if h := srv.host.Load(); h != "check.mosn.io" {
+		t.Errorf("unexpected host header %v", h)
+	}
+	// the connection is reused
+	client := session.client
+	if client == nil {
+		t.Fatal("connection is not kept")
+	}
+	atomic.StoreInt32(&srv.status, http.StatusServiceUnavailable)
+	if session.CheckHealth() {
+		t.Error("check a 503 response, but returns ok")
+	}
+	if session.client != client {
+		t.Error("connection should be reused after an unexpected status")
+	}
+	// the expected status is configurable
+	session.config.ExpectedStatuses = []StatusRange{{Start: 503, End: 504}}
+	if !session.CheckHealth() {
+		t.Error("check an expected 503 response, but returns fail")
+	}
+	// the connection is closed if the check is timeout
+	session.config.Path = "/slow"
+	session.config.Timeout.Duration = 100 * time.Millisecond
+	if session.CheckHealth() {
+		t.Error("check timeout, but returns ok")
+	}
+	if session.client != nil {
+		t.Error("connection should be closed after timeout")
+	}
+	srv.Close()
+	if session.CheckHealth() {
+		t.Error("check a closed server, but returns ok")
+	}
+}
+
+func TestHTTPHealthCheck(t *testing.T) {
+	srv := newCheckServer()
+	defer srv.Close()
+	host := &mockHost{
+		addr: srv.Addr(),
+	}
+	cfg := v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			Protocol:           string(protocol.HTTP1),
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 2,
+			ServiceName:        "test_http",
+			SessionConfig: map[string]interface{}{
+				"path": "/health",
+			},
+		},
+		Timeout:  time.Second,
+		Interval: 50 * time.Millisecond,
+	}
+	hc := CreateHealthCheck(cfg)
+	hc.SetHealthCheckerHostSet(&mockHostSet{
+		hosts: []types.Host{host},
+	})
+	hc.Start()
+	defer hc.Stop()
+	time.Sleep(300 * time.Millisecond)
+	if host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
+		t.Fatal("host should be healthy")
+	}
+	atomic.StoreInt32(&srv.status, http.StatusInternalServerError)
+	time.Sleep(300 * time.Millisecond)
+	if !host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
+		t.Fatal("host should be unhealthy")
+	}
+	atomic.StoreInt32(&srv.status, http.StatusOK)
+	time.Sleep(300 * time.Millisecond)
+	if host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
+		t.Fatal("host should be healthy again")
+	}
+	stats := host.HostStats()
+	if stats.HealthCheckAttempt.Count() == 0 || stats.HealthCheckSuccess.Count() == 0 || stats.HealthCheckFailure.Count() < 2 {
+		t.Errorf("unexpected host stats, attempt: %d, success: %d, failure: %d",
+			stats.HealthCheckAttempt.Count(), stats.HealthCheckSuccess.Count(), stats.HealthCheckFailure.Count())
+	}
+}
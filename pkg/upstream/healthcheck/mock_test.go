@@ -18,9 +18,13 @@
 package healthcheck
 
 import (
+	"context"
+	"net"
 	"sync"
 	"time"
 
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -72,6 +76,20 @@ type mockHost struct {
 	delay  time.Duration
 	lock   sync.Mutex
 	status bool
+	stats  *types.HostStats
+}
+
+func (h *mockHost) HostStats() types.HostStats {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.stats == nil {
+		h.stats = &types.HostStats{
+			HealthCheckAttempt: gometrics.NewCounter(),
+			HealthCheckSuccess: gometrics.NewCounter(),
+			HealthCheckFailure: gometrics.NewCounter(),
+		}
+	}
+	return *h.stats
 }
 
 func (h *mockHost) SetHealth(health bool) {
@@ -91,14 +109,29 @@ func (h *mockHost) AddressString() string {
 	return h.addr
 }
 
+func (h *mockHost) CreateConnection(ctx context.Context) types.CreateConnectionData {
+	addr, _ := net.ResolveTCPAddr("tcp", h.addr)
+	return types.CreateConnectionData{
+		Connection: network.NewClientConnection(nil, time.Second, nil, addr, nil),
+		HostInfo:   h,
+	}
+}
+
+// the health flags are set by the checker and read by the test concurrently
 func (h *mockHost) ClearHealthFlag(flag types.HealthFlag) {
+	h.lock.Lock()
 	h.flag &= ^uint64(flag)
+	h.lock.Unlock()
 }
 
 func (h *mockHost) ContainHealthFlag(flag types.HealthFlag) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 	return h.flag&uint64(flag) > 0
 }
 
 func (h *mockHost) SetHealthFlag(flag types.HealthFlag) {
+	h.lock.Lock()
 	h.flag |= uint64(flag)
+	h.lock.Unlock()
 }
@@ -119,6 +119,7 @@ func (c *sessionChecker) Stop() {
 
 func (c *sessionChecker) HandleSuccess() {
 	c.unHealthCount = 0
+	c.Host.HostStats().HealthCheckSuccess.Inc(1)
 	changed := false
 	if c.Host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
 		c.healthCount++
@@ -133,6 +134,7 @@ func (c *sessionChecker) HandleSuccess() {
 
 func (c *sessionChecker) HandleFailure(reason types.FailureType) {
 	c.healthCount = 0
+	c.Host.HostStats().HealthCheckFailure.Inc(1)
 	changed := false
 	if !c.Host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
 		c.unHealthCount++
@@ -149,6 +151,7 @@ func (c *sessionChecker) OnCheck() {
 	// record current id
 	id := atomic.LoadUint64(&c.checkID)
 	c.HealthChecker.stats.attempt.Inc(1)
+	c.Host.HostStats().HealthCheckAttempt.Inc(1)
 	// start a timeout before check health
 	c.checkTimeout.Stop()
 	c.checkTimeout = utils.NewTimer(c.HealthChecker.timeout, c.OnTimeout)
@@ -18,16 +18,18 @@
 package utils
 
 import (
-	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // WriteFileSafety trys to over write a file safety.
+// the data is written into a temp file and synced, then the temp file is renamed to the target,
+// so the target is always a complete one even if the process crashes during the write.
 func WriteFileSafety(filename string, data []byte, perm os.FileMode) (err error) {
 	tempFile := filename + ".tmp"
 Try:
 	for i := 0; i < 5; i++ {
-		err = ioutil.WriteFile(tempFile, data, perm)
+		err = writeFileSync(tempFile, data, perm)
 		if err == nil {
 			break Try
 		}
@@ -35,6 +37,34 @@ Try:
 	if err != nil {
 		return err
 	}
-	err = os.Rename(tempFile, filename)
-	return
+	if err = os.Rename(tempFile, filename); err != nil {
+		return err
+	}
+	// sync the directory, makes the rename durable
+	return syncDir(filepath.Dir(filename))
+}
+
+func writeFileSync(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
@@ -47,3 +47,24 @@ func TestWriteFileSafety(t *testing.T) {
 		t.Fatal("target file stat verify failed: ", f.Mode())
 	}
 }
+
+func TestWriteFileSafetyOverwrite(t *testing.T) {
+	target := "/tmp/test_write_file_safety_overwrite"
+	defer os.Remove(target)
+	for _, data := range [][]byte{[]byte("a long long data"), []byte("short")} {
+		if err := WriteFileSafety(target, data, 0644); err != nil {
+			t.Fatal("write file error: ", err)
+		}
+		b, err := ioutil.ReadFile(target)
+		if err != nil {
+			t.Fatal("read target file failed: ", err)
+		}
+		if !bytes.Equal(data, b) {
+			t.Errorf("write data is not expected, got %s", string(b))
+		}
+		// the temp file is renamed
+		if _, err := os.Stat(target + ".tmp"); !os.IsNotExist(err) {
+			t.Error("temp file is still exists")
+		}
+	}
+}
@@ -122,9 +122,10 @@ func ConvertClustersConfig(xdsClusters []*xdsapi.Cluster) []*v2.Cluster {
 			HealthCheck:          convertHealthChecks(xdsCluster.GetHealthChecks()),
 			CirBreThresholds:     convertCircuitBreakers(xdsCluster.GetCircuitBreakers()),
 			//OutlierDetection:     convertOutlierDetection(xdsCluster.GetOutlierDetection()),
-			Hosts: convertClusterHosts(xdsCluster.GetHosts()),
-			Spec:  convertSpec(xdsCluster),
-			TLS:   convertTLS(xdsCluster.GetTlsContext()),
+			Hosts:               convertClusterHosts(xdsCluster.GetHosts()),
+			Spec:                convertSpec(xdsCluster),
+			TLS:                 convertTLS(xdsCluster.GetTlsContext()),
+			OriginalDstLbConfig: convertOriginalDstLbConfig(xdsCluster),
 		}
 
 		clusters = append(clusters, cluster)
@@ -446,6 +447,12 @@ func convertFilterChains(xdsFilterChains []xdslistener.FilterChain) []v2.FilterC
 				tlsConfig,
 			},
 		}
+		// the filter chains are selected by the server names
+		if serverNames := xdsFilterChain.GetFilterChainMatch().GetServerNames(); len(serverNames) > 0 {
+			filterChain.MatchConfig = &v2.FilterChainMatchConfig{
+				ServerNames: serverNames,
+			}
+		}
 		filterChains = append(filterChains, filterChain)
 	}
 	return filterChains
@@ -550,8 +557,8 @@ func convertFilterConfig(name string, s *types.Struct) map[string]map[string]int
 		}
 		if routersMngIns := router.GetRoutersMangerInstance(); routersMngIns == nil {
 			log.DefaultLogger.Errorf("xds AddOrUpdateRouters error: router manager in nil")
-		} else {
-			routersMngIns.AddOrUpdateRouters(routerConfig)
+		} else if err := routersMngIns.AddOrUpdateRouters(routerConfig); err != nil {
+			log.DefaultLogger.Errorf("xds AddOrUpdateRouters %s error: %v", routerConfigName, err)
 		}
 		filtersConfigParsed[v2.CONNECTION_MANAGER] = toMap(routerConfig)
 	} else {
@@ -627,6 +634,7 @@ func ConvertRouterConf(routeConfigName string, xdsRouteConfig *xdsapi.RouteConfi
 			//RequireTLS:              xdsVirtualHost.GetRequireTls().String(),
 			//VirtualClusters:         convertVirtualClusters(xdsVirtualHost.GetVirtualClusters()),
 			RequestHeadersToAdd:     convertHeadersToAdd(xdsVirtualHost.GetRequestHeadersToAdd()),
+			RequestHeadersToRemove:  xdsVirtualHost.GetRequestHeadersToRemove(),
 			ResponseHeadersToAdd:    convertHeadersToAdd(xdsVirtualHost.GetResponseHeadersToAdd()),
 			ResponseHeadersToRemove: xdsVirtualHost.GetResponseHeadersToRemove(),
 		}
@@ -637,6 +645,7 @@ func ConvertRouterConf(routeConfigName string, xdsRouteConfig *xdsapi.RouteConfi
 		RouterConfigurationConfig: v2.RouterConfigurationConfig{
 			RouterConfigName:        xdsRouteConfig.GetName(),
 			RequestHeadersToAdd:     convertHeadersToAdd(xdsRouteConfig.GetRequestHeadersToAdd()),
+			RequestHeadersToRemove:  xdsRouteConfig.GetRequestHeadersToRemove(),
 			ResponseHeadersToAdd:    convertHeadersToAdd(xdsRouteConfig.GetResponseHeadersToAdd()),
 			ResponseHeadersToRemove: xdsRouteConfig.GetResponseHeadersToRemove(),
 		},
@@ -660,6 +669,7 @@ func convertRoutes(xdsRoutes []xdsroute.Route) []v2.Router {
 				Metadata: convertMeta(xdsRoute.GetMetadata()),
 			}
 			route.PerFilterConfig = convertPerRouteConfig(xdsRoute.PerFilterConfig)
+			route.Route.RequestHeadersToRemove = xdsRoute.GetRequestHeadersToRemove()
 			routes = append(routes, route)
 		} else if xdsRouteAction := xdsRoute.GetRedirect(); xdsRouteAction != nil {
 			route := v2.Router{
@@ -727,8 +737,24 @@ func convertRouteMatch(xdsRouteMatch xdsroute.RouteMatch) v2.RouterMatch {
 		Regex:  xdsRouteMatch.GetRegex(),
 		//CaseSensitive: xdsRouteMatch.GetCaseSensitive().GetValue(),
 		//Runtime:       convertRuntime(xdsRouteMatch.GetRuntime()),
-		Headers: convertHeaders(xdsRouteMatch.GetHeaders()),
+		Headers:         convertHeaders(xdsRouteMatch.GetHeaders()),
+		QueryParameters: convertQueryParameters(xdsRouteMatch.GetQueryParameters()),
+	}
+}
+
+func convertQueryParameters(xdsQueryParameters []*xdsroute.QueryParameterMatcher) []v2.QueryParameterMatcher {
+	if xdsQueryParameters == nil {
+		return nil
+	}
+	queryParameters := make([]v2.QueryParameterMatcher, 0, len(xdsQueryParameters))
+	for _, xdsQueryParameter := range xdsQueryParameters {
+		queryParameters = append(queryParameters, v2.QueryParameterMatcher{
+			Name:  xdsQueryParameter.GetName(),
+			Value: xdsQueryParameter.GetValue(),
+			Regex: xdsQueryParameter.GetRegex().GetValue(),
+		})
 	}
+	return queryParameters
 }
 
 /*
@@ -749,15 +775,22 @@ func convertHeaders(xdsHeaders []*xdsroute.HeaderMatcher) []v2.HeaderMatcher {
 	}
 	headerMatchers := make([]v2.HeaderMatcher, 0, len(xdsHeaders))
 	for _, xdsHeader := range xdsHeaders {
-		headerMatcher := v2.HeaderMatcher{}
-		if xdsHeader.GetRegexMatch() != "" {
-			headerMatcher.Name = xdsHeader.GetName()
+		headerMatcher := v2.HeaderMatcher{
+			Name:   xdsHeader.GetName(),
+			Invert: xdsHeader.GetInvertMatch(),
+		}
+		switch {
+		case xdsHeader.GetRegexMatch() != "":
 			headerMatcher.Value = xdsHeader.GetRegexMatch()
 			headerMatcher.Regex = true
-		} else {
-			headerMatcher.Name = xdsHeader.GetName()
+		case xdsHeader.GetPrefixMatch() != "":
+			headerMatcher.Prefix = xdsHeader.GetPrefixMatch()
+		case xdsHeader.GetSuffixMatch() != "":
+			headerMatcher.Suffix = xdsHeader.GetSuffixMatch()
+		case xdsHeader.GetPresentMatch():
+			headerMatcher.Present = true
+		default:
 			headerMatcher.Value = xdsHeader.GetExactMatch()
-			headerMatcher.Regex = false
 		}
 
 		// as pseudo headers not support when Http1.x upgrade to Http2, change pseudo headers to normal headers
@@ -797,6 +830,7 @@ func convertRouteAction(xdsRouteAction *xdsroute.RouteAction) v2.RouteAction {
 			RequestHeadersToAdd:     convertHeadersToAdd(xdsRouteAction.GetRequestHeadersToAdd()),
 			ResponseHeadersToAdd:    convertHeadersToAdd(xdsRouteAction.GetResponseHeadersToAdd()),
 			ResponseHeadersToRemove: xdsRouteAction.GetResponseHeadersToRemove(),
+			Priority:                convertRoutingPriority(xdsRouteAction.GetPriority()),
 		},
 		MetadataMatch: convertMeta(xdsRouteAction.GetMetadataMatch()),
 		Timeout:       convertTimeDurPoint2TimeDur(xdsRouteAction.GetTimeout()),
@@ -853,8 +887,9 @@ func convertWeightedCluster(xdsWeightedCluster *xdsroute.WeightedCluster_Cluster
 	}
 	return v2.ClusterWeight{
 		ClusterWeightConfig: v2.ClusterWeightConfig{
-			Name:   xdsWeightedCluster.GetName(),
-			Weight: xdsWeightedCluster.GetWeight().GetValue(),
+			Name:                xdsWeightedCluster.GetName(),
+			Weight:              xdsWeightedCluster.GetWeight().GetValue(),
+			RequestHeadersToAdd: convertHeadersToAdd(xdsWeightedCluster.GetRequestHeadersToAdd()),
 		},
 		MetadataMatch: convertMeta(xdsWeightedCluster.GetMetadataMatch()),
 	}
@@ -864,10 +899,19 @@ func convertRetryPolicy(xdsRetryPolicy *xdsroute.RetryPolicy) *v2.RetryPolicy {
 	if xdsRetryPolicy == nil {
 		return &v2.RetryPolicy{}
 	}
+	var conditions []string
+	for _, cond := range strings.Split(xdsRetryPolicy.GetRetryOn(), ",") {
+		switch cond = strings.TrimSpace(cond); cond {
+		case v2.RetryOn5xx, v2.RetryOnConnectFailure, v2.RetryOnReset, v2.RetryOnRetriableStatusCodes:
+			conditions = append(conditions, cond)
+		}
+	}
 	return &v2.RetryPolicy{
 		RetryPolicyConfig: v2.RetryPolicyConfig{
-			RetryOn:    len(xdsRetryPolicy.GetRetryOn()) > 0,
-			NumRetries: xdsRetryPolicy.GetNumRetries().GetValue(),
+			RetryOn:              len(xdsRetryPolicy.GetRetryOn()) > 0,
+			NumRetries:           xdsRetryPolicy.GetNumRetries().GetValue(),
+			RetryOnConditions:    conditions,
+			RetriableStatusCodes: xdsRetryPolicy.GetRetriableStatusCodes(),
 		},
 		RetryTimeout: convertTimeDurPoint2TimeDur(xdsRetryPolicy.GetPerTryTimeout()),
 	}
@@ -938,11 +982,27 @@ func convertClusterType(xdsClusterType xdsapi.Cluster_DiscoveryType) v2.ClusterT
 	case xdsapi.Cluster_EDS:
 		return v2.EDS_CLUSTER
 	case xdsapi.Cluster_ORIGINAL_DST:
+		return v2.ORIGINAL_DST_CLUSTER
 	}
 	//log.DefaultLogger.Fatalf("unsupported cluster type: %s, exchange to SIMPLE_CLUSTER", xdsClusterType.String())
 	return v2.SIMPLE_CLUSTER
 }
 
+// convertOriginalDstLbConfig returns nil if the cluster is not an ORIGINAL_DST one,
+// the cleanup interval is used as the idle timeout of the destination hosts
+func convertOriginalDstLbConfig(xdsCluster *xdsapi.Cluster) *v2.OriginalDstLbConfig {
+	if xdsCluster.GetType() != xdsapi.Cluster_ORIGINAL_DST {
+		return nil
+	}
+	config := &v2.OriginalDstLbConfig{
+		UseHTTPHeader: xdsCluster.GetOriginalDstLbConfig().GetUseHttpHeader(),
+	}
+	if interval := xdsCluster.GetCleanupInterval(); interval != nil {
+		config.IdleTimeout = &v2.DurationConfig{Duration: *interval}
+	}
+	return config
+}
+
 func convertLbPolicy(xdsLbPolicy xdsapi.Cluster_LbPolicy) v2.LbType {
 	switch xdsLbPolicy {
 	case xdsapi.Cluster_ROUND_ROBIN:
@@ -1017,6 +1077,7 @@ func convertCircuitBreakers(xdsCircuitBreaker *xdscluster.CircuitBreakers) v2.Ci
 			continue
 		}
 		threshold := v2.Thresholds{
+			Priority:           convertRoutingPriority(xdsThreshold.GetPriority()),
 			MaxConnections:     xdsThreshold.GetMaxConnections().GetValue(),
 			MaxPendingRequests: xdsThreshold.GetMaxPendingRequests().GetValue(),
 			MaxRequests:        xdsThreshold.GetMaxRequests().GetValue(),
@@ -1029,6 +1090,14 @@ func convertCircuitBreakers(xdsCircuitBreaker *xdscluster.CircuitBreakers) v2.Ci
 	}
 }
 
+// convertRoutingPriority keeps the default priority empty
+func convertRoutingPriority(priority xdscore.RoutingPriority) v2.RoutingPriority {
+	if priority == xdscore.RoutingPriority_HIGH {
+		return v2.HIGH_PRIORITY
+	}
+	return ""
+}
+
 /*
 func convertOutlierDetection(xdsOutlierDetection *xdscluster.OutlierDetection) v2.OutlierDetection {
 	if xdsOutlierDetection == nil || xdsOutlierDetection.Size() == 0 {
@@ -48,7 +48,9 @@ func ConvertAddOrUpdateRouters(routers []*envoy_api_v2.RouteConfiguration) {
 
 			mosnRouter, _ := ConvertRouterConf("", router)
 			log.DefaultLogger.Tracef("mosnRouter config: %+v", mosnRouter)
-			routersMngIns.AddOrUpdateRouters(mosnRouter)
+			if err := routersMngIns.AddOrUpdateRouters(mosnRouter); err != nil {
+				log.DefaultLogger.Errorf("xds AddOrUpdateRouters %s error: %v", mosnRouter.RouterConfigName, err)
+			}
 		}
 	}
 }
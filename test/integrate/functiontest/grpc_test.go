@@ -0,0 +1,126 @@
+package functiontest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	_ "sofastack.io/sofa-mosn/pkg/stream/http2"
+	"sofastack.io/sofa-mosn/test/integrate"
+)
+
+const (
+	grpcEchoMethod = "/mosn.test.Echo/Echo"
+	// the echo server answers the request with the grpc status or sleeps
+	grpcEchoNotFound = "notfound"
+	grpcEchoSleep    = "sleep"
+)
+
+type grpcEchoServer struct{}
+
+func (s *grpcEchoServer) Echo(ctx context.Context, in *types.StringValue) (*types.StringValue, error) {
+	switch in.Value {
+	case grpcEchoNotFound:
+		return nil, status.Error(codes.NotFound, "no such echo")
+	case grpcEchoSleep:
+		time.Sleep(2 * time.Second)
+	}
+	return &types.StringValue{Value: in.Value}, nil
+}
+
+func grpcEchoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(*grpcEchoServer).Echo(ctx, in)
+}
+
+var grpcEchoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mosn.test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Echo",
+			Handler:    grpcEchoHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+type grpcUpstream struct {
+	t        *testing.T
+	addr     string
+	server   *grpc.Server
+	listener net.Listener
+}
+
+func newGrpcUpstream(t *testing.T, addr string) *grpcUpstream {
+	s := grpc.NewServer()
+	s.RegisterService(&grpcEchoServiceDesc, &grpcEchoServer{})
+	return &grpcUpstream{t: t, addr: addr, server: s}
+}
+
+func (s *grpcUpstream) GoServe() {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.t.Fatalf("listen %s failed, error : %v\n", s.addr, err)
+	}
+	s.listener = ln
+	go s.server.Serve(ln)
+}
+
+func (s *grpcUpstream) Close() {
+	s.server.Stop()
+}
+
+func (s *grpcUpstream) Addr() string {
+	return s.addr
+}
+
+func grpcEcho(conn *grpc.ClientConn, value string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out := new(types.StringValue)
+	if err := conn.Invoke(ctx, grpcEchoMethod, &types.StringValue{Value: value}, out); err != nil {
+		return "", err
+	}
+	return out.Value, nil
+}
+
+func TestGrpcProxy(t *testing.T) {
+	appaddr := "127.0.0.1:8080"
+	server := newGrpcUpstream(t, appaddr)
+	c := integrate.NewTestCase(t, protocol.HTTP2, protocol.HTTP2, server)
+	c.StartProxy()
+	defer c.FinishCase()
+
+	conn, err := grpc.Dial(c.ClientMeshAddr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("dial mosn failed: %v", err)
+	}
+	defer conn.Close()
+
+	if out, err := grpcEcho(conn, "hello", 5*time.Second); err != nil || out != "hello" {
+		t.Fatalf("expected hello echoed, but got %s, error: %v", out, err)
+	}
+	// the grpc-status in the trailers is proxied
+	if _, err := grpcEcho(conn, grpcEchoNotFound, 5*time.Second); status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found, but got %v", err)
+	}
+	// the grpc-timeout is the timeout of the proxy
+	if _, err := grpcEcho(conn, grpcEchoSleep, 500*time.Millisecond); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, but got %v", err)
+	}
+	// the upstream connection failure is unavailable
+	server.Close()
+	if _, err := grpcEcho(conn, "hello", 5*time.Second); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected unavailable, but got %v", err)
+	}
+}
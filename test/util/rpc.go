@@ -173,7 +173,7 @@ func buildBoltV1Request(request *sofarpc.BoltRequest) *sofarpc.BoltRequest {
 	headers := map[string]string{"service": "testSofa"} // used for sofa routing
 
 	buf := buffer.NewIoBuffer(100)
-	if err := serialize.Instance.SerializeMap(headers, buf); err != nil {
+	if err := serialize.GetSerialization(request.Codec).SerializeMap(headers, buf); err != nil {
 		panic("serialize headers error")
 	} else {
 		request.HeaderMap = buf.Bytes()